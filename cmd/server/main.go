@@ -4,15 +4,53 @@ import (
 	"erp-excel/config"
 	"erp-excel/database"
 	"erp-excel/internal/app"
+	"flag"
+	"fmt"
+	"os"
 )
 
 func main() {
+	check := flag.Bool("check", false, "verify the app and ERP database schemas and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-check] [migrate [status] | encrypt-users | create-api-key -name <name> [-scopes <codes>] [-expires <date>]]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
 	// Load configuration
 	cfg := config.MustConfig()
 
 	// Connect to database
 	db := database.MustDatabase(cfg)
 
+	// Preflight check only
+	if *check {
+		code := app.RunSchemaCheck(cfg, db)
+		db.Close()
+		os.Exit(code)
+	}
+
+	// Apply the app schema migrations only
+	if flag.Arg(0) == "migrate" {
+		code := app.RunMigrate(db, flag.Args()[1:])
+		db.Close()
+		os.Exit(code)
+	}
+
+	// Encrypt the fields of the existing users only
+	if flag.Arg(0) == "encrypt-users" {
+		code := app.RunEncryptUsers(cfg, db, flag.Args()[1:])
+		db.Close()
+		os.Exit(code)
+	}
+
+	// Issue a service account key only, such as the first administrator key
+	if flag.Arg(0) == "create-api-key" {
+		code := app.RunCreateAPIKey(cfg, db, flag.Args()[1:])
+		db.Close()
+		os.Exit(code)
+	}
+
 	// Create application
 	application := app.New(cfg, db)
 
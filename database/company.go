@@ -0,0 +1,24 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrUnknownCompany is returned for a company code without an ERP database
+var ErrUnknownCompany = errors.New("unknown company")
+
+type companyKey struct{}
+
+// WithCompany returns a copy of ctx that makes the ERP repositories query the database of the
+// given company. An empty code keeps the default company.
+func WithCompany(ctx context.Context, company string) context.Context {
+	return context.WithValue(ctx, companyKey{}, strings.ToLower(strings.TrimSpace(company)))
+}
+
+// CompanyFromContext returns the company code carried by ctx, empty for the default company
+func CompanyFromContext(ctx context.Context) string {
+	company, _ := ctx.Value(companyKey{}).(string)
+	return company
+}
@@ -7,64 +7,147 @@ import (
 
 	"fmt"
 	"log"
+	"log/slog"
 	"time"
 
-	_ "github.com/denisenkom/go-mssqldb"
+	mssql "github.com/denisenkom/go-mssqldb"
 )
 
 // Database interface
 type Database interface {
 	DB() *sql.DB
-	ERPDatabase() *sql.DB // Add missing method declaration
+	// ReadDB returns the pool the reads of ctx run on: the read replica while it answers, the
+	// primary when there is none, it is down or ctx must read its own writes
+	ReadDB(ctx context.Context) *sql.DB
+	ERPDatabase() *sql.DB // pool of the default company
+	// ERPDatabaseFor returns the pool of the company carried by ctx, see WithCompany
+	ERPDatabaseFor(ctx context.Context) (*sql.DB, error)
+	ERPDatabases() map[string]*sql.DB // by company code
+	// ERPReportDatabaseFor returns the pool the custom SQL reports of the company carried by
+	// ctx run on, that of reports.query_login or the ERP pool when none is configured
+	ERPReportDatabaseFor(ctx context.Context) (*sql.DB, error)
+	// ObserveERPQueries reports every statement run on the ERP databases to the observer
+	ObserveERPQueries(observer QueryObserver)
 	Close() error
 	Ping() error
 }
 
 type database struct {
-	db    *sql.DB
-	erpDB *sql.DB
+	db             *sql.DB
+	replica        *replica           // nil without a read replica
+	erpDBs         map[string]*sql.DB // one pool per company code
+	erpReportDBs   map[string]*sql.DB // pools of reports.query_login by company code, empty without it
+	defaultCompany string
+	observers      queryObservers
 }
 
-// NewDatabase creates a new database connection
+// NewDatabase creates a new database connection, and one ERP connection pool per company
 func NewDatabase(cfg *config.Config) (Database, error) {
 	db, err := sql.Open("sqlserver", cfg.GetDSN())
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to database: %w", err)
 	}
 
-	erpDB, err := sql.Open("sqlserver", cfg.GetERPDatabaseDSN())
-	// log.Printf("Database connection: %v", erpDB)
-	if err != nil {
-		db.Close() // Close the first DB connection if ERP DB fails
-		return nil, fmt.Errorf("error opening ERP database: %w", err)
+	d := &database{
+		db:             db,
+		erpDBs:         make(map[string]*sql.DB),
+		erpReportDBs:   make(map[string]*sql.DB),
+		defaultCompany: cfg.DefaultERPCompany(),
 	}
+	for company, companyConfig := range cfg.ERPCompanyRegistry() {
+		connector, err := mssql.NewConnector(cfg.GetERPCompanyDSN(companyConfig))
+		if err != nil {
+			d.Close() // Close the connections opened so far
+			return nil, fmt.Errorf("error opening ERP database of company %s: %w", company, err)
+		}
+		// The statements of the ERP databases are timed for the slow query log and the metrics
+		erpDB := sql.OpenDB(&instrumentedConnector{Connector: connector, company: company, observers: &d.observers})
+		d.erpDBs[company] = erpDB
+
+		// Kiểm tra kết nối ERP database
+		if err := erpDB.Ping(); err != nil {
+			d.Close()
+			return nil, fmt.Errorf("error pinging ERP database of company %s: %w", company, err)
+		}
+		setPool(erpDB, cfg.ERPDatabase)
 
-	// Kiểm tra kết nối ERP database
-	if err := erpDB.Ping(); err != nil {
-		db.Close() // Close both connections if ping fails
-		erpDB.Close()
-		return nil, fmt.Errorf("error pinging ERP database: %w", err)
+		if cfg.Reports.QueryLogin.User != "" {
+			reportDB, err := openERPReportDatabase(cfg, company, companyConfig, &d.observers)
+			if err != nil {
+				d.Close()
+				return nil, err
+			}
+			d.erpReportDBs[company] = reportDB
+		}
+	}
+	if cfg.Reports.QueryLogin.User == "" {
+		slog.Warn("reports.query_login is not configured; custom SQL reports run as the ERP login")
 	}
 
 	// Set connection pool settings
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(time.Minute * 5)
+	setPool(db, cfg.Database)
+	if cfg.ERPDatabase.ReadOnly && cfg.ERPWriteBack.Enabled && !cfg.ERPWriteBack.DryRun {
+		slog.Warn("ERP database connects read-only while ERP write-back is enabled; write-back fails if the listener routes to a secondary")
+	}
 
 	// Test connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := db.PingContext(ctx); err != nil {
-		db.Close() // Close connections if ping fails
-		erpDB.Close()
+		d.Close() // Close connections if ping fails
 		return nil, fmt.Errorf("error pinging database: %w", err)
 	}
 
-	return &database{
-		db:    db,
-		erpDB: erpDB,
-	}, nil
+	if cfg.DatabaseReplica.Enabled {
+		d.replica, err = openReplica(cfg)
+		if err != nil {
+			d.Close()
+			return nil, fmt.Errorf("error opening read replica: %w", err)
+		}
+	}
+
+	return d, nil
+}
+
+// openERPReportDatabase opens the pool of the custom SQL reports of a company, which connects
+// as reports.query_login
+func openERPReportDatabase(cfg *config.Config, company string, companyConfig config.ERPCompanyConfig, observers *queryObservers) (*sql.DB, error) {
+	connector, err := mssql.NewConnector(cfg.GetERPReportDSN(companyConfig))
+	if err != nil {
+		return nil, fmt.Errorf("error opening report database of company %s: %w", company, err)
+	}
+	reportDB := sql.OpenDB(&instrumentedConnector{Connector: connector, company: company, observers: observers})
+	if err := reportDB.Ping(); err != nil {
+		reportDB.Close()
+		return nil, fmt.Errorf("error pinging report database of company %s: %w", company, err)
+	}
+	setPool(reportDB, cfg.ERPDatabase)
+	return reportDB, nil
+}
+
+// setPool applies the pool settings of one database, defaulting to 25 open and 5 idle
+// connections that are recycled after 5 minutes
+func setPool(db *sql.DB, cfg config.DatabaseConfig) {
+	maxOpen := cfg.MaxOpenConns
+	if maxOpen <= 0 {
+		maxOpen = 25
+	}
+	maxIdle := cfg.MaxIdleConns
+	if maxIdle <= 0 {
+		maxIdle = 5
+	}
+	lifetime := time.Duration(cfg.ConnMaxLifetimeMinutes) * time.Minute
+	if lifetime <= 0 {
+		lifetime = time.Minute * 5
+	}
+
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxIdle)
+	db.SetConnMaxLifetime(lifetime)
+	if cfg.ConnMaxIdleTimeMinutes > 0 {
+		db.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTimeMinutes) * time.Minute)
+	}
 }
 
 // MustDatabase panics if database connection fails
@@ -81,22 +164,88 @@ func (d *database) DB() *sql.DB {
 	return d.db
 }
 
-// ERPDatabase returns the ERP database connection
+// ReadDB returns the read replica while it answers, unless ctx must read from the primary
+func (d *database) ReadDB(ctx context.Context) *sql.DB {
+	if d.replica == nil || primaryReads(ctx) || !d.replica.healthy.Load() {
+		return d.db
+	}
+	return d.replica.db
+}
+
+// ERPDatabase returns the ERP database connection of the default company
 func (d *database) ERPDatabase() *sql.DB {
-	return d.erpDB
+	return d.erpDBs[d.defaultCompany]
+}
+
+// ERPDatabaseFor returns the ERP database connection of the company carried by ctx, the
+// default company when ctx carries none
+func (d *database) ERPDatabaseFor(ctx context.Context) (*sql.DB, error) {
+	company := CompanyFromContext(ctx)
+	if company == "" {
+		company = d.defaultCompany
+	}
+	erpDB, ok := d.erpDBs[company]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCompany, company)
+	}
+	return erpDB, nil
+}
+
+// ERPReportDatabaseFor returns the pool of reports.query_login of the company carried by ctx,
+// the ERP pool of the company when the login is not configured
+func (d *database) ERPReportDatabaseFor(ctx context.Context) (*sql.DB, error) {
+	if len(d.erpReportDBs) == 0 {
+		return d.ERPDatabaseFor(ctx)
+	}
+	company := CompanyFromContext(ctx)
+	if company == "" {
+		company = d.defaultCompany
+	}
+	reportDB, ok := d.erpReportDBs[company]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCompany, company)
+	}
+	return reportDB, nil
+}
+
+// ERPDatabases returns the ERP database connection of every company by company code
+func (d *database) ERPDatabases() map[string]*sql.DB {
+	erpDBs := make(map[string]*sql.DB, len(d.erpDBs))
+	for company, erpDB := range d.erpDBs {
+		erpDBs[company] = erpDB
+	}
+	return erpDBs
 }
 
-// Close closes both database connections
+// ObserveERPQueries reports every statement run on the ERP databases to the observer, replacing
+// the previous one
+func (d *database) ObserveERPQueries(observer QueryObserver) {
+	d.observers.set(observer)
+}
+
+// Close closes the main database and every ERP database connection
 func (d *database) Close() error {
-	// Close both databases and track potential errors
+	// Close all databases and track potential errors
 	var errs []error
 
 	if err := d.db.Close(); err != nil {
 		errs = append(errs, fmt.Errorf("error closing main database: %w", err))
 	}
+	if d.replica != nil {
+		if err := d.replica.close(); err != nil {
+			errs = append(errs, fmt.Errorf("error closing read replica: %w", err))
+		}
+	}
 
-	if err := d.erpDB.Close(); err != nil {
-		errs = append(errs, fmt.Errorf("error closing ERP database: %w", err))
+	for company, erpDB := range d.erpDBs {
+		if err := erpDB.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("error closing ERP database of company %s: %w", company, err))
+		}
+	}
+	for company, reportDB := range d.erpReportDBs {
+		if err := reportDB.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("error closing report database of company %s: %w", company, err))
+		}
 	}
 
 	if len(errs) > 0 {
@@ -108,13 +257,15 @@ func (d *database) Close() error {
 
 // Ping checks if the database connection is alive
 func (d *database) Ping() error {
-	// Ping both databases
+	// Ping all databases
 	if err := d.db.Ping(); err != nil {
 		return fmt.Errorf("error pinging main database: %w", err)
 	}
 
-	if err := d.erpDB.Ping(); err != nil {
-		return fmt.Errorf("error pinging ERP database: %w", err)
+	for company, erpDB := range d.erpDBs {
+		if err := erpDB.Ping(); err != nil {
+			return fmt.Errorf("error pinging ERP database of company %s: %w", company, err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,290 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// Bounds of the parameters recorded with a query
+const (
+	maxArgLength  = 100
+	redactedValue = "<redacted>"
+)
+
+// unlabelledQuery is the label of the queries run without WithQueryLabel
+const unlabelledQuery = "other"
+
+// QueryStats describes one statement run on an ERP database
+type QueryStats struct {
+	Company  string
+	Label    string // the report that ran the query, see WithQueryLabel
+	Query    string // with its whitespace collapsed
+	Args     []string
+	Started  time.Time
+	Duration time.Duration // until the last row was read
+	Rows     int64         // rows read, or rows affected by statements that return none
+	Err      error
+}
+
+// QueryObserver is told about every statement run on the ERP databases, once it is done
+type QueryObserver interface {
+	ObserveQuery(ctx context.Context, stats QueryStats)
+}
+
+type queryLabelKey struct{}
+
+// WithQueryLabel returns a copy of ctx whose ERP queries are recorded under the label, usually
+// the code of the report running them
+func WithQueryLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, queryLabelKey{}, label)
+}
+
+// QueryLabelFromContext returns the label of the ERP queries run with ctx, "other" when it has
+// none
+func QueryLabelFromContext(ctx context.Context) string {
+	if label, _ := ctx.Value(queryLabelKey{}).(string); label != "" {
+		return label
+	}
+	return unlabelledQuery
+}
+
+// queryObservers holds the observer of the ERP queries, which is set once the services using the
+// databases are created
+type queryObservers struct {
+	observer atomic.Pointer[QueryObserver]
+}
+
+func (o *queryObservers) set(observer QueryObserver) {
+	o.observer.Store(&observer)
+}
+
+func (o *queryObservers) observe(ctx context.Context, stats QueryStats) {
+	if observer := o.observer.Load(); observer != nil && *observer != nil {
+		(*observer).ObserveQuery(ctx, stats)
+	}
+}
+
+// instrumentedConnector opens the connections of an ERP database, timing the statements they run
+type instrumentedConnector struct {
+	driver.Connector
+	company   string
+	observers *queryObservers
+}
+
+// erpConn is what the connections of the SQL Server driver implement
+type erpConn interface {
+	driver.Conn
+	driver.ConnPrepareContext
+	driver.ConnBeginTx
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+	driver.NamedValueChecker
+}
+
+// Connect opens a connection. Connections of another driver are not instrumented.
+func (c *instrumentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inner, ok := conn.(erpConn)
+	if !ok {
+		return conn, nil
+	}
+	return &instrumentedConn{erpConn: inner, connector: c}, nil
+}
+
+type instrumentedConn struct {
+	erpConn
+	connector *instrumentedConnector
+}
+
+func (c *instrumentedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.erpConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	inner, ok := stmt.(erpStmt)
+	if !ok {
+		return stmt, nil
+	}
+	return &instrumentedStmt{erpStmt: inner, connector: c.connector, query: query}, nil
+}
+
+// erpStmt is what the statements of the SQL Server driver implement
+type erpStmt interface {
+	driver.Stmt
+	driver.StmtExecContext
+	driver.StmtQueryContext
+}
+
+type instrumentedStmt struct {
+	erpStmt
+	connector *instrumentedConnector
+	query     string
+}
+
+func (s *instrumentedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *instrumentedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	stats := s.stats(ctx, args)
+	result, err := s.erpStmt.ExecContext(ctx, args)
+	stats.Duration = time.Since(stats.Started)
+	stats.Err = err
+	if err == nil {
+		stats.Rows, _ = result.RowsAffected()
+	}
+	s.connector.observers.observe(ctx, stats)
+	return result, err
+}
+
+func (s *instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	stats := s.stats(ctx, args)
+	rows, err := s.erpStmt.QueryContext(ctx, args)
+	if err != nil {
+		stats.Duration = time.Since(stats.Started)
+		stats.Err = err
+		s.connector.observers.observe(ctx, stats)
+		return nil, err
+	}
+	inner, ok := rows.(erpRows)
+	if !ok {
+		stats.Duration = time.Since(stats.Started)
+		s.connector.observers.observe(ctx, stats)
+		return rows, nil
+	}
+	return &instrumentedRows{erpRows: inner, ctx: ctx, stats: stats, observers: s.connector.observers}, nil
+}
+
+// stats starts the stats of a run of the statement
+func (s *instrumentedStmt) stats(ctx context.Context, args []driver.NamedValue) QueryStats {
+	return QueryStats{
+		Company: s.connector.company,
+		Label:   QueryLabelFromContext(ctx),
+		Query:   strings.Join(strings.Fields(s.query), " "),
+		Args:    sanitizeArgs(args),
+		Started: time.Now(),
+	}
+}
+
+// erpRows is what the rows of the SQL Server driver implement
+type erpRows interface {
+	driver.Rows
+	driver.RowsNextResultSet
+	driver.RowsColumnTypeScanType
+	driver.RowsColumnTypeDatabaseTypeName
+	driver.RowsColumnTypeLength
+	driver.RowsColumnTypePrecisionScale
+	driver.RowsColumnTypeNullable
+}
+
+// instrumentedRows counts the rows read and records the query once they are closed
+type instrumentedRows struct {
+	erpRows
+	ctx       context.Context
+	stats     QueryStats
+	observers *queryObservers
+	closed    bool
+}
+
+func (r *instrumentedRows) Next(dest []driver.Value) error {
+	err := r.erpRows.Next(dest)
+	switch {
+	case err == nil:
+		r.stats.Rows++
+	case !errors.Is(err, io.EOF) && r.stats.Err == nil:
+		r.stats.Err = err
+	}
+	return err
+}
+
+func (r *instrumentedRows) Close() error {
+	err := r.erpRows.Close()
+	if !r.closed {
+		r.closed = true
+		r.stats.Duration = time.Since(r.stats.Started)
+		r.observers.observe(r.ctx, r.stats)
+	}
+	return err
+}
+
+// namedValues turns the arguments of the deprecated driver calls into ordinal named values
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+// sanitizeArgs describes the parameters of a query for the logs: long text is cut, binary values
+// show their length and the values of parameters named like a password, secret or token are
+// hidden
+func sanitizeArgs(args []driver.NamedValue) []string {
+	if len(args) == 0 {
+		return nil
+	}
+
+	sanitized := make([]string, len(args))
+	for i, arg := range args {
+		name := arg.Name
+		if name == "" {
+			name = fmt.Sprintf("p%d", arg.Ordinal)
+		}
+		sanitized[i] = "@" + name + "=" + sanitizeArg(name, arg.Value)
+	}
+	return sanitized
+}
+
+func sanitizeArg(name string, value driver.Value) string {
+	lower := strings.ToLower(name)
+	for _, sensitive := range []string{"password", "secret", "token"} {
+		if strings.Contains(lower, sensitive) {
+			return redactedValue
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return fmt.Sprintf("<%d bytes>", len(v))
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+
+	// The driver types of SQL Server, such as VarChar, are kinds of strings
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		text := rv.String()
+		if utf8.RuneCountInString(text) > maxArgLength {
+			text = string([]rune(text)[:maxArgLength]) + "…"
+		}
+		return fmt.Sprintf("%q", text)
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(value)
+	}
+	return fmt.Sprintf("<%T>", value)
+}
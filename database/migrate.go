@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationNamePattern matches migration file names such as 0001_core_tables.sql
+var migrationNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.sql$`)
+
+// batchSeparatorPattern matches the GO lines that split a script into batches, as in sqlcmd
+var batchSeparatorPattern = regexp.MustCompile(`(?im)^\s*GO\s*$`)
+
+const migrationTableSchema = `
+IF OBJECT_ID('schema_migrations', 'U') IS NULL
+CREATE TABLE schema_migrations (
+    version INT PRIMARY KEY,
+    name NVARCHAR(200) NOT NULL,
+    applied_at DATETIME NOT NULL
+);
+`
+
+// Migration is one versioned SQL script of the app database schema
+type Migration struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time // nil while pending
+	script    string
+}
+
+// embeddedMigrations reads the migrations compiled into the binary once
+var embeddedMigrations = sync.OnceValues(readMigrations)
+
+// Migrations returns the embedded migrations, oldest first
+func Migrations() ([]Migration, error) {
+	migrations, err := embeddedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(migrations), nil
+}
+
+// readMigrations reads and orders the embedded migration files
+func readMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %w", err)
+	}
+
+	migrations := make([]Migration, 0, len(entries))
+	seen := make(map[int]string, len(entries))
+	for _, entry := range entries {
+		match := migrationNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", other, entry.Name())
+		}
+		seen[version] = entry.Name()
+		for _, other := range migrations {
+			if other.Name == match[2] {
+				return nil, fmt.Errorf("migrations %04d_%s and %s have the same name", other.Version, other.Name, entry.Name())
+			}
+		}
+
+		script, err := fs.ReadFile(migrationFiles, path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: match[2], script: string(script)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// MigrationStatus returns every migration with the time it was applied to the database
+func MigrationStatus(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, migrationTableSchema); err != nil {
+		return nil, fmt.Errorf("error creating schema_migrations: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("error reading applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("error scanning applied migration: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating applied migrations: %w", err)
+	}
+
+	for i := range migrations {
+		if appliedAt, ok := applied[migrations[i].Version]; ok {
+			migrations[i].AppliedAt = &appliedAt
+		}
+	}
+	return migrations, nil
+}
+
+// Migrate applies the pending migrations in version order and returns them. Each one runs in
+// its own transaction together with its schema_migrations row, so a failing script leaves the
+// database at the previous version.
+func Migrate(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	migrations, err := MigrationStatus(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, migration := range migrations {
+		if migration.AppliedAt != nil {
+			continue
+		}
+		if err := applyMigration(ctx, db, &migration); err != nil {
+			return applied, err
+		}
+		applied = append(applied, migration)
+	}
+	return applied, nil
+}
+
+// applyMigration runs the batches of one migration and records it
+func applyMigration(ctx context.Context, db *sql.DB, migration *Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting migration %04d: %w", migration.Version, err)
+	}
+	defer tx.Rollback()
+
+	if err := execMigration(ctx, tx, migration); err != nil {
+		return err
+	}
+
+	appliedAt := time.Now()
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, name, applied_at) VALUES (@version, @name, @applied_at)",
+		sql.Named("version", migration.Version),
+		sql.Named("name", migration.Name),
+		sql.Named("applied_at", appliedAt),
+	); err != nil {
+		return fmt.Errorf("error recording migration %04d: %w", migration.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing migration %04d: %w", migration.Version, err)
+	}
+	migration.AppliedAt = &appliedAt
+	return nil
+}
+
+// execMigration runs the batches of one migration
+func execMigration(ctx context.Context, tx *sql.Tx, migration *Migration) error {
+	for _, batch := range batchSeparatorPattern.Split(migration.script, -1) {
+		if strings.TrimSpace(batch) == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, batch); err != nil {
+			return fmt.Errorf("error applying migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+	return nil
+}
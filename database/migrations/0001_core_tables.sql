@@ -0,0 +1,85 @@
+-- Users, roles, departments, permissions and access logs. Every statement checks first, so
+-- databases set up by hand before migrations existed adopt this version unchanged.
+
+IF OBJECT_ID('departments', 'U') IS NULL
+CREATE TABLE departments (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    name NVARCHAR(100) NOT NULL,
+    code NVARCHAR(50) NOT NULL,
+    description NVARCHAR(500) NULL,
+    is_active BIT NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL DEFAULT GETDATE(),
+    updated_at DATETIME NOT NULL DEFAULT GETDATE(),
+    deleted_at DATETIME NULL,
+    CONSTRAINT UQ_departments_code UNIQUE (code)
+);
+
+IF OBJECT_ID('users', 'U') IS NULL
+CREATE TABLE users (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    username NVARCHAR(100) NOT NULL,
+    password NVARCHAR(255) NOT NULL,
+    full_name NVARCHAR(200) NULL,
+    email NVARCHAR(200) NULL,
+    phone NVARCHAR(50) NULL,
+    department_id INT NULL REFERENCES departments (id),
+    is_active BIT NOT NULL DEFAULT 1,
+    last_login DATETIME NULL,
+    created_at DATETIME NOT NULL DEFAULT GETDATE(),
+    updated_at DATETIME NOT NULL DEFAULT GETDATE(),
+    deleted_at DATETIME NULL,
+    CONSTRAINT UQ_users_username UNIQUE (username)
+);
+
+IF OBJECT_ID('roles', 'U') IS NULL
+CREATE TABLE roles (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    name NVARCHAR(100) NOT NULL,
+    description NVARCHAR(500) NULL,
+    parent_role_id INT NULL,
+    created_at DATETIME NOT NULL DEFAULT GETDATE(),
+    updated_at DATETIME NOT NULL DEFAULT GETDATE(),
+    deleted_at DATETIME NULL
+);
+
+IF OBJECT_ID('operations', 'U') IS NULL
+CREATE TABLE operations (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    name NVARCHAR(100) NOT NULL,
+    code NVARCHAR(100) NOT NULL,
+    description NVARCHAR(500) NULL,
+    created_at DATETIME NOT NULL DEFAULT GETDATE(),
+    updated_at DATETIME NOT NULL DEFAULT GETDATE(),
+    CONSTRAINT UQ_operations_code UNIQUE (code)
+);
+
+IF OBJECT_ID('user_roles', 'U') IS NULL
+CREATE TABLE user_roles (
+    user_id INT NOT NULL REFERENCES users (id),
+    role_id INT NOT NULL REFERENCES roles (id),
+    created_at DATETIME NOT NULL DEFAULT GETDATE(),
+    PRIMARY KEY (user_id, role_id)
+);
+
+IF OBJECT_ID('role_operations', 'U') IS NULL
+CREATE TABLE role_operations (
+    role_id INT NOT NULL REFERENCES roles (id),
+    operation_id INT NOT NULL REFERENCES operations (id),
+    can_access BIT NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL DEFAULT GETDATE(),
+    PRIMARY KEY (role_id, operation_id)
+);
+
+IF OBJECT_ID('access_logs', 'U') IS NULL
+CREATE TABLE access_logs (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    user_id INT NOT NULL,
+    operation_id INT NOT NULL,
+    access_time DATETIME NOT NULL,
+    search_params NVARCHAR(MAX) NULL,
+    ip_address NVARCHAR(50) NULL,
+    status NVARCHAR(20) NOT NULL,
+    company NVARCHAR(50) NULL,
+    INDEX IX_access_logs_user_id (user_id, access_time),
+    INDEX IX_access_logs_access_time (access_time)
+);
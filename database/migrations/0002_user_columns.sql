@@ -0,0 +1,22 @@
+-- Columns added to users since 0001: soft deletes, password changes, and the encrypted email
+-- and phone with the blind index looked up by email. COL_LENGTH counts bytes, two per NVARCHAR
+-- character.
+
+IF COL_LENGTH('users', 'deleted_at') IS NULL
+    ALTER TABLE users ADD deleted_at DATETIME NULL;
+
+IF COL_LENGTH('users', 'password_changed_at') IS NULL
+    ALTER TABLE users ADD password_changed_at DATETIME NULL;
+
+IF COL_LENGTH('users', 'email') < 1000
+    ALTER TABLE users ALTER COLUMN email NVARCHAR(500) NULL;
+IF COL_LENGTH('users', 'phone') < 400
+    ALTER TABLE users ALTER COLUMN phone NVARCHAR(200) NULL;
+IF COL_LENGTH('users', 'email_hash') IS NULL
+    ALTER TABLE users ADD email_hash CHAR(64) NULL;
+
+GO
+
+-- A separate batch, as the index refers to the column added above
+IF NOT EXISTS (SELECT 1 FROM sys.indexes WHERE name = 'IX_users_email_hash')
+    CREATE INDEX IX_users_email_hash ON users (email_hash);
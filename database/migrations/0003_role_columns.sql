@@ -0,0 +1,7 @@
+-- Columns added to roles since 0001: soft deletes and the parent role operations are inherited from
+
+IF COL_LENGTH('roles', 'deleted_at') IS NULL
+    ALTER TABLE roles ADD deleted_at DATETIME NULL;
+
+IF COL_LENGTH('roles', 'parent_role_id') IS NULL
+    ALTER TABLE roles ADD parent_role_id INT NULL;
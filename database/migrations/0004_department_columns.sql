@@ -0,0 +1,7 @@
+-- Columns added to departments since 0001: soft deletes and the parent department of the tree
+
+IF COL_LENGTH('departments', 'deleted_at') IS NULL
+    ALTER TABLE departments ADD deleted_at DATETIME NULL;
+
+IF COL_LENGTH('departments', 'parent_department_id') IS NULL
+    ALTER TABLE departments ADD parent_department_id INT NULL;
@@ -0,0 +1,4 @@
+-- The ERP company an access was logged for
+
+IF COL_LENGTH('access_logs', 'company') IS NULL
+    ALTER TABLE access_logs ADD company NVARCHAR(50) NULL;
@@ -0,0 +1,87 @@
+-- Local copies of the ERP tables the cached reports read, only the columns their queries use,
+-- and the state of their sync. The customer, department and salesperson of rows synced before
+-- their columns were added stay NULL until the next sync.
+
+IF OBJECT_ID('erp_cache_coptg', 'U') IS NULL
+CREATE TABLE erp_cache_coptg (
+    TG001 NVARCHAR(10) NOT NULL,
+    TG002 NVARCHAR(20) NOT NULL,
+    TG004 NVARCHAR(20) NULL,
+    TG005 NVARCHAR(20) NULL,
+    TG006 NVARCHAR(20) NULL,
+    TG007 NVARCHAR(255) NULL,
+    TG011 NVARCHAR(10) NULL,
+    TG013 DECIMAL(21, 6) NULL,
+    TG020 NVARCHAR(255) NULL,
+    TG023 NVARCHAR(2) NULL,
+    TG025 DECIMAL(21, 6) NULL,
+    TG042 NVARCHAR(8) NULL,
+    TG045 DECIMAL(21, 6) NULL,
+    TG046 DECIMAL(21, 6) NULL,
+    synced_at DATETIME NOT NULL,
+    PRIMARY KEY (TG001, TG002)
+);
+
+IF OBJECT_ID('erp_cache_copth', 'U') IS NULL
+CREATE TABLE erp_cache_copth (
+    TH001 NVARCHAR(10) NOT NULL,
+    TH002 NVARCHAR(20) NOT NULL,
+    TH003 NVARCHAR(10) NOT NULL,
+    TH014 NVARCHAR(10) NULL,
+    TH015 NVARCHAR(20) NULL,
+    TH016 NVARCHAR(10) NULL,
+    synced_at DATETIME NOT NULL,
+    PRIMARY KEY (TH001, TH002, TH003)
+);
+
+IF OBJECT_ID('erp_cache_coptd', 'U') IS NULL
+CREATE TABLE erp_cache_coptd (
+    TD001 NVARCHAR(10) NOT NULL,
+    TD002 NVARCHAR(20) NOT NULL,
+    TD003 NVARCHAR(10) NOT NULL,
+    synced_at DATETIME NOT NULL,
+    PRIMARY KEY (TD001, TD002, TD003)
+);
+
+IF OBJECT_ID('erp_cache_acrta', 'U') IS NULL
+CREATE TABLE erp_cache_acrta (
+    TA001 NVARCHAR(10) NOT NULL,
+    TA002 NVARCHAR(20) NOT NULL,
+    TA009 NVARCHAR(10) NULL,
+    TA029 DECIMAL(21, 6) NULL,
+    TA030 DECIMAL(21, 6) NULL,
+    TA036 NVARCHAR(50) NULL,
+    TA041 DECIMAL(21, 6) NULL,
+    TA042 DECIMAL(21, 6) NULL,
+    synced_at DATETIME NOT NULL,
+    PRIMARY KEY (TA001, TA002)
+);
+
+IF OBJECT_ID('erp_cache_acrtb', 'U') IS NULL
+CREATE TABLE erp_cache_acrtb (
+    TB001 NVARCHAR(10) NOT NULL,
+    TB002 NVARCHAR(20) NOT NULL,
+    TB003 NVARCHAR(10) NOT NULL,
+    TB005 NVARCHAR(10) NULL,
+    TB006 NVARCHAR(20) NULL,
+    TB007 NVARCHAR(10) NULL,
+    TB008 NVARCHAR(8) NULL,
+    synced_at DATETIME NOT NULL,
+    PRIMARY KEY (TB001, TB002, TB003)
+);
+
+IF OBJECT_ID('erp_sync_state', 'U') IS NULL
+CREATE TABLE erp_sync_state (
+    table_name NVARCHAR(50) NOT NULL PRIMARY KEY,
+    synced_from DATETIME NULL,
+    synced_to DATETIME NULL,
+    row_count INT NOT NULL DEFAULT 0,
+    last_run_at DATETIME NULL,
+    status NVARCHAR(20) NOT NULL DEFAULT 'pending',
+    error NVARCHAR(MAX) NULL
+);
+
+GO
+
+IF COL_LENGTH('erp_cache_coptg', 'TG004') IS NULL
+    ALTER TABLE erp_cache_coptg ADD TG004 NVARCHAR(20) NULL, TG005 NVARCHAR(20) NULL, TG006 NVARCHAR(20) NULL;
@@ -0,0 +1,16 @@
+-- Domain events waiting to be published to the message broker
+
+IF OBJECT_ID('event_outbox', 'U') IS NULL
+CREATE TABLE event_outbox (
+    id BIGINT IDENTITY(1,1) PRIMARY KEY,
+    event_type NVARCHAR(100) NOT NULL,
+    payload NVARCHAR(MAX) NOT NULL,
+    status NVARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error NVARCHAR(1000) NULL,
+    created_at DATETIME NOT NULL,
+    published_at DATETIME NULL
+);
+
+IF NOT EXISTS (SELECT 1 FROM sys.indexes WHERE name = 'IX_event_outbox_status')
+CREATE INDEX IX_event_outbox_status ON event_outbox (status, id);
@@ -0,0 +1,17 @@
+-- Every ERP field written back, or tried in a dry run
+
+IF OBJECT_ID('erp_writeback_log', 'U') IS NULL
+CREATE TABLE erp_writeback_log (
+    id BIGINT IDENTITY(1,1) PRIMARY KEY,
+    batch_id NVARCHAR(36) NOT NULL,
+    user_id INT NOT NULL,
+    doc_type NVARCHAR(10) NOT NULL,
+    doc_no NVARCHAR(20) NOT NULL,
+    column_name NVARCHAR(30) NOT NULL,
+    old_value NVARCHAR(255) NULL,
+    new_value NVARCHAR(255) NULL,
+    dry_run BIT NOT NULL,
+    status NVARCHAR(20) NOT NULL,
+    error NVARCHAR(1000) NULL,
+    created_at DATETIME NOT NULL
+);
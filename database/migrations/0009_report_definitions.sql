@@ -0,0 +1,29 @@
+-- Custom SQL reports defined by administrators
+
+IF OBJECT_ID('report_definitions', 'U') IS NULL
+CREATE TABLE report_definitions (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    code NVARCHAR(50) NOT NULL UNIQUE,
+    name NVARCHAR(255) NOT NULL,
+    description NVARCHAR(1000) NULL,
+    source_type NVARCHAR(20) NOT NULL,
+    source NVARCHAR(MAX) NOT NULL,
+    operation_code NVARCHAR(100) NOT NULL,
+    parameters NVARCHAR(MAX) NOT NULL,
+    column_map NVARCHAR(MAX) NOT NULL,
+    timeout_seconds INT NOT NULL DEFAULT 0,
+    max_rows INT NOT NULL DEFAULT 0,
+    title_template NVARCHAR(255) NULL,
+    file_name_template NVARCHAR(255) NULL,
+    is_active BIT NOT NULL DEFAULT 1,
+    created_by INT NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+GO
+
+IF COL_LENGTH('report_definitions', 'title_template') IS NULL
+    ALTER TABLE report_definitions ADD title_template NVARCHAR(255) NULL;
+IF COL_LENGTH('report_definitions', 'file_name_template') IS NULL
+    ALTER TABLE report_definitions ADD file_name_template NVARCHAR(255) NULL;
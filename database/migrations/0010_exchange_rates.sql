@@ -0,0 +1,14 @@
+-- Exchange rates reports convert their totals with
+
+IF OBJECT_ID('exchange_rates', 'U') IS NULL
+CREATE TABLE exchange_rates (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    currency_code NVARCHAR(3) NOT NULL,
+    rate DECIMAL(19, 6) NOT NULL,
+    effective_date DATE NOT NULL,
+    notes NVARCHAR(255) NULL,
+    created_by INT NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    CONSTRAINT UQ_exchange_rates_currency_date UNIQUE (currency_code, effective_date)
+);
@@ -0,0 +1,16 @@
+-- Frozen report results that can be exported again later
+
+IF OBJECT_ID('report_snapshots', 'U') IS NULL
+CREATE TABLE report_snapshots (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    report NVARCHAR(50) NOT NULL,
+    report_name NVARCHAR(255) NOT NULL,
+    parameters NVARCHAR(MAX) NOT NULL,
+    data NVARCHAR(MAX) NOT NULL,
+    row_count INT NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    notes NVARCHAR(500) NULL,
+    created_by INT NOT NULL,
+    created_at DATETIME NOT NULL,
+    INDEX IX_report_snapshots_report (report, created_at)
+);
@@ -0,0 +1,12 @@
+-- Notes imported from edited 230 and 610 exports
+
+IF OBJECT_ID('report_annotations', 'U') IS NULL
+CREATE TABLE report_annotations (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    report NVARCHAR(50) NOT NULL,
+    document_key NVARCHAR(100) NOT NULL,
+    notes NVARCHAR(2000) NOT NULL,
+    updated_by INT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    CONSTRAINT UQ_report_annotations_document UNIQUE (report, document_key)
+);
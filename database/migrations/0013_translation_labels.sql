@@ -0,0 +1,13 @@
+-- Translation labels overriding the built-in ones
+
+IF OBJECT_ID('translation_labels', 'U') IS NULL
+CREATE TABLE translation_labels (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    locale NVARCHAR(10) NOT NULL,
+    label_key NVARCHAR(100) NOT NULL,
+    label NVARCHAR(255) NOT NULL,
+    updated_by INT NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    CONSTRAINT UQ_translation_labels_locale_key UNIQUE (locale, label_key)
+);
@@ -0,0 +1,26 @@
+-- Generated export files and the access log entries they were generated for
+
+IF OBJECT_ID('report_files', 'U') IS NULL
+CREATE TABLE report_files (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    file_name NVARCHAR(255) NOT NULL,
+    report NVARCHAR(100) NOT NULL,
+    user_id INT NOT NULL,
+    access_log_id INT NULL,
+    size BIGINT NOT NULL,
+    row_count INT NULL,
+    checksum CHAR(64) NULL,
+    created_at DATETIME NOT NULL,
+    CONSTRAINT UQ_report_files_file_name UNIQUE (file_name)
+);
+
+GO
+
+IF COL_LENGTH('report_files', 'checksum') IS NULL
+    ALTER TABLE report_files ADD checksum CHAR(64) NULL;
+IF COL_LENGTH('report_files', 'row_count') IS NULL
+    ALTER TABLE report_files ADD row_count INT NULL;
+IF NOT EXISTS (SELECT 1 FROM sys.indexes WHERE name = 'IX_report_files_access_log_id')
+    CREATE INDEX IX_report_files_access_log_id ON report_files (access_log_id);
+IF COL_LENGTH('report_files', 'expires_at') IS NULL
+    ALTER TABLE report_files ADD expires_at DATETIME NULL;
@@ -0,0 +1,21 @@
+-- Queued exports, and the approval decision on the exports that need one
+
+IF OBJECT_ID('export_jobs', 'U') IS NULL
+CREATE TABLE export_jobs (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    report NVARCHAR(50) NOT NULL,
+    parameters NVARCHAR(MAX) NOT NULL,
+    status NVARCHAR(20) NOT NULL DEFAULT 'queued',
+    user_id INT NOT NULL,
+    department_id INT NOT NULL,
+    file_name NVARCHAR(255) NULL,
+    error NVARCHAR(1000) NULL,
+    created_at DATETIME NOT NULL,
+    started_at DATETIME NULL,
+    finished_at DATETIME NULL,
+    INDEX IX_export_jobs_status (status, id),
+    INDEX IX_export_jobs_user_id (user_id, created_at)
+);
+
+IF COL_LENGTH('export_jobs', 'decided_by') IS NULL
+ALTER TABLE export_jobs ADD decided_by INT NULL, decided_at DATETIME NULL, decision_note NVARCHAR(500) NULL;
@@ -0,0 +1,39 @@
+-- Scheduled report deliveries by email or to Google Sheets, and their run history
+
+IF OBJECT_ID('report_schedules', 'U') IS NULL
+CREATE TABLE report_schedules (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    name NVARCHAR(100) NOT NULL,
+    report NVARCHAR(50) NOT NULL,
+    period NVARCHAR(20) NOT NULL,
+    frequency NVARCHAR(20) NOT NULL,
+    cron NVARCHAR(100) NOT NULL,
+    recipients NVARCHAR(4000) NOT NULL,
+    is_active BIT NOT NULL DEFAULT 1,
+    created_by INT NOT NULL,
+    department_id INT NOT NULL,
+    next_run_at DATETIME NULL,
+    last_run_at DATETIME NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    INDEX IX_report_schedules_next_run_at (is_active, next_run_at)
+);
+
+IF COL_LENGTH('report_schedules', 'company') IS NULL
+ALTER TABLE report_schedules ADD company NVARCHAR(50) NULL;
+
+IF COL_LENGTH('report_schedules', 'spreadsheet_id') IS NULL
+ALTER TABLE report_schedules ADD spreadsheet_id NVARCHAR(100) NULL, sheet_name NVARCHAR(100) NULL;
+
+IF OBJECT_ID('report_schedule_runs', 'U') IS NULL
+CREATE TABLE report_schedule_runs (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    schedule_id INT NOT NULL,
+    status NVARCHAR(20) NOT NULL,
+    file_name NVARCHAR(255) NULL,
+    recipients NVARCHAR(4000) NOT NULL,
+    error NVARCHAR(1000) NULL,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME NULL,
+    INDEX IX_report_schedule_runs_schedule_id (schedule_id, started_at)
+);
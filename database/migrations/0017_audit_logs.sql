@@ -0,0 +1,18 @@
+-- Every mutating API request
+
+IF OBJECT_ID('audit_logs', 'U') IS NULL
+CREATE TABLE audit_logs (
+    id BIGINT IDENTITY(1,1) PRIMARY KEY,
+    user_id INT NOT NULL,
+    username NVARCHAR(100) NULL,
+    method NVARCHAR(10) NOT NULL,
+    route NVARCHAR(255) NOT NULL,
+    path NVARCHAR(1000) NOT NULL,
+    payload NVARCHAR(MAX) NULL,
+    ip_address NVARCHAR(50) NOT NULL,
+    status_code INT NOT NULL,
+    latency_ms BIGINT NOT NULL,
+    created_at DATETIME NOT NULL,
+    INDEX IX_audit_logs_created_at (created_at),
+    INDEX IX_audit_logs_user_id (user_id, created_at)
+);
@@ -0,0 +1,13 @@
+-- Saved report parameters of each user
+
+IF OBJECT_ID('report_presets', 'U') IS NULL
+CREATE TABLE report_presets (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    user_id INT NOT NULL,
+    name NVARCHAR(100) NOT NULL,
+    report NVARCHAR(50) NOT NULL,
+    parameters NVARCHAR(MAX) NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    INDEX IX_report_presets_user_id (user_id, name)
+);
@@ -0,0 +1,9 @@
+-- Reports each user marked as favorite
+
+IF OBJECT_ID('report_favorites', 'U') IS NULL
+CREATE TABLE report_favorites (
+    user_id INT NOT NULL,
+    report NVARCHAR(50) NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, report)
+);
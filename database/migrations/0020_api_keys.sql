@@ -0,0 +1,19 @@
+-- Hashed API keys of service accounts and of the users who issued them
+
+IF OBJECT_ID('api_keys', 'U') IS NULL
+CREATE TABLE api_keys (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    name NVARCHAR(100) NOT NULL,
+    key_prefix NVARCHAR(20) NOT NULL,
+    key_hash NVARCHAR(64) NOT NULL,
+    scopes NVARCHAR(MAX) NOT NULL,
+    expires_at DATETIME NULL,
+    last_used_at DATETIME NULL,
+    revoked_at DATETIME NULL,
+    created_by INT NOT NULL,
+    created_at DATETIME NOT NULL,
+    CONSTRAINT UQ_api_keys_prefix UNIQUE (key_prefix)
+);
+
+IF COL_LENGTH('api_keys', 'user_id') IS NULL
+ALTER TABLE api_keys ADD user_id INT NULL;
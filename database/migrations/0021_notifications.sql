@@ -0,0 +1,14 @@
+-- In-app notifications of each user
+
+IF OBJECT_ID('notifications', 'U') IS NULL
+CREATE TABLE notifications (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    user_id INT NOT NULL,
+    type NVARCHAR(50) NOT NULL,
+    title NVARCHAR(200) NOT NULL,
+    message NVARCHAR(1000) NOT NULL,
+    link NVARCHAR(500) NULL,
+    read_at DATETIME NULL,
+    created_at DATETIME NOT NULL,
+    INDEX IX_notifications_user_id (user_id, created_at)
+);
@@ -0,0 +1,48 @@
+-- Uploaded ERP corrections with their rows, and the rules validating them
+
+IF OBJECT_ID('import_batches', 'U') IS NULL
+CREATE TABLE import_batches (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    import_type NVARCHAR(50) NOT NULL,
+    file_name NVARCHAR(255) NOT NULL,
+    status NVARCHAR(20) NOT NULL,
+    total_rows INT NOT NULL,
+    changed_rows INT NOT NULL,
+    unchanged_rows INT NOT NULL,
+    invalid_rows INT NOT NULL,
+    user_id INT NOT NULL,
+    created_at DATETIME NOT NULL,
+    decided_by INT NULL,
+    decided_at DATETIME NULL
+);
+
+IF OBJECT_ID('import_rows', 'U') IS NULL
+CREATE TABLE import_rows (
+    id BIGINT IDENTITY(1,1) PRIMARY KEY,
+    batch_id INT NOT NULL,
+    sheet_row INT NOT NULL,
+    row_key NVARCHAR(100) NOT NULL,
+    old_value NVARCHAR(255) NULL,
+    new_value NVARCHAR(255) NULL,
+    status NVARCHAR(20) NOT NULL,
+    errors NVARCHAR(MAX) NULL,
+    CONSTRAINT FK_import_rows_batch FOREIGN KEY (batch_id) REFERENCES import_batches(id)
+);
+
+IF NOT EXISTS (SELECT 1 FROM sys.indexes WHERE name = 'IX_import_rows_batch_id')
+    CREATE INDEX IX_import_rows_batch_id ON import_rows (batch_id);
+
+IF OBJECT_ID('import_rules', 'U') IS NULL
+CREATE TABLE import_rules (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    import_type NVARCHAR(50) NOT NULL,
+    column_key NVARCHAR(100) NOT NULL,
+    rule_type NVARCHAR(20) NOT NULL,
+    pattern NVARCHAR(500) NULL,
+    min_value DECIMAL(28, 8) NULL,
+    max_value DECIMAL(28, 8) NULL,
+    lookup NVARCHAR(50) NULL,
+    message NVARCHAR(255) NULL,
+    created_by INT NOT NULL,
+    created_at DATETIME NOT NULL
+);
@@ -0,0 +1,11 @@
+-- Date range, row and file size limits of each report set by administrators
+
+IF OBJECT_ID('report_limits', 'U') IS NULL
+CREATE TABLE report_limits (
+    report NVARCHAR(100) NOT NULL PRIMARY KEY,
+    max_months INT NOT NULL,
+    max_rows INT NOT NULL,
+    max_export_mb INT NOT NULL,
+    updated_by INT NOT NULL,
+    updated_at DATETIME NOT NULL
+);
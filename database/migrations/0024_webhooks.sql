@@ -0,0 +1,31 @@
+-- Outbound webhooks and their deliveries
+
+IF OBJECT_ID('webhooks', 'U') IS NULL
+CREATE TABLE webhooks (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    name NVARCHAR(100) NOT NULL,
+    url NVARCHAR(1000) NOT NULL,
+    secret NVARCHAR(200) NOT NULL,
+    events NVARCHAR(MAX) NOT NULL,
+    is_active BIT NOT NULL DEFAULT 1,
+    created_by INT NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+IF OBJECT_ID('webhook_deliveries', 'U') IS NULL
+CREATE TABLE webhook_deliveries (
+    id BIGINT IDENTITY(1,1) PRIMARY KEY,
+    webhook_id INT NOT NULL,
+    event_type NVARCHAR(100) NOT NULL,
+    payload NVARCHAR(MAX) NOT NULL,
+    status NVARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    response_status INT NULL,
+    last_error NVARCHAR(1000) NULL,
+    next_attempt_at DATETIME NULL,
+    created_at DATETIME NOT NULL,
+    delivered_at DATETIME NULL,
+    INDEX IX_webhook_deliveries_due (status, next_attempt_at),
+    INDEX IX_webhook_deliveries_webhook (webhook_id, id)
+);
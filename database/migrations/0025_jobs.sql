@@ -0,0 +1,20 @@
+-- Background jobs of the job engine
+
+IF OBJECT_ID('jobs', 'U') IS NULL
+CREATE TABLE jobs (
+    id BIGINT IDENTITY(1,1) PRIMARY KEY,
+    type NVARCHAR(100) NOT NULL,
+    payload NVARCHAR(MAX) NOT NULL,
+    status NVARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL,
+    last_error NVARCHAR(1000) NULL,
+    run_at DATETIME NOT NULL,
+    locked_until DATETIME NULL,
+    created_by INT NULL,
+    created_at DATETIME NOT NULL,
+    started_at DATETIME NULL,
+    finished_at DATETIME NULL,
+    INDEX IX_jobs_due (status, run_at),
+    INDEX IX_jobs_type (type, id)
+);
@@ -0,0 +1,18 @@
+-- Row policies restricting the report rows users see
+
+IF OBJECT_ID('row_policies', 'U') IS NULL
+CREATE TABLE row_policies (
+    id INT IDENTITY(1,1) PRIMARY KEY,
+    name NVARCHAR(100) NOT NULL,
+    description NVARCHAR(500) NULL,
+    operation_code NVARCHAR(100) NOT NULL,
+    role_id INT NULL,
+    attribute NVARCHAR(50) NOT NULL,
+    operator NVARCHAR(10) NOT NULL,
+    policy_values NVARCHAR(MAX) NOT NULL,
+    is_active BIT NOT NULL DEFAULT 1,
+    created_by INT NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    INDEX IX_row_policies_operation (operation_code, is_active)
+);
@@ -0,0 +1,10 @@
+-- Hashed calendar feed tokens, one per user; issuing a new token replaces the old one
+
+IF OBJECT_ID('calendar_tokens', 'U') IS NULL
+CREATE TABLE calendar_tokens (
+    user_id INT NOT NULL PRIMARY KEY,
+    token_hash NVARCHAR(64) NOT NULL,
+    created_at DATETIME NOT NULL,
+    last_used_at DATETIME NULL,
+    CONSTRAINT UQ_calendar_tokens_hash UNIQUE (token_hash)
+);
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/config"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults of the read replica probe
+const (
+	defaultReplicaCheck   = 10 * time.Second
+	defaultReplicaTimeout = 2 * time.Second
+)
+
+type primaryReadsKey struct{}
+
+// WithPrimaryReads returns a copy of ctx whose reads of the app database go to the primary, so a
+// request that writes reads its own writes rather than a replica lagging behind
+func WithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadsKey{}, true)
+}
+
+// primaryReads reports whether the reads of ctx must go to the primary
+func primaryReads(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryReadsKey{}).(bool)
+	return primary
+}
+
+// replica is a read replica of the app database, pinged in the background so reads leave it as
+// soon as it stops answering and come back once it does again
+type replica struct {
+	db       *sql.DB
+	address  string // host:port, for the logs
+	interval time.Duration
+	timeout  time.Duration
+	healthy  atomic.Bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// openReplica opens the pool of the read replica and starts probing it. A replica down at
+// startup does not stop the instance: reads use the primary until it answers.
+func openReplica(cfg *config.Config) (*replica, error) {
+	db, err := sql.Open("sqlserver", cfg.GetReplicaDSN())
+	if err != nil {
+		return nil, err
+	}
+	setPool(db, cfg.Database)
+
+	host, port := cfg.DatabaseReplica.Host, cfg.DatabaseReplica.Port
+	if host == "" {
+		host = cfg.Database.Host
+	}
+	if port == 0 {
+		port = cfg.Database.Port
+	}
+
+	r := &replica{
+		db:       db,
+		address:  net.JoinHostPort(host, strconv.Itoa(port)),
+		interval: time.Duration(cfg.DatabaseReplica.CheckSeconds) * time.Second,
+		timeout:  time.Duration(cfg.DatabaseReplica.TimeoutMs) * time.Millisecond,
+		stop:     make(chan struct{}),
+	}
+	if r.interval <= 0 {
+		r.interval = defaultReplicaCheck
+	}
+	if r.timeout <= 0 {
+		r.timeout = defaultReplicaTimeout
+	}
+
+	if err := r.check(); err != nil {
+		slog.Warn("Read replica of the app database does not answer, reads go to the primary until it does",
+			"replica", r.address, "error", err)
+	}
+	go r.run()
+
+	return r, nil
+}
+
+// run pings the replica until it is closed
+func (r *replica) run() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.check()
+		}
+	}
+}
+
+// check pings the replica and logs when it goes down or comes back. It returns the error of the
+// ping.
+func (r *replica) check() error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	err := r.db.PingContext(ctx)
+	healthy := err == nil
+	if r.healthy.Swap(healthy) == healthy {
+		return err
+	}
+	if healthy {
+		slog.Info("Read replica of the app database is up, reads go to the replica", "replica", r.address)
+	} else {
+		slog.Warn("Read replica of the app database is down, reads go to the primary", "replica", r.address, "error", err)
+	}
+	return err
+}
+
+// close stops the probe and closes the pool
+func (r *replica) close() error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	return r.db.Close()
+}
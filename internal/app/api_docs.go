@@ -0,0 +1,183 @@
+package app
+
+import (
+	"erp-excel/config"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/models"
+	"erp-excel/internal/openapi"
+	"erp-excel/internal/utils"
+)
+
+// Query parameters shared by the paged lists
+var pageQuery = map[string]string{
+	"page":  "page number, from 1",
+	"limit": "rows per page",
+}
+
+// apiOperations documents the bodies and responses of the routes for /api/docs. Routes missing
+// here are still documented from the route table, with their path parameters and the standard
+// response; add the DTOs of a new route here when it reads a JSON body.
+var apiOperations = map[string]openapi.Operation{
+	// Authentication
+	"POST /api/auth/login":    {Summary: "Log in", Request: dto.LoginRequest{}, Response: dto.LoginResponse{}},
+	"POST /api/auth/logout":   {Summary: "Log out, revoking the token of the request"},
+	"GET /api/auth/profile":   {Summary: "Current user", Response: dto.UserResponse{}},
+	"PUT /api/auth/profile":   {Summary: "Update the current user", Request: dto.UpdateProfileRequest{}, Response: dto.UserResponse{}},
+	"GET /api/auth/menu":      {Summary: "Menu of the current user", Response: []dto.MenuItem{}},
+	"POST /api/auth/company":  {Summary: "Switch ERP company", Request: dto.SwitchCompanyRequest{}, Response: dto.LoginResponse{}},
+	"GET /api/auth/companies": {Summary: "ERP companies of the current user", Response: []dto.CompanyResponse{}},
+
+	// Users, departments, roles
+	"GET /api/users":                 {Summary: "List users", Query: map[string]string{"page": pageQuery["page"], "limit": pageQuery["limit"], "include_deleted": "true to list deleted users too"}},
+	"GET /api/users/:id":             {Summary: "Get a user", Response: dto.UserResponse{}},
+	"POST /api/users":                {Summary: "Create a user", Request: dto.CreateUserRequest{}, Response: dto.UserResponse{}, Status: 201},
+	"PUT /api/users/:id":             {Summary: "Update a user", Request: dto.UpdateUserRequest{}, Response: dto.UserResponse{}},
+	"POST /api/users/password":       {Summary: "Change the current user's password", Request: dto.UpdatePasswordRequest{}},
+	"GET /api/departments/:id":       {Summary: "Get a department", Response: dto.DepartmentResponse{}},
+	"GET /api/departments/tree":      {Summary: "Department tree", Response: []dto.DepartmentTreeNode{}},
+	"POST /api/departments":          {Summary: "Create a department", Request: dto.CreateDepartmentRequest{}, Response: dto.DepartmentResponse{}, Status: 201},
+	"PUT /api/departments/:id":       {Summary: "Update a department", Request: dto.UpdateDepartmentRequest{}, Response: dto.DepartmentResponse{}},
+	"GET /api/roles/:id":             {Summary: "Get a role", Response: dto.RoleResponse{}},
+	"POST /api/roles":                {Summary: "Create a role", Request: dto.CreateRoleRequest{}, Response: dto.RoleResponse{}, Status: 201},
+	"PUT /api/roles/:id":             {Summary: "Update a role", Request: dto.UpdateRoleRequest{}, Response: dto.RoleResponse{}},
+	"POST /api/roles/:id/clone":      {Summary: "Clone a role", Request: dto.CloneRoleRequest{}, Response: dto.RoleResponse{}, Status: 201},
+	"POST /api/roles/operations":     {Summary: "Grant an operation to a role", Request: dto.GrantOperationRequest{}},
+	"GET /api/admin/search":          {Summary: "Search users, departments and roles", Query: map[string]string{"q": "search text"}, Response: dto.SearchResponse{}},
+	"GET /api/admin/trash":           {Summary: "Deleted users, departments and roles", Response: dto.TrashResponse{}},
+	"GET /api/admin/dashboard":       {Summary: "Administration dashboard", Response: dto.DashboardStatistics{}},
+	"GET /api/admin/config/backup":   {Summary: "Back up departments, roles and operations", Response: dto.ConfigBundle{}},
+	"POST /api/admin/config/restore": {Summary: "Restore a configuration backup", Request: dto.ConfigBundle{}, Response: dto.ConfigRestoreResponse{}},
+	"GET /api/admin/schema/check":    {Summary: "Check the ERP tables and columns the reports read", Response: dto.SchemaCheckResponse{}},
+
+	// Assistant 230
+	"POST /api/reports/inventory":              {Summary: "Assistant 230 report data", Request: dto.DateRangeRequest{}, Response: dto.ReportDataResponse{}},
+	"POST /api/reports/inventory/export":       {Summary: "Export Assistant 230 to Excel", Request: dto.DateRangeRequest{}, File: utils.ExcelContentType},
+	"POST /api/reports/inventory/sheets":       {Summary: "Export Assistant 230 to Google Sheets", Request: dto.SheetExportRequest{}, Response: dto.SheetExportResponse{}},
+	"POST /api/reports/inventory/preview":      {Summary: "Preview Assistant 230", Request: dto.DateRangeRequest{}, Response: dto.ReportPreviewResponse{}},
+	"POST /api/reports/inventory/compare":      {Summary: "Compare Assistant 230 between two periods", Request: dto.ReportComparisonRequest{}, Response: dto.ReportComparisonResponse{}},
+	"POST /api/reports/inventory/export/async": {Summary: "Queue an Assistant 230 export", Request: dto.DateRangeRequest{}, Response: dto.ExportJobResponse{}, Status: 202},
+	"POST /api/reports/inventory/notes/import": {Summary: "Import notes from an edited Assistant 230 export", Upload: "file", Response: dto.NoteImportResponse{}},
+	"GET /api/reports/download/:fileName":      {Summary: "Download a generated file", File: utils.ExcelContentType},
+
+	// Assistant 610 and 340
+	"POST /api/assistants/610":                    {Summary: "Assistant 610 report data", Request: dto.DateRangeRequest{}, Response: dto.Assistant610DataResponse{}},
+	"POST /api/assistants/610/export":             {Summary: "Export Assistant 610 to Excel", Request: dto.DateRangeRequest{}, File: utils.ExcelContentType},
+	"POST /api/assistants/610/sheets":             {Summary: "Export Assistant 610 to Google Sheets", Request: dto.SheetExportRequest{}, Response: dto.SheetExportResponse{}},
+	"POST /api/assistants/610/aging":              {Summary: "Assistant 610 aging summary", Request: dto.DateRangeRequest{}, Response: dto.Assistant610AgingSummary{}},
+	"POST /api/assistants/610/compare":            {Summary: "Compare Assistant 610 between two periods", Request: dto.ReportComparisonRequest{}, Response: dto.ReportComparisonResponse{}},
+	"POST /api/assistants/610/export/async":       {Summary: "Queue an Assistant 610 export", Request: dto.DateRangeRequest{}, Response: dto.ExportJobResponse{}, Status: 202},
+	"POST /api/reports/assistant610/preview":      {Summary: "Preview Assistant 610", Request: dto.DateRangeRequest{}, Response: dto.ReportPreviewResponse{}},
+	"POST /api/reports/assistant610/notes/import": {Summary: "Import notes from an edited Assistant 610 export", Upload: "file", Response: dto.NoteImportResponse{}},
+	"GET /api/assistants/download/:fileName":      {Summary: "Download a generated file", File: utils.ExcelContentType},
+	"POST /api/assistants/340":                    {Summary: "Assistant 340 report data", Request: dto.Assistant340Request{}, Response: dto.Assistant340DataResponse{}},
+	"POST /api/assistants/340/export":             {Summary: "Export Assistant 340 to Excel", Request: dto.Assistant340Request{}, File: utils.ExcelContentType},
+
+	// Other reports
+	"POST /api/reports/items/inventory":        {Summary: "Item inventory data", Request: dto.ItemInventoryRequest{}, Response: dto.ItemInventoryDataResponse{}},
+	"POST /api/reports/items/inventory/export": {Summary: "Export item inventory to Excel", Request: dto.ItemInventoryRequest{}, File: utils.ExcelContentType},
+	"POST /api/reports/stock-balance":          {Summary: "Stock balance data", Request: dto.StockBalanceRequest{}, Response: dto.StockBalanceDataResponse{}},
+	"POST /api/reports/stock-balance/export":   {Summary: "Export the stock balance to Excel", Request: dto.StockBalanceRequest{}, File: utils.ExcelContentType},
+	"POST /api/reports/reconciliation":         {Summary: "Reconciliation data", Request: dto.ReconciliationRequest{}, Response: dto.ReconciliationResponse{}},
+	"POST /api/reports/reconciliation/export":  {Summary: "Export the reconciliation to Excel", Request: dto.ReconciliationRequest{}, File: utils.ExcelContentType},
+	"GET /api/reports/definitions":             {Summary: "Reports of the report engine", Response: []dto.ReportDefinitionResponse{}},
+	"POST /api/reports/:code":                  {Summary: "Run an engine report", Request: dto.ReportRunRequest{}, Response: dto.ReportRunResponse{}},
+	"POST /api/reports/:code/export":           {Summary: "Export an engine report to Excel", Request: dto.ReportRunRequest{}, File: utils.ExcelContentType},
+	"POST /api/reports/:code/preview":          {Summary: "Preview an engine report", Request: dto.ReportRunRequest{}, Response: dto.ReportPreviewResponse{}},
+	"POST /api/admin/reports":                  {Summary: "Register a custom report", Request: dto.ReportDefinitionRequest{}, Status: 201},
+	"PUT /api/admin/reports/:code":             {Summary: "Update a custom report", Request: dto.ReportDefinitionRequest{}},
+
+	// Exports, files and schedules
+	"POST /api/reports/bundle":               {Summary: "Export several reports as one ZIP", Request: dto.ReportBundleRequest{}, File: utils.ZipContentType},
+	"GET /api/reports/exports":               {Summary: "Export jobs of the current user", Response: []dto.ExportJobResponse{}},
+	"GET /api/reports/exports/:id":           {Summary: "Get an export job", Response: dto.ExportJobResponse{}},
+	"GET /api/reports/exports/:id/file":      {Summary: "Download the file of an export job", File: utils.ExcelContentType},
+	"GET /api/reports/files":                 {Summary: "Generated files of the current user", Query: map[string]string{"page": pageQuery["page"], "limit": pageQuery["limit"], "report": "report code"}, Response: []dto.DownloadFileResponse{}},
+	"GET /api/downloads/:fileName":           {Summary: "Download a file through a signed link", Query: map[string]string{"expires": "expiry of the link", "signature": "signature of the link"}, File: utils.ExcelContentType},
+	"GET /api/admin/downloads":               {Summary: "Generated files in storage", Query: pageQuery, Response: []dto.DownloadFileResponse{}},
+	"POST /api/admin/downloads/cleanup":      {Summary: "Delete expired generated files", Request: dto.DownloadCleanupRequest{}, Response: dto.DownloadCleanupResponse{}},
+	"POST /api/export-approvals/:id/approve": {Summary: "Approve an export", Request: dto.ExportDecisionRequest{}, Response: dto.ExportJobResponse{}},
+	"POST /api/export-approvals/:id/reject":  {Summary: "Reject an export", Request: dto.ExportDecisionRequest{}, Response: dto.ExportJobResponse{}},
+	"POST /api/reports/schedules":            {Summary: "Schedule a report", Request: dto.ReportScheduleRequest{}, Response: dto.ReportScheduleResponse{}, Status: 201},
+	"GET /api/reports/schedules/:id":         {Summary: "Get a report schedule", Response: dto.ReportScheduleResponse{}},
+	"PUT /api/reports/schedules/:id":         {Summary: "Update a report schedule", Request: dto.ReportScheduleRequest{}, Response: dto.ReportScheduleResponse{}},
+	"POST /api/reports/schedules/:id/run":    {Summary: "Run a report schedule now", Response: models.ReportScheduleRun{}},
+	"POST /api/reports/presets":              {Summary: "Save a report preset", Request: dto.ReportPresetRequest{}, Response: dto.ReportPresetResponse{}, Status: 201},
+	"GET /api/reports/presets/:id":           {Summary: "Get a report preset", Response: dto.ReportPresetResponse{}},
+	"PUT /api/reports/presets/:id":           {Summary: "Update a report preset", Request: dto.ReportPresetRequest{}, Response: dto.ReportPresetResponse{}},
+	"GET /api/reports/history":               {Summary: "Reports the current user ran", Response: []dto.ReportHistoryEntry{}},
+	"GET /api/reports/favorites":             {Summary: "Favorite reports of the current user", Response: []dto.ReportFavoriteResponse{}},
+	"POST /api/snapshots":                    {Summary: "Snapshot a report", Request: dto.ReportSnapshotRequest{}, Response: dto.ReportSnapshotSummary{}, Status: 201},
+	"GET /api/snapshots/:id":                 {Summary: "Get a report snapshot", Response: dto.ReportSnapshotResponse{}},
+	"GET /api/snapshots/:id/export":          {Summary: "Export a report snapshot to Excel", File: utils.ExcelContentType},
+
+	// Webhooks
+	"GET /api/admin/webhooks":                           {Summary: "Webhooks", Response: []models.Webhook{}},
+	"GET /api/admin/webhooks/:id":                       {Summary: "Get a webhook", Response: models.Webhook{}},
+	"POST /api/admin/webhooks":                          {Summary: "Register a webhook; the secret is only returned here", Request: dto.WebhookRequest{}, Response: dto.WebhookCreatedResponse{}, Status: 201},
+	"PUT /api/admin/webhooks/:id":                       {Summary: "Update a webhook, keeping its secret unless a new one is given", Request: dto.WebhookRequest{}, Response: models.Webhook{}},
+	"GET /api/admin/webhooks/deliveries":                {Summary: "Webhook delivery log, newest first", Query: map[string]string{"webhook_id": "webhook ID", "status": "pending, delivered or failed", "event": "event type, e.g. export.completed", "limit": "default 100, at most 1000"}, Response: []models.WebhookDelivery{}},
+	"GET /api/admin/webhooks/:id/deliveries":            {Summary: "Delivery log of a webhook, newest first", Query: map[string]string{"status": "pending, delivered or failed", "event": "event type, e.g. export.completed", "limit": "default 100, at most 1000"}, Response: []models.WebhookDelivery{}},
+	"POST /api/admin/webhooks/deliveries/:id/redeliver": {Summary: "Queue a webhook delivery again", Status: 202},
+
+	// Background jobs
+	"GET /api/admin/jobs":             {Summary: "Background jobs, newest first", Query: map[string]string{"status": "pending, running, completed, failed or cancelled", "type": "job type", "limit": "default 100, at most 1000"}, Response: []models.Job{}},
+	"GET /api/admin/jobs/:id":         {Summary: "Get a background job", Response: models.Job{}},
+	"POST /api/admin/jobs/:id/retry":  {Summary: "Queue a failed or cancelled job again", Status: 202},
+	"POST /api/admin/jobs/:id/cancel": {Summary: "Cancel a pending or running job"},
+
+	// Row policies
+	"GET /api/admin/row-policies":            {Summary: "Row policies of the ERP reports", Query: map[string]string{"operation": "operation code, e.g. reports:view:230"}, Response: []models.RowPolicy{}},
+	"GET /api/admin/row-policies/attributes": {Summary: "Attributes row policies may restrict, by operation", Response: map[string][]string{}},
+	"GET /api/admin/row-policies/:id":        {Summary: "Get a row policy", Response: models.RowPolicy{}},
+	"POST /api/admin/row-policies":           {Summary: "Define a row policy", Request: dto.RowPolicyRequest{}, Response: models.RowPolicy{}, Status: 201},
+	"PUT /api/admin/row-policies/:id":        {Summary: "Update a row policy", Request: dto.RowPolicyRequest{}, Response: models.RowPolicy{}},
+	"DELETE /api/admin/row-policies/:id":     {Summary: "Delete a row policy"},
+
+	// ERP corrections and settings
+	"POST /api/erp/writeback/invoices":         {Summary: "Mark documents as processed in the ERP", Request: dto.ERPWriteBackRequest{}, Response: dto.ERPWriteBackResponse{}},
+	"GET /api/imports":                         {Summary: "Import batches", Response: []models.ImportBatch{}},
+	"POST /api/imports/:type":                  {Summary: "Upload ERP corrections from Excel", Upload: "file", Response: dto.DataImportResponse{}, Status: 201},
+	"GET /api/imports/:id":                     {Summary: "Get an import batch with its rows", Response: dto.DataImportResponse{}},
+	"POST /api/imports/:id/commit":             {Summary: "Commit an import batch", Response: dto.DataImportResponse{}},
+	"GET /api/imports/rules":                   {Summary: "Validation rules of the imports", Query: map[string]string{"type": "import type, e.g. invoice_number"}, Response: []models.ImportRule{}},
+	"POST /api/imports/rules":                  {Summary: "Add an import validation rule", Request: dto.ImportRuleRequest{}, Response: models.ImportRule{}, Status: 201},
+	"POST /api/exchange-rates":                 {Summary: "Add an exchange rate", Request: dto.ExchangeRateRequest{}, Status: 201},
+	"PUT /api/exchange-rates/:id":              {Summary: "Update an exchange rate", Request: dto.ExchangeRateRequest{}},
+	"PUT /api/admin/translations/:locale/:key": {Summary: "Set a translation label", Request: dto.TranslationLabelRequest{}, Response: dto.TranslationLabelResponse{}},
+	"GET /api/admin/translations":              {Summary: "Translation labels", Query: map[string]string{"locale": "vi, en or zh, default vi"}, Response: []dto.TranslationLabelResponse{}},
+	"GET /api/admin/report-limits":             {Summary: "Effective row, size and date range limits of the reports", Response: []dto.ReportLimitResponse{}},
+	"PUT /api/admin/report-limits/:report":     {Summary: "Set the limits of a report", Request: dto.ReportLimitRequest{}, Response: dto.ReportLimitResponse{}},
+	"GET /api/admin/erp-queue":                 {Summary: "Report queries running and waiting for a turn on each ERP database of this instance", Response: []dto.ERPQueueStatus{}},
+	"GET /api/admin/slow-queries":              {Summary: "Latest slow ERP queries of this instance, newest first", Query: map[string]string{"limit": "queries to list, default 50"}, Response: []dto.SlowQuery{}},
+	"POST /api/api-keys":                       {Summary: "Create an API key for the current user", Request: dto.APIKeyRequest{}, Response: dto.APIKeyCreatedResponse{}, Status: 201},
+	"POST /api/admin/api-keys":                 {Summary: "Create an API key", Request: dto.APIKeyRequest{}, Response: dto.APIKeyCreatedResponse{}, Status: 201},
+	"POST /api/batch":                          {Summary: "Run several API requests in one round trip", Request: dto.BatchRequest{}, Response: dto.BatchResponse{}},
+	"POST /api/graphql":                        {Summary: "Run a GraphQL query; answers a GraphQL response with data and errors rather than the standard one", Request: dto.GraphQLRequest{}},
+	"GET /api/graphql/schema":                  {Summary: "GraphQL schema in SDL", File: "text/plain"},
+	"GET /health":                              {Summary: "Health check", Response: dto.HealthResponse{}},
+	"GET /metrics":                             {Summary: "Metrics in the Prometheus text format", File: "text/plain"},
+}
+
+// apiDocs describes the API for the generated OpenAPI document
+func apiDocs(cfg *config.Config) openapi.Options {
+	title := cfg.Server.Name
+	if title == "" {
+		title = "ERP Excel API"
+	}
+
+	return openapi.Options{
+		Title:         title,
+		Version:       "1.0",
+		Description:   "Reports and exports of the ERP data. Authenticate with a bearer token from /api/auth/login or an X-API-Key header.",
+		SecuredPrefix: "/api",
+		Public: []string{
+			"/api/auth/login",
+			"/api/auth/saml/metadata",
+			"/api/auth/saml/login",
+			"/api/auth/saml/acs",
+			"/api/docs",
+			"/api/docs/openapi.json",
+		},
+		Operations: apiOperations,
+	}
+}
@@ -1,115 +1,117 @@
 package app
 
 import (
+	"context"
 	"erp-excel/config"
 	"erp-excel/database"
+	"erp-excel/internal/daterange"
 	"erp-excel/internal/handlers"
+	"erp-excel/internal/logging"
 	"erp-excel/internal/middleware"
-	"erp-excel/internal/repository"
-	"erp-excel/internal/service"
+	"erp-excel/internal/rpc"
+	"erp-excel/internal/rpcapi"
+	"erp-excel/internal/translate"
+	"erp-excel/internal/utils"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
 	fiber "github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 )
 
+const (
+	// defaultShutdownTimeout is how long a shutdown drains requests and jobs unless configured
+	defaultShutdownTimeout = 30 * time.Second
+	// shutdownCancelWait is how long requests cancelled at the shutdown timeout get to return
+	// before the databases are closed
+	shutdownCancelWait = 5 * time.Second
+)
+
 // App represents the application
 type App struct {
-	config *config.Config
-	fiber  *fiber.App
-	db     database.Database
-
-	// Handlers
-	handlers []handlers.BaseHandler
-
-	// Services
-	authService service.AuthService
-
-	// Repositories
-	userRepo         repository.UserRepository
-	departmentRepo   repository.DepartmentRepository
-	roleRepo         repository.RoleRepository
-	operationRepo    repository.OperationRepository
-	reportRepo       repository.InventoryRepository
-	assistant610Repo repository.Assistant610Repository
+	*container
+
+	// Running requests, drained at shutdown
+	requests *middleware.RequestTracker
+
+	// gRPC server for internal services, nil unless enabled
+	grpcServer *rpc.Server
 }
 
 // New creates a new application instance
 func New(cfg *config.Config, db database.Database) *App {
+	logger, err := logging.New(cfg.Logger)
+	if err != nil {
+		log.Fatalf("Error setting up logging: %v", err)
+	}
+	// Route the standard log package and package-level slog calls through the same handler
+	slog.SetDefault(logger)
+
 	app := &App{
-		config: cfg,
-		db:     db,
+		requests: middleware.NewRequestTracker(),
 	}
 
 	// Initialize Fiber
-	app.fiber = fiber.New(fiber.Config{
+	app.container = newContainer(cfg, db, logger, fiber.New(fiber.Config{
 		AppName:      cfg.Server.Name,
-		ErrorHandler: errorHandler,
-	})
+		ErrorHandler: handlers.ErrorHandler,
+	}))
 
 	// Setup middleware
 	app.fiber.Use(recover.New())
-	app.fiber.Use(logger.New())
+	app.fiber.Use(middleware.CancelOnDisconnectMiddleware(app.requests))
+	app.fiber.Use(middleware.RequestIDMiddleware())
+	app.fiber.Use(middleware.ReadRoutingMiddleware())
+	app.fiber.Use(middleware.RequestLogMiddleware(logger))
+	app.fiber.Use(middleware.BodyLogMiddleware(logger, cfg.Logger.Bodies, cfg.Server.Env))
+	app.fiber.Use(middleware.LocaleMiddleware())
 	app.fiber.Use(cors.New(cors.Config{
 		AllowOrigins:     "*",
 		AllowMethods:     "*",
 		AllowHeaders:     "*",
 		AllowCredentials: false,
+		// Let browser clients read the file name, length and checksum of downloads and the request ID
+		ExposeHeaders: "Content-Disposition, Content-Length, X-Checksum, X-Download-URL, X-Request-ID",
 	}))
 
-	// Setup repositories
-	app.userRepo = repository.NewUserRepository(app.db.DB())
-	app.departmentRepo = repository.NewDepartmentRepository(app.db.DB())
-	app.roleRepo = repository.NewRoleRepository(app.db.DB())
-	app.operationRepo = repository.NewOperationRepository(app.db.DB())
-	app.reportRepo = repository.NewInventoryRepository(app.db.ERPDatabase())
-	app.assistant610Repo = repository.NewAssistant610Repository(app.db.ERPDatabase())
-
-	// Setup services
-	app.authService = service.NewAuthService(app.userRepo, app.config)
-	userService := service.NewUserService(app.userRepo, app.departmentRepo, app.roleRepo, app.authService)
-	departmentService := service.NewDepartmentService(app.departmentRepo)
-	roleService := service.NewRoleService(app.roleRepo)
-	operationService := service.NewOperationService(app.operationRepo, app.userRepo, app.roleRepo)
-	reportService := service.NewReportService(
-		app.db.ERPDatabase(),
-		app.config,
-		app.userRepo,
-		app.operationRepo,
-		app.reportRepo,
-	)
-	assistant610Service := service.NewAssistant610Service(
-		app.db.ERPDatabase(),
-		app.config,
-		app.userRepo,
-		app.operationRepo,
-		app.assistant610Repo,
-	)
-	// Setup handlers
-	authHandler := handlers.NewAuthHandler(app.authService)
-	userHandler := handlers.NewUserHandler(userService)
-	departmentHandler := handlers.NewDepartmentHandler(departmentService)
-	roleHandler := handlers.NewRoleHandler(roleService)
-	reportHandler := handlers.NewReportHandler(reportService, app.reportRepo)
-	operationHandler := handlers.NewOperationHandler(operationService)
-	adminHandler := handlers.NewAdminHandler(userService, departmentService, roleService, operationService)
-	assistant610Hander := handlers.NewAssistant610Handler(assistant610Service, app.assistant610Repo)
-	// Store handlers
-	app.handlers = []handlers.BaseHandler{
-		authHandler,
-		userHandler,
-		departmentHandler,
-		roleHandler,
-		reportHandler,
-		adminHandler,
-		operationHandler,
-		assistant610Hander,
+	// Setup the branded Excel template
+	template := cfg.Excel.Template
+	if err := utils.LoadExcelTemplate(template.Path, template.Sheet, template.Region); err != nil {
+		log.Fatalf("Error loading Excel template: %v", err)
+	}
+
+	// Setup the key of signed download links
+	linkSecret := cfg.Downloads.LinkSecret
+	if linkSecret == "" {
+		linkSecret = cfg.JWT.Secret
+	}
+	utils.SetDownloadLinkKey(linkSecret, time.Duration(cfg.Downloads.LinkTTLMinutes)*time.Minute)
+
+	// Setup the fiscal year the quarter and fiscal year periods count from
+	daterange.SetFiscalYearStart(cfg.Reports.FiscalYearStartMonth)
+
+	// Setup the language files of report headers, titles and API messages
+	if err := translate.LoadLocales(cfg.Translations.Dir); err != nil {
+		log.Fatalf("Error loading language files: %v", err)
+	}
+
+	// Setup repositories, services and handlers
+	app.buildRepositories()
+	app.buildServices()
+	app.buildHandlers()
+
+	if cfg.GRPC.Enabled {
+		app.grpcServer, err = rpcapi.NewServer(cfg.GRPC, app.reportEngineService, app.userService, app.operationService, logger)
+		if err != nil {
+			log.Fatalf("Error setting up gRPC server: %v", err)
+		}
 	}
 
 	return app
@@ -117,14 +119,32 @@ func New(cfg *config.Config, db database.Database) *App {
 
 // SetupRoutes configures the application routes
 func (a *App) SetupRoutes() {
-	// Health check endpoint
-	a.fiber.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"status": "ok",
-			"name":   a.config.Server.Name,
-			"env":    a.config.Server.Env,
-		})
-	})
+	// Health check endpoint, probing the databases
+	a.healthHandler.SetupRoutes(a.fiber)
+
+	// Prometheus metrics, protected by their own token rather than a user login
+	if a.config.Metrics.Enabled {
+		a.metricsHandler.SetupRoutes(a.fiber)
+	}
+
+	// Report feeds for BI tools, authenticated with API keys scoped to the reports they read
+	if a.config.Feeds.Enabled {
+		feeds := a.fiber.Group("/feeds",
+			middleware.APIKeyMiddleware(a.apiKeyService),
+			middleware.PermissionMiddleware(a.operationService, "/feeds", feedPermissions),
+		)
+		a.feedHandler.SetupRoutes(feeds)
+	}
+
+	// Calendar feeds, authenticated by the token in the URL, which the request logs leave out
+	if a.config.Calendar.Enabled {
+		a.calendarHandler.SetupFeedRoutes(a.fiber)
+	}
+
+	// API documentation, before the API routes so it is served without authentication
+	if a.config.Docs.Enabled {
+		a.docsHandler.SetupRoutes(a.fiber)
+	}
 
 	// API routes
 	api := a.fiber.Group("/api")
@@ -133,9 +153,26 @@ func (a *App) SetupRoutes() {
 	whitelist := []string{
 		"/api/auth/login",
 	}
+	if a.config.SAML.Enabled {
+		whitelist = append(whitelist,
+			"/api/auth/saml/metadata",
+			"/api/auth/saml/login",
+			"/api/auth/saml/acs",
+		)
+	}
 
 	// Protected routes
-	protected := api.Group("/", middleware.JWTMiddleware(a.authService, whitelist))
+	protected := api.Group("/",
+		// Audit before authentication so rejected requests are recorded too
+		middleware.AuditMiddleware(a.auditService, a.config.Audit.MaxPayloadBytes),
+		middleware.JWTMiddleware(a.authService, a.apiKeyService, a.operationService, whitelist),
+		middleware.CompanyMiddleware(a.config.ERPCompanyRegistry(), a.config.DefaultERPCompany()),
+		middleware.PermissionMiddleware(a.operationService, "/api", routePermissions),
+		middleware.ExportApprovalMiddleware(a.config.ExportApproval, "/api", directExportRoutes),
+		middleware.IdempotencyMiddleware(a.config.Idempotency, a.store),
+		// After idempotency so replayed exports, which do not reach the ERP, are not counted
+		middleware.RateLimitMiddleware(a.config.RateLimit, a.store, "/api", exportRoutes),
+	)
 
 	// Setup all handler routes
 	for _, handler := range a.handlers {
@@ -166,38 +203,86 @@ func (a *App) Start() {
 		}
 	}()
 
-	log.Printf("Server started on port %s", a.config.Server.Port)
+	a.logger.Info("Server started", "port", a.config.Server.Port)
+
+	if a.grpcServer != nil {
+		go func() {
+			if err := a.grpcServer.ListenAndServe(); err != nil {
+				log.Fatalf("Error starting gRPC server: %v", err)
+			}
+		}()
+		a.logger.Info("gRPC server started", "port", a.config.GRPC.Port)
+	}
+
+	// Start background jobs. Export jobs, the job engine and schedules get their own context so they stop
+	// taking work as soon as the shutdown starts, while the others run until the end.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	workersCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+	a.erpSyncService.Start(ctx)
+	a.eventService.Start(ctx)
+	a.notificationService.Start(ctx)
+	a.downloadService.Start(ctx)
+	a.exportJobService.Start(workersCtx)
+	a.webhookService.Start(workersCtx)
+	a.jobService.Start(workersCtx)
+	a.scheduleService.Start(workersCtx)
+	a.auditService.Start(ctx)
 
 	// Wait for interrupt signal
 	<-sigChan
-	log.Println("Shutting down server...")
-
-	// Close database connection
-	if err := a.db.Close(); err != nil {
-		log.Printf("Error closing database connection: %v", err)
+	timeout := time.Duration(a.config.Server.ShutdownTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
 	}
+	a.logger.Info("Shutting down server", "timeout", timeout.String())
 
-	// Shutdown server
-	if err := a.fiber.Shutdown(); err != nil {
-		log.Fatalf("Error shutting down server: %v", err)
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), timeout)
+	defer cancelDrain()
+
+	// Workers finish the job or delivery they are running, or have it cancelled at the timeout
+	stopWorkers()
+	var workers sync.WaitGroup
+	for _, service := range []interface{ Stop(ctx context.Context) }{a.exportJobService, a.scheduleService, a.webhookService, a.jobService} {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			service.Stop(drainCtx)
+		}()
 	}
 
-	log.Println("Server gracefully stopped")
-}
+	// The gRPC server stops alongside, finishing its running calls
+	if a.grpcServer != nil {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			if err := a.grpcServer.Shutdown(drainCtx); err != nil {
+				a.logger.Warn("Shutdown timeout reached with gRPC calls running", "error", err)
+			}
+		}()
+	}
 
-// errorHandler handles API errors
-func errorHandler(c *fiber.Ctx, err error) error {
-	code := fiber.StatusInternalServerError
-	message := "Internal Server Error"
+	// Stop accepting connections and wait for the running requests
+	if err := a.fiber.ShutdownWithContext(drainCtx); err != nil {
+		a.logger.Warn("Shutdown timeout reached, cancelling running requests", "requests", a.requests.InFlight())
+	}
+	if a.requests.InFlight() > 0 {
+		a.requests.Cancel()
+		cancelCtx, cancelWait := context.WithTimeout(context.Background(), shutdownCancelWait)
+		if err := a.requests.Wait(cancelCtx); err != nil {
+			a.logger.Warn("Requests still running after cancellation", "requests", a.requests.InFlight())
+		}
+		cancelWait()
+	}
+	workers.Wait()
 
-	if e, ok := err.(*fiber.Error); ok {
-		code = e.Code
-		message = e.Message
+	// The databases are only closed once nothing uses them
+	cancel()
+	if err := a.db.Close(); err != nil {
+		a.logger.Error("Error closing database connection", "error", err)
 	}
+	a.store.Close()
 
-	return c.Status(code).JSON(fiber.Map{
-		"success": false,
-		"message": message,
-		"error":   err.Error(),
-	})
+	a.logger.Info("Server gracefully stopped")
 }
@@ -0,0 +1,591 @@
+package app
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/database"
+	"erp-excel/internal/cache"
+	"erp-excel/internal/handlers"
+	"erp-excel/internal/integration"
+	"erp-excel/internal/ldap"
+	"erp-excel/internal/metrics"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/service"
+	"erp-excel/internal/storage"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// container holds the dependencies of the application. They are built in stages: the
+// repositories, then the services using them, then the handlers serving them. Each stage only
+// reads the fields set by the stages before it, so a command or a test replaces a dependency by
+// setting its field between two stages.
+type container struct {
+	config *config.Config
+	db     database.Database
+	logger *slog.Logger
+	fiber  *fiber.App
+
+	repositories
+	services
+
+	// Handlers of the protected API routes, in the order their routes are registered
+	handlers []handlers.BaseHandler
+	// Handlers with routes of their own, outside the protected API
+	feedHandler     *handlers.FeedHandler
+	calendarHandler *handlers.CalendarHandler
+	healthHandler   *handlers.HealthHandler
+	docsHandler     *handlers.DocsHandler
+	metricsHandler  *handlers.MetricsHandler
+}
+
+// repositories are the repositories of the app and ERP databases
+type repositories struct {
+	txManager            repository.TxManager
+	userRepo             repository.UserRepository
+	departmentRepo       repository.DepartmentRepository
+	roleRepo             repository.RoleRepository
+	operationRepo        repository.OperationRepository
+	apiKeyRepo           repository.APIKeyRepository
+	calendarTokenRepo    repository.CalendarTokenRepository
+	rowPolicyRepo        repository.RowPolicyRepository
+	assistant230Repo     repository.Assistant230Repository
+	assistant610Repo     repository.Assistant610Repository
+	assistant340Repo     repository.Assistant340Repository
+	reconciliationRepo   repository.ReconciliationRepository
+	dashboardRepo        repository.DashboardRepository
+	itemInventoryRepo    repository.ItemInventoryRepository
+	stockBalanceRepo     repository.StockBalanceRepository
+	reportSourceRepo     repository.ReportSourceRepository
+	reportDefinitionRepo repository.ReportDefinitionRepository
+	reportLimitRepo      repository.ReportLimitRepository
+	reportFileRepo       repository.ReportFileRepository
+	reportSnapshotRepo   repository.ReportSnapshotRepository
+	reportAnnotationRepo repository.ReportAnnotationRepository
+	reportScheduleRepo   repository.ReportScheduleRepository
+	reportPresetRepo     repository.ReportPresetRepository
+	reportFavoriteRepo   repository.ReportFavoriteRepository
+	exportJobRepo        repository.ExportJobRepository
+	erpCacheRepo         repository.ERPCacheRepository
+	erpWriteBackRepo     repository.ERPWriteBackRepository
+	dataImportRepo       repository.DataImportRepository
+	eventOutboxRepo      repository.EventOutboxRepository
+	exchangeRateRepo     repository.ExchangeRateRepository
+	translationRepo      repository.TranslationRepository
+	configBackupRepo     repository.ConfigBackupRepository
+	notificationRepo     repository.NotificationRepository
+	auditLogRepo         repository.AuditLogRepository
+	jobRepo              repository.JobRepository
+	webhookRepo          repository.WebhookRepository
+}
+
+// services are the services and the clients, stores and limits they share
+type services struct {
+	// Generated files, in the local directory, a share or S3
+	fileStorage storage.Storage
+	// Revoked tokens, rate limit counters, cached figures and locks; in Redis when configured
+	store            cache.Store
+	sheetsClient     integration.GoogleSheetsClient
+	sharePointClient integration.SharePointClient
+	directory        *ldap.Client // nil unless LDAP login is enabled
+
+	reportNamer     service.ReportNamer
+	reportLimiter   service.ReportLimiter
+	reportMasker    service.ReportMasker
+	erpThrottle     *service.ERPThrottle
+	queryTimeouts   service.QueryTimeouts
+	metricsRegistry *metrics.Registry
+	erpQueryMonitor *service.ERPQueryMonitor
+
+	schemaCheckService      service.SchemaCheckService
+	jobService              service.JobService
+	webhookService          service.WebhookService
+	eventService            service.EventService
+	authService             service.AuthService
+	userService             service.UserService
+	departmentService       service.DepartmentService
+	roleService             service.RoleService
+	operationService        service.OperationService
+	apiKeyService           service.APIKeyService
+	searchService           service.SearchService
+	dashboardService        service.DashboardService
+	menuService             service.MenuService
+	exchangeRateService     service.ExchangeRateService
+	translationService      service.TranslationService
+	rowPolicyService        service.RowPolicyService
+	reportService           service.ReportService
+	assistant610Service     service.Assistant610Service
+	assistant340Service     service.Assistant340Service
+	itemInventoryService    service.ItemInventoryService
+	stockBalanceService     service.StockBalanceService
+	reconciliationService   service.ReconciliationService
+	reportComparisonService service.ReportComparisonService
+	reportEngineService     service.ReportEngineService
+	reportLimitService      service.ReportLimitService
+	reportDefinitionService service.ReportDefinitionService
+	reportAnnotationService service.ReportAnnotationService
+	reportSnapshotService   service.ReportSnapshotService
+	reportPresetService     service.ReportPresetService
+	reportHistoryService    service.ReportHistoryService
+	configBackupService     service.ConfigBackupService
+	downloadService         service.DownloadService
+	notificationService     service.NotificationService
+	exportApprovalService   service.ExportApprovalService
+	exportJobService        service.ExportJobService
+	auditService            service.AuditService
+	scheduleService         service.ReportScheduleService
+	erpSyncService          service.ERPSyncService
+	erpWriteBackService     service.ERPWriteBackService
+	dataImportService       service.DataImportService
+	calendarService         service.CalendarService
+	samlService             service.SAMLService
+	healthService           service.HealthService
+}
+
+// newContainer creates an empty container
+func newContainer(cfg *config.Config, db database.Database, logger *slog.Logger, app *fiber.App) *container {
+	return &container{
+		config: cfg,
+		db:     db,
+		logger: logger,
+		fiber:  app,
+	}
+}
+
+// buildRepositories creates the repositories, migrating the app database first when configured
+func (c *container) buildRepositories() {
+	cfg, db := c.config, c.db
+	if cfg.Migrations.OnStartup {
+		migrateOnStartup(db)
+	} else {
+		requireMigrations(db)
+	}
+	fieldCipher, err := newFieldCipher(cfg.Encryption)
+	if err != nil {
+		log.Fatalf("Error setting up field encryption: %v", err)
+	}
+
+	c.userRepo = repository.NewUserRepository(db.DB(), db, fieldCipher)
+	c.departmentRepo = repository.NewDepartmentRepository(db.DB(), db)
+	c.roleRepo = repository.NewRoleRepository(db.DB(), db, fieldCipher)
+	c.txManager = repository.NewTxManager(db.DB())
+	if cfg.Preflight.OnStartup || cfg.Preflight.Strict {
+		preflight(cfg, db)
+	}
+	c.operationRepo = repository.NewOperationRepository(db.DB())
+
+	if cfg.ERPSync.UseCache {
+		c.assistant230Repo = repository.NewCachedAssistant230Repository(db.DB(), cfg.DefaultERPCompany(), c.logger)
+		c.assistant610Repo = repository.NewCachedAssistant610Repository(db.DB(), cfg.DefaultERPCompany(), c.logger)
+		c.reconciliationRepo = repository.NewCachedReconciliationRepository(db.DB(), cfg.DefaultERPCompany(), c.logger)
+		c.dashboardRepo = repository.NewCachedDashboardRepository(db.DB(), cfg.DefaultERPCompany(), c.logger)
+	} else {
+		c.assistant230Repo = repository.NewAssistant230Repository(db, c.logger)
+		c.assistant610Repo = repository.NewAssistant610Repository(db, c.logger)
+		c.reconciliationRepo = repository.NewReconciliationRepository(db, c.logger)
+		c.dashboardRepo = repository.NewDashboardRepository(db, c.logger)
+	}
+	c.erpCacheRepo = repository.NewERPCacheRepository(db.DB(), db.ERPDatabase())
+	c.eventOutboxRepo = repository.NewEventOutboxRepository(db.DB())
+	c.erpWriteBackRepo = repository.NewERPWriteBackRepository(db.DB(), db)
+	c.dataImportRepo = repository.NewDataImportRepository(db.DB(), db)
+	c.itemInventoryRepo = repository.NewItemInventoryRepository(db, c.logger)
+	c.assistant340Repo = repository.NewAssistant340Repository(db, c.logger)
+	c.stockBalanceRepo = repository.NewStockBalanceRepository(db, c.logger)
+	c.reportSourceRepo = repository.NewReportSourceRepository(db, c.logger)
+	c.reportDefinitionRepo = repository.NewReportDefinitionRepository(db.DB())
+	c.reportLimitRepo = repository.NewReportLimitRepository(db.DB())
+	c.translationRepo = repository.NewTranslationRepository(db.DB())
+	c.reportFileRepo = repository.NewReportFileRepository(db.DB())
+	c.exchangeRateRepo = repository.NewExchangeRateRepository(db.DB())
+	c.reportSnapshotRepo = repository.NewReportSnapshotRepository(db.DB())
+	c.reportAnnotationRepo = repository.NewReportAnnotationRepository(db.DB())
+	c.reportScheduleRepo = repository.NewReportScheduleRepository(db.DB())
+	c.reportPresetRepo = repository.NewReportPresetRepository(db.DB())
+	c.reportFavoriteRepo = repository.NewReportFavoriteRepository(db.DB())
+	c.exportJobRepo = repository.NewExportJobRepository(db.DB())
+	c.apiKeyRepo = repository.NewAPIKeyRepository(db.DB())
+	c.calendarTokenRepo = repository.NewCalendarTokenRepository(db.DB())
+	c.rowPolicyRepo = repository.NewRowPolicyRepository(db.DB())
+	c.configBackupRepo = repository.NewConfigBackupRepository(db.DB())
+	c.notificationRepo = repository.NewNotificationRepository(db.DB())
+	c.auditLogRepo = repository.NewAuditLogRepository(db.DB())
+	c.jobRepo = repository.NewJobRepository(db.DB())
+	c.webhookRepo = repository.NewWebhookRepository(db.DB())
+}
+
+// buildServices creates the services on top of the repositories
+func (c *container) buildServices() {
+	cfg, logger := c.config, c.logger
+	var err error
+
+	// Limits and instrumentation of the report queries
+	c.reportNamer = service.NewReportNamer(cfg.Reports.Templates, cfg.ERPCompanyRegistry(), c.userRepo, c.departmentRepo, logger)
+	c.reportLimiter = service.NewReportLimiter(cfg, c.userRepo, logger)
+	c.erpThrottle = service.NewERPThrottle(cfg.Reports.Throttle, cfg.DefaultERPCompany(), logger)
+	c.queryTimeouts = service.NewQueryTimeouts(cfg.Reports, c.erpThrottle)
+	c.metricsRegistry = metrics.NewRegistry()
+	c.erpThrottle.RegisterMetrics(c.metricsRegistry)
+	c.erpQueryMonitor = service.NewERPQueryMonitor(cfg.Metrics, c.metricsRegistry, logger)
+	c.db.ObserveERPQueries(c.erpQueryMonitor)
+
+	// Setup file storage
+	c.fileStorage, err = storage.NewStorage(cfg.Storage)
+	if err != nil {
+		log.Fatalf("Error setting up file storage: %v", err)
+	}
+	if cfg.Redis.Enabled && (cfg.Storage.Driver == "" || cfg.Storage.Driver == "local") {
+		// Redis is only needed with several instances, which do not see each other's local files
+		logger.Warn("Generated files are stored on the local disk; downloads only work on the instance that generated them, use the share or s3 storage driver")
+	}
+	if expiry, ok := c.fileStorage.(storage.ExpiryPolicy); ok && cfg.Storage.S3.Lifecycle {
+		// The cleanup still runs; the bucket rule also covers the time no instance is up
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := expiry.SetExpiry(ctx, cfg.Downloads.MaxAgeDays); err != nil {
+			logger.Warn("Error setting the expiry of stored reports", "error", err)
+		} else {
+			logger.Info("Stored reports expire with a bucket lifecycle rule", "max_age_days", cfg.Downloads.MaxAgeDays)
+		}
+		cancel()
+	}
+
+	// Setup integrations
+	c.sheetsClient = integration.NewGoogleSheetsClient(cfg.GoogleSheets)
+	c.sharePointClient = integration.NewSharePointClient(cfg.SharePoint)
+	if cfg.LDAP.Enabled {
+		if c.directory, err = ldap.NewClient(cfg.LDAP); err != nil {
+			log.Fatalf("Error setting up LDAP login: %v", err)
+		}
+	}
+
+	// Users, roles and their access
+	c.store = cache.New(cfg.Redis, logger)
+	c.jobService = service.NewJobService(cfg.Jobs, c.jobRepo, logger)
+	c.webhookService = service.NewWebhookService(cfg.Webhooks, c.webhookRepo, logger)
+	c.eventService, err = service.NewEventService(cfg.Events, c.eventOutboxRepo, c.webhookService, logger)
+	if err != nil {
+		log.Fatalf("Error setting up event publishing: %v", err)
+	}
+	c.authService = service.NewAuthService(c.userRepo, cfg, c.directory, c.eventService, c.store, logger)
+	c.userService = service.NewUserService(c.userRepo, c.departmentRepo, c.roleRepo, c.txManager, c.authService, c.eventService, logger)
+	c.departmentService = service.NewDepartmentService(c.departmentRepo, logger)
+	c.roleService = service.NewRoleService(c.roleRepo, c.operationRepo, c.txManager)
+	c.operationService = service.NewOperationService(c.operationRepo, c.userRepo, c.roleRepo, cfg.JWT.AdminOperation())
+	if err := c.operationService.EnsureOperations(context.Background(), startupOperations(cfg)); err != nil {
+		log.Fatalf("Error registering route operations: %v", err)
+	}
+	c.apiKeyService = service.NewAPIKeyService(c.apiKeyRepo, c.userRepo, c.operationService, logger)
+	c.searchService = service.NewSearchService(c.userRepo, c.departmentRepo, c.roleRepo)
+	c.exchangeRateService = service.NewExchangeRateService(cfg.Currency, c.exchangeRateRepo)
+	c.translationService = service.NewTranslationService(c.translationRepo)
+	if err := c.translationService.Reload(context.Background()); err != nil {
+		logger.Warn("Error loading translation labels, using built-in labels", "error", err)
+	}
+	c.rowPolicyService = service.NewRowPolicyService(
+		c.rowPolicyRepo,
+		c.userRepo,
+		c.departmentRepo,
+		c.roleRepo,
+		logger,
+	)
+	c.dashboardService = service.NewDashboardService(cfg.Dashboard, c.dashboardRepo, c.rowPolicyService, c.store, logger)
+	c.reportMasker, err = service.NewReportMasker(cfg.Reports, c.userRepo, c.operationService, logger)
+	if err != nil {
+		log.Fatalf("Error setting up report masking: %v", err)
+	}
+
+	// Reports
+	c.reportService = service.NewReportService(
+		c.db.ERPDatabase(),
+		cfg,
+		c.userRepo,
+		c.operationRepo,
+		c.assistant230Repo,
+		c.sheetsClient,
+		c.fileStorage,
+		c.reportFileRepo,
+		c.reportNamer,
+		c.reportLimiter,
+		c.queryTimeouts,
+		c.sharePointClient,
+		c.eventService,
+		c.exchangeRateService,
+		c.reportAnnotationRepo,
+		c.rowPolicyService,
+		c.reportMasker,
+		logger,
+	)
+	c.assistant610Service = service.NewAssistant610Service(
+		c.db.ERPDatabase(),
+		cfg,
+		c.userRepo,
+		c.operationRepo,
+		c.assistant610Repo,
+		c.sheetsClient,
+		c.fileStorage,
+		c.reportFileRepo,
+		c.reportNamer,
+		c.reportLimiter,
+		c.queryTimeouts,
+		c.sharePointClient,
+		c.eventService,
+		c.exchangeRateService,
+		c.reportAnnotationRepo,
+		c.rowPolicyService,
+		c.reportMasker,
+		logger,
+	)
+	c.itemInventoryService = service.NewItemInventoryService(
+		cfg,
+		c.itemInventoryRepo,
+		c.operationService,
+		c.fileStorage,
+		c.reportFileRepo,
+		c.reportNamer,
+		c.reportLimiter,
+		c.queryTimeouts,
+		c.sharePointClient,
+		c.eventService,
+		logger,
+	)
+	c.stockBalanceService = service.NewStockBalanceService(
+		cfg,
+		c.stockBalanceRepo,
+		c.operationService,
+		c.fileStorage,
+		c.reportFileRepo,
+		c.reportNamer,
+		c.reportLimiter,
+		c.queryTimeouts,
+		c.sharePointClient,
+		c.eventService,
+		logger,
+	)
+	c.assistant340Service = service.NewAssistant340Service(
+		c.assistant340Repo,
+		c.operationService,
+		c.fileStorage,
+		c.reportFileRepo,
+		c.reportNamer,
+		c.reportLimiter,
+		c.queryTimeouts,
+		c.sharePointClient,
+		c.eventService,
+		c.rowPolicyService,
+		c.reportMasker,
+		logger,
+	)
+	c.reconciliationService = service.NewReconciliationService(c.operationRepo, c.reconciliationRepo, c.rowPolicyService, c.fileStorage, c.reportFileRepo, c.reportNamer, c.reportLimiter, c.queryTimeouts, c.eventService, logger)
+	c.reportComparisonService = service.NewReportComparisonService(c.reportService, c.assistant610Service, logger)
+	c.reportEngineService, err = service.NewReportEngineService(
+		cfg.Reports,
+		c.reportSourceRepo,
+		c.reportDefinitionRepo,
+		c.departmentRepo,
+		c.operationService,
+		c.fileStorage,
+		c.reportFileRepo,
+		c.reportNamer,
+		c.reportLimiter,
+		c.erpThrottle,
+		c.sharePointClient,
+		c.eventService,
+		logger,
+	)
+	if err != nil {
+		log.Fatalf("Error setting up report definitions: %v", err)
+	}
+	c.menuService = service.NewMenuService(cfg, c.roleRepo, c.reportEngineService, logger)
+	c.reportLimitService = service.NewReportLimitService(c.reportLimitRepo, c.reportLimiter, c.reportEngineService)
+	if err := c.reportLimitService.Reload(context.Background()); err != nil {
+		logger.Warn("Error loading report limits, using configured limits", "error", err)
+	}
+	c.reportDefinitionService = service.NewReportDefinitionService(cfg.Reports, c.reportDefinitionRepo)
+	c.configBackupService = service.NewConfigBackupService(cfg.Server, c.configBackupRepo, c.reportDefinitionRepo)
+	c.reportAnnotationService = service.NewReportAnnotationService(
+		c.reportAnnotationRepo,
+		c.assistant230Repo,
+		c.assistant610Repo,
+		c.operationService,
+		cfg.NoteImport.OperationCode,
+		logger,
+	)
+
+	// Exports, downloads and the background work around them
+	c.downloadService = service.NewDownloadService(cfg.Downloads, c.fileStorage, c.reportFileRepo, logger)
+	c.notificationService = service.NewNotificationService(
+		cfg.Notifications,
+		c.notificationRepo,
+		c.userRepo,
+		integration.NewMailer(cfg.Mail),
+		logger,
+	)
+	c.exportApprovalService = service.NewExportApprovalService(
+		cfg.ExportApproval,
+		c.exportJobRepo,
+		c.departmentRepo,
+		c.roleRepo,
+		c.userRepo,
+		c.notificationService,
+		c.eventService,
+		logger,
+	)
+	c.exportJobService = service.NewExportJobService(
+		cfg.ExportJobs,
+		c.exportJobRepo,
+		c.fileStorage,
+		c.reportFileRepo,
+		c.exportApprovalService,
+		c.notificationService,
+		c.reportService,
+		c.assistant610Service,
+		logger,
+	)
+	c.auditService = service.NewAuditService(cfg.Audit, c.auditLogRepo, logger)
+	c.scheduleService = service.NewReportScheduleService(
+		cfg.Schedules,
+		c.reportScheduleRepo,
+		c.userRepo,
+		c.operationService,
+		c.exportApprovalService,
+		c.fileStorage,
+		integration.NewMailer(cfg.Mail),
+		c.sheetsClient,
+		c.reportService,
+		c.assistant610Service,
+		logger,
+	)
+	c.reportSnapshotService = service.NewReportSnapshotService(
+		c.reportSnapshotRepo,
+		c.reportService,
+		c.assistant610Service,
+		c.reportEngineService,
+		c.operationService,
+		c.exportApprovalService,
+		c.fileStorage,
+		c.reportFileRepo,
+		c.reportNamer,
+		c.sharePointClient,
+		c.eventService,
+		cfg.Snapshots.OperationCode,
+		logger,
+	)
+	c.erpSyncService = service.NewERPSyncService(cfg, c.erpCacheRepo, c.store, logger)
+	c.reportPresetService = service.NewReportPresetService(c.reportPresetRepo, c.reportEngineService, logger)
+	c.reportHistoryService = service.NewReportHistoryService(c.operationRepo, c.reportFavoriteRepo, c.reportEngineService, logger)
+	c.calendarService = service.NewCalendarService(
+		cfg,
+		c.calendarTokenRepo,
+		c.userRepo,
+		logger,
+		service.NewReportRunCalendarSource(c.operationRepo, cfg.Calendar.HistoryDays),
+		service.NewERPSyncCalendarSource(cfg, c.erpSyncService),
+	)
+	c.erpWriteBackService, err = service.NewERPWriteBackService(cfg.ERPWriteBack, c.erpWriteBackRepo, c.operationService, logger)
+	if err != nil {
+		log.Fatalf("Error setting up ERP write-back: %v", err)
+	}
+	c.dataImportService = service.NewDataImportService(cfg.Imports, c.dataImportRepo, c.operationService, logger)
+	c.samlService, err = service.NewSAMLService(cfg.SAML, c.userRepo, c.authService, c.eventService, logger)
+	if err != nil {
+		log.Fatalf("Error setting up SAML: %v", err)
+	}
+	c.schemaCheckService = newSchemaCheckService(cfg, c.db)
+	c.healthService = service.NewHealthService(cfg, c.db.DB(), c.db.ERPDatabases(), logger)
+}
+
+// buildHandlers creates the handlers on top of the services
+func (c *container) buildHandlers() {
+	cfg, operationService := c.config, c.operationService
+
+	c.calendarHandler = handlers.NewCalendarHandler(c.calendarService)
+	c.healthHandler = handlers.NewHealthHandler(c.healthService)
+	c.docsHandler = handlers.NewDocsHandler(c.fiber, apiDocs(cfg), cfg.Docs.SwaggerUIURL)
+	c.metricsHandler = handlers.NewMetricsHandler(c.metricsRegistry, cfg.Metrics.Token)
+	c.feedHandler = handlers.NewFeedHandler(c.reportService, c.assistant610Service, cfg.Feeds.PageSize, cfg.Feeds.DefaultPeriod)
+
+	c.handlers = []handlers.BaseHandler{
+		handlers.NewAuthHandler(c.authService, c.menuService, operationService),
+		handlers.NewUserHandler(c.userService),
+		handlers.NewDepartmentHandler(c.departmentService),
+		handlers.NewRoleHandler(c.roleService),
+		handlers.NewReportHandler(c.reportService, c.assistant230Repo, c.fileStorage, c.downloadService),
+		handlers.NewAdminHandler(
+			c.userService,
+			c.departmentService,
+			c.roleService,
+			operationService,
+			c.searchService,
+			c.dashboardService,
+		),
+		handlers.NewOperationHandler(operationService),
+		handlers.NewAssistant610Handler(c.assistant610Service, c.assistant610Repo, c.fileStorage, c.downloadService),
+		handlers.NewAssistant340Handler(c.assistant340Service),
+		handlers.NewItemInventoryHandler(c.itemInventoryService, operationService, cfg.Inventory.OperationCode),
+		handlers.NewStockBalanceHandler(c.stockBalanceService, operationService, cfg.Inventory.StockOperationCode),
+		handlers.NewReconciliationHandler(c.reconciliationService),
+		handlers.NewReportComparisonHandler(c.reportComparisonService),
+		handlers.NewERPSyncHandler(c.erpSyncService),
+		handlers.NewERPWriteBackHandler(c.erpWriteBackService, operationService, cfg.ERPWriteBack.OperationCode),
+		handlers.NewDataImportHandler(c.dataImportService, operationService, cfg.Imports.OperationCode, cfg.Imports.RuleOperationCode),
+		handlers.NewReportDefinitionHandler(c.reportDefinitionService, operationService, cfg.Reports.AdminOperationCode),
+		handlers.NewExchangeRateHandler(c.exchangeRateService, operationService, cfg.Currency.OperationCode),
+		handlers.NewReportSnapshotHandler(c.reportSnapshotService, operationService, cfg.Snapshots.OperationCode),
+		handlers.NewReportAnnotationHandler(c.reportAnnotationService, operationService, cfg.NoteImport.OperationCode),
+		handlers.NewTranslationHandler(c.translationService, operationService, cfg.Translations.OperationCode),
+		handlers.NewReportLimitHandler(c.reportLimitService, operationService, cfg.Reports.AdminOperationCode),
+		handlers.NewERPQueueHandler(c.erpThrottle, operationService, cfg.Reports.AdminOperationCode),
+		handlers.NewSlowQueryHandler(c.erpQueryMonitor, operationService, cfg.Reports.AdminOperationCode),
+		handlers.NewExportJobHandler(c.exportJobService, c.downloadService, operationService, c.fileStorage),
+		handlers.NewReportScheduleHandler(c.scheduleService, operationService, cfg.Schedules.OperationCode),
+		handlers.NewReportPresetHandler(c.reportPresetService),
+		handlers.NewReportHistoryHandler(c.reportHistoryService),
+		handlers.NewDownloadHandler(c.downloadService, operationService, c.fileStorage, cfg.Downloads.OperationCode),
+		handlers.NewConfigBackupHandler(c.configBackupService, operationService, cfg.Backup.OperationCode),
+		handlers.NewSchemaCheckHandler(c.schemaCheckService, operationService, cfg.Preflight.OperationCode),
+		handlers.NewAuditHandler(c.auditService, operationService, cfg.Audit.OperationCode),
+		handlers.NewExportApprovalHandler(c.exportApprovalService, operationService, cfg.ExportApproval.ApproverOperationCode),
+		handlers.NewNotificationHandler(c.notificationService),
+		handlers.NewAPIKeyHandler(c.apiKeyService, operationService, cfg.APIKeys.OperationCode),
+		handlers.NewBatchHandler(c.fiber, "/api"),
+		// generic /reports/:code routes go after the fixed report routes
+		handlers.NewReportEngineHandler(c.reportEngineService, operationService),
+	}
+	if cfg.Calendar.Enabled {
+		c.handlers = append(c.handlers, c.calendarHandler)
+	}
+	if c.samlService.Enabled() {
+		c.handlers = append(c.handlers, handlers.NewSAMLHandler(c.samlService))
+	}
+	if cfg.GraphQL.Enabled {
+		c.handlers = append(c.handlers, handlers.NewGraphQLHandler(
+			c.userService,
+			c.departmentService,
+			c.roleService,
+			operationService,
+			c.reportEngineService,
+			cfg.GraphQL.MaxDepth,
+		))
+	}
+	if cfg.Webhooks.Enabled {
+		c.handlers = append(c.handlers, handlers.NewWebhookHandler(c.webhookService, operationService, cfg.Webhooks.OperationCode))
+	}
+	c.handlers = append(c.handlers, handlers.NewRowPolicyHandler(c.rowPolicyService, operationService, cfg.RowPolicies.OperationCode))
+	c.handlers = append(c.handlers, handlers.NewJobHandler(c.jobService, operationService, cfg.Jobs.OperationCode))
+}
+
+// preflight checks the schemas of the databases before the app prepares its own columns, refusing
+// to start on a failed check when it is strict
+func preflight(cfg *config.Config, db database.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), schemaCheckTimeout)
+	result := newSchemaCheckService(cfg, db).Check(ctx)
+	cancel()
+	writeSchemaCheck(os.Stderr, result)
+	if !result.OK && cfg.Preflight.Strict {
+		log.Fatal("Refusing to start: the schema check failed (preflight.strict)")
+	}
+}
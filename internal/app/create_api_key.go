@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/database"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// RunCreateAPIKey runs the create-api-key subcommand and returns the process exit code. It issues
+// a service account key from the command line, so the first administrator key can be created
+// before any user is an administrator; by default the key has every scope.
+func RunCreateAPIKey(cfg *config.Config, db database.Database, args []string) int {
+	flags := flag.NewFlagSet("create-api-key", flag.ContinueOnError)
+	name := flags.String("name", "", "name of the service account (required)")
+	scopes := flags.String("scopes", "*", "comma-separated operation codes the key may use, * for all of them")
+	expires := flags.String("expires", "", "expiry date of the key, YYYY-MM-DD; the key never expires when empty")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	request := &dto.APIKeyRequest{Name: strings.TrimSpace(*name)}
+	for _, scope := range strings.Split(*scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			request.Scopes = append(request.Scopes, scope)
+		}
+	}
+	if *expires != "" {
+		expiresAt, err := time.ParseInLocation("2006-01-02", *expires, time.Local)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid expiry date %q, expected YYYY-MM-DD\n", *expires)
+			return 2
+		}
+		request.ExpiresAt = &expiresAt
+	}
+	if err := utils.ValidateStruct(request); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid API key: %v\n", err)
+		return 2
+	}
+
+	cipher, err := newFieldCipher(cfg.Encryption)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting up field encryption: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	userRepo := repository.NewUserRepository(db.DB(), nil, cipher)
+	operationService := service.NewOperationService(
+		repository.NewOperationRepository(db.DB()),
+		userRepo,
+		repository.NewRoleRepository(db.DB(), nil, cipher),
+		cfg.JWT.AdminOperation(),
+	)
+	if err := operationService.EnsureOperations(ctx, startupOperations(cfg)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error registering route operations: %v\n", err)
+		return 1
+	}
+	apiKeyService := service.NewAPIKeyService(repository.NewAPIKeyRepository(db.DB()), userRepo, operationService, slog.Default())
+
+	// Created by nobody, as an administrator: whoever runs the command has the server's config
+	created, err := apiKeyService.Create(ctx, 0, true, request)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating API key: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stdout, "API key %d %q created with scopes %s\n", created.APIKey.ID, created.APIKey.Name, strings.Join(created.APIKey.Scopes, ","))
+	fmt.Fprintln(os.Stdout, "Store the key now, it cannot be shown again:")
+	fmt.Fprintln(os.Stdout, created.Key)
+	return 0
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/database"
+	"erp-excel/internal/fieldcrypt"
+	"erp-excel/internal/repository"
+	"fmt"
+	"os"
+)
+
+// encryptUsersBatchSize is the number of users read and rewritten at a time
+const encryptUsersBatchSize = 500
+
+// newFieldCipher creates the cipher of the encrypted user fields, nil when no key is configured
+func newFieldCipher(cfg config.EncryptionConfig) (*fieldcrypt.Cipher, error) {
+	keyID, keys := cfg.Keys()
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return fieldcrypt.New(keyID, keys)
+}
+
+// RunEncryptUsers runs the encrypt-users subcommand and returns the process exit code. It
+// rewrites the email and phone of the existing users as the configured keys store them: it
+// encrypts plaintext rows, moves rows to the current key after a rotation, and decrypts them
+// when only previous keys are left. It can run while the server is up and again after a failure.
+func RunEncryptUsers(cfg *config.Config, db database.Database, args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "Unexpected argument %q, encrypt-users takes none\n", args[0])
+		return 2
+	}
+
+	cipher, err := newFieldCipher(cfg.Encryption)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting up field encryption: %v\n", err)
+		return 1
+	}
+	if cipher == nil {
+		fmt.Fprintln(os.Stderr, "No encryption key configured, set encryption.key or encryption.key_env")
+		return 2
+	}
+
+	ctx := context.Background()
+	userRepo := repository.NewUserRepository(db.DB(), nil, cipher)
+
+	rewritten, err := userRepo.RewriteEncryptedFields(ctx, encryptUsersBatchSize)
+	fmt.Fprintf(os.Stdout, "%d user(s) rewritten\n", rewritten)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error rewriting users: %v\n", err)
+		return 1
+	}
+	if cipher.Enabled() {
+		fmt.Fprintln(os.Stdout, "The email and phone of every user are encrypted with the current key")
+	} else {
+		fmt.Fprintln(os.Stdout, "The email and phone of every user are stored in plaintext; the previous keys can be removed")
+	}
+	return 0
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"context"
+	"erp-excel/database"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// migrateTimeout bounds applying the migrations, which may rebuild indexes of large tables
+const migrateTimeout = 10 * time.Minute
+
+// RunMigrate runs the migrate subcommand against the app database and returns the process exit
+// code. With "status" it lists the migrations instead of applying the pending ones.
+func RunMigrate(db database.Database, args []string) int {
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+
+	if len(args) > 0 && args[0] == "status" {
+		migrations, err := database.MigrationStatus(ctx, db.DB())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading migration status: %v\n", err)
+			return 1
+		}
+		writeMigrations(os.Stdout, migrations)
+		return 0
+	}
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "Unknown migrate command %q, expected no argument or \"status\"\n", args[0])
+		return 2
+	}
+
+	applied, err := database.Migrate(ctx, db.DB())
+	writeMigrations(os.Stdout, applied)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error applying migrations: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stdout, "%d migration(s) applied\n", len(applied))
+	return 0
+}
+
+// migrateOnStartup applies the pending migrations before the repositories are created
+func migrateOnStartup(db database.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+
+	applied, err := database.Migrate(ctx, db.DB())
+	writeMigrations(os.Stderr, applied)
+	if err != nil {
+		log.Fatalf("Error applying migrations: %v", err)
+	}
+}
+
+// requireMigrations stops the server when migrations are pending, since the tables and columns
+// they add are not created anywhere else
+func requireMigrations(db database.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+
+	migrations, err := database.MigrationStatus(ctx, db.DB())
+	if err != nil {
+		log.Fatalf("Error reading migration status: %v", err)
+	}
+	var pending []database.Migration
+	for _, migration := range migrations {
+		if migration.AppliedAt == nil {
+			pending = append(pending, migration)
+		}
+	}
+	if len(pending) > 0 {
+		writeMigrations(os.Stderr, pending)
+		log.Fatalf("%d migration(s) pending: run \"server migrate\" or set migrations.on_startup", len(pending))
+	}
+}
+
+// writeMigrations prints one line per migration with the time it was applied
+func writeMigrations(w io.Writer, migrations []database.Migration) {
+	for _, migration := range migrations {
+		status := "pending"
+		if migration.AppliedAt != nil {
+			status = migration.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%04d %-40s %s\n", migration.Version, migration.Name, status)
+	}
+}
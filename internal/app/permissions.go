@@ -0,0 +1,205 @@
+package app
+
+import (
+	"erp-excel/config"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/models"
+	"slices"
+
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// permissionOperations are the operations guarding the routes in routePermissions. They are
+// registered at startup so they can be granted to roles; handlers that check their own
+// configurable operation are not listed.
+var permissionOperations = []*models.Operation{
+	{Code: "users:read", Name: "View users", Description: "List and view users"},
+	{Code: "users:create", Name: "Create users", Description: "Create users"},
+	{Code: "users:update", Name: "Update users", Description: "Edit users and assign their roles"},
+	{Code: "users:delete", Name: "Delete users", Description: "Delete and restore users"},
+	{Code: "departments:read", Name: "View departments", Description: "List and view departments"},
+	{Code: "departments:create", Name: "Create departments", Description: "Create departments"},
+	{Code: "departments:update", Name: "Update departments", Description: "Edit departments"},
+	{Code: "departments:delete", Name: "Delete departments", Description: "Delete and restore departments"},
+	{Code: "roles:read", Name: "View roles", Description: "List and view roles"},
+	{Code: "roles:create", Name: "Create roles", Description: "Create roles"},
+	{Code: "roles:update", Name: "Update roles", Description: "Edit roles and their operations"},
+	{Code: "roles:delete", Name: "Delete roles", Description: "Delete and restore roles"},
+	{Code: "operations:read", Name: "View operations", Description: "List operations, check user access and read access logs"},
+	{Code: "admin:dashboard", Name: "Admin dashboard", Description: "View the administration dashboard"},
+	{Code: "admin:search", Name: "Admin search", Description: "Search users, departments and roles"},
+	{Code: "admin:trash", Name: "Trash", Description: "View deleted users, departments and roles"},
+	{Code: "reports:view:230", Name: "View Assistant 230", Description: "Run and preview the inventory report"},
+	{Code: "reports:export:230", Name: "Export Assistant 230", Description: "Export the inventory report to Excel or Google Sheets"},
+	{Code: "reports:view:610", Name: "View Assistant 610", Description: "Run and preview the receivables report"},
+	{Code: "reports:export:610", Name: "Export Assistant 610", Description: "Export the receivables report to Excel or Google Sheets"},
+	{Code: "reports:view:340", Name: "View Assistant 340", Description: "Run the purchase receipts report"},
+	{Code: "reports:export:340", Name: "Export Assistant 340", Description: "Export the purchase receipts report to Excel"},
+	{Code: "reports:view:reconciliation", Name: "View reconciliation", Description: "Run the reconciliation report"},
+	{Code: "reports:export:reconciliation", Name: "Export reconciliation", Description: "Export the reconciliation report"},
+	{Code: "erp_sync:read", Name: "View ERP sync", Description: "View the ERP cache sync status"},
+	{Code: "erp_sync:run", Name: "Run ERP sync", Description: "Start an ERP cache sync"},
+}
+
+// startupOperations returns the operations registered at startup: those of routePermissions and
+// the configured admin operation
+func startupOperations(cfg *config.Config) []*models.Operation {
+	return append(slices.Clip(permissionOperations), &models.Operation{
+		Code:        cfg.JWT.AdminOperation(),
+		Name:        "Administrator",
+		Description: "Pass every operation check and issue API keys with every scope",
+	})
+}
+
+// routePermissions maps every /api route to the operation it requires; a route missing here is
+// denied. Routes every signed-in user needs, such as /auth, /users/password, /batch,
+// /notifications, signed /downloads links and the user's own export jobs, files, report presets,
+// history and favorites, and routes whose handler checks an operation of its own, have no
+// operation. The report bundle route checks the export operation of each report in the bundle
+// itself, and the report engine routes the operation of each report definition.
+var routePermissions = []middleware.RoutePermission{
+	// Open to every signed-in user
+	{Path: "/auth/*"},
+	{Method: fiber.MethodPost, Path: "/users/password"},
+	{Method: fiber.MethodPost, Path: "/batch"},
+	{Path: "/notifications/*"},
+	{Path: "/api-keys/*"},
+	{Method: fiber.MethodPost, Path: "/operations/log"},
+	{Method: fiber.MethodPut, Path: "/operations/log/:logID/status"},
+	{Method: fiber.MethodGet, Path: "/downloads/:fileName"},
+	{Method: fiber.MethodGet, Path: "/reports/files"},
+	{Path: "/reports/exports/*"},
+	{Method: fiber.MethodPost, Path: "/reports/bundle"},
+	{Method: fiber.MethodGet, Path: "/reports/history"},
+	{Path: "/reports/favorites/*"},
+	{Path: "/reports/presets/*"},
+	{Method: fiber.MethodGet, Path: "/reports/definitions"},
+	{Method: fiber.MethodGet, Path: "/exchange-rates"},
+	{Method: fiber.MethodGet, Path: "/calendar/subscription"},
+	{Method: fiber.MethodPost, Path: "/calendar/subscription"},
+	{Method: fiber.MethodDelete, Path: "/calendar/subscription"},
+	{Path: "/graphql/*"},
+
+	// Checked by the handler with an operation of its own, mostly configurable
+	{Path: "/admin/api-keys/*"},
+	{Path: "/admin/audit-logs"},
+	{Path: "/admin/config/*"},
+	{Path: "/admin/downloads/*"},
+	{Path: "/admin/erp-queue"},
+	{Path: "/admin/jobs/*"},
+	{Path: "/admin/report-limits/*"},
+	{Path: "/admin/reports/*"},
+	{Path: "/admin/row-policies/*"},
+	{Path: "/admin/schema/check"},
+	{Path: "/admin/slow-queries"},
+	{Path: "/admin/translations/*"},
+	{Path: "/admin/webhooks/*"},
+	{Path: "/erp/writeback/*"},
+	{Path: "/exchange-rates/:id"},
+	{Method: fiber.MethodPost, Path: "/exchange-rates"},
+	{Path: "/export-approvals/*"},
+	{Path: "/imports/*"},
+	{Path: "/reports/items/*"},
+	{Path: "/reports/stock-balance/*"},
+	{Path: "/reports/schedules/*"},
+	{Path: "/snapshots/*"},
+	{Method: fiber.MethodPost, Path: "/reports/inventory/notes/import"},
+	{Method: fiber.MethodPost, Path: "/reports/assistant610/notes/import"},
+
+	{Method: fiber.MethodGet, Path: "/users", OperationCode: "users:read"},
+	{Method: fiber.MethodGet, Path: "/users/:id", OperationCode: "users:read"},
+	{Method: fiber.MethodPost, Path: "/users", OperationCode: "users:create"},
+	{Method: fiber.MethodPut, Path: "/users/:id", OperationCode: "users:update"},
+	{Method: fiber.MethodPost, Path: "/users/:id/roles", OperationCode: "users:update"},
+	{Method: fiber.MethodDelete, Path: "/users/:id", OperationCode: "users:delete"},
+	{Method: fiber.MethodPost, Path: "/users/:id/restore", OperationCode: "users:delete"},
+
+	{Method: fiber.MethodGet, Path: "/departments", OperationCode: "departments:read"},
+	{Method: fiber.MethodGet, Path: "/departments/tree", OperationCode: "departments:read"},
+	{Method: fiber.MethodGet, Path: "/departments/:id", OperationCode: "departments:read"},
+	{Method: fiber.MethodPost, Path: "/departments", OperationCode: "departments:create"},
+	{Method: fiber.MethodPut, Path: "/departments/:id", OperationCode: "departments:update"},
+	{Method: fiber.MethodDelete, Path: "/departments/:id", OperationCode: "departments:delete"},
+	{Method: fiber.MethodPost, Path: "/departments/:id/restore", OperationCode: "departments:delete"},
+
+	{Method: fiber.MethodGet, Path: "/roles", OperationCode: "roles:read"},
+	{Method: fiber.MethodGet, Path: "/roles/:id", OperationCode: "roles:read"},
+	{Method: fiber.MethodPost, Path: "/roles", OperationCode: "roles:create"},
+	{Method: fiber.MethodPut, Path: "/roles/:id", OperationCode: "roles:update"},
+	{Method: fiber.MethodDelete, Path: "/roles/:id", OperationCode: "roles:delete"},
+	{Method: fiber.MethodPost, Path: "/roles/:id/restore", OperationCode: "roles:delete"},
+	{Method: fiber.MethodPost, Path: "/roles/:id/clone", OperationCode: "roles:create"},
+	{Method: fiber.MethodPost, Path: "/roles/operations", OperationCode: "roles:update"},
+	{Method: fiber.MethodGet, Path: "/roles/:id/users", OperationCode: "roles:read"},
+
+	// Logging access stays open: the frontend records the reports every user runs
+	{Method: fiber.MethodGet, Path: "/operations", OperationCode: "operations:read"},
+	{Method: fiber.MethodGet, Path: "/operations/access/:userID/:operationCode", OperationCode: "operations:read"},
+	{Method: fiber.MethodGet, Path: "/operations/logs/recent", OperationCode: "operations:read"},
+	{Method: fiber.MethodGet, Path: "/admin/operations", OperationCode: "operations:read"},
+	{Method: fiber.MethodGet, Path: "/admin/dashboard", OperationCode: "admin:dashboard"},
+	{Method: fiber.MethodGet, Path: "/admin/search", OperationCode: "admin:search"},
+	{Method: fiber.MethodGet, Path: "/admin/trash", OperationCode: "admin:trash"},
+
+	{Method: fiber.MethodPost, Path: "/reports/inventory", OperationCode: "reports:view:230"},
+	{Method: fiber.MethodPost, Path: "/reports/inventory/preview", OperationCode: "reports:view:230"},
+	{Method: fiber.MethodPost, Path: "/reports/inventory/compare", OperationCode: "reports:view:230"},
+	{Method: fiber.MethodPost, Path: "/reports/inventory/export", OperationCode: "reports:export:230"},
+	{Method: fiber.MethodPost, Path: "/reports/inventory/export/async", OperationCode: "reports:export:230"},
+	{Method: fiber.MethodPost, Path: "/reports/inventory/sheets", OperationCode: "reports:export:230"},
+	{Method: fiber.MethodGet, Path: "/reports/download/:fileName", OperationCode: "reports:export:230"},
+
+	{Method: fiber.MethodPost, Path: "/assistants/610", OperationCode: "reports:view:610"},
+	{Method: fiber.MethodPost, Path: "/assistants/610/aging", OperationCode: "reports:view:610"},
+	{Method: fiber.MethodPost, Path: "/assistants/610/compare", OperationCode: "reports:view:610"},
+	{Method: fiber.MethodPost, Path: "/reports/assistant610/preview", OperationCode: "reports:view:610"},
+	{Method: fiber.MethodPost, Path: "/assistants/610/export", OperationCode: "reports:export:610"},
+	{Method: fiber.MethodPost, Path: "/assistants/610/export/async", OperationCode: "reports:export:610"},
+	{Method: fiber.MethodPost, Path: "/assistants/610/sheets", OperationCode: "reports:export:610"},
+	{Method: fiber.MethodGet, Path: "/assistants/download/:fileName", OperationCode: "reports:export:610"},
+
+	{Method: fiber.MethodPost, Path: "/assistants/340", OperationCode: "reports:view:340"},
+	{Method: fiber.MethodPost, Path: "/assistants/340/export", OperationCode: "reports:export:340"},
+
+	{Method: fiber.MethodPost, Path: "/reports/reconciliation", OperationCode: "reports:view:reconciliation"},
+	{Method: fiber.MethodPost, Path: "/reports/reconciliation/export", OperationCode: "reports:export:reconciliation"},
+
+	{Method: fiber.MethodGet, Path: "/admin/erp-sync/status", OperationCode: "erp_sync:read"},
+	{Method: fiber.MethodPost, Path: "/admin/erp-sync/run", OperationCode: "erp_sync:run"},
+
+	// Report engine reports, checked by the handler against the operation of their definition,
+	// after the fixed /reports routes above
+	{Method: fiber.MethodPost, Path: "/reports/:code"},
+	{Method: fiber.MethodPost, Path: "/reports/:code/export"},
+	{Method: fiber.MethodPost, Path: "/reports/:code/preview"},
+}
+
+// feedPermissions guards the /feeds routes of BI tools like the /api routes of the same reports
+var feedPermissions = []middleware.RoutePermission{
+	{Method: fiber.MethodGet, Path: "/"},
+	{Method: fiber.MethodGet, Path: "/assistant230", OperationCode: "reports:view:230"},
+	{Method: fiber.MethodGet, Path: "/assistant610", OperationCode: "reports:view:610"},
+}
+
+// directExportRoutes are the /api routes that export a report straight away, with the operations
+// guarding the data they export. They are refused for reports whose exports need approval.
+// The reconciliation holds the rows of both 230 and 610, so it is listed under both. The
+// report engine route comes last, after the fixed /reports routes it would match.
+var directExportRoutes = []middleware.RoutePermission{
+	{Method: fiber.MethodPost, Path: "/reports/inventory/export", OperationCode: "reports:export:230"},
+	{Method: fiber.MethodPost, Path: "/reports/inventory/sheets", OperationCode: "reports:export:230"},
+
+	{Method: fiber.MethodPost, Path: "/assistants/610/export", OperationCode: "reports:export:610"},
+	{Method: fiber.MethodPost, Path: "/assistants/610/sheets", OperationCode: "reports:export:610"},
+
+	{Method: fiber.MethodPost, Path: "/reports/reconciliation/export", OperationCode: "reports:export:230"},
+	{Method: fiber.MethodPost, Path: "/reports/reconciliation/export", OperationCode: "reports:export:610"},
+	{Method: fiber.MethodPost, Path: "/reports/reconciliation/export", OperationCode: "reports:export:reconciliation"},
+
+	{Method: fiber.MethodPost, Path: "/assistants/340/export", OperationCode: "reports:export:340"},
+
+	{Method: fiber.MethodPost, Path: "/reports/items/inventory/export", OperationCode: config.ItemInventoryExportApproval},
+	{Method: fiber.MethodPost, Path: "/reports/stock-balance/export", OperationCode: config.StockBalanceExportApproval},
+
+	{Method: fiber.MethodPost, Path: "/reports/:code/export", OperationCode: config.ReportDefinitionsExportApproval},
+}
@@ -0,0 +1,27 @@
+package app
+
+import (
+	"erp-excel/internal/middleware"
+
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// exportRoutes are the /api routes that query the ERP database to export a report. They are
+// rate limited per user and IP address. Snapshot exports read stored rows and are left out.
+var exportRoutes = []middleware.Route{
+	{Method: fiber.MethodPost, Path: "/reports/inventory/export"},
+	{Method: fiber.MethodPost, Path: "/reports/inventory/export/async"},
+	{Method: fiber.MethodPost, Path: "/reports/inventory/sheets"},
+
+	{Method: fiber.MethodPost, Path: "/assistants/610/export"},
+	{Method: fiber.MethodPost, Path: "/assistants/610/export/async"},
+	{Method: fiber.MethodPost, Path: "/assistants/610/sheets"},
+
+	{Method: fiber.MethodPost, Path: "/assistants/340/export"},
+
+	{Method: fiber.MethodPost, Path: "/reports/items/inventory/export"},
+	{Method: fiber.MethodPost, Path: "/reports/stock-balance/export"},
+	{Method: fiber.MethodPost, Path: "/reports/reconciliation/export"},
+	{Method: fiber.MethodPost, Path: "/reports/:code/export"},
+	{Method: fiber.MethodPost, Path: "/reports/bundle"},
+}
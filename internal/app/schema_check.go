@@ -0,0 +1,66 @@
+package app
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/database"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/service"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// schemaCheckTimeout bounds the catalog queries of a check
+const schemaCheckTimeout = 30 * time.Second
+
+// newSchemaCheckService creates the schema check service for the app and ERP databases
+func newSchemaCheckService(cfg *config.Config, db database.Database) service.SchemaCheckService {
+	return service.NewSchemaCheckService(
+		cfg,
+		repository.NewSchemaRepository(db.DB()),
+		repository.NewSchemaRepository(db.ERPDatabase()),
+	)
+}
+
+// RunSchemaCheck verifies the database schemas, prints the result and returns the process exit code
+func RunSchemaCheck(cfg *config.Config, db database.Database) int {
+	ctx, cancel := context.WithTimeout(context.Background(), schemaCheckTimeout)
+	defer cancel()
+
+	result := newSchemaCheckService(cfg, db).Check(ctx)
+	writeSchemaCheck(os.Stdout, result)
+	if !result.OK {
+		return 1
+	}
+	return 0
+}
+
+// writeSchemaCheck prints one line per check, with what is missing and how to fix it
+func writeSchemaCheck(w io.Writer, result *dto.SchemaCheckResponse) {
+	for _, check := range result.Checks {
+		status := "OK  "
+		if !check.OK {
+			status = "FAIL"
+		}
+		fmt.Fprintf(w, "%s %-4s %s\n", status, check.Database, check.Name)
+		if check.Error != "" {
+			fmt.Fprintf(w, "       error: %s\n", check.Error)
+		}
+		if len(check.Missing) > 0 {
+			fmt.Fprintf(w, "       missing: %s\n", strings.Join(check.Missing, ", "))
+		}
+		if check.Hint != "" {
+			fmt.Fprintf(w, "       hint: %s\n", check.Hint)
+		}
+	}
+
+	if result.OK {
+		fmt.Fprintln(w, "Schema check passed")
+	} else {
+		fmt.Fprintln(w, "Schema check failed")
+	}
+}
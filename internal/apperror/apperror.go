@@ -0,0 +1,160 @@
+// Package apperror holds the errors API clients can branch on. Each carries a stable code, the
+// HTTP status it is answered with and the title of the response; services wrap them with %w to
+// add detail, and the handlers answer them through a single responder.
+package apperror
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Codes of the errors; they are part of the API and never change once released
+const (
+	CodeInternal         = "internal_error"
+	CodeValidation       = "validation_error"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeRequest          = "request_error"
+	CodeNoData           = "no_data"
+	CodeInvalidRange     = "invalid_range"
+	CodePermissionDenied = "permission_denied"
+	CodeExportTooLarge   = "export_too_large"
+	CodeReportTooLarge   = "report_too_large"
+	CodeQueryTimeout     = "query_timeout"
+	CodeQueryCancelled   = "query_cancelled"
+	CodeERPBusy          = "erp_busy"
+)
+
+// StatusClientClosedRequest answers a request whose client went away before the report was
+// ready, as nginx does. The client never reads it, but access logs and metrics do.
+const StatusClientClosedRequest = 499
+
+// Error is an error with a code and an HTTP status
+type Error struct {
+	Code   string
+	Status int
+	Title  string
+
+	// Detail replaces the error text in responses when set, for errors whose text is meant for
+	// the logs rather than the user
+	Detail string
+
+	// RetryAfter tells clients when to try again, answered in the Retry-After header when set
+	RetryAfter time.Duration
+
+	message string
+}
+
+// New creates an error with a code, the status it is answered with, the title of the response
+// and its text
+func New(code string, status int, title, message string) *Error {
+	return &Error{Code: code, Status: status, Title: title, message: message}
+}
+
+// WithDetail sets the Detail of a new error and returns it
+func (e *Error) WithDetail(detail string) *Error {
+	e.Detail = detail
+	return e
+}
+
+// WithRetryAfter sets the RetryAfter of a new error and returns it
+func (e *Error) WithRetryAfter(retryAfter time.Duration) *Error {
+	e.RetryAfter = retryAfter
+	return e
+}
+
+func (e *Error) Error() string {
+	return e.message
+}
+
+// Kinds of errors. Services wrap them for one-off errors and build their own errors of a kind
+// with Validation, NotFound, Conflict and PermissionDenied.
+var (
+	// ErrValidation is returned for a request that is malformed or breaks a rule, answered with 400
+	ErrValidation = Validation("validation failed")
+	// ErrNotFound is returned when a record the request refers to does not exist, answered with 404
+	ErrNotFound = NotFound("not found")
+	// ErrConflict is returned for a request that clashes with the current state of a record,
+	// answered with 409
+	ErrConflict = Conflict("conflict")
+)
+
+// Validation creates an error of a request that is malformed or breaks a rule
+func Validation(message string) *Error {
+	return New(CodeValidation, http.StatusBadRequest, "Validation error", message)
+}
+
+// NotFound creates an error of a record that does not exist
+func NotFound(message string) *Error {
+	return New(CodeNotFound, http.StatusNotFound, "Not found", message)
+}
+
+// Conflict creates an error of a request that clashes with the current state of a record
+func Conflict(message string) *Error {
+	return New(CodeConflict, http.StatusConflict, "Conflict", message)
+}
+
+// PermissionDenied creates an error of an operation the user may not perform
+func PermissionDenied(message string) *Error {
+	return New(CodePermissionDenied, http.StatusForbidden, "Permission denied", message)
+}
+
+// Shared errors of the services
+var (
+	// ErrNoData is returned for a report or export with no rows in the requested range
+	ErrNoData = New(CodeNoData, http.StatusNotFound, "No Data Found", "no data found")
+	// ErrInvalidRange is returned for a date range or period that cannot be resolved or is beyond
+	// the limits of the report
+	ErrInvalidRange = New(CodeInvalidRange, http.StatusBadRequest, "Invalid date range", "invalid date range")
+	// ErrPermissionDenied is returned when the user may not perform an operation
+	ErrPermissionDenied = PermissionDenied("permission denied")
+)
+
+// As classifies err: the first coded error in its chain, or else ErrNotFound for a record the
+// database did not find. It reports false for errors of no known kind, which are internal.
+func As(err error) (*Error, bool) {
+	var coded *Error
+	switch {
+	case errors.As(err, &coded):
+		return coded, true
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound, true
+	}
+	return nil, false
+}
+
+// StatusOf returns the HTTP status err is answered with, 500 for errors without a code
+func StatusOf(err error) int {
+	if coded, ok := As(err); ok {
+		return coded.Status
+	}
+	return http.StatusInternalServerError
+}
+
+// CodeOfStatus returns the code of a response status for errors that only have a status, such as
+// the errors of the HTTP framework
+func CodeOfStatus(status int) string {
+	switch {
+	case status == http.StatusBadRequest:
+		return CodeValidation
+	case status == http.StatusForbidden:
+		return CodePermissionDenied
+	case status == http.StatusNotFound:
+		return CodeNotFound
+	case status == http.StatusConflict:
+		return CodeConflict
+	case status >= http.StatusBadRequest && status < http.StatusInternalServerError:
+		return CodeRequest
+	}
+	return CodeInternal
+}
+
+// CodeOf returns the code of err, CodeInternal for errors without one
+func CodeOf(err error) string {
+	if coded, ok := As(err); ok {
+		return coded.Code
+	}
+	return CodeInternal
+}
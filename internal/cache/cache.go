@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"context"
+	"erp-excel/config"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// Store keeps short-lived values, counters and locks. The memory store serves one instance; the
+// Redis store shares them among every instance behind the load balancer.
+type Store interface {
+	// Get returns the value of a key and whether it is set
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set sets the value of a key for ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes a key
+	Delete(ctx context.Context, key string) error
+	// Increment adds one to the counter of a key and returns the new count and the time left of
+	// its window. The first increment starts a window of ttl, at the end of which the count resets.
+	Increment(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error)
+	// Count returns the counter of a key and the time left of its window, zero when none runs
+	Count(ctx context.Context, key string) (int64, time.Duration, error)
+	// TryLock takes the lock of a key for at most ttl unless someone else holds it. The returned
+	// function releases the lock if it is still held by the caller.
+	TryLock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error)
+	// Shared reports whether the store is shared among instances
+	Shared() bool
+	Close() error
+}
+
+// New creates the store selected by the configuration: Redis when it is enabled, memory otherwise.
+// When Redis cannot be reached the memory store takes over until it can again, so an outage
+// degrades the instances to keeping their own state rather than failing requests.
+func New(cfg config.RedisConfig, logger *slog.Logger) Store {
+	memory := NewMemoryStore()
+	if !cfg.Enabled {
+		return memory
+	}
+
+	redis := NewRedisStore(cfg)
+	if err := redis.Ping(context.Background()); err != nil {
+		logger.Warn("Redis is unreachable, using in-memory state until it is back", "address", redis.address, "error", err)
+	} else {
+		logger.Info("Using Redis for shared state", "address", redis.address)
+	}
+
+	return &fallbackStore{primary: redis, fallback: memory, logger: logger}
+}
+
+// fallbackStore uses the primary store and, when a call to it fails, the fallback store
+type fallbackStore struct {
+	primary  Store
+	fallback Store
+	logger   *slog.Logger
+	warnedAt atomic.Int64 // unix time of the last outage warning
+}
+
+// failed logs an error of the primary store, at most once a minute
+func (s *fallbackStore) failed(ctx context.Context, err error) {
+	now := time.Now().Unix()
+	last := s.warnedAt.Load()
+	if now-last >= 60 && s.warnedAt.CompareAndSwap(last, now) {
+		s.logger.WarnContext(ctx, "Redis call failed, using in-memory state", "error", err)
+	}
+}
+
+func (s *fallbackStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, ok, err := s.primary.Get(ctx, key)
+	if err != nil {
+		s.failed(ctx, err)
+		return s.fallback.Get(ctx, key)
+	}
+	return value, ok, nil
+}
+
+func (s *fallbackStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := s.primary.Set(ctx, key, value, ttl); err != nil {
+		s.failed(ctx, err)
+		return s.fallback.Set(ctx, key, value, ttl)
+	}
+	return nil
+}
+
+func (s *fallbackStore) Delete(ctx context.Context, key string) error {
+	if err := s.primary.Delete(ctx, key); err != nil {
+		s.failed(ctx, err)
+		return s.fallback.Delete(ctx, key)
+	}
+	return nil
+}
+
+func (s *fallbackStore) Increment(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error) {
+	count, left, err := s.primary.Increment(ctx, key, ttl)
+	if err != nil {
+		s.failed(ctx, err)
+		return s.fallback.Increment(ctx, key, ttl)
+	}
+	return count, left, nil
+}
+
+func (s *fallbackStore) Count(ctx context.Context, key string) (int64, time.Duration, error) {
+	count, left, err := s.primary.Count(ctx, key)
+	if err != nil {
+		s.failed(ctx, err)
+		return s.fallback.Count(ctx, key)
+	}
+	return count, left, nil
+}
+
+func (s *fallbackStore) TryLock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	release, ok, err := s.primary.TryLock(ctx, key, ttl)
+	if err != nil {
+		s.failed(ctx, err)
+		return s.fallback.TryLock(ctx, key, ttl)
+	}
+	return release, ok, nil
+}
+
+func (s *fallbackStore) Shared() bool {
+	return s.primary.Shared()
+}
+
+func (s *fallbackStore) Close() error {
+	return s.primary.Close()
+}
@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// memoryEntry is a value or counter with its expiry
+type memoryEntry struct {
+	value     []byte
+	count     int64
+	token     uint64 // holder of a lock
+	expiresAt time.Time
+}
+
+// MemoryStore keeps the values of one instance in memory
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	nextToken uint64
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates an empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]*memoryEntry),
+	}
+}
+
+// entry returns the live entry of a key. The caller holds the lock.
+func (s *MemoryStore) entry(key string, now time.Time) *memoryEntry {
+	s.sweep(now)
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	if !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt) {
+		delete(s.entries, key)
+		return nil
+	}
+	return entry
+}
+
+// sweep drops the expired entries once a minute, so keys nobody reads again do not accumulate.
+// The caller holds the lock.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	for key, entry := range s.entries {
+		if !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entry(key, time.Now())
+	if entry == nil {
+		return nil, false, nil
+	}
+	if entry.value == nil {
+		// A counter reads as its count, as it does in Redis
+		return []byte(strconv.FormatInt(entry.count, 10)), true, nil
+	}
+	return entry.value, true, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := &memoryEntry{value: append([]byte{}, value...)}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	s.entries[key] = entry
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryStore) Increment(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	entry := s.entry(key, now)
+	if entry == nil {
+		entry = &memoryEntry{expiresAt: now.Add(ttl)}
+		s.entries[key] = entry
+	}
+	entry.count++
+	return entry.count, entry.expiresAt.Sub(now), nil
+}
+
+func (s *MemoryStore) Count(ctx context.Context, key string) (int64, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	entry := s.entry(key, now)
+	if entry == nil {
+		return 0, 0, nil
+	}
+	return entry.count, entry.expiresAt.Sub(now), nil
+}
+
+func (s *MemoryStore) TryLock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.entry(key, now) != nil {
+		return nil, false, nil
+	}
+
+	s.nextToken++
+	token := s.nextToken
+	s.entries[key] = &memoryEntry{
+		value:     []byte(strconv.FormatUint(token, 10)),
+		token:     token,
+		expiresAt: now.Add(ttl),
+	}
+
+	release := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if entry, ok := s.entries[key]; ok && entry.token == token {
+			delete(s.entries, key)
+		}
+	}
+	return release, true, nil
+}
+
+func (s *MemoryStore) Shared() bool {
+	return false
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
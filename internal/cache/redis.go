@@ -0,0 +1,269 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"erp-excel/config"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRedisAddress   = "localhost:6379"
+	defaultRedisKeyPrefix = "erp-excel:"
+	defaultRedisPoolSize  = 10
+	defaultRedisTimeout   = 3 * time.Second
+)
+
+// Scripts run atomically on the server, so concurrent instances see consistent counters and locks
+const (
+	incrementScript = `
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then redis.call('PEXPIRE', KEYS[1], ARGV[1]) end
+return {count, redis.call('PTTL', KEYS[1])}`
+
+	countScript = `
+local count = redis.call('GET', KEYS[1])
+if not count then return {0, 0} end
+return {tonumber(count), redis.call('PTTL', KEYS[1])}`
+
+	unlockScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then return redis.call('DEL', KEYS[1]) end
+return 0`
+)
+
+// RedisStore keeps the values in Redis, shared by every instance. It keeps a small pool of
+// connections and speaks RESP itself, which is all the few commands it sends need.
+type RedisStore struct {
+	address   string
+	username  string
+	password  string
+	db        int
+	keyPrefix string
+	timeout   time.Duration
+	tlsConfig *tls.Config // nil without TLS
+
+	idle chan *respConn
+}
+
+// NewRedisStore creates a store for the configured server. Connections are opened on first use.
+func NewRedisStore(cfg config.RedisConfig) *RedisStore {
+	store := &RedisStore{
+		address:   cfg.Address,
+		username:  cfg.Username,
+		password:  cfg.Password,
+		db:        cfg.DB,
+		keyPrefix: cfg.KeyPrefix,
+		timeout:   time.Duration(cfg.TimeoutSeconds) * time.Second,
+	}
+	if store.address == "" {
+		store.address = defaultRedisAddress
+	}
+	if store.keyPrefix == "" {
+		store.keyPrefix = defaultRedisKeyPrefix
+	}
+	if store.timeout <= 0 {
+		store.timeout = defaultRedisTimeout
+	}
+	poolSize := cfg.PoolSize
+	if poolSize <= 0 {
+		poolSize = defaultRedisPoolSize
+	}
+	store.idle = make(chan *respConn, poolSize)
+
+	if cfg.TLS {
+		host, _, err := net.SplitHostPort(store.address)
+		if err != nil {
+			host = store.address
+		}
+		store.tlsConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	}
+
+	return store
+}
+
+// dial opens and authenticates a connection
+func (s *RedisStore) dial(ctx context.Context, deadline time.Time) (*respConn, error) {
+	dialer := &net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return nil, fmt.Errorf("redis: error connecting: %w", err)
+	}
+	if s.tlsConfig != nil {
+		tlsConn := tls.Client(conn, s.tlsConfig)
+		tlsConn.SetDeadline(deadline)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis: tls handshake failed: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	rc := newRESPConn(conn)
+	if s.password != "" {
+		args := []string{"AUTH", s.password}
+		if s.username != "" {
+			args = []string{"AUTH", s.username, s.password}
+		}
+		if _, err := rc.do(deadline, args...); err != nil {
+			rc.close()
+			return nil, fmt.Errorf("redis: authentication failed: %w", err)
+		}
+	}
+	if s.db > 0 {
+		if _, err := rc.do(deadline, "SELECT", strconv.Itoa(s.db)); err != nil {
+			rc.close()
+			return nil, fmt.Errorf("redis: error selecting database %d: %w", s.db, err)
+		}
+	}
+	return rc, nil
+}
+
+// do runs a command on an idle or new connection. A connection is only reused when the command
+// got a reply, error replies included; after a network error its state is unknown.
+func (s *RedisStore) do(ctx context.Context, args ...string) (interface{}, error) {
+	deadline := time.Now().Add(s.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	var conn *respConn
+	select {
+	case conn = <-s.idle:
+	default:
+		var err error
+		if conn, err = s.dial(ctx, deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := conn.do(deadline, args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		conn.close()
+		return nil, err
+	}
+
+	select {
+	case s.idle <- conn:
+	default:
+		conn.close()
+	}
+	return reply, err
+}
+
+// key prefixes a key, so several applications can share a server
+func (s *RedisStore) key(key string) string {
+	return s.keyPrefix + key
+}
+
+// Ping checks the server can be reached
+func (s *RedisStore) Ping(ctx context.Context) error {
+	_, err := s.do(ctx, "PING")
+	return err
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := s.do(ctx, "GET", s.key(key))
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.(string)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected GET reply %T", reply)
+	}
+	return []byte(value), true, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", s.key(key), string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+	_, err := s.do(ctx, args...)
+	return err
+}
+
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	_, err := s.do(ctx, "DEL", s.key(key))
+	return err
+}
+
+func (s *RedisStore) Increment(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error) {
+	reply, err := s.do(ctx, "EVAL", incrementScript, "1", s.key(key), strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	if err != nil {
+		return 0, 0, err
+	}
+	return counterReply(reply)
+}
+
+func (s *RedisStore) Count(ctx context.Context, key string) (int64, time.Duration, error) {
+	reply, err := s.do(ctx, "EVAL", countScript, "1", s.key(key))
+	if err != nil {
+		return 0, 0, err
+	}
+	return counterReply(reply)
+}
+
+// counterReply reads the {count, milliseconds left} reply of the counter scripts
+func counterReply(reply interface{}) (int64, time.Duration, error) {
+	items, ok := reply.([]interface{})
+	if !ok || len(items) != 2 {
+		return 0, 0, fmt.Errorf("redis: unexpected counter reply %v", reply)
+	}
+	count, _ := items[0].(int64)
+	left, _ := items[1].(int64)
+	if left < 0 {
+		// The key has no expiry (-1) or is gone (-2)
+		left = 0
+	}
+	return count, time.Duration(left) * time.Millisecond, nil
+}
+
+func (s *RedisStore) TryLock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, false, fmt.Errorf("error generating lock token: %w", err)
+	}
+	holder := hex.EncodeToString(token)
+
+	reply, err := s.do(ctx, "SET", s.key(key), holder, "NX", "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+
+	// Only the holder may release the lock; once it expired someone else may hold it
+	release := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		defer cancel()
+		s.do(ctx, "EVAL", unlockScript, "1", s.key(key), holder)
+	}
+	return release, true, nil
+}
+
+func (s *RedisStore) Shared() bool {
+	return true
+}
+
+// Close closes the idle connections
+func (s *RedisStore) Close() error {
+	for {
+		select {
+		case conn := <-s.idle:
+			conn.close()
+		default:
+			return nil
+		}
+	}
+}
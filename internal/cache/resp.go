@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// redisError is an error reply of the server, such as a wrong type or a failed script. Unlike
+// network errors it leaves the connection usable.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// errNil is the nil reply, returned for a missing key
+var errNil = errors.New("redis: nil")
+
+// maxBulkLength bounds the bulk strings read, so a corrupt reply cannot allocate without limit
+const maxBulkLength = 512 << 20
+
+// respConn is a connection speaking the Redis serialization protocol (RESP2)
+type respConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+func newRESPConn(conn net.Conn) *respConn {
+	return &respConn{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		writer: bufio.NewWriter(conn),
+	}
+}
+
+// do sends a command and reads its reply before the deadline. The reply is a string, an int64,
+// nil, a []interface{} of those, or a redisError.
+func (c *respConn) do(deadline time.Time, args ...string) (interface{}, error) {
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	// Commands are arrays of bulk strings
+	fmt.Fprintf(c.writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.writer, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	return c.readReply()
+}
+
+// readReply reads one reply
+func (c *respConn) readReply() (interface{}, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid integer reply %q", line)
+		}
+		return n, nil
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil || length > maxBulkLength {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line)
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:length]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid array length %q", line)
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			item, err := c.readReply()
+			// An error inside an array is one of its items, not a failed read
+			var replyErr redisError
+			if errors.As(err, &replyErr) {
+				items[i] = replyErr
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
+
+// readLine reads a line without its CRLF
+func (c *respConn) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("redis: malformed line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
+
+func (c *respConn) close() error {
+	return c.conn.Close()
+}
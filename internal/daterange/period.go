@@ -0,0 +1,110 @@
+// Package daterange resolves the date range of a report request, from a named period such as
+// lastmonth or currentquarter or from explicit dates, and checks it. Quarters and fiscal years
+// start on the configured fiscal start month.
+package daterange
+
+import (
+	"sync"
+	"time"
+)
+
+// Named periods of a report request
+const (
+	Period7Days            = "7days"
+	Period30Days           = "30days"
+	Period3Months          = "3months"
+	PeriodCurrentMonth     = "currentmonth"
+	PeriodLastMonth        = "lastmonth"
+	PeriodCurrentQuarter   = "currentquarter"
+	PeriodLastQuarter      = "lastquarter"
+	PeriodYearToDate       = "yeartodate"
+	PeriodLastYear         = "lastyear"
+	PeriodFiscalYearToDate = "fiscalyeartodate"
+	PeriodLastFiscalYear   = "lastfiscalyear"
+)
+
+// Periods lists the named periods
+var Periods = []string{
+	Period7Days, Period30Days, Period3Months, PeriodCurrentMonth, PeriodLastMonth,
+	PeriodCurrentQuarter, PeriodLastQuarter, PeriodYearToDate, PeriodLastYear,
+	PeriodFiscalYearToDate, PeriodLastFiscalYear,
+}
+
+var (
+	fiscalMu         sync.RWMutex
+	fiscalStartMonth = time.January
+)
+
+// SetFiscalYearStart sets the month fiscal years and quarters start on, 1 for January. Other
+// values keep the calendar year.
+func SetFiscalYearStart(month int) {
+	if month < 1 || month > 12 {
+		month = 1
+	}
+
+	fiscalMu.Lock()
+	defer fiscalMu.Unlock()
+	fiscalStartMonth = time.Month(month)
+}
+
+// FiscalYearStart returns the month fiscal years start on
+func FiscalYearStart() time.Month {
+	fiscalMu.RLock()
+	defer fiscalMu.RUnlock()
+	return fiscalStartMonth
+}
+
+// PeriodRange returns the first and the last instant of a named period as seen at now. Periods
+// running up to today end at the end of today. ok is false for an unknown period.
+func PeriodRange(period string, now time.Time) (time.Time, time.Time, bool) {
+	endOfToday := now.Truncate(24 * time.Hour).Add(24*time.Hour - time.Nanosecond)
+	firstOfMonth := monthStart(now.Year(), now.Month(), now.Location())
+	fiscalYear := fiscalYearStart(now)
+	quarter := quarterStart(now, fiscalYear)
+
+	switch period {
+	case Period7Days:
+		return endOfToday.AddDate(0, 0, -6).Truncate(24 * time.Hour), endOfToday, true
+	case Period30Days:
+		return endOfToday.AddDate(0, 0, -29).Truncate(24 * time.Hour), endOfToday, true
+	case Period3Months:
+		return firstOfMonth.AddDate(0, -2, 0).Truncate(24 * time.Hour), endOfToday, true
+	case PeriodCurrentMonth:
+		return firstOfMonth, endOfToday, true
+	case PeriodLastMonth:
+		return firstOfMonth.AddDate(0, -1, 0), firstOfMonth.Add(-time.Nanosecond), true
+	case PeriodCurrentQuarter:
+		return quarter, endOfToday, true
+	case PeriodLastQuarter:
+		return quarter.AddDate(0, -3, 0), quarter.Add(-time.Nanosecond), true
+	case PeriodYearToDate:
+		return monthStart(now.Year(), time.January, now.Location()), endOfToday, true
+	case PeriodLastYear:
+		firstOfYear := monthStart(now.Year(), time.January, now.Location())
+		return firstOfYear.AddDate(-1, 0, 0), firstOfYear.Add(-time.Nanosecond), true
+	case PeriodFiscalYearToDate:
+		return fiscalYear, endOfToday, true
+	case PeriodLastFiscalYear:
+		return fiscalYear.AddDate(-1, 0, 0), fiscalYear.Add(-time.Nanosecond), true
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// fiscalYearStart returns the first day of the fiscal year now falls in
+func fiscalYearStart(now time.Time) time.Time {
+	start := monthStart(now.Year(), FiscalYearStart(), now.Location())
+	if start.After(now) {
+		start = start.AddDate(-1, 0, 0)
+	}
+	return start
+}
+
+// quarterStart returns the first day of the fiscal quarter now falls in
+func quarterStart(now time.Time, fiscalYear time.Time) time.Time {
+	months := (now.Year()-fiscalYear.Year())*12 + int(now.Month()) - int(fiscalYear.Month())
+	return fiscalYear.AddDate(0, months/3*3, 0)
+}
+
+func monthStart(year int, month time.Month, location *time.Location) time.Time {
+	return time.Date(year, month, 1, 0, 0, 0, 0, location)
+}
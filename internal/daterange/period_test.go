@@ -0,0 +1,142 @@
+package daterange
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func endOfDay(year int, month time.Month, day int) time.Time {
+	return date(year, month, day).Add(24*time.Hour - time.Nanosecond)
+}
+
+// withFiscalYearStart sets the fiscal start month for the test and restores January after it
+func withFiscalYearStart(t *testing.T, month int) {
+	t.Helper()
+	SetFiscalYearStart(month)
+	t.Cleanup(func() { SetFiscalYearStart(1) })
+}
+
+func TestPeriodRange(t *testing.T) {
+	midMarch := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+	newYear := date(2024, time.January, 1)
+	endOfMarch := time.Date(2024, time.March, 31, 23, 59, 59, 0, time.UTC)
+	firstOfApril := date(2024, time.April, 1)
+	endOfMay := time.Date(2024, time.May, 31, 8, 0, 0, 0, time.UTC)
+	newYearsEve := time.Date(2024, time.December, 31, 23, 59, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		fiscal   int
+		period   string
+		now      time.Time
+		wantFrom time.Time
+		wantTo   time.Time
+	}{
+		{"7 days", 1, Period7Days, midMarch, date(2024, time.March, 9), endOfDay(2024, time.March, 15)},
+		{"30 days across a leap february", 1, Period30Days, midMarch, date(2024, time.February, 15), endOfDay(2024, time.March, 15)},
+		{"30 days across the new year", 1, Period30Days, newYear, date(2023, time.December, 3), endOfDay(2024, time.January, 1)},
+		{"3 months", 1, Period3Months, midMarch, date(2024, time.January, 1), endOfDay(2024, time.March, 15)},
+		{"3 months from the last day of a month", 1, Period3Months, endOfMay, date(2024, time.March, 1), endOfDay(2024, time.May, 31)},
+		{"3 months across the new year", 1, Period3Months, newYear, date(2023, time.November, 1), endOfDay(2024, time.January, 1)},
+
+		{"current month", 1, PeriodCurrentMonth, midMarch, date(2024, time.March, 1), endOfDay(2024, time.March, 15)},
+		{"current month on its first day", 1, PeriodCurrentMonth, firstOfApril, date(2024, time.April, 1), endOfDay(2024, time.April, 1)},
+		{"last month ends on the leap day", 1, PeriodLastMonth, endOfMarch, date(2024, time.February, 1), endOfDay(2024, time.February, 29)},
+		{"last month on the first day of a month", 1, PeriodLastMonth, firstOfApril, date(2024, time.March, 1), endOfDay(2024, time.March, 31)},
+		{"last month across the new year", 1, PeriodLastMonth, newYear, date(2023, time.December, 1), endOfDay(2023, time.December, 31)},
+
+		{"current quarter", 1, PeriodCurrentQuarter, midMarch, date(2024, time.January, 1), endOfDay(2024, time.March, 15)},
+		{"current quarter on its last instant", 1, PeriodCurrentQuarter, endOfMarch, date(2024, time.January, 1), endOfDay(2024, time.March, 31)},
+		{"current quarter on its first day", 1, PeriodCurrentQuarter, firstOfApril, date(2024, time.April, 1), endOfDay(2024, time.April, 1)},
+		{"current quarter at the end of the year", 1, PeriodCurrentQuarter, newYearsEve, date(2024, time.October, 1), endOfDay(2024, time.December, 31)},
+		{"last quarter", 1, PeriodLastQuarter, firstOfApril, date(2024, time.January, 1), endOfDay(2024, time.March, 31)},
+		{"last quarter at the end of the year", 1, PeriodLastQuarter, newYearsEve, date(2024, time.July, 1), endOfDay(2024, time.September, 30)},
+		{"last quarter across the new year", 1, PeriodLastQuarter, newYear, date(2023, time.October, 1), endOfDay(2023, time.December, 31)},
+
+		{"year to date", 1, PeriodYearToDate, midMarch, date(2024, time.January, 1), endOfDay(2024, time.March, 15)},
+		{"year to date on new year's day", 1, PeriodYearToDate, newYear, date(2024, time.January, 1), endOfDay(2024, time.January, 1)},
+		{"last year", 1, PeriodLastYear, midMarch, date(2023, time.January, 1), endOfDay(2023, time.December, 31)},
+		{"last year on new year's eve", 1, PeriodLastYear, newYearsEve, date(2023, time.January, 1), endOfDay(2023, time.December, 31)},
+		{"fiscal year to date on the calendar year", 1, PeriodFiscalYearToDate, midMarch, date(2024, time.January, 1), endOfDay(2024, time.March, 15)},
+		{"last fiscal year on the calendar year", 1, PeriodLastFiscalYear, midMarch, date(2023, time.January, 1), endOfDay(2023, time.December, 31)},
+
+		{"fiscal year from april, before its start", 4, PeriodFiscalYearToDate, midMarch, date(2023, time.April, 1), endOfDay(2024, time.March, 15)},
+		{"fiscal year from april, on its first day", 4, PeriodFiscalYearToDate, firstOfApril, date(2024, time.April, 1), endOfDay(2024, time.April, 1)},
+		{"last fiscal year from april, before its start", 4, PeriodLastFiscalYear, midMarch, date(2022, time.April, 1), endOfDay(2023, time.March, 31)},
+		{"last fiscal year from april, on its first day", 4, PeriodLastFiscalYear, firstOfApril, date(2023, time.April, 1), endOfDay(2024, time.March, 31)},
+		{"fiscal quarter from april, spanning the calendar year", 4, PeriodCurrentQuarter, midMarch, date(2024, time.January, 1), endOfDay(2024, time.March, 15)},
+		{"last fiscal quarter from april, spanning the calendar year", 4, PeriodLastQuarter, midMarch, date(2023, time.October, 1), endOfDay(2023, time.December, 31)},
+		{"fiscal quarter from july", 7, PeriodCurrentQuarter, endOfMay, date(2024, time.April, 1), endOfDay(2024, time.May, 31)},
+		{"fiscal quarter from february", 2, PeriodCurrentQuarter, newYear, date(2023, time.November, 1), endOfDay(2024, time.January, 1)},
+		{"last fiscal quarter from february", 2, PeriodLastQuarter, newYear, date(2023, time.August, 1), endOfDay(2023, time.October, 31)},
+		{"year to date ignores the fiscal year", 4, PeriodYearToDate, midMarch, date(2024, time.January, 1), endOfDay(2024, time.March, 15)},
+		{"last year ignores the fiscal year", 4, PeriodLastYear, midMarch, date(2023, time.January, 1), endOfDay(2023, time.December, 31)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withFiscalYearStart(t, tt.fiscal)
+
+			from, to, ok := PeriodRange(tt.period, tt.now)
+			if !ok {
+				t.Fatalf("PeriodRange(%q) is not ok", tt.period)
+			}
+			if !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) {
+				t.Errorf("PeriodRange(%q, %s) = %s, %s, want %s, %s", tt.period, tt.now, from, to, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestPeriodRangeUnknown(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+
+	for _, period := range []string{"", "lastweek", "LastMonth", " lastmonth", "12months"} {
+		t.Run(period, func(t *testing.T) {
+			from, to, ok := PeriodRange(period, now)
+			if ok || !from.IsZero() || !to.IsZero() {
+				t.Errorf("PeriodRange(%q) = %s, %s, %t, want zero times and not ok", period, from, to, ok)
+			}
+		})
+	}
+}
+
+func TestPeriodsAreKnown(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+
+	for _, period := range Periods {
+		from, to, ok := PeriodRange(period, now)
+		if !ok {
+			t.Errorf("PeriodRange(%q) is not ok", period)
+			continue
+		}
+		if from.After(to) || to.After(endOfDay(2024, time.March, 15)) {
+			t.Errorf("PeriodRange(%q) = %s, %s, want an ordered range ending by today", period, from, to)
+		}
+	}
+}
+
+func TestSetFiscalYearStart(t *testing.T) {
+	tests := []struct {
+		month int
+		want  time.Month
+	}{
+		{1, time.January},
+		{4, time.April},
+		{12, time.December},
+		{0, time.January},
+		{13, time.January},
+		{-3, time.January},
+	}
+
+	for _, tt := range tests {
+		withFiscalYearStart(t, tt.month)
+		if got := FiscalYearStart(); got != tt.want {
+			t.Errorf("SetFiscalYearStart(%d) gives %s, want %s", tt.month, got, tt.want)
+		}
+	}
+}
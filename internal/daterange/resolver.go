@@ -0,0 +1,73 @@
+package daterange
+
+import (
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"fmt"
+	"time"
+)
+
+// Resolver resolves the date ranges of report requests. The zero value resolves them against the
+// current time without limiting how far back they go.
+type Resolver struct {
+	// Now returns the time periods are resolved at and future dates are checked against,
+	// time.Now when nil
+	Now func() time.Time
+
+	// MaxMonths is how many months back from today a range may start; 0 does not limit
+	MaxMonths int
+}
+
+// Resolve returns the first and the last instant of the range of a request: the named period when
+// one is given, otherwise the whole days from FromDate to ToDate. The range must not be reversed,
+// end in the future or start more than MaxMonths ago; errors wrap apperror.ErrInvalidRange.
+func (r Resolver) Resolve(request *dto.DateRangeRequest) (time.Time, time.Time, error) {
+	now := r.now()
+	var fromDate, toDate time.Time
+
+	switch {
+	case request.Period != nil && *request.Period != "":
+		var ok bool
+		fromDate, toDate, ok = PeriodRange(*request.Period, now)
+		if !ok {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: unknown period %s", apperror.ErrInvalidRange, *request.Period)
+		}
+	case request.FromDate != nil && request.ToDate != nil && !request.FromDate.IsZero() && !request.ToDate.IsZero():
+		if request.FromDate.Year() < 1900 || request.ToDate.Year() < 1900 {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: from_date and to_date must be after 1900", apperror.ErrInvalidRange)
+		}
+		fromDate = request.FromDate.Truncate(24 * time.Hour)
+		toDate = request.ToDate.Truncate(24 * time.Hour).Add(24*time.Hour - time.Nanosecond)
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from_date and to_date are required if period is not specified", apperror.ErrInvalidRange)
+	}
+
+	if err := r.check(fromDate, toDate, now); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return fromDate, toDate, nil
+}
+
+// check rejects reversed ranges, ranges ending after today and ranges starting before the limit
+func (r Resolver) check(fromDate, toDate, now time.Time) error {
+	if fromDate.After(toDate) {
+		return fmt.Errorf("%w: from date must be before or equal to to date", apperror.ErrInvalidRange)
+	}
+
+	today := now.Truncate(24 * time.Hour)
+	if toDate.After(today.Add(24*time.Hour - time.Nanosecond)) {
+		return fmt.Errorf("%w: to date cannot be in the future", apperror.ErrInvalidRange)
+	}
+
+	if r.MaxMonths > 0 && fromDate.Truncate(24*time.Hour).Before(today.AddDate(0, -r.MaxMonths, 0)) {
+		return fmt.Errorf("%w: date range cannot exceed %d months from current date", apperror.ErrInvalidRange, r.MaxMonths)
+	}
+	return nil
+}
+
+func (r Resolver) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
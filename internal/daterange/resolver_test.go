@@ -0,0 +1,195 @@
+package daterange
+
+import (
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolverResolve(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		maxMonths int
+		request   dto.DateRangeRequest
+		wantFrom  time.Time
+		wantTo    time.Time
+	}{
+		{
+			name:     "named period",
+			request:  dto.DateRangeRequest{Period: ptr(PeriodLastMonth)},
+			wantFrom: date(2024, time.February, 1),
+			wantTo:   endOfDay(2024, time.February, 29),
+		},
+		{
+			name:     "period wins over dates",
+			request:  dto.DateRangeRequest{Period: ptr(PeriodCurrentMonth), FromDate: ptr(date(2023, time.May, 1)), ToDate: ptr(date(2023, time.May, 2))},
+			wantFrom: date(2024, time.March, 1),
+			wantTo:   endOfDay(2024, time.March, 15),
+		},
+		{
+			name:     "empty period falls back to dates",
+			request:  dto.DateRangeRequest{Period: ptr(""), FromDate: ptr(date(2024, time.January, 10)), ToDate: ptr(date(2024, time.January, 20))},
+			wantFrom: date(2024, time.January, 10),
+			wantTo:   endOfDay(2024, time.January, 20),
+		},
+		{
+			name: "dates cover whole days",
+			request: dto.DateRangeRequest{
+				FromDate: ptr(time.Date(2024, time.January, 10, 8, 15, 0, 0, time.UTC)),
+				ToDate:   ptr(time.Date(2024, time.January, 20, 17, 45, 0, 0, time.UTC)),
+			},
+			wantFrom: date(2024, time.January, 10),
+			wantTo:   endOfDay(2024, time.January, 20),
+		},
+		{
+			name:     "single day",
+			request:  dto.DateRangeRequest{FromDate: ptr(date(2024, time.February, 29)), ToDate: ptr(date(2024, time.February, 29))},
+			wantFrom: date(2024, time.February, 29),
+			wantTo:   endOfDay(2024, time.February, 29),
+		},
+		{
+			name:     "up to today",
+			request:  dto.DateRangeRequest{FromDate: ptr(date(2024, time.March, 1)), ToDate: ptr(time.Date(2024, time.March, 15, 23, 0, 0, 0, time.UTC))},
+			wantFrom: date(2024, time.March, 1),
+			wantTo:   endOfDay(2024, time.March, 15),
+		},
+		{
+			name:      "start exactly at the limit",
+			maxMonths: 6,
+			request:   dto.DateRangeRequest{FromDate: ptr(date(2023, time.September, 15)), ToDate: ptr(date(2024, time.March, 15))},
+			wantFrom:  date(2023, time.September, 15),
+			wantTo:    endOfDay(2024, time.March, 15),
+		},
+		{
+			name:      "period within the limit",
+			maxMonths: 6,
+			request:   dto.DateRangeRequest{Period: ptr(PeriodLastQuarter)},
+			wantFrom:  date(2023, time.October, 1),
+			wantTo:    endOfDay(2023, time.December, 31),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := Resolver{Now: func() time.Time { return now }, MaxMonths: tt.maxMonths}
+
+			from, to, err := resolver.Resolve(&tt.request)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) {
+				t.Errorf("Resolve() = %s, %s, want %s, %s", from, to, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestResolverResolveInvalid(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		maxMonths int
+		request   dto.DateRangeRequest
+		wantError string
+	}{
+		{
+			name:      "nothing given",
+			request:   dto.DateRangeRequest{},
+			wantError: "required",
+		},
+		{
+			name:      "only from date",
+			request:   dto.DateRangeRequest{FromDate: ptr(date(2024, time.January, 1))},
+			wantError: "required",
+		},
+		{
+			name:      "only to date",
+			request:   dto.DateRangeRequest{ToDate: ptr(date(2024, time.January, 1))},
+			wantError: "required",
+		},
+		{
+			name:      "zero dates",
+			request:   dto.DateRangeRequest{FromDate: ptr(time.Time{}), ToDate: ptr(time.Time{})},
+			wantError: "required",
+		},
+		{
+			name:      "unknown period",
+			request:   dto.DateRangeRequest{Period: ptr("lastweek")},
+			wantError: "unknown period lastweek",
+		},
+		{
+			name:      "before 1900",
+			request:   dto.DateRangeRequest{FromDate: ptr(date(1899, time.December, 31)), ToDate: ptr(date(2024, time.January, 1))},
+			wantError: "after 1900",
+		},
+		{
+			name:      "reversed",
+			request:   dto.DateRangeRequest{FromDate: ptr(date(2024, time.February, 2)), ToDate: ptr(date(2024, time.February, 1))},
+			wantError: "before or equal",
+		},
+		{
+			name:      "ends tomorrow",
+			request:   dto.DateRangeRequest{FromDate: ptr(date(2024, time.March, 1)), ToDate: ptr(date(2024, time.March, 16))},
+			wantError: "future",
+		},
+		{
+			name:      "entirely in the future",
+			request:   dto.DateRangeRequest{FromDate: ptr(date(2025, time.January, 1)), ToDate: ptr(date(2025, time.January, 31))},
+			wantError: "future",
+		},
+		{
+			name:      "starts a day before the limit",
+			maxMonths: 6,
+			request:   dto.DateRangeRequest{FromDate: ptr(date(2023, time.September, 14)), ToDate: ptr(date(2024, time.March, 15))},
+			wantError: "exceed 6 months",
+		},
+		{
+			name:      "period beyond the limit",
+			maxMonths: 12,
+			request:   dto.DateRangeRequest{Period: ptr(PeriodLastYear)},
+			wantError: "exceed 12 months",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := Resolver{Now: func() time.Time { return now }, MaxMonths: tt.maxMonths}
+
+			from, to, err := resolver.Resolve(&tt.request)
+			if err == nil {
+				t.Fatalf("Resolve() = %s, %s, want an error", from, to)
+			}
+			if !errors.Is(err, apperror.ErrInvalidRange) {
+				t.Errorf("Resolve() error = %v, want it to wrap ErrInvalidRange", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("Resolve() error = %v, want it to mention %q", err, tt.wantError)
+			}
+			if !from.IsZero() || !to.IsZero() {
+				t.Errorf("Resolve() = %s, %s, want zero times with the error", from, to)
+			}
+		})
+	}
+}
+
+func TestResolverZeroValue(t *testing.T) {
+	from, to, err := Resolver{}.Resolve(&dto.DateRangeRequest{Period: ptr(PeriodLastYear)})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	lastYear := time.Now().Year() - 1
+	if from.Year() != lastYear || to.Year() != lastYear {
+		t.Errorf("Resolve() = %s, %s, want last year %d", from, to, lastYear)
+	}
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
@@ -0,0 +1,20 @@
+package dto
+
+import (
+	"erp-excel/internal/models"
+	"time"
+)
+
+// APIKeyRequest creates an API key for a service account
+type APIKeyRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+	// Operation codes the key may use; "*" grants every operation, like an administrator
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,required,max=100"`
+	ExpiresAt *time.Time `json:"expires_at"` // the key never expires when empty
+}
+
+// APIKeyCreatedResponse returns a new API key. Key is only returned here; it cannot be read back.
+type APIKeyCreatedResponse struct {
+	APIKey *models.APIKey `json:"api_key"`
+	Key    string         `json:"key"`
+}
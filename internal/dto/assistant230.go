@@ -2,12 +2,6 @@ package dto
 
 import "time"
 
-type DateRangeRequest struct {
-	FromDate *time.Time `json:"fromDate"`
-	ToDate   *time.Time `json:"toDate"`
-	Period   *string    `json:"period"`
-}
-
 type ReportRequest struct {
 	DepartmentID string           `json:"department_id"`
 	DateRange    DateRangeRequest `json:"date_range"`
@@ -22,17 +16,19 @@ type Asisstant230ReportItem struct {
 	DetailedOrderNumber string `json:"detailed_order_number"` // Detailed order number (mã đơn hàng chi tiết)
 	InvoiceNumber       string `json:"invoice_number"`        // Invoice number (hoa đơn)
 	Notes               string `json:"notes"`                 // Notes (ghi chú)
+	InvoiceStatus       string `json:"invoice_status"`        // invoiced or uninvoiced (trạng thái hóa đơn)
+
+	ConvertedTotal *float64 `json:"converted_total,omitempty"` // local amount in the requested target currency
 }
 
 type ReportDataResponse struct {
-	ReportName  string                   `json:"report_name"`
-	GeneratedAt time.Time                `json:"generated_at"`
-	Items       []Asisstant230ReportItem `json:"items"`
-}
+	ReportName  string      `json:"report_name"`
+	GeneratedAt time.Time   `json:"generated_at"`
+	Items       interface{} `json:"items"` // []Asisstant230ReportItem, or rows of the selected columns
+
+	// Columns lists the selected columns in order, when the request selected any
+	Columns []string `json:"columns,omitempty"`
 
-type ReportFileResponse struct {
-	ReportName  string    `json:"report_name"`
-	FileName    string    `json:"file_name"`    // Name of the file for download
-	FileDetal   any       `json:"filed_detail"` // Detail of the file (e.g., excelize.File)
-	GeneratedAt time.Time `json:"generated_at"`
+	TargetCurrency string   `json:"target_currency,omitempty"`
+	ConvertedTotal *float64 `json:"converted_total,omitempty"` // counted once per sales order
 }
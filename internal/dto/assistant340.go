@@ -0,0 +1,32 @@
+package dto
+
+import "time"
+
+// Assistant340Request filters the purchase receipts report
+type Assistant340Request struct {
+	DateRangeRequest
+	SupplierCode string `json:"supplierCode,omitempty" validate:"omitempty,max=10"`
+	ItemCode     string `json:"itemCode,omitempty" validate:"omitempty,max=40"`
+}
+
+// Assistant340ReportItem is one purchase receipt line with the purchase order line it receives
+type Assistant340ReportItem struct {
+	ReceiptDate     string  `json:"receipt_date"`     // Receipt date, dd/mm/yyyy (ngày nhập hàng)
+	PurchaseReceipt string  `json:"purchase_receipt"` // Receipt type and number (phiếu nhập hàng)
+	SupplierCode    string  `json:"supplier_code"`    // Supplier code (mã nhà cung cấp)
+	SupplierName    string  `json:"supplier_name"`    // Supplier name (nhà cung cấp)
+	PurchaseOrder   string  `json:"purchase_order"`   // Purchase order type, number and line (đơn mua hàng)
+	ItemCode        string  `json:"item_code"`        // Item code (mã vật tư)
+	ItemName        string  `json:"item_name"`        // Item name (tên vật tư)
+	OrderQty        float64 `json:"order_qty"`        // Quantity ordered on the purchase order line
+	ReceivedQty     float64 `json:"received_qty"`     // Quantity received by the receipt line
+	CurrencyType    string  `json:"currency_type"`    // Transaction currency code (nguyên tệ)
+	AmountTrans     float64 `json:"amount_trans"`     // Amount with tax in the transaction currency
+	Amount          float64 `json:"amount"`           // Amount with tax in the local currency
+}
+
+type Assistant340DataResponse struct {
+	ReportName  string                   `json:"report_name"`
+	GeneratedAt time.Time                `json:"generated_at"`
+	Items       []Assistant340ReportItem `json:"items"`
+}
@@ -12,10 +12,44 @@ type Asisstant610ReportItem struct {
 	OrderNo       string `json:"order_no"`        // Detailed order number (mã đơn hàng chi tiết)
 	InvoiceNumber string `json:"invoice_number"`  // Invoice number (hoa đơn)
 	Notes         string `json:"notes"`           // Notes (ghi chú)
+
+	ConvertedTotal *float64 `json:"converted_total,omitempty"` // local amount in the requested target currency
 }
 
 type Assistant610DataResponse struct {
-	ReportName  string                   `json:"report_name"`
-	GeneratedAt time.Time                `json:"generated_at"`
-	Items       []Asisstant610ReportItem `json:"items"`
+	ReportName  string      `json:"report_name"`
+	GeneratedAt time.Time   `json:"generated_at"`
+	Items       interface{} `json:"items"` // []Asisstant610ReportItem, or rows of the selected columns
+
+	// Columns lists the selected columns in order, when the request selected any
+	Columns []string `json:"columns,omitempty"`
+
+	TargetCurrency string   `json:"target_currency,omitempty"`
+	ConvertedTotal *float64 `json:"converted_total,omitempty"` // counted once per AR document
+}
+
+// Aging bucket keys for the 610 receivables aging summary
+const (
+	AgingBucket0To30  = "0-30"
+	AgingBucket31To60 = "31-60"
+	AgingBucket61To90 = "61-90"
+	AgingBucketOver90 = "90+"
+)
+
+// Assistant610AgingBucket totals the receivable documents that fall into one age range
+type Assistant610AgingBucket struct {
+	Bucket        string  `json:"bucket"`
+	DocumentCount int     `json:"document_count"`
+	TotalAmt      float64 `json:"total_amt"`
+	Percent       float64 `json:"percent"`
+}
+
+// Assistant610AgingSummary is the aging view of the 610 report, aged by document date
+type Assistant610AgingSummary struct {
+	ReportName    string                    `json:"report_name"`
+	AsOf          time.Time                 `json:"as_of"`
+	Buckets       []Assistant610AgingBucket `json:"buckets"`
+	DocumentCount int                       `json:"document_count"`
+	TotalAmt      float64                   `json:"total_amt"`
+	GeneratedAt   time.Time                 `json:"generated_at"`
 }
@@ -6,12 +6,29 @@ import "github.com/golang-jwt/jwt/v4"
 type LoginRequest struct {
 	Username string `json:"username" validate:"required"`
 	Password string `json:"password" validate:"required"`
+	Company  string `json:"company,omitempty" validate:"omitempty,max=50"` // ERP company to work on, the default one when empty
+
+	// IPAddress is the address of the client, set by the handler for the login.failed event
+	IPAddress string `json:"-"`
 }
 
 // LoginResponse represents login response with tokens
 type LoginResponse struct {
-	User  *UserResponse `json:"user"`
-	Token string        `json:"token"`
+	User    *UserResponse `json:"user"`
+	Token   string        `json:"token"`
+	Company string        `json:"company"` // the company carried by the token
+}
+
+// SwitchCompanyRequest asks for a token working on another ERP company
+type SwitchCompanyRequest struct {
+	Company string `json:"company" validate:"required,max=50"`
+}
+
+// CompanyResponse is a company of the registry the user may select
+type CompanyResponse struct {
+	Code    string `json:"code"`
+	Name    string `json:"name"`
+	Default bool   `json:"default"`
 }
 
 // TokenClaims represents JWT claims
@@ -19,6 +36,7 @@ type TokenClaims struct {
 	UserID       int    `json:"user_id"`
 	Username     string `json:"username"`
 	DepartmentID int    `json:"department_id"`
-	Exp          int64  `json:"exp,omitempty"` // for compatibility
+	Company      string `json:"company,omitempty"` // ERP company, the default one when empty
+	Exp          int64  `json:"exp,omitempty"`     // for compatibility
 	jwt.RegisteredClaims
 }
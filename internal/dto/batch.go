@@ -0,0 +1,41 @@
+package dto
+
+import "encoding/json"
+
+// MaxBatchOperations is the most sub-requests one batch may contain
+const MaxBatchOperations = 20
+
+// BatchRequest runs several API requests in order in one round trip. A path or body may refer to
+// the response of an earlier operation with {{ref.field.path}}, where ref is the operation ID or
+// index, e.g. "/users/{{newUser.data.id}}/roles".
+type BatchRequest struct {
+	Operations  []BatchOperation `json:"operations" validate:"required,min=1,max=20,dive"`
+	StopOnError bool             `json:"stop_on_error"` // skip the remaining operations after a failure
+}
+
+// BatchOperation is one sub-request of a batch
+type BatchOperation struct {
+	ID     string          `json:"id" validate:"omitempty,max=50,alphanumunicode"`
+	Method string          `json:"method" validate:"required,oneof=GET POST PUT PATCH DELETE"`
+	Path   string          `json:"path" validate:"required,startswith=/"` // relative to /api, e.g. /users
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchResult is the outcome of one sub-request
+type BatchResult struct {
+	Index       int             `json:"index"`
+	ID          string          `json:"id,omitempty"`
+	Status      int             `json:"status"`
+	Skipped     bool            `json:"skipped,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`         // JSON responses only
+	ContentType string          `json:"content_type,omitempty"` // set when the body was not JSON
+	Error       string          `json:"error,omitempty"`
+}
+
+// BatchResponse lists the outcome of every operation in request order
+type BatchResponse struct {
+	Results   []BatchResult `json:"results"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Skipped   int           `json:"skipped"`
+}
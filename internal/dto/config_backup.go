@@ -0,0 +1,59 @@
+package dto
+
+import (
+	"erp-excel/internal/models"
+	"time"
+)
+
+// ConfigBundleVersion is the format version of the configuration bundles written by this build
+const ConfigBundleVersion = 1
+
+// ConfigBundle is a backup of the permission configuration. Entries reference each other by
+// code and name rather than ID, so a bundle can be restored into another instance.
+type ConfigBundle struct {
+	Version           int                        `json:"version"`
+	ExportedAt        time.Time                  `json:"exported_at"`
+	Source            string                     `json:"source,omitempty"` // server name and environment
+	Operations        []ConfigBundleOperation    `json:"operations" validate:"dive"`
+	Departments       []ConfigBundleDepartment   `json:"departments" validate:"dive"`
+	Roles             []ConfigBundleRole         `json:"roles" validate:"dive"`
+	ReportDefinitions []*models.ReportDefinition `json:"report_definitions"`
+}
+
+// ConfigBundleOperation is an operation, identified by its code
+type ConfigBundleOperation struct {
+	Code        string `json:"code" validate:"required"`
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description,omitempty"`
+}
+
+// ConfigBundleDepartment is a department, identified by its code
+type ConfigBundleDepartment struct {
+	Code        string `json:"code" validate:"required"`
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description,omitempty"`
+	IsActive    bool   `json:"is_active"`
+}
+
+// ConfigBundleRole is a role, identified by its name, with the codes of its operations
+type ConfigBundleRole struct {
+	Name        string   `json:"name" validate:"required"`
+	Description string   `json:"description,omitempty"`
+	Parent      string   `json:"parent,omitempty"` // name of the role this role inherits from
+	Operations  []string `json:"operations"`
+}
+
+// ConfigRestoreResponse counts the entries a restore created and updated
+type ConfigRestoreResponse struct {
+	Operations        ConfigRestoreCount `json:"operations" validate:"dive"`
+	Departments       ConfigRestoreCount `json:"departments"`
+	Roles             ConfigRestoreCount `json:"roles"`
+	ReportDefinitions ConfigRestoreCount `json:"report_definitions"`
+	DryRun            bool               `json:"dry_run,omitempty"` // nothing was written
+}
+
+// ConfigRestoreCount counts the restored entries of one kind
+type ConfigRestoreCount struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+}
@@ -0,0 +1,27 @@
+package dto
+
+import "time"
+
+// DashboardSalesTotals sums the ERP sales orders of the dashboard period
+type DashboardSalesTotals struct {
+	SalesAmount          float64 `json:"sales_amount"` // local currency
+	OrderCount           int     `json:"order_count"`
+	UninvoicedOrderCount int     `json:"uninvoiced_order_count"`
+}
+
+// DashboardCustomer is one of the customers with the largest sales in the dashboard period
+type DashboardCustomer struct {
+	CustomerName string  `json:"customer_name"`
+	OrderCount   int     `json:"order_count"`
+	SalesAmount  float64 `json:"sales_amount"`
+}
+
+// DashboardStatistics are the business figures of the dashboard, from the ERP sales orders of
+// the current month. They are cached, so they may be up to the cache lifetime old.
+type DashboardStatistics struct {
+	FromDate time.Time `json:"from_date"`
+	ToDate   time.Time `json:"to_date"`
+	DashboardSalesTotals
+	TopCustomers []DashboardCustomer `json:"top_customers"`
+	GeneratedAt  time.Time           `json:"generated_at"` // when the figures were read from the ERP
+}
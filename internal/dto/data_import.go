@@ -0,0 +1,22 @@
+package dto
+
+import "erp-excel/internal/models"
+
+// DataImportResponse is an import batch with its staged rows: the preview diff of the ERP values
+// and the corrected ones, and the validation errors of each row
+type DataImportResponse struct {
+	Batch *models.ImportBatch `json:"batch"`
+	Rows  []*models.ImportRow `json:"rows"`
+}
+
+// ImportRuleRequest adds a validation rule to an import type
+type ImportRuleRequest struct {
+	ImportType string   `json:"import_type" validate:"required,max=50"`
+	Column     string   `json:"column" validate:"required,max=100"`
+	Type       string   `json:"type" validate:"required,oneof=required pattern range max_length lookup"`
+	Pattern    string   `json:"pattern" validate:"max=500"`
+	Min        *float64 `json:"min"`
+	Max        *float64 `json:"max"`
+	Lookup     string   `json:"lookup" validate:"max=50"`
+	Message    string   `json:"message" validate:"max=255"`
+}
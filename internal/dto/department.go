@@ -1,26 +1,39 @@
 package dto
 
+import "time"
+
 // DepartmentResponse represents department data for API responses
 type DepartmentResponse struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Code        string `json:"code"`
-	Description string `json:"description,omitempty"`
-	IsActive    bool   `json:"is_active"`
-	UserCount   int    `json:"user_count,omitempty"`
+	ID                 int        `json:"id"`
+	Name               string     `json:"name"`
+	Code               string     `json:"code"`
+	Description        string     `json:"description,omitempty"`
+	ParentDepartmentID *int       `json:"parent_department_id,omitempty"`
+	IsActive           bool       `json:"is_active"`
+	UserCount          int        `json:"user_count,omitempty"`
+	TotalUserCount     int        `json:"total_user_count,omitempty"` // users of the department and every department below it
+	DeletedAt          *time.Time `json:"deleted_at,omitempty"`
+}
+
+// DepartmentTreeNode is a department with the departments below it
+type DepartmentTreeNode struct {
+	DepartmentResponse
+	Children []*DepartmentTreeNode `json:"children,omitempty"`
 }
 
 // CreateDepartmentRequest represents request to create a new department
 type CreateDepartmentRequest struct {
-	Name        string `json:"name" validate:"required"`
-	Code        string `json:"code" validate:"required,min=2,max=20"`
-	Description string `json:"description" validate:"omitempty"`
-	IsActive    *bool  `json:"is_active" validate:"omitempty"`
+	Name               string `json:"name" validate:"required"`
+	Code               string `json:"code" validate:"required,min=2,max=20"`
+	Description        string `json:"description" validate:"omitempty"`
+	ParentDepartmentID *int   `json:"parent_department_id" validate:"omitempty,min=1"`
+	IsActive           *bool  `json:"is_active" validate:"omitempty"`
 }
 
 // UpdateDepartmentRequest represents request to update a department
 type UpdateDepartmentRequest struct {
-	Name        string `json:"name" validate:"omitempty"`
-	Description string `json:"description" validate:"omitempty"`
-	IsActive    *bool  `json:"is_active" validate:"omitempty"`
+	Name               string `json:"name" validate:"omitempty"`
+	Description        string `json:"description" validate:"omitempty"`
+	ParentDepartmentID *int   `json:"parent_department_id" validate:"omitempty,min=0"` // 0 removes the parent
+	IsActive           *bool  `json:"is_active" validate:"omitempty"`
 }
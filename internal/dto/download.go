@@ -0,0 +1,54 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DownloadFileResponse describes a generated file in file storage
+type DownloadFileResponse struct {
+	FileName   string    `json:"file_name"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+	AgeHours   int       `json:"age_hours"`
+	Checksum   string    `json:"checksum,omitempty"` // SHA-256, for files recorded with one
+
+	// Known for files generated since exports are recorded
+	Report    string             `json:"report,omitempty"`
+	RowCount  int                `json:"row_count,omitempty"`
+	UserID    int                `json:"user_id,omitempty"`
+	Username  string             `json:"username,omitempty"`
+	AccessLog *DownloadAccessLog `json:"access_log,omitempty"`
+	ExpiresAt *time.Time         `json:"expires_at,omitempty"` // when the retention cleanup deletes it
+
+	// Search parameters of the run that generated the file, when it was logged
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+
+	// Signed link to download the file again, in the user's own export history
+	DownloadURL string `json:"download_url,omitempty"`
+
+	// Set when the recorded file is no longer in file storage
+	Missing bool `json:"missing,omitempty"`
+}
+
+// DownloadAccessLog is the access log entry of the run that generated a file
+type DownloadAccessLog struct {
+	ID         int        `json:"id"`
+	Operation  string     `json:"operation,omitempty"`
+	Status     string     `json:"status,omitempty"`
+	AccessTime *time.Time `json:"access_time,omitempty"`
+}
+
+// DownloadCleanupRequest deletes generated files older than a number of days
+type DownloadCleanupRequest struct {
+	OlderThanDays int  `json:"older_than_days" validate:"required,min=1"`
+	DryRun        bool `json:"dry_run"` // only report what would be deleted
+}
+
+// DownloadCleanupResponse reports the files removed by a cleanup
+type DownloadCleanupResponse struct {
+	DeletedFiles []string `json:"deleted_files"`
+	DeletedCount int      `json:"deleted_count"`
+	FreedBytes   int64    `json:"freed_bytes"`
+	DryRun       bool     `json:"dry_run,omitempty"`
+}
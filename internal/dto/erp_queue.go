@@ -0,0 +1,18 @@
+package dto
+
+// ERPQueueStatus is the state of the queue of the report queries of one company ERP database
+type ERPQueueStatus struct {
+	Company    string `json:"company"`
+	MaxRunning int    `json:"max_running"` // 0 does not limit
+	MaxWaiting int    `json:"max_waiting"`
+	Running    int    `json:"running"`
+	Waiting    int    `json:"waiting"` // the queue depth
+
+	// Counters since the instance started
+	Admitted    int64 `json:"admitted"`     // queries that ran, waiting or not
+	Queued      int64 `json:"queued"`       // queries that had to wait for their turn
+	Rejected    int64 `json:"rejected"`     // queries refused because the queue was full or the wait too long
+	PeakWaiting int   `json:"peak_waiting"` // deepest the queue has been
+	MaxWaitMs   int64 `json:"max_wait_ms"`  // longest wait for a turn
+	AvgQueryMs  int64 `json:"avg_query_ms"` // moving average of how long queries hold their turn
+}
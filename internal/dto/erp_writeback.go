@@ -0,0 +1,35 @@
+package dto
+
+// ERPDocumentKey identifies a sales delivery document (COPTG) in the ERP
+type ERPDocumentKey struct {
+	DocType string `json:"doc_type" validate:"required,max=10"` // TG001
+	DocNo   string `json:"doc_no" validate:"required,max=20"`   // TG002
+}
+
+// ERPWriteBackRequest marks confirmed documents as processed in the ERP
+type ERPWriteBackRequest struct {
+	Documents []ERPDocumentKey `json:"documents" validate:"required,min=1,dive"`
+	Note      string           `json:"note" validate:"max=255"`
+	DryRun    bool             `json:"dry_run"`
+}
+
+// ERPWriteBackItem is the outcome for one document
+type ERPWriteBackItem struct {
+	DocType      string `json:"doc_type"`
+	DocNo        string `json:"doc_no"`
+	PreviousFlag string `json:"previous_flag"`
+	PreviousNote string `json:"previous_note"`
+	NewFlag      string `json:"new_flag,omitempty"`
+	NewNote      string `json:"new_note,omitempty"`
+	Status       string `json:"status"` // previewed, updated, skipped
+	Reason       string `json:"reason,omitempty"`
+}
+
+// ERPWriteBackResponse summarises a write-back batch
+type ERPWriteBackResponse struct {
+	BatchID string             `json:"batch_id"`
+	DryRun  bool               `json:"dry_run"`
+	Updated int                `json:"updated"`
+	Skipped int                `json:"skipped"`
+	Items   []ERPWriteBackItem `json:"items"`
+}
@@ -0,0 +1,11 @@
+package dto
+
+import "time"
+
+// ExchangeRateRequest creates or updates an exchange rate
+type ExchangeRateRequest struct {
+	CurrencyCode  string    `json:"currency_code" validate:"required,len=3,alpha"`
+	Rate          float64   `json:"rate" validate:"gt=0"` // local currency units per unit of the currency
+	EffectiveDate time.Time `json:"effective_date" validate:"required"`
+	Notes         string    `json:"notes" validate:"max=255"`
+}
@@ -0,0 +1,63 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ExportJobResponse is the status of a background export. FileName is set once the job has completed.
+type ExportJobResponse struct {
+	ID         int             `json:"id"`
+	Report     string          `json:"report"`
+	Parameters json.RawMessage `json:"parameters"`
+	Status     string          `json:"status"` // pending_approval, queued, running, completed, failed, rejected
+	FileName   string          `json:"file_name,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+
+	// Set for exports that needed approval: the requester, who decided and their note
+	UserID    int        `json:"user_id,omitempty"`
+	DecidedBy *int       `json:"decided_by,omitempty"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+	Note      string     `json:"note,omitempty"`
+}
+
+// ExportDecisionRequest is an approver's note on approving or rejecting an export
+type ExportDecisionRequest struct {
+	Note string `json:"note" validate:"omitempty,max=500"`
+}
+
+// ReportBundleRequest exports several reports into one ZIP, e.g. Assistant 230 and 610 for the
+// same month. The dates given here apply to the reports that have none of their own.
+type ReportBundleRequest struct {
+	DateRangeRequest
+	Reports []ReportBundleItem `json:"reports" validate:"required,min=1,max=10,dive"`
+
+	// Async queues the bundle as an export job even when it is small enough to be generated
+	// while the request waits
+	Async bool `json:"async,omitempty"`
+}
+
+// Normalize moves the deprecated spellings of the bundle and of its reports to their fields
+func (r *ReportBundleRequest) Normalize() {
+	r.DateRangeRequest.Normalize()
+	for i := range r.Reports {
+		r.Reports[i].Normalize()
+	}
+}
+
+// ReportBundleItem is one report of a bundle with its own export parameters
+type ReportBundleItem struct {
+	Report string `json:"report" validate:"required,oneof=assistant230 assistant610"`
+	DateRangeRequest
+}
+
+// ReportBundleResponse is either the stored ZIP of a bundle generated straight away, or the job
+// generating it in the background
+type ReportBundleResponse struct {
+	FileName    string             `json:"file_name,omitempty"`
+	DownloadURL string             `json:"download_url,omitempty"`
+	Job         *ExportJobResponse `json:"job,omitempty"`
+}
@@ -0,0 +1,9 @@
+package dto
+
+// FeedResponse is an OData v4 style page of report rows, readable by Power BI's OData connector
+type FeedResponse struct {
+	Context  string      `json:"@odata.context"`
+	Count    *int        `json:"@odata.count,omitempty"`
+	Value    interface{} `json:"value"`
+	NextLink string      `json:"@odata.nextLink,omitempty"`
+}
@@ -0,0 +1,8 @@
+package dto
+
+// GraphQLRequest is a GraphQL request as sent over HTTP
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
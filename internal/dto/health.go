@@ -0,0 +1,20 @@
+package dto
+
+import "time"
+
+// HealthResponse reports whether the instance and the databases it depends on are usable
+type HealthResponse struct {
+	Status       string             `json:"status"` // ok or unavailable
+	Name         string             `json:"name"`
+	Env          string             `json:"env"`
+	CheckedAt    time.Time          `json:"checked_at"`
+	Dependencies []DependencyHealth `json:"dependencies"`
+}
+
+// DependencyHealth is the outcome of probing one dependency
+type DependencyHealth struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"` // ok or down
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
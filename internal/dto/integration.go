@@ -0,0 +1,20 @@
+package dto
+
+import "time"
+
+// SheetExportRequest represents a request to push a report into Google Sheets.
+// SpreadsheetID and SheetName pick another configured target than the report's when set.
+type SheetExportRequest struct {
+	DateRangeRequest
+	SpreadsheetID string `json:"spreadsheet_id"`
+	SheetName     string `json:"sheet_name"`
+}
+
+// SheetExportResponse represents the result of a Google Sheets export
+type SheetExportResponse struct {
+	ReportName    string    `json:"report_name"`
+	SpreadsheetID string    `json:"spreadsheet_id"`
+	SheetName     string    `json:"sheet_name"`
+	RowCount      int       `json:"row_count"`
+	ExportedAt    time.Time `json:"exported_at"`
+}
@@ -0,0 +1,29 @@
+package dto
+
+import "time"
+
+// ItemInventoryRequest filters the item-level inventory report
+type ItemInventoryRequest struct {
+	DateRangeRequest
+	ItemCode      string `json:"itemCode,omitempty" validate:"omitempty,max=40"`
+	WarehouseCode string `json:"warehouseCode,omitempty" validate:"omitempty,max=10"`
+}
+
+// ItemInventoryItem is the stock movement of one item in one warehouse over the period
+type ItemInventoryItem struct {
+	ItemCode      string  `json:"item_code"`      // Item code (mã vật tư)
+	ItemName      string  `json:"item_name"`      // Item name (tên vật tư)
+	Unit          string  `json:"unit"`           // Stock unit (đơn vị tính)
+	WarehouseCode string  `json:"warehouse_code"` // Warehouse code (mã kho)
+	WarehouseName string  `json:"warehouse_name"` // Warehouse name (tên kho)
+	OpeningQty    float64 `json:"opening_qty"`    // Balance before the period (tồn đầu kỳ)
+	QtyIn         float64 `json:"qty_in"`         // Quantity received (nhập trong kỳ)
+	QtyOut        float64 `json:"qty_out"`        // Quantity issued (xuất trong kỳ)
+	BalanceQty    float64 `json:"balance_qty"`    // Balance at the end of the period (tồn cuối kỳ)
+}
+
+type ItemInventoryDataResponse struct {
+	ReportName  string              `json:"report_name"`
+	GeneratedAt time.Time           `json:"generated_at"`
+	Items       []ItemInventoryItem `json:"items"`
+}
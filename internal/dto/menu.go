@@ -0,0 +1,9 @@
+package dto
+
+// MenuItem is an entry of the navigation menu. Groups have children and no path.
+type MenuItem struct {
+	Key      string      `json:"key"`
+	Title    string      `json:"title"`
+	Path     string      `json:"path,omitempty"`
+	Children []*MenuItem `json:"children,omitempty"`
+}
@@ -0,0 +1,41 @@
+package dto
+
+import "time"
+
+// Reconciliation statuses of a shipping document against its AR document
+const (
+	ReconciliationMatched           = "matched"
+	ReconciliationShippedUninvoiced = "shipped_uninvoiced"
+	ReconciliationAmountMismatch    = "amount_mismatch"
+)
+
+// ReconciliationRequest selects the period of shipping documents (230) to match against AR documents (610)
+type ReconciliationRequest struct {
+	DateRangeRequest
+	IncludeMatched bool    `json:"includeMatched"`
+	Tolerance      float64 `json:"tolerance" validate:"gte=0"` // allowed local currency difference, defaults to 1
+}
+
+// ReconciliationItem is one shipping document and the AR document it was invoiced on, if any
+type ReconciliationItem struct {
+	ShippingOrder string  `json:"shipping_order"` // Shipping document (mã phiếu xuất)
+	DocDate       string  `json:"doc_date"`       // Shipping date (ngày chứng từ)
+	CustomerName  string  `json:"customer_name"`  // Customer name (tên khách hàng)
+	ShippedAmt    float64 `json:"shipped_amt"`    // Shipped amount in local currency
+	ARDocument    string  `json:"ar_document"`    // AR document (chứng từ công nợ)
+	InvoiceNumber string  `json:"invoice_number"` // Invoice number (hóa đơn)
+	InvoicedAmt   float64 `json:"invoiced_amt"`   // AR document amount in local currency
+	ARShippedAmt  float64 `json:"ar_shipped_amt"` // Total of all shipments invoiced on the AR document
+	Difference    float64 `json:"difference"`     // InvoicedAmt - ARShippedAmt
+	Status        string  `json:"status"`
+}
+
+// ReconciliationResponse lists the reconciliation result with counts per status
+type ReconciliationResponse struct {
+	ReportName     string               `json:"report_name"`
+	GeneratedAt    time.Time            `json:"generated_at"`
+	Matched        int                  `json:"matched"`
+	Uninvoiced     int                  `json:"shipped_uninvoiced"`
+	AmountMismatch int                  `json:"amount_mismatch"`
+	Items          []ReconciliationItem `json:"items"`
+}
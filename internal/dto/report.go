@@ -0,0 +1,101 @@
+package dto
+
+import "time"
+
+// DateRangeRequest selects the period and rows of a report. The JSON fields are snake_case like
+// the responses; the camelCase spellings of the first versions of the API are still read during
+// their deprecation window and moved to the snake_case fields by Normalize.
+type DateRangeRequest struct {
+	FromDate *time.Time `json:"from_date,omitempty"`
+	ToDate   *time.Time `json:"to_date,omitempty"`
+	Period   *string    `json:"period,omitempty"`
+
+	// InvoiceStatus filters the Assistant 230 report, defaults to uninvoiced
+	InvoiceStatus string `json:"invoice_status,omitempty" validate:"omitempty,oneof=uninvoiced invoiced all"`
+
+	// TargetCurrency converts the local amounts of the 230/610 reports, e.g. USD
+	TargetCurrency string `json:"target_currency,omitempty" validate:"omitempty,len=3,alpha"`
+
+	// Filters narrow the rows returned by the 230/610 report data endpoints
+	Filters *ReportFilters `json:"filters,omitempty"`
+
+	// Columns selects the columns of the 230/610 reports and their order, in the JSON data and
+	// the exports. Names are the JSON fields of the report items; empty means every column.
+	Columns []string `json:"columns,omitempty" validate:"omitempty,max=30,dive,max=50"`
+
+	// Deprecated spellings of the fields above
+	LegacyFromDate       *time.Time `json:"fromDate,omitempty" deprecated:"true"`
+	LegacyToDate         *time.Time `json:"toDate,omitempty" deprecated:"true"`
+	LegacyInvoiceStatus  string     `json:"invoiceStatus,omitempty" deprecated:"true"`
+	LegacyTargetCurrency string     `json:"targetCurrency,omitempty" deprecated:"true"`
+}
+
+// Normalize moves the deprecated spellings to their fields, so the request is validated, stored
+// and echoed back in the canonical format. A field given in both spellings keeps the snake_case
+// value.
+func (r *DateRangeRequest) Normalize() {
+	if r.FromDate == nil {
+		r.FromDate = r.LegacyFromDate
+	}
+	if r.ToDate == nil {
+		r.ToDate = r.LegacyToDate
+	}
+	if r.InvoiceStatus == "" {
+		r.InvoiceStatus = r.LegacyInvoiceStatus
+	}
+	if r.TargetCurrency == "" {
+		r.TargetCurrency = r.LegacyTargetCurrency
+	}
+	r.LegacyFromDate, r.LegacyToDate, r.LegacyInvoiceStatus, r.LegacyTargetCurrency = nil, nil, "", ""
+
+	if r.Filters != nil {
+		r.Filters.Normalize()
+	}
+}
+
+// ReportFilters select rows of the 230/610 report data; empty fields do not filter
+type ReportFilters struct {
+	CustomerName  string `json:"customer_name,omitempty" validate:"omitempty,max=100"` // part of the customer name
+	InvoiceNumber string `json:"invoice_number,omitempty" validate:"omitempty,max=50"`
+	CurrencyType  string `json:"currency_type,omitempty" validate:"omitempty,max=10"` // transaction currency, e.g. USD
+	OrderNumber   string `json:"order_number,omitempty" validate:"omitempty,max=50"`  // sales or detailed order number
+
+	// Deprecated spellings of the fields above
+	LegacyCustomerName  string `json:"customerName,omitempty" deprecated:"true"`
+	LegacyInvoiceNumber string `json:"invoiceNumber,omitempty" deprecated:"true"`
+	LegacyCurrencyType  string `json:"currencyType,omitempty" deprecated:"true"`
+	LegacyOrderNumber   string `json:"orderNumber,omitempty" deprecated:"true"`
+}
+
+// Normalize moves the deprecated spellings to their fields, like DateRangeRequest.Normalize
+func (f *ReportFilters) Normalize() {
+	if f.CustomerName == "" {
+		f.CustomerName = f.LegacyCustomerName
+	}
+	if f.InvoiceNumber == "" {
+		f.InvoiceNumber = f.LegacyInvoiceNumber
+	}
+	if f.CurrencyType == "" {
+		f.CurrencyType = f.LegacyCurrencyType
+	}
+	if f.OrderNumber == "" {
+		f.OrderNumber = f.LegacyOrderNumber
+	}
+	f.LegacyCustomerName, f.LegacyInvoiceNumber, f.LegacyCurrencyType, f.LegacyOrderNumber = "", "", "", ""
+}
+
+// Invoice status filters for the Assistant 230 report
+const (
+	InvoiceStatusUninvoiced = "uninvoiced"
+	InvoiceStatusInvoiced   = "invoiced"
+	InvoiceStatusAll        = "all"
+)
+
+type ReportFileResponse struct {
+	ReportName  string    `json:"report_name"`
+	FileName    string    `json:"file_name"`    // Name of the file for download
+	FileDetal   any       `json:"filed_detail"` // Detail of the file (e.g., excelize.File)
+	DownloadURL string    `json:"download_url,omitempty"`
+	Checksum    string    `json:"checksum,omitempty"` // SHA-256 of the file, hex encoded
+	GeneratedAt time.Time `json:"generated_at"`
+}
@@ -0,0 +1,11 @@
+package dto
+
+// NoteImportResponse summarizes an import of edited notes
+type NoteImportResponse struct {
+	Report    string   `json:"report"`
+	RowsRead  int      `json:"rows_read"`
+	Updated   int      `json:"updated"`   // documents whose note was saved
+	Unchanged int      `json:"unchanged"` // documents whose note already matched the report
+	Unmatched []string `json:"unmatched"` // document numbers that are not in the report for the date range
+	Conflicts []string `json:"conflicts"` // documents with different notes on different rows; the first one is kept
+}
@@ -0,0 +1,77 @@
+package dto
+
+import "time"
+
+// ReportComparisonRequest runs a 230/610 report for two date ranges. The embedded range is the
+// current one; its filters, invoice status, currency and columns apply to both runs.
+type ReportComparisonRequest struct {
+	DateRangeRequest
+
+	// The previous range, set like the current one. Empty compares with the range just before
+	// the current one: the same days of the previous months when the current range starts on
+	// the first of a month, otherwise the same number of days.
+	PreviousFromDate *time.Time `json:"previous_from_date,omitempty"`
+	PreviousToDate   *time.Time `json:"previous_to_date,omitempty"`
+	PreviousPeriod   *string    `json:"previous_period,omitempty"`
+
+	// GroupBy is the column the deltas are keyed by, defaults to customer_name
+	GroupBy string `json:"group_by,omitempty" validate:"omitempty,max=50"`
+
+	// Deprecated spellings of the fields above
+	LegacyPreviousFromDate *time.Time `json:"previousFromDate,omitempty" deprecated:"true"`
+	LegacyPreviousToDate   *time.Time `json:"previousToDate,omitempty" deprecated:"true"`
+	LegacyPreviousPeriod   *string    `json:"previousPeriod,omitempty" deprecated:"true"`
+	LegacyGroupBy          string     `json:"groupBy,omitempty" deprecated:"true"`
+}
+
+// Normalize moves the deprecated spellings of both ranges to their fields
+func (r *ReportComparisonRequest) Normalize() {
+	r.DateRangeRequest.Normalize()
+
+	if r.PreviousFromDate == nil {
+		r.PreviousFromDate = r.LegacyPreviousFromDate
+	}
+	if r.PreviousToDate == nil {
+		r.PreviousToDate = r.LegacyPreviousToDate
+	}
+	if r.PreviousPeriod == nil {
+		r.PreviousPeriod = r.LegacyPreviousPeriod
+	}
+	if r.GroupBy == "" {
+		r.GroupBy = r.LegacyGroupBy
+	}
+	r.LegacyPreviousFromDate, r.LegacyPreviousToDate, r.LegacyPreviousPeriod, r.LegacyGroupBy = nil, nil, nil, ""
+}
+
+// ReportComparisonPeriod is the data of one of the compared date ranges
+type ReportComparisonPeriod struct {
+	FromDate      time.Time   `json:"from_date"`
+	ToDate        time.Time   `json:"to_date"`
+	Items         interface{} `json:"items"`
+	DocumentCount int         `json:"document_count"` // sales orders for 230, AR documents for 610
+	TotalAmt      float64     `json:"total_amt"`
+}
+
+// ReportComparisonDelta compares the documents of one group key across the two ranges
+type ReportComparisonDelta struct {
+	Key               string   `json:"key"`
+	CurrentAmt        float64  `json:"current_amt"`
+	PreviousAmt       float64  `json:"previous_amt"`
+	DeltaAmt          float64  `json:"delta_amt"`
+	DeltaPercent      *float64 `json:"delta_percent"` // nil when the previous amount is zero
+	CurrentDocuments  int      `json:"current_documents"`
+	PreviousDocuments int      `json:"previous_documents"`
+}
+
+// ReportComparisonResponse holds both datasets and the deltas per group key, largest change first
+type ReportComparisonResponse struct {
+	ReportName     string                  `json:"report_name"`
+	GeneratedAt    time.Time               `json:"generated_at"`
+	GroupBy        string                  `json:"group_by"`
+	Columns        []string                `json:"columns,omitempty"`
+	TargetCurrency string                  `json:"target_currency,omitempty"` // amounts are converted when set
+	Current        ReportComparisonPeriod  `json:"current"`
+	Previous       ReportComparisonPeriod  `json:"previous"`
+	Total          ReportComparisonDelta   `json:"total"`
+	Deltas         []ReportComparisonDelta `json:"deltas"`
+}
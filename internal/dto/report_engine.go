@@ -0,0 +1,91 @@
+package dto
+
+import "time"
+
+// ReportRunRequest runs a report of the generic report engine. Date parameters are resolved from
+// the date range; other parameters are passed by name in Params.
+type ReportRunRequest struct {
+	DateRangeRequest
+	Params map[string]string `json:"params"`
+}
+
+// ReportParameterInfo describes a parameter the caller has to supply
+type ReportParameterInfo struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+	Default  string `json:"default,omitempty"`
+}
+
+// ReportDefinitionResponse lists a report available to the current user
+type ReportDefinitionResponse struct {
+	Code        string                `json:"code"`
+	Name        string                `json:"name"`
+	Description string                `json:"description,omitempty"`
+	UsesDates   bool                  `json:"uses_dates"`
+	Parameters  []ReportParameterInfo `json:"parameters"`
+	Columns     []string              `json:"columns,omitempty"`
+}
+
+// ReportRunResponse is the data of a generic report run
+type ReportRunResponse struct {
+	Code        string                   `json:"code"`
+	ReportName  string                   `json:"report_name"`
+	GeneratedAt time.Time                `json:"generated_at"`
+	Columns     []string                 `json:"columns"`
+	Items       []map[string]interface{} `json:"items"`
+	RowCount    int                      `json:"row_count"`
+	Truncated   bool                     `json:"truncated"` // the report stopped at its row limit
+}
+
+// PreviewRowLimit is the number of rows returned by the report preview endpoints
+const PreviewRowLimit = 50
+
+// ReportPreviewResponse returns the first rows of a report together with the parameters the
+// request was resolved to, so filters can be checked before running the full report
+type ReportPreviewResponse struct {
+	Type        string                 `json:"type"`
+	ReportName  string                 `json:"report_name"`
+	Parameters  map[string]interface{} `json:"parameters"`
+	Columns     []string               `json:"columns,omitempty"`
+	Items       interface{}            `json:"items"`
+	RowCount    int                    `json:"row_count"`
+	Limit       int                    `json:"limit"`
+	Truncated   bool                   `json:"truncated"` // more rows match than were returned
+	GeneratedAt time.Time              `json:"generated_at"`
+}
+
+// ReportDefinitionRequest registers or replaces an admin-defined SQL report. The query is a
+// read-only SELECT (or WITH ... SELECT) template using @Name placeholders for its parameters.
+type ReportDefinitionRequest struct {
+	Code           string                   `json:"code" validate:"omitempty,max=50"` // ignored on update
+	Name           string                   `json:"name" validate:"required,max=255"`
+	Description    string                   `json:"description" validate:"max=1000"`
+	Query          string                   `json:"query" validate:"required"`
+	OperationCode  string                   `json:"operation_code" validate:"max=100"` // defaults to report_<code>
+	Parameters     []ReportParameterRequest `json:"parameters" validate:"dive"`
+	Columns        []ReportColumnRequest    `json:"columns" validate:"dive"`
+	TimeoutSeconds int                      `json:"timeout_seconds" validate:"min=0"`
+	MaxRows        int                      `json:"max_rows" validate:"min=0"`
+	IsActive       *bool                    `json:"is_active"`
+
+	// Placeholders: {name} {period} {from} {to} {department} {user} {yyyy} {MM} {dd}, plus {title} in file names
+	TitleTemplate    string `json:"title_template" validate:"max=255"`
+	FileNameTemplate string `json:"file_name_template" validate:"max=255"`
+}
+
+// ReportParameterRequest declares a query parameter
+type ReportParameterRequest struct {
+	Name     string `json:"name" validate:"required"`
+	Source   string `json:"source" validate:"omitempty,oneof=from_date to_date department_id department_ids user_id"`
+	Type     string `json:"type" validate:"omitempty,oneof=string int date"`
+	Required bool   `json:"required"`
+	Default  string `json:"default"`
+}
+
+// ReportColumnRequest maps a result set column to a report column key
+type ReportColumnRequest struct {
+	Key   string `json:"key" validate:"required"`
+	Field string `json:"field" validate:"required"`
+	Type  string `json:"type" validate:"omitempty,oneof=string number date currency"` // Excel cell type
+}
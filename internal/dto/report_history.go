@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ReportHistoryEntry is one of the user's recent report runs
+type ReportHistoryEntry struct {
+	ID        int             `json:"id"` // access log ID
+	Report    string          `json:"report"`
+	Operation string          `json:"operation"`
+	Status    string          `json:"status"`
+	RunAt     time.Time       `json:"run_at"`
+	Favorite  bool            `json:"favorite"`
+	RunAgain  *ReportRunAgain `json:"run_again"`
+}
+
+// ReportRunAgain repeats a run: Parameters is the body to post to the report's data or export
+// endpoint. A period in the parameters is resolved again, so "lastmonth" runs the new last month.
+type ReportRunAgain struct {
+	Report     string          `json:"report"`
+	Parameters json.RawMessage `json:"parameters"`
+}
+
+// ReportFavoriteResponse tells whether a report is starred
+type ReportFavoriteResponse struct {
+	Report    string     `json:"report"`
+	Favorite  bool       `json:"favorite"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
@@ -0,0 +1,28 @@
+package dto
+
+import "time"
+
+// Sources of a report limit
+const (
+	ReportLimitSourceDefault = "default" // the global limits
+	ReportLimitSourceConfig  = "config"
+	ReportLimitSourceCustom  = "custom" // set through the API
+)
+
+// ReportLimitRequest sets the guardrails of a report; 0 uses the default and -1 removes the limit
+type ReportLimitRequest struct {
+	MaxMonths   int `json:"max_months" validate:"min=-1,max=120"`
+	MaxRows     int `json:"max_rows" validate:"min=-1"`
+	MaxExportMB int `json:"max_export_mb" validate:"min=-1,max=1024"`
+}
+
+// ReportLimitResponse is the effective guardrails of a report; 0 does not limit
+type ReportLimitResponse struct {
+	Report      string     `json:"report"`
+	MaxMonths   int        `json:"max_months"`
+	MaxRows     int        `json:"max_rows"`
+	MaxExportMB int        `json:"max_export_mb"`
+	Source      string     `json:"source"` // default, config or custom
+	UpdatedBy   int        `json:"updated_by,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
@@ -0,0 +1,8 @@
+package dto
+
+// ReportPageRequest asks for one sorted page of a report's rows
+type ReportPageRequest struct {
+	Page  int    // 1-based page number
+	Limit int    // rows per page, 0 for every row
+	Sort  string // sort key of the report, prefixed with - for descending order
+}
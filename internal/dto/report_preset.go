@@ -0,0 +1,23 @@
+package dto
+
+import "time"
+
+// ReportPresetRequest creates or replaces a saved preset. The date range, filters, columns and
+// report engine parameters are stored as given; a period is resolved each time the preset is run.
+type ReportPresetRequest struct {
+	Name   string `json:"name" validate:"required,max=100"`
+	Report string `json:"report" validate:"required,max=50"` // assistant230, assistant610 or a report engine code
+	DateRangeRequest
+	Params map[string]string `json:"params"` // parameters of report engine reports
+}
+
+// ReportPresetResponse is a saved preset. Parameters is the body to post to the report's data
+// or export endpoint.
+type ReportPresetResponse struct {
+	ID         int              `json:"id"`
+	Name       string           `json:"name"`
+	Report     string           `json:"report"`
+	Parameters ReportRunRequest `json:"parameters"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
@@ -0,0 +1,44 @@
+package dto
+
+import "time"
+
+// ReportScheduleRequest creates or replaces a report schedule. Time is HH:MM in server time and is
+// used with the daily, weekly and monthly frequencies; cron takes a five-field cron expression.
+// The report is emailed to the recipients and pushed to the Google Sheet when SpreadsheetID or
+// SheetName pick a configured target; at least one of them is required.
+type ReportScheduleRequest struct {
+	Name       string   `json:"name" validate:"required,max=100"`
+	Report     string   `json:"report" validate:"required,oneof=assistant230 assistant610"`
+	Period     string   `json:"period" validate:"required,oneof=7days 30days 3months currentmonth lastmonth currentquarter lastquarter yeartodate lastyear fiscalyeartodate lastfiscalyear"`
+	Frequency  string   `json:"frequency" validate:"required,oneof=daily weekly monthly cron"`
+	Time       string   `json:"time" validate:"omitempty,datetime=15:04"`
+	Weekday    int      `json:"weekday" validate:"min=0,max=6"`       // weekly: 0 is Sunday
+	DayOfMonth int      `json:"day_of_month" validate:"min=0,max=28"` // monthly, defaults to the 1st
+	Cron       string   `json:"cron" validate:"max=100"`
+	Recipients []string `json:"recipients" validate:"max=20,dive,email"`
+	IsActive   *bool    `json:"is_active"`
+
+	SpreadsheetID string `json:"spreadsheet_id" validate:"max=100"`
+	SheetName     string `json:"sheet_name" validate:"max=100"`
+}
+
+// ReportScheduleResponse is a schedule with its recipients
+type ReportScheduleResponse struct {
+	ID            int        `json:"id"`
+	Name          string     `json:"name"`
+	Report        string     `json:"report"`
+	Period        string     `json:"period"`
+	Frequency     string     `json:"frequency"`
+	Cron          string     `json:"cron"`
+	Recipients    []string   `json:"recipients"`
+	SpreadsheetID string     `json:"spreadsheet_id,omitempty"`
+	SheetName     string     `json:"sheet_name,omitempty"`
+	IsActive      bool       `json:"is_active"`
+	CreatedBy     int        `json:"created_by"`
+	DepartmentID  int        `json:"department_id"`
+	Company       string     `json:"company,omitempty"`
+	NextRunAt     *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt     *time.Time `json:"last_run_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
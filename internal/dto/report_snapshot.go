@@ -0,0 +1,36 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ReportSnapshotRequest runs a report and stores its full result as a snapshot
+type ReportSnapshotRequest struct {
+	Report string `json:"report" validate:"required,max=50"` // assistant230, assistant610 or a report engine code
+	DateRangeRequest
+	Params map[string]string `json:"params"` // parameters of report engine reports
+	Notes  string            `json:"notes" validate:"max=500"`
+}
+
+// ReportSnapshotSummary lists a snapshot without its rows
+type ReportSnapshotSummary struct {
+	ID         int             `json:"id"`
+	Report     string          `json:"report"`
+	ReportName string          `json:"report_name"`
+	Parameters json.RawMessage `json:"parameters"`
+	RowCount   int             `json:"row_count"`
+	Checksum   string          `json:"checksum"`
+	Notes      string          `json:"notes,omitempty"`
+	CreatedBy  int             `json:"created_by"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// ReportSnapshotResponse is a snapshot with its rows. ChecksumValid is false when the stored data
+// no longer matches the checksum taken when the snapshot was created.
+type ReportSnapshotResponse struct {
+	ReportSnapshotSummary
+	ChecksumValid bool                     `json:"checksum_valid"`
+	Columns       []string                 `json:"columns"`
+	Items         []map[string]interface{} `json:"items"`
+}
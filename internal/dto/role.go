@@ -4,19 +4,22 @@ import "time"
 
 // RoleResponse represents role data for API responses
 type RoleResponse struct {
-	ID           int       `json:"id"`
-	Name         string    `json:"name"`
-	Description  string    `json:"description,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	OperationIDs []int     `json:"operation_ids,omitempty"`
-	UserCount    int       `json:"user_count,omitempty"`
+	ID           int        `json:"id"`
+	Name         string     `json:"name"`
+	Description  string     `json:"description,omitempty"`
+	ParentRoleID *int       `json:"parent_role_id,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	OperationIDs []int      `json:"operation_ids,omitempty"`
+	UserCount    int        `json:"user_count,omitempty"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
 }
 
 // CreateRoleRequest represents request to create a new role
 type CreateRoleRequest struct {
 	Name         string `json:"name" validate:"required"`
 	Description  string `json:"description" validate:"omitempty"`
+	ParentRoleID *int   `json:"parent_role_id" validate:"omitempty,min=1"`
 	OperationIDs []int  `json:"operation_ids" validate:"omitempty,dive,min=1"`
 }
 
@@ -24,9 +27,22 @@ type CreateRoleRequest struct {
 type UpdateRoleRequest struct {
 	Name         string `json:"name" validate:"omitempty"`
 	Description  string `json:"description" validate:"omitempty"`
+	ParentRoleID *int   `json:"parent_role_id" validate:"omitempty,min=0"` // 0 removes the parent
 	OperationIDs []int  `json:"operation_ids" validate:"omitempty,dive,min=1"`
 }
 
+// CloneRoleRequest represents request to copy a role with its parent and operations
+type CloneRoleRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description" validate:"omitempty"` // defaults to the source role's
+}
+
+// GrantOperationRequest represents request to add one operation to several roles
+type GrantOperationRequest struct {
+	OperationID int   `json:"operation_id" validate:"required,min=1"`
+	RoleIDs     []int `json:"role_ids" validate:"required,min=1,dive,min=1"`
+}
+
 // OperationResponse represents operation data for API responses
 type OperationResponse struct {
 	ID          int    `json:"id"`
@@ -34,3 +50,10 @@ type OperationResponse struct {
 	Code        string `json:"code"`
 	Description string `json:"description,omitempty"`
 }
+
+// PermissionResponse tells the frontend whether the current user may perform an operation
+type PermissionResponse struct {
+	Code    string `json:"code"`
+	Name    string `json:"name"`
+	Allowed bool   `json:"allowed"`
+}
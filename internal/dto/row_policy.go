@@ -0,0 +1,15 @@
+package dto
+
+// RowPolicyRequest creates or updates a row policy
+type RowPolicyRequest struct {
+	Name          string `json:"name" validate:"required,max=100"`
+	Description   string `json:"description" validate:"max=500"`
+	OperationCode string `json:"operation_code" validate:"required,max=100"`
+	RoleID        *int   `json:"role_id"` // the policy applies to every user without one
+	Attribute     string `json:"attribute" validate:"required,max=50"`
+	Operator      string `json:"operator" validate:"required,oneof=in not_in"`
+	// Values are literals or the placeholders {username}, {user_id}, {department_id} and
+	// {department_code} of the user running the report
+	Values   []string `json:"values" validate:"required,min=1,max=1000,dive,required,max=200"`
+	IsActive *bool    `json:"is_active"` // active unless false
+}
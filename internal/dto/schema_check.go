@@ -0,0 +1,20 @@
+package dto
+
+import "time"
+
+// SchemaCheckResponse reports whether the app and ERP databases have what the reports need
+type SchemaCheckResponse struct {
+	OK        bool                `json:"ok"`
+	CheckedAt time.Time           `json:"checked_at"`
+	Checks    []SchemaCheckResult `json:"checks"`
+}
+
+// SchemaCheckResult is the outcome of one check
+type SchemaCheckResult struct {
+	Database string   `json:"database"` // app or erp
+	Name     string   `json:"name"`     // report or component that needs the objects
+	OK       bool     `json:"ok"`
+	Missing  []string `json:"missing,omitempty"` // TABLE or TABLE.COLUMN, or the procedure name
+	Error    string   `json:"error,omitempty"`
+	Hint     string   `json:"hint,omitempty"` // what to do about it
+}
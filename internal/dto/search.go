@@ -0,0 +1,28 @@
+package dto
+
+// Admin search result types
+const (
+	SearchTypeUser       = "user"
+	SearchTypeDepartment = "department"
+	SearchTypeRole       = "role"
+)
+
+// SearchResult is one match of the admin quick search
+type SearchResult struct {
+	ID       int    `json:"id"`
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle,omitempty"`
+	Inactive bool   `json:"inactive,omitempty"`
+}
+
+// SearchResultGroup holds the matches of one entity type
+type SearchResultGroup struct {
+	Type  string          `json:"type"`
+	Items []*SearchResult `json:"items"`
+}
+
+// SearchResponse groups admin quick search matches by entity type
+type SearchResponse struct {
+	Query  string               `json:"query"`
+	Groups []*SearchResultGroup `json:"groups"`
+}
@@ -0,0 +1,16 @@
+package dto
+
+import "time"
+
+// SlowQuery is an ERP query that ran longer than the slow query threshold
+type SlowQuery struct {
+	Company    string    `json:"company"`
+	Report     string    `json:"report"` // "other" for queries run outside a report
+	Query      string    `json:"query"`
+	Parameters []string  `json:"parameters"` // sanitized, such as @fromDate="2024-01-01"
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Rows       int64     `json:"rows"`
+	Error      string    `json:"error,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+}
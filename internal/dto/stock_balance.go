@@ -0,0 +1,27 @@
+package dto
+
+import "time"
+
+// StockBalanceRequest filters the on-hand stock report
+type StockBalanceRequest struct {
+	AsOfDate      *time.Time `json:"asOfDate,omitempty"` // stock at the end of this day, defaults to today
+	ItemCode      string     `json:"itemCode,omitempty" validate:"omitempty,max=40"`
+	WarehouseCode string     `json:"warehouseCode,omitempty" validate:"omitempty,max=10"`
+}
+
+// StockBalanceItem is the on-hand quantity of one item in one warehouse
+type StockBalanceItem struct {
+	ItemCode      string  `json:"item_code"`      // Item code (mã vật tư)
+	ItemName      string  `json:"item_name"`      // Item name (tên vật tư)
+	Unit          string  `json:"unit"`           // Stock unit (đơn vị tính)
+	WarehouseCode string  `json:"warehouse_code"` // Warehouse code (mã kho)
+	WarehouseName string  `json:"warehouse_name"` // Warehouse name (tên kho)
+	OnHandQty     float64 `json:"on_hand_qty"`    // Quantity in stock at the end of the as-of date (tồn kho)
+}
+
+type StockBalanceDataResponse struct {
+	ReportName  string             `json:"report_name"`
+	GeneratedAt time.Time          `json:"generated_at"`
+	AsOf        time.Time          `json:"as_of"`
+	Items       []StockBalanceItem `json:"items"`
+}
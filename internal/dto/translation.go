@@ -0,0 +1,25 @@
+package dto
+
+import "time"
+
+// Translation label sources
+const (
+	TranslationSourceBuiltIn = "builtin"
+	TranslationSourceCustom  = "custom"
+)
+
+// TranslationLabelRequest sets the label of a translation key
+type TranslationLabelRequest struct {
+	Label string `json:"label" validate:"required,max=255"`
+}
+
+// TranslationLabelResponse is the effective label of a key in a locale
+type TranslationLabelResponse struct {
+	Locale    string     `json:"locale"`
+	Key       string     `json:"key"`
+	Label     string     `json:"label"`
+	Source    string     `json:"source"`                  // builtin or custom
+	BuiltIn   string     `json:"builtin_label,omitempty"` // shipped label, when a custom one overrides it
+	UpdatedBy int        `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
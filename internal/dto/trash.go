@@ -0,0 +1,8 @@
+package dto
+
+// TrashResponse lists the soft deleted records an administrator can restore
+type TrashResponse struct {
+	Users       []*UserResponse       `json:"users"`
+	Roles       []*RoleResponse       `json:"roles"`
+	Departments []*DepartmentResponse `json:"departments"`
+}
@@ -4,17 +4,19 @@ import "time"
 
 // UserResponse represents user data for API responses
 type UserResponse struct {
-	ID           int       `json:"id"`
-	Username     string    `json:"username"`
-	FullName     string    `json:"full_name"`
-	Email        string    `json:"email"`
-	DepartmentID int       `json:"department_id"`
-	Department   string    `json:"department,omitempty"`
-	IsActive     bool      `json:"is_active"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	LastLogin    time.Time `json:"last_login,omitempty"`
-	Roles        []string  `json:"roles,omitempty"`
+	ID           int        `json:"id"`
+	Username     string     `json:"username"`
+	FullName     string     `json:"full_name"`
+	Email        string     `json:"email"`
+	Phone        string     `json:"phone,omitempty"`
+	DepartmentID int        `json:"department_id"`
+	Department   string     `json:"department,omitempty"`
+	IsActive     bool       `json:"is_active"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	LastLogin    time.Time  `json:"last_login,omitempty"`
+	Roles        []string   `json:"roles,omitempty"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
 }
 
 // CreateUserRequest represents request to create a new user
@@ -36,6 +38,14 @@ type UpdateUserRequest struct {
 	UpdatedAt    time.Time `json:"updated_at" validate:"omitempty"`
 }
 
+// UpdateProfileRequest is a user's own change of their contact details. Department and roles
+// can only be changed by an administrator.
+type UpdateProfileRequest struct {
+	FullName string `json:"full_name" validate:"required,max=100"`
+	Email    string `json:"email" validate:"omitempty,email,max=200"` // empty clears it
+	Phone    string `json:"phone" validate:"omitempty,max=50"`        // empty clears it
+}
+
 type UpdatePasswordRequest struct {
 	CurrentPassword string `json:"current_password" validate:"required"`
 	NewPassword     string `json:"new_password" validate:"required,min=6"`
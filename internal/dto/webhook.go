@@ -0,0 +1,20 @@
+package dto
+
+import "erp-excel/internal/models"
+
+// WebhookRequest creates or updates a webhook
+type WebhookRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+	URL  string `json:"url" validate:"required,url,max=1000"`
+	// Secret signs the deliveries; a secret is generated when creating a webhook without one, and
+	// an update without one keeps the current secret
+	Secret   string   `json:"secret" validate:"omitempty,min=16,max=200"`
+	Events   []string `json:"events" validate:"required,min=1,dive,required"`
+	IsActive *bool    `json:"is_active"` // active unless false
+}
+
+// WebhookCreatedResponse returns a new webhook with its secret, which cannot be read back
+type WebhookCreatedResponse struct {
+	Webhook *models.Webhook `json:"webhook"`
+	Secret  string          `json:"secret"`
+}
@@ -0,0 +1,93 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"erp-excel/config"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type kafkaPublisher struct {
+	config     config.KafkaConfig
+	httpClient *http.Client
+}
+
+// NewKafkaPublisher creates a publisher that produces to Kafka through a Kafka REST Proxy (v2 API),
+// keyed by event type so events of one type stay ordered within a partition
+func NewKafkaPublisher(cfg config.KafkaConfig) (Publisher, error) {
+	if cfg.RestProxyURL == "" {
+		return nil, errors.New("kafka rest proxy url is required")
+	}
+	if cfg.Topic == "" {
+		cfg.Topic = "kanban.events"
+	}
+
+	return &kafkaPublisher{
+		config:     cfg,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Publish produces a single record to the configured topic
+func (p *kafkaPublisher) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"records": []map[string]interface{}{
+			{"key": event.Type, "value": event},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error marshalling event: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/topics/%s", strings.TrimRight(p.config.RestProxyURL, "/"), url.PathEscape(p.config.Topic))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating kafka request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if p.config.Username != "" {
+		req.SetBasicAuth(p.config.Username, p.config.Password)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error publishing event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("error publishing event: %s: %s", resp.Status, msg)
+	}
+
+	// The proxy answers 200 even when a record fails, the error is reported per offset
+	var result struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("error decoding kafka response: %w", err)
+	}
+	for _, offset := range result.Offsets {
+		if offset.ErrorCode != nil {
+			return fmt.Errorf("error publishing event: %s", offset.Error)
+		}
+	}
+
+	return nil
+}
+
+// Close is a no-op, the REST proxy holds no connection state
+func (p *kafkaPublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,106 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"erp-excel/config"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Domain event types
+const (
+	ExportCompleted         = "export.completed"
+	ExportApprovalRequested = "export.approval_requested"
+	ExportApproved          = "export.approved"
+	ExportRejected          = "export.rejected"
+	UserCreated             = "user.created"
+	LoginFailed             = "login.failed"
+)
+
+// Types lists the event types, which webhooks subscribe to
+var Types = []string{
+	ExportCompleted,
+	ExportApprovalRequested,
+	ExportApproved,
+	ExportRejected,
+	UserCreated,
+	LoginFailed,
+}
+
+// Event is the envelope sent to the message queue
+type Event struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Publisher delivers events to a message broker
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+	Close() error
+}
+
+// NewPublisher creates the publisher for the configured driver
+func NewPublisher(cfg config.EventsConfig) (Publisher, error) {
+	switch cfg.Driver {
+	case "", "log":
+		return &logPublisher{}, nil
+	case "rabbitmq":
+		return NewRabbitMQPublisher(cfg.RabbitMQ)
+	case "kafka":
+		return NewKafkaPublisher(cfg.Kafka)
+	default:
+		return nil, fmt.Errorf("unknown events driver %q", cfg.Driver)
+	}
+}
+
+// logPublisher writes events to the application log, useful in development
+type logPublisher struct{}
+
+func (p *logPublisher) Publish(ctx context.Context, event Event) error {
+	slog.InfoContext(ctx, "Event", "id", event.ID, "type", event.Type, "data", string(event.Data))
+	return nil
+}
+
+func (p *logPublisher) Close() error {
+	return nil
+}
+
+// ExportCompletedData is the payload of export.completed
+type ExportCompletedData struct {
+	Report       string `json:"report"`
+	FileName     string `json:"file_name"`
+	RowCount     int    `json:"row_count"`
+	UserID       int    `json:"user_id"`
+	DepartmentID int    `json:"department_id"`
+	DownloadURL  string `json:"download_url,omitempty"`
+}
+
+// UserCreatedData is the payload of user.created
+type UserCreatedData struct {
+	UserID       int    `json:"user_id"`
+	Username     string `json:"username"`
+	DepartmentID int    `json:"department_id"`
+	Source       string `json:"source"` // admin or saml
+}
+
+// LoginFailedData is the payload of login.failed
+type LoginFailedData struct {
+	Username  string `json:"username"`
+	Reason    string `json:"reason"`
+	IPAddress string `json:"ip_address,omitempty"`
+}
+
+// ExportApprovalData is the payload of export.approval_requested, export.approved and
+// export.rejected
+type ExportApprovalData struct {
+	JobID        int    `json:"job_id"`
+	Report       string `json:"report"`
+	UserID       int    `json:"user_id"`
+	DepartmentID int    `json:"department_id"`
+	DecidedBy    int    `json:"decided_by,omitempty"`
+	Note         string `json:"note,omitempty"`
+}
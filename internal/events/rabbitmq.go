@@ -0,0 +1,131 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"erp-excel/config"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type rabbitMQPublisher struct {
+	config config.RabbitMQConfig
+
+	mu      sync.Mutex
+	conn    *amqp.Connection
+	channel *amqp.Channel
+}
+
+// NewRabbitMQPublisher creates a publisher that sends events to a topic exchange, routed by event type
+func NewRabbitMQPublisher(cfg config.RabbitMQConfig) (Publisher, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("rabbitmq url is required")
+	}
+	if cfg.Exchange == "" {
+		cfg.Exchange = "kanban.events"
+	}
+
+	return &rabbitMQPublisher{config: cfg}, nil
+}
+
+// Publish sends an event with publisher confirms, reconnecting if the connection was lost
+func (p *rabbitMQPublisher) Publish(ctx context.Context, event Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.connect(); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error marshalling event: %w", err)
+	}
+
+	confirmation, err := p.channel.PublishWithDeferredConfirmWithContext(
+		ctx,
+		p.config.Exchange,
+		event.Type,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			MessageId:    strconv.FormatInt(event.ID, 10),
+			Type:         event.Type,
+			Timestamp:    event.OccurredAt,
+			Body:         body,
+		},
+	)
+	if err != nil {
+		p.reset()
+		return fmt.Errorf("error publishing event: %w", err)
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error waiting for publish confirmation: %w", err)
+	}
+	if !acked {
+		return errors.New("event was not acknowledged by the broker")
+	}
+
+	return nil
+}
+
+// Close closes the channel and connection
+func (p *rabbitMQPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.reset()
+	return nil
+}
+
+// connect opens the connection and channel if needed and declares the exchange
+func (p *rabbitMQPublisher) connect() error {
+	if p.conn != nil && !p.conn.IsClosed() && p.channel != nil && !p.channel.IsClosed() {
+		return nil
+	}
+	p.reset()
+
+	conn, err := amqp.Dial(p.config.URL)
+	if err != nil {
+		return fmt.Errorf("error connecting to rabbitmq: %w", err)
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("error opening rabbitmq channel: %w", err)
+	}
+
+	if err := channel.ExchangeDeclare(p.config.Exchange, "topic", true, false, false, false, nil); err != nil {
+		conn.Close()
+		return fmt.Errorf("error declaring rabbitmq exchange: %w", err)
+	}
+
+	if err := channel.Confirm(false); err != nil {
+		conn.Close()
+		return fmt.Errorf("error enabling publisher confirms: %w", err)
+	}
+
+	p.conn = conn
+	p.channel = channel
+	return nil
+}
+
+func (p *rabbitMQPublisher) reset() {
+	if p.channel != nil {
+		p.channel.Close()
+		p.channel = nil
+	}
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
@@ -0,0 +1,81 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers of a webhook delivery
+const (
+	WebhookIDHeader        = "X-Webhook-Id"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// WebhookSender posts events to webhook URLs
+type WebhookSender struct {
+	httpClient *http.Client
+}
+
+// NewWebhookSender creates a sender whose requests time out after timeout
+func NewWebhookSender(timeout time.Duration) *WebhookSender {
+	return &WebhookSender{
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Send posts an event signed with the secret and returns the response status. Any status but 2xx
+// is an error; the status is returned with it when there was a response.
+func (s *WebhookSender) Send(ctx context.Context, url, secret string, event Event) (int, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("error marshalling event: %w", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("error creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "erp-excel-webhooks")
+	req.Header.Set(WebhookIDHeader, strconv.FormatInt(event.ID, 10))
+	req.Header.Set(WebhookEventHeader, event.Type)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(secret, timestamp, body))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error delivering webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, fmt.Errorf("webhook answered %s: %s", resp.Status, msg)
+	}
+	// Drain the body so the connection is reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret.
+// Receivers compute it the same way and reject old timestamps, so a captured delivery cannot be
+// replayed later.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,160 @@
+// Package fieldcrypt encrypts sensitive column values, such as the email and phone of users, with
+// AES-256-GCM before they are stored. Encrypted values carry the ID of their key, so keys can be
+// rotated: values under older keys stay readable until they are rewritten under the current one.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix marks encrypted values, which read "enc:<key id>:<base64 nonce and ciphertext>"
+const prefix = "enc:"
+
+// ErrNoKey is returned when reading a value encrypted with a key that is not configured
+var ErrNoKey = errors.New("encryption key not configured")
+
+// Cipher encrypts and decrypts field values. A nil Cipher stores values as they are and can only
+// read plaintext values.
+type Cipher struct {
+	currentID string // empty when values are stored in plaintext
+	keys      map[string]cipher.AEAD
+	indexKey  []byte
+}
+
+// New creates a cipher that encrypts with the key currentID and decrypts with any of keys, which
+// map key IDs to base64 encoded 32 byte keys. Without a currentID the keys are only used to read
+// values, which are stored in plaintext again; that is how encryption is turned off.
+func New(currentID string, keys map[string]string) (*Cipher, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKey
+	}
+
+	c := &Cipher{currentID: currentID, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, encoded := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid encryption key ID %q", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding encryption key %s: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("encryption key %s must be 32 bytes, got %d", id, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("error creating cipher for key %s: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("error creating cipher for key %s: %w", id, err)
+		}
+		c.keys[id] = aead
+
+		if id == currentID {
+			// The blind index has its own key, derived so that it never equals the encryption key
+			mac := hmac.New(sha256.New, key)
+			mac.Write([]byte("fieldcrypt blind index"))
+			c.indexKey = mac.Sum(nil)
+		}
+	}
+	if currentID != "" && c.keys[currentID] == nil {
+		return nil, fmt.Errorf("encryption key %s is not configured", currentID)
+	}
+
+	return c, nil
+}
+
+// Enabled reports whether new values are encrypted
+func (c *Cipher) Enabled() bool {
+	return c != nil && c.currentID != ""
+}
+
+// Encrypt encrypts a value of the given field under the current key. The field, such as
+// "users.email", is authenticated with the value, so a value copied to another field does not
+// decrypt. Empty values and values without a current key are returned as they are.
+func (c *Cipher) Encrypt(field, value string) (string, error) {
+	if value == "" || !c.Enabled() {
+		return value, nil
+	}
+
+	aead := c.keys[c.currentID]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error generating nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(field))
+
+	return prefix + c.currentID + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the plaintext of a stored value of the field. Values that are not encrypted,
+// such as rows written before encryption was turned on, are returned as they are.
+func (c *Cipher) Decrypt(field, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !ok {
+		return "", fmt.Errorf("malformed encrypted value of %s", field)
+	}
+	var aead cipher.AEAD
+	if c != nil {
+		aead = c.keys[id]
+	}
+	if aead == nil {
+		return "", fmt.Errorf("%w: key %s of %s", ErrNoKey, id, field)
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted value of %s", field)
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("error decrypting %s with key %s: %w", field, id, err)
+	}
+
+	return string(plaintext), nil
+}
+
+// IsCurrent reports whether a stored value is in the form Encrypt gives now: encrypted under the
+// current key, or plaintext when there is none. Empty values are always current.
+func (c *Cipher) IsCurrent(value string) bool {
+	if value == "" {
+		return true
+	}
+	if !c.Enabled() {
+		return !IsEncrypted(value)
+	}
+	return strings.HasPrefix(value, prefix+c.currentID+":")
+}
+
+// BlindIndex returns a keyed hash of a value, trimmed and lower-cased, so encrypted values can be
+// looked up by exact match without decrypting them. It is empty for empty values and when values
+// are not encrypted.
+func (c *Cipher) BlindIndex(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" || !c.Enabled() {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsEncrypted reports whether a stored value is encrypted
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
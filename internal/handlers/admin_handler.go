@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"erp-excel/internal/dto"
 	"erp-excel/internal/service"
 	"erp-excel/internal/utils"
+	"log/slog"
+	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -15,6 +19,8 @@ type AdminHandler struct {
 	departmentService service.DepartmentService
 	roleService       service.RoleService
 	operationService  service.OperationService
+	searchService     service.SearchService
+	dashboardService  service.DashboardService
 }
 
 // NewAdminHandler creates a new admin handler
@@ -23,48 +29,48 @@ func NewAdminHandler(
 	departmentService service.DepartmentService,
 	roleService service.RoleService,
 	operationService service.OperationService,
+	searchService service.SearchService,
+	dashboardService service.DashboardService,
 ) *AdminHandler {
 	return &AdminHandler{
 		userService:       userService,
 		departmentService: departmentService,
 		roleService:       roleService,
 		operationService:  operationService,
+		searchService:     searchService,
+		dashboardService:  dashboardService,
 	}
 }
 
 // Dashboard returns admin dashboard statistics
 func (h *AdminHandler) Dashboard(c *fiber.Ctx) error {
-	userCount, err := h.userService.CountUsers(c.Context())
+	userCount, err := h.userService.CountUsers(c.UserContext(), false)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error getting user count",
-			err.Error(),
-		))
+		return errorResponse(c, "Error getting user count", err)
 	}
 
-	deptCount, err := h.departmentService.CountDepartments(c.Context())
+	deptCount, err := h.departmentService.CountDepartments(c.UserContext())
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error getting department count",
-			err.Error(),
-		))
+		return errorResponse(c, "Error getting department count", err)
 	}
 
-	roleCount, err := h.roleService.CountRoles(c.Context())
+	roleCount, err := h.roleService.CountRoles(c.UserContext())
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error getting role count",
-			err.Error(),
-		))
+		return errorResponse(c, "Error getting role count", err)
 	}
 
 	// Get recent access logs
-	logs, err := h.operationService.GetRecentLogs(c.Context(), 10)
+	logs, err := h.operationService.GetRecentLogs(c.UserContext(), 10)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error getting recent logs",
-			err.Error(),
-		))
+		return errorResponse(c, "Error getting recent logs", err)
+	}
+
+	// The ERP figures are left out rather than failing the dashboard when the ERP is unavailable
+	userID, _ := c.Locals("user_id").(int)
+	statistics, err := h.dashboardService.GetStatistics(c.UserContext(), userID)
+	if err != nil {
+		slog.WarnContext(c.UserContext(), "Dashboard without ERP statistics", "error", err)
+		statistics = nil
 	}
 
 	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
@@ -73,6 +79,7 @@ func (h *AdminHandler) Dashboard(c *fiber.Ctx) error {
 			"department_count": deptCount,
 			"role_count":       roleCount,
 			"recent_logs":      logs,
+			"erp_statistics":   statistics,
 		},
 		"Dashboard data retrieved successfully",
 	))
@@ -80,12 +87,9 @@ func (h *AdminHandler) Dashboard(c *fiber.Ctx) error {
 
 // GetSystemOperations gets all system operations
 func (h *AdminHandler) GetSystemOperations(c *fiber.Ctx) error {
-	operations, err := h.operationService.GetAllOperations(c.Context())
+	operations, err := h.operationService.GetAllOperations(c.UserContext())
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error getting operations",
-			err.Error(),
-		))
+		return errorResponse(c, "Error getting operations", err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
@@ -94,10 +98,65 @@ func (h *AdminHandler) GetSystemOperations(c *fiber.Ctx) error {
 	))
 }
 
+// Trash lists the deleted users, roles and departments that can be restored
+func (h *AdminHandler) Trash(c *fiber.Ctx) error {
+	users, err := h.userService.GetDeletedUsers(c.UserContext())
+	if err != nil {
+		return errorResponse(c, "Error getting deleted users", err)
+	}
+
+	roles, err := h.roleService.GetDeletedRoles(c.UserContext())
+	if err != nil {
+		return errorResponse(c, "Error getting deleted roles", err)
+	}
+
+	departments, err := h.departmentService.GetDeletedDepartments(c.UserContext())
+	if err != nil {
+		return errorResponse(c, "Error getting deleted departments", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		dto.TrashResponse{
+			Users:       users,
+			Roles:       roles,
+			Departments: departments,
+		},
+		"Trash retrieved successfully",
+	))
+}
+
+// Search finds users, departments and roles matching q for the admin quick-jump box
+func (h *AdminHandler) Search(c *fiber.Ctx) error {
+	query := strings.TrimSpace(c.Query("q"))
+	if len([]rune(query)) < 2 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid search",
+			"q must be at least 2 characters",
+		))
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit", "5"))
+	if limit < 1 || limit > 20 {
+		limit = 5
+	}
+
+	results, err := h.searchService.Search(c.UserContext(), query, limit)
+	if err != nil {
+		return errorResponse(c, "Error searching", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		results,
+		"Search completed successfully",
+	))
+}
+
 // SetupRoutes sets up the handler routes
 func (h *AdminHandler) SetupRoutes(router fiber.Router) {
 	admin := router.Group("/admin")
 
 	admin.Get("/dashboard", h.Dashboard)
 	admin.Get("/operations", h.GetSystemOperations)
+	admin.Get("/trash", h.Trash)
+	admin.Get("/search", h.Search)
 }
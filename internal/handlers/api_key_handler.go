@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// APIKeyHandler lets administrators issue and revoke the API keys of service accounts, and
+// users issue keys of their own for scripts and BI tools pulling reports on their behalf
+type APIKeyHandler struct {
+	BaseHandler
+
+	apiKeyService    service.APIKeyService
+	operationService service.OperationService
+	operationCode    string
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(
+	apiKeyService service.APIKeyService,
+	operationService service.OperationService,
+	operationCode string,
+) *APIKeyHandler {
+	if operationCode == "" {
+		operationCode = "api_keys"
+	}
+
+	return &APIKeyHandler{
+		apiKeyService:    apiKeyService,
+		operationService: operationService,
+		operationCode:    operationCode,
+	}
+}
+
+// GetAll lists the API keys without their secrets
+func (h *APIKeyHandler) GetAll(c *fiber.Ctx) error {
+	keys, err := h.apiKeyService.List(c.UserContext())
+	if err != nil {
+		return errorResponse(c, "Error retrieving API keys", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		keys,
+		"API keys retrieved successfully",
+	))
+}
+
+// Create issues an API key; the key is only part of this response
+func (h *APIKeyHandler) Create(c *fiber.Ctx) error {
+	var request dto.APIKeyRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body",
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	userID, _ := c.Locals("user_id").(int)
+	isAdmin, _ := c.Locals("is_admin").(bool)
+	created, err := h.apiKeyService.Create(c.UserContext(), userID, isAdmin, &request)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKeyRequest) {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid API key",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error creating API key", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.SuccessResponse(
+		created,
+		"API key created successfully, store the key now: it cannot be shown again",
+	))
+}
+
+// Revoke disables an API key
+func (h *APIKeyHandler) Revoke(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid ID",
+			"API key ID must be a number",
+		))
+	}
+
+	if err := h.apiKeyService.Revoke(c.UserContext(), id); err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"API key not found",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error revoking API key", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"API key revoked successfully",
+	))
+}
+
+// GetOwn lists the keys of the current user
+func (h *APIKeyHandler) GetOwn(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	keys, err := h.apiKeyService.ListForUser(c.UserContext(), userID)
+	if err != nil {
+		return errorResponse(c, "Error retrieving API keys", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		keys,
+		"API keys retrieved successfully",
+	))
+}
+
+// CreateOwn issues a key acting as the current user, scoped to some of the user's operations
+func (h *APIKeyHandler) CreateOwn(c *fiber.Ctx) error {
+	var request dto.APIKeyRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body",
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	userID, _ := c.Locals("user_id").(int)
+	created, err := h.apiKeyService.CreateForUser(c.UserContext(), userID, &request)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKeyRequest) {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid API key",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error creating API key", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.SuccessResponse(
+		created,
+		"API key created successfully, store the key now: it cannot be shown again",
+	))
+}
+
+// RevokeOwn disables one of the current user's keys
+func (h *APIKeyHandler) RevokeOwn(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid ID",
+			"API key ID must be a number",
+		))
+	}
+
+	userID, _ := c.Locals("user_id").(int)
+	if err := h.apiKeyService.RevokeForUser(c.UserContext(), userID, id); err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"API key not found",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error revoking API key", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"API key revoked successfully",
+	))
+}
+
+// requireUser limits self-service routes to signed-in users, so neither service accounts nor a
+// user's narrowly scoped key can change the user's account or issue further keys
+func requireUser(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	_, isAPIKey := c.Locals("api_key").(*service.APIKeyPrincipal)
+	if userID == 0 || isAPIKey {
+		return c.Status(fiber.StatusForbidden).JSON(utils.CodedErrorResponse(
+			"Permission denied",
+			"Only signed-in users can manage their own account",
+			apperror.CodePermissionDenied,
+		))
+	}
+	return c.Next()
+}
+
+// SetupRoutes sets up the handler routes
+func (h *APIKeyHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+	keys := router.Group("/admin/api-keys", requireOperation(h.operationCode))
+
+	keys.Get("/", h.GetAll)
+	keys.Post("/", h.Create)
+	keys.Delete("/:id", h.Revoke)
+
+	own := router.Group("/api-keys", requireUser)
+	own.Get("/", h.GetOwn)
+	own.Post("/", h.CreateOwn)
+	own.Delete("/:id", h.RevokeOwn)
+}
@@ -1,15 +1,18 @@
 package handlers
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"log"
-	"path/filepath"
+	"log/slog"
+	"strings"
 	"time"
 
 	"erp-excel/internal/dto"
 	"erp-excel/internal/repository"
 	"erp-excel/internal/service"
+	"erp-excel/internal/storage"
+	"erp-excel/internal/translate"
 	"erp-excel/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -18,17 +21,23 @@ import (
 type ReportHandler struct {
 	BaseHandler
 
-	reportService service.ReportService
-	reportRepo    repository.InventoryRepository
+	reportService    service.ReportService
+	assistant230Repo repository.Assistant230Repository
+	fileStorage      storage.Storage
+	downloadService  service.DownloadService
 }
 
 func NewReportHandler(
 	reportService service.ReportService,
-	reportRepo repository.InventoryRepository,
+	assistant230Repo repository.Assistant230Repository,
+	fileStorage storage.Storage,
+	downloadService service.DownloadService,
 ) *ReportHandler {
 	return &ReportHandler{
-		reportService: reportService,
-		reportRepo:    reportRepo,
+		reportService:    reportService,
+		assistant230Repo: assistant230Repo,
+		fileStorage:      fileStorage,
+		downloadService:  downloadService,
 	}
 }
 
@@ -41,7 +50,7 @@ func (h *ReportHandler) GetInventoryReportData(c *fiber.Ctx) error {
 
 	var request dto.DateRangeRequest
 	if err := c.BodyParser(&request); err != nil {
-		log.Printf("Error parsing request body for inventory data: %v", err)
+		slog.WarnContext(c.UserContext(), "Error parsing request body for inventory data", "error", err)
 		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
 			"Invalid request",
 			"Error parsing request body: "+err.Error(),
@@ -49,50 +58,86 @@ func (h *ReportHandler) GetInventoryReportData(c *fiber.Ctx) error {
 	}
 
 	if err := utils.ValidateStruct(&request); err != nil {
-		log.Printf("Validation error for inventory data: %v", err)
+		slog.WarnContext(c.UserContext(), "Validation error for inventory data", "error", err)
 		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
 			"Validation error",
 			err.Error(),
 		))
 	}
 
-	items, err := h.reportService.GetInventoryReportData(c.Context(), userID, departmentID, &request)
+	columns, err := service.InventoryColumns(&request)
 	if err != nil {
-		log.Printf("Error getting inventory report data: %v", err)
+		slog.WarnContext(c.UserContext(), "Invalid columns for report data", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid column",
+			err.Error(),
+		))
+	}
 
-		if err.Error() == "no data found to export for the specified date range" {
-			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
-				"No Data Found",
-				"No data available for the selected period.",
+	page := parseReportPage(c)
+	items, total, err := h.reportService.GetInventoryReportPage(c.UserContext(), userID, departmentID, &request, page)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error getting inventory report data", "error", err)
+
+		if errors.Is(err, repository.ErrInvalidSort) {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid sort",
+				err.Error(),
 			))
 		}
 
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error retrieving report data",
-			err.Error(),
-		))
+		return errorResponse(c, "Error retrieving report data", err)
 	}
 
-	reportTitle := "Report "
+	var period string
 	if request.Period != nil && *request.Period != "" {
-		reportTitle = fmt.Sprintf("Report: %s", formatPeriod(*request.Period))
-	} else if request.FromDate != nil && !request.FromDate.IsZero() && request.ToDate != nil && !request.ToDate.IsZero() {
-		reportTitle = fmt.Sprintf("Report from %s to %s",
-			request.FromDate.Format("02/01/2006"),
-			request.ToDate.Format("02/01/2006"),
-		)
+		period = formatPeriod(*request.Period)
+	}
+	reportTitle := reportDataTitle(c.UserContext(), period, request.FromDate, request.ToDate)
+	if request.InvoiceStatus != "" {
+		reportTitle = fmt.Sprintf("%s (%s)", reportTitle, translate.Key(c.UserContext(), request.InvoiceStatus))
 	}
 
-	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+	// Without a column selection the items keep their full shape
+	var data interface{} = items
+	var selected []string
+	if len(request.Columns) > 0 {
+		selected = columns
+		data, err = service.ProjectColumns(items, columns)
+		if err != nil {
+			slog.ErrorContext(c.UserContext(), "Error selecting report columns", "error", err)
+			return errorResponse(c, "Error retrieving report data", err)
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(reportDataResponse(
 		dto.ReportDataResponse{
-			ReportName:  reportTitle,
-			GeneratedAt: time.Now(),
-			Items:       items,
+			ReportName:     reportTitle,
+			GeneratedAt:    time.Now(),
+			Items:          data,
+			Columns:        selected,
+			TargetCurrency: strings.ToUpper(request.TargetCurrency),
+			ConvertedTotal: service.ConvertedInventoryTotal(items),
 		},
-		"Report data retrieved successfully",
+		page,
+		total,
 	))
 }
 
+// reportDataTitle titles the data of a period or date range in the language of the request
+func reportDataTitle(ctx context.Context, period string, fromDate, toDate *time.Time) string {
+	switch {
+	case period != "":
+		return strings.ReplaceAll(translate.Text(ctx, "report_data_title_period", "Report: {period}"), "{period}", period)
+	case fromDate != nil && !fromDate.IsZero() && toDate != nil && !toDate.IsZero():
+		return strings.NewReplacer(
+			"{from}", fromDate.Format("02/01/2006"),
+			"{to}", toDate.Format("02/01/2006"),
+		).Replace(translate.Text(ctx, "report_data_title_range", "Report from {from} to {to}"))
+	}
+	return translate.Text(ctx, "report_data_title", "Report ")
+}
+
 func formatPeriod(period string) string {
 	switch period {
 	case "7days":
@@ -105,6 +150,18 @@ func formatPeriod(period string) string {
 		return "Tháng hiện tại"
 	case "lastmonth":
 		return "Tháng trước"
+	case "currentquarter":
+		return "Quý hiện tại"
+	case "lastquarter":
+		return "Quý trước"
+	case "yeartodate":
+		return "Từ đầu năm đến nay"
+	case "lastyear":
+		return "Năm trước"
+	case "fiscalyeartodate":
+		return "Từ đầu năm tài chính đến nay"
+	case "lastfiscalyear":
+		return "Năm tài chính trước"
 	default:
 		return period
 	}
@@ -120,7 +177,7 @@ func (h *ReportHandler) ExportInventoryReport(c *fiber.Ctx) error {
 
 	var request dto.DateRangeRequest
 	if err := c.BodyParser(&request); err != nil {
-		log.Printf("Error parsing request body for inventory export: %v", err)
+		slog.WarnContext(c.UserContext(), "Error parsing request body for inventory export", "error", err)
 		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
 			"Invalid request",
 			"Error parsing request body: "+err.Error(),
@@ -128,30 +185,69 @@ func (h *ReportHandler) ExportInventoryReport(c *fiber.Ctx) error {
 	}
 
 	if err := utils.ValidateStruct(&request); err != nil {
-		log.Printf("Validation error for inventory export: %v", err)
+		slog.WarnContext(c.UserContext(), "Validation error for inventory export", "error", err)
 		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
 			"Validation error",
 			err.Error(),
 		))
 	}
 
-	reportFileResponse, err := h.reportService.ExportInventoryReport(c.Context(), userID, departmentID, &request)
+	reportFileResponse, err := h.reportService.ExportInventoryReport(c.UserContext(), userID, departmentID, &request)
 	if err != nil {
-		log.Printf("Error exporting inventory report: %v", err)
-		if err.Error() == "no data found to export for the specified date range" {
-			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
-				"No Data Found",
-				"No data found for the specified date range to export.",
+		slog.ErrorContext(c.UserContext(), "Error exporting inventory report", "error", err)
+		if errors.Is(err, service.ErrInvalidColumn) {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid column",
+				err.Error(),
 			))
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error exporting report",
+		return errorResponse(c, "Error exporting report", err)
+	}
+
+	return sendExportFile(c, reportFileResponse)
+}
+
+func (h *ReportHandler) ExportInventoryReportToSheet(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	departmentID, ok := c.Locals("department_id").(int)
+	if !ok {
+		departmentID = 0
+	}
+
+	var request dto.SheetExportRequest
+	if err := c.BodyParser(&request); err != nil {
+		slog.WarnContext(c.UserContext(), "Error parsing request body for inventory sheet export", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		slog.WarnContext(c.UserContext(), "Validation error for inventory sheet export", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
 			err.Error(),
 		))
 	}
 
-	c.Attachment(reportFileResponse.FileName)
-	return c.SendStream(reportFileResponse.FileDetal.(*bytes.Buffer))
+	response, err := h.reportService.ExportInventoryReportToSheet(c.UserContext(), userID, departmentID, &request)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error exporting inventory report to sheet", "error", err)
+		if errors.Is(err, service.ErrInvalidColumn) {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid column",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error exporting report to Google Sheets", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		response,
+		"Report exported to Google Sheets successfully",
+	))
 }
 
 func (h *ReportHandler) DownloadInventoryReport(c *fiber.Ctx) error {
@@ -164,17 +260,39 @@ func (h *ReportHandler) DownloadInventoryReport(c *fiber.Ctx) error {
 		))
 	}
 
-	fileName = filepath.Base(fileName)
-	filePath := filepath.Join("public", "downloads", fileName)
+	return sendLinkedFile(c, h.fileStorage, h.downloadService, fileName)
+}
+
+// PreviewInventoryReport returns the first rows of the report with the resolved parameters
+func (h *ReportHandler) PreviewInventoryReport(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	departmentID, _ := c.Locals("department_id").(int)
+
+	var request dto.DateRangeRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
 
-	if !utils.FileExists(filePath) {
-		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
-			"File not found",
-			"The requested file does not exist",
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
 		))
 	}
 
-	return c.Download(filePath, fileName)
+	preview, err := h.reportService.PreviewInventoryReport(c.UserContext(), userID, departmentID, &request)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error previewing report", "error", err)
+		return errorResponse(c, "Error retrieving report preview", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		preview,
+		"Report preview retrieved successfully",
+	))
 }
 
 func (h *ReportHandler) SetupRoutes(router fiber.Router) {
@@ -182,5 +300,7 @@ func (h *ReportHandler) SetupRoutes(router fiber.Router) {
 
 	reports.Post("/inventory", h.GetInventoryReportData)
 	reports.Post("/inventory/export", h.ExportInventoryReport)
+	reports.Post("/inventory/sheets", h.ExportInventoryReportToSheet)
+	reports.Post("/inventory/preview", h.PreviewInventoryReport)
 	reports.Get("/download/:fileName", h.DownloadInventoryReport)
 }
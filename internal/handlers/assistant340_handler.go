@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Assistant340Handler handles the purchase receipts report
+type Assistant340Handler struct {
+	BaseHandler
+
+	assistant340Service service.Assistant340Service
+}
+
+// NewAssistant340Handler creates a new purchase report handler
+func NewAssistant340Handler(assistant340Service service.Assistant340Service) *Assistant340Handler {
+	return &Assistant340Handler{
+		assistant340Service: assistant340Service,
+	}
+}
+
+// GetAssistant340ReportData returns the purchase receipt lines of the period
+func (h *Assistant340Handler) GetAssistant340ReportData(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	var request dto.Assistant340Request
+	if err := c.BodyParser(&request); err != nil {
+		slog.WarnContext(c.UserContext(), "Error parsing request body for assistant 340", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	items, err := h.assistant340Service.GetAssistant340ReportData(c.UserContext(), userID, &request, c.IP())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error getting assistant 340 data", "error", err)
+		return errorResponse(c, "Error retrieving report data", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		dto.Assistant340DataResponse{
+			ReportName:  reportDataTitle(c.UserContext(), "", request.FromDate, request.ToDate),
+			GeneratedAt: time.Now(),
+			Items:       items,
+		},
+		"Report data retrieved successfully",
+	))
+}
+
+// ExportAssistant340Report streams the purchase receipts as an Excel file
+func (h *Assistant340Handler) ExportAssistant340Report(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	departmentID, _ := c.Locals("department_id").(int)
+
+	var request dto.Assistant340Request
+	if err := c.BodyParser(&request); err != nil {
+		slog.WarnContext(c.UserContext(), "Error parsing request body for assistant 340 export", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	reportFileResponse, err := h.assistant340Service.ExportAssistant340Report(c.UserContext(), userID, departmentID, &request, c.IP())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error exporting assistant 340 report", "error", err)
+		return errorResponse(c, "Error exporting report", err)
+	}
+
+	return sendExportFile(c, reportFileResponse)
+}
+
+// SetupRoutes sets up the handler routes; their operations are checked by the route permissions
+func (h *Assistant340Handler) SetupRoutes(router fiber.Router) {
+	reports := router.Group("/assistants")
+
+	reports.Post("/340", h.GetAssistant340ReportData)
+	reports.Post("/340/export", h.ExportAssistant340Report)
+}
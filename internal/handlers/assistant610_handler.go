@@ -1,15 +1,15 @@
 package handlers
 
 import (
-	"bytes"
-	"fmt"
-	"log"
-	"path/filepath"
+	"errors"
+	"log/slog"
+	"strings"
 	"time"
 
 	"erp-excel/internal/dto"
 	"erp-excel/internal/repository"
 	"erp-excel/internal/service"
+	"erp-excel/internal/storage"
 	"erp-excel/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -19,16 +19,22 @@ type Assistant610Handler struct {
 	BaseHandler
 	assistant610Service service.Assistant610Service
 	assistantRepo       repository.Assistant610Repository
+	fileStorage         storage.Storage
+	downloadService     service.DownloadService
 }
 
 // Corrected to match the field types
 func NewAssistant610Handler(
 	assistant610Service service.Assistant610Service,
 	assistantRepo repository.Assistant610Repository,
+	fileStorage storage.Storage,
+	downloadService service.DownloadService,
 ) *Assistant610Handler {
 	return &Assistant610Handler{
 		assistant610Service: assistant610Service,
 		assistantRepo:       assistantRepo,
+		fileStorage:         fileStorage,
+		downloadService:     downloadService,
 	}
 }
 
@@ -41,7 +47,7 @@ func (h *Assistant610Handler) GetAssistant610ReportData(c *fiber.Ctx) error {
 
 	var request dto.DateRangeRequest
 	if err := c.BodyParser(&request); err != nil {
-		log.Printf("Error parsing request body for inventory data: %v", err)
+		slog.WarnContext(c.UserContext(), "Error parsing request body for inventory data", "error", err)
 		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
 			"Invalid request",
 			"Error parsing request body: "+err.Error(),
@@ -49,7 +55,7 @@ func (h *Assistant610Handler) GetAssistant610ReportData(c *fiber.Ctx) error {
 	}
 
 	if err := utils.ValidateStruct(&request); err != nil {
-		log.Printf("Validation error for inventory data: %v", err)
+		slog.WarnContext(c.UserContext(), "Validation error for inventory data", "error", err)
 		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
 			"Validation error",
 			err.Error(),
@@ -57,38 +63,57 @@ func (h *Assistant610Handler) GetAssistant610ReportData(c *fiber.Ctx) error {
 	}
 
 	// Fixed method call to use assistant610Service
-	items, err := h.assistant610Service.GetAssistant610ReportData(c.Context(), userID, departmentID, &request)
+	columns, err := service.Assistant610Columns(&request)
 	if err != nil {
-		log.Printf("Error getting inventory report data: %v", err)
-		if err.Error() == "no data found to export for the specified date range" {
-			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
-				"No Data Found",
-				"No data available for the selected period.",
-			))
-		}
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error retrieving report data",
+		slog.WarnContext(c.UserContext(), "Invalid columns for report data", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid column",
 			err.Error(),
 		))
 	}
 
-	reportTitle := "Report "
+	page := parseReportPage(c)
+	items, total, err := h.assistant610Service.GetAssistant610ReportPage(c.UserContext(), userID, departmentID, &request, page)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error getting inventory report data", "error", err)
+		if errors.Is(err, repository.ErrInvalidSort) {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid sort",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error retrieving report data", err)
+	}
+
+	var period string
 	if request.Period != nil && *request.Period != "" {
-		reportTitle = fmt.Sprintf("Report: %s", format610Period(*request.Period))
-	} else if !request.FromDate.IsZero() && !request.ToDate.IsZero() {
-		reportTitle = fmt.Sprintf("Report from %s to %s",
-			request.FromDate.Format("02/01/2006"),
-			request.ToDate.Format("02/01/2006"),
-		)
+		period = format610Period(*request.Period)
 	}
+	reportTitle := reportDataTitle(c.UserContext(), period, request.FromDate, request.ToDate)
 
-	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+	// Without a column selection the items keep their full shape
+	var data interface{} = items
+	var selected []string
+	if len(request.Columns) > 0 {
+		selected = columns
+		data, err = service.ProjectColumns(items, columns)
+		if err != nil {
+			slog.ErrorContext(c.UserContext(), "Error selecting report columns", "error", err)
+			return errorResponse(c, "Error retrieving report data", err)
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(reportDataResponse(
 		dto.Assistant610DataResponse{
-			ReportName:  reportTitle,
-			GeneratedAt: time.Now(),
-			Items:       items,
+			ReportName:     reportTitle,
+			GeneratedAt:    time.Now(),
+			Items:          data,
+			Columns:        selected,
+			TargetCurrency: strings.ToUpper(request.TargetCurrency),
+			ConvertedTotal: service.ConvertedAssistant610Total(items),
 		},
-		"Report data retrieved successfully",
+		page,
+		total,
 	))
 }
 
@@ -104,6 +129,18 @@ func format610Period(period string) string {
 		return "Tháng hiện tại"
 	case "lastmonth":
 		return "Tháng trước"
+	case "currentquarter":
+		return "Quý hiện tại"
+	case "lastquarter":
+		return "Quý trước"
+	case "yeartodate":
+		return "Từ đầu năm đến nay"
+	case "lastyear":
+		return "Năm trước"
+	case "fiscalyeartodate":
+		return "Từ đầu năm tài chính đến nay"
+	case "lastfiscalyear":
+		return "Năm tài chính trước"
 	default:
 		return period
 	}
@@ -118,7 +155,7 @@ func (h *Assistant610Handler) ExportAssistant610Report(c *fiber.Ctx) error {
 
 	var request dto.DateRangeRequest
 	if err := c.BodyParser(&request); err != nil {
-		log.Printf("Error parsing request body for inventory export: %v", err)
+		slog.WarnContext(c.UserContext(), "Error parsing request body for inventory export", "error", err)
 		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
 			"Invalid request",
 			"Error parsing request body: "+err.Error(),
@@ -126,7 +163,7 @@ func (h *Assistant610Handler) ExportAssistant610Report(c *fiber.Ctx) error {
 	}
 
 	if err := utils.ValidateStruct(&request); err != nil {
-		log.Printf("Validation error for inventory export: %v", err)
+		slog.WarnContext(c.UserContext(), "Validation error for inventory export", "error", err)
 		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
 			"Validation error",
 			err.Error(),
@@ -134,23 +171,98 @@ func (h *Assistant610Handler) ExportAssistant610Report(c *fiber.Ctx) error {
 	}
 
 	// Fixed method call to use assistant610Service
-	reportFileResponse, err := h.assistant610Service.ExportAssistant610Report(c.Context(), userID, departmentID, &request)
+	reportFileResponse, err := h.assistant610Service.ExportAssistant610Report(c.UserContext(), userID, departmentID, &request)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error exporting inventory report", "error", err)
+		if errors.Is(err, service.ErrInvalidColumn) {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid column",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error exporting report", err)
+	}
+
+	return sendExportFile(c, reportFileResponse)
+}
+
+func (h *Assistant610Handler) ExportAssistant610ReportToSheet(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	departmentID, ok := c.Locals("department_id").(int)
+	if !ok {
+		departmentID = 0
+	}
+
+	var request dto.SheetExportRequest
+	if err := c.BodyParser(&request); err != nil {
+		slog.WarnContext(c.UserContext(), "Error parsing request body for 610 sheet export", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		slog.WarnContext(c.UserContext(), "Validation error for 610 sheet export", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	response, err := h.assistant610Service.ExportAssistant610ReportToSheet(c.UserContext(), userID, departmentID, &request)
 	if err != nil {
-		log.Printf("Error exporting inventory report: %v", err)
-		if err.Error() == "no data found to export for the specified date range" {
-			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
-				"No Data Found",
-				"No data found for the specified date range to export.",
+		slog.ErrorContext(c.UserContext(), "Error exporting 610 report to sheet", "error", err)
+		if errors.Is(err, service.ErrInvalidColumn) {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid column",
+				err.Error(),
 			))
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error exporting report",
+		return errorResponse(c, "Error exporting report to Google Sheets", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		response,
+		"Report exported to Google Sheets successfully",
+	))
+}
+
+// GetAssistant610AgingSummary returns the 610 receivables grouped into aging buckets
+func (h *Assistant610Handler) GetAssistant610AgingSummary(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	departmentID, ok := c.Locals("department_id").(int)
+	if !ok {
+		departmentID = 0
+	}
+
+	var request dto.DateRangeRequest
+	if err := c.BodyParser(&request); err != nil {
+		slog.WarnContext(c.UserContext(), "Error parsing request body for 610 aging summary", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		slog.WarnContext(c.UserContext(), "Validation error for 610 aging summary", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
 			err.Error(),
 		))
 	}
 
-	c.Attachment(reportFileResponse.FileName)
-	return c.SendStream(reportFileResponse.FileDetal.(*bytes.Buffer)) // Ensure to check if FileDetal is not nil
+	summary, err := h.assistant610Service.GetAssistant610AgingSummary(c.UserContext(), userID, departmentID, &request)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error getting 610 aging summary", "error", err)
+		return errorResponse(c, "Error retrieving aging summary", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		summary,
+		"Aging summary retrieved successfully",
+	))
 }
 
 func (h *Assistant610Handler) DownloadAssistant610Report(c *fiber.Ctx) error {
@@ -162,17 +274,39 @@ func (h *Assistant610Handler) DownloadAssistant610Report(c *fiber.Ctx) error {
 		))
 	}
 
-	fileName = filepath.Base(fileName)
-	filePath := filepath.Join("public", "downloads", fileName)
+	return sendLinkedFile(c, h.fileStorage, h.downloadService, fileName)
+}
+
+// PreviewAssistant610Report returns the first rows of the report with the resolved parameters
+func (h *Assistant610Handler) PreviewAssistant610Report(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	departmentID, _ := c.Locals("department_id").(int)
+
+	var request dto.DateRangeRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
 
-	if !utils.FileExists(filePath) {
-		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
-			"File not found",
-			"The requested file does not exist",
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
 		))
 	}
 
-	return c.Download(filePath, fileName)
+	preview, err := h.assistant610Service.PreviewAssistant610Report(c.UserContext(), userID, departmentID, &request)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error previewing report", "error", err)
+		return errorResponse(c, "Error retrieving report preview", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		preview,
+		"Report preview retrieved successfully",
+	))
 }
 
 func (h *Assistant610Handler) SetupRoutes(router fiber.Router) {
@@ -180,5 +314,10 @@ func (h *Assistant610Handler) SetupRoutes(router fiber.Router) {
 
 	reports.Post("/610", h.GetAssistant610ReportData) // Corrected to use correct method
 	reports.Post("/610/export", h.ExportAssistant610Report)
+	reports.Post("/610/sheets", h.ExportAssistant610ReportToSheet)
+	reports.Post("/610/aging", h.GetAssistant610AgingSummary)
 	reports.Get("/download/:fileName", h.DownloadAssistant610Report)
+
+	// Preview follows the /reports/{type}/preview convention shared by all reports
+	router.Post("/reports/assistant610/preview", h.PreviewAssistant610Report)
 }
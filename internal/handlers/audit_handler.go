@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AuditHandler lets administrators read the audit trail of mutating requests
+type AuditHandler struct {
+	BaseHandler
+
+	auditService     service.AuditService
+	operationService service.OperationService
+	operationCode    string
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(
+	auditService service.AuditService,
+	operationService service.OperationService,
+	operationCode string,
+) *AuditHandler {
+	if operationCode == "" {
+		operationCode = "audit_logs"
+	}
+
+	return &AuditHandler{
+		auditService:     auditService,
+		operationService: operationService,
+		operationCode:    operationCode,
+	}
+}
+
+// GetAll lists the newest audit entries. Filters: user_id, method, path (prefix), from and to
+// (YYYY-MM-DD or RFC 3339) and limit.
+func (h *AuditHandler) GetAll(c *fiber.Ctx) error {
+	filter := repository.AuditLogFilter{
+		UserID: c.QueryInt("user_id", 0),
+		Method: strings.ToUpper(c.Query("method")),
+		Path:   c.Query("path"),
+		Limit:  c.QueryInt("limit", 0),
+	}
+
+	var err error
+	if filter.From, err = parseAuditTime(c.Query("from"), false); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid from",
+			err.Error(),
+		))
+	}
+	if filter.To, err = parseAuditTime(c.Query("to"), true); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid to",
+			err.Error(),
+		))
+	}
+
+	entries, err := h.auditService.List(c.UserContext(), filter)
+	if err != nil {
+		return errorResponse(c, "Error retrieving audit logs", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		entries,
+		"Audit logs retrieved successfully",
+	))
+}
+
+// parseAuditTime parses a date or timestamp filter; a date used as the end of a range covers the whole day
+func parseAuditTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if endOfDay {
+			t = t.Add(24*time.Hour - time.Millisecond)
+		}
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// SetupRoutes sets up the handler routes
+func (h *AuditHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+
+	router.Get("/admin/audit-logs", requireOperation(h.operationCode), h.GetAll)
+}
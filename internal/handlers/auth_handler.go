@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"erp-excel/database"
 	"erp-excel/internal/dto"
 	"erp-excel/internal/service"
 	"erp-excel/internal/utils"
+	"errors"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -12,13 +15,17 @@ import (
 type AuthHandler struct {
 	BaseHandler // Embedding BaseHandler
 
-	authService service.AuthService
+	authService      service.AuthService
+	menuService      service.MenuService
+	operationService service.OperationService
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(authService service.AuthService) *AuthHandler {
+func NewAuthHandler(authService service.AuthService, menuService service.MenuService, operationService service.OperationService) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
+		authService:      authService,
+		menuService:      menuService,
+		operationService: operationService,
 	}
 }
 
@@ -41,8 +48,15 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	}
 
 	// Attempt login
-	response, err := h.authService.Login(c.Context(), request)
+	request.IPAddress = c.IP()
+	response, err := h.authService.Login(c.UserContext(), request)
 	if err != nil {
+		if status, ok := companyErrorStatus(err); ok {
+			return c.Status(status).JSON(utils.ErrorResponse(
+				"Login failed",
+				err.Error(),
+			))
+		}
 		return c.Status(fiber.StatusUnauthorized).JSON(utils.ErrorResponse(
 			"Login failed",
 			err.Error(),
@@ -55,18 +69,91 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	))
 }
 
-// GetProfile retrieves the current user's profile
-func (h *AuthHandler) GetProfile(c *fiber.Ctx) error {
+// GetCompanies lists the ERP companies the current user may switch to
+func (h *AuthHandler) GetCompanies(c *fiber.Ctx) error {
 	isAdmin, _ := c.Locals("is_admin").(bool)
-	if isAdmin {
-		return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
-			dto.UserResponse{
-				Username: "admin",
-				FullName: "Admin",
-			},
-			"Profile retrieved successfully",
+	departmentID, _ := c.Locals("department_id").(int)
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		h.authService.GetCompanies(departmentID, isAdmin),
+		"Companies retrieved successfully",
+	))
+}
+
+// SwitchCompany issues a new token for the current user working on another ERP company
+func (h *AuthHandler) SwitchCompany(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok || userID == 0 {
+		return c.Status(fiber.StatusUnauthorized).JSON(utils.ErrorResponse(
+			"Authentication required",
+			"User not authenticated",
+		))
+	}
+
+	var request dto.SwitchCompanyRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body",
 		))
 	}
+	if err := utils.ValidateStruct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	response, err := h.authService.SwitchCompany(c.UserContext(), userID, request.Company)
+	if err != nil {
+		if status, ok := companyErrorStatus(err); ok {
+			return c.Status(status).JSON(utils.ErrorResponse(
+				"Error switching company",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error switching company", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		response,
+		"Company switched successfully",
+	))
+}
+
+// Logout revokes the token of the request, so it can no longer be used even before it expires
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	tokenString, ok := strings.CutPrefix(c.Get("Authorization"), "Bearer ")
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Only requests authenticated with a Bearer token can log out",
+		))
+	}
+
+	if err := h.authService.Logout(c.UserContext(), tokenString); err != nil {
+		return errorResponse(c, "Error logging out", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Logout successful",
+	))
+}
+
+// companyErrorStatus maps the errors of selecting a company to their status code
+func companyErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, database.ErrUnknownCompany):
+		return fiber.StatusBadRequest, true
+	case errors.Is(err, service.ErrCompanyNotAllowed):
+		return fiber.StatusForbidden, true
+	}
+	return 0, false
+}
+
+// GetProfile retrieves the current user's profile
+func (h *AuthHandler) GetProfile(c *fiber.Ctx) error {
 	userID, ok := c.Locals("user_id").(int)
 	if !ok || userID == 0 {
 		return c.Status(fiber.StatusUnauthorized).JSON(utils.ErrorResponse(
@@ -75,17 +162,87 @@ func (h *AuthHandler) GetProfile(c *fiber.Ctx) error {
 		))
 	}
 
-	profile, err := h.authService.GetUserProfile(c.Context(), userID)
+	profile, err := h.authService.GetUserProfile(c.UserContext(), userID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error retrieving profile",
+		return errorResponse(c, "Error retrieving profile", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		profile,
+		"Profile retrieved successfully",
+	))
+}
+
+// UpdateProfile changes the current user's own name, email and phone
+func (h *AuthHandler) UpdateProfile(c *fiber.Ctx) error {
+	var request dto.UpdateProfileRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body",
+		))
+	}
+
+	if err := utils.ValidateStruct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
 			err.Error(),
 		))
 	}
 
+	userID, _ := c.Locals("user_id").(int)
+	profile, err := h.authService.UpdateProfile(c.UserContext(), userID, request)
+	if err != nil {
+		return errorResponse(c, "Error updating profile", err)
+	}
+
 	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
 		profile,
-		"Profile retrieved successfully",
+		"Profile updated successfully",
+	))
+}
+
+// GetMenu returns the navigation menu the current user may see
+func (h *AuthHandler) GetMenu(c *fiber.Ctx) error {
+	isAdmin, _ := c.Locals("is_admin").(bool)
+	userID, ok := c.Locals("user_id").(int)
+	if !isAdmin && (!ok || userID == 0) {
+		return c.Status(fiber.StatusUnauthorized).JSON(utils.ErrorResponse(
+			"Authentication required",
+			"User not authenticated",
+		))
+	}
+
+	menu, err := h.menuService.GetMenu(c.UserContext(), userID, isAdmin)
+	if err != nil {
+		return errorResponse(c, "Error retrieving menu", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		menu,
+		"Menu retrieved successfully",
+	))
+}
+
+// GetPermissions returns the permission matrix of the current user
+func (h *AuthHandler) GetPermissions(c *fiber.Ctx) error {
+	isAdmin, _ := c.Locals("is_admin").(bool)
+	userID, ok := c.Locals("user_id").(int)
+	if !isAdmin && (!ok || userID == 0) {
+		return c.Status(fiber.StatusUnauthorized).JSON(utils.ErrorResponse(
+			"Authentication required",
+			"User not authenticated",
+		))
+	}
+
+	permissions, err := h.operationService.GetUserPermissions(c.UserContext(), userID, isAdmin)
+	if err != nil {
+		return errorResponse(c, "Error retrieving permissions", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		permissions,
+		"Permissions retrieved successfully",
 	))
 }
 
@@ -94,5 +251,11 @@ func (h *AuthHandler) SetupRoutes(router fiber.Router) {
 	auth := router.Group("/auth")
 
 	auth.Post("/login", h.Login)
+	auth.Post("/logout", h.Logout)
 	auth.Get("/profile", h.GetProfile)
+	auth.Put("/profile", requireUser, h.UpdateProfile)
+	auth.Get("/menu", h.GetMenu)
+	auth.Get("/permissions", h.GetPermissions)
+	auth.Get("/companies", h.GetCompanies)
+	auth.Post("/company", h.SwitchCompany)
 }
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"encoding/json"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/utils"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// batchReference matches {{ref.field.path}} placeholders in batch paths and bodies
+var batchReference = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)((?:\.[A-Za-z0-9_]+)*)\s*\}\}`)
+
+// quotedBatchReference matches a placeholder that is a whole JSON string
+var quotedBatchReference = regexp.MustCompile(`"` + batchReference.String() + `"`)
+
+// BatchHandler runs several API requests in one round trip
+type BatchHandler struct {
+	BaseHandler
+
+	app    *fiber.App
+	prefix string
+}
+
+// NewBatchHandler creates a new batch handler. Sub-requests are dispatched through the app, so
+// they pass the same authentication, permission checks and access logging as direct calls.
+func NewBatchHandler(app *fiber.App, prefix string) *BatchHandler {
+	return &BatchHandler{
+		app:    app,
+		prefix: prefix,
+	}
+}
+
+// Execute runs the operations of a batch in order and reports the result of each one
+func (h *BatchHandler) Execute(c *fiber.Ctx) error {
+	var request dto.BatchRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	response := dto.BatchResponse{Results: make([]dto.BatchResult, 0, len(request.Operations))}
+	ids := make(map[string]int)
+	failed := false
+
+	for i, operation := range request.Operations {
+		// Nobody is waiting for the rest of the batch once the client has gone away
+		if err := c.UserContext().Err(); err != nil {
+			return err
+		}
+
+		result := dto.BatchResult{Index: i, ID: operation.ID}
+
+		if failed && request.StopOnError {
+			result.Skipped = true
+			response.Skipped++
+			response.Results = append(response.Results, result)
+			continue
+		}
+
+		if operation.ID != "" {
+			if _, exists := ids[operation.ID]; exists {
+				result.Status = fiber.StatusBadRequest
+				result.Error = fmt.Sprintf("duplicate operation id %s", operation.ID)
+			}
+			ids[operation.ID] = i
+		}
+		if result.Error == "" {
+			h.run(c, operation, response.Results, ids, &result)
+		}
+
+		if result.Status >= 200 && result.Status < 300 {
+			response.Succeeded++
+		} else {
+			response.Failed++
+			failed = true
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		response,
+		"Batch executed",
+	))
+}
+
+// run resolves the references of one operation and dispatches it with the caller's credentials
+func (h *BatchHandler) run(c *fiber.Ctx, operation dto.BatchOperation, previous []dto.BatchResult, ids map[string]int, result *dto.BatchResult) {
+	path, err := resolveBatchReferences(operation.Path, false, previous, ids)
+	var body string
+	if err == nil && len(operation.Body) > 0 {
+		body, err = resolveBatchReferences(string(operation.Body), true, previous, ids)
+	}
+	if err != nil {
+		result.Status = fiber.StatusFailedDependency
+		result.Error = err.Error()
+		return
+	}
+
+	var req fasthttp.Request
+	req.Header.SetMethod(operation.Method)
+	req.SetRequestURI(h.prefix + path)
+	// The app routes the normalized path, with dot segments and escapes resolved, whatever its case
+	// and trailing slash; that is the path the operation must stay within
+	if err := h.checkTarget(string(req.URI().Path())); err != nil {
+		result.Status = fiber.StatusBadRequest
+		result.Error = err.Error()
+		return
+	}
+	req.Header.Set(fiber.HeaderAuthorization, c.Get(fiber.HeaderAuthorization))
+	if key := c.Get(middleware.APIKeyHeader); key != "" {
+		req.Header.Set(middleware.APIKeyHeader, key)
+	}
+	if company, ok := c.Locals("company").(string); ok {
+		req.Header.Set(middleware.CompanyHeader, company)
+	}
+	if body != "" {
+		req.Header.SetContentType(fiber.MIMEApplicationJSON)
+		req.SetBodyString(body)
+	}
+
+	var fctx fasthttp.RequestCtx
+	fctx.Init(&req, c.Context().RemoteAddr(), nil)
+	fctx.SetUserValue(middleware.ParentContextKey, c.UserContext())
+	h.app.Handler()(&fctx)
+
+	result.Status = fctx.Response.StatusCode()
+	responseBody := fctx.Response.Body()
+	if json.Valid(responseBody) {
+		result.Body = append(json.RawMessage(nil), responseBody...)
+	} else if len(responseBody) > 0 {
+		result.ContentType = string(fctx.Response.Header.ContentType())
+	}
+}
+
+// checkTarget rejects a sub-request path outside the API or targeting the batch endpoint itself,
+// so batches cannot be nested
+func (h *BatchHandler) checkTarget(path string) error {
+	path = strings.TrimSuffix(strings.ToLower(path), "/")
+	prefix := strings.ToLower(h.prefix)
+	if !strings.HasPrefix(path, prefix+"/") {
+		return fmt.Errorf("operation path must be within %s", h.prefix)
+	}
+	if path == prefix+"/batch" {
+		return fmt.Errorf("batches cannot be nested")
+	}
+	return nil
+}
+
+// resolveBatchReferences replaces {{ref.field.path}} with values from earlier JSON responses. In a
+// JSON body a quoted placeholder is replaced by the JSON value, so numbers stay numbers.
+func resolveBatchReferences(text string, isJSON bool, previous []dto.BatchResult, ids map[string]int) (string, error) {
+	var resolveErr error
+	lookup := func(match string) (interface{}, bool) {
+		parts := batchReference.FindStringSubmatch(match)
+		value, err := batchReferenceValue(parts[1], strings.TrimPrefix(parts[2], "."), previous, ids)
+		if err != nil {
+			if resolveErr == nil {
+				resolveErr = err
+			}
+			return nil, false
+		}
+		return value, true
+	}
+
+	if isJSON {
+		text = quotedBatchReference.ReplaceAllStringFunc(text, func(match string) string {
+			value, ok := lookup(strings.Trim(match, `"`))
+			if !ok {
+				return match
+			}
+			encoded, _ := json.Marshal(value)
+			return string(encoded)
+		})
+	}
+
+	text = batchReference.ReplaceAllStringFunc(text, func(match string) string {
+		value, ok := lookup(match)
+		if !ok {
+			return match
+		}
+		formatted := fmt.Sprint(value)
+		if isJSON {
+			// Inside a JSON string the value has to be escaped, without the surrounding quotes
+			encoded, _ := json.Marshal(formatted)
+			formatted = string(encoded[1 : len(encoded)-1])
+		}
+		return formatted
+	})
+
+	return text, resolveErr
+}
+
+// batchReferenceValue reads a dotted field path from the JSON response of an earlier operation
+func batchReferenceValue(ref string, fieldPath string, previous []dto.BatchResult, ids map[string]int) (interface{}, error) {
+	index, ok := ids[ref]
+	if !ok {
+		n, err := strconv.Atoi(ref)
+		if err != nil {
+			return nil, fmt.Errorf("unknown operation %s", ref)
+		}
+		index = n
+	}
+	if index < 0 || index >= len(previous) {
+		return nil, fmt.Errorf("operation %s has not run before this one", ref)
+	}
+
+	source := previous[index]
+	if source.Status < 200 || source.Status >= 300 || len(source.Body) == 0 {
+		return nil, fmt.Errorf("operation %s did not succeed", ref)
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(source.Body, &value); err != nil {
+		return nil, fmt.Errorf("operation %s did not return JSON", ref)
+	}
+	if fieldPath == "" {
+		return value, nil
+	}
+
+	for _, field := range strings.Split(fieldPath, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			value, ok = node[field]
+		case []interface{}:
+			i, err := strconv.Atoi(field)
+			ok = err == nil && i >= 0 && i < len(node)
+			if ok {
+				value = node[i]
+			}
+		default:
+			ok = false
+		}
+		if !ok {
+			return nil, fmt.Errorf("operation %s response has no field %s", ref, fieldPath)
+		}
+	}
+
+	return value, nil
+}
+
+// SetupRoutes sets up the handler routes
+func (h *BatchHandler) SetupRoutes(router fiber.Router) {
+	router.Post("/batch", h.Execute)
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CalendarHandler serves iCalendar feeds and their subscription links
+type CalendarHandler struct {
+	BaseHandler
+
+	calendarService service.CalendarService
+}
+
+// NewCalendarHandler creates a new calendar handler
+func NewCalendarHandler(calendarService service.CalendarService) *CalendarHandler {
+	return &CalendarHandler{
+		calendarService: calendarService,
+	}
+}
+
+// GetSubscription returns whether the current user has a feed token and since when. The feed URL
+// itself is only shown when the token is issued.
+func (h *CalendarHandler) GetSubscription(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok || userID == 0 {
+		return c.Status(fiber.StatusUnauthorized).JSON(utils.ErrorResponse(
+			"Authentication required",
+			"User not authenticated",
+		))
+	}
+
+	token, err := h.calendarService.GetSubscription(c.UserContext(), userID)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error getting calendar subscription", "error", err)
+		return errorResponse(c, "Error retrieving calendar subscription", err)
+	}
+
+	data := fiber.Map{"subscribed": token != nil}
+	if token != nil {
+		data["created_at"] = token.CreatedAt
+		data["last_used_at"] = token.LastUsedAt
+	}
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		data,
+		"Calendar subscription retrieved successfully",
+	))
+}
+
+// Subscribe issues a new feed token for the current user, replacing the previous one, and
+// returns the personal feed URL
+func (h *CalendarHandler) Subscribe(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok || userID == 0 {
+		return c.Status(fiber.StatusUnauthorized).JSON(utils.ErrorResponse(
+			"Authentication required",
+			"User not authenticated",
+		))
+	}
+
+	token, err := h.calendarService.Subscribe(c.UserContext(), userID)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error issuing calendar token", "error", err)
+		return errorResponse(c, "Error creating calendar subscription", err)
+	}
+
+	feedURL := c.BaseURL() + "/calendar/" + token + ".ics"
+	return c.Status(fiber.StatusCreated).JSON(utils.SuccessResponse(
+		fiber.Map{
+			"url":        feedURL,
+			"webcal_url": "webcal://" + strings.SplitN(feedURL, "://", 2)[1],
+		},
+		"Calendar subscription created, store the URL now, it cannot be shown again",
+	))
+}
+
+// Unsubscribe revokes the current user's feed token
+func (h *CalendarHandler) Unsubscribe(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok || userID == 0 {
+		return c.Status(fiber.StatusUnauthorized).JSON(utils.ErrorResponse(
+			"Authentication required",
+			"User not authenticated",
+		))
+	}
+
+	if err := h.calendarService.Unsubscribe(c.UserContext(), userID); err != nil {
+		if !errors.Is(err, service.ErrCalendarNotSubscribed) {
+			slog.ErrorContext(c.UserContext(), "Error revoking calendar token", "error", err)
+		}
+		return errorResponse(c, "Error revoking calendar subscription", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Calendar subscription revoked successfully",
+	))
+}
+
+// Feed renders the calendar identified by the token in the URL
+func (h *CalendarHandler) Feed(c *fiber.Ctx) error {
+	token := strings.TrimSuffix(c.Params("token"), ".ics")
+
+	userID, err := h.calendarService.UserIDFromToken(c.UserContext(), token)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCalendarToken) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"Calendar not found",
+				err.Error(),
+			))
+		}
+		slog.ErrorContext(c.UserContext(), "Error checking calendar token", "error", err)
+		return errorResponse(c, "Error building calendar", err)
+	}
+
+	feed, err := h.calendarService.BuildFeed(c.UserContext(), userID)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error building calendar feed", "error", err)
+		return errorResponse(c, "Error building calendar", err)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="calendar.ics"`)
+	return c.Status(fiber.StatusOK).Send(feed)
+}
+
+// SetupRoutes sets up the authenticated routes
+func (h *CalendarHandler) SetupRoutes(router fiber.Router) {
+	router.Get("/calendar/subscription", h.GetSubscription)
+	router.Post("/calendar/subscription", h.Subscribe)
+	router.Delete("/calendar/subscription", h.Unsubscribe)
+}
+
+// SetupFeedRoutes sets up the token-authenticated feed, outside the JWT protected API
+func (h *CalendarHandler) SetupFeedRoutes(router fiber.Router) {
+	router.Get("/calendar/:token", h.Feed)
+}
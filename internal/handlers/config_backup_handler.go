@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ConfigBackupHandler backs up and restores the permission configuration
+type ConfigBackupHandler struct {
+	BaseHandler
+
+	configBackupService service.ConfigBackupService
+	operationService    service.OperationService
+	operationCode       string
+}
+
+// NewConfigBackupHandler creates a new configuration backup handler
+func NewConfigBackupHandler(
+	configBackupService service.ConfigBackupService,
+	operationService service.OperationService,
+	operationCode string,
+) *ConfigBackupHandler {
+	if operationCode == "" {
+		operationCode = "config_backup"
+	}
+
+	return &ConfigBackupHandler{
+		configBackupService: configBackupService,
+		operationService:    operationService,
+		operationCode:       operationCode,
+	}
+}
+
+// Backup downloads the configuration as a versioned JSON bundle
+func (h *ConfigBackupHandler) Backup(c *fiber.Ctx) error {
+	bundle, err := h.configBackupService.Export(c.UserContext())
+	if err != nil {
+		return errorResponse(c, "Error exporting configuration", err)
+	}
+
+	content, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return errorResponse(c, "Error exporting configuration", err)
+	}
+
+	c.Attachment(fmt.Sprintf("config-backup-%s.json", bundle.ExportedAt.Format("20060102_150405")))
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	c.Set(checksumHeader, utils.Checksum(content))
+	return c.Status(fiber.StatusOK).Send(content)
+}
+
+// Restore merges an uploaded bundle into the configuration. With ?dry_run=true the changes are
+// counted and rolled back.
+func (h *ConfigBackupHandler) Restore(c *fiber.Ctx) error {
+	var bundle dto.ConfigBundle
+	if err := c.BodyParser(&bundle); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body",
+		))
+	}
+
+	if err := utils.ValidateStruct(&bundle); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	userID, _ := c.Locals("user_id").(int)
+	dryRun := c.QueryBool("dry_run")
+
+	result, err := h.configBackupService.Restore(c.UserContext(), &bundle, userID, dryRun)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidConfigBundle) {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid configuration bundle",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error restoring configuration", err)
+	}
+
+	message := "Configuration restored successfully"
+	if dryRun {
+		message = "Configuration restore checked, nothing was changed"
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		result,
+		message,
+	))
+}
+
+// SetupRoutes sets up the handler routes
+func (h *ConfigBackupHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+	backup := router.Group("/admin/config", requireOperation(h.operationCode))
+
+	backup.Get("/backup", h.Backup)
+	backup.Post("/restore", h.Restore)
+}
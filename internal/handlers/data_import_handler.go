@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"errors"
+	"path/filepath"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DataImportHandler imports corrections of ERP data from Excel through a preview and a commit
+type DataImportHandler struct {
+	BaseHandler
+
+	dataImportService service.DataImportService
+	operationService  service.OperationService
+	operationCode     string
+	ruleOperationCode string
+}
+
+// NewDataImportHandler creates a new data import handler
+func NewDataImportHandler(
+	dataImportService service.DataImportService,
+	operationService service.OperationService,
+	operationCode string,
+	ruleOperationCode string,
+) *DataImportHandler {
+	if operationCode == "" {
+		operationCode = "data_import"
+	}
+	if ruleOperationCode == "" {
+		ruleOperationCode = "data_import_rules"
+	}
+
+	return &DataImportHandler{
+		dataImportService: dataImportService,
+		operationService:  operationService,
+		operationCode:     operationCode,
+		ruleOperationCode: ruleOperationCode,
+	}
+}
+
+// Upload validates an uploaded Excel file, sent as "file" in a multipart form, and stages its
+// rows. The response is the preview of the corrections with the errors of each row.
+func (h *DataImportHandler) Upload(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"An Excel file is required in the file field",
+		))
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error reading uploaded file",
+		))
+	}
+	defer file.Close()
+
+	preview, err := h.dataImportService.Upload(
+		c.UserContext(),
+		userID,
+		c.Params("type"),
+		filepath.Base(fileHeader.Filename),
+		file,
+		c.IP(),
+	)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidImport) {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid import",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error importing file", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.SuccessResponse(
+		preview,
+		"Import staged successfully",
+	))
+}
+
+// GetAll lists the newest import batches
+func (h *DataImportHandler) GetAll(c *fiber.Ctx) error {
+	batches, err := h.dataImportService.List(c.UserContext())
+	if err != nil {
+		return errorResponse(c, "Error retrieving imports", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		batches,
+		"Imports retrieved successfully",
+	))
+}
+
+// GetByID returns an import batch with its staged rows
+func (h *DataImportHandler) GetByID(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid import ID",
+			"Import ID must be a positive number",
+		))
+	}
+
+	preview, err := h.dataImportService.Get(c.UserContext(), id)
+	if err != nil {
+		return h.importError(c, err, "Error retrieving import")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		preview,
+		"Import retrieved successfully",
+	))
+}
+
+// Commit commits the changed rows of a previewed import batch
+func (h *DataImportHandler) Commit(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid import ID",
+			"Import ID must be a positive number",
+		))
+	}
+
+	result, err := h.dataImportService.Commit(c.UserContext(), userID, id, c.IP())
+	if err != nil {
+		return h.importError(c, err, "Error committing import")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		result,
+		"Import committed successfully",
+	))
+}
+
+// Discard drops a previewed import batch
+func (h *DataImportHandler) Discard(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid import ID",
+			"Import ID must be a positive number",
+		))
+	}
+
+	if err := h.dataImportService.Discard(c.UserContext(), userID, id, c.IP()); err != nil {
+		return h.importError(c, err, "Error discarding import")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Import discarded successfully",
+	))
+}
+
+// GetRules lists the validation rules of ?type=, or of every import type
+func (h *DataImportHandler) GetRules(c *fiber.Ctx) error {
+	rules, err := h.dataImportService.ListRules(c.UserContext(), c.Query("type"))
+	if err != nil {
+		return h.importError(c, err, "Error retrieving import rules")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		rules,
+		"Import rules retrieved successfully",
+	))
+}
+
+// CreateRule adds a validation rule to an import type
+func (h *DataImportHandler) CreateRule(c *fiber.Ctx) error {
+	var request dto.ImportRuleRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body",
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	userID, _ := c.Locals("user_id").(int)
+	rule, err := h.dataImportService.CreateRule(c.UserContext(), userID, &request)
+	if err != nil {
+		return h.importError(c, err, "Error creating import rule")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.SuccessResponse(
+		rule,
+		"Import rule created successfully",
+	))
+}
+
+// DeleteRule deletes a validation rule managed in the database
+func (h *DataImportHandler) DeleteRule(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid rule ID",
+			"Rule ID must be a positive number",
+		))
+	}
+
+	userID, _ := c.Locals("user_id").(int)
+	if err := h.dataImportService.DeleteRule(c.UserContext(), userID, id); err != nil {
+		return h.importError(c, err, "Error deleting import rule")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Import rule deleted successfully",
+	))
+}
+
+// importError maps the errors of an existing import batch to their status
+func (h *DataImportHandler) importError(c *fiber.Ctx, err error, title string) error {
+	switch {
+	case errors.Is(err, service.ErrImportNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+			"Import not found",
+			err.Error(),
+		))
+	case errors.Is(err, service.ErrImportRuleNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+			"Import rule not found",
+			err.Error(),
+		))
+	case errors.Is(err, service.ErrImportClosed), errors.Is(err, service.ErrNothingToCommit):
+		return c.Status(fiber.StatusConflict).JSON(utils.ErrorResponse(
+			title,
+			err.Error(),
+		))
+	case errors.Is(err, service.ErrInvalidImport):
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid import",
+			err.Error(),
+		))
+	case errors.Is(err, service.ErrInvalidImportRule):
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid import rule",
+			err.Error(),
+		))
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
+		title,
+		err.Error(),
+	))
+}
+
+// SetupRoutes sets up the handler routes
+func (h *DataImportHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+
+	// Registered before /imports/:type and /imports/:id, which would match them
+	rules := router.Group("/imports/rules", requireOperation(h.ruleOperationCode))
+	rules.Get("/", h.GetRules)
+	rules.Post("/", h.CreateRule)
+	rules.Delete("/:id", h.DeleteRule)
+
+	imports := router.Group("/imports", requireOperation(h.operationCode))
+	imports.Get("/", h.GetAll)
+	imports.Post("/:type", h.Upload)
+	imports.Get("/:id", h.GetByID)
+	imports.Post("/:id/commit", h.Commit)
+	imports.Delete("/:id", h.Discard)
+}
@@ -4,6 +4,7 @@ import (
 	"erp-excel/internal/dto"
 	"erp-excel/internal/service"
 	"erp-excel/internal/utils"
+	"errors"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
@@ -41,21 +42,15 @@ func (h *DepartmentHandler) GetAll(c *fiber.Ctx) error {
 	offset := (page - 1) * limit
 
 	// Get departments
-	departments, err := h.departmentService.GetAllDepartments(c.Context(), limit, offset)
+	departments, err := h.departmentService.GetAllDepartments(c.UserContext(), limit, offset)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error retrieving departments",
-			err.Error(),
-		))
+		return errorResponse(c, "Error retrieving departments", err)
 	}
 
 	// Get total count for pagination
-	total, err := h.departmentService.CountDepartments(c.Context())
+	total, err := h.departmentService.CountDepartments(c.UserContext())
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error counting departments",
-			err.Error(),
-		))
+		return errorResponse(c, "Error counting departments", err)
 	}
 
 	// Calculate pagination info
@@ -89,7 +84,7 @@ func (h *DepartmentHandler) GetByID(c *fiber.Ctx) error {
 		))
 	}
 
-	department, err := h.departmentService.GetDepartmentByID(c.Context(), id)
+	department, err := h.departmentService.GetDepartmentByID(c.UserContext(), id)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
 			"Department not found",
@@ -103,6 +98,19 @@ func (h *DepartmentHandler) GetByID(c *fiber.Ctx) error {
 	))
 }
 
+// GetTree retrieves every department arranged under its parent
+func (h *DepartmentHandler) GetTree(c *fiber.Ctx) error {
+	tree, err := h.departmentService.GetDepartmentTree(c.UserContext())
+	if err != nil {
+		return errorResponse(c, "Error retrieving departments", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		tree,
+		"Department tree retrieved successfully",
+	))
+}
+
 // Create creates a new department
 func (h *DepartmentHandler) Create(c *fiber.Ctx) error {
 	var request dto.CreateDepartmentRequest
@@ -122,12 +130,15 @@ func (h *DepartmentHandler) Create(c *fiber.Ctx) error {
 	}
 
 	// Create department
-	department, err := h.departmentService.CreateDepartment(c.Context(), request)
+	department, err := h.departmentService.CreateDepartment(c.UserContext(), request)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error creating department",
-			err.Error(),
-		))
+		if errors.Is(err, service.ErrParentDepartmentNotFound) || errors.Is(err, service.ErrDepartmentCycle) {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid parent department",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error creating department", err)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(utils.SuccessResponse(
@@ -163,12 +174,15 @@ func (h *DepartmentHandler) Update(c *fiber.Ctx) error {
 	}
 
 	// Update department
-	department, err := h.departmentService.UpdateDepartment(c.Context(), id, request)
+	department, err := h.departmentService.UpdateDepartment(c.UserContext(), id, request)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error updating department",
-			err.Error(),
-		))
+		if errors.Is(err, service.ErrParentDepartmentNotFound) || errors.Is(err, service.ErrDepartmentCycle) {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid parent department",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error updating department", err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
@@ -187,11 +201,8 @@ func (h *DepartmentHandler) Delete(c *fiber.Ctx) error {
 		))
 	}
 
-	if err := h.departmentService.DeleteDepartment(c.Context(), id); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error deleting department",
-			err.Error(),
-		))
+	if err := h.departmentService.DeleteDepartment(c.UserContext(), id); err != nil {
+		return errorResponse(c, "Error deleting department", err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
@@ -200,13 +211,41 @@ func (h *DepartmentHandler) Delete(c *fiber.Ctx) error {
 	))
 }
 
+// Restore takes a department out of the trash
+func (h *DepartmentHandler) Restore(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid department ID",
+			"Department ID must be a number",
+		))
+	}
+
+	if err := h.departmentService.RestoreDepartment(c.UserContext(), id); err != nil {
+		if errors.Is(err, service.ErrNotDeleted) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"Department not found in trash",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error restoring department", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Department restored successfully",
+	))
+}
+
 // SetupRoutes sets up the handler routes
 func (h *DepartmentHandler) SetupRoutes(router fiber.Router) {
 	departments := router.Group("/departments")
 
 	departments.Get("/", h.GetAll)
+	departments.Get("/tree", h.GetTree)
 	departments.Get("/:id", h.GetByID)
 	departments.Post("/", h.Create)
 	departments.Put("/:id", h.Update)
 	departments.Delete("/:id", h.Delete)
+	departments.Post("/:id/restore", h.Restore)
 }
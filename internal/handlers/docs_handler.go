@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"erp-excel/internal/openapi"
+	"erp-excel/internal/utils"
+	"fmt"
+	"html"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DocsHandler serves the OpenAPI document of the API, generated from the route table, and a
+// Swagger UI to browse it
+type DocsHandler struct {
+	BaseHandler
+
+	app          *fiber.App
+	options      openapi.Options
+	swaggerUIURL string
+
+	once     sync.Once
+	document []byte
+	err      error
+}
+
+// NewDocsHandler creates a new docs handler. The document is generated on the first request,
+// once every route has been registered. swaggerUIURL is where the swagger-ui-dist assets are
+// loaded from.
+func NewDocsHandler(app *fiber.App, options openapi.Options, swaggerUIURL string) *DocsHandler {
+	if swaggerUIURL == "" {
+		swaggerUIURL = "https://unpkg.com/swagger-ui-dist@5"
+	}
+
+	return &DocsHandler{
+		app:          app,
+		options:      options,
+		swaggerUIURL: swaggerUIURL,
+	}
+}
+
+// Document returns the OpenAPI 3 document
+func (h *DocsHandler) Document(c *fiber.Ctx) error {
+	h.once.Do(func() {
+		h.document, h.err = json.Marshal(openapi.Build(h.app.GetRoutes(true), h.options))
+	})
+	if h.err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
+			"Error generating API documentation",
+			h.err.Error(),
+		))
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	return c.Send(h.document)
+}
+
+// UI serves the Swagger UI page of the document
+func (h *DocsHandler) UI(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.SendString(fmt.Sprintf(swaggerUIPage,
+		html.EscapeString(h.options.Title),
+		html.EscapeString(h.swaggerUIURL),
+		html.EscapeString(h.swaggerUIURL),
+	))
+}
+
+// SetupRoutes registers the documentation on the root router, outside authentication, so the UI
+// can be opened in a browser
+func (h *DocsHandler) SetupRoutes(router fiber.Router) {
+	router.Get("/api/docs", h.UI)
+	router.Get("/api/docs/openapi.json", h.Document)
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>%s</title>
+  <link rel="stylesheet" href="%s/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="%s/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({
+      url: window.location.pathname.replace(/\/$/, '') + '/openapi.json',
+      dom_id: '#swagger-ui',
+      persistAuthorization: true
+    });
+  </script>
+</body>
+</html>
+`
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/storage"
+	"erp-excel/internal/utils"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DownloadHandler lets administrators manage the generated export files and users list and
+// download again the files they generated
+type DownloadHandler struct {
+	BaseHandler
+
+	downloadService  service.DownloadService
+	operationService service.OperationService
+	fileStorage      storage.Storage
+	operationCode    string
+}
+
+// NewDownloadHandler creates a new download handler
+func NewDownloadHandler(
+	downloadService service.DownloadService,
+	operationService service.OperationService,
+	fileStorage storage.Storage,
+	operationCode string,
+) *DownloadHandler {
+	if operationCode == "" {
+		operationCode = "downloads_admin"
+	}
+
+	return &DownloadHandler{
+		downloadService:  downloadService,
+		operationService: operationService,
+		fileStorage:      fileStorage,
+		operationCode:    operationCode,
+	}
+}
+
+// GetAll lists the generated files with size, age, owner and access log
+func (h *DownloadHandler) GetAll(c *fiber.Ctx) error {
+	files, err := h.downloadService.List(c.UserContext())
+	if err != nil {
+		return errorResponse(c, "Error retrieving files", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		files,
+		"Files retrieved successfully",
+	))
+}
+
+// GetByAccessLog lists the files generated by the run of an access log entry
+func (h *DownloadHandler) GetByAccessLog(c *fiber.Ctx) error {
+	logID, err := c.ParamsInt("logID")
+	if err != nil || logID < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid log ID",
+			"Log ID must be a positive number",
+		))
+	}
+
+	files, err := h.downloadService.ListByAccessLog(c.UserContext(), logID)
+	if err != nil {
+		if errors.Is(err, service.ErrDownloadNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"File not found",
+				"No file was recorded for this access log",
+			))
+		}
+		return errorResponse(c, "Error retrieving files", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		files,
+		"Files retrieved successfully",
+	))
+}
+
+// DownloadByAccessLog serves the newest file generated by the run of an access log entry
+func (h *DownloadHandler) DownloadByAccessLog(c *fiber.Ctx) error {
+	logID, err := c.ParamsInt("logID")
+	if err != nil || logID < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid log ID",
+			"Log ID must be a positive number",
+		))
+	}
+
+	files, err := h.downloadService.ListByAccessLog(c.UserContext(), logID)
+	if err != nil {
+		if errors.Is(err, service.ErrDownloadNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"File not found",
+				"No file was recorded for this access log",
+			))
+		}
+		return errorResponse(c, "Error retrieving file", err)
+	}
+
+	return sendStoredFile(c, h.fileStorage, h.downloadService, files[0].FileName)
+}
+
+// Delete removes one generated file
+func (h *DownloadHandler) Delete(c *fiber.Ctx) error {
+	if err := h.downloadService.Delete(c.UserContext(), c.Params("fileName")); err != nil {
+		if errors.Is(err, service.ErrDownloadNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"File not found",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error deleting file", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"File deleted successfully",
+	))
+}
+
+// Cleanup removes the generated files older than a number of days
+func (h *DownloadHandler) Cleanup(c *fiber.Ctx) error {
+	var request dto.DownloadCleanupRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body",
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	result, err := h.downloadService.Cleanup(c.UserContext(), &request)
+	if err != nil {
+		return errorResponse(c, "Error cleaning up files", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		result,
+		"Files cleaned up successfully",
+	))
+}
+
+// GetMine lists a page of the files the signed-in user generated, newest first, with the
+// parameters of each run and a link to download the file again; report=<code> narrows the list
+// to one report
+func (h *DownloadHandler) GetMine(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	// Parse pagination parameters
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+	report := strings.TrimSpace(c.Query("report"))
+
+	files, err := h.downloadService.ListForUser(c.UserContext(), userID, report, limit, offset)
+	if err != nil {
+		return errorResponse(c, "Error retrieving files", err)
+	}
+
+	total, err := h.downloadService.CountForUser(c.UserContext(), userID, report)
+	if err != nil {
+		return errorResponse(c, "Error counting files", err)
+	}
+
+	// Calculate pagination info
+	totalPages := (total + limit - 1) / limit
+	hasNext := page < totalPages
+	hasPrev := page > 1
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		fiber.Map{
+			"files": files,
+			"pagination": fiber.Map{
+				"total":       total,
+				"page":        page,
+				"limit":       limit,
+				"total_pages": totalPages,
+				"has_next":    hasNext,
+				"has_prev":    hasPrev,
+			},
+		},
+		"Files retrieved successfully",
+	))
+}
+
+// DownloadLink serves a file through the signed download link returned with its export
+func (h *DownloadHandler) DownloadLink(c *fiber.Ctx) error {
+	return sendLinkedFile(c, h.fileStorage, h.downloadService, c.Params("fileName"))
+}
+
+// SetupRoutes sets up the handler routes
+func (h *DownloadHandler) SetupRoutes(router fiber.Router) {
+	// The link's signature stands in for an operation check
+	router.Get("/downloads/:fileName", h.DownloadLink)
+	// Every user sees only their own files
+	router.Get("/reports/files", h.GetMine)
+
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+	downloads := router.Group("/admin/downloads", requireOperation(h.operationCode))
+
+	downloads.Get("/", h.GetAll)
+	downloads.Post("/cleanup", h.Cleanup)
+	downloads.Get("/logs/:logID", h.GetByAccessLog)
+	downloads.Get("/logs/:logID/file", h.DownloadByAccessLog)
+	downloads.Delete("/:fileName", h.Delete)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ERPQueueHandler shows administrators the queues of the report queries waiting for a turn on
+// the ERP databases
+type ERPQueueHandler struct {
+	BaseHandler
+
+	throttle         *service.ERPThrottle
+	operationService service.OperationService
+	operationCode    string
+}
+
+// NewERPQueueHandler creates a new ERP queue handler
+func NewERPQueueHandler(
+	throttle *service.ERPThrottle,
+	operationService service.OperationService,
+	operationCode string,
+) *ERPQueueHandler {
+	if operationCode == "" {
+		operationCode = "report_admin"
+	}
+
+	return &ERPQueueHandler{
+		throttle:         throttle,
+		operationService: operationService,
+		operationCode:    operationCode,
+	}
+}
+
+// GetStatus returns the queue depth, running queries and counters of every ERP database of this
+// instance
+func (h *ERPQueueHandler) GetStatus(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		h.throttle.Status(),
+		"ERP queues retrieved successfully",
+	))
+}
+
+// SetupRoutes sets up the handler routes
+func (h *ERPQueueHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+	router.Get("/admin/erp-queue", requireOperation(h.operationCode), h.GetStatus)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ERPSyncHandler handles ERP cache sync operations
+type ERPSyncHandler struct {
+	BaseHandler
+
+	erpSyncService service.ERPSyncService
+}
+
+// NewERPSyncHandler creates a new ERP sync handler
+func NewERPSyncHandler(erpSyncService service.ERPSyncService) *ERPSyncHandler {
+	return &ERPSyncHandler{
+		erpSyncService: erpSyncService,
+	}
+}
+
+// GetStatus returns the state of the ERP cache tables
+func (h *ERPSyncHandler) GetStatus(c *fiber.Ctx) error {
+	states, err := h.erpSyncService.GetStatus(c.UserContext())
+	if err != nil {
+		return errorResponse(c, "Error retrieving sync status", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		states,
+		"Sync status retrieved successfully",
+	))
+}
+
+// RunSync triggers an ERP cache sync immediately
+func (h *ERPSyncHandler) RunSync(c *fiber.Ctx) error {
+	states, err := h.erpSyncService.RunSync(c.UserContext())
+	if err != nil {
+		return errorResponse(c, "Error syncing ERP data", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		states,
+		"ERP data synced successfully",
+	))
+}
+
+// SetupRoutes sets up the handler routes
+func (h *ERPSyncHandler) SetupRoutes(router fiber.Router) {
+	sync := router.Group("/admin/erp-sync")
+
+	sync.Get("/status", h.GetStatus)
+	sync.Post("/run", h.RunSync)
+}
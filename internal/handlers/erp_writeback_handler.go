@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ERPWriteBackHandler handles marking processed documents back in the ERP
+type ERPWriteBackHandler struct {
+	BaseHandler
+
+	writeBackService service.ERPWriteBackService
+	operationService service.OperationService
+	operationCode    string
+}
+
+// NewERPWriteBackHandler creates a new ERP write-back handler
+func NewERPWriteBackHandler(
+	writeBackService service.ERPWriteBackService,
+	operationService service.OperationService,
+	operationCode string,
+) *ERPWriteBackHandler {
+	if operationCode == "" {
+		operationCode = "erp_writeback"
+	}
+
+	return &ERPWriteBackHandler{
+		writeBackService: writeBackService,
+		operationService: operationService,
+		operationCode:    operationCode,
+	}
+}
+
+// WriteBackInvoices previews (dry_run) or applies the processed flag on ERP documents
+func (h *ERPWriteBackHandler) WriteBackInvoices(c *fiber.Ctx) error {
+	var request dto.ERPWriteBackRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body",
+		))
+	}
+
+	if err := utils.ValidateStruct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	userID, _ := c.Locals("user_id").(int)
+
+	response, err := h.writeBackService.WriteBack(c.UserContext(), userID, &request, c.IP())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error writing back ERP documents", "error", err)
+		return errorResponse(c, "Error writing back ERP documents", err)
+	}
+
+	message := "ERP documents updated successfully"
+	if response.DryRun {
+		message = "Dry run completed, no ERP documents were changed"
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		response,
+		message,
+	))
+}
+
+// GetLogs returns the write-back audit trail
+func (h *ERPWriteBackHandler) GetLogs(c *fiber.Ctx) error {
+	logs, err := h.writeBackService.GetLogs(c.UserContext(), c.QueryInt("limit", 50))
+	if err != nil {
+		return errorResponse(c, "Error retrieving write-back logs", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		logs,
+		"Write-back logs retrieved successfully",
+	))
+}
+
+// SetupRoutes sets up the handler routes
+func (h *ERPWriteBackHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+	writeBack := router.Group("/erp/writeback", requireOperation(h.operationCode))
+
+	writeBack.Post("/invoices", h.WriteBackInvoices)
+	writeBack.Get("/logs", h.GetLogs)
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// errorResponse answers a failed request from its error, classified by apperror: coded errors,
+// which services wrap to add detail, with their status, title and code, e.g. 400 validation_error,
+// 403 permission_denied, 404 not_found, 409 conflict, 503 with a Retry-After header when the ERP
+// database is busy or 504 when an ERP query timed out, and records the database did not find with
+// 404. Any other error is answered with 500, the given title and the internal_error code.
+func errorResponse(c *fiber.Ctx, title string, err error) error {
+	status, code, detail := fiber.StatusInternalServerError, apperror.CodeInternal, err.Error()
+	if coded, ok := apperror.As(err); ok {
+		status, code, title = coded.Status, coded.Code, coded.Title
+		if coded.Detail != "" {
+			detail = coded.Detail
+		}
+		if coded.RetryAfter > 0 {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int((coded.RetryAfter+time.Second-1)/time.Second)))
+		}
+	}
+
+	return c.Status(status).JSON(utils.CodedErrorResponse(title, detail, code))
+}
+
+// ErrorHandler is the Fiber error handler. It answers the errors handlers and middleware return
+// rather than write a response themselves like errorResponse does; Fiber errors keep their status.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return c.Status(fiberErr.Code).JSON(utils.CodedErrorResponse(
+			fiberErr.Message,
+			err.Error(),
+			apperror.CodeOfStatus(fiberErr.Code),
+		))
+	}
+
+	return errorResponse(c, "Internal Server Error", err)
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ExchangeRateHandler manages the exchange rates used for converted report totals
+type ExchangeRateHandler struct {
+	BaseHandler
+
+	exchangeRateService service.ExchangeRateService
+	operationService    service.OperationService
+	operationCode       string
+}
+
+// NewExchangeRateHandler creates a new exchange rate handler
+func NewExchangeRateHandler(
+	exchangeRateService service.ExchangeRateService,
+	operationService service.OperationService,
+	operationCode string,
+) *ExchangeRateHandler {
+	if operationCode == "" {
+		operationCode = "exchange_rates"
+	}
+
+	return &ExchangeRateHandler{
+		exchangeRateService: exchangeRateService,
+		operationService:    operationService,
+		operationCode:       operationCode,
+	}
+}
+
+// GetAll returns the exchange rates, optionally filtered by ?currency=
+func (h *ExchangeRateHandler) GetAll(c *fiber.Ctx) error {
+	rates, err := h.exchangeRateService.List(c.UserContext(), c.Query("currency"))
+	if err != nil {
+		return errorResponse(c, "Error retrieving exchange rates", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		rates,
+		"Exchange rates retrieved successfully",
+	))
+}
+
+// Create adds an exchange rate
+func (h *ExchangeRateHandler) Create(c *fiber.Ctx) error {
+	var request dto.ExchangeRateRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body",
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	userID, _ := c.Locals("user_id").(int)
+	rate, err := h.exchangeRateService.Create(c.UserContext(), userID, &request)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Error creating exchange rate",
+			err.Error(),
+		))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.SuccessResponse(
+		rate,
+		"Exchange rate created successfully",
+	))
+}
+
+// Update changes an exchange rate
+func (h *ExchangeRateHandler) Update(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid ID",
+			"Exchange rate ID must be a number",
+		))
+	}
+
+	var request dto.ExchangeRateRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body",
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	rate, err := h.exchangeRateService.Update(c.UserContext(), id, &request)
+	if err != nil {
+		if errors.Is(err, service.ErrExchangeRateNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"Exchange rate not found",
+				err.Error(),
+			))
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Error updating exchange rate",
+			err.Error(),
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		rate,
+		"Exchange rate updated successfully",
+	))
+}
+
+// Delete removes an exchange rate
+func (h *ExchangeRateHandler) Delete(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid ID",
+			"Exchange rate ID must be a number",
+		))
+	}
+
+	if err := h.exchangeRateService.Delete(c.UserContext(), id); err != nil {
+		if errors.Is(err, service.ErrExchangeRateNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"Exchange rate not found",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error deleting exchange rate", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Exchange rate deleted successfully",
+	))
+}
+
+// SetupRoutes sets up the handler routes
+func (h *ExchangeRateHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+	rates := router.Group("/exchange-rates")
+
+	// Every user running a converted report may read the rates
+	rates.Get("/", h.GetAll)
+	rates.Post("/", requireOperation(h.operationCode), h.Create)
+	rates.Put("/:id", requireOperation(h.operationCode), h.Update)
+	rates.Delete("/:id", requireOperation(h.operationCode), h.Delete)
+}
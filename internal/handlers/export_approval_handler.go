@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ExportApprovalHandler lets department managers approve or reject exports of sensitive reports
+type ExportApprovalHandler struct {
+	BaseHandler
+
+	approvalService  service.ExportApprovalService
+	operationService service.OperationService
+	operationCode    string
+}
+
+// NewExportApprovalHandler creates a new export approval handler
+func NewExportApprovalHandler(
+	approvalService service.ExportApprovalService,
+	operationService service.OperationService,
+	operationCode string,
+) *ExportApprovalHandler {
+	if operationCode == "" {
+		operationCode = "export_approvals"
+	}
+
+	return &ExportApprovalHandler{
+		approvalService:  approvalService,
+		operationService: operationService,
+		operationCode:    operationCode,
+	}
+}
+
+// GetPending lists the exports waiting for the user's approval
+func (h *ExportApprovalHandler) GetPending(c *fiber.Ctx) error {
+	departmentID, _ := c.Locals("department_id").(int)
+	isAdmin, _ := c.Locals("is_admin").(bool)
+
+	jobs, err := h.approvalService.ListPending(c.UserContext(), departmentID, isAdmin)
+	if err != nil {
+		return errorResponse(c, "Error retrieving export requests", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		jobs,
+		"Export requests retrieved successfully",
+	))
+}
+
+// decide returns a handler that approves or rejects an export request
+func (h *ExportApprovalHandler) decide(approved bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(int)
+		departmentID, _ := c.Locals("department_id").(int)
+		isAdmin, _ := c.Locals("is_admin").(bool)
+
+		id, err := c.ParamsInt("id")
+		if err != nil || id < 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid request ID",
+				"Request ID must be a positive number",
+			))
+		}
+
+		// The note is optional, so an empty body is accepted
+		var request dto.ExportDecisionRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&request); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+					"Invalid request",
+					"Error parsing request body: "+err.Error(),
+				))
+			}
+		}
+
+		if err := utils.ValidateStruct(&request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Validation error",
+				err.Error(),
+			))
+		}
+
+		job, err := h.approvalService.Decide(c.UserContext(), userID, departmentID, isAdmin, id, approved, request.Note)
+		if err != nil {
+			if errors.Is(err, service.ErrExportRequestNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+					"Export request not found",
+					err.Error(),
+				))
+			}
+			if errors.Is(err, service.ErrSelfApproval) {
+				return c.Status(fiber.StatusForbidden).JSON(utils.ErrorResponse(
+					"Forbidden",
+					err.Error(),
+				))
+			}
+			return errorResponse(c, "Error deciding export request", err)
+		}
+
+		message := "Export request rejected"
+		if approved {
+			message = "Export request approved"
+		}
+		return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(job, message))
+	}
+}
+
+// SetupRoutes sets up the handler routes
+func (h *ExportApprovalHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+
+	approvals := router.Group("/export-approvals", requireOperation(h.operationCode))
+	approvals.Get("/", h.GetPending)
+	approvals.Post("/:id/approve", h.decide(true))
+	approvals.Post("/:id/reject", h.decide(false))
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/storage"
+	"erp-excel/internal/utils"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ExportJobHandler handles exports generated in the background
+type ExportJobHandler struct {
+	BaseHandler
+
+	exportJobService service.ExportJobService
+	downloadService  service.DownloadService
+	operationService service.OperationService
+	fileStorage      storage.Storage
+}
+
+// NewExportJobHandler creates a new export job handler
+func NewExportJobHandler(
+	exportJobService service.ExportJobService,
+	downloadService service.DownloadService,
+	operationService service.OperationService,
+	fileStorage storage.Storage,
+) *ExportJobHandler {
+	return &ExportJobHandler{
+		exportJobService: exportJobService,
+		downloadService:  downloadService,
+		operationService: operationService,
+		fileStorage:      fileStorage,
+	}
+}
+
+// submit returns a handler that queues an export of the report
+func (h *ExportJobHandler) submit(report string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(int)
+		departmentID, _ := c.Locals("department_id").(int)
+		isAdmin, _ := c.Locals("is_admin").(bool)
+
+		var request dto.DateRangeRequest
+		if err := c.BodyParser(&request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid request",
+				"Error parsing request body: "+err.Error(),
+			))
+		}
+
+		if err := utils.ValidateStruct(&request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Validation error",
+				err.Error(),
+			))
+		}
+
+		job, err := h.exportJobService.Submit(c.UserContext(), userID, departmentID, isAdmin, report, &request)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidExportJob) {
+				return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+					"Validation error",
+					err.Error(),
+				))
+			}
+			return errorResponse(c, "Error queuing export", err)
+		}
+
+		message := "Export queued successfully"
+		if job.Status == "pending_approval" {
+			message = "Export submitted for approval"
+		}
+		return c.Status(fiber.StatusAccepted).JSON(utils.SuccessResponse(job, message))
+	}
+}
+
+// Bundle exports several reports into one ZIP. A small bundle is generated and downloaded
+// straight away; a large one, or one that needs approval, is queued and answered with its job.
+// Every report needs the operation of its own export.
+func (h *ExportJobHandler) Bundle(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	departmentID, _ := c.Locals("department_id").(int)
+	isAdmin, _ := c.Locals("is_admin").(bool)
+
+	var request dto.ReportBundleRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	for _, item := range request.Reports {
+		operationCode, _ := service.ExportOperationCode(item.Report)
+		allowed, err := middleware.HasOperation(c, h.operationService, operationCode)
+		if err != nil {
+			return errorResponse(c, "Error checking permissions", err)
+		}
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).JSON(utils.CodedErrorResponse(
+				"Permission denied",
+				"You don't have permission to export "+item.Report,
+				apperror.CodePermissionDenied,
+			))
+		}
+	}
+
+	bundle, err := h.exportJobService.Bundle(c.UserContext(), userID, departmentID, isAdmin, &request)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidExportJob) {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Validation error",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error exporting reports", err)
+	}
+
+	if bundle.Job != nil {
+		message := "Export queued successfully"
+		if bundle.Job.Status == "pending_approval" {
+			message = "Export submitted for approval"
+		}
+		return c.Status(fiber.StatusAccepted).JSON(utils.SuccessResponse(bundle, message))
+	}
+
+	if bundle.DownloadURL != "" {
+		c.Set("X-Download-URL", bundle.DownloadURL)
+	}
+	return sendStoredFile(c, h.fileStorage, h.downloadService, bundle.FileName)
+}
+
+// GetAll lists the user's export jobs, newest first
+func (h *ExportJobHandler) GetAll(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	jobs, err := h.exportJobService.List(c.UserContext(), userID)
+	if err != nil {
+		return errorResponse(c, "Error retrieving export jobs", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		jobs,
+		"Export jobs retrieved successfully",
+	))
+}
+
+// GetByID returns the status of an export job
+func (h *ExportJobHandler) GetByID(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid job ID",
+			"Job ID must be a positive number",
+		))
+	}
+
+	job, err := h.exportJobService.Get(c.UserContext(), userID, id)
+	if err != nil {
+		if errors.Is(err, service.ErrExportJobNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"Export job not found",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error retrieving export job", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		job,
+		"Export job retrieved successfully",
+	))
+}
+
+// Download serves the file of a completed export job
+func (h *ExportJobHandler) Download(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid job ID",
+			"Job ID must be a positive number",
+		))
+	}
+
+	fileName, err := h.exportJobService.FileName(c.UserContext(), userID, id)
+	if err != nil {
+		if errors.Is(err, service.ErrExportJobNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"Export job not found",
+				err.Error(),
+			))
+		}
+		if errors.Is(err, service.ErrExportJobNotReady) {
+			return c.Status(fiber.StatusConflict).JSON(utils.ErrorResponse(
+				"Export not ready",
+				"The export has not completed yet",
+			))
+		}
+		return errorResponse(c, "Error retrieving file", err)
+	}
+
+	return sendStoredFile(c, h.fileStorage, h.downloadService, fileName)
+}
+
+// SetupRoutes sets up the handler routes
+func (h *ExportJobHandler) SetupRoutes(router fiber.Router) {
+	router.Post("/reports/inventory/export/async", h.submit("assistant230"))
+	router.Post("/assistants/610/export/async", h.submit("assistant610"))
+	// Checks the export operation of each report in the bundle
+	router.Post("/reports/bundle", h.Bundle)
+
+	jobs := router.Group("/reports/exports")
+	jobs.Get("/", h.GetAll)
+	jobs.Get("/:id", h.GetByID)
+	jobs.Get("/:id/file", h.Download)
+}
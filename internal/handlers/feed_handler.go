@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FeedHandler exposes report datasets as paged OData feeds for BI tools
+type FeedHandler struct {
+	BaseHandler
+
+	reportService       service.ReportService
+	assistant610Service service.Assistant610Service
+	pageSize            int
+	defaultPeriod       string
+}
+
+// NewFeedHandler creates a new feed handler
+func NewFeedHandler(
+	reportService service.ReportService,
+	assistant610Service service.Assistant610Service,
+	pageSize int,
+	defaultPeriod string,
+) *FeedHandler {
+	if pageSize <= 0 {
+		pageSize = 1000
+	}
+	if defaultPeriod == "" {
+		defaultPeriod = "30days"
+	}
+
+	return &FeedHandler{
+		reportService:       reportService,
+		assistant610Service: assistant610Service,
+		pageSize:            pageSize,
+		defaultPeriod:       defaultPeriod,
+	}
+}
+
+// ServiceDocument lists the available feeds
+func (h *FeedHandler) ServiceDocument(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"@odata.context": c.BaseURL() + "/feeds/$metadata",
+		"value": []fiber.Map{
+			{"name": "assistant230", "kind": "EntitySet", "url": "assistant230"},
+			{"name": "assistant610", "kind": "EntitySet", "url": "assistant610"},
+		},
+	})
+}
+
+// Assistant230Feed returns a page of the Sales 230 report
+func (h *FeedHandler) Assistant230Feed(c *fiber.Ctx) error {
+	request, err := h.parseDateRange(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			err.Error(),
+		))
+	}
+
+	userID, departmentID := feedCaller(c)
+	items, err := h.reportService.GetInventoryReportData(c.UserContext(), userID, departmentID, request)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error getting 230 feed data", "error", err)
+		return errorResponse(c, "Error retrieving feed data", err)
+	}
+
+	start, end, err := h.pageBounds(c, len(items))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			err.Error(),
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(h.feedPage(c, "assistant230", items[start:end], len(items), end))
+}
+
+// Assistant610Feed returns a page of the Sales 610 report
+func (h *FeedHandler) Assistant610Feed(c *fiber.Ctx) error {
+	request, err := h.parseDateRange(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			err.Error(),
+		))
+	}
+
+	userID, departmentID := feedCaller(c)
+	items, err := h.assistant610Service.GetAssistant610ReportData(c.UserContext(), userID, departmentID, request)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error getting 610 feed data", "error", err)
+		return errorResponse(c, "Error retrieving feed data", err)
+	}
+
+	start, end, err := h.pageBounds(c, len(items))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			err.Error(),
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(h.feedPage(c, "assistant610", items[start:end], len(items), end))
+}
+
+// feedCaller returns who the API key of the request acts as, so a user's key reads the rows the
+// user may see; service account keys have neither and read every row
+func feedCaller(c *fiber.Ctx) (int, int) {
+	userID, _ := c.Locals("user_id").(int)
+	departmentID, _ := c.Locals("department_id").(int)
+	return userID, departmentID
+}
+
+// parseDateRange reads from_date/to_date (YYYY-MM-DD) or period, and invoice_status, from the query string
+func (h *FeedHandler) parseDateRange(c *fiber.Ctx) (*dto.DateRangeRequest, error) {
+	request := &dto.DateRangeRequest{InvoiceStatus: dateRangeParam(c.Query, "invoice_status", "invoiceStatus")}
+	if err := utils.ValidateStruct(request); err != nil {
+		return nil, err
+	}
+
+	fromDate := dateRangeParam(c.Query, "from_date", "fromDate")
+	toDate := dateRangeParam(c.Query, "to_date", "toDate")
+	period := c.Query("period")
+
+	if fromDate != "" || toDate != "" {
+		from, err := time.Parse("2006-01-02", fromDate)
+		if err != nil {
+			return nil, fmt.Errorf("from_date must be in YYYY-MM-DD format")
+		}
+		to, err := time.Parse("2006-01-02", toDate)
+		if err != nil {
+			return nil, fmt.Errorf("to_date must be in YYYY-MM-DD format")
+		}
+		request.FromDate = &from
+		request.ToDate = &to
+		return request, nil
+	}
+
+	if period == "" {
+		period = h.defaultPeriod
+	}
+	request.Period = &period
+
+	return request, nil
+}
+
+// pageBounds resolves $skip and $top into slice bounds
+func (h *FeedHandler) pageBounds(c *fiber.Ctx, total int) (int, int, error) {
+	skip, err := strconv.Atoi(c.Query("$skip", "0"))
+	if err != nil || skip < 0 {
+		return 0, 0, fmt.Errorf("$skip must be a non-negative number")
+	}
+
+	top, err := strconv.Atoi(c.Query("$top", strconv.Itoa(h.pageSize)))
+	if err != nil || top < 0 {
+		return 0, 0, fmt.Errorf("$top must be a non-negative number")
+	}
+	if top > h.pageSize {
+		top = h.pageSize
+	}
+
+	if skip > total {
+		skip = total
+	}
+	end := skip + top
+	if end > total {
+		end = total
+	}
+
+	return skip, end, nil
+}
+
+// feedPage builds the OData response, including a next link while more rows remain
+func (h *FeedHandler) feedPage(c *fiber.Ctx, entitySet string, value interface{}, total, end int) dto.FeedResponse {
+	response := dto.FeedResponse{
+		Context: fmt.Sprintf("%s/feeds/$metadata#%s", c.BaseURL(), entitySet),
+		Value:   value,
+	}
+
+	if c.Query("$count") == "true" {
+		response.Count = &total
+	}
+
+	if end < total {
+		query := url.Values{}
+		c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+			query.Set(string(key), string(value))
+		})
+		query.Set("$skip", strconv.Itoa(end))
+		response.NextLink = fmt.Sprintf("%s%s?%s", c.BaseURL(), c.Path(), query.Encode())
+	}
+
+	return response
+}
+
+// SetupRoutes sets up the handler routes
+func (h *FeedHandler) SetupRoutes(router fiber.Router) {
+	router.Get("/", h.ServiceDocument)
+	router.Get("/assistant230", h.Assistant230Feed)
+	router.Get("/assistant610", h.Assistant610Feed)
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"bytes"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/service"
+	"erp-excel/internal/storage"
+	"erp-excel/internal/utils"
+	"errors"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// checksumHeader carries the hex encoded SHA-256 of a file so clients can verify the download
+const checksumHeader = "X-Checksum"
+
+// sendExportFile streams a generated export with its download URL, checksum and length
+func sendExportFile(c *fiber.Ctx, response *dto.ReportFileResponse) error {
+	content, ok := response.FileDetal.(*bytes.Buffer)
+	if !ok || content == nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
+			"Error exporting report",
+			"The generated file is missing",
+		))
+	}
+
+	if response.DownloadURL != "" {
+		c.Set("X-Download-URL", response.DownloadURL)
+	}
+	if response.Checksum != "" {
+		c.Set(checksumHeader, response.Checksum)
+	}
+	c.Attachment(response.FileName)
+	c.Set(fiber.HeaderContentType, fileContentType(response.FileName))
+	return c.SendStream(content, content.Len())
+}
+
+// sendLinkedFile serves a stored file through a signed download link, which only the user it was
+// issued to can follow until it expires. Administrators may download files without a link.
+func sendLinkedFile(c *fiber.Ctx, fileStorage storage.Storage, downloadService service.DownloadService, fileName string) error {
+	userID, _ := c.Locals("user_id").(int)
+	isAdmin, _ := c.Locals("is_admin").(bool)
+
+	if unescaped, err := url.PathUnescape(fileName); err == nil {
+		fileName = unescaped
+	}
+	fileName = filepath.Base(fileName)
+
+	if !isAdmin || c.Query("signature") != "" {
+		if err := utils.VerifyDownloadLink(fileName, userID, c.Query("expires"), c.Query("signature")); err != nil {
+			if errors.Is(err, utils.ErrDownloadLinkExpired) {
+				return c.Status(fiber.StatusGone).JSON(utils.ErrorResponse(
+					"Download link expired",
+					"Export the report again to get a new link",
+				))
+			}
+			return c.Status(fiber.StatusForbidden).JSON(utils.ErrorResponse(
+				"Invalid download link",
+				"The link is not valid for this file or user",
+			))
+		}
+	}
+
+	record, err := downloadService.GetFile(c.UserContext(), fileName)
+	if err != nil {
+		return errorResponse(c, "Error retrieving file", err)
+	}
+	if record != nil && record.ExpiresAt != nil && record.ExpiresAt.Before(time.Now()) {
+		return c.Status(fiber.StatusGone).JSON(utils.ErrorResponse(
+			"File expired",
+			"The file is past its retention period, export the report again",
+		))
+	}
+
+	return sendStoredFile(c, fileStorage, downloadService, fileName)
+}
+
+// sendStoredFile serves a file from file storage, redirecting to object storage when it hands out
+// presigned URLs. The checksum and length come from the export history when the file is recorded.
+func sendStoredFile(c *fiber.Ctx, fileStorage storage.Storage, downloadService service.DownloadService, fileName string) error {
+	fileName = filepath.Base(fileName)
+
+	exists, err := fileStorage.Exists(c.UserContext(), fileName)
+	if err != nil {
+		return errorResponse(c, "Error retrieving file", err)
+	}
+	if !exists {
+		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+			"File not found",
+			"The requested file does not exist",
+		))
+	}
+
+	size := -1
+	record, err := downloadService.GetFile(c.UserContext(), fileName)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error getting file record", "file", fileName, "error", err)
+	} else if record != nil {
+		size = int(record.Size)
+		if record.Checksum != "" {
+			c.Set(checksumHeader, record.Checksum)
+		}
+	}
+
+	// Object storage serves the file directly through a presigned URL
+	downloadURL, err := fileStorage.PresignedURL(c.UserContext(), fileName, 0)
+	if err != nil {
+		return errorResponse(c, "Error retrieving file", err)
+	}
+	if downloadURL != "" {
+		return c.Redirect(downloadURL, fiber.StatusTemporaryRedirect)
+	}
+
+	file, err := fileStorage.Open(c.UserContext(), fileName)
+	if err != nil {
+		return errorResponse(c, "Error retrieving file", err)
+	}
+
+	// The file on disk is authoritative for the length, e.g. for files exported before recording
+	if stat, ok := file.(interface{ Stat() (os.FileInfo, error) }); ok {
+		if info, err := stat.Stat(); err == nil {
+			size = int(info.Size())
+		}
+	}
+
+	c.Attachment(fileName)
+	c.Set(fiber.HeaderContentType, fileContentType(fileName))
+	return c.SendStream(file, size)
+}
+
+// fileContentType returns the MIME type of a generated file
+func fileContentType(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".xlsx":
+		return utils.ExcelContentType
+	case ".zip":
+		return utils.ZipContentType
+	}
+	return fiber.MIMEOctetStream
+}
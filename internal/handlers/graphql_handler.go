@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// defaultGraphQLMaxDepth limits the nesting of queries unless configured
+const defaultGraphQLMaxDepth = 8
+
+// errOperationDenied is returned for a field the user may not query
+var errOperationDenied = apperror.PermissionDenied("you don't have permission to perform this operation")
+
+// GraphQLHandler serves the GraphQL endpoint, which reads users, departments, roles, operations
+// and reports with nested data in one request. Every field checks the operation of the REST
+// endpoint returning the same data.
+type GraphQLHandler struct {
+	BaseHandler
+
+	userService         service.UserService
+	departmentService   service.DepartmentService
+	roleService         service.RoleService
+	operationService    service.OperationService
+	reportEngineService service.ReportEngineService
+	schema              graphql.Schema
+	maxDepth            int
+}
+
+// NewGraphQLHandler creates a new GraphQL handler
+func NewGraphQLHandler(
+	userService service.UserService,
+	departmentService service.DepartmentService,
+	roleService service.RoleService,
+	operationService service.OperationService,
+	reportEngineService service.ReportEngineService,
+	maxDepth int,
+) *GraphQLHandler {
+	if maxDepth <= 0 {
+		maxDepth = defaultGraphQLMaxDepth
+	}
+
+	h := &GraphQLHandler{
+		userService:         userService,
+		departmentService:   departmentService,
+		roleService:         roleService,
+		operationService:    operationService,
+		reportEngineService: reportEngineService,
+		maxDepth:            maxDepth,
+	}
+	schema, err := h.newSchema()
+	if err != nil {
+		// The schema is fixed, so this is a mistake in newSchema
+		panic(fmt.Sprintf("graphql schema: %v", err))
+	}
+	h.schema = schema
+	return h
+}
+
+// Query runs a GraphQL query sent as JSON in a POST body, or in the query string of a GET. It
+// answers 200 once the query ran, with the errors of single fields next to the data, and 400 for
+// a query rejected before it ran.
+func (h *GraphQLHandler) Query(c *fiber.Ctx) error {
+	var request dto.GraphQLRequest
+	if c.Method() == fiber.MethodGet {
+		request.Query = c.Query("query")
+		request.OperationName = c.Query("operationName")
+		if variables := c.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				return graphQLRequestError(c, "error parsing variables: "+err.Error())
+			}
+		}
+	} else if err := c.BodyParser(&request); err != nil {
+		return graphQLRequestError(c, "error parsing request body")
+	}
+
+	if strings.TrimSpace(request.Query) == "" {
+		return graphQLRequestError(c, "the request has no query")
+	}
+
+	ctx := context.WithValue(c.UserContext(), graphQLRequestKey{}, newGraphQLRequest(c))
+	result := h.execute(ctx, request)
+
+	// Execution leaves data null only when the query did not run
+	status := fiber.StatusOK
+	if result.Data == nil {
+		status = fiber.StatusBadRequest
+		for i := range result.Errors {
+			result.Errors[i].Extensions = map[string]interface{}{"code": apperror.CodeValidation}
+		}
+	}
+	return c.Status(status).JSON(result)
+}
+
+// execute parses and validates a query, checks how deeply it nests and runs it
+func (h *GraphQLHandler) execute(ctx context.Context, request dto.GraphQLRequest) *graphql.Result {
+	document, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(request.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	validation := graphql.ValidateDocument(&h.schema, document, graphql.SpecifiedRules)
+	if !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+	if depth := queryDepth(document); depth > h.maxDepth {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{
+			gqlerrors.NewFormattedError(fmt.Sprintf("the query nests more than %d levels deep", h.maxDepth)),
+		}}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           document,
+		OperationName: request.OperationName,
+		Args:          request.Variables,
+		Context:       ctx,
+	})
+}
+
+// queryDepth returns how deeply the fields of a document nest, the fields of an operation being
+// at depth 1. Introspection fields are left out, as they cannot reach the data of the services.
+func queryDepth(document *ast.Document) int {
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	depth := 0
+	for _, definition := range document.Definitions {
+		if operation, ok := definition.(*ast.OperationDefinition); ok {
+			depth = max(depth, selectionDepth(operation.SelectionSet, fragments))
+		}
+	}
+	return depth
+}
+
+// selectionDepth returns the depth of a selection set. Validation has rejected fragment cycles.
+func selectionDepth(set *ast.SelectionSet, fragments map[string]*ast.FragmentDefinition) int {
+	if set == nil {
+		return 0
+	}
+
+	depth := 0
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if !strings.HasPrefix(selection.Name.Value, "__") {
+				depth = max(depth, 1+selectionDepth(selection.SelectionSet, fragments))
+			}
+		case *ast.InlineFragment:
+			depth = max(depth, selectionDepth(selection.SelectionSet, fragments))
+		case *ast.FragmentSpread:
+			if fragment, ok := fragments[selection.Name.Value]; ok {
+				depth = max(depth, selectionDepth(fragment.SelectionSet, fragments))
+			}
+		}
+	}
+	return depth
+}
+
+// Schema returns the schema in the GraphQL schema definition language
+func (h *GraphQLHandler) Schema(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+	return c.Status(fiber.StatusOK).SendString(schemaSDL(h.schema))
+}
+
+// graphQLRequestError answers a request that is not a GraphQL request, in the shape of a GraphQL
+// response so clients read it like any other error
+func graphQLRequestError(c *fiber.Ctx, message string) error {
+	formatted := gqlerrors.NewFormattedError(message)
+	formatted.Extensions = map[string]interface{}{"code": apperror.CodeValidation}
+	return c.Status(fiber.StatusBadRequest).JSON(&graphql.Result{Errors: []gqlerrors.FormattedError{formatted}})
+}
+
+// fieldError is the error of a field, which graphql-go answers with its extensions
+type fieldError struct {
+	message string
+	code    string
+}
+
+func (e *fieldError) Error() string {
+	return e.message
+}
+
+func (e *fieldError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+// graphQLError presents the error of a field with the code errorResponse would answer it with
+func graphQLError(ctx context.Context, err error) error {
+	message, code := err.Error(), apperror.CodeInternal
+	if coded, ok := apperror.As(err); ok {
+		code = coded.Code
+		if coded.Detail != "" {
+			message = coded.Detail
+		}
+	} else {
+		slog.ErrorContext(ctx, "Error resolving GraphQL field", "error", err)
+	}
+
+	return &fieldError{message: message, code: code}
+}
+
+type graphQLRequestKey struct{}
+
+// graphQLRequest is the state the resolvers of a request share: the Fiber context the permission
+// checks read, and the checks and records already loaded, so a list of users does not check the
+// same operation or load the same department once per user
+type graphQLRequest struct {
+	c           *fiber.Ctx
+	access      map[string]error
+	departments map[int]*dto.DepartmentResponse
+	roles       map[int]*dto.RoleResponse
+	operations  map[int]*dto.OperationResponse
+}
+
+func newGraphQLRequest(c *fiber.Ctx) *graphQLRequest {
+	return &graphQLRequest{
+		c:           c,
+		access:      make(map[string]error),
+		departments: make(map[int]*dto.DepartmentResponse),
+		roles:       make(map[int]*dto.RoleResponse),
+	}
+}
+
+func graphQLRequestFrom(ctx context.Context) *graphQLRequest {
+	return ctx.Value(graphQLRequestKey{}).(*graphQLRequest)
+}
+
+// require checks an operation the same way RoleCheckMiddleware does
+func (h *GraphQLHandler) require(ctx context.Context, operationCode string) error {
+	request := graphQLRequestFrom(ctx)
+	if err, ok := request.access[operationCode]; ok {
+		return err
+	}
+
+	allowed, err := middleware.HasOperation(request.c, h.operationService, operationCode)
+	if err == nil && !allowed {
+		err = errOperationDenied
+	}
+	request.access[operationCode] = err
+	return err
+}
+
+// requireReport checks the operation of a report, as the REST report routes do
+func (h *GraphQLHandler) requireReport(ctx context.Context, code string) error {
+	definition, err := h.reportEngineService.GetDefinition(ctx, code)
+	if err != nil {
+		return err
+	}
+	return h.require(ctx, definition.OperationCode)
+}
+
+func (h *GraphQLHandler) department(ctx context.Context, id int) (*dto.DepartmentResponse, error) {
+	request := graphQLRequestFrom(ctx)
+	if department, ok := request.departments[id]; ok {
+		return department, nil
+	}
+
+	department, err := h.departmentService.GetDepartmentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	request.departments[id] = department
+	return department, nil
+}
+
+func (h *GraphQLHandler) role(ctx context.Context, id int) (*dto.RoleResponse, error) {
+	request := graphQLRequestFrom(ctx)
+	if role, ok := request.roles[id]; ok {
+		return role, nil
+	}
+
+	role, err := h.roleService.GetRoleByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	request.roles[id] = role
+	return role, nil
+}
+
+func (h *GraphQLHandler) operation(ctx context.Context, id int) (*dto.OperationResponse, error) {
+	request := graphQLRequestFrom(ctx)
+	if request.operations == nil {
+		operations, err := h.operationService.GetAllOperations(ctx)
+		if err != nil {
+			return nil, err
+		}
+		request.operations = make(map[int]*dto.OperationResponse, len(operations))
+		for _, operation := range operations {
+			request.operations[operation.ID] = operation
+		}
+	}
+	return request.operations[id], nil
+}
+
+// SetupRoutes sets up the handler routes
+func (h *GraphQLHandler) SetupRoutes(router fiber.Router) {
+	router.Get("/graphql", h.Query)
+	router.Post("/graphql", h.Query)
+	router.Get("/graphql/schema", h.Schema)
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"erp-excel/internal/apperror"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// newGraphQLTestApp serves a handler without services, which only the me field of a request
+// without a user can run
+func newGraphQLTestApp(maxDepth int) *fiber.App {
+	app := fiber.New()
+	NewGraphQLHandler(nil, nil, nil, nil, nil, maxDepth).SetupRoutes(app)
+	return app
+}
+
+func TestGraphQLQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "executed",
+			body:       `{"query": "{ me { id department { name } } }"}`,
+			wantStatus: fiber.StatusOK,
+			wantBody:   `{"data":{"me":null}}`,
+		},
+		{
+			name:       "variables and fragments",
+			body:       `{"query": "query Q($id: Int!) { ...F } fragment F on Query { me { id } user(id: $id) @skip(if: true) { id } }", "variables": {"id": 1}}`,
+			wantStatus: fiber.StatusOK,
+			wantBody:   `{"data":{"me":null}}`,
+		},
+		{
+			name:       "at the depth limit",
+			body:       `{"query": "{ me { department { parent { id } } } }"}`,
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "too deep",
+			body:       `{"query": "{ me { department { parent { parent { id } } } } }"}`,
+			wantStatus: fiber.StatusBadRequest,
+			wantBody:   "the query nests more than 4 levels deep",
+		},
+		{
+			name:       "too deep through a fragment",
+			body:       `{"query": "{ me { ...U } } fragment U on User { department { parent { parent { id } } } }"}`,
+			wantStatus: fiber.StatusBadRequest,
+			wantBody:   "the query nests more than 4 levels deep",
+		},
+		{
+			name:       "introspection left out of the depth",
+			body:       `{"query": "{ __schema { types { fields { type { ofType { ofType { name } } } } } } }"}`,
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "unknown field",
+			body:       `{"query": "{ me { password } }"}`,
+			wantStatus: fiber.StatusBadRequest,
+			wantBody:   `Cannot query field \"password\" on type \"User\".`,
+		},
+		{
+			name:       "syntax error",
+			body:       `{"query": "{ me { id }"}`,
+			wantStatus: fiber.StatusBadRequest,
+			wantBody:   `"code":"` + apperror.CodeValidation + `"`,
+		},
+		{
+			name:       "missing variable",
+			body:       `{"query": "query Q($id: Int!) { user(id: $id) { id } }"}`,
+			wantStatus: fiber.StatusBadRequest,
+			wantBody:   `"code":"` + apperror.CodeValidation + `"`,
+		},
+		{
+			name:       "no query",
+			body:       `{"query": " "}`,
+			wantStatus: fiber.StatusBadRequest,
+			wantBody:   "the request has no query",
+		},
+	}
+
+	app := newGraphQLTestApp(4)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(fiber.MethodPost, "/graphql", strings.NewReader(tt.body))
+			request.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			response, err := app.Test(request)
+			if err != nil {
+				t.Fatalf("app.Test() error = %v", err)
+			}
+			body, _ := io.ReadAll(response.Body)
+
+			if response.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", response.StatusCode, tt.wantStatus, body)
+			}
+			if !json.Valid(body) {
+				t.Errorf("body is not JSON: %s", body)
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestGraphQLError(t *testing.T) {
+	err := graphQLError(t.Context(), apperror.PermissionDenied("no access"))
+	extended, ok := err.(interface{ Extensions() map[string]interface{} })
+	if !ok || err.Error() != "no access" || extended.Extensions()["code"] != apperror.PermissionDenied("").Code {
+		t.Errorf("graphQLError() = %v, want the message and code of the application error", err)
+	}
+}
+
+func TestGraphQLSchemaSDL(t *testing.T) {
+	response, err := newGraphQLTestApp(0).Test(httptest.NewRequest(fiber.MethodGet, "/graphql/schema", nil))
+	if err != nil {
+		t.Fatalf("app.Test() error = %v", err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	sdl := string(body)
+
+	for _, want := range []string{
+		"scalar JSON\nscalar Time\n\nschema {\n  query: Query\n}\n\ntype Query {\n",
+		"  department(id: Int!): Department\n",
+		"  users(\n    include_deleted: Boolean\n    \"At most 100\"\n    limit: Int = 10\n    page: Int = 1\n  ): [User!]\n",
+		"\"A report of the report engine\"\ntype Report {\n",
+		"  run(\n    from_date: Time\n    \"Values of the report parameters by name, as strings\"\n    params: JSON\n    period: String\n    to_date: Time\n  ): ReportRun\n",
+		"  role_names: [String!]!\n",
+	} {
+		if !strings.Contains(sdl, want) {
+			t.Errorf("SDL does not contain %q:\n%s", want, sdl)
+		}
+	}
+	if strings.Contains(sdl, "__") || strings.Contains(sdl, "scalar String") {
+		t.Errorf("SDL should leave out introspection and built-in types:\n%s", sdl)
+	}
+}
@@ -0,0 +1,574 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/utils"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// graphQLTime is the Time scalar: times are written as RFC 3339 strings, the zero time as null,
+// and time arguments are passed on as the strings of the REST request bodies
+var graphQLTime = graphql.NewScalar(graphql.ScalarConfig{
+	Name: "Time",
+	Serialize: func(value interface{}) interface{} {
+		if t, ok := value.(*time.Time); ok && t != nil {
+			value = *t
+		}
+		if t, ok := value.(time.Time); ok && !t.IsZero() {
+			return t.Format(time.RFC3339Nano)
+		}
+		return nil
+	},
+	ParseValue: func(value interface{}) interface{} {
+		if text, ok := value.(string); ok {
+			return text
+		}
+		return nil
+	},
+	ParseLiteral: func(value ast.Value) interface{} {
+		if text, ok := value.(*ast.StringValue); ok {
+			return text.Value
+		}
+		return nil
+	},
+})
+
+// graphQLJSON is the JSON scalar, whose values are written and read as they are
+var graphQLJSON = graphql.NewScalar(graphql.ScalarConfig{
+	Name:         "JSON",
+	Serialize:    func(value interface{}) interface{} { return value },
+	ParseValue:   func(value interface{}) interface{} { return value },
+	ParseLiteral: graphQLLiteral,
+})
+
+// graphQLLiteral returns the value of a JSON literal of a query
+func graphQLLiteral(value ast.Value) interface{} {
+	switch literal := value.(type) {
+	case *ast.StringValue:
+		return literal.Value
+	case *ast.EnumValue:
+		return literal.Value
+	case *ast.BooleanValue:
+		return literal.Value
+	case *ast.IntValue:
+		number, _ := strconv.ParseInt(literal.Value, 10, 64)
+		return number
+	case *ast.FloatValue:
+		number, _ := strconv.ParseFloat(literal.Value, 64)
+		return number
+	case *ast.ListValue:
+		list := make([]interface{}, 0, len(literal.Values))
+		for _, item := range literal.Values {
+			list = append(list, graphQLLiteral(item))
+		}
+		return list
+	case *ast.ObjectValue:
+		object := make(map[string]interface{}, len(literal.Fields))
+		for _, field := range literal.Fields {
+			object[field.Name.Value] = graphQLLiteral(field.Value)
+		}
+		return object
+	}
+	return nil
+}
+
+// newSchema builds the GraphQL schema. Fields are named after the JSON fields of the REST
+// responses, so both APIs read alike; the fields added for nesting, such as User.department,
+// check the operation of the REST endpoint returning their data. Fields that check an operation
+// are nullable, so one the user may not read leaves the rest of the response intact.
+func (h *GraphQLHandler) newSchema() (graphql.Schema, error) {
+	operation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Operation",
+		Fields: graphql.Fields{
+			"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"name":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"code":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"description": &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	var user, department, role *graphql.Object
+	department = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Department",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":                   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"name":                 &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"code":                 &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"description":          &graphql.Field{Type: graphql.String},
+				"parent_department_id": &graphql.Field{Type: graphql.Int},
+				"parent":               &graphql.Field{Type: department, Resolve: graphQLResolver(h.resolveDepartmentParent)},
+				"is_active":            &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+				"user_count":           &graphql.Field{Type: graphql.Int},
+				"total_user_count":     &graphql.Field{Type: graphql.Int, Description: "Users of the department and every department below it"},
+				"deleted_at":           &graphql.Field{Type: graphQLTime},
+			}
+		}),
+	})
+
+	role = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Role",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":             &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"name":           &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"description":    &graphql.Field{Type: graphql.String},
+				"parent_role_id": &graphql.Field{Type: graphql.Int},
+				"parent":         &graphql.Field{Type: role, Resolve: graphQLResolver(h.resolveRoleParent)},
+				"created_at":     &graphql.Field{Type: graphql.NewNonNull(graphQLTime)},
+				"updated_at":     &graphql.Field{Type: graphql.NewNonNull(graphQLTime)},
+				"operation_ids":  &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.Int)))},
+				"operations":     &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(operation)), Resolve: graphQLResolver(h.resolveRoleOperations)},
+				"user_count":     &graphql.Field{Type: graphql.Int},
+				"users": &graphql.Field{
+					Description: "The users that hold the role directly",
+					Type:        graphql.NewList(graphql.NewNonNull(user)),
+					Args:        pageArgs(),
+					Resolve:     graphQLResolver(h.resolveRoleUsers),
+				},
+				"deleted_at": &graphql.Field{Type: graphQLTime},
+			}
+		}),
+	})
+
+	user = graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":            &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"username":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"full_name":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"email":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"phone":         &graphql.Field{Type: graphql.String},
+			"department_id": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"department_name": &graphql.Field{
+				Description: "The name of the department, which needs no departments:read",
+				Type:        graphql.String,
+				Resolve:     graphQLResolver(resolveUserDepartmentName),
+			},
+			"department": &graphql.Field{Type: department, Resolve: graphQLResolver(h.resolveUserDepartment)},
+			"is_active":  &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"created_at": &graphql.Field{Type: graphql.NewNonNull(graphQLTime)},
+			"updated_at": &graphql.Field{Type: graphql.NewNonNull(graphQLTime)},
+			"last_login": &graphql.Field{Type: graphQLTime},
+			"deleted_at": &graphql.Field{Type: graphQLTime},
+			"role_names": &graphql.Field{
+				Description: "The names of the roles, which need no roles:read",
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+				Resolve:     graphQLResolver(resolveUserRoleNames),
+			},
+			"roles": &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(role)), Resolve: graphQLResolver(h.resolveUserRoles)},
+		},
+	})
+
+	reportParameter := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ReportParameter",
+		Fields: graphql.Fields{
+			"name":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"type":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"required": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"default":  &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	reportRun := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ReportRun",
+		Fields: graphql.Fields{
+			"code":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"report_name":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"generated_at": &graphql.Field{Type: graphql.NewNonNull(graphQLTime)},
+			"columns":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+			"items":        &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphQLJSON))), Description: "The rows, as objects keyed by column"},
+			"row_count":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"truncated":    &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Description: "The report stopped at its row limit"},
+		},
+	})
+
+	report := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Report",
+		Description: "A report of the report engine",
+		Fields: graphql.Fields{
+			"code":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"name":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"description": &graphql.Field{Type: graphql.String},
+			"uses_dates":  &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"parameters":  &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(reportParameter)))},
+			"columns":     &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+			"run": &graphql.Field{
+				Description: "Runs the report, with the arguments of POST /api/reports/:code",
+				Type:        reportRun,
+				Args: graphql.FieldConfigArgument{
+					"from_date": &graphql.ArgumentConfig{Type: graphQLTime},
+					"to_date":   &graphql.ArgumentConfig{Type: graphQLTime},
+					"period":    &graphql.ArgumentConfig{Type: graphql.String},
+					"params":    &graphql.ArgumentConfig{Type: graphQLJSON, Description: "Values of the report parameters by name, as strings"},
+				},
+				Resolve: graphQLResolver(h.resolveReportRun),
+			},
+		},
+	})
+
+	idArgs := graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)}}
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me": &graphql.Field{
+				Description: "The current user",
+				Type:        user,
+				Resolve:     graphQLResolver(h.resolveMe),
+			},
+			"users": &graphql.Field{
+				Type: graphql.NewList(graphql.NewNonNull(user)),
+				Args: withArgs(pageArgs(), graphql.FieldConfigArgument{
+					"include_deleted": &graphql.ArgumentConfig{Type: graphql.Boolean},
+				}),
+				Resolve: graphQLResolver(h.resolveUsers),
+			},
+			"user":        &graphql.Field{Type: user, Args: idArgs, Resolve: graphQLResolver(h.resolveUser)},
+			"departments": &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(department)), Args: pageArgs(), Resolve: graphQLResolver(h.resolveDepartments)},
+			"department":  &graphql.Field{Type: department, Args: idArgs, Resolve: graphQLResolver(h.resolveDepartment)},
+			"roles":       &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(role)), Args: pageArgs(), Resolve: graphQLResolver(h.resolveRoles)},
+			"role":        &graphql.Field{Type: role, Args: idArgs, Resolve: graphQLResolver(h.resolveRole)},
+			"operations":  &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(operation)), Resolve: graphQLResolver(h.resolveOperations)},
+			"reports": &graphql.Field{
+				Description: "The reports the current user can run",
+				Type:        graphql.NewList(graphql.NewNonNull(report)),
+				Resolve:     graphQLResolver(h.resolveReports),
+			},
+			"report": &graphql.Field{
+				Type:    report,
+				Args:    graphql.FieldConfigArgument{"code": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: graphQLResolver(h.resolveReport),
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+// schemaSDL writes a schema in the GraphQL schema definition language, for client tooling and
+// code generators. Types and fields are sorted by name, as graphql-go keeps no order.
+func schemaSDL(schema graphql.Schema) string {
+	var scalars, objects []string
+	for name, named := range schema.TypeMap() {
+		switch named.(type) {
+		case *graphql.Scalar:
+			if name != graphQLTime.Name() && name != graphQLJSON.Name() {
+				continue
+			}
+			scalars = append(scalars, name)
+		case *graphql.Object:
+			if strings.HasPrefix(name, "__") || name == schema.QueryType().Name() {
+				continue
+			}
+			objects = append(objects, name)
+		}
+	}
+	sort.Strings(scalars)
+	sort.Strings(objects)
+
+	var sdl strings.Builder
+	for _, name := range scalars {
+		fmt.Fprintf(&sdl, "scalar %s\n", name)
+	}
+	fmt.Fprintf(&sdl, "\nschema {\n  query: %s\n}\n", schema.QueryType().Name())
+
+	for _, name := range append([]string{schema.QueryType().Name()}, objects...) {
+		object := schema.Type(name).(*graphql.Object)
+		sdl.WriteString("\n")
+		writeDescription(&sdl, "", object.Description())
+		fmt.Fprintf(&sdl, "type %s {\n", name)
+
+		fields := object.Fields()
+		fieldNames := make([]string, 0, len(fields))
+		for fieldName := range fields {
+			fieldNames = append(fieldNames, fieldName)
+		}
+		sort.Strings(fieldNames)
+		for _, fieldName := range fieldNames {
+			field := fields[fieldName]
+			writeDescription(&sdl, "  ", field.Description)
+			sdl.WriteString("  " + field.Name)
+			if len(field.Args) > 0 {
+				args := make([]*graphql.Argument, len(field.Args))
+				copy(args, field.Args)
+				sort.Slice(args, func(i, j int) bool { return args[i].Name() < args[j].Name() })
+
+				// Arguments go one per line when one of them has a description
+				separator, end := ", ", ")"
+				for _, arg := range args {
+					if arg.Description() != "" {
+						separator, end = "\n    ", "\n  )"
+					}
+				}
+				sdl.WriteString("(")
+				for i, arg := range args {
+					if i > 0 || separator != ", " {
+						sdl.WriteString(separator)
+					}
+					if arg.Description() != "" {
+						fmt.Fprintf(&sdl, "%q%s", arg.Description(), separator)
+					}
+					sdl.WriteString(arg.Name() + ": " + arg.Type.String())
+					if arg.DefaultValue != nil {
+						fmt.Fprintf(&sdl, " = %#v", arg.DefaultValue)
+					}
+				}
+				sdl.WriteString(end)
+			}
+			sdl.WriteString(": " + field.Type.String() + "\n")
+		}
+		sdl.WriteString("}\n")
+	}
+	return sdl.String()
+}
+
+func writeDescription(sdl *strings.Builder, indent, description string) {
+	if description != "" {
+		fmt.Fprintf(sdl, "%s%q\n", indent, description)
+	}
+}
+
+// graphQLResolver adapts a resolver to graphql-go, presenting its errors with graphQLError
+func graphQLResolver(resolve func(ctx context.Context, p graphql.ResolveParams) (interface{}, error)) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		value, err := resolve(p.Context, p)
+		if err != nil {
+			return nil, graphQLError(p.Context, err)
+		}
+		return value, nil
+	}
+}
+
+// pageArgs are the arguments of a paged list, as the page and limit query parameters of REST
+func pageArgs() graphql.FieldConfigArgument {
+	return graphql.FieldConfigArgument{
+		"page":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1},
+		"limit": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 10, Description: "At most 100"},
+	}
+}
+
+// withArgs returns the arguments of both sets
+func withArgs(args, more graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	for name, arg := range more {
+		args[name] = arg
+	}
+	return args
+}
+
+// graphQLPage returns the limit and offset of a paged list, with the defaults of the REST lists
+func graphQLPage(p graphql.ResolveParams) (int, int) {
+	page, _ := p.Args["page"].(int)
+	limit, _ := p.Args["limit"].(int)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+	return limit, (page - 1) * limit
+}
+
+func (h *GraphQLHandler) resolveMe(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	userID, _ := graphQLRequestFrom(ctx).c.Locals("user_id").(int)
+	if userID == 0 {
+		// API keys of no user
+		return nil, nil
+	}
+	return h.userService.GetUserByID(ctx, userID)
+}
+
+func (h *GraphQLHandler) resolveUsers(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	if err := h.require(ctx, "users:read"); err != nil {
+		return nil, err
+	}
+	limit, offset := graphQLPage(p)
+	includeDeleted, _ := p.Args["include_deleted"].(bool)
+	return h.userService.GetAllUsers(ctx, limit, offset, includeDeleted)
+}
+
+func (h *GraphQLHandler) resolveUser(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	if err := h.require(ctx, "users:read"); err != nil {
+		return nil, err
+	}
+	return h.userService.GetUserByID(ctx, p.Args["id"].(int))
+}
+
+func resolveUserDepartmentName(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	return p.Source.(*dto.UserResponse).Department, nil
+}
+
+func resolveUserRoleNames(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	return p.Source.(*dto.UserResponse).Roles, nil
+}
+
+func (h *GraphQLHandler) resolveUserDepartment(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	user := p.Source.(*dto.UserResponse)
+	if user.DepartmentID == 0 {
+		return nil, nil
+	}
+	if err := h.require(ctx, "departments:read"); err != nil {
+		return nil, err
+	}
+	return h.department(ctx, user.DepartmentID)
+}
+
+func (h *GraphQLHandler) resolveUserRoles(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	if err := h.require(ctx, "roles:read"); err != nil {
+		return nil, err
+	}
+
+	assigned, err := h.userService.GetUserRoles(ctx, p.Source.(*dto.UserResponse).ID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]*dto.RoleResponse, 0, len(assigned))
+	for _, role := range assigned {
+		role, err := h.role(ctx, role.ID)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+func (h *GraphQLHandler) resolveDepartments(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	if err := h.require(ctx, "departments:read"); err != nil {
+		return nil, err
+	}
+	limit, offset := graphQLPage(p)
+	return h.departmentService.GetAllDepartments(ctx, limit, offset)
+}
+
+func (h *GraphQLHandler) resolveDepartment(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	if err := h.require(ctx, "departments:read"); err != nil {
+		return nil, err
+	}
+	return h.department(ctx, p.Args["id"].(int))
+}
+
+func (h *GraphQLHandler) resolveDepartmentParent(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	department := p.Source.(*dto.DepartmentResponse)
+	if department.ParentDepartmentID == nil {
+		return nil, nil
+	}
+	return h.department(ctx, *department.ParentDepartmentID)
+}
+
+func (h *GraphQLHandler) resolveRoles(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	if err := h.require(ctx, "roles:read"); err != nil {
+		return nil, err
+	}
+	limit, offset := graphQLPage(p)
+	return h.roleService.GetAllRoles(ctx, limit, offset)
+}
+
+func (h *GraphQLHandler) resolveRole(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	if err := h.require(ctx, "roles:read"); err != nil {
+		return nil, err
+	}
+	return h.role(ctx, p.Args["id"].(int))
+}
+
+func (h *GraphQLHandler) resolveRoleParent(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	role := p.Source.(*dto.RoleResponse)
+	if role.ParentRoleID == nil {
+		return nil, nil
+	}
+	return h.role(ctx, *role.ParentRoleID)
+}
+
+func (h *GraphQLHandler) resolveRoleOperations(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	if err := h.require(ctx, "operations:read"); err != nil {
+		return nil, err
+	}
+
+	role := p.Source.(*dto.RoleResponse)
+	operations := make([]*dto.OperationResponse, 0, len(role.OperationIDs))
+	for _, id := range role.OperationIDs {
+		operation, err := h.operation(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if operation != nil {
+			operations = append(operations, operation)
+		}
+	}
+	return operations, nil
+}
+
+func (h *GraphQLHandler) resolveRoleUsers(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	if err := h.require(ctx, "roles:read"); err != nil {
+		return nil, err
+	}
+	limit, offset := graphQLPage(p)
+	return h.roleService.GetRoleUsers(ctx, p.Source.(*dto.RoleResponse).ID, limit, offset)
+}
+
+func (h *GraphQLHandler) resolveOperations(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	if err := h.require(ctx, "operations:read"); err != nil {
+		return nil, err
+	}
+	return h.operationService.GetAllOperations(ctx)
+}
+
+func (h *GraphQLHandler) resolveReports(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	c := graphQLRequestFrom(ctx).c
+	userID, _ := c.Locals("user_id").(int)
+	isAdmin, _ := c.Locals("is_admin").(bool)
+
+	reports, err := h.reportEngineService.ListReports(ctx, userID, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*dto.ReportDefinitionResponse, 0, len(reports))
+	for i := range reports {
+		response = append(response, &reports[i])
+	}
+	return response, nil
+}
+
+func (h *GraphQLHandler) resolveReport(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	code := p.Args["code"].(string)
+	if err := h.requireReport(ctx, code); err != nil {
+		return nil, err
+	}
+	return h.reportEngineService.GetReport(ctx, code)
+}
+
+func (h *GraphQLHandler) resolveReportRun(ctx context.Context, p graphql.ResolveParams) (interface{}, error) {
+	code := p.Source.(*dto.ReportDefinitionResponse).Code
+	if err := h.requireReport(ctx, code); err != nil {
+		return nil, err
+	}
+
+	// The arguments are the fields of the REST request body, parsed the same way
+	body, err := json.Marshal(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	var request dto.ReportRunRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, fmt.Errorf("%w: %v", apperror.ErrValidation, err)
+	}
+	if err := utils.ValidateStruct(&request); err != nil {
+		return nil, err
+	}
+
+	c := graphQLRequestFrom(ctx).c
+	userID, _ := c.Locals("user_id").(int)
+	departmentID, _ := c.Locals("department_id").(int)
+	return h.reportEngineService.RunReport(ctx, userID, departmentID, code, &request, c.IP())
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"erp-excel/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HealthHandler serves the health check used by load balancers
+type HealthHandler struct {
+	BaseHandler
+
+	healthService service.HealthService
+}
+
+// NewHealthHandler creates a new health handler
+func NewHealthHandler(healthService service.HealthService) *HealthHandler {
+	return &HealthHandler{
+		healthService: healthService,
+	}
+}
+
+// Check probes the databases and answers 503 when one of them is down, so the instance is taken
+// out of rotation
+func (h *HealthHandler) Check(c *fiber.Ctx) error {
+	result := h.healthService.Check(c.UserContext())
+
+	status := fiber.StatusOK
+	if result.Status != service.HealthStatusOK {
+		status = fiber.StatusServiceUnavailable
+	}
+	return c.Status(status).JSON(result)
+}
+
+// SetupRoutes registers the health check on the root router, outside authentication
+func (h *HealthHandler) SetupRoutes(router fiber.Router) {
+	router.Get("/health", h.Check)
+}
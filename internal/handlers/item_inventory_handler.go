@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ItemInventoryHandler handles the item-level inventory report
+type ItemInventoryHandler struct {
+	BaseHandler
+
+	itemInventoryService service.ItemInventoryService
+	operationService     service.OperationService
+	operationCode        string
+}
+
+// NewItemInventoryHandler creates a new item inventory handler
+func NewItemInventoryHandler(
+	itemInventoryService service.ItemInventoryService,
+	operationService service.OperationService,
+	operationCode string,
+) *ItemInventoryHandler {
+	if operationCode == "" {
+		operationCode = "item_inventory"
+	}
+
+	return &ItemInventoryHandler{
+		itemInventoryService: itemInventoryService,
+		operationService:     operationService,
+		operationCode:        operationCode,
+	}
+}
+
+// GetItemInventoryData returns quantity in/out/balance per item and warehouse
+func (h *ItemInventoryHandler) GetItemInventoryData(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	var request dto.ItemInventoryRequest
+	if err := c.BodyParser(&request); err != nil {
+		slog.WarnContext(c.UserContext(), "Error parsing request body for item inventory", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	items, err := h.itemInventoryService.GetItemInventoryData(c.UserContext(), userID, &request, c.IP())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error getting item inventory data", "error", err)
+		return errorResponse(c, "Error retrieving report data", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		dto.ItemInventoryDataResponse{
+			ReportName: fmt.Sprintf("Inventory from %s to %s",
+				request.FromDate.Format("02/01/2006"),
+				request.ToDate.Format("02/01/2006"),
+			),
+			GeneratedAt: time.Now(),
+			Items:       items,
+		},
+		"Report data retrieved successfully",
+	))
+}
+
+// ExportItemInventory streams the item inventory as an Excel file
+func (h *ItemInventoryHandler) ExportItemInventory(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	departmentID, _ := c.Locals("department_id").(int)
+
+	var request dto.ItemInventoryRequest
+	if err := c.BodyParser(&request); err != nil {
+		slog.WarnContext(c.UserContext(), "Error parsing request body for item inventory export", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	reportFileResponse, err := h.itemInventoryService.ExportItemInventory(c.UserContext(), userID, departmentID, &request, c.IP())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error exporting item inventory", "error", err)
+		return errorResponse(c, "Error exporting report", err)
+	}
+
+	return sendExportFile(c, reportFileResponse)
+}
+
+// SetupRoutes sets up the handler routes
+func (h *ItemInventoryHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+	inventory := router.Group("/reports/items", requireOperation(h.operationCode))
+
+	inventory.Post("/inventory", h.GetItemInventoryData)
+	inventory.Post("/inventory/export", h.ExportItemInventory)
+}
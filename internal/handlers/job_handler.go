@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// JobHandler lets administrators follow the background jobs and retry or cancel them
+type JobHandler struct {
+	BaseHandler
+
+	jobService       service.JobService
+	operationService service.OperationService
+	operationCode    string
+}
+
+// NewJobHandler creates a new job handler
+func NewJobHandler(
+	jobService service.JobService,
+	operationService service.OperationService,
+	operationCode string,
+) *JobHandler {
+	if operationCode == "" {
+		operationCode = "jobs"
+	}
+
+	return &JobHandler{
+		jobService:       jobService,
+		operationService: operationService,
+		operationCode:    operationCode,
+	}
+}
+
+// GetAll lists the newest jobs. Filters: status (pending, running, completed, failed or
+// cancelled), type and limit.
+func (h *JobHandler) GetAll(c *fiber.Ctx) error {
+	filter := repository.JobFilter{
+		Status: c.Query("status"),
+		Type:   c.Query("type"),
+		Limit:  c.QueryInt("limit", 0),
+	}
+
+	jobs, err := h.jobService.List(c.UserContext(), filter)
+	if err != nil {
+		return errorResponse(c, "Error retrieving jobs", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		jobs,
+		"Jobs retrieved successfully",
+	))
+}
+
+// Get gets a job
+func (h *JobHandler) Get(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid ID",
+			"Job ID must be a number",
+		))
+	}
+
+	job, err := h.jobService.Get(c.UserContext(), id)
+	if err != nil {
+		return errorResponse(c, "Error retrieving job", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		job,
+		"Job retrieved successfully",
+	))
+}
+
+// Retry queues a failed or cancelled job again with a fresh count of attempts
+func (h *JobHandler) Retry(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid ID",
+			"Job ID must be a number",
+		))
+	}
+
+	if err := h.jobService.Retry(c.UserContext(), id); err != nil {
+		return errorResponse(c, "Error retrying job", err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(utils.SuccessResponse(
+		nil,
+		"Job queued successfully",
+	))
+}
+
+// Cancel cancels a pending or running job
+func (h *JobHandler) Cancel(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid ID",
+			"Job ID must be a number",
+		))
+	}
+
+	if err := h.jobService.Cancel(c.UserContext(), id); err != nil {
+		return errorResponse(c, "Error cancelling job", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Job cancelled successfully",
+	))
+}
+
+// SetupRoutes sets up the handler routes
+func (h *JobHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+	jobs := router.Group("/admin/jobs", requireOperation(h.operationCode))
+
+	jobs.Get("/", h.GetAll)
+	jobs.Get("/:id", h.Get)
+	jobs.Post("/:id/retry", h.Retry)
+	jobs.Post("/:id/cancel", h.Cancel)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"erp-excel/internal/metrics"
+	"erp-excel/internal/utils"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MetricsHandler serves the metrics in the Prometheus text format for scraping
+type MetricsHandler struct {
+	BaseHandler
+
+	registry *metrics.Registry
+	token    string
+}
+
+// NewMetricsHandler creates a new metrics handler. Scrapers must send the token as a bearer token
+// unless it is empty.
+func NewMetricsHandler(registry *metrics.Registry, token string) *MetricsHandler {
+	return &MetricsHandler{
+		registry: registry,
+		token:    token,
+	}
+}
+
+// GetMetrics writes every metric of the registry
+func (h *MetricsHandler) GetMetrics(c *fiber.Ctx) error {
+	if h.token != "" {
+		token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			return c.Status(fiber.StatusUnauthorized).JSON(utils.ErrorResponse(
+				"Invalid token",
+				"The metrics require a valid bearer token",
+			))
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+	_, err := h.registry.WriteTo(c)
+	return err
+}
+
+// SetupRoutes registers the metrics on the root router, outside the API authentication
+func (h *MetricsHandler) SetupRoutes(router fiber.Router) {
+	router.Get("/metrics", h.GetMetrics)
+}
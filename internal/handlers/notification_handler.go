@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// NotificationHandler serves the in-app notifications of the signed-in user
+type NotificationHandler struct {
+	BaseHandler
+
+	notificationService service.NotificationService
+}
+
+// NewNotificationHandler creates a new notification handler
+func NewNotificationHandler(notificationService service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+	}
+}
+
+// GetAll lists a page of the user's notifications, newest first; unread=true lists only the
+// unread ones
+func (h *NotificationHandler) GetAll(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	// Parse pagination parameters
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+	unreadOnly := c.QueryBool("unread")
+
+	notifications, err := h.notificationService.List(c.UserContext(), userID, unreadOnly, limit, offset)
+	if err != nil {
+		return errorResponse(c, "Error retrieving notifications", err)
+	}
+
+	total, err := h.notificationService.Count(c.UserContext(), userID, unreadOnly)
+	if err != nil {
+		return errorResponse(c, "Error counting notifications", err)
+	}
+
+	// Calculate pagination info
+	totalPages := (total + limit - 1) / limit
+	hasNext := page < totalPages
+	hasPrev := page > 1
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		fiber.Map{
+			"notifications": notifications,
+			"pagination": fiber.Map{
+				"total":       total,
+				"page":        page,
+				"limit":       limit,
+				"total_pages": totalPages,
+				"has_next":    hasNext,
+				"has_prev":    hasPrev,
+			},
+		},
+		"Notifications retrieved successfully",
+	))
+}
+
+// GetUnreadCount returns how many of the user's notifications are unread
+func (h *NotificationHandler) GetUnreadCount(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	count, err := h.notificationService.Count(c.UserContext(), userID, true)
+	if err != nil {
+		return errorResponse(c, "Error counting notifications", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		fiber.Map{"count": count},
+		"Unread notifications counted successfully",
+	))
+}
+
+// MarkRead marks a notification as read
+func (h *NotificationHandler) MarkRead(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid notification ID",
+			"Notification ID must be a positive number",
+		))
+	}
+
+	if err := h.notificationService.MarkRead(c.UserContext(), userID, id); err != nil {
+		if errors.Is(err, service.ErrNotificationNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"Notification not found",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error updating notification", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Notification marked as read",
+	))
+}
+
+// MarkAllRead marks every notification of the user as read
+func (h *NotificationHandler) MarkAllRead(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	count, err := h.notificationService.MarkAllRead(c.UserContext(), userID)
+	if err != nil {
+		return errorResponse(c, "Error updating notifications", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		fiber.Map{"count": count},
+		"Notifications marked as read",
+	))
+}
+
+// SetupRoutes sets up the handler routes
+func (h *NotificationHandler) SetupRoutes(router fiber.Router) {
+	notifications := router.Group("/notifications")
+	notifications.Get("/", h.GetAll)
+	notifications.Get("/unread-count", h.GetUnreadCount)
+	notifications.Post("/read-all", h.MarkAllRead)
+	notifications.Post("/:id/read", h.MarkRead)
+}
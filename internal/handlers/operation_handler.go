@@ -24,12 +24,9 @@ func NewOperationHandler(operationService service.OperationService) *OperationHa
 
 // GetAllOperations retrieves all operations
 func (h *OperationHandler) GetAllOperations(c *fiber.Ctx) error {
-	operations, err := h.operationService.GetAllOperations(c.Context())
+	operations, err := h.operationService.GetAllOperations(c.UserContext())
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error retrieving operations",
-			err.Error(),
-		))
+		return errorResponse(c, "Error retrieving operations", err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
@@ -59,12 +56,9 @@ func (h *OperationHandler) CheckUserAccess(c *fiber.Ctx) error {
 	}
 
 	// Check user access
-	hasAccess, err := h.operationService.CheckUserAccess(c.Context(), userID, operationCode)
+	hasAccess, err := h.operationService.CheckUserAccess(c.UserContext(), userID, operationCode)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error checking user access",
-			err.Error(),
-		))
+		return errorResponse(c, "Error checking user access", err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
@@ -104,17 +98,14 @@ func (h *OperationHandler) LogAccess(c *fiber.Ctx) error {
 
 	// Log access
 	logID, err := h.operationService.LogAccess(
-		c.Context(),
+		c.UserContext(),
 		requestBody.UserID,
 		requestBody.OperationCode,
 		requestBody.Params,
 		ipAddress,
 	)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error logging access",
-			err.Error(),
-		))
+		return errorResponse(c, "Error logging access", err)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(utils.SuccessResponse(
@@ -157,12 +148,9 @@ func (h *OperationHandler) UpdateLogStatus(c *fiber.Ctx) error {
 	}
 
 	// Update log status
-	updated, err := h.operationService.UpdateLogStatus(c.Context(), logID, requestBody.Status)
+	updated, err := h.operationService.UpdateLogStatus(c.UserContext(), logID, requestBody.Status)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error updating log status",
-			err.Error(),
-		))
+		return errorResponse(c, "Error updating log status", err)
 	}
 
 	if !updated {
@@ -184,12 +172,9 @@ func (h *OperationHandler) GetRecentLogs(c *fiber.Ctx) error {
 	limit, _ := strconv.Atoi(c.Query("limit", "10"))
 
 	// Get recent logs
-	logs, err := h.operationService.GetRecentLogs(c.Context(), limit)
+	logs, err := h.operationService.GetRecentLogs(c.UserContext(), limit)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error retrieving recent logs",
-			err.Error(),
-		))
+		return errorResponse(c, "Error retrieving recent logs", err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
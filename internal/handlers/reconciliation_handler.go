@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReconciliationHandler handles the 230 vs 610 reconciliation report
+type ReconciliationHandler struct {
+	BaseHandler
+
+	reconciliationService service.ReconciliationService
+}
+
+// NewReconciliationHandler creates a new reconciliation handler
+func NewReconciliationHandler(reconciliationService service.ReconciliationService) *ReconciliationHandler {
+	return &ReconciliationHandler{
+		reconciliationService: reconciliationService,
+	}
+}
+
+// GetReconciliation lists shipping documents that are uninvoiced or invoiced with a different amount
+func (h *ReconciliationHandler) GetReconciliation(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	var request dto.ReconciliationRequest
+	if err := c.BodyParser(&request); err != nil {
+		slog.WarnContext(c.UserContext(), "Error parsing request body for reconciliation", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	response, err := h.reconciliationService.GetReconciliation(c.UserContext(), userID, &request)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error getting reconciliation", "error", err)
+		return errorResponse(c, "Error retrieving report data", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		response,
+		"Report data retrieved successfully",
+	))
+}
+
+// ExportReconciliation streams the reconciliation as an Excel file with mismatches highlighted
+func (h *ReconciliationHandler) ExportReconciliation(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	departmentID, _ := c.Locals("department_id").(int)
+
+	var request dto.ReconciliationRequest
+	if err := c.BodyParser(&request); err != nil {
+		slog.WarnContext(c.UserContext(), "Error parsing request body for reconciliation export", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	reportFileResponse, err := h.reconciliationService.ExportReconciliation(c.UserContext(), userID, departmentID, &request)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error exporting reconciliation", "error", err)
+		return errorResponse(c, "Error exporting report", err)
+	}
+
+	return sendExportFile(c, reportFileResponse)
+}
+
+// SetupRoutes sets up the handler routes
+func (h *ReconciliationHandler) SetupRoutes(router fiber.Router) {
+	reports := router.Group("/reports")
+
+	reports.Post("/reconciliation", h.GetReconciliation)
+	reports.Post("/reconciliation/export", h.ExportReconciliation)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReportAnnotationHandler imports notes edited in exported report files
+type ReportAnnotationHandler struct {
+	BaseHandler
+
+	reportAnnotationService service.ReportAnnotationService
+	operationService        service.OperationService
+	operationCode           string
+}
+
+// NewReportAnnotationHandler creates a new report annotation handler
+func NewReportAnnotationHandler(
+	reportAnnotationService service.ReportAnnotationService,
+	operationService service.OperationService,
+	operationCode string,
+) *ReportAnnotationHandler {
+	if operationCode == "" {
+		operationCode = "note_import"
+	}
+
+	return &ReportAnnotationHandler{
+		reportAnnotationService: reportAnnotationService,
+		operationService:        operationService,
+		operationCode:           operationCode,
+	}
+}
+
+// importNotes returns a handler importing the notes of one report. The multipart form carries the
+// edited export as "file" and the date range it was exported for as period or fromDate/toDate.
+func (h *ReportAnnotationHandler) importNotes(report string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(int)
+		departmentID, _ := c.Locals("department_id").(int)
+
+		request, err := parseFormDateRange(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid request",
+				err.Error(),
+			))
+		}
+
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid request",
+				"An Excel file is required in the file field",
+			))
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid request",
+				"Error reading uploaded file",
+			))
+		}
+		defer file.Close()
+
+		response, err := h.reportAnnotationService.ImportNotes(c.UserContext(), userID, departmentID, report, request, file, c.IP())
+		if err != nil {
+			slog.ErrorContext(c.UserContext(), "Error importing notes", "report", report, "error", err)
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Error importing notes",
+				err.Error(),
+			))
+		}
+
+		return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+			response,
+			"Notes imported successfully",
+		))
+	}
+}
+
+// parseFormDateRange reads a date range from form values; dates use YYYY-MM-DD
+func parseFormDateRange(c *fiber.Ctx) (*dto.DateRangeRequest, error) {
+	var request dto.DateRangeRequest
+
+	if period := c.FormValue("period"); period != "" {
+		request.Period = &period
+		return &request, nil
+	}
+
+	fromDate, err := time.Parse("2006-01-02", dateRangeParam(c.FormValue, "from_date", "fromDate"))
+	if err != nil {
+		return nil, errors.New("from_date must be a date (YYYY-MM-DD) when period is not given")
+	}
+	toDate, err := time.Parse("2006-01-02", dateRangeParam(c.FormValue, "to_date", "toDate"))
+	if err != nil {
+		return nil, errors.New("to_date must be a date (YYYY-MM-DD) when period is not given")
+	}
+	request.FromDate = &fromDate
+	request.ToDate = &toDate
+
+	return &request, nil
+}
+
+// dateRangeParam reads a date range parameter of a form or query string by its snake_case name,
+// falling back to its deprecated camelCase spelling, as dto.DateRangeRequest does for JSON
+func dateRangeParam(value func(key string, defaultValue ...string) string, name, legacy string) string {
+	return value(name, value(legacy))
+}
+
+// SetupRoutes sets up the handler routes
+func (h *ReportAnnotationHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+
+	router.Post("/reports/inventory/notes/import", requireOperation(h.operationCode), h.importNotes("assistant230"))
+	router.Post("/reports/assistant610/notes/import", requireOperation(h.operationCode), h.importNotes("assistant610"))
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+
+	"erp-excel/internal/dto"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReportComparisonHandler compares the 230/610 reports across two date ranges
+type ReportComparisonHandler struct {
+	BaseHandler
+
+	comparisonService service.ReportComparisonService
+}
+
+// NewReportComparisonHandler creates a new report comparison handler
+func NewReportComparisonHandler(comparisonService service.ReportComparisonService) *ReportComparisonHandler {
+	return &ReportComparisonHandler{comparisonService: comparisonService}
+}
+
+// CompareReport returns the report data of both ranges and the deltas per group key
+func (h *ReportComparisonHandler) CompareReport(report string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(int)
+		departmentID, _ := c.Locals("department_id").(int)
+
+		var request dto.ReportComparisonRequest
+		if err := c.BodyParser(&request); err != nil {
+			slog.WarnContext(c.UserContext(), "Error parsing request body for report comparison", "error", err)
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid request",
+				"Error parsing request body: "+err.Error(),
+			))
+		}
+
+		if err := utils.ValidateStruct(&request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Validation error",
+				err.Error(),
+			))
+		}
+
+		comparison, err := h.comparisonService.CompareReport(c.UserContext(), userID, departmentID, report, &request)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidComparison):
+				return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+					"Invalid comparison",
+					err.Error(),
+				))
+			case errors.Is(err, service.ErrInvalidColumn):
+				return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+					"Invalid column",
+					err.Error(),
+				))
+			}
+
+			slog.ErrorContext(c.UserContext(), "Error comparing report periods", "report", report, "error", err)
+			return errorResponse(c, "Error comparing report periods", err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+			comparison,
+			"Report comparison retrieved successfully",
+		))
+	}
+}
+
+func (h *ReportComparisonHandler) SetupRoutes(router fiber.Router) {
+	router.Post("/reports/inventory/compare", h.CompareReport("assistant230"))
+	router.Post("/assistants/610/compare", h.CompareReport("assistant610"))
+}
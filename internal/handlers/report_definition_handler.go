@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReportDefinitionHandler lets admins manage custom SQL reports
+type ReportDefinitionHandler struct {
+	BaseHandler
+
+	reportDefinitionService service.ReportDefinitionService
+	operationService        service.OperationService
+	operationCode           string
+}
+
+// NewReportDefinitionHandler creates a new report definition handler
+func NewReportDefinitionHandler(
+	reportDefinitionService service.ReportDefinitionService,
+	operationService service.OperationService,
+	operationCode string,
+) *ReportDefinitionHandler {
+	if operationCode == "" {
+		operationCode = "report_admin"
+	}
+
+	return &ReportDefinitionHandler{
+		reportDefinitionService: reportDefinitionService,
+		operationService:        operationService,
+		operationCode:           operationCode,
+	}
+}
+
+// GetAll returns every custom report definition
+func (h *ReportDefinitionHandler) GetAll(c *fiber.Ctx) error {
+	definitions, err := h.reportDefinitionService.List(c.UserContext())
+	if err != nil {
+		return errorResponse(c, "Error retrieving report definitions", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		definitions,
+		"Report definitions retrieved successfully",
+	))
+}
+
+// GetByCode returns a custom report definition
+func (h *ReportDefinitionHandler) GetByCode(c *fiber.Ctx) error {
+	definition, err := h.reportDefinitionService.Get(c.UserContext(), c.Params("code"))
+	if err != nil {
+		return reportDefinitionError(c, "Error retrieving report definition", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		definition,
+		"Report definition retrieved successfully",
+	))
+}
+
+// Create registers a custom report definition
+func (h *ReportDefinitionHandler) Create(c *fiber.Ctx) error {
+	var request dto.ReportDefinitionRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body",
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	userID, _ := c.Locals("user_id").(int)
+	definition, err := h.reportDefinitionService.Create(c.UserContext(), userID, &request)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Error creating report definition",
+			err.Error(),
+		))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.SuccessResponse(
+		definition,
+		"Report definition created successfully",
+	))
+}
+
+// Update replaces a custom report definition
+func (h *ReportDefinitionHandler) Update(c *fiber.Ctx) error {
+	var request dto.ReportDefinitionRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body",
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	definition, err := h.reportDefinitionService.Update(c.UserContext(), c.Params("code"), &request)
+	if err != nil {
+		if errors.Is(err, service.ErrReportNotFound) {
+			return reportDefinitionError(c, "", err)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Error updating report definition",
+			err.Error(),
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		definition,
+		"Report definition updated successfully",
+	))
+}
+
+// Delete removes a custom report definition
+func (h *ReportDefinitionHandler) Delete(c *fiber.Ctx) error {
+	if err := h.reportDefinitionService.Delete(c.UserContext(), c.Params("code")); err != nil {
+		return reportDefinitionError(c, "Error deleting report definition", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Report definition deleted successfully",
+	))
+}
+
+// reportDefinitionError maps an unknown report to 404 and anything else to 500
+func reportDefinitionError(c *fiber.Ctx, message string, err error) error {
+	if errors.Is(err, service.ErrReportNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+			"Report not found",
+			"The requested report definition does not exist",
+		))
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
+		message,
+		err.Error(),
+	))
+}
+
+// SetupRoutes sets up the handler routes
+func (h *ReportDefinitionHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+	definitions := router.Group("/admin/reports", requireOperation(h.operationCode))
+
+	definitions.Get("/", h.GetAll)
+	definitions.Get("/:code", h.GetByCode)
+	definitions.Post("/", h.Create)
+	definitions.Put("/:code", h.Update)
+	definitions.Delete("/:code", h.Delete)
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReportEngineHandler serves the reports registered with the generic report engine
+type ReportEngineHandler struct {
+	BaseHandler
+
+	reportEngineService service.ReportEngineService
+	operationService    service.OperationService
+}
+
+// NewReportEngineHandler creates a new report engine handler
+func NewReportEngineHandler(
+	reportEngineService service.ReportEngineService,
+	operationService service.OperationService,
+) *ReportEngineHandler {
+	return &ReportEngineHandler{
+		reportEngineService: reportEngineService,
+		operationService:    operationService,
+	}
+}
+
+// ListReports returns the reports the current user can run
+func (h *ReportEngineHandler) ListReports(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	isAdmin, _ := c.Locals("is_admin").(bool)
+
+	reports, err := h.reportEngineService.ListReports(c.UserContext(), userID, isAdmin)
+	if err != nil {
+		return errorResponse(c, "Error retrieving reports", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		reports,
+		"Reports retrieved successfully",
+	))
+}
+
+// RunReport returns the data of a report
+func (h *ReportEngineHandler) RunReport(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	departmentID, _ := c.Locals("department_id").(int)
+
+	request, err := parseReportRunRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			err.Error(),
+		))
+	}
+
+	response, err := h.reportEngineService.RunReport(c.UserContext(), userID, departmentID, c.Params("code"), request, c.IP())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error running report", "report", c.Params("code"), "error", err)
+		return errorResponse(c, "Error retrieving report data", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		response,
+		"Report data retrieved successfully",
+	))
+}
+
+// ExportReport streams a report as an Excel file
+func (h *ReportEngineHandler) ExportReport(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	departmentID, _ := c.Locals("department_id").(int)
+
+	request, err := parseReportRunRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			err.Error(),
+		))
+	}
+
+	reportFileResponse, err := h.reportEngineService.ExportReport(c.UserContext(), userID, departmentID, c.Params("code"), request, c.IP())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error exporting report", "report", c.Params("code"), "error", err)
+		return errorResponse(c, "Error exporting report", err)
+	}
+
+	return sendExportFile(c, reportFileResponse)
+}
+
+// PreviewReport returns the first rows of a report with the resolved parameters
+func (h *ReportEngineHandler) PreviewReport(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	departmentID, _ := c.Locals("department_id").(int)
+
+	request, err := parseReportRunRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			err.Error(),
+		))
+	}
+
+	preview, err := h.reportEngineService.PreviewReport(c.UserContext(), userID, departmentID, c.Params("code"), request)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error previewing report", "report", c.Params("code"), "error", err)
+		return errorResponse(c, "Error retrieving report preview", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		preview,
+		"Report preview retrieved successfully",
+	))
+}
+
+// parseReportRunRequest reads and validates the request body
+func parseReportRunRequest(c *fiber.Ctx) (*dto.ReportRunRequest, error) {
+	var request dto.ReportRunRequest
+	if err := c.BodyParser(&request); err != nil {
+		return nil, fmt.Errorf("error parsing request body: %w", err)
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+// requireReportAccess checks the operation code of the requested report
+func (h *ReportEngineHandler) requireReportAccess(c *fiber.Ctx) error {
+	definition, err := h.reportEngineService.GetDefinition(c.UserContext(), c.Params("code"))
+	if err != nil {
+		if errors.Is(err, service.ErrReportNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"Report not found",
+				"The requested report does not exist",
+			))
+		}
+		return errorResponse(c, "Error retrieving report", err)
+	}
+
+	if isAdmin, _ := c.Locals("is_admin").(bool); isAdmin {
+		return c.Next()
+	}
+	if principal, ok := c.Locals("api_key").(*service.APIKeyPrincipal); ok {
+		if !principal.Allows(definition.OperationCode) {
+			return c.Status(fiber.StatusForbidden).JSON(utils.CodedErrorResponse(
+				"Permission denied",
+				"The API key is not scoped to this operation",
+				apperror.CodePermissionDenied,
+			))
+		}
+		if principal.UserID == 0 {
+			return c.Next()
+		}
+	}
+
+	userID, _ := c.Locals("user_id").(int)
+	hasAccess, err := h.operationService.CheckUserAccess(c.UserContext(), userID, definition.OperationCode)
+	if err != nil {
+		return errorResponse(c, "Error checking permissions", err)
+	}
+	if !hasAccess {
+		return c.Status(fiber.StatusForbidden).JSON(utils.CodedErrorResponse(
+			"Permission denied",
+			"You don't have permission to perform this operation",
+			apperror.CodePermissionDenied,
+		))
+	}
+
+	return c.Next()
+}
+
+// SetupRoutes sets up the handler routes. It has to be registered after the handlers with fixed
+// /reports routes, since /reports/:code matches any report code.
+func (h *ReportEngineHandler) SetupRoutes(router fiber.Router) {
+	reports := router.Group("/reports")
+
+	reports.Get("/definitions", h.ListReports)
+	reports.Post("/:code", h.requireReportAccess, h.RunReport)
+	reports.Post("/:code/export", h.requireReportAccess, h.ExportReport)
+	reports.Post("/:code/preview", h.requireReportAccess, h.PreviewReport)
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReportHistoryHandler handles the user's recent report runs and starred reports
+type ReportHistoryHandler struct {
+	BaseHandler
+
+	reportHistoryService service.ReportHistoryService
+}
+
+// NewReportHistoryHandler creates a new report history handler
+func NewReportHistoryHandler(reportHistoryService service.ReportHistoryService) *ReportHistoryHandler {
+	return &ReportHistoryHandler{
+		reportHistoryService: reportHistoryService,
+	}
+}
+
+// GetHistory lists the user's recent report runs with the parameters to run them again
+func (h *ReportHistoryHandler) GetHistory(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	entries, err := h.reportHistoryService.History(c.UserContext(), userID, c.QueryInt("limit", 0))
+	if err != nil {
+		return errorResponse(c, "Error retrieving report history", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		entries,
+		"Report history retrieved successfully",
+	))
+}
+
+// GetFavorites lists the reports the user starred
+func (h *ReportHistoryHandler) GetFavorites(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	favorites, err := h.reportHistoryService.Favorites(c.UserContext(), userID)
+	if err != nil {
+		return errorResponse(c, "Error retrieving favorites", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		favorites,
+		"Favorites retrieved successfully",
+	))
+}
+
+// ToggleFavorite stars a report, or unstars it when it already is
+func (h *ReportHistoryHandler) ToggleFavorite(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	favorite, err := h.reportHistoryService.ToggleFavorite(c.UserContext(), userID, c.Params("report"))
+	if err != nil {
+		if errors.Is(err, service.ErrReportNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"Report not found",
+				"The requested report does not exist",
+			))
+		}
+		return errorResponse(c, "Error updating favorite", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		favorite,
+		"Favorite updated successfully",
+	))
+}
+
+// SetupRoutes sets up the handler routes. History and favorites are the user's own, so any
+// authenticated user can read them.
+func (h *ReportHistoryHandler) SetupRoutes(router fiber.Router) {
+	router.Get("/reports/history", h.GetHistory)
+	router.Get("/reports/favorites", h.GetFavorites)
+	router.Post("/reports/favorites/:report", h.ToggleFavorite)
+}
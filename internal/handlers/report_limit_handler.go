@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReportLimitHandler manages the per-report guardrails: max months, max rows and max export size
+type ReportLimitHandler struct {
+	BaseHandler
+
+	limitService     service.ReportLimitService
+	operationService service.OperationService
+	operationCode    string
+}
+
+// NewReportLimitHandler creates a new report limit handler
+func NewReportLimitHandler(
+	limitService service.ReportLimitService,
+	operationService service.OperationService,
+	operationCode string,
+) *ReportLimitHandler {
+	if operationCode == "" {
+		operationCode = "report_admin"
+	}
+
+	return &ReportLimitHandler{
+		limitService:     limitService,
+		operationService: operationService,
+		operationCode:    operationCode,
+	}
+}
+
+// GetAll returns the effective limits of the reports
+func (h *ReportLimitHandler) GetAll(c *fiber.Ctx) error {
+	limits, err := h.limitService.List(c.UserContext())
+	if err != nil {
+		return errorResponse(c, "Error retrieving report limits", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		limits,
+		"Report limits retrieved successfully",
+	))
+}
+
+// Set creates or replaces the limit of a report, overriding its configured one
+func (h *ReportLimitHandler) Set(c *fiber.Ctx) error {
+	var request dto.ReportLimitRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body",
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	userID, _ := c.Locals("user_id").(int)
+	limit, err := h.limitService.Set(c.UserContext(), userID, c.Params("report"), &request)
+	if err != nil {
+		if errors.Is(err, service.ErrReportNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"Report not found",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error saving report limit", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		limit,
+		"Report limit saved successfully",
+	))
+}
+
+// Delete removes the limit set at runtime of a report, restoring its configured one
+func (h *ReportLimitHandler) Delete(c *fiber.Ctx) error {
+	if err := h.limitService.Delete(c.UserContext(), c.Params("report")); err != nil {
+		if errors.Is(err, service.ErrReportLimitNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"Report limit not found",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error deleting report limit", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Report limit deleted successfully",
+	))
+}
+
+// Reload re-reads the limits from the database, e.g. after another instance changed them
+func (h *ReportLimitHandler) Reload(c *fiber.Ctx) error {
+	if err := h.limitService.Reload(c.UserContext()); err != nil {
+		return errorResponse(c, "Error reloading report limits", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Report limits reloaded successfully",
+	))
+}
+
+// SetupRoutes sets up the handler routes
+func (h *ReportLimitHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+	limits := router.Group("/admin/report-limits", requireOperation(h.operationCode))
+
+	limits.Get("/", h.GetAll)
+	limits.Post("/reload", h.Reload)
+	limits.Put("/:report", h.Set)
+	limits.Delete("/:report", h.Delete)
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// parseReportPage reads the page, limit and sort query parameters of the report data endpoints.
+// Without a limit every row is returned, as before the endpoints were paginated.
+func parseReportPage(c *fiber.Ctx) dto.ReportPageRequest {
+	page := dto.ReportPageRequest{
+		Page:  c.QueryInt("page", 1),
+		Limit: c.QueryInt("limit", 0),
+		Sort:  c.Query("sort"),
+	}
+
+	if page.Page < 1 {
+		page.Page = 1
+	}
+	if page.Limit < 0 {
+		page.Limit = 0
+	}
+	if page.Limit > service.MaxReportPageSize {
+		page.Limit = service.MaxReportPageSize
+	}
+
+	return page
+}
+
+// reportDataResponse adds pagination metadata to report data when a page was requested
+func reportDataResponse(data interface{}, page dto.ReportPageRequest, total int) fiber.Map {
+	if page.Limit > 0 {
+		return utils.PaginatedResponse(data, page.Page, page.Limit, total, "Report data retrieved successfully")
+	}
+	return utils.SuccessResponse(data, "Report data retrieved successfully")
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReportPresetHandler handles the report presets users save for recurring pulls
+type ReportPresetHandler struct {
+	BaseHandler
+
+	reportPresetService service.ReportPresetService
+}
+
+// NewReportPresetHandler creates a new report preset handler
+func NewReportPresetHandler(reportPresetService service.ReportPresetService) *ReportPresetHandler {
+	return &ReportPresetHandler{
+		reportPresetService: reportPresetService,
+	}
+}
+
+// GetAll lists the user's presets
+func (h *ReportPresetHandler) GetAll(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	presets, err := h.reportPresetService.List(c.UserContext(), userID)
+	if err != nil {
+		return errorResponse(c, "Error retrieving presets", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		presets,
+		"Presets retrieved successfully",
+	))
+}
+
+// GetByID returns one preset
+func (h *ReportPresetHandler) GetByID(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid preset ID",
+			"Preset ID must be a positive number",
+		))
+	}
+
+	preset, err := h.reportPresetService.Get(c.UserContext(), userID, id)
+	if err != nil {
+		return presetError(c, err, "Error retrieving preset")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		preset,
+		"Preset retrieved successfully",
+	))
+}
+
+// Create saves a preset for the current user
+func (h *ReportPresetHandler) Create(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	var request dto.ReportPresetRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	preset, err := h.reportPresetService.Create(c.UserContext(), userID, &request)
+	if err != nil {
+		return presetError(c, err, "Error creating preset")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.SuccessResponse(
+		preset,
+		"Preset created successfully",
+	))
+}
+
+// Update replaces the name, report and parameters of a preset
+func (h *ReportPresetHandler) Update(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid preset ID",
+			"Preset ID must be a positive number",
+		))
+	}
+
+	var request dto.ReportPresetRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	preset, err := h.reportPresetService.Update(c.UserContext(), userID, id, &request)
+	if err != nil {
+		return presetError(c, err, "Error updating preset")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		preset,
+		"Preset updated successfully",
+	))
+}
+
+// Delete removes a preset
+func (h *ReportPresetHandler) Delete(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid preset ID",
+			"Preset ID must be a positive number",
+		))
+	}
+
+	if err := h.reportPresetService.Delete(c.UserContext(), userID, id); err != nil {
+		return presetError(c, err, "Error deleting preset")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Preset deleted successfully",
+	))
+}
+
+// presetError maps preset service errors to responses
+func presetError(c *fiber.Ctx, err error, message string) error {
+	if errors.Is(err, service.ErrReportPresetNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+			"Preset not found",
+			err.Error(),
+		))
+	}
+	if errors.Is(err, service.ErrReportPresetExists) {
+		return c.Status(fiber.StatusConflict).JSON(utils.ErrorResponse(
+			"Preset already exists",
+			err.Error(),
+		))
+	}
+	if errors.Is(err, service.ErrInvalidReportPreset) {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
+		message,
+		err.Error(),
+	))
+}
+
+// SetupRoutes sets up the handler routes. Presets belong to the user who saved them, so any
+// authenticated user can manage their own.
+func (h *ReportPresetHandler) SetupRoutes(router fiber.Router) {
+	presets := router.Group("/reports/presets")
+
+	presets.Get("/", h.GetAll)
+	presets.Post("/", h.Create)
+	presets.Get("/:id", h.GetByID)
+	presets.Put("/:id", h.Update)
+	presets.Delete("/:id", h.Delete)
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReportScheduleHandler handles scheduled report delivery by email
+type ReportScheduleHandler struct {
+	BaseHandler
+
+	reportScheduleService service.ReportScheduleService
+	operationService      service.OperationService
+	operationCode         string
+}
+
+// NewReportScheduleHandler creates a new report schedule handler
+func NewReportScheduleHandler(
+	reportScheduleService service.ReportScheduleService,
+	operationService service.OperationService,
+	operationCode string,
+) *ReportScheduleHandler {
+	if operationCode == "" {
+		operationCode = "report_schedules"
+	}
+
+	return &ReportScheduleHandler{
+		reportScheduleService: reportScheduleService,
+		operationService:      operationService,
+		operationCode:         operationCode,
+	}
+}
+
+// GetAll lists the user's schedules, or every schedule for an administrator
+func (h *ReportScheduleHandler) GetAll(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	isAdmin, _ := c.Locals("is_admin").(bool)
+
+	schedules, err := h.reportScheduleService.List(c.UserContext(), userID, isAdmin)
+	if err != nil {
+		return errorResponse(c, "Error retrieving schedules", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		schedules,
+		"Schedules retrieved successfully",
+	))
+}
+
+// GetByID returns one schedule
+func (h *ReportScheduleHandler) GetByID(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	isAdmin, _ := c.Locals("is_admin").(bool)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid schedule ID",
+			"Schedule ID must be a positive number",
+		))
+	}
+
+	schedule, err := h.reportScheduleService.Get(c.UserContext(), userID, isAdmin, id)
+	if err != nil {
+		return scheduleError(c, err, "Error retrieving schedule")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		schedule,
+		"Schedule retrieved successfully",
+	))
+}
+
+// Create adds a schedule owned by the current user
+func (h *ReportScheduleHandler) Create(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	departmentID, _ := c.Locals("department_id").(int)
+	isAdmin, _ := c.Locals("is_admin").(bool)
+
+	var request dto.ReportScheduleRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	schedule, err := h.reportScheduleService.Create(c.UserContext(), userID, departmentID, isAdmin, &request)
+	if err != nil {
+		return scheduleError(c, err, "Error creating schedule")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.SuccessResponse(
+		schedule,
+		"Schedule created successfully",
+	))
+}
+
+// Update replaces the settings of a schedule
+func (h *ReportScheduleHandler) Update(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	isAdmin, _ := c.Locals("is_admin").(bool)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid schedule ID",
+			"Schedule ID must be a positive number",
+		))
+	}
+
+	var request dto.ReportScheduleRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	schedule, err := h.reportScheduleService.Update(c.UserContext(), userID, isAdmin, id, &request)
+	if err != nil {
+		return scheduleError(c, err, "Error updating schedule")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		schedule,
+		"Schedule updated successfully",
+	))
+}
+
+// Delete removes a schedule and its run history
+func (h *ReportScheduleHandler) Delete(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	isAdmin, _ := c.Locals("is_admin").(bool)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid schedule ID",
+			"Schedule ID must be a positive number",
+		))
+	}
+
+	if err := h.reportScheduleService.Delete(c.UserContext(), userID, isAdmin, id); err != nil {
+		return scheduleError(c, err, "Error deleting schedule")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Schedule deleted successfully",
+	))
+}
+
+// GetRuns lists the newest deliveries of a schedule
+func (h *ReportScheduleHandler) GetRuns(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	isAdmin, _ := c.Locals("is_admin").(bool)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid schedule ID",
+			"Schedule ID must be a positive number",
+		))
+	}
+
+	runs, err := h.reportScheduleService.ListRuns(c.UserContext(), userID, isAdmin, id)
+	if err != nil {
+		return scheduleError(c, err, "Error retrieving schedule runs")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		runs,
+		"Schedule runs retrieved successfully",
+	))
+}
+
+// Run delivers a schedule immediately, e.g. to check its recipients
+func (h *ReportScheduleHandler) Run(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	isAdmin, _ := c.Locals("is_admin").(bool)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid schedule ID",
+			"Schedule ID must be a positive number",
+		))
+	}
+
+	run, err := h.reportScheduleService.RunNow(c.UserContext(), userID, isAdmin, id)
+	if err != nil {
+		return scheduleError(c, err, "Error running schedule")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		run,
+		"Schedule run finished",
+	))
+}
+
+// scheduleError maps schedule service errors to responses
+func scheduleError(c *fiber.Ctx, err error, message string) error {
+	if errors.Is(err, service.ErrReportScheduleNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+			"Schedule not found",
+			err.Error(),
+		))
+	}
+	if errors.Is(err, service.ErrInvalidReportSchedule) {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+	return errorResponse(c, message, err)
+}
+
+// SetupRoutes sets up the handler routes
+func (h *ReportScheduleHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+	schedules := router.Group("/reports/schedules", requireOperation(h.operationCode))
+
+	schedules.Get("/", h.GetAll)
+	schedules.Post("/", h.Create)
+	schedules.Get("/:id", h.GetByID)
+	schedules.Put("/:id", h.Update)
+	schedules.Delete("/:id", h.Delete)
+	schedules.Get("/:id/runs", h.GetRuns)
+	schedules.Post("/:id/run", h.Run)
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"errors"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReportSnapshotHandler handles persistent report snapshots
+type ReportSnapshotHandler struct {
+	BaseHandler
+
+	reportSnapshotService service.ReportSnapshotService
+	operationService      service.OperationService
+	operationCode         string
+}
+
+// NewReportSnapshotHandler creates a new report snapshot handler
+func NewReportSnapshotHandler(
+	reportSnapshotService service.ReportSnapshotService,
+	operationService service.OperationService,
+	operationCode string,
+) *ReportSnapshotHandler {
+	if operationCode == "" {
+		operationCode = "report_snapshots"
+	}
+
+	return &ReportSnapshotHandler{
+		reportSnapshotService: reportSnapshotService,
+		operationService:      operationService,
+		operationCode:         operationCode,
+	}
+}
+
+// Create runs a report and stores its result as a snapshot
+func (h *ReportSnapshotHandler) Create(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	departmentID, _ := c.Locals("department_id").(int)
+	isAdmin, _ := c.Locals("is_admin").(bool)
+
+	var request dto.ReportSnapshotRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	snapshot, err := h.reportSnapshotService.Create(c.UserContext(), userID, departmentID, isAdmin, &request, c.IP())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error creating report snapshot", "report", request.Report, "error", err)
+		if errors.Is(err, service.ErrReportNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"Report not found",
+				"The requested report does not exist",
+			))
+		}
+		if errors.Is(err, service.ErrInvalidColumn) {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid column",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error creating snapshot", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.SuccessResponse(
+		snapshot,
+		"Snapshot created successfully",
+	))
+}
+
+// GetAll lists the latest snapshots, filtered by ?report= and limited by ?limit=
+func (h *ReportSnapshotHandler) GetAll(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	isAdmin, _ := c.Locals("is_admin").(bool)
+
+	snapshots, err := h.reportSnapshotService.List(c.UserContext(), userID, isAdmin, c.Query("report"), c.QueryInt("limit", 0))
+	if err != nil {
+		return errorResponse(c, "Error retrieving snapshots", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		snapshots,
+		"Snapshots retrieved successfully",
+	))
+}
+
+// GetByID re-opens a snapshot with all of its rows
+func (h *ReportSnapshotHandler) GetByID(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	isAdmin, _ := c.Locals("is_admin").(bool)
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid ID",
+			"Snapshot ID must be a number",
+		))
+	}
+
+	snapshot, err := h.reportSnapshotService.Get(c.UserContext(), userID, isAdmin, id)
+	if err != nil {
+		return snapshotError(c, "Error retrieving snapshot", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		snapshot,
+		"Snapshot retrieved successfully",
+	))
+}
+
+// Export streams a snapshot as an Excel file
+func (h *ReportSnapshotHandler) Export(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	departmentID, _ := c.Locals("department_id").(int)
+	isAdmin, _ := c.Locals("is_admin").(bool)
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid ID",
+			"Snapshot ID must be a number",
+		))
+	}
+
+	reportFileResponse, err := h.reportSnapshotService.Export(c.UserContext(), userID, departmentID, isAdmin, id, c.IP())
+	if err != nil {
+		return snapshotError(c, "Error exporting snapshot", err)
+	}
+
+	return sendExportFile(c, reportFileResponse)
+}
+
+// snapshotError maps an unknown snapshot to 404, a report the user may not open to 403 and
+// anything else to 500
+func snapshotError(c *fiber.Ctx, message string, err error) error {
+	if errors.Is(err, service.ErrSnapshotNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+			"Snapshot not found",
+			err.Error(),
+		))
+	}
+	return errorResponse(c, message, err)
+}
+
+// SetupRoutes sets up the handler routes
+func (h *ReportSnapshotHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+	snapshots := router.Group("/snapshots", requireOperation(h.operationCode))
+
+	snapshots.Get("/", h.GetAll)
+	snapshots.Post("/", h.Create)
+	snapshots.Get("/:id", h.GetByID)
+	snapshots.Get("/:id/export", h.Export)
+}
@@ -4,6 +4,7 @@ import (
 	"erp-excel/internal/dto"
 	"erp-excel/internal/service"
 	"erp-excel/internal/utils"
+	"errors"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
@@ -41,21 +42,15 @@ func (h *RoleHandler) GetAll(c *fiber.Ctx) error {
 	offset := (page - 1) * limit
 
 	// Get roles
-	roles, err := h.roleService.GetAllRoles(c.Context(), limit, offset)
+	roles, err := h.roleService.GetAllRoles(c.UserContext(), limit, offset)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error retrieving roles",
-			err.Error(),
-		))
+		return errorResponse(c, "Error retrieving roles", err)
 	}
 
 	// Get total count for pagination
-	total, err := h.roleService.CountRoles(c.Context())
+	total, err := h.roleService.CountRoles(c.UserContext())
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error counting roles",
-			err.Error(),
-		))
+		return errorResponse(c, "Error counting roles", err)
 	}
 
 	// Calculate pagination info
@@ -89,7 +84,7 @@ func (h *RoleHandler) GetByID(c *fiber.Ctx) error {
 		))
 	}
 
-	role, err := h.roleService.GetRoleByID(c.Context(), id)
+	role, err := h.roleService.GetRoleByID(c.UserContext(), id)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
 			"Role not found",
@@ -122,12 +117,15 @@ func (h *RoleHandler) Create(c *fiber.Ctx) error {
 	}
 
 	// Create role
-	role, err := h.roleService.CreateRole(c.Context(), request)
+	role, err := h.roleService.CreateRole(c.UserContext(), request)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error creating role",
-			err.Error(),
-		))
+		if errors.Is(err, service.ErrParentRoleNotFound) || errors.Is(err, service.ErrRoleCycle) {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid parent role",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error creating role", err)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(utils.SuccessResponse(
@@ -163,12 +161,15 @@ func (h *RoleHandler) Update(c *fiber.Ctx) error {
 	}
 
 	// Update role
-	role, err := h.roleService.UpdateRole(c.Context(), id, request)
+	role, err := h.roleService.UpdateRole(c.UserContext(), id, request)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error updating role",
-			err.Error(),
-		))
+		if errors.Is(err, service.ErrParentRoleNotFound) || errors.Is(err, service.ErrRoleCycle) {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid parent role",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error updating role", err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
@@ -187,16 +188,176 @@ func (h *RoleHandler) Delete(c *fiber.Ctx) error {
 		))
 	}
 
-	if err := h.roleService.DeleteRole(c.Context(), id); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error deleting role",
+	if err := h.roleService.DeleteRole(c.UserContext(), id); err != nil {
+		return errorResponse(c, "Error deleting role", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Role deleted successfully",
+	))
+}
+
+// Restore takes a role out of the trash
+func (h *RoleHandler) Restore(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid role ID",
+			"Role ID must be a number",
+		))
+	}
+
+	if err := h.roleService.RestoreRole(c.UserContext(), id); err != nil {
+		if errors.Is(err, service.ErrNotDeleted) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"Role not found in trash",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error restoring role", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Role restored successfully",
+	))
+}
+
+// Clone copies a role with its parent and operations under a new name
+func (h *RoleHandler) Clone(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid role ID",
+			"Role ID must be a number",
+		))
+	}
+
+	var request dto.CloneRoleRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body",
+		))
+	}
+
+	if err := utils.ValidateStruct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	role, err := h.roleService.CloneRole(c.UserContext(), id, request)
+	if err != nil {
+		if errors.Is(err, service.ErrRoleNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"Role not found",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error cloning role", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.SuccessResponse(
+		role,
+		"Role cloned successfully",
+	))
+}
+
+// GrantOperation adds one operation to several roles at once
+func (h *RoleHandler) GrantOperation(c *fiber.Ctx) error {
+	var request dto.GrantOperationRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body",
+		))
+	}
+
+	if err := utils.ValidateStruct(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
 			err.Error(),
 		))
 	}
 
+	if err := h.roleService.GrantOperation(c.UserContext(), request); err != nil {
+		switch {
+		case errors.Is(err, service.ErrRoleNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"Role not found",
+				err.Error(),
+			))
+		case errors.Is(err, service.ErrOperationNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"Operation not found",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error assigning operation", err)
+	}
+
 	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
 		nil,
-		"Role deleted successfully",
+		"Operation assigned successfully",
+	))
+}
+
+// GetUsers lists a page of the members of a role
+func (h *RoleHandler) GetUsers(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid role ID",
+			"Role ID must be a number",
+		))
+	}
+
+	// Parse pagination parameters
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+
+	// Handle invalid pagination
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	users, err := h.roleService.GetRoleUsers(c.UserContext(), id, limit, (page-1)*limit)
+	if err != nil {
+		if errors.Is(err, service.ErrRoleNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"Role not found",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error retrieving role users", err)
+	}
+
+	total, err := h.roleService.CountRoleUsers(c.UserContext(), id)
+	if err != nil {
+		return errorResponse(c, "Error counting role users", err)
+	}
+
+	totalPages := (total + limit - 1) / limit
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		fiber.Map{
+			"users": users,
+			"pagination": fiber.Map{
+				"total":       total,
+				"page":        page,
+				"limit":       limit,
+				"total_pages": totalPages,
+				"has_next":    page < totalPages,
+				"has_prev":    page > 1,
+			},
+		},
+		"Role users retrieved successfully",
 	))
 }
 
@@ -207,6 +368,10 @@ func (h *RoleHandler) SetupRoutes(router fiber.Router) {
 	roles.Get("/", h.GetAll)
 	roles.Get("/:id", h.GetByID)
 	roles.Post("/", h.Create)
+	roles.Post("/operations", h.GrantOperation)
 	roles.Put("/:id", h.Update)
 	roles.Delete("/:id", h.Delete)
+	roles.Post("/:id/restore", h.Restore)
+	roles.Post("/:id/clone", h.Clone)
+	roles.Get("/:id/users", h.GetUsers)
 }
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RowPolicyHandler lets administrators define the row policies restricting the rows users see in
+// the ERP reports
+type RowPolicyHandler struct {
+	BaseHandler
+
+	rowPolicyService service.RowPolicyService
+	operationService service.OperationService
+	operationCode    string
+}
+
+// NewRowPolicyHandler creates a new row policy handler
+func NewRowPolicyHandler(
+	rowPolicyService service.RowPolicyService,
+	operationService service.OperationService,
+	operationCode string,
+) *RowPolicyHandler {
+	if operationCode == "" {
+		operationCode = "row_policies"
+	}
+
+	return &RowPolicyHandler{
+		rowPolicyService: rowPolicyService,
+		operationService: operationService,
+		operationCode:    operationCode,
+	}
+}
+
+// GetAll lists the policies, only those of an operation with ?operation=
+func (h *RowPolicyHandler) GetAll(c *fiber.Ctx) error {
+	policies, err := h.rowPolicyService.List(c.UserContext(), c.Query("operation"))
+	if err != nil {
+		return errorResponse(c, "Error retrieving row policies", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		policies,
+		"Row policies retrieved successfully",
+	))
+}
+
+// GetAttributes lists the attributes policies may restrict, by operation
+func (h *RowPolicyHandler) GetAttributes(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		h.rowPolicyService.Attributes(),
+		"Row policy attributes retrieved successfully",
+	))
+}
+
+// Get gets a policy
+func (h *RowPolicyHandler) Get(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid ID",
+			"Row policy ID must be a number",
+		))
+	}
+
+	policy, err := h.rowPolicyService.Get(c.UserContext(), id)
+	if err != nil {
+		return errorResponse(c, "Error retrieving row policy", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		policy,
+		"Row policy retrieved successfully",
+	))
+}
+
+// Create defines a policy
+func (h *RowPolicyHandler) Create(c *fiber.Ctx) error {
+	request, err := parseRowPolicyRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			err.Error(),
+		))
+	}
+
+	userID, _ := c.Locals("user_id").(int)
+	policy, err := h.rowPolicyService.Create(c.UserContext(), userID, request)
+	if err != nil {
+		return errorResponse(c, "Error creating row policy", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.SuccessResponse(
+		policy,
+		"Row policy created successfully",
+	))
+}
+
+// Update changes a policy
+func (h *RowPolicyHandler) Update(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid ID",
+			"Row policy ID must be a number",
+		))
+	}
+
+	request, err := parseRowPolicyRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			err.Error(),
+		))
+	}
+
+	policy, err := h.rowPolicyService.Update(c.UserContext(), id, request)
+	if err != nil {
+		return errorResponse(c, "Error updating row policy", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		policy,
+		"Row policy updated successfully",
+	))
+}
+
+// Delete removes a policy
+func (h *RowPolicyHandler) Delete(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid ID",
+			"Row policy ID must be a number",
+		))
+	}
+
+	if err := h.rowPolicyService.Delete(c.UserContext(), id); err != nil {
+		return errorResponse(c, "Error deleting row policy", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Row policy deleted successfully",
+	))
+}
+
+// parseRowPolicyRequest parses and validates the body of a row policy request
+func parseRowPolicyRequest(c *fiber.Ctx) (*dto.RowPolicyRequest, error) {
+	var request dto.RowPolicyRequest
+	if err := c.BodyParser(&request); err != nil {
+		return nil, fmt.Errorf("error parsing request body: %w", err)
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+// SetupRoutes sets up the handler routes
+func (h *RowPolicyHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+	policies := router.Group("/admin/row-policies", requireOperation(h.operationCode))
+
+	policies.Get("/", h.GetAll)
+	policies.Post("/", h.Create)
+	policies.Get("/attributes", h.GetAttributes)
+	policies.Get("/:id", h.Get)
+	policies.Put("/:id", h.Update)
+	policies.Delete("/:id", h.Delete)
+}
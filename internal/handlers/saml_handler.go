@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"log/slog"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SAMLHandler handles SAML single sign-on requests
+type SAMLHandler struct {
+	BaseHandler
+
+	samlService service.SAMLService
+}
+
+// NewSAMLHandler creates a new SAML handler
+func NewSAMLHandler(samlService service.SAMLService) *SAMLHandler {
+	return &SAMLHandler{
+		samlService: samlService,
+	}
+}
+
+// Metadata returns the service provider metadata for the identity provider
+func (h *SAMLHandler) Metadata(c *fiber.Ctx) error {
+	metadata, err := h.samlService.Metadata()
+	if err != nil {
+		return errorResponse(c, "Error building metadata", err)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/samlmetadata+xml")
+	return c.Status(fiber.StatusOK).Send(metadata)
+}
+
+// Login redirects the browser to the identity provider
+func (h *SAMLHandler) Login(c *fiber.Ctx) error {
+	redirectURL, err := h.samlService.LoginURL(c.Query("RelayState"))
+	if err != nil {
+		return errorResponse(c, "Error starting SSO login", err)
+	}
+
+	return c.Redirect(redirectURL, fiber.StatusFound)
+}
+
+// AssertionConsumerService receives the identity provider's POSTed response
+func (h *SAMLHandler) AssertionConsumerService(c *fiber.Ctx) error {
+	samlResponse := c.FormValue("SAMLResponse")
+	if samlResponse == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"SAMLResponse is required",
+		))
+	}
+
+	response, err := h.samlService.Login(c.UserContext(), samlResponse)
+	if err != nil {
+		slog.WarnContext(c.UserContext(), "SAML login failed", "error", err)
+		return c.Status(fiber.StatusUnauthorized).JSON(utils.ErrorResponse(
+			"Login failed",
+			err.Error(),
+		))
+	}
+
+	// Browser flow: hand the token to the frontend in the URL fragment so it never reaches server logs
+	if redirectURL := h.samlService.RedirectURL(); redirectURL != "" {
+		fragment := url.Values{}
+		fragment.Set("token", response.Token)
+		if relayState := c.FormValue("RelayState"); relayState != "" {
+			fragment.Set("state", relayState)
+		}
+		return c.Redirect(redirectURL+"#"+fragment.Encode(), fiber.StatusFound)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		response,
+		"Login successful",
+	))
+}
+
+// SetupRoutes sets up the handler routes
+func (h *SAMLHandler) SetupRoutes(router fiber.Router) {
+	samlRoutes := router.Group("/auth/saml")
+
+	samlRoutes.Get("/metadata", h.Metadata)
+	samlRoutes.Get("/login", h.Login)
+	samlRoutes.Post("/acs", h.AssertionConsumerService)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SchemaCheckHandler runs the preflight check of the app and ERP database schemas
+type SchemaCheckHandler struct {
+	BaseHandler
+
+	schemaCheckService service.SchemaCheckService
+	operationService   service.OperationService
+	operationCode      string
+}
+
+// NewSchemaCheckHandler creates a new schema check handler
+func NewSchemaCheckHandler(
+	schemaCheckService service.SchemaCheckService,
+	operationService service.OperationService,
+	operationCode string,
+) *SchemaCheckHandler {
+	if operationCode == "" {
+		operationCode = "schema_check"
+	}
+
+	return &SchemaCheckHandler{
+		schemaCheckService: schemaCheckService,
+		operationService:   operationService,
+		operationCode:      operationCode,
+	}
+}
+
+// Check verifies that the databases have the tables, columns and procedures the reports need
+func (h *SchemaCheckHandler) Check(c *fiber.Ctx) error {
+	result := h.schemaCheckService.Check(c.UserContext())
+
+	message := "Schema check passed"
+	if !result.OK {
+		message = "Schema check failed"
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		result,
+		message,
+	))
+}
+
+// SetupRoutes sets up the handler routes
+func (h *SchemaCheckHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+
+	router.Get("/admin/schema/check", requireOperation(h.operationCode), h.Check)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultSlowQueryLimit is how many slow queries are listed unless the request asks otherwise
+const defaultSlowQueryLimit = 50
+
+// SlowQueryHandler shows administrators the latest slow ERP queries of this instance
+type SlowQueryHandler struct {
+	BaseHandler
+
+	monitor          *service.ERPQueryMonitor
+	operationService service.OperationService
+	operationCode    string
+}
+
+// NewSlowQueryHandler creates a new slow query handler
+func NewSlowQueryHandler(
+	monitor *service.ERPQueryMonitor,
+	operationService service.OperationService,
+	operationCode string,
+) *SlowQueryHandler {
+	if operationCode == "" {
+		operationCode = "report_admin"
+	}
+
+	return &SlowQueryHandler{
+		monitor:          monitor,
+		operationService: operationService,
+		operationCode:    operationCode,
+	}
+}
+
+// GetSlowQueries returns the latest slow queries, newest first, with their duration, rows and
+// sanitized parameters
+func (h *SlowQueryHandler) GetSlowQueries(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		h.monitor.SlowQueries(c.QueryInt("limit", defaultSlowQueryLimit)),
+		"Slow queries retrieved successfully",
+	))
+}
+
+// SetupRoutes sets up the handler routes
+func (h *SlowQueryHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+	router.Get("/admin/slow-queries", requireOperation(h.operationCode), h.GetSlowQueries)
+}
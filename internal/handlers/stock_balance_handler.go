@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/translate"
+	"erp-excel/internal/utils"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// StockBalanceHandler handles the on-hand stock report
+type StockBalanceHandler struct {
+	BaseHandler
+
+	stockBalanceService service.StockBalanceService
+	operationService    service.OperationService
+	operationCode       string
+}
+
+// NewStockBalanceHandler creates a new stock balance handler
+func NewStockBalanceHandler(
+	stockBalanceService service.StockBalanceService,
+	operationService service.OperationService,
+	operationCode string,
+) *StockBalanceHandler {
+	if operationCode == "" {
+		operationCode = "stock_balance"
+	}
+
+	return &StockBalanceHandler{
+		stockBalanceService: stockBalanceService,
+		operationService:    operationService,
+		operationCode:       operationCode,
+	}
+}
+
+// GetStockBalanceData returns the quantity on hand per item and warehouse
+func (h *StockBalanceHandler) GetStockBalanceData(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+
+	var request dto.StockBalanceRequest
+	if err := c.BodyParser(&request); err != nil {
+		slog.WarnContext(c.UserContext(), "Error parsing request body for stock balance", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	items, err := h.stockBalanceService.GetStockBalanceData(c.UserContext(), userID, &request, c.IP())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error getting stock balance data", "error", err)
+		return errorResponse(c, "Error retrieving report data", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		dto.StockBalanceDataResponse{
+			ReportName: strings.ReplaceAll(
+				translate.Text(c.UserContext(), "stock_balance_data_title", "Stock balance as of {date}"),
+				"{date}", request.AsOfDate.Format("02/01/2006"),
+			),
+			GeneratedAt: time.Now(),
+			AsOf:        *request.AsOfDate,
+			Items:       items,
+		},
+		"Report data retrieved successfully",
+	))
+}
+
+// ExportStockBalance streams the stock balance as an Excel file
+func (h *StockBalanceHandler) ExportStockBalance(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int)
+	departmentID, _ := c.Locals("department_id").(int)
+
+	var request dto.StockBalanceRequest
+	if err := c.BodyParser(&request); err != nil {
+		slog.WarnContext(c.UserContext(), "Error parsing request body for stock balance export", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body: "+err.Error(),
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	reportFileResponse, err := h.stockBalanceService.ExportStockBalance(c.UserContext(), userID, departmentID, &request, c.IP())
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Error exporting stock balance", "error", err)
+		return errorResponse(c, "Error exporting report", err)
+	}
+
+	return sendExportFile(c, reportFileResponse)
+}
+
+// SetupRoutes sets up the handler routes
+func (h *StockBalanceHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+	stock := router.Group("/reports/stock-balance", requireOperation(h.operationCode))
+
+	stock.Post("", h.GetStockBalanceData)
+	stock.Post("/export", h.ExportStockBalance)
+}
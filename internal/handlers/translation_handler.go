@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TranslationHandler manages the translation labels used for report headers
+type TranslationHandler struct {
+	BaseHandler
+
+	translationService service.TranslationService
+	operationService   service.OperationService
+	operationCode      string
+}
+
+// NewTranslationHandler creates a new translation handler
+func NewTranslationHandler(
+	translationService service.TranslationService,
+	operationService service.OperationService,
+	operationCode string,
+) *TranslationHandler {
+	if operationCode == "" {
+		operationCode = "translations"
+	}
+
+	return &TranslationHandler{
+		translationService: translationService,
+		operationService:   operationService,
+		operationCode:      operationCode,
+	}
+}
+
+// GetAll returns the effective labels of ?locale= (default vi)
+func (h *TranslationHandler) GetAll(c *fiber.Ctx) error {
+	labels, err := h.translationService.List(c.UserContext(), c.Query("locale"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Error retrieving translations",
+			err.Error(),
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		labels,
+		"Translations retrieved successfully",
+	))
+}
+
+// Set creates or replaces the label of a key
+func (h *TranslationHandler) Set(c *fiber.Ctx) error {
+	var request dto.TranslationLabelRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			"Error parsing request body",
+		))
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Validation error",
+			err.Error(),
+		))
+	}
+
+	userID, _ := c.Locals("user_id").(int)
+	label, err := h.translationService.Set(c.UserContext(), userID, c.Params("locale"), c.Params("key"), &request)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Error saving translation",
+			err.Error(),
+		))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		label,
+		"Translation saved successfully",
+	))
+}
+
+// Delete removes the runtime label of a key, restoring the built-in one if any
+func (h *TranslationHandler) Delete(c *fiber.Ctx) error {
+	if err := h.translationService.Delete(c.UserContext(), c.Params("locale"), c.Params("key")); err != nil {
+		if errors.Is(err, service.ErrTranslationNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"Translation not found",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error deleting translation", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Translation deleted successfully",
+	))
+}
+
+// Reload re-reads the labels from the database, e.g. after another instance changed them
+func (h *TranslationHandler) Reload(c *fiber.Ctx) error {
+	if err := h.translationService.Reload(c.UserContext()); err != nil {
+		return errorResponse(c, "Error reloading translations", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Translations reloaded successfully",
+	))
+}
+
+// SetupRoutes sets up the handler routes
+func (h *TranslationHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+	translations := router.Group("/admin/translations", requireOperation(h.operationCode))
+
+	translations.Get("/", h.GetAll)
+	translations.Post("/reload", h.Reload)
+	translations.Put("/:locale/:key", h.Set)
+	translations.Delete("/:locale/:key", h.Delete)
+}
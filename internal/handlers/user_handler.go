@@ -4,6 +4,7 @@ import (
 	"erp-excel/internal/dto"
 	"erp-excel/internal/service"
 	"erp-excel/internal/utils"
+	"errors"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
@@ -40,22 +41,19 @@ func (h *UserHandler) GetAll(c *fiber.Ctx) error {
 	// Calculate offset
 	offset := (page - 1) * limit
 
+	// Deleted users are listed alongside the others on request, marked by deleted_at
+	includeDeleted := c.QueryBool("include_deleted")
+
 	// Get users
-	users, err := h.userService.GetAllUsers(c.Context(), limit, offset)
+	users, err := h.userService.GetAllUsers(c.UserContext(), limit, offset, includeDeleted)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error retrieving users",
-			err.Error(),
-		))
+		return errorResponse(c, "Error retrieving users", err)
 	}
 
 	// Get total count for pagination
-	total, err := h.userService.CountUsers(c.Context())
+	total, err := h.userService.CountUsers(c.UserContext(), includeDeleted)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error counting users",
-			err.Error(),
-		))
+		return errorResponse(c, "Error counting users", err)
 	}
 
 	// Calculate pagination info
@@ -89,7 +87,7 @@ func (h *UserHandler) GetByID(c *fiber.Ctx) error {
 		))
 	}
 
-	user, err := h.userService.GetUserByID(c.Context(), id)
+	user, err := h.userService.GetUserByID(c.UserContext(), id)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
 			"User not found",
@@ -122,12 +120,9 @@ func (h *UserHandler) Create(c *fiber.Ctx) error {
 	}
 
 	// Create user
-	user, err := h.userService.CreateUser(c.Context(), request)
+	user, err := h.userService.CreateUser(c.UserContext(), request)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error creating user",
-			err.Error(),
-		))
+		return errorResponse(c, "Error creating user", err)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(utils.SuccessResponse(
@@ -163,12 +158,9 @@ func (h *UserHandler) Update(c *fiber.Ctx) error {
 	}
 
 	// Update user
-	user, err := h.userService.UpdateUser(c.Context(), id, request)
+	user, err := h.userService.UpdateUser(c.UserContext(), id, request)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error updating user",
-			err.Error(),
-		))
+		return errorResponse(c, "Error updating user", err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
@@ -179,20 +171,13 @@ func (h *UserHandler) Update(c *fiber.Ctx) error {
 
 // UpdatePassword updates a user's password
 func (h *UserHandler) UpdatePassword(c *fiber.Ctx) error {
-	var (
-		userID int
-		ok     bool
-	)
-	isAdmin, _ := c.Locals("is_admin").(bool)
-	if !isAdmin {
-		// Get current user ID
-		userID, ok = c.Locals("user_id").(int)
-		if !ok || userID == 0 {
-			return c.Status(fiber.StatusUnauthorized).JSON(utils.ErrorResponse(
-				"Authentication required",
-				"User not authenticated",
-			))
-		}
+	// Get current user ID
+	userID, ok := c.Locals("user_id").(int)
+	if !ok || userID == 0 {
+		return c.Status(fiber.StatusUnauthorized).JSON(utils.ErrorResponse(
+			"Authentication required",
+			"User not authenticated",
+		))
 	}
 
 	var request dto.UpdatePasswordRequest
@@ -212,11 +197,8 @@ func (h *UserHandler) UpdatePassword(c *fiber.Ctx) error {
 	}
 
 	// Update password
-	if err := h.userService.UpdateUserPassword(c.Context(), userID, request); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error updating password",
-			err.Error(),
-		))
+	if err := h.userService.UpdateUserPassword(c.UserContext(), userID, request); err != nil {
+		return errorResponse(c, "Error updating password", err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
@@ -225,7 +207,7 @@ func (h *UserHandler) UpdatePassword(c *fiber.Ctx) error {
 	))
 }
 
-// Delete deactivates a user
+// Delete moves a user to the trash
 func (h *UserHandler) Delete(c *fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
@@ -235,11 +217,8 @@ func (h *UserHandler) Delete(c *fiber.Ctx) error {
 		))
 	}
 
-	if err := h.userService.DeleteUser(c.Context(), id); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error deleting user",
-			err.Error(),
-		))
+	if err := h.userService.DeleteUser(c.UserContext(), id); err != nil {
+		return errorResponse(c, "Error deleting user", err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
@@ -278,11 +257,8 @@ func (h *UserHandler) AssignRoles(c *fiber.Ctx) error {
 	}
 
 	// Assign roles
-	if err := h.userService.AssignRolesToUser(c.Context(), id, request.RoleIDs); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
-			"Error assigning roles",
-			err.Error(),
-		))
+	if err := h.userService.AssignRolesToUser(c.UserContext(), id, request.RoleIDs); err != nil {
+		return errorResponse(c, "Error assigning roles", err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
@@ -291,6 +267,32 @@ func (h *UserHandler) AssignRoles(c *fiber.Ctx) error {
 	))
 }
 
+// Restore takes a user out of the trash
+func (h *UserHandler) Restore(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid user ID",
+			"User ID must be a number",
+		))
+	}
+
+	if err := h.userService.RestoreUser(c.UserContext(), id); err != nil {
+		if errors.Is(err, service.ErrNotDeleted) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.ErrorResponse(
+				"User not found in trash",
+				err.Error(),
+			))
+		}
+		return errorResponse(c, "Error restoring user", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"User restored successfully",
+	))
+}
+
 // SetupRoutes sets up the handler routes
 func (h *UserHandler) SetupRoutes(router fiber.Router) {
 	users := router.Group("/users")
@@ -300,6 +302,7 @@ func (h *UserHandler) SetupRoutes(router fiber.Router) {
 	users.Post("/", h.Create)
 	users.Put("/:id", h.Update)
 	users.Delete("/:id", h.Delete)
+	users.Post("/:id/restore", h.Restore)
 	users.Post("/:id/roles", h.AssignRoles)
 	users.Post("/password", h.UpdatePassword)
 }
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/middleware"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// WebhookHandler lets administrators register the webhooks receiving events and read the log of
+// their deliveries
+type WebhookHandler struct {
+	BaseHandler
+
+	webhookService   service.WebhookService
+	operationService service.OperationService
+	operationCode    string
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(
+	webhookService service.WebhookService,
+	operationService service.OperationService,
+	operationCode string,
+) *WebhookHandler {
+	if operationCode == "" {
+		operationCode = "webhooks"
+	}
+
+	return &WebhookHandler{
+		webhookService:   webhookService,
+		operationService: operationService,
+		operationCode:    operationCode,
+	}
+}
+
+// GetAll lists the webhooks without their secrets
+func (h *WebhookHandler) GetAll(c *fiber.Ctx) error {
+	webhooks, err := h.webhookService.List(c.UserContext())
+	if err != nil {
+		return errorResponse(c, "Error retrieving webhooks", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		webhooks,
+		"Webhooks retrieved successfully",
+	))
+}
+
+// Get gets a webhook
+func (h *WebhookHandler) Get(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid ID",
+			"Webhook ID must be a number",
+		))
+	}
+
+	webhook, err := h.webhookService.Get(c.UserContext(), id)
+	if err != nil {
+		return errorResponse(c, "Error retrieving webhook", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		webhook,
+		"Webhook retrieved successfully",
+	))
+}
+
+// Create registers a webhook; its secret is only part of this response
+func (h *WebhookHandler) Create(c *fiber.Ctx) error {
+	request, err := parseWebhookRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			err.Error(),
+		))
+	}
+
+	userID, _ := c.Locals("user_id").(int)
+	created, err := h.webhookService.Create(c.UserContext(), userID, request)
+	if err != nil {
+		return errorResponse(c, "Error creating webhook", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(utils.SuccessResponse(
+		created,
+		"Webhook created successfully, store the secret now: it cannot be shown again",
+	))
+}
+
+// Update changes a webhook; the secret is kept unless a new one is given
+func (h *WebhookHandler) Update(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid ID",
+			"Webhook ID must be a number",
+		))
+	}
+
+	request, err := parseWebhookRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid request",
+			err.Error(),
+		))
+	}
+
+	webhook, err := h.webhookService.Update(c.UserContext(), id, request)
+	if err != nil {
+		return errorResponse(c, "Error updating webhook", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		webhook,
+		"Webhook updated successfully",
+	))
+}
+
+// Delete removes a webhook and its delivery log
+func (h *WebhookHandler) Delete(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid ID",
+			"Webhook ID must be a number",
+		))
+	}
+
+	if err := h.webhookService.Delete(c.UserContext(), id); err != nil {
+		return errorResponse(c, "Error deleting webhook", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		nil,
+		"Webhook deleted successfully",
+	))
+}
+
+// GetDeliveries lists the newest deliveries. Filters: webhook_id, status (pending, delivered or
+// failed), event and limit.
+func (h *WebhookHandler) GetDeliveries(c *fiber.Ctx) error {
+	filter := repository.WebhookDeliveryFilter{
+		WebhookID: c.QueryInt("webhook_id", 0),
+		Status:    c.Query("status"),
+		EventType: c.Query("event"),
+		Limit:     c.QueryInt("limit", 0),
+	}
+	if id, err := c.ParamsInt("id"); err == nil {
+		filter.WebhookID = id
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(c.UserContext(), filter)
+	if err != nil {
+		return errorResponse(c, "Error retrieving webhook deliveries", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(utils.SuccessResponse(
+		deliveries,
+		"Webhook deliveries retrieved successfully",
+	))
+}
+
+// Redeliver queues a delivery again with a fresh count of attempts
+func (h *WebhookHandler) Redeliver(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+			"Invalid ID",
+			"Delivery ID must be a number",
+		))
+	}
+
+	if err := h.webhookService.Redeliver(c.UserContext(), id); err != nil {
+		return errorResponse(c, "Error queueing webhook delivery", err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(utils.SuccessResponse(
+		nil,
+		"Webhook delivery queued successfully",
+	))
+}
+
+// parseWebhookRequest parses and validates the body of a webhook request
+func parseWebhookRequest(c *fiber.Ctx) (*dto.WebhookRequest, error) {
+	var request dto.WebhookRequest
+	if err := c.BodyParser(&request); err != nil {
+		return nil, fmt.Errorf("error parsing request body: %w", err)
+	}
+
+	if err := utils.ValidateStruct(&request); err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+// SetupRoutes sets up the handler routes
+func (h *WebhookHandler) SetupRoutes(router fiber.Router) {
+	requireOperation := middleware.RoleCheckMiddleware(h.operationService)
+	webhooks := router.Group("/admin/webhooks", requireOperation(h.operationCode))
+
+	webhooks.Get("/", h.GetAll)
+	webhooks.Post("/", h.Create)
+	webhooks.Get("/deliveries", h.GetDeliveries)
+	webhooks.Post("/deliveries/:id/redeliver", h.Redeliver)
+	webhooks.Get("/:id", h.Get)
+	webhooks.Put("/:id", h.Update)
+	webhooks.Delete("/:id", h.Delete)
+	webhooks.Get("/:id/deliveries", h.GetDeliveries)
+}
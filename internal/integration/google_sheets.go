@@ -0,0 +1,286 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"erp-excel/config"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	sheetsAPIBase = "https://sheets.googleapis.com/v4/spreadsheets"
+	sheetsScope   = "https://www.googleapis.com/auth/spreadsheets"
+)
+
+// GoogleSheetsClient writes tabular data into Google Sheets
+type GoogleSheetsClient interface {
+	Enabled() bool
+	Target(name string) (config.SheetTargetConfig, bool)
+	Targets() []config.SheetTargetConfig
+	WriteValues(ctx context.Context, spreadsheetID, sheetName string, values [][]interface{}) error
+}
+
+// serviceAccount holds the fields we need from a Google service account key file
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+type googleSheetsClient struct {
+	config     config.GoogleSheetsConfig
+	httpClient *http.Client
+
+	mu          sync.Mutex
+	account     *serviceAccount
+	accessToken string
+	tokenExpiry time.Time
+}
+
+// NewGoogleSheetsClient creates a new Google Sheets client
+func NewGoogleSheetsClient(cfg config.GoogleSheetsConfig) GoogleSheetsClient {
+	return &googleSheetsClient{
+		config:     cfg,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Enabled reports whether the integration is switched on
+func (c *googleSheetsClient) Enabled() bool {
+	return c.config.Enabled
+}
+
+// Target returns the configured spreadsheet and tab for a report
+func (c *googleSheetsClient) Target(name string) (config.SheetTargetConfig, bool) {
+	target, ok := c.config.Targets[name]
+	return target, ok
+}
+
+// Targets returns every configured spreadsheet and tab
+func (c *googleSheetsClient) Targets() []config.SheetTargetConfig {
+	targets := make([]config.SheetTargetConfig, 0, len(c.config.Targets))
+	for _, target := range c.config.Targets {
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// WriteValues replaces the content of a sheet tab with the given rows
+func (c *googleSheetsClient) WriteValues(ctx context.Context, spreadsheetID, sheetName string, values [][]interface{}) error {
+	if !c.config.Enabled {
+		return errors.New("google sheets integration is disabled")
+	}
+	if spreadsheetID == "" || sheetName == "" {
+		return errors.New("spreadsheet id and sheet name are required")
+	}
+
+	token, err := c.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := c.ensureSheet(ctx, token, spreadsheetID, sheetName); err != nil {
+		return err
+	}
+
+	sheetRange := url.PathEscape(fmt.Sprintf("'%s'", strings.ReplaceAll(sheetName, "'", "''")))
+
+	// Clear old content first so shorter reports don't leave stale rows behind
+	clearURL := fmt.Sprintf("%s/%s/values/%s:clear", sheetsAPIBase, url.PathEscape(spreadsheetID), sheetRange)
+	if err := c.do(ctx, token, http.MethodPost, clearURL, map[string]interface{}{}, nil); err != nil {
+		return fmt.Errorf("error clearing sheet: %w", err)
+	}
+
+	updateURL := fmt.Sprintf("%s/%s/values/%s!A1?valueInputOption=RAW", sheetsAPIBase, url.PathEscape(spreadsheetID), sheetRange)
+	body := map[string]interface{}{
+		"majorDimension": "ROWS",
+		"values":         values,
+	}
+	if err := c.do(ctx, token, http.MethodPut, updateURL, body, nil); err != nil {
+		return fmt.Errorf("error writing sheet values: %w", err)
+	}
+
+	return nil
+}
+
+// ensureSheet creates the tab if it does not exist yet
+func (c *googleSheetsClient) ensureSheet(ctx context.Context, token, spreadsheetID, sheetName string) error {
+	var meta struct {
+		Sheets []struct {
+			Properties struct {
+				Title string `json:"title"`
+			} `json:"properties"`
+		} `json:"sheets"`
+	}
+
+	metaURL := fmt.Sprintf("%s/%s?fields=sheets.properties.title", sheetsAPIBase, url.PathEscape(spreadsheetID))
+	if err := c.do(ctx, token, http.MethodGet, metaURL, nil, &meta); err != nil {
+		return fmt.Errorf("error reading spreadsheet: %w", err)
+	}
+
+	for _, sheet := range meta.Sheets {
+		if sheet.Properties.Title == sheetName {
+			return nil
+		}
+	}
+
+	batchURL := fmt.Sprintf("%s/%s:batchUpdate", sheetsAPIBase, url.PathEscape(spreadsheetID))
+	body := map[string]interface{}{
+		"requests": []interface{}{
+			map[string]interface{}{
+				"addSheet": map[string]interface{}{
+					"properties": map[string]interface{}{"title": sheetName},
+				},
+			},
+		},
+	}
+	if err := c.do(ctx, token, http.MethodPost, batchURL, body, nil); err != nil {
+		return fmt.Errorf("error creating sheet tab: %w", err)
+	}
+
+	return nil
+}
+
+// token returns a cached access token or fetches a new one using the service account
+func (c *googleSheetsClient) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.accessToken != "" && time.Now().Before(c.tokenExpiry) {
+		return c.accessToken, nil
+	}
+
+	if c.account == nil {
+		account, err := loadServiceAccount(c.config.CredentialsFile)
+		if err != nil {
+			return "", err
+		}
+		c.account = account
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(c.account.PrivateKey))
+	if err != nil {
+		return "", fmt.Errorf("error parsing service account key: %w", err)
+	}
+
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   c.account.ClientEmail,
+		"scope": sheetsScope,
+		"aud":   c.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("error signing token assertion: %w", err)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("error creating token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error requesting access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("error requesting access token: %s: %s", resp.Status, msg)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("error decoding access token: %w", err)
+	}
+
+	c.accessToken = tokenResp.AccessToken
+	// Refresh a minute early to avoid using a token that expires mid-request
+	c.tokenExpiry = now.Add(time.Duration(tokenResp.ExpiresIn)*time.Second - time.Minute)
+
+	return c.accessToken, nil
+}
+
+// do sends a JSON request to the Sheets API and decodes the response into out when given
+func (c *googleSheetsClient) do(ctx context.Context, token, method, endpoint string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("error marshalling request: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, msg)
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("error decoding response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// loadServiceAccount reads a Google service account key file
+func loadServiceAccount(path string) (*serviceAccount, error) {
+	if path == "" {
+		return nil, errors.New("google sheets credentials file is not configured")
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading service account file: %w", err)
+	}
+
+	var account serviceAccount
+	if err := json.Unmarshal(raw, &account); err != nil {
+		return nil, fmt.Errorf("error parsing service account file: %w", err)
+	}
+
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	return &account, nil
+}
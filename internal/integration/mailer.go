@@ -0,0 +1,221 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"erp-excel/config"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MailAttachment is a file attached to an email
+type MailAttachment struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+}
+
+// MailMessage is an email with optional attachments
+type MailMessage struct {
+	To          []string
+	Subject     string
+	Body        string // plain text
+	Attachments []MailAttachment
+}
+
+// Mailer sends emails through the configured SMTP server
+type Mailer interface {
+	Enabled() bool
+	Send(ctx context.Context, message *MailMessage) error
+}
+
+type smtpMailer struct {
+	config config.MailConfig
+}
+
+// NewMailer creates a new SMTP mailer
+func NewMailer(cfg config.MailConfig) Mailer {
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+	if cfg.TLS == "" {
+		cfg.TLS = "starttls"
+	}
+
+	return &smtpMailer{
+		config: cfg,
+	}
+}
+
+// Enabled reports whether an SMTP server is configured
+func (m *smtpMailer) Enabled() bool {
+	return m.config.Host != ""
+}
+
+// Send delivers the message to every recipient in one SMTP transaction
+func (m *smtpMailer) Send(ctx context.Context, message *MailMessage) error {
+	if !m.Enabled() {
+		return errors.New("mail is not configured")
+	}
+	if len(message.To) == 0 {
+		return errors.New("email has no recipients")
+	}
+	if m.config.From == "" {
+		return errors.New("mail sender address is not configured")
+	}
+
+	content, err := buildMailMessage(m.config.From, message)
+	if err != nil {
+		return err
+	}
+
+	client, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if m.config.TLS == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server does not support STARTTLS")
+		}
+		if err := client.StartTLS(&tls.Config{ServerName: m.config.Host}); err != nil {
+			return fmt.Errorf("error starting TLS: %w", err)
+		}
+	}
+
+	if m.config.Username != "" {
+		auth := smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("error authenticating with smtp server: %w", err)
+		}
+	}
+
+	if err := client.Mail(m.config.From); err != nil {
+		return fmt.Errorf("error setting sender: %w", err)
+	}
+	for _, recipient := range message.To {
+		if err := client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("error adding recipient %s: %w", recipient, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("error starting message: %w", err)
+	}
+	if _, err := writer.Write(content); err != nil {
+		return fmt.Errorf("error writing message: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("error sending message: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// dial connects to the SMTP server, honouring the context deadline
+func (m *smtpMailer) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+
+	var conn net.Conn
+	var err error
+	if m.config.TLS == "tls" {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: m.config.Host}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to smtp server: %w", err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error connecting to smtp server: %w", err)
+	}
+
+	return client, nil
+}
+
+// buildMailMessage renders the message as MIME, multipart when there are attachments
+func buildMailMessage(from string, message *MailMessage) ([]byte, error) {
+	var buf bytes.Buffer
+
+	writeHeader := func(name, value string) {
+		buf.WriteString(name + ": " + value + "\r\n")
+	}
+	writeHeader("From", from)
+	writeHeader("To", strings.Join(message.To, ", "))
+	writeHeader("Subject", mime.QEncoding.Encode("utf-8", message.Subject))
+	writeHeader("Date", time.Now().Format(time.RFC1123Z))
+	writeHeader("MIME-Version", "1.0")
+
+	body := base64Lines([]byte(message.Body))
+	if len(message.Attachments) == 0 {
+		writeHeader("Content-Type", `text/plain; charset="utf-8"`)
+		writeHeader("Content-Transfer-Encoding", "base64")
+		buf.WriteString("\r\n" + body)
+		return buf.Bytes(), nil
+	}
+
+	boundaryBytes := make([]byte, 16)
+	if _, err := rand.Read(boundaryBytes); err != nil {
+		return nil, fmt.Errorf("error creating mime boundary: %w", err)
+	}
+	boundary := hex.EncodeToString(boundaryBytes)
+
+	writeHeader("Content-Type", `multipart/mixed; boundary="`+boundary+`"`)
+	buf.WriteString("\r\n")
+
+	buf.WriteString("--" + boundary + "\r\n")
+	writeHeader("Content-Type", `text/plain; charset="utf-8"`)
+	writeHeader("Content-Transfer-Encoding", "base64")
+	buf.WriteString("\r\n" + body)
+
+	for _, attachment := range message.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		fileName := mime.QEncoding.Encode("utf-8", attachment.FileName)
+
+		buf.WriteString("--" + boundary + "\r\n")
+		writeHeader("Content-Type", contentType+`; name="`+fileName+`"`)
+		writeHeader("Content-Disposition", `attachment; filename="`+fileName+`"`)
+		writeHeader("Content-Transfer-Encoding", "base64")
+		buf.WriteString("\r\n" + base64Lines(attachment.Content))
+	}
+	buf.WriteString("--" + boundary + "--\r\n")
+
+	return buf.Bytes(), nil
+}
+
+// base64Lines encodes content as base64 wrapped at 76 characters, as MIME requires
+func base64Lines(content []byte) string {
+	encoded := base64.StdEncoding.EncodeToString(content)
+
+	var builder strings.Builder
+	for len(encoded) > 76 {
+		builder.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	builder.WriteString(encoded + "\r\n")
+	return builder.String()
+}
@@ -0,0 +1,228 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"erp-excel/config"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	graphAPIBase    = "https://graph.microsoft.com/v1.0"
+	graphScope      = "https://graph.microsoft.com/.default"
+	graphTokenURL   = "https://login.microsoftonline.com/%s/oauth2/v2.0/token"
+	graphSimpleMax  = 4 * 1024 * 1024 // simple PUT uploads are limited to 4MB
+	graphChunkSize  = 5 * 320 * 1024  // upload session chunks must be multiples of 320KB
+	defaultPathTmpl = "Reports/{report}/{yyyy}/{MM}/{filename}"
+)
+
+// SharePointClient uploads files into a SharePoint document library or OneDrive folder via Microsoft Graph
+type SharePointClient interface {
+	Enabled() bool
+	ResolvePath(reportName, fileName string, at time.Time) string
+	Upload(ctx context.Context, filePath string, content []byte) (string, error)
+}
+
+type sharePointClient struct {
+	config     config.SharePointConfig
+	httpClient *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	tokenExpiry time.Time
+}
+
+// NewSharePointClient creates a new Microsoft Graph upload client
+func NewSharePointClient(cfg config.SharePointConfig) SharePointClient {
+	return &sharePointClient{
+		config:     cfg,
+		httpClient: &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// Enabled reports whether the integration is switched on
+func (c *sharePointClient) Enabled() bool {
+	return c.config.Enabled
+}
+
+// ResolvePath expands the configured path template for a report.
+// Supported placeholders: {report}, {filename}, {yyyy}, {MM}, {dd}.
+func (c *sharePointClient) ResolvePath(reportName, fileName string, at time.Time) string {
+	template := c.config.PathTemplate
+	if override, ok := c.config.Targets[reportName]; ok && override != "" {
+		template = override
+	}
+	if template == "" {
+		template = defaultPathTmpl
+	}
+
+	replacer := strings.NewReplacer(
+		"{report}", reportName,
+		"{filename}", fileName,
+		"{yyyy}", at.Format("2006"),
+		"{MM}", at.Format("01"),
+		"{dd}", at.Format("02"),
+	)
+
+	return strings.Trim(path.Clean("/"+replacer.Replace(template)), "/")
+}
+
+// Upload writes the content to the drive path, replacing any existing file, and returns its web URL
+func (c *sharePointClient) Upload(ctx context.Context, filePath string, content []byte) (string, error) {
+	if !c.config.Enabled {
+		return "", errors.New("sharepoint integration is disabled")
+	}
+	if c.config.DriveID == "" {
+		return "", errors.New("sharepoint drive id is not configured")
+	}
+
+	token, err := c.token(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	itemURL := fmt.Sprintf("%s/drives/%s/root:/%s:", graphAPIBase, url.PathEscape(c.config.DriveID), escapeDrivePath(filePath))
+
+	var item struct {
+		WebURL string `json:"webUrl"`
+	}
+
+	if len(content) <= graphSimpleMax {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, itemURL+"/content", bytes.NewReader(content))
+		if err != nil {
+			return "", fmt.Errorf("error creating upload request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/octet-stream")
+
+		if err := c.send(req, &item); err != nil {
+			return "", fmt.Errorf("error uploading file: %w", err)
+		}
+		return item.WebURL, nil
+	}
+
+	// Larger files go through an upload session
+	var session struct {
+		UploadURL string `json:"uploadUrl"`
+	}
+	sessionBody, _ := json.Marshal(map[string]interface{}{
+		"item": map[string]interface{}{"@microsoft.graph.conflictBehavior": "replace"},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, itemURL+"/createUploadSession", bytes.NewReader(sessionBody))
+	if err != nil {
+		return "", fmt.Errorf("error creating upload session request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	if err := c.send(req, &session); err != nil {
+		return "", fmt.Errorf("error creating upload session: %w", err)
+	}
+
+	total := len(content)
+	for start := 0; start < total; start += graphChunkSize {
+		end := start + graphChunkSize
+		if end > total {
+			end = total
+		}
+
+		// The upload URL is pre-authenticated, so no bearer token is sent with the chunks
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, session.UploadURL, bytes.NewReader(content[start:end]))
+		if err != nil {
+			return "", fmt.Errorf("error creating chunk request: %w", err)
+		}
+		req.ContentLength = int64(end - start)
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, total))
+
+		var out interface{}
+		if end == total {
+			out = &item
+		}
+		if err := c.send(req, out); err != nil {
+			return "", fmt.Errorf("error uploading chunk: %w", err)
+		}
+	}
+
+	return item.WebURL, nil
+}
+
+// token returns a cached app-only access token or requests a new one with client credentials
+func (c *sharePointClient) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.accessToken != "" && time.Now().Before(c.tokenExpiry) {
+		return c.accessToken, nil
+	}
+
+	if c.config.TenantID == "" || c.config.ClientID == "" || c.config.ClientSecret == "" {
+		return "", errors.New("sharepoint tenant id, client id and client secret are required")
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", c.config.ClientID)
+	form.Set("client_secret", c.config.ClientSecret)
+	form.Set("scope", graphScope)
+
+	tokenURL := fmt.Sprintf(graphTokenURL, url.PathEscape(c.config.TenantID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("error creating token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	now := time.Now()
+	if err := c.send(req, &tokenResp); err != nil {
+		return "", fmt.Errorf("error requesting access token: %w", err)
+	}
+
+	c.accessToken = tokenResp.AccessToken
+	// Refresh a minute early to avoid using a token that expires mid-request
+	c.tokenExpiry = now.Add(time.Duration(tokenResp.ExpiresIn)*time.Second - time.Minute)
+
+	return c.accessToken, nil
+}
+
+// send executes a request and decodes the JSON response into out when given
+func (c *sharePointClient) send(req *http.Request, out interface{}) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return fmt.Errorf("%s: %s", resp.Status, msg)
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("error decoding response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// escapeDrivePath escapes each segment of a drive-relative path
+func escapeDrivePath(filePath string) string {
+	segments := strings.Split(filePath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,250 @@
+// Package ldap checks passwords against an LDAP directory, such as Active Directory, for the
+// login: it searches the user's entry with a service account and binds as the entry.
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"erp-excel/config"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+// ErrInvalidCredentials is returned when the user is unknown to the directory or the password is wrong
+var ErrInvalidCredentials = errors.New("ldap: invalid credentials")
+
+const (
+	defaultTimeout           = 10 * time.Second
+	defaultUsernameAttribute = "sAMAccountName"
+)
+
+// Entry is the directory entry of an authenticated user. Attribute names are lowercased.
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Attribute returns the first value of an attribute, or an empty string
+func (e *Entry) Attribute(name string) string {
+	if values := e.Attributes[strings.ToLower(name)]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Values returns every value of an attribute
+func (e *Entry) Values(name string) []string {
+	return e.Attributes[strings.ToLower(name)]
+}
+
+// Client checks user passwords against an LDAP directory. It opens one connection per login,
+// which is plenty for the rate people log in at.
+type Client struct {
+	config    config.LDAPConfig
+	address   string
+	useTLS    bool
+	tlsConfig *tls.Config
+	timeout   time.Duration
+}
+
+// NewClient validates the configuration and creates a client
+func NewClient(cfg config.LDAPConfig) (*Client, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ldap url: %w", err)
+	}
+
+	client := &Client{config: cfg, timeout: time.Duration(cfg.TimeoutSeconds) * time.Second}
+	if client.timeout <= 0 {
+		client.timeout = defaultTimeout
+	}
+	if client.config.UsernameAttribute == "" {
+		client.config.UsernameAttribute = defaultUsernameAttribute
+	}
+	if cfg.BaseDN == "" {
+		return nil, errors.New("ldap base_dn is not configured")
+	}
+
+	port := u.Port()
+	switch u.Scheme {
+	case "ldap":
+		if port == "" {
+			port = "389"
+		}
+	case "ldaps":
+		if port == "" {
+			port = "636"
+		}
+		client.useTLS = true
+		if cfg.StartTLS {
+			return nil, errors.New("ldap start_tls cannot be combined with an ldaps url")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported ldap url scheme %q", u.Scheme)
+	}
+	client.address = net.JoinHostPort(u.Hostname(), port)
+
+	client.tlsConfig = &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if cfg.CACertificateFile != "" {
+		pem, err := os.ReadFile(cfg.CACertificateFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading ldap ca certificate: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("ldap ca certificate file contains no certificate")
+		}
+		client.tlsConfig.RootCAs = pool
+	}
+
+	return client, nil
+}
+
+// Authenticate looks up the user's entry and checks the password by binding as it
+func (c *Client) Authenticate(ctx context.Context, username, password string) (*Entry, error) {
+	// A simple bind with an empty password is an unauthenticated bind that servers accept
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = conn.Unbind()
+		_ = conn.Close()
+	}()
+
+	if err := bind(conn, c.config.BindDN, c.config.BindPassword); err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			return nil, errors.New("ldap: the bind account was rejected")
+		}
+		return nil, err
+	}
+
+	// Two entries are enough to tell the match is ambiguous
+	result, err := conn.Search(goldap.NewSearchRequest(
+		c.config.BaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases, 2, 0, false,
+		c.filter(username), c.attributes(), nil,
+	))
+	// sizeLimitExceeded still returns the entries found, which are more than one
+	if err != nil && !(goldap.IsErrorWithCode(err, goldap.LDAPResultSizeLimitExceeded) && len(result.Entries) > 1) {
+		return nil, resultError(err, "search")
+	}
+	switch len(result.Entries) {
+	case 0:
+		return nil, ErrInvalidCredentials
+	case 1:
+	default:
+		return nil, fmt.Errorf("ldap: %d entries match user %s", len(result.Entries), username)
+	}
+
+	found := result.Entries[0]
+	if err := bind(conn, found.DN, password); err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{DN: found.DN, Attributes: make(map[string][]string, len(found.Attributes))}
+	for _, attribute := range found.Attributes {
+		name := strings.ToLower(attribute.Name)
+		entry.Attributes[name] = append(entry.Attributes[name], attribute.Values...)
+	}
+	return entry, nil
+}
+
+// filter matches the user's entry, of the configured object class when set
+func (c *Client) filter(username string) string {
+	byName := fmt.Sprintf("(%s=%s)", c.config.UsernameAttribute, goldap.EscapeFilter(username))
+	if c.config.ObjectClass == "" {
+		return byName
+	}
+	return fmt.Sprintf("(&(objectClass=%s)%s)", goldap.EscapeFilter(c.config.ObjectClass), byName)
+}
+
+// attributes lists the attributes read from the entry
+func (c *Client) attributes() []string {
+	var attributes []string
+	for _, name := range []string{
+		c.config.UsernameAttribute,
+		c.config.FullNameAttribute,
+		c.config.EmailAttribute,
+		c.config.GroupAttribute,
+	} {
+		if name != "" {
+			attributes = append(attributes, name)
+		}
+	}
+	return attributes
+}
+
+// dial connects to the server, upgrading to TLS as configured, with a deadline for the whole login
+func (c *Client) dial(ctx context.Context) (*goldap.Conn, error) {
+	deadline := time.Now().Add(c.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	dialer := &net.Dialer{Deadline: deadline}
+	raw, err := dialer.DialContext(ctx, "tcp", c.address)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: error connecting to %s: %w", c.address, err)
+	}
+	if err := raw.SetDeadline(deadline); err != nil {
+		raw.Close()
+		return nil, fmt.Errorf("ldap: error setting deadline: %w", err)
+	}
+
+	// The handshake of an ldaps connection runs with the first message
+	if c.useTLS {
+		raw = tls.Client(raw, c.tlsConfig)
+	}
+	conn := goldap.NewConn(raw, c.useTLS)
+	conn.Start()
+
+	if c.config.StartTLS {
+		if err := conn.StartTLS(c.tlsConfig); err != nil {
+			conn.Close()
+			return nil, resultError(err, "start tls")
+		}
+	}
+	return conn, nil
+}
+
+// bind authenticates the session with a simple bind, anonymous when dn is empty
+func bind(conn *goldap.Conn, dn, password string) error {
+	request := &goldap.SimpleBindRequest{Username: dn, Password: password, AllowEmptyPassword: dn == ""}
+	if _, err := conn.SimpleBind(request); err != nil {
+		return resultError(err, "bind")
+	}
+	return nil
+}
+
+// resultError turns the error of an operation into ErrInvalidCredentials or an error naming the
+// operation and its result code
+func resultError(err error, operation string) error {
+	if goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+		return ErrInvalidCredentials
+	}
+
+	var result *goldap.Error
+	if errors.As(err, &result) && result.ResultCode < goldap.ErrorNetwork {
+		return fmt.Errorf("ldap: %s failed with result %d: %v", operation, result.ResultCode, result.Err)
+	}
+	return fmt.Errorf("ldap: %s failed: %w", operation, err)
+}
@@ -0,0 +1,365 @@
+package ldap
+
+import (
+	"context"
+	"erp-excel/config"
+	"errors"
+	"net"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+// fakeUser is an entry of the fake directory
+type fakeUser struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// fakeDirectory is an LDAP server answering binds and searches from a fixed set of entries
+type fakeDirectory struct {
+	bindDN       string
+	bindPassword string
+	users        []fakeUser
+
+	mu       sync.Mutex
+	searches []fakeSearch
+}
+
+// fakeSearch records what a search asked for
+type fakeSearch struct {
+	baseDN      string
+	objectClass string
+	username    string
+	attributes  []string
+}
+
+func startDirectory(t *testing.T, directory *fakeDirectory) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go directory.serve(conn)
+		}
+	}()
+	return "ldap://" + listener.Addr().String()
+}
+
+func (d *fakeDirectory) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		message, err := ber.ReadPacket(conn)
+		if err != nil || len(message.Children) < 2 {
+			return
+		}
+		id, op := message.Children[0].Value.(int64), message.Children[1]
+
+		var responses []*ber.Packet
+		switch op.Tag {
+		case goldap.ApplicationBindRequest:
+			responses = append(responses, result(goldap.ApplicationBindResponse, d.bind(op.Children[1].Value.(string), op.Children[2].Data.String()), ""))
+		case goldap.ApplicationSearchRequest:
+			responses = d.search(op)
+		case goldap.ApplicationExtendedRequest:
+			responses = append(responses, result(goldap.ApplicationExtendedResponse, goldap.LDAPResultProtocolError, "unsupported extended operation"))
+		default:
+			return
+		}
+		for _, response := range responses {
+			envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+			envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+			envelope.AppendChild(response)
+			if _, err := conn.Write(envelope.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (d *fakeDirectory) bind(dn, password string) int {
+	if dn == d.bindDN && password == d.bindPassword {
+		return goldap.LDAPResultSuccess
+	}
+	for _, user := range d.users {
+		if dn == user.dn && password == user.password {
+			return goldap.LDAPResultSuccess
+		}
+	}
+	return goldap.LDAPResultInvalidCredentials
+}
+
+// filterAssertion matches an equality assertion of a decompiled filter
+var filterAssertion = regexp.MustCompile(`\((\w+)=([^)]*)\)`)
+
+// search answers the equality filter on uid, optionally and-ed with one on objectClass
+func (d *fakeDirectory) search(op *ber.Packet) []*ber.Packet {
+	search := fakeSearch{baseDN: op.Children[0].Value.(string)}
+	filter, _ := goldap.DecompileFilter(op.Children[6])
+	for _, assertion := range filterAssertion.FindAllStringSubmatch(filter, -1) {
+		if assertion[1] == "objectClass" {
+			search.objectClass = assertion[2]
+		} else {
+			search.username = assertion[2]
+		}
+	}
+	for _, attribute := range op.Children[7].Children {
+		search.attributes = append(search.attributes, attribute.Value.(string))
+	}
+	d.mu.Lock()
+	d.searches = append(d.searches, search)
+	d.mu.Unlock()
+
+	// A referral comes first, which the client skips
+	reference := ber.Encode(ber.ClassApplication, ber.TypeConstructed, goldap.ApplicationSearchResultReference, nil, "Search Result Reference")
+	reference.AppendChild(octetString("ldap://other/dc=example"))
+	responses := []*ber.Packet{reference}
+	for _, user := range d.users {
+		if !strings.EqualFold(user.attributes["uid"][0], search.username) {
+			continue
+		}
+		attributeList := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+		for name, values := range user.attributes {
+			attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+			attribute.AppendChild(octetString(name))
+			valueSet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+			for _, value := range values {
+				valueSet.AppendChild(octetString(value))
+			}
+			attribute.AppendChild(valueSet)
+			attributeList.AppendChild(attribute)
+		}
+		entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, goldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+		entry.AppendChild(octetString(user.dn))
+		entry.AppendChild(attributeList)
+		responses = append(responses, entry)
+	}
+	return append(responses, result(goldap.ApplicationSearchResultDone, goldap.LDAPResultSuccess, ""))
+}
+
+// recorded returns the searches made so far
+func (d *fakeDirectory) recorded() []fakeSearch {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]fakeSearch(nil), d.searches...)
+}
+
+// result encodes an LDAPResult
+func result(tag ber.Tag, code int, message string) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	op.AppendChild(octetString(""))
+	op.AppendChild(octetString(message))
+	return op
+}
+
+func octetString(value string) *ber.Packet {
+	return ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "")
+}
+
+func testDirectory() *fakeDirectory {
+	return &fakeDirectory{
+		bindDN:       "cn=reader,dc=example",
+		bindPassword: "reader-password",
+		users: []fakeUser{
+			{
+				dn:       "uid=jdoe,ou=people,dc=example",
+				password: "correct horse",
+				attributes: map[string][]string{
+					"uid":      {"jdoe"},
+					"cn":       {"Jane Doe"},
+					"mail":     {"jane@example.com"},
+					"memberOf": {"cn=finance,dc=example", "cn=reports,dc=example"},
+				},
+			},
+			{dn: "uid=twin,ou=a,dc=example", password: "a", attributes: map[string][]string{"uid": {"twin"}}},
+			{dn: "uid=twin,ou=b,dc=example", password: "b", attributes: map[string][]string{"uid": {"twin"}}},
+		},
+	}
+}
+
+func testConfig(url string) config.LDAPConfig {
+	return config.LDAPConfig{
+		URL:               url,
+		BindDN:            "cn=reader,dc=example",
+		BindPassword:      "reader-password",
+		BaseDN:            "dc=example",
+		UsernameAttribute: "uid",
+		FullNameAttribute: "cn",
+		EmailAttribute:    "mail",
+		GroupAttribute:    "memberOf",
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	directory := testDirectory()
+	client, err := NewClient(testConfig(startDirectory(t, directory)))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	entry, err := client.Authenticate(context.Background(), "jdoe", "correct horse")
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if entry.DN != "uid=jdoe,ou=people,dc=example" {
+		t.Errorf("DN = %q", entry.DN)
+	}
+	if entry.Attribute("CN") != "Jane Doe" || entry.Attribute("mail") != "jane@example.com" || entry.Attribute("missing") != "" {
+		t.Errorf("attributes = %v", entry.Attributes)
+	}
+	if groups := entry.Values("memberof"); !reflect.DeepEqual(groups, []string{"cn=finance,dc=example", "cn=reports,dc=example"}) {
+		t.Errorf("groups = %v", groups)
+	}
+
+	want := fakeSearch{baseDN: "dc=example", username: "jdoe", attributes: []string{"uid", "cn", "mail", "memberOf"}}
+	if searches := directory.recorded(); len(searches) != 1 || !reflect.DeepEqual(searches[0], want) {
+		t.Errorf("searches = %+v, want %+v", searches, want)
+	}
+}
+
+func TestAuthenticateFailures(t *testing.T) {
+	url := startDirectory(t, testDirectory())
+
+	tests := []struct {
+		name     string
+		config   func(cfg *config.LDAPConfig)
+		username string
+		password string
+		wantErr  error
+		wantText string
+	}{
+		{name: "wrong password", username: "jdoe", password: "wrong", wantErr: ErrInvalidCredentials},
+		{name: "unknown user", username: "nobody", password: "x", wantErr: ErrInvalidCredentials},
+		{name: "empty password", username: "jdoe", password: "", wantErr: ErrInvalidCredentials},
+		{name: "empty username", username: "", password: "x", wantErr: ErrInvalidCredentials},
+		{name: "ambiguous user", username: "twin", password: "a", wantText: "ldap: 2 entries match user twin"},
+		{
+			name:     "bind account rejected",
+			config:   func(cfg *config.LDAPConfig) { cfg.BindPassword = "stale" },
+			username: "jdoe",
+			password: "correct horse",
+			wantText: "ldap: the bind account was rejected",
+		},
+		{
+			name:     "start tls refused",
+			config:   func(cfg *config.LDAPConfig) { cfg.StartTLS = true },
+			username: "jdoe",
+			password: "correct horse",
+			wantText: "ldap: start tls failed with result 2: unsupported extended operation",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(url)
+			if tt.config != nil {
+				tt.config(&cfg)
+			}
+			client, err := NewClient(cfg)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			entry, err := client.Authenticate(context.Background(), tt.username, tt.password)
+			if entry != nil {
+				t.Errorf("Authenticate() entry = %+v, want none", entry)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantText != "" && (err == nil || err.Error() != tt.wantText) {
+				t.Errorf("Authenticate() error = %v, want %s", err, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestAuthenticateWithObjectClass(t *testing.T) {
+	directory := testDirectory()
+	cfg := testConfig(startDirectory(t, directory))
+	cfg.ObjectClass = "person"
+	cfg.FullNameAttribute, cfg.EmailAttribute, cfg.GroupAttribute = "", "", ""
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	if _, err := client.Authenticate(context.Background(), "jdoe", "correct horse"); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	want := fakeSearch{baseDN: "dc=example", objectClass: "person", username: "jdoe", attributes: []string{"uid"}}
+	if searches := directory.recorded(); len(searches) != 1 || !reflect.DeepEqual(searches[0], want) {
+		t.Errorf("searches = %+v, want %+v", searches, want)
+	}
+}
+
+func TestAuthenticateWithAnonymousSearch(t *testing.T) {
+	directory := testDirectory()
+	directory.bindDN, directory.bindPassword = "", ""
+	cfg := testConfig(startDirectory(t, directory))
+	cfg.BindDN, cfg.BindPassword = "", ""
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	if _, err := client.Authenticate(context.Background(), "jdoe", "correct horse"); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		startTLS    bool
+		baseDN      string
+		wantAddress string
+		wantTLS     bool
+		wantErr     string
+	}{
+		{name: "ldap", url: "ldap://dc1.example.com", baseDN: "dc=example", wantAddress: "dc1.example.com:389"},
+		{name: "ldaps", url: "ldaps://dc1.example.com", baseDN: "dc=example", wantAddress: "dc1.example.com:636", wantTLS: true},
+		{name: "explicit port", url: "ldap://dc1.example.com:3268", baseDN: "dc=example", wantAddress: "dc1.example.com:3268"},
+		{name: "start tls", url: "ldap://dc1.example.com", startTLS: true, baseDN: "dc=example", wantAddress: "dc1.example.com:389"},
+		{name: "ldaps with start tls", url: "ldaps://dc1.example.com", startTLS: true, baseDN: "dc=example", wantErr: "ldap start_tls cannot be combined with an ldaps url"},
+		{name: "no base dn", url: "ldap://dc1.example.com", wantErr: "ldap base_dn is not configured"},
+		{name: "other scheme", url: "http://dc1.example.com", baseDN: "dc=example", wantErr: `unsupported ldap url scheme "http"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(config.LDAPConfig{URL: tt.url, StartTLS: tt.startTLS, BaseDN: tt.baseDN})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("NewClient() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			if client.address != tt.wantAddress || client.useTLS != tt.wantTLS {
+				t.Errorf("address = %s, TLS = %t, want %s, %t", client.address, client.useTLS, tt.wantAddress, tt.wantTLS)
+			}
+			if client.timeout != defaultTimeout || client.config.UsernameAttribute != defaultUsernameAttribute {
+				t.Errorf("defaults = %v, %s", client.timeout, client.config.UsernameAttribute)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"context"
+	"erp-excel/config"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type contextKey struct{}
+
+// WithRequestID returns a context carrying the ID of the request it belongs to
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, requestID)
+}
+
+// RequestID returns the request ID carried by a context, or an empty string
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(contextKey{}).(string)
+	return requestID
+}
+
+// New builds the application logger. Lines are JSON unless the format is "text", go to stdout
+// and, when a path is configured, to that file too. Every line logged with a request context
+// is tagged with its request_id.
+func New(cfg config.LoggerConfig) (*slog.Logger, error) {
+	var out io.Writer = os.Stdout
+	if cfg.Path != "" {
+		if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+			return nil, fmt.Errorf("error creating log directory: %w", err)
+		}
+		file, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("error opening log file: %w", err)
+		}
+		out = io.MultiWriter(os.Stdout, file)
+	}
+
+	options := &slog.HandlerOptions{Level: parseLevel(cfg.Level)}
+
+	var handler slog.Handler
+	if strings.EqualFold(cfg.Format, "text") {
+		handler = slog.NewTextHandler(out, options)
+	} else {
+		handler = slog.NewJSONHandler(out, options)
+	}
+
+	return slog.New(&contextHandler{Handler: handler}), nil
+}
+
+// parseLevel maps the configured level to a slog level, defaulting to info
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// contextHandler adds the request ID of the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
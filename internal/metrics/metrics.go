@@ -0,0 +1,305 @@
+// Package metrics keeps counters, histograms and gauges and writes them in the Prometheus text
+// exposition format, so Prometheus can scrape them from /metrics without a client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// labelSeparator joins the label values of a series into its key; it cannot appear in UTF-8 text
+const labelSeparator = "\xff"
+
+// collector is a metric of a registry
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds the metrics served at /metrics
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]collector
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]collector)}
+}
+
+// register adds a metric, panicking when its name is taken since that is a programming error
+func (r *Registry) register(metric collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.metrics[metric.name()]; ok {
+		panic(fmt.Sprintf("metric %s registered twice", metric.name()))
+	}
+	r.metrics[metric.name()] = metric
+}
+
+// WriteTo writes every metric in the Prometheus text format, sorted by name
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := make([]collector, 0, len(r.metrics))
+	for _, metric := range r.metrics {
+		metrics = append(metrics, metric)
+	}
+	r.mu.Unlock()
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name() < metrics[j].name() })
+
+	counter := &countingWriter{w: w}
+	buffered := bufio.NewWriter(counter)
+	for _, metric := range metrics {
+		metric.write(buffered)
+	}
+	err := buffered.Flush()
+	return counter.n, err
+}
+
+// desc is the name, help and label names of a metric
+type desc struct {
+	metricName string
+	help       string
+	labels     []string
+}
+
+func (d desc) name() string {
+	return d.metricName
+}
+
+// key returns the key of the series with the label values, panicking when their number is wrong
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d", d.metricName, len(d.labels), len(values)))
+	}
+	return strings.Join(values, labelSeparator)
+}
+
+// header writes the HELP and TYPE lines of the metric
+func (d desc) header(w *bufio.Writer, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.metricName, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.metricName, metricType)
+}
+
+// sample writes one sample line with the label values of the key and any extra label
+func (d desc) sample(w *bufio.Writer, suffix string, key string, extra string, value float64) {
+	w.WriteString(d.metricName + suffix)
+
+	var pairs []string
+	if len(d.labels) > 0 {
+		for i, value := range strings.Split(key, labelSeparator) {
+			pairs = append(pairs, d.labels[i]+`="`+escapeLabel(value)+`"`)
+		}
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) > 0 {
+		w.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+
+	w.WriteString(" " + formatValue(value) + "\n")
+}
+
+// Counter is a value that only goes up, such as the number of queries run, per label values
+type Counter struct {
+	desc
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// Counter registers a counter with the label names
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{metricName: name, help: help, labels: labels}, values: make(map[string]float64)}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the series of the label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds a non-negative value to the series of the label values
+func (c *Counter) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		panic(fmt.Sprintf("counter %s cannot decrease", c.metricName))
+	}
+	key := c.key(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] += value
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.header(w, "counter")
+	for _, key := range sortedKeys(c.values) {
+		c.sample(w, "", key, "", c.values[key])
+	}
+}
+
+// Histogram counts observations, such as query durations, in cumulative buckets per label values
+type Histogram struct {
+	desc
+	buckets []float64 // upper bounds, ascending
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64 // per bucket, not cumulative; the last one is +Inf
+	sum    float64
+	count  uint64
+}
+
+// Histogram registers a histogram with the upper bounds of its buckets and the label names
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	bounds := append([]float64(nil), buckets...)
+	sort.Float64s(bounds)
+
+	h := &Histogram{
+		desc:    desc{metricName: name, help: help, labels: labels},
+		buckets: bounds,
+		series:  make(map[string]*histogramSeries),
+	}
+	r.register(h)
+	return h
+}
+
+// Observe adds an observation to the series of the label values
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+	bucket := sort.SearchFloat64s(h.buckets, value) // the first bound not below the value
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+	s.counts[bucket]++
+	s.sum += value
+	s.count++
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.header(w, "histogram")
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			h.sample(w, "_bucket", key, `le="`+formatValue(bound)+`"`, float64(cumulative))
+		}
+		h.sample(w, "_bucket", key, `le="+Inf"`, float64(s.count))
+		h.sample(w, "_sum", key, "", s.sum)
+		h.sample(w, "_count", key, "", float64(s.count))
+	}
+}
+
+// GaugeValue is the value of a gauge for some label values
+type GaugeValue struct {
+	Labels []string
+	Value  float64
+}
+
+// gaugeFunc is a gauge read when the metrics are scraped
+type gaugeFunc struct {
+	desc
+	collect func() []GaugeValue
+}
+
+// GaugeFunc registers a gauge whose values, such as a queue depth, are read from collect when the
+// metrics are scraped
+func (r *Registry) GaugeFunc(name, help string, labels []string, collect func() []GaugeValue) {
+	r.register(&gaugeFunc{desc: desc{metricName: name, help: help, labels: labels}, collect: collect})
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	values := make(map[string]float64)
+	for _, value := range g.collect() {
+		values[g.key(value.Labels)] = value.Value
+	}
+
+	g.header(w, "gauge")
+	for _, key := range sortedKeys(values) {
+		g.sample(w, "", key, "", values[key])
+	}
+}
+
+// DurationBuckets are histogram buckets in seconds from 10 milliseconds to 10 minutes
+var DurationBuckets = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+
+// ExponentialBuckets returns count buckets starting at start, each factor times the previous
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// countingWriter counts the bytes written for WriteTo
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
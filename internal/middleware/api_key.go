@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// APIKeyMiddleware authenticates machine clients (e.g. Power BI) with an API key sent in the
+// X-API-Key header, the same keys JWTMiddleware accepts. Keys in the api_key query parameter are
+// refused, as URLs end up in access logs and BI tool caches.
+func APIKeyMiddleware(apiKeyService service.APIKeyService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Query("api_key") != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid request",
+				"API keys are only accepted in the X-API-Key header",
+			))
+		}
+
+		key := c.Get(APIKeyHeader)
+		if key == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(utils.ErrorResponse(
+				"API key required",
+				"Missing X-API-Key header",
+			))
+		}
+
+		return authenticateAPIKey(c, apiKeyService, key)
+	}
+}
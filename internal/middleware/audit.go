@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"erp-excel/internal/models"
+	"erp-excel/internal/service"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AuditMiddleware records every POST, PUT, PATCH and DELETE request in the audit trail with the
+// user, matched route, a masked payload summary, IP, status code and latency. It must run before
+// authentication so rejected requests such as failed logins are recorded too.
+func AuditMiddleware(auditService service.AuditService, maxPayloadBytes int) fiber.Handler {
+	if maxPayloadBytes <= 0 {
+		maxPayloadBytes = 2000
+	}
+
+	return func(c *fiber.Ctx) error {
+		if !auditService.Enabled() {
+			return c.Next()
+		}
+
+		switch c.Method() {
+		case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+		default:
+			return c.Next()
+		}
+
+		start := time.Now()
+		payload := summarizePayload(string(c.Request().Header.ContentType()), c.Body(), maxPayloadBytes)
+		// Fiber reuses its buffers after the request, and the entry is written asynchronously
+		method := strings.Clone(c.Method())
+		path := strings.Clone(c.Path())
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			// The error handler has not written the response yet
+			status = errorStatus(err)
+		}
+
+		userID, _ := c.Locals("user_id").(int)
+		username, _ := c.Locals("username").(string)
+
+		auditService.Record(&models.AuditLog{
+			UserID:     userID,
+			Username:   username,
+			Method:     method,
+			Route:      c.Route().Path,
+			Path:       path,
+			Payload:    payload,
+			IPAddress:  strings.Clone(c.IP()),
+			StatusCode: status,
+			LatencyMs:  time.Since(start).Milliseconds(),
+			CreatedAt:  start,
+		})
+
+		return err
+	}
+}
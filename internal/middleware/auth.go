@@ -3,23 +3,36 @@ package middleware
 import (
 	"erp-excel/internal/service"
 	"erp-excel/internal/utils"
-	"fmt"
+	"errors"
 	"strings"
 
 	fiber "github.com/gofiber/fiber/v2"
 )
 
-// JWTMiddleware validates JWT tokens
-func JWTMiddleware(authService service.AuthService, whiteList []string) fiber.Handler {
+// APIKeyHeader carries the API key of a machine client
+const APIKeyHeader = "X-API-Key"
+
+// JWTMiddleware validates JWT tokens, and API keys sent in the X-API-Key header or as
+// "Authorization: ApiKey {key}". Users whose roles have the admin operation are administrators.
+func JWTMiddleware(
+	authService service.AuthService,
+	apiKeyService service.APIKeyService,
+	operationService service.OperationService,
+	whiteList []string,
+) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Skip middleware for whitelisted routes
 		for _, route := range whiteList {
-			fmt.Println(c.Path())
 			if c.Path() == route {
 				return c.Next()
 			}
 		}
 
+		// Machine clients may send their API key in its own header instead
+		if key := c.Get(APIKeyHeader); key != "" {
+			return authenticateAPIKey(c, apiKeyService, key)
+		}
+
 		// Get the JWT token from the request
 		authHeader := c.Get("Authorization")
 
@@ -31,12 +44,9 @@ func JWTMiddleware(authService service.AuthService, whiteList []string) fiber.Ha
 			))
 		}
 
-		// Bypass if auth token is super admin
-		if authHeader == "Basic 17c4520f6cfd1ab53d8745e84681eb49" {
-			c.Locals("user_id", 0)
-			c.Locals("username", "super_admin")
-			c.Locals("is_admin", true)
-			return c.Next()
+		// Machine clients authenticate with an API key instead of a token
+		if key, ok := strings.CutPrefix(authHeader, "ApiKey "); ok {
+			return authenticateAPIKey(c, apiKeyService, key)
 		}
 
 		// Check if auth header format is valid
@@ -50,7 +60,6 @@ func JWTMiddleware(authService service.AuthService, whiteList []string) fiber.Ha
 
 		// Validate token
 		tokenString := parts[1]
-		fmt.Println("Token nhận được từ frontend:", tokenString) // Thêm dòng này
 		claims, err := authService.ValidateToken(tokenString)
 		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(utils.ErrorResponse(
@@ -59,6 +68,13 @@ func JWTMiddleware(authService service.AuthService, whiteList []string) fiber.Ha
 			))
 		}
 
+		if authService.IsTokenRevoked(c.UserContext(), tokenString) {
+			return c.Status(fiber.StatusUnauthorized).JSON(utils.ErrorResponse(
+				"Invalid token",
+				"The token was revoked by logging out",
+			))
+		}
+
 		if claims.UserID == 0 {
 			return c.Status(fiber.StatusUnauthorized).JSON(utils.ErrorResponse(
 				"Invalid user",
@@ -66,12 +82,49 @@ func JWTMiddleware(authService service.AuthService, whiteList []string) fiber.Ha
 			))
 		}
 
+		// The role may have changed since the token was issued, so it is not part of the claims
+		isAdmin, err := operationService.IsAdmin(c.UserContext(), claims.UserID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
+				"Error checking permissions",
+				err.Error(),
+			))
+		}
+
 		// Set user info in context
 		c.Locals("user_id", claims.UserID)
 		c.Locals("username", claims.Username)
 		c.Locals("department_id", claims.DepartmentID)
+		c.Locals("company", claims.Company)
+		c.Locals("is_admin", isAdmin)
 
 		// Continue to next handler
 		return c.Next()
 	}
 }
+
+// authenticateAPIKey resolves an API key to who it acts as. A service account has no user or
+// department; its scopes decide which operations it may use, and a key with every scope acts as
+// an administrator. A user's key acts as the user, limited to the operations of its scopes.
+func authenticateAPIKey(c *fiber.Ctx, apiKeyService service.APIKeyService, key string) error {
+	principal, err := apiKeyService.Authenticate(c.UserContext(), strings.TrimSpace(key))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKey) {
+			return c.Status(fiber.StatusUnauthorized).JSON(utils.ErrorResponse(
+				"Invalid API key",
+				"The API key is unknown, revoked or expired",
+			))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
+			"Error checking API key",
+			err.Error(),
+		))
+	}
+
+	c.Locals("user_id", principal.UserID)
+	c.Locals("username", principal.Username)
+	c.Locals("department_id", principal.DepartmentID)
+	c.Locals("is_admin", principal.IsAdmin())
+	c.Locals("api_key", principal)
+	return c.Next()
+}
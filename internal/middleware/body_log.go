@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"erp-excel/config"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// defaultBodyLogMaxBytes cuts logged bodies unless configured
+const defaultBodyLogMaxBytes = 4000
+
+// BodyLogMiddleware logs the headers and body of every request and the body of its response at
+// debug level. JSON fields, headers and query parameters that look like credentials are redacted; uploads, files
+// and other bodies that are not JSON are only described. It only logs when enabled for the
+// server environment and the logger is at debug level, and it must run after
+// RequestIDMiddleware so the lines carry the request ID.
+func BodyLogMiddleware(logger *slog.Logger, cfg config.LogBodiesConfig, env string) fiber.Handler {
+	if !cfg.Enabled || !bodyLogEnvironment(cfg.Environments, env) {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+	maxBytes := cfg.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultBodyLogMaxBytes
+	}
+
+	return func(c *fiber.Ctx) error {
+		if !logger.Enabled(c.UserContext(), slog.LevelDebug) {
+			return c.Next()
+		}
+
+		logger.DebugContext(c.UserContext(), "Request body",
+			"method", c.Method(),
+			"path", logPath(c),
+			"query", redactQuery(c.Request().URI().QueryArgs()),
+			"headers", redactHeaders(c.GetReqHeaders()),
+			"body", summarizePayload(string(c.Request().Header.ContentType()), c.Body(), maxBytes),
+		)
+
+		err := c.Next()
+		if err != nil {
+			// The error handler writes the response after the middleware chain returns
+			logger.DebugContext(c.UserContext(), "Response body", "status", errorStatus(err), "error", err.Error())
+			return err
+		}
+
+		response := c.Response()
+		body := "[stream]"
+		if !response.IsBodyStream() {
+			body = summarizePayload(string(response.Header.ContentType()), response.Body(), maxBytes)
+		}
+		logger.DebugContext(c.UserContext(), "Response body", "status", response.StatusCode(), "body", body)
+
+		return nil
+	}
+}
+
+// bodyLogEnvironment reports whether bodies are logged in the server environment
+func bodyLogEnvironment(environments []string, env string) bool {
+	if len(environments) == 0 {
+		environments = []string{"development"}
+	}
+	for _, environment := range environments {
+		if strings.EqualFold(strings.TrimSpace(environment), env) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactQuery returns the query string of a request with the values of credential parameters,
+// such as an api_key sent by mistake and the signature of download links, redacted
+func redactQuery(args *fasthttp.Args) string {
+	var query strings.Builder
+	args.VisitAll(func(key, value []byte) {
+		if query.Len() > 0 {
+			query.WriteByte('&')
+		}
+		query.WriteString(url.QueryEscape(string(key)))
+		query.WriteByte('=')
+		if isSensitiveKey(string(key)) {
+			query.WriteString(redactedValue)
+			return
+		}
+		query.WriteString(url.QueryEscape(string(value)))
+	})
+	return query.String()
+}
+
+// redactHeaders returns the request headers with the values of credential headers such as
+// Authorization, Cookie and X-API-Key redacted
+func redactHeaders(headers map[string][]string) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for name, values := range headers {
+		if isSensitiveKey(name) {
+			redacted[name] = redactedValue
+			continue
+		}
+		redacted[name] = strings.Join(values, ", ")
+	}
+	return redacted
+}
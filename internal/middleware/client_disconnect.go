@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// disconnectPollInterval is how often the connection of a running request is checked
+const disconnectPollInterval = 500 * time.Millisecond
+
+// ParentContextKey is the request user value holding the context of the request that dispatched
+// an internal sub-request, such as an operation of a batch
+const ParentContextKey = "parent_context"
+
+// RequestTracker counts the running requests so shutdown can wait for them to finish, and
+// cancels them when they outlast the shutdown timeout
+type RequestTracker struct {
+	inFlight atomic.Int64
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewRequestTracker creates a tracker for CancelOnDisconnectMiddleware
+func NewRequestTracker() *RequestTracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &RequestTracker{ctx: ctx, cancel: cancel}
+}
+
+// InFlight returns the number of requests running
+func (t *RequestTracker) InFlight() int64 {
+	return t.inFlight.Load()
+}
+
+// Cancel cancels the context of every running request
+func (t *RequestTracker) Cancel() {
+	t.cancel()
+}
+
+// Wait returns once no request is running, or with the error of ctx when it is done first
+func (t *RequestTracker) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for t.InFlight() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// CancelOnDisconnectMiddleware gives every request a context that is cancelled when the client
+// closes its connection or the tracker cancels it at shutdown, so ERP queries and Excel
+// generation stop instead of running to completion for nobody. Handlers pass c.UserContext()
+// to services. The server shutting down does not cancel requests by itself; they are drained.
+func CancelOnDisconnectMiddleware(tracker *RequestTracker) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tracker.inFlight.Add(1)
+		defer tracker.inFlight.Add(-1)
+
+		parent := c.UserContext()
+		if dispatcher, ok := c.Context().UserValue(ParentContextKey).(context.Context); ok {
+			parent = dispatcher
+		}
+
+		ctx, cancel := context.WithCancel(parent)
+		defer cancel()
+		c.SetUserContext(ctx)
+
+		// Batch sub-requests follow their parent; they and tests have no connection to watch
+		conn := c.Context().Conn()
+		if conn == nil {
+			return c.Next()
+		}
+
+		go func() {
+			ticker := time.NewTicker(disconnectPollInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-tracker.ctx.Done():
+					cancel()
+					return
+				case <-ticker.C:
+					if connClosed(conn) {
+						cancel()
+						return
+					}
+				}
+			}
+		}()
+
+		return c.Next()
+	}
+}
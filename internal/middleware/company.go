@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"erp-excel/config"
+	"erp-excel/database"
+	"erp-excel/internal/utils"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CompanyHeader names the company whose ERP database a request reads
+const CompanyHeader = "X-Company"
+
+// CompanyMiddleware picks the company of the request from the X-Company header, the company
+// query parameter or the company of the token, in that order, and carries it in the request
+// context, so the ERP repositories query that company's database. Requests that name no
+// company use the default one. Unknown companies, and companies the user's department may not
+// select, are rejected. It runs after the JWT middleware.
+func CompanyMiddleware(companies map[string]config.ERPCompanyConfig, defaultCompany string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		company := c.Get(CompanyHeader)
+		if company == "" {
+			company = c.Query("company")
+		}
+		if company == "" {
+			company, _ = c.Locals("company").(string)
+		}
+		company = strings.ToLower(strings.TrimSpace(company))
+		if company == "" {
+			company = defaultCompany
+		}
+
+		registered, ok := companies[company]
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Unknown company",
+				"No ERP database is configured for company "+company,
+			))
+		}
+
+		isAdmin, _ := c.Locals("is_admin").(bool)
+		departmentID, _ := c.Locals("department_id").(int)
+		if !isAdmin && !registered.Allows(departmentID) {
+			return c.Status(fiber.StatusForbidden).JSON(utils.ErrorResponse(
+				"Company not allowed",
+				"Your department may not access company "+company,
+			))
+		}
+
+		c.Locals("company", company)
+		c.SetUserContext(database.WithCompany(c.UserContext(), company))
+		return c.Next()
+	}
+}
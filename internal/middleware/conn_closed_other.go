@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd
+
+package middleware
+
+import "net"
+
+// connClosed cannot peek sockets on this platform, so requests only stop on server shutdown
+func connClosed(conn net.Conn) bool {
+	return false
+}
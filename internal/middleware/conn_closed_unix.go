@@ -0,0 +1,41 @@
+//go:build linux || darwin || freebsd
+
+package middleware
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// connClosed reports whether the peer has closed the connection. The socket is peeked without
+// blocking, so bytes of a pipelined request are left for the server to read.
+func connClosed(conn net.Conn) bool {
+	sysConn, ok := conn.(syscall.Conn)
+	if !ok {
+		return false
+	}
+	rawConn, err := sysConn.SyscallConn()
+	if err != nil {
+		return false
+	}
+
+	closed := false
+	buf := make([]byte, 1)
+	err = rawConn.Read(func(fd uintptr) bool {
+		n, _, err := syscall.Recvfrom(int(fd), buf, syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		switch {
+		case err == nil:
+			closed = n == 0 // orderly shutdown by the client
+		case errors.Is(err, syscall.EAGAIN), errors.Is(err, syscall.EWOULDBLOCK), errors.Is(err, syscall.EINTR):
+		default:
+			closed = true // reset or otherwise broken
+		}
+		return true
+	})
+	if err != nil {
+		return true
+	}
+
+	return closed
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"erp-excel/internal/apperror"
 	"erp-excel/internal/utils"
 
 	fiber "github.com/gofiber/fiber/v2"
@@ -21,9 +22,10 @@ func DepartmentFilterMiddleware(adminOnly bool) fiber.Handler {
 
 		// If route is admin-only and user is not admin, reject
 		if adminOnly && !isAdmin {
-			return c.Status(fiber.StatusForbidden).JSON(utils.ErrorResponse(
+			return c.Status(fiber.StatusForbidden).JSON(utils.CodedErrorResponse(
 				"Permission denied",
 				"This operation requires administrative privileges",
+				apperror.CodePermissionDenied,
 			))
 		}
 
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"erp-excel/config"
+	"erp-excel/internal/utils"
+	"slices"
+	"strings"
+
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// exportRouteRule is a direct export route and whether its exports need approval
+type exportRouteRule struct {
+	routeRule
+	path     string
+	approval bool
+}
+
+// ExportApprovalMiddleware refuses the direct exports of reports that need approval, so their
+// files are only generated through queued exports once a manager approved them. routes tie the
+// direct export routes to the operation guarding them; administrators are let through. The
+// first route matching a request decides with every operation listed for its path, so fixed
+// paths are listed before the patterns that would match them too.
+func ExportApprovalMiddleware(cfg config.ExportApprovalConfig, prefix string, routes []RoutePermission) fiber.Handler {
+	rules := make([]exportRouteRule, 0, len(routes))
+	for _, route := range routes {
+		rules = append(rules, exportRouteRule{
+			routeRule: routeRule{
+				method:   strings.ToUpper(route.Method),
+				segments: pathSegments(route.Path),
+			},
+			path:     route.Path,
+			approval: cfg.RequiresApproval(route.OperationCode),
+		})
+	}
+	enabled := slices.ContainsFunc(rules, func(rule exportRouteRule) bool { return rule.approval })
+
+	return func(c *fiber.Ctx) error {
+		if !enabled {
+			return c.Next()
+		}
+		if isAdmin, _ := c.Locals("is_admin").(bool); isAdmin {
+			return c.Next()
+		}
+
+		segments := pathSegments(strings.TrimPrefix(c.Path(), prefix))
+		matched := ""
+		for _, rule := range rules {
+			if matched != "" && rule.path != matched {
+				continue
+			}
+			if rule.method != c.Method() || !matchSegments(rule.segments, segments) {
+				continue
+			}
+			matched = rule.path
+			if rule.approval {
+				return c.Status(fiber.StatusForbidden).JSON(utils.ErrorResponse(
+					"Export requires approval",
+					"This report's exports need a manager's approval, which only /async exports wait for",
+				))
+			}
+		}
+		return c.Next()
+	}
+}
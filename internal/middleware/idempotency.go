@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"erp-excel/config"
+	"erp-excel/internal/cache"
+	"erp-excel/internal/utils"
+	"fmt"
+	"time"
+
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// IdempotencyKeyHeader is the request header that identifies a retried POST request
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const (
+	// idempotencyKeyPrefix starts the store keys of the recorded responses, and
+	// idempotencyLockPrefix those of the requests in progress
+	idempotencyKeyPrefix  = "idempotency:"
+	idempotencyLockPrefix = "idempotency:lock:"
+
+	// idempotencyLockTTL bounds how long a key stays in progress when its instance dies mid-request
+	idempotencyLockTTL = 15 * time.Minute
+
+	// Headers of export files: the signed link to the stored file and its SHA-256
+	downloadURLHeader = "X-Download-URL"
+	checksumHeader    = "X-Checksum"
+)
+
+// idempotentResponse is a finished response kept for replay. Bodies above the size limit are
+// not kept: the response keeps the link to the stored file when it is an export, and the
+// checksum of the body otherwise.
+type idempotentResponse struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"`
+	Headers     [][2]string `json:"headers,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+	BodyOmitted bool        `json:"body_omitted,omitempty"`
+	DownloadURL string      `json:"download_url,omitempty"`
+	Checksum    string      `json:"checksum,omitempty"`
+}
+
+// IdempotencyMiddleware replays the first successful response of an authenticated POST request when
+// the client retries it with the same Idempotency-Key, so a retry after a timeout does not export a
+// report or create a user twice. Keys are scoped to the user and path; reusing a key with a
+// different body is rejected. Failed responses are not kept, so the retry runs again.
+//
+// Responses live in the store, so a retry landing on another instance is replayed too when it is
+// shared. Bodies up to MaxBodyBytes are kept whole; a retried export above it is redirected to
+// the download link of the file generated the first time.
+func IdempotencyMiddleware(cfg config.IdempotencyConfig, store cache.Store) fiber.Handler {
+	ttl := time.Duration(cfg.TTLMinutes) * time.Minute
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	maxBodyBytes := cfg.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = 64 << 10
+	}
+
+	return func(c *fiber.Ctx) error {
+		key := c.Get(IdempotencyKeyHeader)
+		userID, authenticated := c.Locals("user_id").(int)
+		if key == "" || c.Method() != fiber.MethodPost || !authenticated {
+			return c.Next()
+		}
+
+		if len(key) > 255 {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorResponse(
+				"Invalid idempotency key",
+				"Idempotency-Key must be at most 255 characters",
+			))
+		}
+
+		username, _ := c.Locals("username").(string)
+		scope := sha256.Sum256([]byte(fmt.Sprintf("%d|%s|%s|%s", userID, username, c.Path(), key)))
+		recordKey := idempotencyKeyPrefix + hex.EncodeToString(scope[:])
+		fingerprint := sha256.Sum256(c.Body())
+
+		// A store that cannot be reached leaves the request to run as if it had no key
+		ctx := c.UserContext()
+		entry, found, err := loadIdempotentResponse(ctx, store, recordKey)
+		if err != nil {
+			return c.Next()
+		}
+		if !found {
+			release, locked, err := store.TryLock(ctx, idempotencyLockPrefix+hex.EncodeToString(scope[:]), idempotencyLockTTL)
+			if err != nil {
+				return c.Next()
+			}
+			if locked {
+				defer release()
+			}
+			// The first request may have finished since the lookup
+			entry, found, _ = loadIdempotentResponse(ctx, store, recordKey)
+			if !found && !locked {
+				return c.Status(fiber.StatusConflict).JSON(utils.ErrorResponse(
+					"Request in progress",
+					"A request with this Idempotency-Key is still being processed",
+				))
+			}
+		}
+		if found {
+			return replayIdempotentResponse(c, entry, hex.EncodeToString(fingerprint[:]))
+		}
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status < 200 || status >= 300 {
+			return nil
+		}
+
+		entry = &idempotentResponse{Fingerprint: hex.EncodeToString(fingerprint[:]), Status: status}
+		response := c.Response()
+		// A stream is only read into memory when it is small enough to keep
+		size := response.Header.ContentLength()
+		if !response.IsBodyStream() {
+			size = len(response.Body())
+		}
+		if size >= 0 && size <= maxBodyBytes {
+			response.Header.VisitAll(func(name, value []byte) {
+				switch string(name) {
+				case fiber.HeaderContentLength, fiber.HeaderDate, fiber.HeaderServer, fiber.HeaderConnection:
+					return
+				}
+				entry.Headers = append(entry.Headers, [2]string{string(name), string(value)})
+			})
+			// Body drains a stream, which is then sent from memory once
+			entry.Body = append([]byte(nil), response.Body()...)
+		} else {
+			entry.BodyOmitted = true
+			entry.DownloadURL = string(response.Header.Peek(downloadURLHeader))
+			entry.Checksum = string(response.Header.Peek(checksumHeader))
+			if entry.Checksum == "" && !response.IsBodyStream() {
+				sum := sha256.Sum256(response.Body())
+				entry.Checksum = hex.EncodeToString(sum[:])
+			}
+		}
+
+		if value, err := json.Marshal(entry); err == nil {
+			store.Set(ctx, recordKey, value, ttl)
+		}
+		return nil
+	}
+}
+
+// loadIdempotentResponse reads the response recorded under a key
+func loadIdempotentResponse(ctx context.Context, store cache.Store, recordKey string) (*idempotentResponse, bool, error) {
+	value, found, err := store.Get(ctx, recordKey)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	var entry idempotentResponse
+	if err := json.Unmarshal(value, &entry); err != nil {
+		return nil, false, err
+	}
+	return &entry, true, nil
+}
+
+// replayIdempotentResponse answers a retry with the recorded response
+func replayIdempotentResponse(c *fiber.Ctx, entry *idempotentResponse, fingerprint string) error {
+	if entry.Fingerprint != fingerprint {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(utils.ErrorResponse(
+			"Idempotency key reused",
+			"Idempotency-Key was already used for a different request",
+		))
+	}
+
+	c.Set("Idempotent-Replayed", "true")
+	if entry.Checksum != "" {
+		c.Set(checksumHeader, entry.Checksum)
+	}
+	switch {
+	case !entry.BodyOmitted:
+		for _, header := range entry.Headers {
+			c.Set(header[0], header[1])
+		}
+		return c.Status(entry.Status).Send(entry.Body)
+	case entry.DownloadURL != "":
+		c.Set(downloadURLHeader, entry.DownloadURL)
+		return c.Redirect(entry.DownloadURL, fiber.StatusSeeOther)
+	default:
+		return c.Status(fiber.StatusConflict).JSON(utils.ErrorResponse(
+			"Request already processed",
+			"The response to this Idempotency-Key is too large to be replayed; send the request with a new key to run it again",
+		))
+	}
+}
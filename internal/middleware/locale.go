@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"erp-excel/internal/translate"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LocaleMiddleware picks the language of the request from the lang query parameter or the
+// Accept-Language header and carries it in the request context, so Excel headers and report
+// titles are written in it. The message of JSON responses is translated as well, but only when
+// the request asked for a language: clients that never did keep the English messages.
+func LocaleMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		locale, explicit := translate.Resolve(c.Query("lang"), c.Get(fiber.HeaderAcceptLanguage))
+
+		c.Locals("locale", locale)
+		c.SetUserContext(translate.WithLocale(c.UserContext(), locale))
+		c.Set(fiber.HeaderContentLanguage, locale)
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		if explicit {
+			translateResponseMessage(c, locale)
+		}
+		return nil
+	}
+}
+
+// translateResponseMessage replaces the message field of a JSON response body with its
+// translation. Bodies that are not a JSON object are left as they are.
+func translateResponseMessage(c *fiber.Ctx, locale string) {
+	if !bytes.HasPrefix(c.Response().Header.ContentType(), []byte(fiber.MIMEApplicationJSON)) {
+		return
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(c.Response().Body(), &body); err != nil {
+		return
+	}
+
+	var message string
+	if err := json.Unmarshal(body["message"], &message); err != nil || message == "" {
+		return
+	}
+	translated := translate.Message(locale, message)
+	if translated == message {
+		return
+	}
+
+	encoded, err := json.Marshal(translated)
+	if err != nil {
+		return
+	}
+	body["message"] = encoded
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return
+	}
+	c.Response().SetBodyRaw(data)
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/service"
+	"erp-excel/internal/utils"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RoutePermission ties a route to the operation a user needs to call it. Path is relative to the
+// group the middleware is mounted on and may contain :param segments; a last * segment matches
+// the rest of the path, if any. An empty Method matches every method. An empty OperationCode lets
+// every signed-in caller through, for routes every user needs and for routes whose handler checks
+// an operation of its own.
+type RoutePermission struct {
+	Method        string
+	Path          string
+	OperationCode string
+}
+
+type routeRule struct {
+	method   string
+	segments []string
+	check    fiber.Handler
+}
+
+// PermissionMiddleware checks the operation of the first permission matching the request method
+// and path, the same way RoleCheckMiddleware does. Requests no permission matches are denied, so
+// a new route is closed until it is given a permission.
+func PermissionMiddleware(operationService service.OperationService, prefix string, permissions []RoutePermission) fiber.Handler {
+	requireOperation := RoleCheckMiddleware(operationService)
+
+	rules := make([]routeRule, 0, len(permissions))
+	for _, permission := range permissions {
+		check := signedIn
+		if permission.OperationCode != "" {
+			check = requireOperation(permission.OperationCode)
+		}
+		rules = append(rules, routeRule{
+			method:   strings.ToUpper(permission.Method),
+			segments: pathSegments(permission.Path),
+			check:    check,
+		})
+	}
+
+	return func(c *fiber.Ctx) error {
+		segments := pathSegments(strings.TrimPrefix(c.Path(), prefix))
+		for _, rule := range rules {
+			if (rule.method == "" || rule.method == c.Method()) && matchSegments(rule.segments, segments) {
+				return rule.check(c)
+			}
+		}
+		return c.Status(fiber.StatusForbidden).JSON(utils.CodedErrorResponse(
+			"Permission denied",
+			"No permission is defined for this route",
+			apperror.CodePermissionDenied,
+		))
+	}
+}
+
+// signedIn lets every caller the authentication middleware accepted through
+func signedIn(c *fiber.Ctx) error {
+	return c.Next()
+}
+
+// pathSegments splits a path into its non-empty segments, so trailing slashes do not matter
+func pathSegments(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+}
+
+// matchSegments reports whether a path matches a pattern; a :param segment matches any segment
+// and a last * segment any number of them
+func matchSegments(pattern, path []string) bool {
+	if n := len(pattern); n > 0 && pattern[n-1] == "*" {
+		if len(path) < n-1 {
+			return false
+		}
+		pattern, path = pattern[:n-1], path[:n-1]
+	}
+	if len(pattern) != len(path) {
+		return false
+	}
+	for i, segment := range pattern {
+		if strings.HasPrefix(segment, ":") {
+			continue
+		}
+		if !strings.EqualFold(segment, path[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/internal/cache"
+	"erp-excel/internal/utils"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// Route identifies the routes a middleware applies to. Path is relative to the group the
+// middleware is mounted on and may contain :param segments.
+type Route struct {
+	Method string
+	Path   string
+}
+
+// rateLimitKeyPrefix starts the store keys of the rate limit counters
+const rateLimitKeyPrefix = "rate_limit:"
+
+type rateLimiter struct {
+	store  cache.Store
+	window time.Duration
+}
+
+// RateLimitMiddleware limits how many requests to the given routes a user and an IP address may
+// make per window, answering 429 with a Retry-After header over the limit. It must run after
+// authentication so requests are counted against their user. Counters live in the store, so
+// the instances enforce the limits together when it is shared and each on its own otherwise.
+func RateLimitMiddleware(cfg config.RateLimitConfig, store cache.Store, prefix string, routes []Route) fiber.Handler {
+	if !cfg.Enabled || (cfg.PerUser <= 0 && cfg.PerIP <= 0) {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	limiter := &rateLimiter{
+		store:  store,
+		window: time.Duration(cfg.WindowSeconds) * time.Second,
+	}
+	if limiter.window <= 0 {
+		limiter.window = time.Minute
+	}
+
+	rules := make([]routeRule, 0, len(routes))
+	for _, route := range routes {
+		rules = append(rules, routeRule{
+			method:   strings.ToUpper(route.Method),
+			segments: pathSegments(route.Path),
+		})
+	}
+
+	return func(c *fiber.Ctx) error {
+		segments := pathSegments(strings.TrimPrefix(c.Path(), prefix))
+		matched := false
+		for _, rule := range rules {
+			if rule.method == c.Method() && matchSegments(rule.segments, segments) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return c.Next()
+		}
+
+		var keys []string
+		var limits []int
+		if userID, ok := c.Locals("user_id").(int); ok && userID > 0 && cfg.PerUser > 0 {
+			keys = append(keys, "user:"+strconv.Itoa(userID))
+			limits = append(limits, cfg.PerUser)
+		}
+		if cfg.PerIP > 0 {
+			keys = append(keys, "ip:"+c.IP())
+			limits = append(limits, cfg.PerIP)
+		}
+
+		if retryAfter := limiter.allow(c.UserContext(), keys, limits); retryAfter > 0 {
+			seconds := int((retryAfter + time.Second - 1) / time.Second)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+			return c.Status(fiber.StatusTooManyRequests).JSON(utils.ErrorResponse(
+				"Too many exports",
+				fmt.Sprintf("Export limit reached, try again in %d seconds", seconds),
+			))
+		}
+
+		return c.Next()
+	}
+}
+
+// allow counts the request against every key when all of them are under their limit. Otherwise
+// nothing is counted and it returns how long to wait until the request would be allowed. Requests
+// of other instances may slip in between the check and the count, so a burst can go a request or
+// two over the limit.
+func (l *rateLimiter) allow(ctx context.Context, keys []string, limits []int) time.Duration {
+	var retryAfter time.Duration
+	for i, key := range keys {
+		count, left, err := l.store.Count(ctx, rateLimitKeyPrefix+key)
+		if err != nil {
+			continue
+		}
+		if count >= int64(limits[i]) && left > retryAfter {
+			retryAfter = left
+		}
+	}
+	if retryAfter > 0 {
+		return retryAfter
+	}
+
+	for _, key := range keys {
+		l.store.Increment(ctx, rateLimitKeyPrefix+key, l.window)
+	}
+	return 0
+}
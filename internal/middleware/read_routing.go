@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"erp-excel/database"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReadRoutingMiddleware sends the reads of requests that may write to the primary app database,
+// so they read their own writes; only GET and HEAD requests read from the read replica
+func ReadRoutingMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead:
+		default:
+			c.SetUserContext(database.WithPrimaryReads(c.UserContext()))
+		}
+		return c.Next()
+	}
+}
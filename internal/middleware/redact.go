@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// redactedValue replaces the values of sensitive fields in logged and audited payloads
+const redactedValue = "***"
+
+// sensitiveKeys are matched case-insensitively against parts of JSON field, header and query
+// parameter names
+var sensitiveKeys = []string{"password", "token", "secret", "api_key", "apikey", "api-key", "authorization", "cookie", "samlresponse", "signature", "credential"}
+
+// credentialPathPrefixes start the paths whose next segment is a credential, for clients that
+// cannot send headers, such as the token of a calendar feed URL
+var credentialPathPrefixes = []string{"/calendar/"}
+
+// logPath returns the path of a request with any credential segment redacted, for the logs
+func logPath(c *fiber.Ctx) string {
+	path := c.Path()
+	for _, prefix := range credentialPathPrefixes {
+		// Routes are case-insensitive, so the prefix is too
+		if len(path) > len(prefix) && strings.EqualFold(path[:len(prefix)], prefix) {
+			return path[:len(prefix)] + redactedValue
+		}
+	}
+	return path
+}
+
+// summarizePayload returns the JSON body with sensitive fields masked, or a short description of
+// other bodies, cut to maxBytes
+func summarizePayload(contentType string, body []byte, maxBytes int) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	if mediaType != fiber.MIMEApplicationJSON {
+		// Uploads and forms are not stored; they may hold files or credentials
+		return fmt.Sprintf("[%s, %d bytes]", mediaType, len(body))
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Sprintf("[invalid json, %d bytes]", len(body))
+	}
+
+	masked, err := json.Marshal(maskSensitive(value))
+	if err != nil {
+		return fmt.Sprintf("[json, %d bytes]", len(body))
+	}
+
+	summary := string(masked)
+	if len(summary) > maxBytes {
+		summary = strings.ToValidUTF8(summary[:maxBytes], "") + "...[truncated]"
+	}
+	return summary
+}
+
+// maskSensitive replaces the values of sensitive keys anywhere in a decoded JSON value
+func maskSensitive(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, item := range typed {
+			if isSensitiveKey(key) {
+				typed[key] = redactedValue
+				continue
+			}
+			typed[key] = maskSensitive(item)
+		}
+		return typed
+	case []interface{}:
+		for i, item := range typed {
+			typed[i] = maskSensitive(item)
+		}
+		return typed
+	default:
+		return value
+	}
+}
+
+// isSensitiveKey reports whether a JSON field, header or query parameter name looks like it holds
+// a credential
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
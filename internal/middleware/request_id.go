@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"erp-excel/internal/logging"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps request IDs supplied by clients
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware tags the request with an ID, taken from the X-Request-ID header, from the
+// request that dispatched it for batch operations, or newly generated. The ID is returned in
+// the response header and carried by the request context, so every line logged with
+// c.UserContext() can be correlated across handlers, services and repositories.
+func RequestIDMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestID := strings.TrimSpace(c.Get(RequestIDHeader))
+		if !validRequestID(requestID) {
+			requestID = logging.RequestID(c.UserContext())
+		}
+		if requestID == "" {
+			requestID = utils.UUIDv4()
+		}
+		// Fiber reuses the header buffer after the request, while the ID may outlive it in logs
+		requestID = strings.Clone(requestID)
+
+		c.Set(RequestIDHeader, requestID)
+		c.Locals("request_id", requestID)
+		c.SetUserContext(logging.WithRequestID(c.UserContext(), requestID))
+
+		return c.Next()
+	}
+}
+
+// validRequestID reports whether a client supplied ID is short and printable enough to log
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"erp-excel/internal/apperror"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestLogMiddleware writes one structured line per request with its method, path, status,
+// latency, client IP and user. Server errors are logged as errors and client errors as warnings.
+// It must run after RequestIDMiddleware so the line carries the request ID.
+func RequestLogMiddleware(logger *slog.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			// The error handler has not written the response yet
+			status = errorStatus(err)
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= fiber.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		userID, _ := c.Locals("user_id").(int)
+		attrs := []slog.Attr{
+			slog.String("method", c.Method()),
+			slog.String("path", logPath(c)),
+			slog.Int("status", status),
+			slog.Int64("latency_ms", time.Since(start).Milliseconds()),
+			slog.String("ip", c.IP()),
+			slog.Int("user_id", userID),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+		logger.LogAttrs(c.UserContext(), level, "Request", attrs...)
+
+		return err
+	}
+}
+
+// errorStatus returns the status the error handler answers an error returned down the chain with
+func errorStatus(err error) int {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	}
+	return apperror.StatusOf(err)
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"erp-excel/internal/apperror"
 	"erp-excel/internal/service"
 	"erp-excel/internal/utils"
 
@@ -15,6 +16,20 @@ func RoleCheckMiddleware(operationService service.OperationService) func(string)
 			if isAdmin {
 				return c.Next()
 			}
+			// API keys may use the operations they are scoped to; a user's key also needs the
+			// user to still have the operation
+			if principal, ok := c.Locals("api_key").(*service.APIKeyPrincipal); ok {
+				if !principal.Allows(operationCode) {
+					return c.Status(fiber.StatusForbidden).JSON(utils.CodedErrorResponse(
+						"Permission denied",
+						"The API key is not scoped to this operation",
+						apperror.CodePermissionDenied,
+					))
+				}
+				if principal.UserID == 0 {
+					return c.Next()
+				}
+			}
 			// Get user ID from context
 			userID, ok := c.Locals("user_id").(int)
 			if !ok || userID == 0 {
@@ -25,7 +40,7 @@ func RoleCheckMiddleware(operationService service.OperationService) func(string)
 			}
 
 			// Check if user has permission for the operation
-			hasAccess, err := operationService.CheckUserAccess(c.Context(), userID, operationCode)
+			hasAccess, err := operationService.CheckUserAccess(c.UserContext(), userID, operationCode)
 			if err != nil {
 				return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorResponse(
 					"Error checking permissions",
@@ -34,9 +49,10 @@ func RoleCheckMiddleware(operationService service.OperationService) func(string)
 			}
 
 			if !hasAccess {
-				return c.Status(fiber.StatusForbidden).JSON(utils.ErrorResponse(
+				return c.Status(fiber.StatusForbidden).JSON(utils.CodedErrorResponse(
 					"Permission denied",
 					"You don't have permission to perform this operation",
+					apperror.CodePermissionDenied,
 				))
 			}
 
@@ -45,3 +61,25 @@ func RoleCheckMiddleware(operationService service.OperationService) func(string)
 		}
 	}
 }
+
+// HasOperation reports whether the request may perform the operation, checked the same way as
+// RoleCheckMiddleware, for handlers whose operation depends on the request body
+func HasOperation(c *fiber.Ctx, operationService service.OperationService, operationCode string) (bool, error) {
+	if isAdmin, _ := c.Locals("is_admin").(bool); isAdmin {
+		return true, nil
+	}
+	if principal, ok := c.Locals("api_key").(*service.APIKeyPrincipal); ok {
+		if !principal.Allows(operationCode) {
+			return false, nil
+		}
+		if principal.UserID == 0 {
+			return true, nil
+		}
+	}
+
+	userID, ok := c.Locals("user_id").(int)
+	if !ok || userID == 0 {
+		return false, nil
+	}
+	return operationService.CheckUserAccess(c.UserContext(), userID, operationCode)
+}
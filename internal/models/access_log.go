@@ -11,4 +11,7 @@ type AccessLog struct {
 	SearchParams string    `json:"search_params,omitempty"`
 	IPAddress    string    `json:"ip_address,omitempty"`
 	Status       string    `json:"status"`
+	Company      string    `json:"company,omitempty"` // ERP company the operation worked on
+
+	OperationName string `json:"operation_name,omitempty"` // filled by queries that join operations
 }
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// APIKey authenticates a machine client instead of a signed-in user: a service account, or a
+// user's own integration acting on their behalf. Only the hash of the key is stored; the key
+// itself is shown once when it is created.
+type APIKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`            // first characters of the key, to recognise it and look it up
+	KeyHash    string     `json:"-"`                 // hex SHA-256 of the key
+	Scopes     []string   `json:"scopes"`            // operation codes the key may use, "*" for all of them
+	UserID     *int       `json:"user_id,omitempty"` // user the key acts as, nil for a service account
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedBy  int        `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Active reports whether the key is neither revoked nor expired at the given time
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
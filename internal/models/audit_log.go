@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// AuditLog records one mutating API request
+type AuditLog struct {
+	ID         int64     `json:"id"`
+	UserID     int       `json:"user_id"` // 0 for unauthenticated requests such as a failed login
+	Username   string    `json:"username,omitempty"`
+	Method     string    `json:"method"`
+	Route      string    `json:"route"` // matched route pattern, e.g. /api/users/:id
+	Path       string    `json:"path"`
+	Payload    string    `json:"payload,omitempty"` // summary of the request body with secrets masked
+	IPAddress  string    `json:"ip_address"`
+	StatusCode int       `json:"status_code"`
+	LatencyMs  int64     `json:"latency_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
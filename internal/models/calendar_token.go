@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// CalendarToken is the credential in the URL of a user's calendar feed. Only its hash is stored;
+// the URL is shown once when the token is issued.
+type CalendarToken struct {
+	UserID     int        `json:"user_id"`
+	TokenHash  string     `json:"-"` // hex SHA-256 of the token
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
@@ -0,0 +1,102 @@
+package models
+
+import "time"
+
+// Statuses of an import batch
+const (
+	ImportPreviewed = "previewed" // uploaded and validated, waiting to be committed
+	ImportCommitted = "committed"
+	ImportDiscarded = "discarded"
+)
+
+// Statuses of an imported row
+const (
+	ImportRowChanged   = "changed"   // differs from the ERP, committed with the batch
+	ImportRowUnchanged = "unchanged" // same as the ERP, nothing to correct
+	ImportRowInvalid   = "invalid"   // failed validation, left out of the commit
+)
+
+// ImportBatch is one uploaded Excel sheet of ERP corrections, staged until a user commits it
+type ImportBatch struct {
+	ID            int       `json:"id"`
+	ImportType    string    `json:"import_type"` // e.g. invoice_number
+	FileName      string    `json:"file_name"`
+	Status        string    `json:"status"` // previewed, committed, discarded
+	TotalRows     int       `json:"total_rows"`
+	ChangedRows   int       `json:"changed_rows"`
+	UnchangedRows int       `json:"unchanged_rows"`
+	InvalidRows   int       `json:"invalid_rows"`
+	UserID        int       `json:"user_id"`
+	CreatedAt     time.Time `json:"created_at"`
+
+	// Set once the batch was committed or discarded
+	DecidedBy *int       `json:"decided_by,omitempty"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+
+	// Filled by queries that join users
+	Username string `json:"username,omitempty"`
+}
+
+// ImportRow is one staged row of an import batch with its value in the ERP at upload
+type ImportRow struct {
+	ID        int64             `json:"id"`
+	BatchID   int               `json:"batch_id"`
+	RowNumber int               `json:"row_number"` // row of the sheet as Excel numbers it
+	Key       string            `json:"key"`        // document the row corrects, e.g. a sales order number
+	OldValue  string            `json:"old_value"`
+	NewValue  string            `json:"new_value"`
+	Status    string            `json:"status"` // changed, unchanged, invalid
+	Errors    []ImportCellError `json:"errors,omitempty"`
+}
+
+// Error codes of imported cells: the rule types, and the checks every import makes
+const (
+	ImportErrorNotNumber   = "not_number"   // a range rule on a cell that is not a number
+	ImportErrorDuplicate   = "duplicate"    // the document appears again with another value
+	ImportErrorNotFound    = "not_found"    // the document is not in the ERP
+	ImportErrorERPState    = "erp_state"    // the ERP document cannot be corrected, e.g. it is voided
+	ImportErrorERPModified = "erp_modified" // the ERP value changed between preview and commit
+)
+
+// ImportCellError is a validation error of one cell of an imported row. Code is machine-readable:
+// the type of the failed rule or one of the ImportError codes.
+type ImportCellError struct {
+	Column  string `json:"column"`
+	Code    string `json:"code"`
+	Value   string `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+// Types of import validation rules
+const (
+	ImportRuleRequired  = "required"
+	ImportRulePattern   = "pattern"    // the cell matches a regular expression
+	ImportRuleRange     = "range"      // the cell is a number between min and max
+	ImportRuleMaxLength = "max_length" // the cell has at most max characters
+	ImportRuleLookup    = "lookup"     // the cell is a key of an ERP master table
+)
+
+// Sources of import validation rules
+const (
+	ImportRuleBuiltIn = "builtin"
+	ImportRuleConfig  = "config"
+	ImportRuleCustom  = "custom" // managed in the database
+)
+
+// ImportRule validates one column of the sheets of an import type. Every column a rule names must
+// be in the sheet; cells left empty are only checked by required rules.
+type ImportRule struct {
+	ID         int      `json:"id,omitempty"` // set for rules managed in the database
+	ImportType string   `json:"import_type"`
+	Column     string   `json:"column"` // export column key, e.g. invoice_number
+	Type       string   `json:"type"`   // required, pattern, range, max_length or lookup
+	Pattern    string   `json:"pattern,omitempty"`
+	Min        *float64 `json:"min,omitempty"`
+	Max        *float64 `json:"max,omitempty"`
+	Lookup     string   `json:"lookup,omitempty"`  // ERP table, e.g. customer or item
+	Message    string   `json:"message,omitempty"` // replaces the default error message
+	Source     string   `json:"source"`            // builtin, config or custom
+
+	CreatedBy int        `json:"created_by,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
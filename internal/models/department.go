@@ -4,12 +4,14 @@ import "time"
 
 // Department represents a department in the organization
 type Department struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Code        string    `json:"code"`
-	Description string    `json:"description,omitempty"`
-	IsActive    bool      `json:"is_active"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Users       []*User   `json:"users,omitempty"`
+	ID                 int        `json:"id"`
+	Name               string     `json:"name"`
+	Code               string     `json:"code"`
+	Description        string     `json:"description,omitempty"`
+	ParentDepartmentID *int       `json:"parent_department_id,omitempty"` // the department this one belongs to
+	IsActive           bool       `json:"is_active"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	DeletedAt          *time.Time `json:"deleted_at,omitempty"`
+	Users              []*User    `json:"users,omitempty"`
 }
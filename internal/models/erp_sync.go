@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ERPSyncState tracks the last synced window of an ERP cache group
+type ERPSyncState struct {
+	TableName  string    `json:"table_name"`
+	SyncedFrom time.Time `json:"synced_from"`
+	SyncedTo   time.Time `json:"synced_to"`
+	RowCount   int       `json:"row_count"`
+	LastRunAt  time.Time `json:"last_run_at"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ERPWriteBackLog is one audited change (or dry-run preview) made to an ERP document
+type ERPWriteBackLog struct {
+	ID        int64     `json:"id"`
+	BatchID   string    `json:"batch_id"`
+	UserID    int       `json:"user_id"`
+	DocType   string    `json:"doc_type"`
+	DocNo     string    `json:"doc_no"`
+	Column    string    `json:"column"`
+	OldValue  string    `json:"old_value"`
+	NewValue  string    `json:"new_value"`
+	DryRun    bool      `json:"dry_run"`
+	Status    string    `json:"status"` // previewed, pending, updated, skipped, error
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// OutboxEvent is a domain event waiting to be (or already) published to the message queue
+type OutboxEvent struct {
+	ID          int64      `json:"id"`
+	EventType   string     `json:"event_type"`
+	Payload     string     `json:"payload"`
+	Status      string     `json:"status"` // pending, published, failed
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
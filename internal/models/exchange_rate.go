@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ExchangeRate is the value of one unit of a currency in the local currency, valid from its
+// effective date until the next rate of the same currency
+type ExchangeRate struct {
+	ID            int       `json:"id"`
+	CurrencyCode  string    `json:"currency_code"`
+	Rate          float64   `json:"rate"` // local currency units per unit of CurrencyCode
+	EffectiveDate time.Time `json:"effective_date"`
+	Notes         string    `json:"notes,omitempty"`
+	CreatedBy     int       `json:"created_by"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ExportJob is an export queued to run in the background
+type ExportJob struct {
+	ID           int        `json:"id"`
+	Report       string     `json:"report"`     // assistant230 or assistant610
+	Parameters   string     `json:"parameters"` // JSON of the export request
+	Status       string     `json:"status"`     // pending_approval, queued, running, completed, failed, rejected
+	UserID       int        `json:"user_id"`
+	DepartmentID int        `json:"department_id"`
+	FileName     string     `json:"file_name,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+
+	// Set once a manager approved or rejected an export that needs sign-off
+	DecidedBy    *int       `json:"decided_by,omitempty"`
+	DecidedAt    *time.Time `json:"decided_at,omitempty"`
+	DecisionNote string     `json:"decision_note,omitempty"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Statuses of a background job
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Job is a unit of background work of a registered type, queued in the jobs table
+type Job struct {
+	ID          int64      `json:"id"`
+	Type        string     `json:"type"`
+	Payload     string     `json:"payload"` // JSON handed to the handler of the type
+	Status      string     `json:"status"`  // pending, running, completed, failed, cancelled
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	RunAt       time.Time  `json:"run_at"` // when a pending job is next due
+	CreatedBy   int        `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"` // start of the last attempt
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Notification types
+const (
+	NotificationExportReady             = "export_ready"
+	NotificationExportFailed            = "export_failed"
+	NotificationExportApprovalRequested = "export_approval_requested"
+	NotificationExportApproved          = "export_approved"
+	NotificationExportRejected          = "export_rejected"
+	NotificationPasswordExpiring        = "password_expiring"
+)
+
+// Notification is a message shown to a user in the app
+type Notification struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	Type      string     `json:"type"`
+	Title     string     `json:"title"`
+	Message   string     `json:"message"`
+	Link      string     `json:"link,omitempty"` // API path of what the notification is about
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
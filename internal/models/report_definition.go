@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Report definition source types
+const (
+	ReportSourceProcedure = "procedure" // ERP stored procedure registered in the config
+	ReportSourceQuery     = "query"     // read-only SELECT template registered by an admin
+)
+
+// Report parameter sources; parameters without a source are read from the request
+const (
+	ReportParamFromDate     = "from_date"
+	ReportParamToDate       = "to_date"
+	ReportParamDepartmentID = "department_id"
+	ReportParamUserID       = "user_id"
+	// Comma separated IDs of the user's department and every department below it, for STRING_SPLIT
+	ReportParamDepartmentIDs = "department_ids"
+)
+
+// ReportDefinition describes a report served by the generic report engine
+type ReportDefinition struct {
+	ID             int               `json:"id,omitempty"` // set for definitions stored in the database
+	Code           string            `json:"code"`
+	Name           string            `json:"name"`
+	Description    string            `json:"description,omitempty"`
+	SourceType     string            `json:"source_type"`    // procedure or query
+	Source         string            `json:"source"`         // stored procedure name or SELECT template
+	OperationCode  string            `json:"operation_code"` // operation a role needs to run the report
+	Parameters     []ReportParameter `json:"parameters"`
+	Columns        []ReportColumn    `json:"columns"` // empty means every column of the result set
+	TimeoutSeconds int               `json:"timeout_seconds"`
+	MaxRows        int               `json:"max_rows,omitempty"` // rows read before the result is cut off
+	IsActive       bool              `json:"is_active"`
+	CreatedBy      int               `json:"created_by,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+
+	// Excel title and file name templates; empty means the configured or built-in naming
+	TitleTemplate    string `json:"title_template,omitempty"`
+	FileNameTemplate string `json:"file_name_template,omitempty"`
+}
+
+// ReportParameter is a named parameter passed to the report source
+type ReportParameter struct {
+	Name     string `json:"name"`             // parameter name without the @
+	Source   string `json:"source,omitempty"` // from_date, to_date, department_id, department_ids, user_id or empty for request
+	Type     string `json:"type,omitempty"`   // string, int or date for request parameters
+	Required bool   `json:"required"`
+	Default  string `json:"default,omitempty"`
+}
+
+// ReportColumn maps a result set column to a report column key
+type ReportColumn struct {
+	Key   string `json:"key"`            // report column key, translated in exports
+	Field string `json:"field"`          // column name in the result set
+	Type  string `json:"type,omitempty"` // string, number, date or currency in Excel exports
+}
+
+// ReportResult is the result set returned by a report source
+type ReportResult struct {
+	Columns   []string
+	Rows      []map[string]interface{}
+	Truncated bool // more rows were available than the requested limit
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// ReportFavorite marks a report a user starred
+type ReportFavorite struct {
+	UserID    int       `json:"user_id"`
+	Report    string    `json:"report"` // assistant230, assistant610 or a report engine code
+	CreatedAt time.Time `json:"created_at"`
+}
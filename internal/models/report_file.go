@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// ReportFile records who generated a stored export file and the access log of the run
+type ReportFile struct {
+	ID          int       `json:"id"`
+	FileName    string    `json:"file_name"`
+	Report      string    `json:"report"`
+	UserID      int       `json:"user_id"`
+	AccessLogID int       `json:"access_log_id,omitempty"` // 0 when the run was not logged
+	Size        int64     `json:"size"`
+	RowCount    int       `json:"row_count"`          // data rows of the main sheet
+	Checksum    string    `json:"checksum,omitempty"` // SHA-256 of the content, hex encoded
+	CreatedAt   time.Time `json:"created_at"`
+
+	// Set by the retention cleanup when files have a maximum age
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// Filled by queries that join users and access logs
+	Username        string     `json:"username,omitempty"`
+	AccessLogStatus string     `json:"access_log_status,omitempty"`
+	AccessTime      *time.Time `json:"access_time,omitempty"`
+	OperationName   string     `json:"operation_name,omitempty"`
+	Parameters      string     `json:"parameters,omitempty"` // search parameters of the run, as JSON
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// ReportLimit holds the guardrails of a report, set at runtime, that protect the ERP database from
+// runaway queries. A field of 0 uses the default and -1 removes the limit.
+type ReportLimit struct {
+	Report      string `json:"report"`
+	MaxMonths   int    `json:"max_months"`    // how many months back from today a date range may start
+	MaxRows     int    `json:"max_rows"`      // most rows a query of the report may return
+	MaxExportMB int    `json:"max_export_mb"` // largest export file of the report, in megabytes
+
+	UpdatedBy int       `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
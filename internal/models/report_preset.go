@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ReportPreset is a named set of report parameters a user saved to run the report again
+type ReportPreset struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	Name       string    `json:"name"`
+	Report     string    `json:"report"` // assistant230, assistant610 or a report engine code
+	Parameters string    `json:"-"`      // JSON encoded dto.ReportRunRequest
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// ReportSchedule emails a report export to a list of recipients, or pushes it to a Google Sheet,
+// on a recurring schedule
+type ReportSchedule struct {
+	ID            int        `json:"id"`
+	Name          string     `json:"name"`
+	Report        string     `json:"report"`                   // assistant230 or assistant610
+	Period        string     `json:"period"`                   // date period resolved at each run, e.g. lastmonth
+	Frequency     string     `json:"frequency"`                // daily, weekly, monthly or cron
+	Cron          string     `json:"cron"`                     // effective cron expression, derived for the other frequencies
+	Recipients    string     `json:"-"`                        // comma separated email addresses, none when only pushed to a sheet
+	SpreadsheetID string     `json:"spreadsheet_id,omitempty"` // configured Google Sheets target the report is also pushed to
+	SheetName     string     `json:"sheet_name,omitempty"`
+	IsActive      bool       `json:"is_active"`
+	CreatedBy     int        `json:"created_by"`
+	DepartmentID  int        `json:"department_id"` // the report is generated with the creator's department scope
+	Company       string     `json:"company"`       // ERP company the creator worked on, the default one when empty
+	NextRunAt     *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt     *time.Time `json:"last_run_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// ReportScheduleRun is one delivery attempt of a schedule
+type ReportScheduleRun struct {
+	ID         int        `json:"id"`
+	ScheduleID int        `json:"schedule_id"`
+	Status     string     `json:"status"` // running, success, failed
+	FileName   string     `json:"file_name,omitempty"`
+	Recipients string     `json:"recipients"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ReportSnapshot is the frozen result set of one report run. Snapshots are never updated; the
+// checksum covers Data so later tampering in the database can be detected.
+type ReportSnapshot struct {
+	ID         int       `json:"id"`
+	Report     string    `json:"report"` // assistant230, assistant610 or a report engine code
+	ReportName string    `json:"report_name"`
+	Parameters string    `json:"parameters"` // JSON of the request the snapshot was taken with
+	Data       string    `json:"-"`          // JSON of the columns and rows
+	RowCount   int       `json:"row_count"`
+	Checksum   string    `json:"checksum"` // hex SHA-256 of Data
+	Notes      string    `json:"notes,omitempty"`
+	CreatedBy  int       `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -4,12 +4,14 @@ import "time"
 
 // Role represents a user role with permissions
 type Role struct {
-	ID          int          `json:"id"`
-	Name        string       `json:"name"`
-	Description string       `json:"description,omitempty"`
-	CreatedAt   time.Time    `json:"created_at"`
-	UpdatedAt   time.Time    `json:"updated_at"`
-	Operations  []*Operation `json:"operations,omitempty"`
+	ID           int          `json:"id"`
+	Name         string       `json:"name"`
+	Description  string       `json:"description,omitempty"`
+	ParentRoleID *int         `json:"parent_role_id,omitempty"` // the role whose operations this role inherits
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	DeletedAt    *time.Time   `json:"deleted_at,omitempty"`
+	Operations   []*Operation `json:"operations,omitempty"`
 }
 
 // RoleOperation represents the relationship between roles and operations
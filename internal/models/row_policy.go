@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Operators of a row policy
+const (
+	RowPolicyIn    = "in"     // the attribute is one of the values
+	RowPolicyNotIn = "not_in" // the attribute is none of the values
+)
+
+// RowPolicy narrows the rows a report returns, such as "sales reps see only their own
+// customers". It is defined on an operation of a report and applies to the users holding its
+// role, or to every user without one. Values are literals or the placeholders {username},
+// {user_id}, {department_id} and {department_code}, replaced with those of the user running the
+// report.
+type RowPolicy struct {
+	ID            int       `json:"id"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description,omitempty"`
+	OperationCode string    `json:"operation_code"`
+	RoleID        *int      `json:"role_id,omitempty"`
+	Attribute     string    `json:"attribute"` // report attribute, such as customer or salesperson
+	Operator      string    `json:"operator"`
+	Values        []string  `json:"values"`
+	IsActive      bool      `json:"is_active"`
+	CreatedBy     int       `json:"created_by"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// TranslationLabel is a label for a translation key in one locale, managed at runtime
+type TranslationLabel struct {
+	ID        int       `json:"id"`
+	Locale    string    `json:"locale"`
+	Key       string    `json:"key"`
+	Label     string    `json:"label"`
+	UpdatedBy int       `json:"updated_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -16,7 +16,10 @@ type User struct {
 	LastLogin    time.Time   `json:"last_login,omitempty"`
 	CreatedAt    time.Time   `json:"created_at"`
 	UpdatedAt    time.Time   `json:"updated_at"`
+	DeletedAt    *time.Time  `json:"deleted_at,omitempty"`
 	Roles        []*Role     `json:"roles,omitempty"`
+
+	PasswordChangedAt time.Time `json:"-"` // only read when checking password age
 }
 
 // UserRole represents the relationship between users and roles
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Webhook is a URL an administrator registered to receive events. The secret signs the deliveries
+// and is only shown when it is generated.
+type Webhook struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`
+	Events    []string  `json:"events"` // event types delivered to the URL
+	IsActive  bool      `json:"is_active"`
+	CreatedBy int       `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Subscribes reports whether the webhook receives events of a type
+func (w *Webhook) Subscribes(eventType string) bool {
+	for _, event := range w.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is an event sent, or still to be sent, to a webhook
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	WebhookID      int        `json:"webhook_id"`
+	EventType      string     `json:"event_type"`
+	Payload        string     `json:"payload"`
+	Status         string     `json:"status"` // pending, delivered, failed
+	Attempts       int        `json:"attempts"`
+	ResponseStatus int        `json:"response_status,omitempty"` // HTTP status of the last attempt
+	LastError      string     `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
@@ -0,0 +1,348 @@
+// Package openapi generates an OpenAPI 3 document from the Fiber route table and the request and
+// response DTOs of the routes, so the API description cannot drift from the registered routes.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Operation documents what the route table cannot tell about a route: its JSON body, query
+// parameters and the data of its response. Routes without an Operation are still listed.
+type Operation struct {
+	Summary  string
+	Query    map[string]string // query parameters and their description
+	Request  any               // a value of the JSON body type
+	Upload   string            // multipart form field of an uploaded file, instead of a JSON body
+	Response any               // a value of the type of the data field of the success response
+	File     string            // content type of a downloaded file, instead of a JSON response
+	Status   int               // status of a success, default 200
+}
+
+// Options describe the API and its authentication
+type Options struct {
+	Title       string
+	Version     string
+	Description string
+
+	// SecuredPrefix is the prefix of the routes that need a bearer token or an API key; Public
+	// lists the routes under it that do not
+	SecuredPrefix string
+	Public        []string
+
+	// Operations are keyed by method and path as registered, e.g. "GET /api/users/:id"
+	Operations map[string]Operation
+}
+
+// Document is an OpenAPI 3.0 document
+type Document struct {
+	OpenAPI    string                               `json:"openapi"`
+	Info       Info                                 `json:"info"`
+	Paths      map[string]map[string]*PathOperation `json:"paths"`
+	Components Components                           `json:"components"`
+	Tags       []Tag                                `json:"tags,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+// PathOperation is one method of a path
+type PathOperation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path or query
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// handlerName matches the endpoint of a route, e.g. "handlers.(*UserHandler).GetAll-fm", or
+// "handlers.(*ExportJobHandler).submit.func1" for handlers built by a method
+var handlerName = regexp.MustCompile(`\(\*(\w+?)Handler\)\.(\w+)`)
+
+// Build generates the document of the routes. HEAD routes, which Fiber adds for every GET, and
+// middleware are left out.
+func Build(routes []fiber.Route, options Options) *Document {
+	components := newSchemas()
+	document := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       options.Title,
+			Version:     options.Version,
+			Description: options.Description,
+		},
+		Paths: make(map[string]map[string]*PathOperation),
+		Components: Components{
+			Schemas: components.byName,
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				"apiKey":     {Type: "apiKey", In: "header", Name: "X-API-Key"},
+			},
+		},
+	}
+	components.byName["ErrorResponse"] = errorSchema()
+
+	tags := make(map[string]bool)
+	for _, route := range routes {
+		if route.Method == http.MethodHead || len(route.Handlers) == 0 {
+			continue
+		}
+		path := cleanPath(route.Path)
+		doc := options.Operations[route.Method+" "+path]
+
+		tag, method := endpointName(route.Handlers[len(route.Handlers)-1])
+		operation := &PathOperation{
+			Summary:   doc.Summary,
+			Responses: make(map[string]Response),
+			Security:  []map[string][]string{},
+		}
+		if operation.Summary == "" {
+			operation.Summary = words(method)
+		}
+		if tag != "" {
+			operation.Tags = []string{tag}
+			tags[tag] = true
+		}
+		if options.SecuredPrefix != "" && strings.HasPrefix(path, options.SecuredPrefix) && !slices.Contains(options.Public, path) {
+			operation.Security = []map[string][]string{{"bearerAuth": {}}, {"apiKey": {}}}
+		}
+
+		operation.Parameters = append(pathParameters(path), queryParameters(doc.Query)...)
+		operation.RequestBody = requestBody(components, doc)
+		status := doc.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		operation.Responses[strconv.Itoa(status)] = successResponse(components, doc)
+		operation.Responses["default"] = Response{
+			Description: "Error",
+			Content: map[string]MediaType{
+				fiber.MIMEApplicationJSON: {Schema: &Schema{Ref: "#/components/schemas/ErrorResponse"}},
+			},
+		}
+
+		openAPIPath := toOpenAPIPath(path)
+		if document.Paths[openAPIPath] == nil {
+			document.Paths[openAPIPath] = make(map[string]*PathOperation)
+		}
+		method = strings.ToLower(route.Method)
+		if _, exists := document.Paths[openAPIPath][method]; !exists {
+			document.Paths[openAPIPath][method] = operation
+		}
+	}
+
+	for tag := range tags {
+		document.Tags = append(document.Tags, Tag{Name: tag})
+	}
+	sort.Slice(document.Tags, func(i, j int) bool { return document.Tags[i].Name < document.Tags[j].Name })
+	return document
+}
+
+// requestBody describes the JSON body or the uploaded file of an operation
+func requestBody(components *schemas, doc Operation) *RequestBody {
+	switch {
+	case doc.Upload != "":
+		return &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{
+				fiber.MIMEMultipartForm: {Schema: &Schema{
+					Type:       "object",
+					Properties: map[string]*Schema{doc.Upload: {Type: "string", Format: "binary"}},
+					Required:   []string{doc.Upload},
+				}},
+			},
+		}
+	case doc.Request != nil:
+		return &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{
+				fiber.MIMEApplicationJSON: {Schema: components.of(reflect.TypeOf(doc.Request))},
+			},
+		}
+	}
+	return nil
+}
+
+// successResponse describes the file or the standard success response of an operation
+func successResponse(components *schemas, doc Operation) Response {
+	if doc.File != "" {
+		return Response{
+			Description: "File",
+			Content: map[string]MediaType{
+				doc.File: {Schema: &Schema{Type: "string", Format: "binary"}},
+			},
+		}
+	}
+
+	data := &Schema{}
+	if doc.Response != nil {
+		data = components.of(reflect.TypeOf(doc.Response))
+	}
+	return Response{
+		Description: "Success",
+		Content: map[string]MediaType{
+			fiber.MIMEApplicationJSON: {Schema: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"success": {Type: "boolean"},
+					"message": {Type: "string"},
+					"data":    data,
+				},
+			}},
+		},
+	}
+}
+
+// errorSchema is the schema of utils.ErrorResponse and utils.CodedErrorResponse
+func errorSchema() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"success": {Type: "boolean"},
+			"message": {Type: "string"},
+			"error":   {Type: "string"},
+			"code":    {Type: "string", Description: "Stable code of the error, e.g. no_data, invalid_range or permission_denied"},
+		},
+	}
+}
+
+// pathParameters describes the :name, :name? and * segments of a Fiber path
+func pathParameters(path string) []Parameter {
+	var parameters []Parameter
+	for _, segment := range strings.Split(path, "/") {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			parameters = append(parameters, Parameter{
+				Name:     strings.TrimSuffix(segment[1:], "?"),
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		case segment == "*":
+			parameters = append(parameters, Parameter{Name: "path", In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+	return parameters
+}
+
+// queryParameters describes the query parameters of an operation, sorted by name
+func queryParameters(query map[string]string) []Parameter {
+	parameters := make([]Parameter, 0, len(query))
+	for name, description := range query {
+		parameters = append(parameters, Parameter{
+			Name:        name,
+			In:          "query",
+			Description: description,
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+	sort.Slice(parameters, func(i, j int) bool { return parameters[i].Name < parameters[j].Name })
+	return parameters
+}
+
+// toOpenAPIPath turns the parameters of a Fiber path into OpenAPI ones: /users/:id becomes /users/{id}
+func toOpenAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			segments[i] = "{" + strings.TrimSuffix(segment[1:], "?") + "}"
+		case segment == "*":
+			segments[i] = "{path}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// cleanPath drops the doubled and trailing slashes group prefixes leave in registered paths
+func cleanPath(path string) string {
+	for strings.Contains(path, "//") {
+		path = strings.ReplaceAll(path, "//", "/")
+	}
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return path
+}
+
+// endpointName returns the handler type, without Handler, and the method serving a route
+func endpointName(handler fiber.Handler) (string, string) {
+	function := runtime.FuncForPC(reflect.ValueOf(handler).Pointer())
+	if function == nil {
+		return "", ""
+	}
+	match := handlerName.FindStringSubmatch(function.Name())
+	if match == nil {
+		return "", ""
+	}
+	return words(match[1]), match[2]
+}
+
+// words splits a Go identifier into words, keeping acronyms together: ERPWriteBack becomes
+// "ERP Write Back", getAll "Get All"
+func words(identifier string) string {
+	runes := []rune(identifier)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) &&
+			(!unicode.IsUpper(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			b.WriteRune(' ')
+		}
+		if i == 0 {
+			r = unicode.ToUpper(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,243 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is an OpenAPI 3.0 schema object, limited to what the DTOs need
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Deprecated           bool               `json:"deprecated,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemas collects the schemas of the named struct types met while describing the routes, so
+// each DTO is described once under components and referenced from the operations
+type schemas struct {
+	byName map[string]*Schema
+	types  map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{
+		byName: make(map[string]*Schema),
+		types:  make(map[reflect.Type]string),
+	}
+}
+
+// of returns the schema of a Go type, as encoding/json marshals it. Named structs are referenced
+// from components.
+func (s *schemas) of(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := s.of(t.Elem())
+		if schema.Ref != "" {
+			// $ref siblings are ignored by OpenAPI 3.0 tools, so the pointer stays non-nullable
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + s.register(t)}
+	}
+	// interface{} and anything else: any JSON value
+	return &Schema{}
+}
+
+// register describes a named struct under components once and returns its component name. Types
+// of different packages with the same name are told apart by their package name.
+func (s *schemas) register(t reflect.Type) string {
+	if name, ok := s.types[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := s.byName[name]; taken {
+		name = packageName(t) + name
+	}
+	s.types[t] = name
+	// Reserve the name before describing the fields, for types that refer to themselves
+	s.byName[name] = &Schema{}
+	*s.byName[name] = *s.object(t)
+	return name
+}
+
+// object describes the JSON fields of a struct, flattening embedded structs as encoding/json does
+func (s *schemas) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	s.addFields(schema, t)
+	if len(schema.Properties) == 0 {
+		schema.Properties = nil
+	}
+	return schema
+}
+
+func (s *schemas) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				s.addFields(schema, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := s.of(field.Type)
+		required := applyValidation(property, field.Type, field.Tag.Get("validate"))
+		if strings.Contains(options, "string") && property.Ref == "" {
+			property.Type = "string"
+		}
+		// Fields kept for a deprecation window are tagged deprecated:"true"
+		if field.Tag.Get("deprecated") == "true" && property.Ref == "" {
+			property.Deprecated = true
+		}
+		schema.Properties[name] = property
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// applyValidation adds the go-playground validate rules of a field to its schema and reports
+// whether the field is required. Rules after dive apply to the elements and are left out.
+func applyValidation(schema *Schema, t reflect.Type, tag string) bool {
+	if tag == "" || schema.Ref != "" {
+		return strings.HasPrefix(tag, "required")
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == "dive" {
+			break
+		}
+		name, value, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "oneof":
+			schema.Enum = strings.Fields(value)
+		case "email":
+			schema.Format = "email"
+		case "url":
+			schema.Format = "uri"
+		case "min", "max", "len", "gte", "lte":
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			applyBound(schema, t.Kind(), name, number)
+		}
+	}
+	return required
+}
+
+// applyBound sets a min, max or len rule as the length, item count or value bound of a field
+func applyBound(schema *Schema, kind reflect.Kind, rule string, number float64) {
+	lower := rule == "min" || rule == "gte" || rule == "len"
+	upper := rule == "max" || rule == "lte" || rule == "len"
+	count := int(number)
+
+	switch kind {
+	case reflect.String:
+		if lower {
+			schema.MinLength = &count
+		}
+		if upper {
+			schema.MaxLength = &count
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if lower {
+			schema.MinItems = &count
+		}
+		if upper {
+			schema.MaxItems = &count
+		}
+	default:
+		if lower {
+			schema.Minimum = &number
+		}
+		if upper {
+			schema.Maximum = &number
+		}
+	}
+}
+
+// packageName returns the last element of the import path of a type, capitalised
+func packageName(t reflect.Type) string {
+	path := t.PkgPath()
+	name := path[strings.LastIndex(path, "/")+1:]
+	if name == "" {
+		return ""
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -29,9 +29,9 @@ func NewAccessLogRepository(db *sql.DB) AccessLogRepository {
 // Create adds a new access log entry
 func (r *accessLogRepository) Create(ctx context.Context, log *models.AccessLog) (int, error) {
 	query := `
-        INSERT INTO access_logs (user_id, operation_id, access_time, search_params, ip_address, status)
+        INSERT INTO access_logs (user_id, operation_id, access_time, search_params, ip_address, status, company)
         OUTPUT INSERTED.id
-        VALUES (@user_id, @operation_id, @access_time, @search_params, @ip_address, @status)
+        VALUES (@user_id, @operation_id, @access_time, @search_params, @ip_address, @status, NULLIF(@company, ''))
     `
 
 	var id int
@@ -44,6 +44,7 @@ func (r *accessLogRepository) Create(ctx context.Context, log *models.AccessLog)
 		sql.Named("search_params", log.SearchParams),
 		sql.Named("ip_address", log.IPAddress),
 		sql.Named("status", log.Status),
+		sql.Named("company", accessLogCompany(ctx, log)),
 	).Scan(&id)
 
 	if err != nil {
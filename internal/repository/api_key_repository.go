@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// APIKeyRepository stores the hashed API keys of service accounts
+type APIKeyRepository interface {
+	List(ctx context.Context) ([]*models.APIKey, error)
+	ListByUser(ctx context.Context, userID int) ([]*models.APIKey, error)
+	GetByID(ctx context.Context, id int) (*models.APIKey, error)
+	GetByPrefix(ctx context.Context, prefix string) (*models.APIKey, error)
+	Create(ctx context.Context, key *models.APIKey) (int, error)
+	Revoke(ctx context.Context, id int) error
+	TouchLastUsed(ctx context.Context, id int, usedAt time.Time) error
+}
+
+type apiKeyRepository struct {
+	db *sql.DB
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(db *sql.DB) APIKeyRepository {
+	return &apiKeyRepository{
+		db: db,
+	}
+}
+
+const apiKeyColumns = `id, name, key_prefix, key_hash, scopes, user_id, expires_at, last_used_at, revoked_at, created_by, created_at`
+
+// List gets all API keys, newest first, including the revoked ones
+func (r *apiKeyRepository) List(ctx context.Context) ([]*models.APIKey, error) {
+	return r.list(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at DESC`)
+}
+
+// ListByUser gets the API keys of a user, newest first, including the revoked ones
+func (r *apiKeyRepository) ListByUser(ctx context.Context, userID int) ([]*models.APIKey, error) {
+	return r.list(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE user_id = @user_id ORDER BY created_at DESC`,
+		sql.Named("user_id", userID),
+	)
+}
+
+// list runs a query returning API key rows
+func (r *apiKeyRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// GetByID gets an API key by ID
+func (r *apiKeyRepository) GetByID(ctx context.Context, id int) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = @id`
+	return scanAPIKey(r.db.QueryRowContext(ctx, query, sql.Named("id", id)))
+}
+
+// GetByPrefix gets the API key a presented key belongs to
+func (r *apiKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_prefix = @prefix`
+	return scanAPIKey(r.db.QueryRowContext(ctx, query, sql.Named("prefix", prefix)))
+}
+
+// Create stores a new API key
+func (r *apiKeyRepository) Create(ctx context.Context, key *models.APIKey) (int, error) {
+	now := time.Now()
+	query := `
+        INSERT INTO api_keys (name, key_prefix, key_hash, scopes, user_id, expires_at, created_by, created_at)
+        OUTPUT INSERTED.id
+        VALUES (@name, @key_prefix, @key_hash, @scopes, @user_id, @expires_at, @created_by, @now)
+    `
+
+	var id int
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		sql.Named("name", key.Name),
+		sql.Named("key_prefix", key.Prefix),
+		sql.Named("key_hash", key.KeyHash),
+		sql.Named("scopes", strings.Join(key.Scopes, ",")),
+		sql.Named("user_id", key.UserID),
+		sql.Named("expires_at", key.ExpiresAt),
+		sql.Named("created_by", key.CreatedBy),
+		sql.Named("now", now),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error creating API key: %w", err)
+	}
+
+	key.ID = id
+	key.CreatedAt = now
+	return id, nil
+}
+
+// Revoke disables an API key; revoking it again keeps the first revocation time
+func (r *apiKeyRepository) Revoke(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE api_keys SET revoked_at = ISNULL(revoked_at, @now) WHERE id = @id`,
+		sql.Named("id", id),
+		sql.Named("now", time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("error revoking API key: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("API key not found: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// TouchLastUsed records when an API key was last used
+func (r *apiKeyRepository) TouchLastUsed(ctx context.Context, id int, usedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE api_keys SET last_used_at = @used_at WHERE id = @id`,
+		sql.Named("id", id),
+		sql.Named("used_at", usedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("error updating API key last use: %w", err)
+	}
+	return nil
+}
+
+// scanAPIKey scans one API key row
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var key models.APIKey
+	var scopes string
+	var userID sql.NullInt64
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		&scopes,
+		&userID,
+		&expiresAt,
+		&lastUsedAt,
+		&revokedAt,
+		&key.CreatedBy,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("API key not found: %w", err)
+		}
+		return nil, fmt.Errorf("error scanning API key: %w", err)
+	}
+
+	key.Scopes = strings.FieldsFunc(scopes, func(r rune) bool { return r == ',' })
+	if userID.Valid {
+		id := int(userID.Int64)
+		key.UserID = &id
+	}
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
@@ -1,4 +1,3 @@
-// repository/inventory_repository.go
 package repository
 
 import (
@@ -6,42 +5,37 @@ import (
 	"database/sql"
 	"erp-excel/internal/dto"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
 )
 
-type InventoryRepository interface {
+// Assistant230Repository reads report 230, the sales orders with their invoice status, from the
+// ERP. The item stock movements are read by ItemInventoryRepository.
+type Assistant230Repository interface {
 	GetInventoryReport(
 		ctx context.Context,
 		fromDate time.Time,
 		toDate time.Time,
 		departmentID int,
+		invoiceStatus string,
+		limit int,
 	) ([]dto.Asisstant230ReportItem, error)
-}
-
-type inventoryRepository struct {
-	erpDB *sql.DB
-}
 
-func NewInventoryRepository(erpDB *sql.DB) InventoryRepository {
-	return &inventoryRepository{
-		erpDB: erpDB,
-	}
+	// GetInventoryReportPage returns one sorted page of the filtered report and its total number of rows
+	GetInventoryReportPage(
+		ctx context.Context,
+		fromDate time.Time,
+		toDate time.Time,
+		departmentID int,
+		invoiceStatus string,
+		filter ReportFilter,
+		page ReportPage,
+	) ([]dto.Asisstant230ReportItem, int, error)
 }
 
-func (r *inventoryRepository) GetInventoryReport(
-	ctx context.Context,
-	fromDate time.Time,
-	toDate time.Time,
-	departmentID int,
-) ([]dto.Asisstant230ReportItem, error) {
-	log.Printf("GetInventoryReport called with fromDate: %v, toDate: %v, departmentID: %d", fromDate, toDate, departmentID)
-	_, err := r.erpDB.ExecContext(ctx, "USE Leader")
-	if err != nil {
-		return nil, fmt.Errorf("error switching database: %w", err)
-	}
-
-	query := `
+// inventoryReportQuery selects the report rows; @InvoiceStatus is uninvoiced, invoiced or all
+const inventoryReportQuery = `
    SELECT DISTINCT
     CONVERT(VARCHAR(10), CONVERT(DATETIME, COPTG.TG042), 103) AS document_date,
     COPTG.TG001 + '-' + COPTG.TG002 AS sales_order_number,
@@ -60,7 +54,8 @@ func (r *inventoryRepository) GetInventoryReport(
     REPLACE(CONVERT(VARCHAR, CONVERT(MONEY, (ISNULL(COPTG.TG045, 0) + ISNULL(COPTG.TG046, 0))), 1), '.00', '') AS currency,
     ISNULL(COPTD.TD001 + '-' + COPTD.TD002 + '-' + RIGHT('0' + CONVERT(VARCHAR, COPTD.TD003), 4), '') AS detailed_order_number,
     ISNULL(ACRTA.TA036, '') AS invoice_number,
-    ISNULL(COPTG.TG020, '') AS notes
+    ISNULL(COPTG.TG020, '') AS notes,
+    CASE WHEN ACRTA.TA001 IS NULL THEN 'uninvoiced' ELSE 'invoiced' END AS invoice_status
 FROM 
     COPTG WITH (NOLOCK)
 LEFT JOIN 
@@ -73,17 +68,87 @@ LEFT JOIN
     COPTD WITH (NOLOCK) ON COPTD.TD001 = COPTH.TH014 AND COPTD.TD002 = COPTH.TH015 AND COPTD.TD003 = COPTH.TH016
 WHERE 
     COPTG.TG023 <> 'V'  
-    AND TG042 BETWEEN @FromDate AND @ToDate AND ACRTA.TA001 IS NULL
+    AND TG042 BETWEEN @FromDate AND @ToDate
+    AND (
+        @InvoiceStatus = 'all'
+        OR (@InvoiceStatus = 'uninvoiced' AND ACRTA.TA001 IS NULL)
+        OR (@InvoiceStatus = 'invoiced' AND ACRTA.TA001 IS NOT NULL)
+    )
     `
-	log.Printf("Executing query: %s with FromDate: %v, ToDate: %v", query, fromDate, toDate)
 
-	rows, err := r.erpDB.QueryContext(
-		ctx,
-		query,
-		sql.Named("FromDate", fromDate),
-		sql.Named("ToDate", toDate),
-		// sql.Named("DepartmentID", departmentID), // Uncomment and use if needed in SQL query
-	)
+// inventoryFilterColumns match the filters with the ERP columns behind the report columns
+var inventoryFilterColumns = reportFilterColumns{
+	customerName:  "COPTG.TG007",
+	invoiceNumber: "ISNULL(ACRTA.TA036, '')",
+	currencyType:  "COPTG.TG011",
+	orderNumbers: []string{
+		"COPTG.TG001 + '-' + COPTG.TG002",
+		"ISNULL(COPTD.TD001 + '-' + COPTD.TD002 + '-' + RIGHT('0' + CONVERT(VARCHAR, COPTD.TD003), 4), '')",
+	},
+}
+
+// inventorySort orders pages of the report. Dates and amounts are formatted as text by the
+// query, so they are converted back to sort by value.
+var inventorySort = reportSort{
+	columns: map[string]string{
+		"document_date":         "CONVERT(DATE, report.document_date, 103)",
+		"sales_order_number":    "report.sales_order_number",
+		"customer_name":         "report.customer_name",
+		"currency_type":         "TRY_CONVERT(MONEY, report.currency_type)",
+		"currency":              "TRY_CONVERT(MONEY, report.currency)",
+		"detailed_order_number": "report.detailed_order_number",
+		"invoice_number":        "report.invoice_number",
+		"notes":                 "report.notes",
+		"invoice_status":        "report.invoice_status",
+	},
+	defaultKey:  "document_date",
+	tiebreakers: "report.sales_order_number, report.detailed_order_number, report.invoice_number",
+}
+
+type assistant230Repository struct {
+	erp    ERPPool
+	cached bool // read from the local ERP cache tables instead of the ERP server
+	logger *slog.Logger
+}
+
+func NewAssistant230Repository(erp ERPPool, logger *slog.Logger) Assistant230Repository {
+	return &assistant230Repository{
+		erp:    erp,
+		logger: logger,
+	}
+}
+
+// NewCachedAssistant230Repository reads the report from the ERP cache tables on the app database,
+// which hold the ERP data of the given company
+func NewCachedAssistant230Repository(db *sql.DB, company string, logger *slog.Logger) Assistant230Repository {
+	return &assistant230Repository{
+		erp:    cachePool{db: db, company: company},
+		cached: true,
+		logger: logger,
+	}
+}
+
+func (r *assistant230Repository) GetInventoryReport(
+	ctx context.Context,
+	fromDate time.Time,
+	toDate time.Time,
+	departmentID int,
+	invoiceStatus string,
+	limit int,
+) ([]dto.Asisstant230ReportItem, error) {
+	r.logger.DebugContext(ctx, "Querying inventory report",
+		"from_date", fromDate, "to_date", toDate, "department_id", departmentID, "invoice_status", invoiceStatus)
+	erpDB, query, args, err := r.reportQuery(ctx, fromDate, toDate, invoiceStatus, ReportFilter{})
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 {
+		query = strings.Replace(query, "SELECT DISTINCT", "SELECT DISTINCT TOP (@Limit)", 1)
+		args = append(args, sql.Named("Limit", limit))
+	}
+	r.logger.DebugContext(ctx, "Executing inventory query", "query", query)
+
+	rows, err := erpDB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error querying inventory data: %w", err)
 	}
@@ -92,17 +157,7 @@ WHERE
 	var items []dto.Asisstant230ReportItem
 	for rows.Next() {
 		var item dto.Asisstant230ReportItem
-		if err := rows.Scan(
-			&item.DocumentDate,
-			&item.SalesOrderNumber,
-			&item.CustomerName,
-
-			&item.CurrencyType,
-			&item.Currency,
-			&item.DetailedOrderNumber,
-			&item.InvoiceNumber,
-			&item.Notes,
-		); err != nil {
+		if err := rows.Scan(inventoryItemFields(&item)...); err != nil {
 			return nil, fmt.Errorf("error scanning inventory data: %w", err)
 		}
 		items = append(items, item)
@@ -114,3 +169,105 @@ WHERE
 
 	return items, nil
 }
+
+// GetInventoryReportPage returns one sorted page of the filtered report and its total number of rows
+func (r *assistant230Repository) GetInventoryReportPage(
+	ctx context.Context,
+	fromDate time.Time,
+	toDate time.Time,
+	departmentID int,
+	invoiceStatus string,
+	filter ReportFilter,
+	page ReportPage,
+) ([]dto.Asisstant230ReportItem, int, error) {
+	r.logger.DebugContext(ctx, "Querying inventory report page",
+		"from_date", fromDate, "to_date", toDate, "department_id", departmentID, "invoice_status", invoiceStatus,
+		"filter", filter, "offset", page.Offset, "limit", page.Limit, "sort", page.SortBy)
+
+	erpDB, query, args, err := r.reportQuery(ctx, fromDate, toDate, invoiceStatus, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	paged, pageArgs, err := pageQuery(query, args, page, inventorySort)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := erpDB.QueryContext(ctx, paged, pageArgs...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error querying inventory data: %w", err)
+	}
+	defer rows.Close()
+
+	items := []dto.Asisstant230ReportItem{}
+	total := 0
+	for rows.Next() {
+		var item dto.Asisstant230ReportItem
+		if err := rows.Scan(append(inventoryItemFields(&item), &total)...); err != nil {
+			return nil, 0, fmt.Errorf("error scanning inventory data: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating inventory data: %w", err)
+	}
+
+	if len(items) == 0 && page.Offset > 0 {
+		if total, err = countReportRows(ctx, erpDB, query, args); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return items, total, nil
+}
+
+// reportQuery returns the ERP database of the request's company and the filtered report query
+// with its arguments
+func (r *assistant230Repository) reportQuery(
+	ctx context.Context,
+	fromDate time.Time,
+	toDate time.Time,
+	invoiceStatus string,
+	filter ReportFilter,
+) (*sql.DB, string, []interface{}, error) {
+	if invoiceStatus == "" {
+		invoiceStatus = dto.InvoiceStatusUninvoiced
+	}
+	erpDB, err := r.erp.ERPDatabaseFor(ctx)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("error selecting ERP database: %w", err)
+	}
+
+	// The filters and row policies extend the WHERE clause that ends the query
+	conditions, filterArgs := filterConditions(filter, inventoryFilterColumns)
+	policy, policyArgs, err := policyConditions(ctx, "assistant230")
+	if err != nil {
+		return nil, "", nil, err
+	}
+	query := inventoryReportQuery + conditions + policy
+	if r.cached {
+		query = cacheTableReplacer.Replace(query)
+	}
+	args := []interface{}{
+		sql.Named("FromDate", fromDate),
+		sql.Named("ToDate", toDate),
+		sql.Named("InvoiceStatus", invoiceStatus),
+	}
+	args = append(args, filterArgs...)
+	return erpDB, query, append(args, policyArgs...), nil
+}
+
+// inventoryItemFields returns the scan destinations of the report columns, in query order
+func inventoryItemFields(item *dto.Asisstant230ReportItem) []interface{} {
+	return []interface{}{
+		&item.DocumentDate,
+		&item.SalesOrderNumber,
+		&item.CustomerName,
+		&item.CurrencyType,
+		&item.Currency,
+		&item.DetailedOrderNumber,
+		&item.InvoiceNumber,
+		&item.Notes,
+		&item.InvoiceStatus,
+	}
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/dto"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Assistant340Repository reads purchase receipts from the ERP purchasing tables
+type Assistant340Repository interface {
+	GetAssistant340Report(
+		ctx context.Context,
+		fromDate time.Time,
+		toDate time.Time,
+		supplierCode string,
+		itemCode string,
+	) ([]dto.Assistant340ReportItem, error)
+}
+
+type assistant340Repository struct {
+	erp    ERPPool
+	logger *slog.Logger
+}
+
+// NewAssistant340Repository creates a new purchase report repository. The ERP cache does not
+// copy the purchasing tables, so this always reads the ERP server.
+func NewAssistant340Repository(erp ERPPool, logger *slog.Logger) Assistant340Repository {
+	return &assistant340Repository{
+		erp:    erp,
+		logger: logger,
+	}
+}
+
+// GetAssistant340Report returns the lines of the purchase receipts (PURTG/PURTH) dated in the
+// period, with the purchase order line (PURTD) each one receives. Voided receipts are left out.
+func (r *assistant340Repository) GetAssistant340Report(
+	ctx context.Context,
+	fromDate time.Time,
+	toDate time.Time,
+	supplierCode string,
+	itemCode string,
+) ([]dto.Assistant340ReportItem, error) {
+	r.logger.DebugContext(ctx, "Querying purchase receipts",
+		"from_date", fromDate, "to_date", toDate, "supplier_code", supplierCode, "item_code", itemCode)
+	erpDB, err := r.erp.ERPDatabaseFor(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting ERP database: %w", err)
+	}
+	policy, policyArgs, err := policyConditions(ctx, "assistant340")
+	if err != nil {
+		return nil, err
+	}
+
+	// The row policies extend the WHERE clause before the ORDER BY
+	query := `
+	SELECT
+    CONVERT(VARCHAR(10), CONVERT(DATE, PURTG.TG003, 112), 103) AS receipt_date,
+    RTRIM(PURTG.TG001) + '-' + RTRIM(PURTG.TG002) AS purchase_receipt,
+    PURTG.TG005 AS supplier_code,
+    ISNULL(PURMA.MA002, '') AS supplier_name,
+    ISNULL(RTRIM(PURTD.TD001) + '-' + RTRIM(PURTD.TD002) + '-' + RTRIM(PURTD.TD003), '') AS purchase_order,
+    PURTH.TH004 AS item_code,
+    ISNULL(PURTH.TH005, '') AS item_name,
+    ISNULL(PURTD.TD008, 0) AS order_qty,
+    PURTH.TH007 AS received_qty,
+    PURTG.TG007 AS currency_type,
+    PURTH.TH045 + PURTH.TH046 AS amount_trans,
+    PURTH.TH047 + PURTH.TH048 AS amount
+FROM
+    PURTG WITH (NOLOCK)
+JOIN
+    PURTH WITH (NOLOCK) ON PURTH.TH001 = PURTG.TG001 AND PURTH.TH002 = PURTG.TG002
+LEFT JOIN
+    PURTD WITH (NOLOCK) ON PURTD.TD001 = PURTH.TH011 AND PURTD.TD002 = PURTH.TH012 AND PURTD.TD003 = PURTH.TH013
+LEFT JOIN
+    PURMA WITH (NOLOCK) ON PURMA.MA001 = PURTG.TG005
+WHERE PURTG.TG003 BETWEEN @FromDate AND @ToDate
+    AND PURTG.TG013 <> 'V'
+    AND (@SupplierCode = '' OR PURTG.TG005 = @SupplierCode)
+    AND (@ItemCode = '' OR PURTH.TH004 = @ItemCode)` + policy + `
+ORDER BY
+    PURTG.TG003, PURTG.TG001, PURTG.TG002, PURTH.TH003
+	`
+
+	// TG003 is stored as YYYYMMDD text
+	args := []interface{}{
+		sql.Named("FromDate", fromDate.Format("20060102")),
+		sql.Named("ToDate", toDate.Format("20060102")),
+		sql.Named("SupplierCode", supplierCode),
+		sql.Named("ItemCode", itemCode),
+	}
+	rows, err := erpDB.QueryContext(ctx, query, append(args, policyArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying purchase receipts: %w", err)
+	}
+	defer rows.Close()
+
+	var items []dto.Assistant340ReportItem
+	for rows.Next() {
+		var item dto.Assistant340ReportItem
+		if err := rows.Scan(
+			&item.ReceiptDate,
+			&item.PurchaseReceipt,
+			&item.SupplierCode,
+			&item.SupplierName,
+			&item.PurchaseOrder,
+			&item.ItemCode,
+			&item.ItemName,
+			&item.OrderQty,
+			&item.ReceivedQty,
+			&item.CurrencyType,
+			&item.AmountTrans,
+			&item.Amount,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning purchase receipts: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating purchase receipts: %w", err)
+	}
+
+	return items, nil
+}
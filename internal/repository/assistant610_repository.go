@@ -5,7 +5,8 @@ import (
 	"database/sql"
 	"erp-excel/internal/dto"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
 )
 
@@ -15,32 +16,22 @@ type Assistant610Repository interface {
 		fromDate time.Time,
 		toDate time.Time,
 		departmentID int,
+		limit int,
 	) ([]dto.Asisstant610ReportItem, error)
-}
-
-type assistant610Repository struct {
-	erpDB *sql.DB
-}
 
-func NewAssistant610Repository(erpDB *sql.DB) Assistant610Repository {
-	return &assistant610Repository{
-		erpDB: erpDB,
-	}
+	// GetAssistant610ReportPage returns one sorted page of the filtered report and its total number of rows
+	GetAssistant610ReportPage(
+		ctx context.Context,
+		fromDate time.Time,
+		toDate time.Time,
+		departmentID int,
+		filter ReportFilter,
+		page ReportPage,
+	) ([]dto.Asisstant610ReportItem, int, error)
 }
 
-func (r *assistant610Repository) GetAssistant610Report(
-	ctx context.Context,
-	fromDate time.Time,
-	toDate time.Time,
-	departmentID int,
-) ([]dto.Asisstant610ReportItem, error) {
-	log.Printf("GetAssistant610Report called with fromDate: %v, toDate: %v, departmentID: %d", fromDate, toDate, departmentID)
-	_, err := r.erpDB.ExecContext(ctx, "USE Leader")
-	if err != nil {
-		return nil, fmt.Errorf("error switching database: %w", err)
-	}
-
-	query := `
+// assistant610ReportQuery selects the receivable documents of the report
+const assistant610ReportQuery = `
 	SELECT DISTINCT
     CONVERT(VARCHAR(10), ACRTB.TB008, 103) AS doc_date,
     ACRTA.TA001 + '-' + ACRTA.TA002 AS ar_type,
@@ -81,15 +72,78 @@ OUTER APPLY (
 WHERE  ACRTB.TB008 BETWEEN @FromDate AND @ToDate
 	
 	`
-	log.Printf("Executing query: %s with FromDate: %v, ToDate: %v, DepartmentID: %d", query, fromDate, toDate, departmentID)
 
-	rows, err := r.erpDB.QueryContext(
-		ctx,
-		query,
-		sql.Named("FromDate", fromDate),
-		sql.Named("ToDate", toDate),
-		sql.Named("DepartmentID", departmentID),
-	)
+// assistant610FilterColumns match the filters with the ERP columns behind the report columns
+var assistant610FilterColumns = reportFilterColumns{
+	customerName:  "ISNULL(COPTG.TG007, '')",
+	invoiceNumber: "ISNULL(ACRTA.TA036, '')",
+	currencyType:  "ACRTA.TA009",
+	orderNumbers: []string{
+		"ACRTB.TB005 + '-' + ACRTB.TB006 + '-' + ACRTB.TB007",
+		"ISNULL(DetailOrder.order_no, '')",
+	},
+}
+
+// assistant610Sort orders pages of the report. Dates and amounts are formatted as text by the
+// query, so they are converted back to sort by value.
+var assistant610Sort = reportSort{
+	columns: map[string]string{
+		"doc_date":        "CONVERT(DATE, report.doc_date, 103)",
+		"ar_type":         "report.ar_type",
+		"shipping_order":  "report.shipping_order",
+		"customer_name":   "report.customer_name",
+		"total_amt_trans": "TRY_CONVERT(MONEY, report.total_amt_trans)",
+		"total_amt":       "TRY_CONVERT(MONEY, report.total_amt)",
+		"order_no":        "report.order_no",
+		"invoice_number":  "report.invoice_number",
+		"notes":           "report.notes",
+	},
+	defaultKey:  "doc_date",
+	tiebreakers: "report.ar_type, report.shipping_order",
+}
+
+type assistant610Repository struct {
+	erp    ERPPool
+	cached bool // read from the local ERP cache tables instead of the ERP server
+	logger *slog.Logger
+}
+
+func NewAssistant610Repository(erp ERPPool, logger *slog.Logger) Assistant610Repository {
+	return &assistant610Repository{
+		erp:    erp,
+		logger: logger,
+	}
+}
+
+// NewCachedAssistant610Repository reads the report from the ERP cache tables on the app database,
+// which hold the ERP data of the given company
+func NewCachedAssistant610Repository(db *sql.DB, company string, logger *slog.Logger) Assistant610Repository {
+	return &assistant610Repository{
+		erp:    cachePool{db: db, company: company},
+		cached: true,
+		logger: logger,
+	}
+}
+
+func (r *assistant610Repository) GetAssistant610Report(
+	ctx context.Context,
+	fromDate time.Time,
+	toDate time.Time,
+	departmentID int,
+	limit int,
+) ([]dto.Asisstant610ReportItem, error) {
+	r.logger.DebugContext(ctx, "Querying Assistant 610 report", "from_date", fromDate, "to_date", toDate, "department_id", departmentID)
+	erpDB, query, args, err := r.reportQuery(ctx, fromDate, toDate, departmentID, ReportFilter{})
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 {
+		query = strings.Replace(query, "SELECT DISTINCT", "SELECT DISTINCT TOP (@Limit)", 1)
+		args = append(args, sql.Named("Limit", limit))
+	}
+	r.logger.DebugContext(ctx, "Executing Assistant 610 query", "query", query)
+
+	rows, err := erpDB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error querying inventory data: %w", err)
 	}
@@ -98,17 +152,7 @@ WHERE  ACRTB.TB008 BETWEEN @FromDate AND @ToDate
 	var items []dto.Asisstant610ReportItem
 	for rows.Next() {
 		var item dto.Asisstant610ReportItem
-		if err := rows.Scan(
-			&item.DocDate,
-			&item.Ar_Type,
-			&item.ShippingOrder,
-			&item.CustomerName,
-			&item.TotalAmtTrans,
-			&item.TotalAmt,
-			&item.OrderNo,
-			&item.InvoiceNumber,
-			&item.Notes,
-		); err != nil {
+		if err := rows.Scan(assistant610ItemFields(&item)...); err != nil {
 			return nil, fmt.Errorf("error scanning inventory data: %w", err)
 		}
 		items = append(items, item)
@@ -121,3 +165,101 @@ WHERE  ACRTB.TB008 BETWEEN @FromDate AND @ToDate
 
 	return items, nil
 }
+
+// GetAssistant610ReportPage returns one sorted page of the filtered report and its total number of rows
+func (r *assistant610Repository) GetAssistant610ReportPage(
+	ctx context.Context,
+	fromDate time.Time,
+	toDate time.Time,
+	departmentID int,
+	filter ReportFilter,
+	page ReportPage,
+) ([]dto.Asisstant610ReportItem, int, error) {
+	r.logger.DebugContext(ctx, "Querying Assistant 610 report page",
+		"from_date", fromDate, "to_date", toDate, "department_id", departmentID,
+		"filter", filter, "offset", page.Offset, "limit", page.Limit, "sort", page.SortBy)
+
+	erpDB, query, args, err := r.reportQuery(ctx, fromDate, toDate, departmentID, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	paged, pageArgs, err := pageQuery(query, args, page, assistant610Sort)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := erpDB.QueryContext(ctx, paged, pageArgs...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error querying inventory data: %w", err)
+	}
+	defer rows.Close()
+
+	items := []dto.Asisstant610ReportItem{}
+	total := 0
+	for rows.Next() {
+		var item dto.Asisstant610ReportItem
+		if err := rows.Scan(append(assistant610ItemFields(&item), &total)...); err != nil {
+			return nil, 0, fmt.Errorf("error scanning inventory data: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating inventory data: %w", err)
+	}
+
+	if len(items) == 0 && page.Offset > 0 {
+		if total, err = countReportRows(ctx, erpDB, query, args); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return items, total, nil
+}
+
+// reportQuery returns the ERP database of the request's company and the filtered report query
+// with its arguments
+func (r *assistant610Repository) reportQuery(
+	ctx context.Context,
+	fromDate time.Time,
+	toDate time.Time,
+	departmentID int,
+	filter ReportFilter,
+) (*sql.DB, string, []interface{}, error) {
+	erpDB, err := r.erp.ERPDatabaseFor(ctx)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("error selecting ERP database: %w", err)
+	}
+
+	// The filters and row policies extend the WHERE clause that ends the query
+	conditions, filterArgs := filterConditions(filter, assistant610FilterColumns)
+	policy, policyArgs, err := policyConditions(ctx, "assistant610")
+	if err != nil {
+		return nil, "", nil, err
+	}
+	query := assistant610ReportQuery + conditions + policy
+	if r.cached {
+		query = cacheTableReplacer.Replace(query)
+	}
+	args := []interface{}{
+		sql.Named("FromDate", fromDate),
+		sql.Named("ToDate", toDate),
+		sql.Named("DepartmentID", departmentID),
+	}
+	args = append(args, filterArgs...)
+	return erpDB, query, append(args, policyArgs...), nil
+}
+
+// assistant610ItemFields returns the scan destinations of the report columns, in query order
+func assistant610ItemFields(item *dto.Asisstant610ReportItem) []interface{} {
+	return []interface{}{
+		&item.DocDate,
+		&item.Ar_Type,
+		&item.ShippingOrder,
+		&item.CustomerName,
+		&item.TotalAmtTrans,
+		&item.TotalAmt,
+		&item.OrderNo,
+		&item.InvoiceNumber,
+		&item.Notes,
+	}
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"fmt"
+	"time"
+)
+
+// AuditLogFilter narrows an audit trail query; zero values match everything
+type AuditLogFilter struct {
+	UserID int
+	Method string
+	Path   string // prefix of the request path
+	From   time.Time
+	To     time.Time
+	Limit  int
+}
+
+// AuditLogRepository stores the audit trail of mutating API requests
+type AuditLogRepository interface {
+	Create(ctx context.Context, entry *models.AuditLog) error
+	List(ctx context.Context, filter AuditLogFilter) ([]*models.AuditLog, error)
+}
+
+type auditLogRepository struct {
+	db *sql.DB
+}
+
+// NewAuditLogRepository creates a new audit log repository
+func NewAuditLogRepository(db *sql.DB) AuditLogRepository {
+	return &auditLogRepository{
+		db: db,
+	}
+}
+
+// Create stores an audit entry
+func (r *auditLogRepository) Create(ctx context.Context, entry *models.AuditLog) error {
+	query := `
+        INSERT INTO audit_logs (user_id, username, method, route, path, payload, ip_address, status_code, latency_ms, created_at)
+        VALUES (@user_id, NULLIF(@username, ''), @method, LEFT(@route, 255), LEFT(@path, 1000), NULLIF(@payload, ''), LEFT(@ip_address, 50), @status_code, @latency_ms, @created_at)
+    `
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("user_id", entry.UserID),
+		sql.Named("username", entry.Username),
+		sql.Named("method", entry.Method),
+		sql.Named("route", entry.Route),
+		sql.Named("path", entry.Path),
+		sql.Named("payload", entry.Payload),
+		sql.Named("ip_address", entry.IPAddress),
+		sql.Named("status_code", entry.StatusCode),
+		sql.Named("latency_ms", entry.LatencyMs),
+		sql.Named("created_at", entry.CreatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("error creating audit log: %w", err)
+	}
+	return nil
+}
+
+// List gets the newest audit entries matching the filter
+func (r *auditLogRepository) List(ctx context.Context, filter AuditLogFilter) ([]*models.AuditLog, error) {
+	query := `
+        SELECT TOP (@limit) id, user_id, ISNULL(username, ''), method, route, path, ISNULL(payload, ''), ip_address, status_code, latency_ms, created_at
+        FROM audit_logs
+        WHERE (@user_id = 0 OR user_id = @user_id)
+          AND (@method = '' OR method = @method)
+          AND (@path = '' OR path LIKE @path + '%')
+          AND (@from IS NULL OR created_at >= @from)
+          AND (@to IS NULL OR created_at <= @to)
+        ORDER BY id DESC
+    `
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		query,
+		sql.Named("limit", filter.Limit),
+		sql.Named("user_id", filter.UserID),
+		sql.Named("method", filter.Method),
+		sql.Named("path", filter.Path),
+		sql.Named("from", nullTime(filter.From)),
+		sql.Named("to", nullTime(filter.To)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error listing audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*models.AuditLog{}
+	for rows.Next() {
+		var entry models.AuditLog
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.UserID,
+			&entry.Username,
+			&entry.Method,
+			&entry.Route,
+			&entry.Path,
+			&entry.Payload,
+			&entry.IPAddress,
+			&entry.StatusCode,
+			&entry.LatencyMs,
+			&entry.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning audit log: %w", err)
+		}
+		entries = append(entries, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit logs: %w", err)
+	}
+
+	return entries, nil
+}
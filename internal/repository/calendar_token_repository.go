@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CalendarTokenRepository stores the hashed calendar feed tokens of the users
+type CalendarTokenRepository interface {
+	GetByUser(ctx context.Context, userID int) (*models.CalendarToken, error)
+	GetByHash(ctx context.Context, tokenHash string) (*models.CalendarToken, error)
+	Replace(ctx context.Context, token *models.CalendarToken) error
+	Delete(ctx context.Context, userID int) error
+	TouchLastUsed(ctx context.Context, userID int, usedAt time.Time) error
+}
+
+type calendarTokenRepository struct {
+	db *sql.DB
+}
+
+// NewCalendarTokenRepository creates a new calendar token repository
+func NewCalendarTokenRepository(db *sql.DB) CalendarTokenRepository {
+	return &calendarTokenRepository{
+		db: db,
+	}
+}
+
+const calendarTokenColumns = `user_id, token_hash, created_at, last_used_at`
+
+// GetByUser gets the token of a user
+func (r *calendarTokenRepository) GetByUser(ctx context.Context, userID int) (*models.CalendarToken, error) {
+	query := `SELECT ` + calendarTokenColumns + ` FROM calendar_tokens WHERE user_id = @user_id`
+	return r.get(ctx, query, sql.Named("user_id", userID))
+}
+
+// GetByHash gets the token with the given hash
+func (r *calendarTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.CalendarToken, error) {
+	query := `SELECT ` + calendarTokenColumns + ` FROM calendar_tokens WHERE token_hash = @token_hash`
+	return r.get(ctx, query, sql.Named("token_hash", tokenHash))
+}
+
+func (r *calendarTokenRepository) get(ctx context.Context, query string, args ...interface{}) (*models.CalendarToken, error) {
+	var token models.CalendarToken
+	var lastUsedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&token.UserID,
+		&token.TokenHash,
+		&token.CreatedAt,
+		&lastUsedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("calendar token not found: %w", err)
+		}
+		return nil, fmt.Errorf("error getting calendar token: %w", err)
+	}
+
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	return &token, nil
+}
+
+// Replace stores the token of a user in place of the previous one
+func (r *calendarTokenRepository) Replace(ctx context.Context, token *models.CalendarToken) error {
+	query := `
+        MERGE calendar_tokens AS target
+        USING (SELECT @user_id AS user_id) AS source
+        ON target.user_id = source.user_id
+        WHEN MATCHED THEN
+            UPDATE SET token_hash = @token_hash, created_at = @now, last_used_at = NULL
+        WHEN NOT MATCHED THEN
+            INSERT (user_id, token_hash, created_at)
+            VALUES (@user_id, @token_hash, @now);
+    `
+
+	token.CreatedAt = time.Now()
+	token.LastUsedAt = nil
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("user_id", token.UserID),
+		sql.Named("token_hash", token.TokenHash),
+		sql.Named("now", token.CreatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("error saving calendar token: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the token of a user
+func (r *calendarTokenRepository) Delete(ctx context.Context, userID int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM calendar_tokens WHERE user_id = @user_id", sql.Named("user_id", userID))
+	if err != nil {
+		return fmt.Errorf("error deleting calendar token: %w", err)
+	}
+
+	return checkAffected(result, "calendar token")
+}
+
+// TouchLastUsed records when the token of a user was last used
+func (r *calendarTokenRepository) TouchLastUsed(ctx context.Context, userID int, usedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE calendar_tokens SET last_used_at = @used_at WHERE user_id = @user_id`,
+		sql.Named("user_id", userID),
+		sql.Named("used_at", usedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("error updating calendar token last use: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,392 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/models"
+	"fmt"
+	"time"
+)
+
+// ConfigBackupRepository reads and writes the permission configuration as a whole
+type ConfigBackupRepository interface {
+	Export(ctx context.Context) (*dto.ConfigBundle, error)
+	Restore(ctx context.Context, bundle *dto.ConfigBundle, userID int, dryRun bool) (*dto.ConfigRestoreResponse, error)
+}
+
+type configBackupRepository struct {
+	db *sql.DB
+}
+
+// NewConfigBackupRepository creates a new configuration backup repository
+func NewConfigBackupRepository(db *sql.DB) ConfigBackupRepository {
+	return &configBackupRepository{
+		db: db,
+	}
+}
+
+// Export reads the operations, active departments and roles, role operations and stored report
+// definitions. Soft deleted departments and roles are left out.
+func (r *configBackupRepository) Export(ctx context.Context) (*dto.ConfigBundle, error) {
+	bundle := &dto.ConfigBundle{
+		Version:           dto.ConfigBundleVersion,
+		ExportedAt:        time.Now(),
+		Operations:        []dto.ConfigBundleOperation{},
+		Departments:       []dto.ConfigBundleDepartment{},
+		Roles:             []dto.ConfigBundleRole{},
+		ReportDefinitions: []*models.ReportDefinition{},
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT code, name, ISNULL(description, '') FROM operations ORDER BY code`)
+	if err != nil {
+		return nil, fmt.Errorf("error exporting operations: %w", err)
+	}
+	for rows.Next() {
+		var operation dto.ConfigBundleOperation
+		if err := rows.Scan(&operation.Code, &operation.Name, &operation.Description); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning operation: %w", err)
+		}
+		bundle.Operations = append(bundle.Operations, operation)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating operations: %w", err)
+	}
+
+	rows, err = r.db.QueryContext(ctx, `
+        SELECT code, name, ISNULL(description, ''), is_active
+        FROM departments
+        WHERE deleted_at IS NULL
+        ORDER BY code
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("error exporting departments: %w", err)
+	}
+	for rows.Next() {
+		var department dto.ConfigBundleDepartment
+		if err := rows.Scan(&department.Code, &department.Name, &department.Description, &department.IsActive); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning department: %w", err)
+		}
+		bundle.Departments = append(bundle.Departments, department)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating departments: %w", err)
+	}
+
+	rows, err = r.db.QueryContext(ctx, `
+        SELECT r.name, ISNULL(r.description, ''), ISNULL(p.name, ''), o.code
+        FROM roles r
+        LEFT JOIN roles p ON p.id = r.parent_role_id AND p.deleted_at IS NULL
+        LEFT JOIN role_operations ro ON ro.role_id = r.id AND ro.can_access = 1
+        LEFT JOIN operations o ON ro.operation_id = o.id
+        WHERE r.deleted_at IS NULL
+        ORDER BY r.name, o.code
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("error exporting roles: %w", err)
+	}
+	for rows.Next() {
+		var (
+			name        string
+			description string
+			parent      string
+			code        sql.NullString
+		)
+		if err := rows.Scan(&name, &description, &parent, &code); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning role: %w", err)
+		}
+
+		// Rows are ordered by role, one row per operation
+		if n := len(bundle.Roles); n == 0 || bundle.Roles[n-1].Name != name {
+			bundle.Roles = append(bundle.Roles, dto.ConfigBundleRole{
+				Name:        name,
+				Description: description,
+				Parent:      parent,
+				Operations:  []string{},
+			})
+		}
+		if code.Valid {
+			role := &bundle.Roles[len(bundle.Roles)-1]
+			role.Operations = append(role.Operations, code.String)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating roles: %w", err)
+	}
+
+	rows, err = r.db.QueryContext(ctx, `SELECT `+reportDefinitionColumns+` FROM report_definitions ORDER BY code`)
+	if err != nil {
+		return nil, fmt.Errorf("error exporting report definitions: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		definition, err := scanReportDefinition(rows)
+		if err != nil {
+			return nil, err
+		}
+		bundle.ReportDefinitions = append(bundle.ReportDefinitions, definition)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating report definitions: %w", err)
+	}
+
+	return bundle, nil
+}
+
+// Restore merges a bundle into the configuration in one transaction: entries are matched by code or
+// name, existing ones are updated and missing ones created. Soft deleted departments and roles that
+// appear in the bundle are restored, and the operations of every role in the bundle are replaced.
+// Entries that are not in the bundle are left untouched. A dry run rolls the transaction back.
+func (r *configBackupRepository) Restore(
+	ctx context.Context,
+	bundle *dto.ConfigBundle,
+	userID int,
+	dryRun bool,
+) (*dto.ConfigRestoreResponse, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	response := &dto.ConfigRestoreResponse{DryRun: dryRun}
+
+	for _, operation := range bundle.Operations {
+		created, err := upsert(
+			ctx, tx,
+			`SELECT id FROM operations WHERE code = @code`,
+			`UPDATE operations SET name = @name, description = @description, updated_at = @now WHERE id = @id`,
+			`INSERT INTO operations (name, code, description, created_at, updated_at)
+             OUTPUT INSERTED.id
+             VALUES (@name, @code, @description, @now, @now)`,
+			sql.Named("code", operation.Code),
+			sql.Named("name", operation.Name),
+			sql.Named("description", operation.Description),
+			sql.Named("now", now),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error restoring operation %s: %w", operation.Code, err)
+		}
+		countRestored(&response.Operations, created)
+	}
+
+	for _, department := range bundle.Departments {
+		created, err := upsert(
+			ctx, tx,
+			`SELECT id FROM departments WHERE code = @code`,
+			`UPDATE departments
+             SET name = @name, description = @description, is_active = @is_active, deleted_at = NULL, updated_at = @now
+             WHERE id = @id`,
+			`INSERT INTO departments (name, code, description, is_active, created_at, updated_at)
+             OUTPUT INSERTED.id
+             VALUES (@name, @code, @description, @is_active, @now, @now)`,
+			sql.Named("code", department.Code),
+			sql.Named("name", department.Name),
+			sql.Named("description", department.Description),
+			sql.Named("is_active", department.IsActive),
+			sql.Named("now", now),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error restoring department %s: %w", department.Code, err)
+		}
+		countRestored(&response.Departments, created)
+	}
+
+	for _, definition := range bundle.ReportDefinitions {
+		parameters, columns, err := marshalReportDefinition(definition)
+		if err != nil {
+			return nil, err
+		}
+
+		created, err := upsert(
+			ctx, tx,
+			`SELECT id FROM report_definitions WHERE code = @code`,
+			`UPDATE report_definitions
+             SET name = @name, description = @description, source_type = @source_type, source = @source,
+                 operation_code = @operation_code, parameters = @parameters, column_map = @columns,
+                 timeout_seconds = @timeout_seconds, max_rows = @max_rows, title_template = @title_template,
+                 file_name_template = @file_name_template, is_active = @is_active, updated_at = @now
+             WHERE id = @id`,
+			`INSERT INTO report_definitions (
+                 code, name, description, source_type, source, operation_code, parameters, column_map,
+                 timeout_seconds, max_rows, title_template, file_name_template, is_active, created_by,
+                 created_at, updated_at
+             )
+             OUTPUT INSERTED.id
+             VALUES (
+                 @code, @name, @description, @source_type, @source, @operation_code, @parameters, @columns,
+                 @timeout_seconds, @max_rows, @title_template, @file_name_template, @is_active, @created_by,
+                 @now, @now
+             )`,
+			sql.Named("code", definition.Code),
+			sql.Named("name", definition.Name),
+			sql.Named("description", definition.Description),
+			sql.Named("source_type", definition.SourceType),
+			sql.Named("source", definition.Source),
+			sql.Named("operation_code", definition.OperationCode),
+			sql.Named("parameters", parameters),
+			sql.Named("columns", columns),
+			sql.Named("timeout_seconds", definition.TimeoutSeconds),
+			sql.Named("max_rows", definition.MaxRows),
+			sql.Named("title_template", definition.TitleTemplate),
+			sql.Named("file_name_template", definition.FileNameTemplate),
+			sql.Named("is_active", definition.IsActive),
+			sql.Named("created_by", userID),
+			sql.Named("now", now),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error restoring report definition %s: %w", definition.Code, err)
+		}
+		if err := ensureReportOperation(ctx, tx, definition, now); err != nil {
+			return nil, err
+		}
+		countRestored(&response.ReportDefinitions, created)
+	}
+
+	// Roles go last so they can reference the operations restored above
+	operationIDs, err := operationIDsByCode(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, role := range bundle.Roles {
+		var roleID int
+		err := tx.QueryRowContext(ctx, `SELECT id FROM roles WHERE name = @name`, sql.Named("name", role.Name)).Scan(&roleID)
+		switch {
+		case err == sql.ErrNoRows:
+			err = tx.QueryRowContext(
+				ctx,
+				`INSERT INTO roles (name, description, created_at, updated_at)
+                 OUTPUT INSERTED.id
+                 VALUES (@name, @description, @now, @now)`,
+				sql.Named("name", role.Name),
+				sql.Named("description", role.Description),
+				sql.Named("now", now),
+			).Scan(&roleID)
+			response.Roles.Created++
+		case err == nil:
+			_, err = tx.ExecContext(
+				ctx,
+				`UPDATE roles SET description = @description, deleted_at = NULL, updated_at = @now WHERE id = @id`,
+				sql.Named("id", roleID),
+				sql.Named("description", role.Description),
+				sql.Named("now", now),
+			)
+			response.Roles.Updated++
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error restoring role %s: %w", role.Name, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM role_operations WHERE role_id = @role_id`, sql.Named("role_id", roleID)); err != nil {
+			return nil, fmt.Errorf("error restoring operations of role %s: %w", role.Name, err)
+		}
+		for _, code := range role.Operations {
+			operationID, ok := operationIDs[code]
+			if !ok {
+				return nil, fmt.Errorf("role %s references unknown operation %s", role.Name, code)
+			}
+			_, err := tx.ExecContext(
+				ctx,
+				`INSERT INTO role_operations (role_id, operation_id, can_access, created_at) VALUES (@role_id, @operation_id, 1, @now)`,
+				sql.Named("role_id", roleID),
+				sql.Named("operation_id", operationID),
+				sql.Named("now", now),
+			)
+			if err != nil {
+				return nil, fmt.Errorf("error restoring operations of role %s: %w", role.Name, err)
+			}
+		}
+	}
+
+	// Parents are linked once every role of the bundle exists
+	for _, role := range bundle.Roles {
+		result, err := tx.ExecContext(
+			ctx,
+			`UPDATE roles
+             SET parent_role_id = (SELECT id FROM roles WHERE name = @parent)
+             WHERE name = @name AND (@parent = '' OR EXISTS (SELECT 1 FROM roles WHERE name = @parent))`,
+			sql.Named("name", role.Name),
+			sql.Named("parent", role.Parent),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error restoring parent of role %s: %w", role.Name, err)
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return nil, fmt.Errorf("role %s references unknown parent role %s", role.Name, role.Parent)
+		}
+	}
+
+	if dryRun {
+		return response, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing restore: %w", err)
+	}
+
+	return response, nil
+}
+
+// upsert updates the row found by the select query, passing its id as @id, or inserts a new one.
+// It reports whether the row was created.
+func upsert(ctx context.Context, tx *sql.Tx, selectQuery, updateQuery, insertQuery string, args ...interface{}) (bool, error) {
+	var id int
+	err := tx.QueryRowContext(ctx, selectQuery, args...).Scan(&id)
+	if err == sql.ErrNoRows {
+		if err := tx.QueryRowContext(ctx, insertQuery, args...).Scan(&id); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, updateQuery, append(args, sql.Named("id", id))...); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// operationIDsByCode maps every operation code to its id
+func operationIDsByCode(ctx context.Context, tx *sql.Tx) (map[string]int, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id, code FROM operations`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting operations: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[string]int)
+	for rows.Next() {
+		var (
+			id   int
+			code string
+		)
+		if err := rows.Scan(&id, &code); err != nil {
+			return nil, fmt.Errorf("error scanning operation: %w", err)
+		}
+		ids[code] = id
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating operations: %w", err)
+	}
+
+	return ids, nil
+}
+
+// countRestored records one restored entry
+func countRestored(count *dto.ConfigRestoreCount, created bool) {
+	if created {
+		count.Created++
+	} else {
+		count.Updated++
+	}
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/dto"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// DashboardRepository aggregates the ERP sales orders for the dashboard, only those the Assistant
+// 230 row policies of the context allow. Its queries end with their WHERE clause so the policies
+// can extend it.
+type DashboardRepository interface {
+	GetSalesTotals(ctx context.Context, fromDate time.Time, toDate time.Time) (*dto.DashboardSalesTotals, error)
+	GetTopCustomers(ctx context.Context, fromDate time.Time, toDate time.Time, limit int) ([]dto.DashboardCustomer, error)
+}
+
+// An order counts as invoiced like in the Assistant 230 report: when an AR invoice line refers to it
+const dashboardSalesTotalsQuery = `
+SELECT
+    COUNT(*) AS order_count,
+    ISNULL(SUM(ISNULL(COPTG.TG045, 0) + ISNULL(COPTG.TG046, 0)), 0) AS sales_amount,
+    ISNULL(SUM(CASE WHEN NOT EXISTS (
+        SELECT 1
+        FROM ACRTB WITH (NOLOCK)
+        JOIN ACRTA WITH (NOLOCK) ON ACRTA.TA001 = ACRTB.TB001 AND ACRTA.TA002 = ACRTB.TB002
+        WHERE ACRTB.TB005 = COPTG.TG001 AND ACRTB.TB006 = COPTG.TG002
+    ) THEN 1 ELSE 0 END), 0) AS uninvoiced_order_count
+FROM
+    COPTG WITH (NOLOCK)
+WHERE
+    COPTG.TG023 <> 'V'
+    AND COPTG.TG042 BETWEEN @FromDate AND @ToDate`
+
+const dashboardTopCustomersQuery = `
+SELECT TOP (@Limit)
+    ISNULL(COPTG.TG007, '') AS customer_name,
+    COUNT(*) AS order_count,
+    SUM(ISNULL(COPTG.TG045, 0) + ISNULL(COPTG.TG046, 0)) AS sales_amount
+FROM
+    COPTG WITH (NOLOCK)
+WHERE
+    COPTG.TG023 <> 'V'
+    AND COPTG.TG042 BETWEEN @FromDate AND @ToDate`
+
+const dashboardTopCustomersOrder = `
+GROUP BY
+    ISNULL(COPTG.TG007, '')
+ORDER BY
+    sales_amount DESC, customer_name
+`
+
+type dashboardRepository struct {
+	erp    ERPPool
+	cached bool // read from the local ERP cache tables instead of the ERP server
+	logger *slog.Logger
+}
+
+func NewDashboardRepository(erp ERPPool, logger *slog.Logger) DashboardRepository {
+	return &dashboardRepository{
+		erp:    erp,
+		logger: logger,
+	}
+}
+
+// NewCachedDashboardRepository aggregates the ERP cache tables on the app database,
+// which hold the ERP data of the given company
+func NewCachedDashboardRepository(db *sql.DB, company string, logger *slog.Logger) DashboardRepository {
+	return &dashboardRepository{
+		erp:    cachePool{db: db, company: company},
+		cached: true,
+		logger: logger,
+	}
+}
+
+// GetSalesTotals sums the sales orders dated within the range and counts those not invoiced yet
+func (r *dashboardRepository) GetSalesTotals(ctx context.Context, fromDate time.Time, toDate time.Time) (*dto.DashboardSalesTotals, error) {
+	erpDB, query, policyArgs, err := r.prepare(ctx, dashboardSalesTotalsQuery, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var totals dto.DashboardSalesTotals
+	if err := erpDB.QueryRowContext(ctx, query, append(dateRangeArgs(fromDate, toDate), policyArgs...)...).Scan(
+		&totals.OrderCount,
+		&totals.SalesAmount,
+		&totals.UninvoicedOrderCount,
+	); err != nil {
+		return nil, fmt.Errorf("error querying sales totals: %w", err)
+	}
+
+	return &totals, nil
+}
+
+// GetTopCustomers returns the customers with the largest sales within the range, largest first
+func (r *dashboardRepository) GetTopCustomers(ctx context.Context, fromDate time.Time, toDate time.Time, limit int) ([]dto.DashboardCustomer, error) {
+	erpDB, query, policyArgs, err := r.prepare(ctx, dashboardTopCustomersQuery, dashboardTopCustomersOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	args := append(dateRangeArgs(fromDate, toDate), sql.Named("Limit", limit))
+	args = append(args, policyArgs...)
+	rows, err := erpDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying top customers: %w", err)
+	}
+	defer rows.Close()
+
+	customers := []dto.DashboardCustomer{}
+	for rows.Next() {
+		var customer dto.DashboardCustomer
+		if err := rows.Scan(&customer.CustomerName, &customer.OrderCount, &customer.SalesAmount); err != nil {
+			return nil, fmt.Errorf("error scanning top customer: %w", err)
+		}
+		customers = append(customers, customer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating top customers: %w", err)
+	}
+
+	return customers, nil
+}
+
+// prepare returns the ERP database of the request's company and the query, its WHERE clause
+// extended by the row policies and followed by suffix, pointed at the cache tables when reading
+// the cache, with the arguments of the policies
+func (r *dashboardRepository) prepare(ctx context.Context, query string, suffix string) (*sql.DB, string, []interface{}, error) {
+	erpDB, err := r.erp.ERPDatabaseFor(ctx)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("error selecting ERP database: %w", err)
+	}
+	policy, policyArgs, err := policyConditions(ctx, "assistant230")
+	if err != nil {
+		return nil, "", nil, err
+	}
+	query += policy + suffix
+	if r.cached {
+		query = cacheTableReplacer.Replace(query)
+	}
+	return erpDB, query, policyArgs, nil
+}
+
+// dateRangeArgs passes a date range as YYYYMMDD text, the format of the ERP document dates
+func dateRangeArgs(fromDate time.Time, toDate time.Time) []interface{} {
+	return []interface{}{
+		sql.Named("FromDate", fromDate.Format("20060102")),
+		sql.Named("ToDate", toDate.Format("20060102")),
+	}
+}
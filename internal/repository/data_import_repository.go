@@ -0,0 +1,561 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"erp-excel/internal/models"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ERPInvoiceState is the invoice of a COPTG document in the ERP
+type ERPInvoiceState struct {
+	DocType       string
+	DocNo         string
+	Confirmed     string // TG023, V for voided documents
+	Invoiced      bool
+	InvoiceNumber string // ACRTA.TA036
+}
+
+// DataImportRepository stages the ERP corrections uploaded from Excel and reads the ERP values
+// they are validated against
+type DataImportRepository interface {
+	CreateBatch(ctx context.Context, batch *models.ImportBatch, rows []*models.ImportRow) error
+	GetBatch(ctx context.Context, id int) (*models.ImportBatch, error)
+	ListBatches(ctx context.Context, limit int) ([]*models.ImportBatch, error)
+	ListRows(ctx context.Context, batchID int) ([]*models.ImportRow, error)
+	Commit(ctx context.Context, batch *models.ImportBatch, rows []*models.ImportRow, userID int) error
+	Discard(ctx context.Context, id int, userID int) error
+	GetInvoices(ctx context.Context, keys [][2]string) (map[[2]string]*ERPInvoiceState, error)
+
+	ListRules(ctx context.Context, importType string) ([]*models.ImportRule, error)
+	CreateRule(ctx context.Context, rule *models.ImportRule) error
+	DeleteRule(ctx context.Context, id int) error
+	LookupERPValues(ctx context.Context, lookup string, values []string) (map[string]bool, error)
+}
+
+type dataImportRepository struct {
+	db  *sql.DB
+	erp ERPPool
+}
+
+// NewDataImportRepository creates a new data import repository
+func NewDataImportRepository(db *sql.DB, erp ERPPool) DataImportRepository {
+	return &dataImportRepository{
+		db:  db,
+		erp: erp,
+	}
+}
+
+// erpLookups are the ERP master tables lookup rules check values against, with their key column.
+// Rules name a lookup, never a table, so no table or column from a request reaches the SQL.
+var erpLookups = map[string][2]string{
+	"customer":  {"COPMA", "MA001"},
+	"supplier":  {"PURMA", "MA001"},
+	"item":      {"INVMB", "MB001"},
+	"warehouse": {"CMSMC", "MC001"},
+}
+
+// ERPLookups returns the names of the ERP lookups, sorted
+func ERPLookups() []string {
+	names := make([]string, 0, len(erpLookups))
+	for name := range erpLookups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+const importBatchQuery = `
+        SELECT b.id, b.import_type, b.file_name, b.status, b.total_rows, b.changed_rows,
+               b.unchanged_rows, b.invalid_rows, b.user_id, b.created_at, b.decided_by, b.decided_at,
+               ISNULL(u.username, '')
+        FROM import_batches b
+        LEFT JOIN users u ON b.user_id = u.id
+`
+
+// CreateBatch records a batch and its rows in one transaction, setting their IDs
+func (r *dataImportRepository) CreateBatch(ctx context.Context, batch *models.ImportBatch, rows []*models.ImportRow) error {
+	if batch.CreatedAt.IsZero() {
+		batch.CreatedAt = time.Now()
+	}
+
+	return runInTx(ctx, r.db, func(tx DBTX) error {
+		err := tx.QueryRowContext(
+			ctx,
+			`
+            INSERT INTO import_batches (import_type, file_name, status, total_rows, changed_rows, unchanged_rows, invalid_rows, user_id, created_at)
+            OUTPUT INSERTED.id
+            VALUES (@import_type, @file_name, @status, @total_rows, @changed_rows, @unchanged_rows, @invalid_rows, @user_id, @created_at)
+        `,
+			sql.Named("import_type", batch.ImportType),
+			sql.Named("file_name", batch.FileName),
+			sql.Named("status", batch.Status),
+			sql.Named("total_rows", batch.TotalRows),
+			sql.Named("changed_rows", batch.ChangedRows),
+			sql.Named("unchanged_rows", batch.UnchangedRows),
+			sql.Named("invalid_rows", batch.InvalidRows),
+			sql.Named("user_id", batch.UserID),
+			sql.Named("created_at", batch.CreatedAt),
+		).Scan(&batch.ID)
+		if err != nil {
+			return fmt.Errorf("error creating import batch: %w", err)
+		}
+
+		query := `
+            INSERT INTO import_rows (batch_id, sheet_row, row_key, old_value, new_value, status, errors)
+            OUTPUT INSERTED.id
+            VALUES (@batch_id, @sheet_row, @row_key, @old_value, @new_value, @status, @errors)
+        `
+		for _, row := range rows {
+			row.BatchID = batch.ID
+			cellErrors, err := encodeCellErrors(row.Errors)
+			if err != nil {
+				return err
+			}
+			err = tx.QueryRowContext(
+				ctx,
+				query,
+				sql.Named("batch_id", row.BatchID),
+				sql.Named("sheet_row", row.RowNumber),
+				sql.Named("row_key", row.Key),
+				sql.Named("old_value", row.OldValue),
+				sql.Named("new_value", row.NewValue),
+				sql.Named("status", row.Status),
+				sql.Named("errors", cellErrors),
+			).Scan(&row.ID)
+			if err != nil {
+				return fmt.Errorf("error staging import row: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetBatch gets an import batch
+func (r *dataImportRepository) GetBatch(ctx context.Context, id int) (*models.ImportBatch, error) {
+	query := importBatchQuery + ` WHERE b.id = @id`
+
+	return scanImportBatch(r.db.QueryRowContext(ctx, query, sql.Named("id", id)))
+}
+
+// ListBatches gets the newest import batches
+func (r *dataImportRepository) ListBatches(ctx context.Context, limit int) ([]*models.ImportBatch, error) {
+	query := importBatchQuery + ` ORDER BY b.id DESC OFFSET 0 ROWS FETCH NEXT @limit ROWS ONLY`
+
+	rows, err := r.db.QueryContext(ctx, query, sql.Named("limit", limit))
+	if err != nil {
+		return nil, fmt.Errorf("error listing import batches: %w", err)
+	}
+	defer rows.Close()
+
+	var batches []*models.ImportBatch
+	for rows.Next() {
+		batch, err := scanImportBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, batch)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating import batches: %w", err)
+	}
+
+	return batches, nil
+}
+
+// ListRows gets the staged rows of a batch in sheet order
+func (r *dataImportRepository) ListRows(ctx context.Context, batchID int) ([]*models.ImportRow, error) {
+	query := `
+        SELECT id, batch_id, sheet_row, row_key, ISNULL(old_value, ''), ISNULL(new_value, ''), status, ISNULL(errors, '')
+        FROM import_rows
+        WHERE batch_id = @batch_id
+        ORDER BY sheet_row
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, sql.Named("batch_id", batchID))
+	if err != nil {
+		return nil, fmt.Errorf("error listing import rows: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*models.ImportRow
+	for rows.Next() {
+		var row models.ImportRow
+		var cellErrors string
+		if err := rows.Scan(
+			&row.ID,
+			&row.BatchID,
+			&row.RowNumber,
+			&row.Key,
+			&row.OldValue,
+			&row.NewValue,
+			&row.Status,
+			&cellErrors,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning import row: %w", err)
+		}
+		if cellErrors != "" {
+			if err := json.Unmarshal([]byte(cellErrors), &row.Errors); err != nil {
+				return nil, fmt.Errorf("error reading errors of import row %d: %w", row.ID, err)
+			}
+		}
+		result = append(result, &row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating import rows: %w", err)
+	}
+
+	return result, nil
+}
+
+// Commit marks a previewed batch committed with its counts, saving the status and errors of the
+// given rows, which were validated again. Batches no longer previewed are not found.
+func (r *dataImportRepository) Commit(ctx context.Context, batch *models.ImportBatch, rows []*models.ImportRow, userID int) error {
+	return runInTx(ctx, r.db, func(tx DBTX) error {
+		result, err := tx.ExecContext(
+			ctx,
+			`
+            UPDATE import_batches
+            SET status = @status, changed_rows = @changed_rows, unchanged_rows = @unchanged_rows,
+                invalid_rows = @invalid_rows, decided_by = @decided_by, decided_at = @decided_at
+            WHERE id = @id AND status = @previewed
+        `,
+			sql.Named("status", models.ImportCommitted),
+			sql.Named("changed_rows", batch.ChangedRows),
+			sql.Named("unchanged_rows", batch.UnchangedRows),
+			sql.Named("invalid_rows", batch.InvalidRows),
+			sql.Named("decided_by", userID),
+			sql.Named("decided_at", time.Now()),
+			sql.Named("id", batch.ID),
+			sql.Named("previewed", models.ImportPreviewed),
+		)
+		if err != nil {
+			return fmt.Errorf("error committing import batch: %w", err)
+		}
+		if err := checkAffected(result, "import batch"); err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			cellErrors, err := encodeCellErrors(row.Errors)
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(
+				ctx,
+				"UPDATE import_rows SET old_value = @old_value, status = @status, errors = @errors WHERE id = @id",
+				sql.Named("old_value", row.OldValue),
+				sql.Named("status", row.Status),
+				sql.Named("errors", cellErrors),
+				sql.Named("id", row.ID),
+			)
+			if err != nil {
+				return fmt.Errorf("error updating import row: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Discard marks a previewed batch discarded. Batches no longer previewed are not found.
+func (r *dataImportRepository) Discard(ctx context.Context, id int, userID int) error {
+	result, err := r.db.ExecContext(
+		ctx,
+		`
+        UPDATE import_batches
+        SET status = @status, decided_by = @decided_by, decided_at = @decided_at
+        WHERE id = @id AND status = @previewed
+    `,
+		sql.Named("status", models.ImportDiscarded),
+		sql.Named("decided_by", userID),
+		sql.Named("decided_at", time.Now()),
+		sql.Named("id", id),
+		sql.Named("previewed", models.ImportPreviewed),
+	)
+	if err != nil {
+		return fmt.Errorf("error discarding import batch: %w", err)
+	}
+
+	return checkAffected(result, "import batch")
+}
+
+// invoiceLookupSize is how many documents GetInvoices reads per query, keeping the two
+// parameters of each below the 2100 parameters SQL Server accepts
+const invoiceLookupSize = 500
+
+// GetInvoices reads the invoice of the given COPTG documents from the ERP. Documents invoiced on
+// several invoices report the first invoice number found.
+func (r *dataImportRepository) GetInvoices(ctx context.Context, keys [][2]string) (map[[2]string]*ERPInvoiceState, error) {
+	result := make(map[[2]string]*ERPInvoiceState, len(keys))
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	erpDB, err := r.erp.ERPDatabaseFor(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting ERP database: %w", err)
+	}
+
+	for start := 0; start < len(keys); start += invoiceLookupSize {
+		end := min(start+invoiceLookupSize, len(keys))
+		if err := r.readInvoices(ctx, erpDB, keys[start:end], result); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// readInvoices reads the invoices of one chunk of documents into result
+func (r *dataImportRepository) readInvoices(ctx context.Context, erpDB *sql.DB, keys [][2]string, result map[[2]string]*ERPInvoiceState) error {
+	filter, params := keyFilter(keys)
+	query := fmt.Sprintf(`
+        SELECT RTRIM(COPTG.TG001), RTRIM(COPTG.TG002), ISNULL(COPTG.TG023, ''),
+               CASE WHEN ACRTA.TA001 IS NULL THEN 0 ELSE 1 END, ISNULL(RTRIM(ACRTA.TA036), '')
+        FROM COPTG WITH (NOLOCK)
+        LEFT JOIN ACRTB WITH (NOLOCK) ON ACRTB.TB005 = COPTG.TG001 AND ACRTB.TB006 = COPTG.TG002
+        LEFT JOIN ACRTA WITH (NOLOCK) ON ACRTA.TA001 = ACRTB.TB001 AND ACRTA.TA002 = ACRTB.TB002
+        WHERE %s
+    `, filter)
+
+	rows, err := erpDB.QueryContext(ctx, query, params...)
+	if err != nil {
+		return fmt.Errorf("error reading ERP invoices: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var state ERPInvoiceState
+		if err := rows.Scan(&state.DocType, &state.DocNo, &state.Confirmed, &state.Invoiced, &state.InvoiceNumber); err != nil {
+			return fmt.Errorf("error scanning ERP invoice: %w", err)
+		}
+		key := [2]string{state.DocType, state.DocNo}
+		if existing, ok := result[key]; ok && existing.Invoiced {
+			continue
+		}
+		result[key] = &state
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating ERP invoices: %w", err)
+	}
+	return nil
+}
+
+// ListRules returns the validation rules managed in the database for an import type, or for all
+// types when importType is empty, oldest first
+func (r *dataImportRepository) ListRules(ctx context.Context, importType string) ([]*models.ImportRule, error) {
+	query := `
+        SELECT id, import_type, column_key, rule_type, ISNULL(pattern, ''), min_value, max_value,
+               ISNULL(lookup, ''), ISNULL(message, ''), created_by, created_at
+        FROM import_rules
+        WHERE @import_type = '' OR import_type = @import_type
+        ORDER BY id
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, sql.Named("import_type", importType))
+	if err != nil {
+		return nil, fmt.Errorf("error listing import rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*models.ImportRule
+	for rows.Next() {
+		var rule models.ImportRule
+		var minValue, maxValue sql.NullFloat64
+		var createdAt time.Time
+		err := rows.Scan(
+			&rule.ID,
+			&rule.ImportType,
+			&rule.Column,
+			&rule.Type,
+			&rule.Pattern,
+			&minValue,
+			&maxValue,
+			&rule.Lookup,
+			&rule.Message,
+			&rule.CreatedBy,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning import rule: %w", err)
+		}
+		if minValue.Valid {
+			rule.Min = &minValue.Float64
+		}
+		if maxValue.Valid {
+			rule.Max = &maxValue.Float64
+		}
+		rule.Source = models.ImportRuleCustom
+		rule.CreatedAt = &createdAt
+		rules = append(rules, &rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating import rules: %w", err)
+	}
+	return rules, nil
+}
+
+// CreateRule records a validation rule, setting its ID
+func (r *dataImportRepository) CreateRule(ctx context.Context, rule *models.ImportRule) error {
+	now := time.Now()
+	err := r.db.QueryRowContext(
+		ctx,
+		`
+        INSERT INTO import_rules (import_type, column_key, rule_type, pattern, min_value, max_value, lookup, message, created_by, created_at)
+        OUTPUT INSERTED.id
+        VALUES (@import_type, @column_key, @rule_type, NULLIF(@pattern, ''), @min_value, @max_value, NULLIF(@lookup, ''), NULLIF(@message, ''), @created_by, @created_at)
+    `,
+		sql.Named("import_type", rule.ImportType),
+		sql.Named("column_key", rule.Column),
+		sql.Named("rule_type", rule.Type),
+		sql.Named("pattern", rule.Pattern),
+		sql.Named("min_value", nullFloatPtr(rule.Min)),
+		sql.Named("max_value", nullFloatPtr(rule.Max)),
+		sql.Named("lookup", rule.Lookup),
+		sql.Named("message", rule.Message),
+		sql.Named("created_by", rule.CreatedBy),
+		sql.Named("created_at", now),
+	).Scan(&rule.ID)
+	if err != nil {
+		return fmt.Errorf("error creating import rule: %w", err)
+	}
+
+	rule.Source = models.ImportRuleCustom
+	rule.CreatedAt = &now
+	return nil
+}
+
+// DeleteRule deletes a validation rule
+func (r *dataImportRepository) DeleteRule(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM import_rules WHERE id = @id`, sql.Named("id", id))
+	if err != nil {
+		return fmt.Errorf("error deleting import rule: %w", err)
+	}
+	return checkAffected(result, "import rule")
+}
+
+// erpLookupSize is how many values LookupERPValues checks per query
+const erpLookupSize = 1000
+
+// LookupERPValues reports which of the values exist as keys of the ERP table of a lookup. The
+// result is keyed by the upper-case values, as ERP collations ignore case.
+func (r *dataImportRepository) LookupERPValues(ctx context.Context, lookup string, values []string) (map[string]bool, error) {
+	table, ok := erpLookups[lookup]
+	if !ok {
+		return nil, fmt.Errorf("unknown ERP lookup %s", lookup)
+	}
+
+	found := make(map[string]bool, len(values))
+	if len(values) == 0 {
+		return found, nil
+	}
+
+	erpDB, err := r.erp.ERPDatabaseFor(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting ERP database: %w", err)
+	}
+
+	for start := 0; start < len(values); start += erpLookupSize {
+		chunk := values[start:min(start+erpLookupSize, len(values))]
+		names := make([]string, len(chunk))
+		params := make([]interface{}, len(chunk))
+		for i, value := range chunk {
+			names[i] = fmt.Sprintf("@v%d", i)
+			params[i] = sql.Named(fmt.Sprintf("v%d", i), value)
+		}
+
+		query := fmt.Sprintf(
+			"SELECT DISTINCT RTRIM(%[2]s) FROM %[1]s WITH (NOLOCK) WHERE %[2]s IN (%[3]s)",
+			table[0], table[1], strings.Join(names, ", "),
+		)
+		if err := r.readLookup(ctx, erpDB, query, params, found); err != nil {
+			return nil, fmt.Errorf("error looking up ERP %s: %w", lookup, err)
+		}
+	}
+
+	return found, nil
+}
+
+// readLookup adds the keys returned by a lookup query to found
+func (r *dataImportRepository) readLookup(ctx context.Context, erpDB *sql.DB, query string, params []interface{}, found map[string]bool) error {
+	rows, err := erpDB.QueryContext(ctx, query, params...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return err
+		}
+		found[strings.ToUpper(key)] = true
+	}
+	return rows.Err()
+}
+
+// nullFloatPtr maps an optional number to a nullable parameter
+func nullFloatPtr(value *float64) sql.NullFloat64 {
+	if value == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: *value, Valid: true}
+}
+
+// scanImportBatch scans one row of importBatchQuery
+func scanImportBatch(row rowScanner) (*models.ImportBatch, error) {
+	var batch models.ImportBatch
+	var decidedBy sql.NullInt64
+	var decidedAt sql.NullTime
+
+	err := row.Scan(
+		&batch.ID,
+		&batch.ImportType,
+		&batch.FileName,
+		&batch.Status,
+		&batch.TotalRows,
+		&batch.ChangedRows,
+		&batch.UnchangedRows,
+		&batch.InvalidRows,
+		&batch.UserID,
+		&batch.CreatedAt,
+		&decidedBy,
+		&decidedAt,
+		&batch.Username,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("import batch not found: %w", err)
+		}
+		return nil, fmt.Errorf("error scanning import batch: %w", err)
+	}
+
+	if decidedBy.Valid {
+		id := int(decidedBy.Int64)
+		batch.DecidedBy = &id
+	}
+	if decidedAt.Valid {
+		batch.DecidedAt = &decidedAt.Time
+	}
+	return &batch, nil
+}
+
+// encodeCellErrors returns the JSON of the errors of a row, or NULL when it has none
+func encodeCellErrors(cellErrors []models.ImportCellError) (sql.NullString, error) {
+	if len(cellErrors) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(cellErrors)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("error encoding import row errors: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
@@ -17,25 +17,56 @@ type DepartmentRepository interface {
 	List(ctx context.Context, limit, offset int) ([]*models.Department, error)
 	Count(ctx context.Context) (int, error)
 	GetUserCount(ctx context.Context, departmentID int) (int, error)
+	GetTotalUserCount(ctx context.Context, departmentID int) (int, error)
+	GetUserCounts(ctx context.Context) (map[int]int, error)
+	ListAll(ctx context.Context) ([]*models.Department, error)
+	CountChildren(ctx context.Context, departmentID int) (int, error)
+	GetAncestorIDs(ctx context.Context, departmentID int) ([]int, error)
+	GetDescendantIDs(ctx context.Context, departmentID int) ([]int, error)
+	ListDeleted(ctx context.Context) ([]*models.Department, error)
+	Restore(ctx context.Context, id int) error
+	Search(ctx context.Context, text string, limit int) ([]*models.Department, error)
 }
 
 type departmentRepository struct {
-	db *sql.DB
+	db    *sql.DB
+	reads ReadPool
 }
 
-// NewDepartmentRepository creates a new department repository
-func NewDepartmentRepository(db *sql.DB) DepartmentRepository {
+// NewDepartmentRepository creates a new department repository. GetByID, List and Count read from
+// the read pool, which may be nil to read from db.
+func NewDepartmentRepository(db *sql.DB, reads ReadPool) DepartmentRepository {
 	return &departmentRepository{
-		db: db,
+		db:    db,
+		reads: reads,
 	}
 }
 
+// maxDepartmentDepth bounds the walk through the department tree, so a cycle left in the data
+// cannot loop forever
+const maxDepartmentDepth = 32
+
+// departmentSubtreeCTE resolves @department_id together with every department below it. Deleted
+// departments are skipped and so are the departments below them.
+const departmentSubtreeCTE = `
+        WITH subtree AS (
+            SELECT id, 0 AS depth
+            FROM departments
+            WHERE id = @department_id AND deleted_at IS NULL
+            UNION ALL
+            SELECT d.id, s.depth + 1
+            FROM subtree s
+            JOIN departments d ON d.parent_department_id = s.id
+            WHERE d.deleted_at IS NULL AND s.depth < @max_depth
+        )
+`
+
 // Create adds a new department
 func (r *departmentRepository) Create(ctx context.Context, department *models.Department) (*models.Department, error) {
 	query := `
-        INSERT INTO departments (name, code, description, is_active, created_at, updated_at)
+        INSERT INTO departments (name, code, description, parent_department_id, is_active, created_at, updated_at)
         OUTPUT INSERTED.id
-        VALUES (@name, @code, @description, @is_active, @created_at, @updated_at)
+        VALUES (@name, @code, @description, @parent_department_id, @is_active, @created_at, @updated_at)
     `
 
 	var id int
@@ -45,6 +76,7 @@ func (r *departmentRepository) Create(ctx context.Context, department *models.De
 		sql.Named("name", department.Name),
 		sql.Named("code", department.Code),
 		sql.Named("description", department.Description),
+		sql.Named("parent_department_id", nullIntPtr(department.ParentDepartmentID)),
 		sql.Named("is_active", department.IsActive),
 		sql.Named("created_at", time.Now()),
 		sql.Named("updated_at", time.Now()),
@@ -61,17 +93,19 @@ func (r *departmentRepository) Create(ctx context.Context, department *models.De
 // GetByID gets a department by ID
 func (r *departmentRepository) GetByID(ctx context.Context, id int) (*models.Department, error) {
 	query := `
-        SELECT id, name, code, description, is_active, created_at, updated_at
+        SELECT id, name, code, description, parent_department_id, is_active, created_at, updated_at
         FROM departments
-        WHERE id = @id
+        WHERE id = @id AND deleted_at IS NULL
     `
 
 	var department models.Department
-	err := r.db.QueryRowContext(ctx, query, sql.Named("id", id)).Scan(
+	var parentDepartmentID sql.NullInt64
+	err := reader(ctx, r.db, r.reads).QueryRowContext(ctx, query, sql.Named("id", id)).Scan(
 		&department.ID,
 		&department.Name,
 		&department.Code,
 		&department.Description,
+		&parentDepartmentID,
 		&department.IsActive,
 		&department.CreatedAt,
 		&department.UpdatedAt,
@@ -83,12 +117,7 @@ func (r *departmentRepository) GetByID(ctx context.Context, id int) (*models.Dep
 		}
 		return nil, fmt.Errorf("error getting department: %w", err)
 	}
-
-	// Get user count for this department
-	if _, err := r.GetUserCount(ctx, department.ID); err == nil {
-		// Just log the error, don't fail the operation
-		fmt.Printf("Error getting user count: %v\n", err)
-	}
+	department.ParentDepartmentID = intPtr(parentDepartmentID)
 
 	return &department, nil
 }
@@ -99,9 +128,10 @@ func (r *departmentRepository) Update(ctx context.Context, department *models.De
         UPDATE departments
         SET name = @name,
             description = @description,
+            parent_department_id = @parent_department_id,
             is_active = @is_active,
             updated_at = @updated_at
-        WHERE id = @id
+        WHERE id = @id AND deleted_at IS NULL
     `
 
 	_, err := r.db.ExecContext(
@@ -109,6 +139,7 @@ func (r *departmentRepository) Update(ctx context.Context, department *models.De
 		query,
 		sql.Named("name", department.Name),
 		sql.Named("description", department.Description),
+		sql.Named("parent_department_id", nullIntPtr(department.ParentDepartmentID)),
 		sql.Named("is_active", department.IsActive),
 		sql.Named("updated_at", time.Now()),
 		sql.Named("id", department.ID),
@@ -121,44 +152,101 @@ func (r *departmentRepository) Update(ctx context.Context, department *models.De
 	return nil
 }
 
-// Delete deactivates a department
+// Delete soft deletes a department
 func (r *departmentRepository) Delete(ctx context.Context, id int) error {
 	query := `
         UPDATE departments
-        SET is_active = 0,
-            updated_at = @updated_at
-        WHERE id = @id
+        SET deleted_at = @now,
+            updated_at = @now
+        WHERE id = @id AND deleted_at IS NULL
     `
 
-	_, err := r.db.ExecContext(
-		ctx,
-		query,
-		sql.Named("updated_at", time.Now()),
-		sql.Named("id", id),
-	)
-
+	result, err := r.db.ExecContext(ctx, query, sql.Named("now", time.Now()), sql.Named("id", id))
 	if err != nil {
 		return fmt.Errorf("error deleting department: %w", err)
 	}
 
-	return nil
+	return checkAffected(result, "department")
+}
+
+// Restore brings a soft deleted department back
+func (r *departmentRepository) Restore(ctx context.Context, id int) error {
+	query := `
+        UPDATE departments
+        SET deleted_at = NULL,
+            updated_at = @now
+        WHERE id = @id AND deleted_at IS NOT NULL
+    `
+
+	result, err := r.db.ExecContext(ctx, query, sql.Named("now", time.Now()), sql.Named("id", id))
+	if err != nil {
+		return fmt.Errorf("error restoring department: %w", err)
+	}
+
+	return checkAffected(result, "deleted department")
+}
+
+// ListDeleted gets the soft deleted departments, most recently deleted first
+func (r *departmentRepository) ListDeleted(ctx context.Context) ([]*models.Department, error) {
+	query := `
+        SELECT id, name, code, description, is_active, created_at, updated_at, deleted_at
+        FROM departments
+        WHERE deleted_at IS NOT NULL
+        ORDER BY deleted_at DESC
+    `
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error listing deleted departments: %w", err)
+	}
+	defer rows.Close()
+
+	var departments []*models.Department
+	for rows.Next() {
+		var department models.Department
+		var deletedAt time.Time
+
+		err := rows.Scan(
+			&department.ID,
+			&department.Name,
+			&department.Code,
+			&department.Description,
+			&department.IsActive,
+			&department.CreatedAt,
+			&department.UpdatedAt,
+			&deletedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning department: %w", err)
+		}
+
+		department.DeletedAt = &deletedAt
+		departments = append(departments, &department)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating departments: %w", err)
+	}
+
+	return departments, nil
 }
 
 // List gets a list of departments
 func (r *departmentRepository) List(ctx context.Context, limit, offset int) ([]*models.Department, error) {
 	query := `
-        SELECT id, name, code, description, is_active, created_at, updated_at
+        SELECT id, name, code, description, parent_department_id, is_active, created_at, updated_at
         FROM (
             SELECT 
-                id, name, code, description, is_active, created_at, updated_at,
+                id, name, code, description, parent_department_id, is_active, created_at, updated_at,
                 ROW_NUMBER() OVER (ORDER BY name) AS RowNum
             FROM departments
+            WHERE deleted_at IS NULL
         ) AS DepartmentWithRowNumbers
         WHERE RowNum BETWEEN @offset + 1 AND @offset + @limit
         ORDER BY name
     `
 
-	rows, err := r.db.QueryContext(
+	rows, err := reader(ctx, r.db, r.reads).QueryContext(
 		ctx,
 		query,
 		sql.Named("limit", limit),
@@ -169,14 +257,39 @@ func (r *departmentRepository) List(ctx context.Context, limit, offset int) ([]*
 	}
 	defer rows.Close()
 
+	return scanDepartments(rows)
+}
+
+// ListAll gets every department that is not deleted, ordered by name, for building the tree
+func (r *departmentRepository) ListAll(ctx context.Context) ([]*models.Department, error) {
+	query := `
+        SELECT id, name, code, description, parent_department_id, is_active, created_at, updated_at
+        FROM departments
+        WHERE deleted_at IS NULL
+        ORDER BY name
+    `
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error listing departments: %w", err)
+	}
+	defer rows.Close()
+
+	return scanDepartments(rows)
+}
+
+// scanDepartments reads the departments selected by List and ListAll
+func scanDepartments(rows *sql.Rows) ([]*models.Department, error) {
 	var departments []*models.Department
 	for rows.Next() {
 		var department models.Department
+		var parentDepartmentID sql.NullInt64
 		err := rows.Scan(
 			&department.ID,
 			&department.Name,
 			&department.Code,
 			&department.Description,
+			&parentDepartmentID,
 			&department.IsActive,
 			&department.CreatedAt,
 			&department.UpdatedAt,
@@ -185,6 +298,53 @@ func (r *departmentRepository) List(ctx context.Context, limit, offset int) ([]*
 			return nil, fmt.Errorf("error scanning department: %w", err)
 		}
 
+		department.ParentDepartmentID = intPtr(parentDepartmentID)
+		departments = append(departments, &department)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating departments: %w", err)
+	}
+
+	return departments, nil
+}
+
+// Search finds departments whose name, code or description contains the text
+func (r *departmentRepository) Search(ctx context.Context, text string, limit int) ([]*models.Department, error) {
+	query := `
+        SELECT TOP (@limit) id, name, code, description, is_active
+        FROM departments
+        WHERE deleted_at IS NULL
+          AND (name LIKE @pattern ESCAPE '\'
+               OR code LIKE @pattern ESCAPE '\'
+               OR description LIKE @pattern ESCAPE '\')
+        ORDER BY name
+    `
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		query,
+		sql.Named("limit", clampSearchLimit(limit)),
+		sql.Named("pattern", containsPattern(text)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error searching departments: %w", err)
+	}
+	defer rows.Close()
+
+	var departments []*models.Department
+	for rows.Next() {
+		var department models.Department
+		err := rows.Scan(
+			&department.ID,
+			&department.Name,
+			&department.Code,
+			&department.Description,
+			&department.IsActive,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning department: %w", err)
+		}
 		departments = append(departments, &department)
 	}
 
@@ -198,7 +358,7 @@ func (r *departmentRepository) List(ctx context.Context, limit, offset int) ([]*
 // Count gets the total number of departments
 func (r *departmentRepository) Count(ctx context.Context) (int, error) {
 	var count int
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM departments").Scan(&count)
+	err := reader(ctx, r.db, r.reads).QueryRowContext(ctx, "SELECT COUNT(*) FROM departments WHERE deleted_at IS NULL").Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("error counting departments: %w", err)
 	}
@@ -210,7 +370,7 @@ func (r *departmentRepository) GetUserCount(ctx context.Context, departmentID in
 	query := `
         SELECT COUNT(*) 
         FROM users 
-        WHERE department_id = @department_id
+        WHERE department_id = @department_id AND deleted_at IS NULL
     `
 
 	var count int
@@ -221,3 +381,129 @@ func (r *departmentRepository) GetUserCount(ctx context.Context, departmentID in
 
 	return count, nil
 }
+
+// GetTotalUserCount gets the number of users in a department and every department below it
+func (r *departmentRepository) GetTotalUserCount(ctx context.Context, departmentID int) (int, error) {
+	query := departmentSubtreeCTE + `
+        SELECT COUNT(*)
+        FROM users u
+        JOIN subtree s ON u.department_id = s.id
+        WHERE u.deleted_at IS NULL
+    `
+
+	var count int
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		sql.Named("department_id", departmentID),
+		sql.Named("max_depth", maxDepartmentDepth),
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting users below department: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetUserCounts gets the number of users of every department that has any, by department ID
+func (r *departmentRepository) GetUserCounts(ctx context.Context) (map[int]int, error) {
+	query := `
+        SELECT department_id, COUNT(*)
+        FROM users
+        WHERE deleted_at IS NULL
+        GROUP BY department_id
+    `
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error counting users per department: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var departmentID, count int
+		if err := rows.Scan(&departmentID, &count); err != nil {
+			return nil, fmt.Errorf("error scanning department user count: %w", err)
+		}
+		counts[departmentID] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating department user counts: %w", err)
+	}
+
+	return counts, nil
+}
+
+// CountChildren gets the number of departments directly below a department
+func (r *departmentRepository) CountChildren(ctx context.Context, departmentID int) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(
+		ctx,
+		"SELECT COUNT(*) FROM departments WHERE parent_department_id = @department_id AND deleted_at IS NULL",
+		sql.Named("department_id", departmentID),
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting child departments: %w", err)
+	}
+	return count, nil
+}
+
+// GetAncestorIDs gets the IDs of the departments above a department, nearest first. Deleted
+// departments are included, since restoring them brings them back into the tree.
+func (r *departmentRepository) GetAncestorIDs(ctx context.Context, departmentID int) ([]int, error) {
+	query := `
+        WITH ancestors AS (
+            SELECT parent_department_id AS id, 1 AS depth
+            FROM departments
+            WHERE id = @department_id AND parent_department_id IS NOT NULL
+            UNION ALL
+            SELECT d.parent_department_id, a.depth + 1
+            FROM ancestors a
+            JOIN departments d ON d.id = a.id
+            WHERE d.parent_department_id IS NOT NULL AND a.depth < @max_depth
+        )
+        SELECT id FROM ancestors ORDER BY depth
+    `
+
+	return r.queryIDs(ctx, query, departmentID, "department ancestors")
+}
+
+// GetDescendantIDs gets the ID of a department followed by the IDs of every department below it
+func (r *departmentRepository) GetDescendantIDs(ctx context.Context, departmentID int) ([]int, error) {
+	query := departmentSubtreeCTE + `
+        SELECT id FROM subtree ORDER BY depth, id
+    `
+
+	return r.queryIDs(ctx, query, departmentID, "department descendants")
+}
+
+// queryIDs runs a department tree query and reads the IDs it selects
+func (r *departmentRepository) queryIDs(ctx context.Context, query string, departmentID int, what string) ([]int, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		query,
+		sql.Named("department_id", departmentID),
+		sql.Named("max_depth", maxDepartmentDepth),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting %s: %w", what, err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning %s: %w", what, err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating %s: %w", what, err)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,383 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ERPCacheRepository copies ERP report tables into local cache tables on the app database
+type ERPCacheRepository interface {
+	GetSyncStates(ctx context.Context) ([]*models.ERPSyncState, error)
+	GetSyncState(ctx context.Context, tableName string) (*models.ERPSyncState, error)
+	SaveSyncState(ctx context.Context, state *models.ERPSyncState) error
+	SyncSalesDeliveries(ctx context.Context, fromDate, toDate time.Time) (int, error)
+	SyncReceivables(ctx context.Context, fromDate, toDate time.Time) (int, error)
+}
+
+// Names of the sync groups tracked in erp_sync_state
+const (
+	SyncGroupSalesDeliveries = "sales_deliveries" // COPTG, COPTH, COPTD
+	SyncGroupReceivables     = "receivables"      // ACRTB, ACRTA
+)
+
+// cacheTableReplacer rewrites ERP table references in report queries to the local cache tables
+var cacheTableReplacer = strings.NewReplacer(
+	"COPTG WITH (NOLOCK)", "erp_cache_coptg AS COPTG WITH (NOLOCK)",
+	"COPTH WITH (NOLOCK)", "erp_cache_copth AS COPTH WITH (NOLOCK)",
+	"COPTD WITH (NOLOCK)", "erp_cache_coptd AS COPTD WITH (NOLOCK)",
+	"ACRTA WITH (NOLOCK)", "erp_cache_acrta AS ACRTA WITH (NOLOCK)",
+	"ACRTB WITH (NOLOCK)", "erp_cache_acrtb AS ACRTB WITH (NOLOCK)",
+)
+
+type erpCacheRepository struct {
+	db    *sql.DB
+	erpDB *sql.DB
+}
+
+// NewERPCacheRepository creates a new ERP cache repository
+func NewERPCacheRepository(db *sql.DB, erpDB *sql.DB) ERPCacheRepository {
+	return &erpCacheRepository{
+		db:    db,
+		erpDB: erpDB,
+	}
+}
+
+// GetSyncStates gets the sync state of all cache groups
+func (r *erpCacheRepository) GetSyncStates(ctx context.Context) ([]*models.ERPSyncState, error) {
+	query := `
+        SELECT table_name, synced_from, synced_to, row_count, last_run_at, status, ISNULL(error, '')
+        FROM erp_sync_state
+        ORDER BY table_name
+    `
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting sync states: %w", err)
+	}
+	defer rows.Close()
+
+	var states []*models.ERPSyncState
+	for rows.Next() {
+		state, err := scanSyncState(rows)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sync states: %w", err)
+	}
+
+	return states, nil
+}
+
+// GetSyncState gets the sync state of a cache group, or nil if it never ran
+func (r *erpCacheRepository) GetSyncState(ctx context.Context, tableName string) (*models.ERPSyncState, error) {
+	query := `
+        SELECT table_name, synced_from, synced_to, row_count, last_run_at, status, ISNULL(error, '')
+        FROM erp_sync_state
+        WHERE table_name = @table_name
+    `
+
+	state, err := scanSyncState(r.db.QueryRowContext(ctx, query, sql.Named("table_name", tableName)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// SaveSyncState inserts or updates the sync state of a cache group
+func (r *erpCacheRepository) SaveSyncState(ctx context.Context, state *models.ERPSyncState) error {
+	query := `
+        MERGE erp_sync_state AS target
+        USING (SELECT @table_name AS table_name) AS source
+        ON target.table_name = source.table_name
+        WHEN MATCHED THEN
+            UPDATE SET synced_from = @synced_from,
+                       synced_to = @synced_to,
+                       row_count = @row_count,
+                       last_run_at = @last_run_at,
+                       status = @status,
+                       error = @error
+        WHEN NOT MATCHED THEN
+            INSERT (table_name, synced_from, synced_to, row_count, last_run_at, status, error)
+            VALUES (@table_name, @synced_from, @synced_to, @row_count, @last_run_at, @status, @error);
+    `
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("table_name", state.TableName),
+		sql.Named("synced_from", nullTime(state.SyncedFrom)),
+		sql.Named("synced_to", nullTime(state.SyncedTo)),
+		sql.Named("row_count", state.RowCount),
+		sql.Named("last_run_at", state.LastRunAt),
+		sql.Named("status", state.Status),
+		sql.Named("error", state.Error),
+	)
+	if err != nil {
+		return fmt.Errorf("error saving sync state: %w", err)
+	}
+
+	return nil
+}
+
+// SyncSalesDeliveries replaces cached COPTG rows (and their COPTH/COPTD lines) in the date window
+func (r *erpCacheRepository) SyncSalesDeliveries(ctx context.Context, fromDate, toDate time.Time) (int, error) {
+	headers, err := r.fetch(ctx, `
+        SELECT TG001, TG002, TG004, TG005, TG006, TG007, TG011, TG013, TG020, TG023, TG025, TG042, TG045, TG046
+        FROM COPTG WITH (NOLOCK)
+        WHERE TG042 BETWEEN @FromDate AND @ToDate
+    `, 14, fromDate, toDate)
+	if err != nil {
+		return 0, fmt.Errorf("error reading COPTG: %w", err)
+	}
+
+	lines, err := r.fetch(ctx, `
+        SELECT COPTH.TH001, COPTH.TH002, COPTH.TH003, COPTH.TH014, COPTH.TH015, COPTH.TH016
+        FROM COPTH WITH (NOLOCK)
+        JOIN COPTG WITH (NOLOCK) ON COPTG.TG001 = COPTH.TH001 AND COPTG.TG002 = COPTH.TH002
+        WHERE COPTG.TG042 BETWEEN @FromDate AND @ToDate
+    `, 6, fromDate, toDate)
+	if err != nil {
+		return 0, fmt.Errorf("error reading COPTH: %w", err)
+	}
+
+	orders, err := r.fetch(ctx, `
+        SELECT DISTINCT COPTD.TD001, COPTD.TD002, COPTD.TD003
+        FROM COPTD WITH (NOLOCK)
+        JOIN COPTH WITH (NOLOCK) ON COPTD.TD001 = COPTH.TH014 AND COPTD.TD002 = COPTH.TH015 AND COPTD.TD003 = COPTH.TH016
+        JOIN COPTG WITH (NOLOCK) ON COPTG.TG001 = COPTH.TH001 AND COPTG.TG002 = COPTH.TH002
+        WHERE COPTG.TG042 BETWEEN @FromDate AND @ToDate
+    `, 3, fromDate, toDate)
+	if err != nil {
+		return 0, fmt.Errorf("error reading COPTD: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Drop the window first so rows voided or deleted in the ERP disappear from the cache too
+	_, err = tx.ExecContext(ctx, `
+        DELETE COPTH FROM erp_cache_copth COPTH
+        JOIN erp_cache_coptg COPTG ON COPTG.TG001 = COPTH.TH001 AND COPTG.TG002 = COPTH.TH002
+        WHERE COPTG.TG042 BETWEEN @FromDate AND @ToDate
+    `, sql.Named("FromDate", fromDate.Format("20060102")), sql.Named("ToDate", toDate.Format("20060102")))
+	if err != nil {
+		return 0, fmt.Errorf("error clearing cached COPTH: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        DELETE FROM erp_cache_coptg
+        WHERE TG042 BETWEEN @FromDate AND @ToDate
+    `, sql.Named("FromDate", fromDate.Format("20060102")), sql.Named("ToDate", toDate.Format("20060102")))
+	if err != nil {
+		return 0, fmt.Errorf("error clearing cached COPTG: %w", err)
+	}
+
+	if err := upsertRows(ctx, tx, "erp_cache_coptg",
+		[]string{"TG001", "TG002", "TG004", "TG005", "TG006", "TG007", "TG011", "TG013", "TG020", "TG023", "TG025", "TG042", "TG045", "TG046"},
+		[]string{"TG001", "TG002"}, headers); err != nil {
+		return 0, err
+	}
+	if err := upsertRows(ctx, tx, "erp_cache_copth",
+		[]string{"TH001", "TH002", "TH003", "TH014", "TH015", "TH016"},
+		[]string{"TH001", "TH002", "TH003"}, lines); err != nil {
+		return 0, err
+	}
+	if err := upsertRows(ctx, tx, "erp_cache_coptd",
+		[]string{"TD001", "TD002", "TD003"},
+		[]string{"TD001", "TD002", "TD003"}, orders); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return len(headers) + len(lines) + len(orders), nil
+}
+
+// SyncReceivables replaces cached ACRTB rows (and their ACRTA headers) in the date window
+func (r *erpCacheRepository) SyncReceivables(ctx context.Context, fromDate, toDate time.Time) (int, error) {
+	details, err := r.fetch(ctx, `
+        SELECT TB001, TB002, TB003, TB005, TB006, TB007, TB008
+        FROM ACRTB WITH (NOLOCK)
+        WHERE TB008 BETWEEN @FromDate AND @ToDate
+    `, 7, fromDate, toDate)
+	if err != nil {
+		return 0, fmt.Errorf("error reading ACRTB: %w", err)
+	}
+
+	headers, err := r.fetch(ctx, `
+        SELECT DISTINCT ACRTA.TA001, ACRTA.TA002, ACRTA.TA009, ACRTA.TA029, ACRTA.TA030,
+               ACRTA.TA036, ACRTA.TA041, ACRTA.TA042
+        FROM ACRTA WITH (NOLOCK)
+        JOIN ACRTB WITH (NOLOCK) ON ACRTA.TA001 = ACRTB.TB001 AND ACRTA.TA002 = ACRTB.TB002
+        WHERE ACRTB.TB008 BETWEEN @FromDate AND @ToDate
+    `, 8, fromDate, toDate)
+	if err != nil {
+		return 0, fmt.Errorf("error reading ACRTA: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+        DELETE FROM erp_cache_acrtb
+        WHERE TB008 BETWEEN @FromDate AND @ToDate
+    `, sql.Named("FromDate", fromDate.Format("20060102")), sql.Named("ToDate", toDate.Format("20060102")))
+	if err != nil {
+		return 0, fmt.Errorf("error clearing cached ACRTB: %w", err)
+	}
+
+	if err := upsertRows(ctx, tx, "erp_cache_acrtb",
+		[]string{"TB001", "TB002", "TB003", "TB005", "TB006", "TB007", "TB008"},
+		[]string{"TB001", "TB002", "TB003"}, details); err != nil {
+		return 0, err
+	}
+	if err := upsertRows(ctx, tx, "erp_cache_acrta",
+		[]string{"TA001", "TA002", "TA009", "TA029", "TA030", "TA036", "TA041", "TA042"},
+		[]string{"TA001", "TA002"}, headers); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return len(details) + len(headers), nil
+}
+
+// fetch reads rows from the ERP database as nullable strings
+func (r *erpCacheRepository) fetch(ctx context.Context, query string, columns int, fromDate, toDate time.Time) ([][]sql.NullString, error) {
+	rows, err := r.erpDB.QueryContext(
+		ctx,
+		query,
+		sql.Named("FromDate", fromDate),
+		sql.Named("ToDate", toDate),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result [][]sql.NullString
+	for rows.Next() {
+		values := make([]sql.NullString, columns)
+		dest := make([]interface{}, columns)
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		result = append(result, values)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return result, nil
+}
+
+// upsertRows merges rows into a cache table by primary key
+func upsertRows(ctx context.Context, tx *sql.Tx, table string, columns, keys []string, rows [][]sql.NullString) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	params := make([]string, len(columns))
+	updates := make([]string, 0, len(columns))
+	isKey := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		isKey[key] = true
+	}
+	for i, column := range columns {
+		params[i] = "@" + column
+		if !isKey[column] {
+			updates = append(updates, fmt.Sprintf("%s = @%s", column, column))
+		}
+	}
+	updates = append(updates, "synced_at = @synced_at")
+
+	conditions := make([]string, len(keys))
+	for i, key := range keys {
+		conditions[i] = fmt.Sprintf("%s = @%s", key, key)
+	}
+
+	query := fmt.Sprintf(`
+        UPDATE %[1]s SET %[2]s WHERE %[3]s;
+        IF @@ROWCOUNT = 0
+            INSERT INTO %[1]s (%[4]s, synced_at) VALUES (%[5]s, @synced_at);
+    `, table, strings.Join(updates, ", "), strings.Join(conditions, " AND "),
+		strings.Join(columns, ", "), strings.Join(params, ", "))
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("error preparing upsert for %s: %w", table, err)
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for _, row := range rows {
+		args := make([]interface{}, 0, len(columns)+1)
+		for i, column := range columns {
+			args = append(args, sql.Named(column, row[i]))
+		}
+		args = append(args, sql.Named("synced_at", now))
+
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return fmt.Errorf("error writing %s: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+// scanSyncState scans a row of erp_sync_state
+func scanSyncState(row interface{ Scan(...interface{}) error }) (*models.ERPSyncState, error) {
+	var state models.ERPSyncState
+	var syncedFrom, syncedTo, lastRunAt sql.NullTime
+
+	err := row.Scan(
+		&state.TableName,
+		&syncedFrom,
+		&syncedTo,
+		&state.RowCount,
+		&lastRunAt,
+		&state.Status,
+		&state.Error,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error scanning sync state: %w", err)
+	}
+
+	state.SyncedFrom = syncedFrom.Time
+	state.SyncedTo = syncedTo.Time
+	state.LastRunAt = lastRunAt.Time
+
+	return &state, nil
+}
+
+// nullTime converts a zero time to a SQL NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/database"
+	"fmt"
+)
+
+// ERPPool returns the ERP database of the company a request works on. database.Database
+// implements it with one connection pool per company.
+type ERPPool interface {
+	ERPDatabaseFor(ctx context.Context) (*sql.DB, error)
+}
+
+// cachePool serves the local ERP cache tables, which the ERP sync fills from one company
+type cachePool struct {
+	db      *sql.DB
+	company string
+}
+
+func (p cachePool) ERPDatabaseFor(ctx context.Context) (*sql.DB, error) {
+	if company := database.CompanyFromContext(ctx); company != "" && company != p.company {
+		return nil, fmt.Errorf("%w: the ERP cache holds company %s only, not %s", database.ErrUnknownCompany, p.company, company)
+	}
+	return p.db, nil
+}
+
+// reportQueryPool serves the custom SQL reports from the pools of their read-only login
+type reportQueryPool struct {
+	db database.Database
+}
+
+func (p reportQueryPool) ERPDatabaseFor(ctx context.Context) (*sql.DB, error) {
+	return p.db.ERPReportDatabaseFor(ctx)
+}
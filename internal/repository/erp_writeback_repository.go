@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"fmt"
+	"time"
+)
+
+// ERPDocumentState is the current write-back state of a COPTG document
+type ERPDocumentState struct {
+	DocType   string
+	DocNo     string
+	Confirmed string // TG023
+	Flag      string
+	Note      string
+}
+
+// ERPWriteBackRepository reads and updates the write-back columns of COPTG and keeps the audit trail
+type ERPWriteBackRepository interface {
+	GetDocuments(ctx context.Context, flagColumn, noteColumn string, keys [][2]string) (map[[2]string]*ERPDocumentState, error)
+	UpdateDocuments(ctx context.Context, flagColumn, noteColumn string, keys [][2]string, flag, note string) (int, error)
+	AddLogs(ctx context.Context, logs []*models.ERPWriteBackLog) error
+	SettleLogs(ctx context.Context, batchID, status, errorMessage string) error
+	ListLogs(ctx context.Context, limit int) ([]*models.ERPWriteBackLog, error)
+}
+
+type erpWriteBackRepository struct {
+	db  *sql.DB
+	erp ERPPool
+}
+
+// NewERPWriteBackRepository creates a new ERP write-back repository
+func NewERPWriteBackRepository(db *sql.DB, erp ERPPool) ERPWriteBackRepository {
+	return &erpWriteBackRepository{
+		db:  db,
+		erp: erp,
+	}
+}
+
+// keyFilter builds "(TG001 = @t0 AND TG002 = @n0) OR ..." for the given document keys
+func keyFilter(keys [][2]string) (string, []interface{}) {
+	filter := ""
+	params := make([]interface{}, 0, len(keys)*2)
+	for i, key := range keys {
+		if i > 0 {
+			filter += " OR "
+		}
+		filter += fmt.Sprintf("(TG001 = @t%d AND TG002 = @n%d)", i, i)
+		params = append(params, sql.Named(fmt.Sprintf("t%d", i), key[0]), sql.Named(fmt.Sprintf("n%d", i), key[1]))
+	}
+	return filter, params
+}
+
+// GetDocuments reads the current flag and note of the given documents.
+// Column names must already be validated by the caller, they cannot be passed as parameters.
+func (r *erpWriteBackRepository) GetDocuments(
+	ctx context.Context,
+	flagColumn, noteColumn string,
+	keys [][2]string,
+) (map[[2]string]*ERPDocumentState, error) {
+	result := make(map[[2]string]*ERPDocumentState, len(keys))
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	filter, params := keyFilter(keys)
+	query := fmt.Sprintf(`
+        SELECT RTRIM(TG001), RTRIM(TG002), ISNULL(TG023, ''), ISNULL(%s, ''), ISNULL(%s, '')
+        FROM COPTG WITH (NOLOCK)
+        WHERE %s
+    `, flagColumn, noteColumn, filter)
+
+	erpDB, err := r.erp.ERPDatabaseFor(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting ERP database: %w", err)
+	}
+	rows, err := erpDB.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("error reading ERP documents: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var state ERPDocumentState
+		if err := rows.Scan(&state.DocType, &state.DocNo, &state.Confirmed, &state.Flag, &state.Note); err != nil {
+			return nil, fmt.Errorf("error scanning ERP document: %w", err)
+		}
+		result[[2]string{state.DocType, state.DocNo}] = &state
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating ERP documents: %w", err)
+	}
+
+	return result, nil
+}
+
+// UpdateDocuments sets the flag and note on the given documents in a single transaction
+func (r *erpWriteBackRepository) UpdateDocuments(
+	ctx context.Context,
+	flagColumn, noteColumn string,
+	keys [][2]string,
+	flag, note string,
+) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	erpDB, err := r.erp.ERPDatabaseFor(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error selecting ERP database: %w", err)
+	}
+	tx, err := erpDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	filter, params := keyFilter(keys)
+	query := fmt.Sprintf(`
+        UPDATE COPTG
+        SET %s = @flag, %s = @note
+        WHERE %s
+    `, flagColumn, noteColumn, filter)
+	params = append(params, sql.Named("flag", flag), sql.Named("note", note))
+
+	result, err := tx.ExecContext(ctx, query, params...)
+	if err != nil {
+		return 0, fmt.Errorf("error updating ERP documents: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting affected rows: %w", err)
+	}
+
+	// Never touch more rows than were asked for
+	if int(affected) > len(keys) {
+		return 0, fmt.Errorf("update matched %d rows for %d documents, rolled back", affected, len(keys))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return int(affected), nil
+}
+
+// AddLogs writes audit records
+func (r *erpWriteBackRepository) AddLogs(ctx context.Context, logs []*models.ERPWriteBackLog) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO erp_writeback_log (batch_id, user_id, doc_type, doc_no, column_name, old_value, new_value, dry_run, status, error, created_at)
+        VALUES (@batch_id, @user_id, @doc_type, @doc_no, @column_name, @old_value, @new_value, @dry_run, @status, @error, @created_at)
+    `
+
+	now := time.Now()
+	for _, entry := range logs {
+		_, err := tx.ExecContext(
+			ctx,
+			query,
+			sql.Named("batch_id", entry.BatchID),
+			sql.Named("user_id", entry.UserID),
+			sql.Named("doc_type", entry.DocType),
+			sql.Named("doc_no", entry.DocNo),
+			sql.Named("column_name", entry.Column),
+			sql.Named("old_value", entry.OldValue),
+			sql.Named("new_value", entry.NewValue),
+			sql.Named("dry_run", entry.DryRun),
+			sql.Named("status", entry.Status),
+			sql.Named("error", entry.Error),
+			sql.Named("created_at", now),
+		)
+		if err != nil {
+			return fmt.Errorf("error writing write-back log: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// SettleLogs sets the outcome of the pending audit records of a batch once the ERP update is over
+func (r *erpWriteBackRepository) SettleLogs(ctx context.Context, batchID, status, errorMessage string) error {
+	query := `
+        UPDATE erp_writeback_log
+        SET status = @status, error = @error
+        WHERE batch_id = @batch_id AND status = 'pending'
+    `
+
+	if _, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("status", status),
+		sql.Named("error", errorMessage),
+		sql.Named("batch_id", batchID),
+	); err != nil {
+		return fmt.Errorf("error settling write-back logs: %w", err)
+	}
+	return nil
+}
+
+// ListLogs gets the most recent audit records
+func (r *erpWriteBackRepository) ListLogs(ctx context.Context, limit int) ([]*models.ERPWriteBackLog, error) {
+	query := `
+        SELECT TOP (@limit) id, batch_id, user_id, doc_type, doc_no, column_name,
+               ISNULL(old_value, ''), ISNULL(new_value, ''), dry_run, status, ISNULL(error, ''), created_at
+        FROM erp_writeback_log
+        ORDER BY id DESC
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, sql.Named("limit", limit))
+	if err != nil {
+		return nil, fmt.Errorf("error listing write-back logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*models.ERPWriteBackLog
+	for rows.Next() {
+		var entry models.ERPWriteBackLog
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.BatchID,
+			&entry.UserID,
+			&entry.DocType,
+			&entry.DocNo,
+			&entry.Column,
+			&entry.OldValue,
+			&entry.NewValue,
+			&entry.DryRun,
+			&entry.Status,
+			&entry.Error,
+			&entry.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning write-back log: %w", err)
+		}
+		logs = append(logs, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating write-back logs: %w", err)
+	}
+
+	return logs, nil
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"fmt"
+	"time"
+)
+
+// EventOutboxRepository stores domain events until they have been published
+type EventOutboxRepository interface {
+	Add(ctx context.Context, eventType, payload string) (int64, error)
+	ListPending(ctx context.Context, limit, maxAttempts int) ([]*models.OutboxEvent, error)
+	MarkPublished(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, errMsg string, maxAttempts int) error
+}
+
+type eventOutboxRepository struct {
+	db *sql.DB
+}
+
+// NewEventOutboxRepository creates a new event outbox repository
+func NewEventOutboxRepository(db *sql.DB) EventOutboxRepository {
+	return &eventOutboxRepository{
+		db: db,
+	}
+}
+
+// Add stores a new pending event
+func (r *eventOutboxRepository) Add(ctx context.Context, eventType, payload string) (int64, error) {
+	query := `
+        INSERT INTO event_outbox (event_type, payload, status, attempts, created_at)
+        OUTPUT INSERTED.id
+        VALUES (@event_type, @payload, 'pending', 0, @created_at)
+    `
+
+	var id int64
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		sql.Named("event_type", eventType),
+		sql.Named("payload", payload),
+		sql.Named("created_at", time.Now()),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error adding outbox event: %w", err)
+	}
+
+	return id, nil
+}
+
+// ListPending gets the oldest events that still have to be published
+func (r *eventOutboxRepository) ListPending(ctx context.Context, limit, maxAttempts int) ([]*models.OutboxEvent, error) {
+	query := `
+        SELECT TOP (@limit) id, event_type, payload, status, attempts, ISNULL(last_error, ''), created_at, published_at
+        FROM event_outbox
+        WHERE status = 'pending' AND attempts < @max_attempts
+        ORDER BY id
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, sql.Named("limit", limit), sql.Named("max_attempts", maxAttempts))
+	if err != nil {
+		return nil, fmt.Errorf("error listing outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*models.OutboxEvent
+	for rows.Next() {
+		var event models.OutboxEvent
+		var publishedAt sql.NullTime
+		if err := rows.Scan(
+			&event.ID,
+			&event.EventType,
+			&event.Payload,
+			&event.Status,
+			&event.Attempts,
+			&event.LastError,
+			&event.CreatedAt,
+			&publishedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning outbox event: %w", err)
+		}
+		if publishedAt.Valid {
+			event.PublishedAt = &publishedAt.Time
+		}
+		events = append(events, &event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbox events: %w", err)
+	}
+
+	return events, nil
+}
+
+// MarkPublished flags an event as delivered
+func (r *eventOutboxRepository) MarkPublished(ctx context.Context, id int64) error {
+	query := `
+        UPDATE event_outbox
+        SET status = 'published', published_at = @published_at, last_error = NULL
+        WHERE id = @id
+    `
+
+	if _, err := r.db.ExecContext(ctx, query, sql.Named("id", id), sql.Named("published_at", time.Now())); err != nil {
+		return fmt.Errorf("error marking outbox event published: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed records a failed attempt; the event is given up once it reaches maxAttempts
+func (r *eventOutboxRepository) MarkFailed(ctx context.Context, id int64, errMsg string, maxAttempts int) error {
+	query := `
+        UPDATE event_outbox
+        SET attempts = attempts + 1,
+            last_error = LEFT(@last_error, 1000),
+            status = CASE WHEN attempts + 1 >= @max_attempts THEN 'failed' ELSE 'pending' END
+        WHERE id = @id
+    `
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("id", id),
+		sql.Named("last_error", errMsg),
+		sql.Named("max_attempts", maxAttempts),
+	)
+	if err != nil {
+		return fmt.Errorf("error marking outbox event failed: %w", err)
+	}
+	return nil
+}
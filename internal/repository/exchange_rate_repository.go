@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"fmt"
+	"time"
+)
+
+// ExchangeRateRepository stores the exchange rates used to convert report totals
+type ExchangeRateRepository interface {
+	List(ctx context.Context, currencyCode string) ([]*models.ExchangeRate, error)
+	GetByID(ctx context.Context, id int) (*models.ExchangeRate, error)
+	Create(ctx context.Context, rate *models.ExchangeRate) (int, error)
+	Update(ctx context.Context, rate *models.ExchangeRate) error
+	Delete(ctx context.Context, id int) error
+}
+
+type exchangeRateRepository struct {
+	db *sql.DB
+}
+
+// NewExchangeRateRepository creates a new exchange rate repository
+func NewExchangeRateRepository(db *sql.DB) ExchangeRateRepository {
+	return &exchangeRateRepository{
+		db: db,
+	}
+}
+
+// List gets the exchange rates ordered by currency and effective date, optionally for one currency
+func (r *exchangeRateRepository) List(ctx context.Context, currencyCode string) ([]*models.ExchangeRate, error) {
+	query := `
+        SELECT id, currency_code, rate, effective_date, ISNULL(notes, ''), created_by, created_at, updated_at
+        FROM exchange_rates
+        WHERE @currency_code = '' OR currency_code = @currency_code
+        ORDER BY currency_code, effective_date
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, sql.Named("currency_code", currencyCode))
+	if err != nil {
+		return nil, fmt.Errorf("error getting exchange rates: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []*models.ExchangeRate
+	for rows.Next() {
+		rate, err := scanExchangeRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating exchange rates: %w", err)
+	}
+
+	return rates, nil
+}
+
+// GetByID gets an exchange rate by ID
+func (r *exchangeRateRepository) GetByID(ctx context.Context, id int) (*models.ExchangeRate, error) {
+	query := `
+        SELECT id, currency_code, rate, effective_date, ISNULL(notes, ''), created_by, created_at, updated_at
+        FROM exchange_rates
+        WHERE id = @id
+    `
+
+	return scanExchangeRate(r.db.QueryRowContext(ctx, query, sql.Named("id", id)))
+}
+
+// Create stores a new exchange rate
+func (r *exchangeRateRepository) Create(ctx context.Context, rate *models.ExchangeRate) (int, error) {
+	now := time.Now()
+	query := `
+        INSERT INTO exchange_rates (currency_code, rate, effective_date, notes, created_by, created_at, updated_at)
+        OUTPUT INSERTED.id
+        VALUES (@currency_code, @rate, @effective_date, @notes, @created_by, @now, @now)
+    `
+
+	var id int
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		sql.Named("currency_code", rate.CurrencyCode),
+		sql.Named("rate", rate.Rate),
+		sql.Named("effective_date", rate.EffectiveDate),
+		sql.Named("notes", rate.Notes),
+		sql.Named("created_by", rate.CreatedBy),
+		sql.Named("now", now),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error creating exchange rate: %w", err)
+	}
+
+	rate.ID = id
+	rate.CreatedAt = now
+	rate.UpdatedAt = now
+	return id, nil
+}
+
+// Update changes an existing exchange rate
+func (r *exchangeRateRepository) Update(ctx context.Context, rate *models.ExchangeRate) error {
+	now := time.Now()
+	query := `
+        UPDATE exchange_rates
+        SET currency_code = @currency_code, rate = @rate, effective_date = @effective_date,
+            notes = @notes, updated_at = @now
+        WHERE id = @id
+    `
+
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("id", rate.ID),
+		sql.Named("currency_code", rate.CurrencyCode),
+		sql.Named("rate", rate.Rate),
+		sql.Named("effective_date", rate.EffectiveDate),
+		sql.Named("notes", rate.Notes),
+		sql.Named("now", now),
+	)
+	if err != nil {
+		return fmt.Errorf("error updating exchange rate: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("exchange rate not found: %w", sql.ErrNoRows)
+	}
+
+	rate.UpdatedAt = now
+	return nil
+}
+
+// Delete removes an exchange rate
+func (r *exchangeRateRepository) Delete(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM exchange_rates WHERE id = @id`, sql.Named("id", id))
+	if err != nil {
+		return fmt.Errorf("error deleting exchange rate: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("exchange rate not found: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// scanExchangeRate scans one exchange rate row
+func scanExchangeRate(row rowScanner) (*models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	err := row.Scan(
+		&rate.ID,
+		&rate.CurrencyCode,
+		&rate.Rate,
+		&rate.EffectiveDate,
+		&rate.Notes,
+		&rate.CreatedBy,
+		&rate.CreatedAt,
+		&rate.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("exchange rate not found: %w", err)
+		}
+		return nil, fmt.Errorf("error scanning exchange rate: %w", err)
+	}
+	return &rate, nil
+}
@@ -0,0 +1,320 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"fmt"
+	"time"
+)
+
+// ExportJobRepository stores the queue of background exports
+type ExportJobRepository interface {
+	Create(ctx context.Context, job *models.ExportJob) (int, error)
+	GetByID(ctx context.Context, id int) (*models.ExportJob, error)
+	ListByUser(ctx context.Context, userID int, limit int) ([]*models.ExportJob, error)
+	ListPendingApproval(ctx context.Context, departmentIDs []int, limit int) ([]*models.ExportJob, error)
+	Decide(ctx context.Context, id int, approved bool, decidedBy int, note string) error
+	ClaimNext(ctx context.Context) (*models.ExportJob, error)
+	Complete(ctx context.Context, id int, fileName string) error
+	Fail(ctx context.Context, id int, errMsg string) error
+	FailStale(ctx context.Context, startedBefore time.Time) (int64, error)
+}
+
+type exportJobRepository struct {
+	db *sql.DB
+}
+
+// NewExportJobRepository creates a new export job repository
+func NewExportJobRepository(db *sql.DB) ExportJobRepository {
+	return &exportJobRepository{
+		db: db,
+	}
+}
+
+const exportJobColumns = `id, report, parameters, status, user_id, department_id, ISNULL(file_name, ''), ISNULL(error, ''), created_at, started_at, finished_at, decided_by, decided_at, ISNULL(decision_note, '')`
+
+// Create queues a new job, or holds it for approval when its status is pending_approval
+func (r *exportJobRepository) Create(ctx context.Context, job *models.ExportJob) (int, error) {
+	now := time.Now()
+	if job.Status == "" {
+		job.Status = "queued"
+	}
+	query := `
+        INSERT INTO export_jobs (report, parameters, status, user_id, department_id, created_at)
+        OUTPUT INSERTED.id
+        VALUES (@report, @parameters, @status, @user_id, @department_id, @created_at)
+    `
+
+	var id int
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		sql.Named("report", job.Report),
+		sql.Named("parameters", job.Parameters),
+		sql.Named("status", job.Status),
+		sql.Named("user_id", job.UserID),
+		sql.Named("department_id", job.DepartmentID),
+		sql.Named("created_at", now),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error creating export job: %w", err)
+	}
+
+	job.ID = id
+	job.CreatedAt = now
+	return id, nil
+}
+
+// GetByID gets a job by ID
+func (r *exportJobRepository) GetByID(ctx context.Context, id int) (*models.ExportJob, error) {
+	query := `SELECT ` + exportJobColumns + ` FROM export_jobs WHERE id = @id`
+
+	job, err := scanExportJob(r.db.QueryRowContext(ctx, query, sql.Named("id", id)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("export job not found: %w", err)
+		}
+		return nil, fmt.Errorf("error getting export job: %w", err)
+	}
+
+	return job, nil
+}
+
+// ListByUser gets the newest jobs of a user
+func (r *exportJobRepository) ListByUser(ctx context.Context, userID int, limit int) ([]*models.ExportJob, error) {
+	query := `
+        SELECT TOP (@limit) ` + exportJobColumns + `
+        FROM export_jobs
+        WHERE user_id = @user_id
+        ORDER BY created_at DESC, id DESC
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, sql.Named("limit", limit), sql.Named("user_id", userID))
+	if err != nil {
+		return nil, fmt.Errorf("error listing export jobs: %w", err)
+	}
+	defer rows.Close()
+
+	return scanExportJobs(rows)
+}
+
+// ListPendingApproval gets the oldest jobs waiting for approval of users in the departments, or
+// of every user when departmentIDs is nil
+func (r *exportJobRepository) ListPendingApproval(ctx context.Context, departmentIDs []int, limit int) ([]*models.ExportJob, error) {
+	query := `
+        SELECT TOP (@limit) ` + exportJobColumns + `
+        FROM export_jobs
+        WHERE status = 'pending_approval'
+    `
+	params := []interface{}{sql.Named("limit", limit)}
+
+	if departmentIDs != nil {
+		if len(departmentIDs) == 0 {
+			return []*models.ExportJob{}, nil
+		}
+		query += " AND department_id IN ("
+		for i, departmentID := range departmentIDs {
+			if i > 0 {
+				query += ", "
+			}
+			paramName := fmt.Sprintf("department_id_%d", i)
+			query += "@" + paramName
+			params = append(params, sql.Named(paramName, departmentID))
+		}
+		query += ")"
+	}
+	query += " ORDER BY created_at, id"
+
+	rows, err := r.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing export jobs pending approval: %w", err)
+	}
+	defer rows.Close()
+
+	return scanExportJobs(rows)
+}
+
+// Decide queues a job waiting for approval, or rejects it
+func (r *exportJobRepository) Decide(ctx context.Context, id int, approved bool, decidedBy int, note string) error {
+	status := "rejected"
+	if approved {
+		status = "queued"
+	}
+
+	query := `
+        UPDATE export_jobs
+        SET status = @status, decided_by = @decided_by, decided_at = @decided_at,
+            decision_note = NULLIF(LEFT(@note, 500), ''),
+            finished_at = CASE WHEN @status = 'rejected' THEN @decided_at END
+        WHERE id = @id AND status = 'pending_approval'
+    `
+
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("id", id),
+		sql.Named("status", status),
+		sql.Named("decided_by", decidedBy),
+		sql.Named("decided_at", time.Now()),
+		sql.Named("note", note),
+	)
+	if err != nil {
+		return fmt.Errorf("error deciding export job: %w", err)
+	}
+
+	return checkAffected(result, "pending export job")
+}
+
+// scanExportJobs reads the rows of a job listing
+func scanExportJobs(rows *sql.Rows) ([]*models.ExportJob, error) {
+	jobs := []*models.ExportJob{}
+	for rows.Next() {
+		job, err := scanExportJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning export job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating export jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// ClaimNext marks the oldest queued job as running and returns it, or nil when the queue is empty.
+// READPAST lets several workers and instances claim jobs without picking the same one.
+func (r *exportJobRepository) ClaimNext(ctx context.Context) (*models.ExportJob, error) {
+	query := `
+        WITH next_job AS (
+            SELECT TOP (1) *
+            FROM export_jobs WITH (ROWLOCK, UPDLOCK, READPAST)
+            WHERE status = 'queued'
+            ORDER BY id
+        )
+        UPDATE next_job
+        SET status = 'running', started_at = @started_at
+        OUTPUT INSERTED.id, INSERTED.report, INSERTED.parameters, INSERTED.status, INSERTED.user_id,
+            INSERTED.department_id, ISNULL(INSERTED.file_name, ''), ISNULL(INSERTED.error, ''),
+            INSERTED.created_at, INSERTED.started_at, INSERTED.finished_at,
+            INSERTED.decided_by, INSERTED.decided_at, ISNULL(INSERTED.decision_note, '')
+    `
+
+	job, err := scanExportJob(r.db.QueryRowContext(ctx, query, sql.Named("started_at", time.Now())))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error claiming export job: %w", err)
+	}
+
+	return job, nil
+}
+
+// Complete records the file generated by a job
+func (r *exportJobRepository) Complete(ctx context.Context, id int, fileName string) error {
+	query := `
+        UPDATE export_jobs
+        SET status = 'completed', file_name = @file_name, error = NULL, finished_at = @finished_at
+        WHERE id = @id
+    `
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("id", id),
+		sql.Named("file_name", fileName),
+		sql.Named("finished_at", time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("error completing export job: %w", err)
+	}
+	return nil
+}
+
+// Fail records why a job did not produce a file
+func (r *exportJobRepository) Fail(ctx context.Context, id int, errMsg string) error {
+	query := `
+        UPDATE export_jobs
+        SET status = 'failed', error = LEFT(@error, 1000), finished_at = @finished_at
+        WHERE id = @id
+    `
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("id", id),
+		sql.Named("error", errMsg),
+		sql.Named("finished_at", time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("error failing export job: %w", err)
+	}
+	return nil
+}
+
+// FailStale fails the jobs still running since before a time, left behind by a stopped instance
+func (r *exportJobRepository) FailStale(ctx context.Context, startedBefore time.Time) (int64, error) {
+	query := `
+        UPDATE export_jobs
+        SET status = 'failed', error = 'the export was interrupted', finished_at = @finished_at
+        WHERE status = 'running' AND started_at < @started_before
+    `
+
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("started_before", startedBefore),
+		sql.Named("finished_at", time.Now()),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error failing stale export jobs: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting affected rows: %w", err)
+	}
+
+	return affected, nil
+}
+
+// scanExportJob scans one row selected with exportJobColumns
+func scanExportJob(row rowScanner) (*models.ExportJob, error) {
+	var job models.ExportJob
+	var startedAt, finishedAt, decidedAt sql.NullTime
+	var decidedBy sql.NullInt64
+	if err := row.Scan(
+		&job.ID,
+		&job.Report,
+		&job.Parameters,
+		&job.Status,
+		&job.UserID,
+		&job.DepartmentID,
+		&job.FileName,
+		&job.Error,
+		&job.CreatedAt,
+		&startedAt,
+		&finishedAt,
+		&decidedBy,
+		&decidedAt,
+		&job.DecisionNote,
+	); err != nil {
+		return nil, err
+	}
+
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	job.DecidedBy = intPtr(decidedBy)
+	if decidedAt.Valid {
+		job.DecidedAt = &decidedAt.Time
+	}
+
+	return &job, nil
+}
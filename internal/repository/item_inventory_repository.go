@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/dto"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ItemInventoryRepository reads item stock movements from the ERP inventory ledger
+type ItemInventoryRepository interface {
+	GetItemInventory(
+		ctx context.Context,
+		fromDate time.Time,
+		toDate time.Time,
+		itemCode string,
+		warehouseCode string,
+	) ([]dto.ItemInventoryItem, error)
+}
+
+type itemInventoryRepository struct {
+	erp    ERPPool
+	logger *slog.Logger
+}
+
+// NewItemInventoryRepository creates a new item inventory repository. The ERP cache does not
+// copy the inventory ledger, so this always reads the ERP server.
+func NewItemInventoryRepository(erp ERPPool, logger *slog.Logger) ItemInventoryRepository {
+	return &itemInventoryRepository{
+		erp:    erp,
+		logger: logger,
+	}
+}
+
+// GetItemInventory returns opening balance, receipts, issues and closing balance per item and
+// warehouse. INVLA holds one row per stock movement: LA005 is 1 for receipts and -1 for issues.
+func (r *itemInventoryRepository) GetItemInventory(
+	ctx context.Context,
+	fromDate time.Time,
+	toDate time.Time,
+	itemCode string,
+	warehouseCode string,
+) ([]dto.ItemInventoryItem, error) {
+	r.logger.DebugContext(ctx, "Querying item inventory",
+		"from_date", fromDate, "to_date", toDate, "item_code", itemCode, "warehouse_code", warehouseCode)
+	erpDB, err := r.erp.ERPDatabaseFor(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting ERP database: %w", err)
+	}
+
+	query := `
+	SELECT
+    INVLA.LA001 AS item_code,
+    ISNULL(INVMB.MB002, '') AS item_name,
+    ISNULL(INVMB.MB004, '') AS unit,
+    INVLA.LA009 AS warehouse_code,
+    ISNULL(CMSMC.MC002, '') AS warehouse_name,
+    SUM(CASE WHEN INVLA.LA004 < @FromDate THEN INVLA.LA005 * INVLA.LA011 ELSE 0 END) AS opening_qty,
+    SUM(CASE WHEN INVLA.LA004 >= @FromDate AND INVLA.LA005 = 1 THEN INVLA.LA011 ELSE 0 END) AS qty_in,
+    SUM(CASE WHEN INVLA.LA004 >= @FromDate AND INVLA.LA005 = -1 THEN INVLA.LA011 ELSE 0 END) AS qty_out,
+    SUM(INVLA.LA005 * INVLA.LA011) AS balance_qty
+FROM
+    INVLA WITH (NOLOCK)
+LEFT JOIN
+    INVMB WITH (NOLOCK) ON INVMB.MB001 = INVLA.LA001
+LEFT JOIN
+    CMSMC WITH (NOLOCK) ON CMSMC.MC001 = INVLA.LA009
+WHERE INVLA.LA004 <= @ToDate
+    AND (@ItemCode = '' OR INVLA.LA001 = @ItemCode)
+    AND (@WarehouseCode = '' OR INVLA.LA009 = @WarehouseCode)
+GROUP BY
+    INVLA.LA001, INVMB.MB002, INVMB.MB004, INVLA.LA009, CMSMC.MC002
+HAVING
+    SUM(CASE WHEN INVLA.LA004 < @FromDate THEN INVLA.LA005 * INVLA.LA011 ELSE 0 END) <> 0
+    OR SUM(CASE WHEN INVLA.LA004 >= @FromDate THEN 1 ELSE 0 END) > 0
+ORDER BY
+    INVLA.LA001, INVLA.LA009
+	`
+
+	// LA004 is stored as YYYYMMDD text
+	rows, err := erpDB.QueryContext(
+		ctx,
+		query,
+		sql.Named("FromDate", fromDate.Format("20060102")),
+		sql.Named("ToDate", toDate.Format("20060102")),
+		sql.Named("ItemCode", itemCode),
+		sql.Named("WarehouseCode", warehouseCode),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying item inventory: %w", err)
+	}
+	defer rows.Close()
+
+	var items []dto.ItemInventoryItem
+	for rows.Next() {
+		var item dto.ItemInventoryItem
+		if err := rows.Scan(
+			&item.ItemCode,
+			&item.ItemName,
+			&item.Unit,
+			&item.WarehouseCode,
+			&item.WarehouseName,
+			&item.OpeningQty,
+			&item.QtyIn,
+			&item.QtyOut,
+			&item.BalanceQty,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning item inventory: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating item inventory: %w", err)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// JobFilter narrows the job list
+type JobFilter struct {
+	Status string
+	Type   string
+	Limit  int
+}
+
+// JobRepository stores the queue of the background job engine
+type JobRepository interface {
+	Create(ctx context.Context, job *models.Job) (int64, error)
+	GetByID(ctx context.Context, id int64) (*models.Job, error)
+	List(ctx context.Context, filter JobFilter) ([]*models.Job, error)
+	Claim(ctx context.Context, types []string, lease time.Duration) (*models.Job, error)
+	Complete(ctx context.Context, id int64) error
+	Fail(ctx context.Context, id int64, errMsg string, runAt *time.Time) error
+	Retry(ctx context.Context, id int64) (bool, error)
+	Cancel(ctx context.Context, id int64) (bool, error)
+	DeleteFinishedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type jobRepository struct {
+	db *sql.DB
+}
+
+// NewJobRepository creates a new job repository
+func NewJobRepository(db *sql.DB) JobRepository {
+	return &jobRepository{
+		db: db,
+	}
+}
+
+const jobColumns = `id, type, payload, status, attempts, max_attempts, ISNULL(last_error, ''), run_at,
+    ISNULL(created_by, 0), created_at, started_at, finished_at`
+
+// Create queues a job, due at its RunAt or at once when it has none
+func (r *jobRepository) Create(ctx context.Context, job *models.Job) (int64, error) {
+	now := time.Now()
+	if job.RunAt.IsZero() {
+		job.RunAt = now
+	}
+	query := `
+        INSERT INTO jobs (type, payload, status, attempts, max_attempts, run_at, created_by, created_at)
+        OUTPUT INSERTED.id
+        VALUES (@type, @payload, 'pending', 0, @max_attempts, @run_at, NULLIF(@created_by, 0), @now)
+    `
+
+	var id int64
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		sql.Named("type", job.Type),
+		sql.Named("payload", job.Payload),
+		sql.Named("max_attempts", job.MaxAttempts),
+		sql.Named("run_at", job.RunAt),
+		sql.Named("created_by", job.CreatedBy),
+		sql.Named("now", now),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error creating job: %w", err)
+	}
+
+	job.ID = id
+	job.Status = models.JobPending
+	job.CreatedAt = now
+	return id, nil
+}
+
+// GetByID gets a job by ID
+func (r *jobRepository) GetByID(ctx context.Context, id int64) (*models.Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE id = @id`
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, sql.Named("id", id)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("job not found: %w", err)
+		}
+		return nil, fmt.Errorf("error getting job: %w", err)
+	}
+	return job, nil
+}
+
+// List gets the newest jobs matching the filter
+func (r *jobRepository) List(ctx context.Context, filter JobFilter) ([]*models.Job, error) {
+	query := `
+        SELECT TOP (@limit) ` + jobColumns + `
+        FROM jobs
+        WHERE (@status = '' OR status = @status)
+          AND (@type = '' OR type = @type)
+        ORDER BY id DESC
+    `
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		query,
+		sql.Named("limit", filter.Limit),
+		sql.Named("status", filter.Status),
+		sql.Named("type", filter.Type),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error listing jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []*models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// Claim takes the oldest due job of one of the types, marks it running and counts the attempt, or
+// returns nil when none is due. A running job whose lease ended belonged to a worker that stopped
+// mid-run and is claimed again. READPAST lets several workers and instances claim jobs without
+// picking the same one.
+func (r *jobRepository) Claim(ctx context.Context, types []string, lease time.Duration) (*models.Job, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, len(types))
+	args := make([]interface{}, 0, len(types)+2)
+	for i, jobType := range types {
+		names[i] = fmt.Sprintf("@type%d", i)
+		args = append(args, sql.Named(fmt.Sprintf("type%d", i), jobType))
+	}
+
+	now := time.Now()
+	query := `
+        WITH next_job AS (
+            SELECT TOP (1) *
+            FROM jobs WITH (ROWLOCK, UPDLOCK, READPAST)
+            WHERE type IN (` + strings.Join(names, ", ") + `)
+              AND ((status = 'pending' AND run_at <= @now) OR (status = 'running' AND locked_until < @now))
+            ORDER BY run_at, id
+        )
+        UPDATE next_job
+        SET status = 'running', attempts = attempts + 1, started_at = @now, locked_until = @lease_until
+        OUTPUT INSERTED.id, INSERTED.type, INSERTED.payload, INSERTED.status, INSERTED.attempts,
+            INSERTED.max_attempts, ISNULL(INSERTED.last_error, ''), INSERTED.run_at,
+            ISNULL(INSERTED.created_by, 0), INSERTED.created_at, INSERTED.started_at, INSERTED.finished_at
+    `
+	args = append(args, sql.Named("now", now), sql.Named("lease_until", now.Add(lease)))
+
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error claiming job: %w", err)
+	}
+
+	return job, nil
+}
+
+// Complete records a job that succeeded. A job cancelled while it ran stays cancelled.
+func (r *jobRepository) Complete(ctx context.Context, id int64) error {
+	query := `
+        UPDATE jobs
+        SET status = 'completed', last_error = NULL, locked_until = NULL, finished_at = @now
+        WHERE id = @id AND status = 'running'
+    `
+
+	if _, err := r.db.ExecContext(ctx, query, sql.Named("id", id), sql.Named("now", time.Now())); err != nil {
+		return fmt.Errorf("error completing job: %w", err)
+	}
+	return nil
+}
+
+// Fail records a failed attempt, retried at runAt or given up when it is nil. A job cancelled
+// while it ran stays cancelled.
+func (r *jobRepository) Fail(ctx context.Context, id int64, errMsg string, runAt *time.Time) error {
+	query := `
+        UPDATE jobs
+        SET status = CASE WHEN @run_at IS NULL THEN 'failed' ELSE 'pending' END,
+            last_error = LEFT(@last_error, 1000),
+            run_at = ISNULL(@run_at, run_at),
+            locked_until = NULL,
+            finished_at = CASE WHEN @run_at IS NULL THEN @now ELSE NULL END
+        WHERE id = @id AND status = 'running'
+    `
+
+	_, err := r.db.ExecContext(ctx, query,
+		sql.Named("id", id),
+		sql.Named("last_error", errMsg),
+		sql.Named("run_at", nullTimePtr(runAt)),
+		sql.Named("now", time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("error failing job: %w", err)
+	}
+	return nil
+}
+
+// Retry queues a failed or cancelled job again with a fresh count of attempts, due at once. It
+// reports whether the job was in one of those states.
+func (r *jobRepository) Retry(ctx context.Context, id int64) (bool, error) {
+	query := `
+        UPDATE jobs
+        SET status = 'pending', attempts = 0, run_at = @now, locked_until = NULL, finished_at = NULL
+        WHERE id = @id AND status IN ('failed', 'cancelled')
+    `
+
+	result, err := r.db.ExecContext(ctx, query, sql.Named("id", id), sql.Named("now", time.Now()))
+	if err != nil {
+		return false, fmt.Errorf("error retrying job: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// Cancel cancels a pending or running job and reports whether it was in one of those states
+func (r *jobRepository) Cancel(ctx context.Context, id int64) (bool, error) {
+	query := `
+        UPDATE jobs
+        SET status = 'cancelled', locked_until = NULL, finished_at = @now
+        WHERE id = @id AND status IN ('pending', 'running')
+    `
+
+	result, err := r.db.ExecContext(ctx, query, sql.Named("id", id), sql.Named("now", time.Now()))
+	if err != nil {
+		return false, fmt.Errorf("error cancelling job: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// DeleteFinishedBefore removes the completed, failed and cancelled jobs that finished before a time
+func (r *jobRepository) DeleteFinishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx,
+		`DELETE FROM jobs WHERE status IN ('completed', 'failed', 'cancelled') AND finished_at < @before`,
+		sql.Named("before", before),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting jobs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// scanJob scans one job row
+func scanJob(row rowScanner) (*models.Job, error) {
+	var job models.Job
+	var startedAt, finishedAt sql.NullTime
+	err := row.Scan(
+		&job.ID,
+		&job.Type,
+		&job.Payload,
+		&job.Status,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.LastError,
+		&job.RunAt,
+		&job.CreatedBy,
+		&job.CreatedAt,
+		&startedAt,
+		&finishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"fmt"
+	"time"
+)
+
+// NotificationRepository stores the in-app notifications of users
+type NotificationRepository interface {
+	Create(ctx context.Context, notification *models.Notification) (int, error)
+	List(ctx context.Context, userID int, unreadOnly bool, limit, offset int) ([]*models.Notification, error)
+	Count(ctx context.Context, userID int, unreadOnly bool) (int, error)
+	MarkRead(ctx context.Context, userID int, id int) error
+	MarkAllRead(ctx context.Context, userID int) (int, error)
+	ExistsSince(ctx context.Context, userID int, notificationType string, since time.Time) (bool, error)
+}
+
+type notificationRepository struct {
+	db *sql.DB
+}
+
+// NewNotificationRepository creates a new notification repository
+func NewNotificationRepository(db *sql.DB) NotificationRepository {
+	return &notificationRepository{
+		db: db,
+	}
+}
+
+const notificationColumns = `id, user_id, type, title, message, ISNULL(link, ''), read_at, created_at`
+
+// Create stores a new unread notification
+func (r *notificationRepository) Create(ctx context.Context, notification *models.Notification) (int, error) {
+	now := time.Now()
+	query := `
+        INSERT INTO notifications (user_id, type, title, message, link, created_at)
+        OUTPUT INSERTED.id
+        VALUES (@user_id, @type, @title, @message, NULLIF(@link, ''), @created_at)
+    `
+
+	var id int
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		sql.Named("user_id", notification.UserID),
+		sql.Named("type", notification.Type),
+		sql.Named("title", notification.Title),
+		sql.Named("message", notification.Message),
+		sql.Named("link", notification.Link),
+		sql.Named("created_at", now),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error creating notification: %w", err)
+	}
+
+	notification.ID = id
+	notification.CreatedAt = now
+	return id, nil
+}
+
+// List gets a page of the user's notifications, newest first
+func (r *notificationRepository) List(ctx context.Context, userID int, unreadOnly bool, limit, offset int) ([]*models.Notification, error) {
+	query := `
+        SELECT ` + notificationColumns + `
+        FROM notifications
+        WHERE user_id = @user_id AND (@unread_only = 0 OR read_at IS NULL)
+        ORDER BY created_at DESC, id DESC
+        OFFSET @offset ROWS
+        FETCH NEXT @limit ROWS ONLY
+    `
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		query,
+		sql.Named("user_id", userID),
+		sql.Named("unread_only", unreadOnly),
+		sql.Named("limit", limit),
+		sql.Named("offset", offset),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error listing notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []*models.Notification
+	for rows.Next() {
+		var notification models.Notification
+		var readAt sql.NullTime
+		err := rows.Scan(
+			&notification.ID,
+			&notification.UserID,
+			&notification.Type,
+			&notification.Title,
+			&notification.Message,
+			&notification.Link,
+			&readAt,
+			&notification.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning notification: %w", err)
+		}
+		if readAt.Valid {
+			notification.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, &notification)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating notifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+// Count gets the number of the user's notifications, or of the unread ones
+func (r *notificationRepository) Count(ctx context.Context, userID int, unreadOnly bool) (int, error) {
+	query := `
+        SELECT COUNT(*)
+        FROM notifications
+        WHERE user_id = @user_id AND (@unread_only = 0 OR read_at IS NULL)
+    `
+
+	var count int
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		sql.Named("user_id", userID),
+		sql.Named("unread_only", unreadOnly),
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting notifications: %w", err)
+	}
+	return count, nil
+}
+
+// MarkRead marks a notification of the user as read; reading it again keeps the first time
+func (r *notificationRepository) MarkRead(ctx context.Context, userID int, id int) error {
+	query := `
+        UPDATE notifications
+        SET read_at = ISNULL(read_at, @read_at)
+        WHERE id = @id AND user_id = @user_id
+    `
+
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("read_at", time.Now()),
+		sql.Named("id", id),
+		sql.Named("user_id", userID),
+	)
+	if err != nil {
+		return fmt.Errorf("error marking notification read: %w", err)
+	}
+	return checkAffected(result, "notification")
+}
+
+// MarkAllRead marks every unread notification of the user as read and returns how many there were
+func (r *notificationRepository) MarkAllRead(ctx context.Context, userID int) (int, error) {
+	query := `
+        UPDATE notifications
+        SET read_at = @read_at
+        WHERE user_id = @user_id AND read_at IS NULL
+    `
+
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("read_at", time.Now()),
+		sql.Named("user_id", userID),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error marking notifications read: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error reading affected rows: %w", err)
+	}
+	return int(affected), nil
+}
+
+// ExistsSince reports whether the user got a notification of the type at or after since
+func (r *notificationRepository) ExistsSince(ctx context.Context, userID int, notificationType string, since time.Time) (bool, error) {
+	query := `
+        SELECT CASE WHEN EXISTS (
+            SELECT 1 FROM notifications
+            WHERE user_id = @user_id AND type = @type AND created_at >= @since
+        ) THEN 1 ELSE 0 END
+    `
+
+	var exists bool
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		sql.Named("user_id", userID),
+		sql.Named("type", notificationType),
+		sql.Named("since", since),
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("error checking notifications: %w", err)
+	}
+	return exists, nil
+}
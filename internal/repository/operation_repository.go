@@ -3,9 +3,11 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"erp-excel/database"
 	"erp-excel/internal/dto"
 	"erp-excel/internal/models"
 	"fmt"
+	"time"
 )
 
 // OperationRepository interface
@@ -16,6 +18,8 @@ type OperationRepository interface {
 	LogAccess(ctx context.Context, log *models.AccessLog) (int, error)
 	UpdateLogStatus(ctx context.Context, logID int, status string) (bool, error)
 	GetRecentLogs(ctx context.Context, limit int) ([]*models.AccessLog, error)
+	GetUserLogs(ctx context.Context, userID int, since time.Time, limit int) ([]*models.AccessLog, error)
+	EnsureOperations(ctx context.Context, operations []*models.Operation) error
 }
 
 type operationRepository struct {
@@ -29,6 +33,15 @@ func NewOperationRepository(db *sql.DB) OperationRepository {
 	}
 }
 
+// accessLogCompany returns the ERP company an access is logged for, by default the company of
+// the request
+func accessLogCompany(ctx context.Context, log *models.AccessLog) string {
+	if log.Company != "" {
+		return log.Company
+	}
+	return database.CompanyFromContext(ctx)
+}
+
 // GetAll gets all operations
 func (r *operationRepository) GetAll(ctx context.Context) ([]*dto.OperationResponse, error) {
 	query := `
@@ -125,9 +138,9 @@ func (r *operationRepository) GetByID(ctx context.Context, id int) (*models.Oper
 // LogAccess logs access to an operation
 func (r *operationRepository) LogAccess(ctx context.Context, log *models.AccessLog) (int, error) {
 	query := `
-        INSERT INTO access_logs (user_id, operation_id, access_time, search_params, ip_address, status)
+        INSERT INTO access_logs (user_id, operation_id, access_time, search_params, ip_address, status, company)
         OUTPUT INSERTED.id
-        VALUES (@user_id, @operation_id, @access_time, @search_params, @ip_address, @status)
+        VALUES (@user_id, @operation_id, @access_time, @search_params, @ip_address, @status, NULLIF(@company, ''))
     `
 
 	var id int
@@ -140,6 +153,7 @@ func (r *operationRepository) LogAccess(ctx context.Context, log *models.AccessL
 		sql.Named("search_params", log.SearchParams),
 		sql.Named("ip_address", log.IPAddress),
 		sql.Named("status", log.Status),
+		sql.Named("company", accessLogCompany(ctx, log)),
 	).Scan(&id)
 
 	if err != nil {
@@ -240,3 +254,87 @@ func (r *operationRepository) GetRecentLogs(ctx context.Context, limit int) ([]*
 
 	return logs, nil
 }
+
+// GetUserLogs gets a user's access logs since the given time, newest first
+func (r *operationRepository) GetUserLogs(ctx context.Context, userID int, since time.Time, limit int) ([]*models.AccessLog, error) {
+	query := `
+        SELECT TOP (@limit)
+            l.id,
+            l.user_id,
+            l.operation_id,
+            l.access_time,
+            ISNULL(l.search_params, ''),
+            ISNULL(l.ip_address, ''),
+            l.status,
+            ISNULL(l.company, ''),
+            o.name AS operation_name
+        FROM access_logs l
+        JOIN operations o ON l.operation_id = o.id
+        WHERE l.user_id = @user_id AND l.access_time >= @since
+        ORDER BY l.access_time DESC
+    `
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		query,
+		sql.Named("limit", limit),
+		sql.Named("user_id", userID),
+		sql.Named("since", since),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*models.AccessLog
+	for rows.Next() {
+		var log models.AccessLog
+		err := rows.Scan(
+			&log.ID,
+			&log.UserID,
+			&log.OperationID,
+			&log.AccessTime,
+			&log.SearchParams,
+			&log.IPAddress,
+			&log.Status,
+			&log.Company,
+			&log.OperationName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning log: %w", err)
+		}
+
+		logs = append(logs, &log)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating logs: %w", err)
+	}
+
+	return logs, nil
+}
+
+// EnsureOperations adds the operations whose code does not exist yet; existing ones are left as they are
+func (r *operationRepository) EnsureOperations(ctx context.Context, operations []*models.Operation) error {
+	query := `
+        IF NOT EXISTS (SELECT 1 FROM operations WHERE code = @code)
+        INSERT INTO operations (name, code, description, created_at, updated_at)
+        VALUES (@name, @code, @description, @now, @now)
+    `
+
+	now := time.Now()
+	for _, operation := range operations {
+		_, err := r.db.ExecContext(
+			ctx,
+			query,
+			sql.Named("code", operation.Code),
+			sql.Named("name", operation.Name),
+			sql.Named("description", operation.Description),
+			sql.Named("now", now),
+		)
+		if err != nil {
+			return fmt.Errorf("error registering operation %s: %w", operation.Code, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// ReadPool returns the app database the reads of a request run on. database.Database implements
+// it with the read replica, falling back to the primary.
+type ReadPool interface {
+	ReadDB(ctx context.Context) *sql.DB
+}
+
+// reader returns where a read of a repository runs: its transaction when it is in one, so the
+// transaction sees its own writes, otherwise the read pool, or db without one
+func reader(ctx context.Context, db DBTX, reads ReadPool) DBTX {
+	if _, inTx := db.(*sql.Tx); inTx || reads == nil {
+		return db
+	}
+	return reads.ReadDB(ctx)
+}
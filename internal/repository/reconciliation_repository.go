@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/dto"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ReconciliationRepository matches shipping documents (COPTG) against AR documents (ACRTA/ACRTB),
+// only those the row policies of the context allow
+type ReconciliationRepository interface {
+	GetShipmentInvoices(ctx context.Context, fromDate time.Time, toDate time.Time) ([]dto.ReconciliationItem, error)
+}
+
+type reconciliationRepository struct {
+	erp    ERPPool
+	cached bool // read from the local ERP cache tables instead of the ERP server
+	logger *slog.Logger
+}
+
+func NewReconciliationRepository(erp ERPPool, logger *slog.Logger) ReconciliationRepository {
+	return &reconciliationRepository{
+		erp:    erp,
+		logger: logger,
+	}
+}
+
+// NewCachedReconciliationRepository reads from the ERP cache tables on the app database,
+// which hold the ERP data of the given company
+func NewCachedReconciliationRepository(db *sql.DB, company string, logger *slog.Logger) ReconciliationRepository {
+	return &reconciliationRepository{
+		erp:    cachePool{db: db, company: company},
+		cached: true,
+		logger: logger,
+	}
+}
+
+// GetShipmentInvoices returns every non-voided shipping document in the period with the AR document
+// it was invoiced on. ar_shipped_amt totals all shipments on that AR document, including shipments
+// outside the period, so it can be compared with the invoiced amount. Status is left to the caller.
+func (r *reconciliationRepository) GetShipmentInvoices(
+	ctx context.Context,
+	fromDate time.Time,
+	toDate time.Time,
+) ([]dto.ReconciliationItem, error) {
+	r.logger.DebugContext(ctx, "Querying shipment invoices", "from_date", fromDate, "to_date", toDate)
+	erpDB, err := r.erp.ERPDatabaseFor(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting ERP database: %w", err)
+	}
+	policy, policyArgs, err := policyConditions(ctx, "reconciliation")
+	if err != nil {
+		return nil, err
+	}
+
+	// The row policies restrict the shipping documents the reconciliation starts from
+	query := `
+WITH Links AS (
+    SELECT DISTINCT
+        COPTG.TG001 AS ship_type,
+        COPTG.TG002 AS ship_no,
+        COPTG.TG042 AS ship_date,
+        ISNULL(COPTG.TG007, '') AS customer_name,
+        ISNULL(COPTG.TG045, 0) + ISNULL(COPTG.TG046, 0) AS shipped_amt,
+        ACRTB.TB001 AS ar_type,
+        ACRTB.TB002 AS ar_no
+    FROM
+        COPTG WITH (NOLOCK)
+    LEFT JOIN
+        ACRTB WITH (NOLOCK) ON ACRTB.TB005 = COPTG.TG001 AND ACRTB.TB006 = COPTG.TG002
+    WHERE
+        COPTG.TG023 <> 'V'
+        AND COPTG.TG042 BETWEEN @FromDate AND @ToDate` + policy + `
+)
+SELECT
+    Links.ship_type + '-' + Links.ship_no AS shipping_order,
+    CONVERT(VARCHAR(10), CONVERT(DATETIME, Links.ship_date), 103) AS doc_date,
+    Links.customer_name,
+    Links.shipped_amt,
+    ISNULL(ACRTA.TA001 + '-' + ACRTA.TA002, '') AS ar_document,
+    ISNULL(ACRTA.TA036, '') AS invoice_number,
+    ISNULL(ACRTA.TA041, 0) + ISNULL(ACRTA.TA042, 0) AS invoiced_amt,
+    ISNULL(ARShipments.shipped_amt, 0) AS ar_shipped_amt
+FROM
+    Links
+LEFT JOIN
+    ACRTA WITH (NOLOCK) ON ACRTA.TA001 = Links.ar_type AND ACRTA.TA002 = Links.ar_no
+OUTER APPLY (
+    SELECT
+        SUM(ISNULL(COPTG.TG045, 0) + ISNULL(COPTG.TG046, 0)) AS shipped_amt
+    FROM
+        COPTG WITH (NOLOCK)
+    WHERE
+        COPTG.TG023 <> 'V'
+        AND EXISTS (
+            SELECT 1
+            FROM ACRTB WITH (NOLOCK)
+            WHERE ACRTB.TB001 = ACRTA.TA001 AND ACRTB.TB002 = ACRTA.TA002
+                AND ACRTB.TB005 = COPTG.TG001 AND ACRTB.TB006 = COPTG.TG002
+        )
+) AS ARShipments
+ORDER BY
+    Links.ship_date, shipping_order
+	`
+	if r.cached {
+		query = cacheTableReplacer.Replace(query)
+	}
+
+	args := []interface{}{
+		sql.Named("FromDate", fromDate),
+		sql.Named("ToDate", toDate),
+	}
+	rows, err := erpDB.QueryContext(ctx, query, append(args, policyArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying reconciliation data: %w", err)
+	}
+	defer rows.Close()
+
+	var items []dto.ReconciliationItem
+	for rows.Next() {
+		var item dto.ReconciliationItem
+		if err := rows.Scan(
+			&item.ShippingOrder,
+			&item.DocDate,
+			&item.CustomerName,
+			&item.ShippedAmt,
+			&item.ARDocument,
+			&item.InvoiceNumber,
+			&item.InvoicedAmt,
+			&item.ARShippedAmt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning reconciliation data: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reconciliation data: %w", err)
+	}
+
+	return items, nil
+}
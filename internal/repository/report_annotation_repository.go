@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ReportAnnotationRepository stores document notes imported from edited report exports. The notes
+// live in the app database and never touch the ERP.
+type ReportAnnotationRepository interface {
+	GetNotes(ctx context.Context, report string) (map[string]string, error)
+	SaveNotes(ctx context.Context, report string, notes map[string]string, userID int) error
+}
+
+type reportAnnotationRepository struct {
+	db *sql.DB
+}
+
+// NewReportAnnotationRepository creates a new report annotation repository
+func NewReportAnnotationRepository(db *sql.DB) ReportAnnotationRepository {
+	return &reportAnnotationRepository{
+		db: db,
+	}
+}
+
+// GetNotes gets the notes of a report keyed by document
+func (r *reportAnnotationRepository) GetNotes(ctx context.Context, report string) (map[string]string, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT document_key, notes FROM report_annotations WHERE report = @report`,
+		sql.Named("report", report),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting report annotations: %w", err)
+	}
+	defer rows.Close()
+
+	notes := make(map[string]string)
+	for rows.Next() {
+		var key, note string
+		if err := rows.Scan(&key, &note); err != nil {
+			return nil, fmt.Errorf("error scanning report annotation: %w", err)
+		}
+		notes[key] = note
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating report annotations: %w", err)
+	}
+
+	return notes, nil
+}
+
+// SaveNotes inserts or replaces the notes of several documents in one transaction
+func (r *reportAnnotationRepository) SaveNotes(ctx context.Context, report string, notes map[string]string, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+        MERGE report_annotations AS target
+        USING (SELECT @report AS report, @document_key AS document_key) AS source
+        ON target.report = source.report AND target.document_key = source.document_key
+        WHEN MATCHED THEN
+            UPDATE SET notes = @notes, updated_by = @updated_by, updated_at = @now
+        WHEN NOT MATCHED THEN
+            INSERT (report, document_key, notes, updated_by, updated_at)
+            VALUES (@report, @document_key, @notes, @updated_by, @now);
+    `
+
+	now := time.Now()
+	for key, note := range notes {
+		_, err := tx.ExecContext(
+			ctx,
+			query,
+			sql.Named("report", report),
+			sql.Named("document_key", key),
+			sql.Named("notes", note),
+			sql.Named("updated_by", userID),
+			sql.Named("now", now),
+		)
+		if err != nil {
+			return fmt.Errorf("error saving note for %s: %w", key, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing report annotations: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"erp-excel/internal/models"
+	"fmt"
+	"time"
+)
+
+// ReportDefinitionRepository stores the custom report definitions registered by admins
+type ReportDefinitionRepository interface {
+	List(ctx context.Context) ([]*models.ReportDefinition, error)
+	GetByCode(ctx context.Context, code string) (*models.ReportDefinition, error)
+	Create(ctx context.Context, definition *models.ReportDefinition) (int, error)
+	Update(ctx context.Context, definition *models.ReportDefinition) error
+	Delete(ctx context.Context, code string) error
+}
+
+type reportDefinitionRepository struct {
+	db *sql.DB
+}
+
+// NewReportDefinitionRepository creates a new report definition repository
+func NewReportDefinitionRepository(db *sql.DB) ReportDefinitionRepository {
+	return &reportDefinitionRepository{
+		db: db,
+	}
+}
+
+const reportDefinitionColumns = `
+        id, code, name, ISNULL(description, ''), source_type, source, operation_code,
+        parameters, column_map, timeout_seconds, max_rows, ISNULL(title_template, ''),
+        ISNULL(file_name_template, ''), is_active, created_by, created_at, updated_at
+`
+
+// List gets every stored report definition
+func (r *reportDefinitionRepository) List(ctx context.Context) ([]*models.ReportDefinition, error) {
+	query := `SELECT ` + reportDefinitionColumns + ` FROM report_definitions ORDER BY name`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting report definitions: %w", err)
+	}
+	defer rows.Close()
+
+	var definitions []*models.ReportDefinition
+	for rows.Next() {
+		definition, err := scanReportDefinition(rows)
+		if err != nil {
+			return nil, err
+		}
+		definitions = append(definitions, definition)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating report definitions: %w", err)
+	}
+
+	return definitions, nil
+}
+
+// GetByCode gets a stored report definition by code
+func (r *reportDefinitionRepository) GetByCode(ctx context.Context, code string) (*models.ReportDefinition, error) {
+	query := `SELECT ` + reportDefinitionColumns + ` FROM report_definitions WHERE code = @code`
+
+	definition, err := scanReportDefinition(r.db.QueryRowContext(ctx, query, sql.Named("code", code)))
+	if err != nil {
+		return nil, err
+	}
+	return definition, nil
+}
+
+// Create stores a new report definition and registers its operation code, so roles can be granted
+// access to the report like any other operation
+func (r *reportDefinitionRepository) Create(ctx context.Context, definition *models.ReportDefinition) (int, error) {
+	parameters, columns, err := marshalReportDefinition(definition)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	query := `
+        INSERT INTO report_definitions (
+            code, name, description, source_type, source, operation_code, parameters, column_map,
+            timeout_seconds, max_rows, title_template, file_name_template, is_active, created_by,
+            created_at, updated_at
+        )
+        OUTPUT INSERTED.id
+        VALUES (
+            @code, @name, @description, @source_type, @source, @operation_code, @parameters, @columns,
+            @timeout_seconds, @max_rows, @title_template, @file_name_template, @is_active, @created_by,
+            @now, @now
+        )
+    `
+
+	var id int
+	err = tx.QueryRowContext(
+		ctx,
+		query,
+		sql.Named("code", definition.Code),
+		sql.Named("name", definition.Name),
+		sql.Named("description", definition.Description),
+		sql.Named("source_type", definition.SourceType),
+		sql.Named("source", definition.Source),
+		sql.Named("operation_code", definition.OperationCode),
+		sql.Named("parameters", parameters),
+		sql.Named("columns", columns),
+		sql.Named("timeout_seconds", definition.TimeoutSeconds),
+		sql.Named("max_rows", definition.MaxRows),
+		sql.Named("title_template", definition.TitleTemplate),
+		sql.Named("file_name_template", definition.FileNameTemplate),
+		sql.Named("is_active", definition.IsActive),
+		sql.Named("created_by", definition.CreatedBy),
+		sql.Named("now", now),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error creating report definition: %w", err)
+	}
+
+	if err := ensureReportOperation(ctx, tx, definition, now); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing report definition: %w", err)
+	}
+
+	definition.ID = id
+	definition.CreatedAt = now
+	definition.UpdatedAt = now
+	return id, nil
+}
+
+// Update replaces a stored report definition
+func (r *reportDefinitionRepository) Update(ctx context.Context, definition *models.ReportDefinition) error {
+	parameters, columns, err := marshalReportDefinition(definition)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	query := `
+        UPDATE report_definitions
+        SET name = @name, description = @description, source = @source, operation_code = @operation_code,
+            parameters = @parameters, column_map = @columns, timeout_seconds = @timeout_seconds,
+            max_rows = @max_rows, title_template = @title_template,
+            file_name_template = @file_name_template, is_active = @is_active, updated_at = @now
+        WHERE code = @code
+    `
+
+	result, err := tx.ExecContext(
+		ctx,
+		query,
+		sql.Named("code", definition.Code),
+		sql.Named("name", definition.Name),
+		sql.Named("description", definition.Description),
+		sql.Named("source", definition.Source),
+		sql.Named("operation_code", definition.OperationCode),
+		sql.Named("parameters", parameters),
+		sql.Named("columns", columns),
+		sql.Named("timeout_seconds", definition.TimeoutSeconds),
+		sql.Named("max_rows", definition.MaxRows),
+		sql.Named("title_template", definition.TitleTemplate),
+		sql.Named("file_name_template", definition.FileNameTemplate),
+		sql.Named("is_active", definition.IsActive),
+		sql.Named("now", now),
+	)
+	if err != nil {
+		return fmt.Errorf("error updating report definition: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("report definition not found: %w", sql.ErrNoRows)
+	}
+
+	if err := ensureReportOperation(ctx, tx, definition, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing report definition: %w", err)
+	}
+
+	definition.UpdatedAt = now
+	return nil
+}
+
+// Delete removes a stored report definition. Its operation is kept so role assignments and access
+// logs stay valid.
+func (r *reportDefinitionRepository) Delete(ctx context.Context, code string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM report_definitions WHERE code = @code`, sql.Named("code", code))
+	if err != nil {
+		return fmt.Errorf("error deleting report definition: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("report definition not found: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// ensureReportOperation adds the operation of a report definition if it does not exist yet
+func ensureReportOperation(ctx context.Context, tx *sql.Tx, definition *models.ReportDefinition, now time.Time) error {
+	query := `
+        IF NOT EXISTS (SELECT 1 FROM operations WHERE code = @code)
+        INSERT INTO operations (name, code, description, created_at, updated_at)
+        VALUES (@name, @code, @description, @now, @now)
+    `
+
+	_, err := tx.ExecContext(
+		ctx,
+		query,
+		sql.Named("code", definition.OperationCode),
+		sql.Named("name", definition.Name),
+		sql.Named("description", "Run report "+definition.Code),
+		sql.Named("now", now),
+	)
+	if err != nil {
+		return fmt.Errorf("error registering report operation: %w", err)
+	}
+	return nil
+}
+
+// marshalReportDefinition encodes the parameters and columns stored as JSON
+func marshalReportDefinition(definition *models.ReportDefinition) (string, string, error) {
+	parameters, err := json.Marshal(definition.Parameters)
+	if err != nil {
+		return "", "", fmt.Errorf("error marshalling report parameters: %w", err)
+	}
+	columns, err := json.Marshal(definition.Columns)
+	if err != nil {
+		return "", "", fmt.Errorf("error marshalling report columns: %w", err)
+	}
+	return string(parameters), string(columns), nil
+}
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanReportDefinition scans one report definition row
+func scanReportDefinition(row rowScanner) (*models.ReportDefinition, error) {
+	var (
+		definition models.ReportDefinition
+		parameters string
+		columns    string
+	)
+	err := row.Scan(
+		&definition.ID,
+		&definition.Code,
+		&definition.Name,
+		&definition.Description,
+		&definition.SourceType,
+		&definition.Source,
+		&definition.OperationCode,
+		&parameters,
+		&columns,
+		&definition.TimeoutSeconds,
+		&definition.MaxRows,
+		&definition.TitleTemplate,
+		&definition.FileNameTemplate,
+		&definition.IsActive,
+		&definition.CreatedBy,
+		&definition.CreatedAt,
+		&definition.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("report definition not found: %w", err)
+		}
+		return nil, fmt.Errorf("error scanning report definition: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(parameters), &definition.Parameters); err != nil {
+		return nil, fmt.Errorf("error decoding parameters of report %s: %w", definition.Code, err)
+	}
+	if err := json.Unmarshal([]byte(columns), &definition.Columns); err != nil {
+		return nil, fmt.Errorf("error decoding columns of report %s: %w", definition.Code, err)
+	}
+
+	return &definition, nil
+}
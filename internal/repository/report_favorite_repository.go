@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"fmt"
+	"time"
+)
+
+// ReportFavoriteRepository stores the reports users starred
+type ReportFavoriteRepository interface {
+	Add(ctx context.Context, userID int, report string) error
+	Remove(ctx context.Context, userID int, report string) (bool, error)
+	List(ctx context.Context, userID int) ([]*models.ReportFavorite, error)
+}
+
+type reportFavoriteRepository struct {
+	db *sql.DB
+}
+
+// NewReportFavoriteRepository creates a new report favorite repository
+func NewReportFavoriteRepository(db *sql.DB) ReportFavoriteRepository {
+	return &reportFavoriteRepository{
+		db: db,
+	}
+}
+
+// Add stars a report for a user, doing nothing when it already is
+func (r *reportFavoriteRepository) Add(ctx context.Context, userID int, report string) error {
+	query := `
+        IF NOT EXISTS (SELECT 1 FROM report_favorites WHERE user_id = @user_id AND report = @report)
+        INSERT INTO report_favorites (user_id, report, created_at)
+        VALUES (@user_id, @report, @created_at)
+    `
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("user_id", userID),
+		sql.Named("report", report),
+		sql.Named("created_at", time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("error adding report favorite: %w", err)
+	}
+	return nil
+}
+
+// Remove unstars a report, reporting whether it was starred
+func (r *reportFavoriteRepository) Remove(ctx context.Context, userID int, report string) (bool, error) {
+	result, err := r.db.ExecContext(
+		ctx,
+		`DELETE FROM report_favorites WHERE user_id = @user_id AND report = @report`,
+		sql.Named("user_id", userID),
+		sql.Named("report", report),
+	)
+	if err != nil {
+		return false, fmt.Errorf("error removing report favorite: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// List gets the reports a user starred, newest first
+func (r *reportFavoriteRepository) List(ctx context.Context, userID int) ([]*models.ReportFavorite, error) {
+	query := `
+        SELECT user_id, report, created_at
+        FROM report_favorites
+        WHERE user_id = @user_id
+        ORDER BY created_at DESC, report
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, sql.Named("user_id", userID))
+	if err != nil {
+		return nil, fmt.Errorf("error listing report favorites: %w", err)
+	}
+	defer rows.Close()
+
+	favorites := []*models.ReportFavorite{}
+	for rows.Next() {
+		var favorite models.ReportFavorite
+		if err := rows.Scan(&favorite.UserID, &favorite.Report, &favorite.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning report favorite: %w", err)
+		}
+		favorites = append(favorites, &favorite)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating report favorites: %w", err)
+	}
+
+	return favorites, nil
+}
@@ -0,0 +1,273 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"fmt"
+	"time"
+)
+
+// ReportFileRepository records the export files kept in file storage
+type ReportFileRepository interface {
+	Create(ctx context.Context, file *models.ReportFile) error
+	List(ctx context.Context) ([]*models.ReportFile, error)
+	GetByFileName(ctx context.Context, fileName string) (*models.ReportFile, error)
+	GetByAccessLogID(ctx context.Context, accessLogID int) ([]*models.ReportFile, error)
+	ListByUser(ctx context.Context, userID int, report string, limit, offset int) ([]*models.ReportFile, error)
+	CountByUser(ctx context.Context, userID int, report string) (int, error)
+	DeleteByFileName(ctx context.Context, fileName string) error
+	SetMissingExpiry(ctx context.Context, maxAge time.Duration) (int, error)
+}
+
+type reportFileRepository struct {
+	db *sql.DB
+}
+
+// NewReportFileRepository creates a new report file repository
+func NewReportFileRepository(db *sql.DB) ReportFileRepository {
+	return &reportFileRepository{
+		db: db,
+	}
+}
+
+const reportFileQuery = `
+        SELECT f.id, f.file_name, f.report, f.user_id, ISNULL(f.access_log_id, 0), f.size,
+               ISNULL(f.row_count, 0), ISNULL(f.checksum, ''), f.created_at, f.expires_at, ISNULL(u.username, ''), ISNULL(l.status, ''),
+               l.access_time, ISNULL(o.name, ''), ISNULL(l.search_params, '')
+        FROM report_files f
+        LEFT JOIN users u ON f.user_id = u.id
+        LEFT JOIN access_logs l ON f.access_log_id = l.id
+        LEFT JOIN operations o ON l.operation_id = o.id
+`
+
+// Create records a stored export file, replacing an older record of the same file name
+func (r *reportFileRepository) Create(ctx context.Context, file *models.ReportFile) error {
+	query := `
+        DELETE FROM report_files WHERE file_name = @file_name;
+        INSERT INTO report_files (file_name, report, user_id, access_log_id, size, row_count, checksum, created_at, expires_at)
+        VALUES (@file_name, @report, @user_id, @access_log_id, @size, @row_count, @checksum, @created_at, @expires_at);
+    `
+
+	var accessLogID sql.NullInt64
+	if file.AccessLogID > 0 {
+		accessLogID = sql.NullInt64{Int64: int64(file.AccessLogID), Valid: true}
+	}
+	var checksum sql.NullString
+	if file.Checksum != "" {
+		checksum = sql.NullString{String: file.Checksum, Valid: true}
+	}
+	if file.CreatedAt.IsZero() {
+		file.CreatedAt = time.Now()
+	}
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("file_name", file.FileName),
+		sql.Named("report", file.Report),
+		sql.Named("user_id", file.UserID),
+		sql.Named("access_log_id", accessLogID),
+		sql.Named("size", file.Size),
+		sql.Named("row_count", file.RowCount),
+		sql.Named("checksum", checksum),
+		sql.Named("created_at", file.CreatedAt),
+		sql.Named("expires_at", nullTimePtr(file.ExpiresAt)),
+	)
+	if err != nil {
+		return fmt.Errorf("error recording report file: %w", err)
+	}
+
+	return nil
+}
+
+// List gets the recorded files with their owner and access log, newest first
+func (r *reportFileRepository) List(ctx context.Context) ([]*models.ReportFile, error) {
+	query := reportFileQuery + ` ORDER BY f.created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error listing report files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []*models.ReportFile
+	for rows.Next() {
+		file, err := scanReportFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating report files: %w", err)
+	}
+
+	return files, nil
+}
+
+// GetByFileName gets the record of a stored file
+func (r *reportFileRepository) GetByFileName(ctx context.Context, fileName string) (*models.ReportFile, error) {
+	query := reportFileQuery + ` WHERE f.file_name = @file_name`
+
+	return scanReportFile(r.db.QueryRowContext(ctx, query, sql.Named("file_name", fileName)))
+}
+
+// GetByAccessLogID gets the files generated by the run of an access log entry, newest first
+func (r *reportFileRepository) GetByAccessLogID(ctx context.Context, accessLogID int) ([]*models.ReportFile, error) {
+	query := reportFileQuery + ` WHERE f.access_log_id = @access_log_id ORDER BY f.created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, sql.Named("access_log_id", accessLogID))
+	if err != nil {
+		return nil, fmt.Errorf("error getting report files of access log: %w", err)
+	}
+	defer rows.Close()
+
+	var files []*models.ReportFile
+	for rows.Next() {
+		file, err := scanReportFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating report files: %w", err)
+	}
+
+	return files, nil
+}
+
+// userFilesFilter selects the unexpired files of a user, of one report when report is set
+const userFilesFilter = `
+        WHERE f.user_id = @user_id
+          AND (f.expires_at IS NULL OR f.expires_at > GETDATE())
+          AND (@report = '' OR f.report = @report)
+`
+
+// ListByUser gets a page of the unexpired files generated by a user, newest first
+func (r *reportFileRepository) ListByUser(ctx context.Context, userID int, report string, limit, offset int) ([]*models.ReportFile, error) {
+	query := reportFileQuery + userFilesFilter + `
+        ORDER BY f.created_at DESC
+        OFFSET @offset ROWS FETCH NEXT @limit ROWS ONLY
+    `
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		query,
+		sql.Named("user_id", userID),
+		sql.Named("report", report),
+		sql.Named("offset", offset),
+		sql.Named("limit", limit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error listing report files of user: %w", err)
+	}
+	defer rows.Close()
+
+	var files []*models.ReportFile
+	for rows.Next() {
+		file, err := scanReportFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating report files: %w", err)
+	}
+
+	return files, nil
+}
+
+// CountByUser gets the number of unexpired files generated by a user
+func (r *reportFileRepository) CountByUser(ctx context.Context, userID int, report string) (int, error) {
+	query := `SELECT COUNT(*) FROM report_files f` + userFilesFilter
+
+	var count int
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		sql.Named("user_id", userID),
+		sql.Named("report", report),
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting report files of user: %w", err)
+	}
+
+	return count, nil
+}
+
+// DeleteByFileName removes the record of a file
+func (r *reportFileRepository) DeleteByFileName(ctx context.Context, fileName string) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		"DELETE FROM report_files WHERE file_name = @file_name",
+		sql.Named("file_name", fileName),
+	)
+	if err != nil {
+		return fmt.Errorf("error deleting report file record: %w", err)
+	}
+
+	return nil
+}
+
+// SetMissingExpiry sets the expiry of the files recorded without one to their creation plus
+// maxAge and returns how many there were
+func (r *reportFileRepository) SetMissingExpiry(ctx context.Context, maxAge time.Duration) (int, error) {
+	result, err := r.db.ExecContext(
+		ctx,
+		"UPDATE report_files SET expires_at = DATEADD(second, @seconds, created_at) WHERE expires_at IS NULL",
+		sql.Named("seconds", int64(maxAge/time.Second)),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error setting report file expiry: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error reading affected rows: %w", err)
+	}
+	return int(affected), nil
+}
+
+// scanReportFile scans one report file row of reportFileQuery
+func scanReportFile(row rowScanner) (*models.ReportFile, error) {
+	var file models.ReportFile
+	var expiresAt, accessTime sql.NullTime
+
+	err := row.Scan(
+		&file.ID,
+		&file.FileName,
+		&file.Report,
+		&file.UserID,
+		&file.AccessLogID,
+		&file.Size,
+		&file.RowCount,
+		&file.Checksum,
+		&file.CreatedAt,
+		&expiresAt,
+		&file.Username,
+		&file.AccessLogStatus,
+		&accessTime,
+		&file.OperationName,
+		&file.Parameters,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("report file not found: %w", err)
+		}
+		return nil, fmt.Errorf("error scanning report file: %w", err)
+	}
+
+	if expiresAt.Valid {
+		file.ExpiresAt = &expiresAt.Time
+	}
+	if accessTime.Valid {
+		file.AccessTime = &accessTime.Time
+	}
+	return &file, nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// ReportFilter narrows a report to the matching rows; empty fields do not filter
+type ReportFilter struct {
+	CustomerName  string // part of the customer name
+	InvoiceNumber string
+	CurrencyType  string // transaction currency code, e.g. USD
+	OrderNumber   string // sales or detailed order number
+}
+
+// reportFilterColumns are the expressions of a report query the filter fields are compared with
+type reportFilterColumns struct {
+	customerName  string
+	invoiceNumber string
+	currencyType  string
+	orderNumbers  []string
+}
+
+// likeEscaper makes LIKE wildcards in user input match literally
+var likeEscaper = strings.NewReplacer("[", "[[]", "%", "[%]", "_", "[_]")
+
+// filterConditions returns the conditions and arguments to append to the WHERE clause of a report
+// query, each starting with AND
+func filterConditions(filter ReportFilter, columns reportFilterColumns) (string, []interface{}) {
+	var conditions strings.Builder
+	var args []interface{}
+
+	if filter.CustomerName != "" {
+		conditions.WriteString("\n    AND " + columns.customerName + " LIKE @FilterCustomerName")
+		args = append(args, sql.Named("FilterCustomerName", "%"+likeEscaper.Replace(filter.CustomerName)+"%"))
+	}
+	if filter.InvoiceNumber != "" {
+		conditions.WriteString("\n    AND " + columns.invoiceNumber + " = @FilterInvoiceNumber")
+		args = append(args, sql.Named("FilterInvoiceNumber", filter.InvoiceNumber))
+	}
+	if filter.CurrencyType != "" {
+		conditions.WriteString("\n    AND " + columns.currencyType + " = @FilterCurrencyType")
+		args = append(args, sql.Named("FilterCurrencyType", filter.CurrencyType))
+	}
+	if filter.OrderNumber != "" && len(columns.orderNumbers) > 0 {
+		matches := make([]string, 0, len(columns.orderNumbers))
+		for _, column := range columns.orderNumbers {
+			matches = append(matches, column+" = @FilterOrderNumber")
+		}
+		conditions.WriteString("\n    AND (" + strings.Join(matches, " OR ") + ")")
+		args = append(args, sql.Named("FilterOrderNumber", filter.OrderNumber))
+	}
+
+	return conditions.String(), args
+}
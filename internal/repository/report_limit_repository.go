@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"fmt"
+	"time"
+)
+
+// ReportLimitRepository stores the report guardrails set at runtime, which replace the configured ones
+type ReportLimitRepository interface {
+	List(ctx context.Context) ([]*models.ReportLimit, error)
+	Upsert(ctx context.Context, limit *models.ReportLimit) error
+	Delete(ctx context.Context, report string) error
+}
+
+type reportLimitRepository struct {
+	db *sql.DB
+}
+
+// NewReportLimitRepository creates a new report limit repository
+func NewReportLimitRepository(db *sql.DB) ReportLimitRepository {
+	return &reportLimitRepository{
+		db: db,
+	}
+}
+
+// List gets the limits ordered by report
+func (r *reportLimitRepository) List(ctx context.Context) ([]*models.ReportLimit, error) {
+	query := `
+        SELECT report, max_months, max_rows, max_export_mb, updated_by, updated_at
+        FROM report_limits
+        ORDER BY report
+    `
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting report limits: %w", err)
+	}
+	defer rows.Close()
+
+	var limits []*models.ReportLimit
+	for rows.Next() {
+		var limit models.ReportLimit
+		err := rows.Scan(
+			&limit.Report,
+			&limit.MaxMonths,
+			&limit.MaxRows,
+			&limit.MaxExportMB,
+			&limit.UpdatedBy,
+			&limit.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning report limit: %w", err)
+		}
+		limits = append(limits, &limit)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating report limits: %w", err)
+	}
+
+	return limits, nil
+}
+
+// Upsert creates the limit of a report or replaces it
+func (r *reportLimitRepository) Upsert(ctx context.Context, limit *models.ReportLimit) error {
+	query := `
+        MERGE report_limits AS target
+        USING (SELECT @report AS report) AS source
+        ON target.report = source.report
+        WHEN MATCHED THEN
+            UPDATE SET max_months = @max_months, max_rows = @max_rows, max_export_mb = @max_export_mb,
+                updated_by = @updated_by, updated_at = @now
+        WHEN NOT MATCHED THEN
+            INSERT (report, max_months, max_rows, max_export_mb, updated_by, updated_at)
+            VALUES (@report, @max_months, @max_rows, @max_export_mb, @updated_by, @now);
+    `
+
+	limit.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("report", limit.Report),
+		sql.Named("max_months", limit.MaxMonths),
+		sql.Named("max_rows", limit.MaxRows),
+		sql.Named("max_export_mb", limit.MaxExportMB),
+		sql.Named("updated_by", limit.UpdatedBy),
+		sql.Named("now", limit.UpdatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("error saving report limit: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the limit of a report
+func (r *reportLimitRepository) Delete(ctx context.Context, report string) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM report_limits WHERE report = @report", sql.Named("report", report))
+	if err != nil {
+		return fmt.Errorf("error deleting report limit: %w", err)
+	}
+
+	return checkAffected(result, "report limit")
+}
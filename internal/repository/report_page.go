@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/apperror"
+	"fmt"
+)
+
+// ReportPage selects one sorted page of a report's rows
+type ReportPage struct {
+	Offset   int
+	Limit    int    // rows in the page, 0 for every row from Offset on
+	SortBy   string // sort key of the report, empty for its default order
+	SortDesc bool
+}
+
+// ErrInvalidSort is returned when a page is sorted by a key the report does not have
+var ErrInvalidSort = apperror.Validation("invalid sort column")
+
+// reportSort maps the sort keys of a report to expressions over the columns of its query
+type reportSort struct {
+	columns     map[string]string
+	defaultKey  string
+	tiebreakers string // appended to every order so pages do not overlap
+}
+
+// pageQuery wraps a report query so it returns one sorted page, with the total number of rows of
+// the report in an extra total_count column
+func pageQuery(query string, args []interface{}, page ReportPage, sort reportSort) (string, []interface{}, error) {
+	key := page.SortBy
+	if key == "" {
+		key = sort.defaultKey
+	}
+	column, ok := sort.columns[key]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %s", ErrInvalidSort, key)
+	}
+
+	direction := "ASC"
+	if page.SortDesc {
+		direction = "DESC"
+	}
+
+	paged := fmt.Sprintf(`SELECT report.*, COUNT(*) OVER () AS total_count
+FROM (%s) AS report
+ORDER BY %s %s, %s
+OFFSET @Offset ROWS`, query, column, direction, sort.tiebreakers)
+
+	pageArgs := append(append([]interface{}{}, args...), sql.Named("Offset", page.Offset))
+	if page.Limit > 0 {
+		paged += " FETCH NEXT @Limit ROWS ONLY"
+		pageArgs = append(pageArgs, sql.Named("Limit", page.Limit))
+	}
+
+	return paged, pageArgs, nil
+}
+
+// countReportRows counts the rows of a report query, for pages past its end that carry no
+// total_count
+func countReportRows(ctx context.Context, db DBTX, query string, args []interface{}) (int, error) {
+	var total int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM ("+query+") AS report", args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("error counting report rows: %w", err)
+	}
+	return total, nil
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RowPredicate keeps the report rows whose attribute is, or with RowPolicyNotIn is not, one of
+// the values. An empty value list keeps no rows, or with RowPolicyNotIn all of them.
+type RowPredicate struct {
+	Attribute string
+	Operator  string
+	Values    []string
+}
+
+// policyColumns map the row policy attributes of a report to the ERP expressions they compare
+type policyColumns map[string]string
+
+// reportPolicyColumns are the attributes row policies may restrict, by report code. Reports not
+// listed here do not support row policies.
+var reportPolicyColumns = map[string]policyColumns{
+	"assistant230": {
+		"customer":    "COPTG.TG004",
+		"department":  "COPTG.TG005",
+		"salesperson": "COPTG.TG006",
+		"currency":    "COPTG.TG011",
+	},
+	// Receivables without a sales delivery have no customer, department or salesperson, so
+	// policies on those attributes leave them out
+	"assistant610": {
+		"customer":    "COPTG.TG004",
+		"department":  "COPTG.TG005",
+		"salesperson": "COPTG.TG006",
+		"currency":    "ACRTA.TA009",
+	},
+	"assistant340": {
+		"supplier": "PURTG.TG005",
+		"item":     "PURTH.TH004",
+		"currency": "PURTG.TG007",
+	},
+	// The reconciliation restricts its shipping documents by the policies of both 230 and 610;
+	// the AR documents of a shipment are in its currency
+	"reconciliation": {
+		"customer":    "COPTG.TG004",
+		"department":  "COPTG.TG005",
+		"salesperson": "COPTG.TG006",
+		"currency":    "COPTG.TG011",
+	},
+}
+
+// RowPolicyAttributes returns the attributes row policies may restrict in a report, sorted, or
+// nil when the report does not support row policies
+func RowPolicyAttributes(report string) []string {
+	columns, ok := reportPolicyColumns[report]
+	if !ok {
+		return nil
+	}
+	attributes := make([]string, 0, len(columns))
+	for attribute := range columns {
+		attributes = append(attributes, attribute)
+	}
+	sort.Strings(attributes)
+	return attributes
+}
+
+type rowPolicyKey struct{}
+
+// rowPolicy is what the report services evaluated for the user running a report
+type rowPolicy struct {
+	predicates []RowPredicate
+	err        error
+}
+
+// WithRowPredicates returns a context whose report queries only return the rows matching every
+// predicate
+func WithRowPredicates(ctx context.Context, predicates []RowPredicate) context.Context {
+	return context.WithValue(ctx, rowPolicyKey{}, rowPolicy{predicates: predicates})
+}
+
+// WithRowPolicyError returns a context whose report queries fail with err, for when the policies
+// of the user could not be evaluated; the report must not run unrestricted then
+func WithRowPolicyError(ctx context.Context, err error) context.Context {
+	return context.WithValue(ctx, rowPolicyKey{}, rowPolicy{err: err})
+}
+
+// RowPolicyRestricts reports whether the row policies of the context restrict report queries, so
+// results computed for it may not be shared with other users
+func RowPolicyRestricts(ctx context.Context) bool {
+	policy, _ := ctx.Value(rowPolicyKey{}).(rowPolicy)
+	return policy.err != nil || len(policy.predicates) > 0
+}
+
+// policyConditions returns the conditions and arguments the row predicates of the context add to
+// the WHERE clause of a report query, each starting with AND. A predicate on an attribute the
+// report does not have is an error rather than ignored, so the report never shows more than the
+// policy allows.
+func policyConditions(ctx context.Context, report string) (string, []interface{}, error) {
+	policy, _ := ctx.Value(rowPolicyKey{}).(rowPolicy)
+	if policy.err != nil {
+		return "", nil, policy.err
+	}
+
+	var conditions strings.Builder
+	var args []interface{}
+	for _, predicate := range policy.predicates {
+		column, ok := reportPolicyColumns[report][predicate.Attribute]
+		if !ok {
+			return "", nil, fmt.Errorf("report %s has no row policy attribute %q", report, predicate.Attribute)
+		}
+
+		names := make([]string, 0, len(predicate.Values))
+		for _, value := range predicate.Values {
+			name := fmt.Sprintf("Policy%d", len(args))
+			names = append(names, "@"+name)
+			args = append(args, sql.Named(name, value))
+		}
+
+		switch {
+		case predicate.Operator == models.RowPolicyNotIn && len(names) == 0:
+		case predicate.Operator == models.RowPolicyNotIn:
+			conditions.WriteString("\n    AND ISNULL(" + column + ", '') NOT IN (" + strings.Join(names, ", ") + ")")
+		case predicate.Operator != models.RowPolicyIn:
+			return "", nil, fmt.Errorf("unknown row policy operator %q", predicate.Operator)
+		case len(names) == 0:
+			conditions.WriteString("\n    AND 1 = 0")
+		default:
+			conditions.WriteString("\n    AND " + column + " IN (" + strings.Join(names, ", ") + ")")
+		}
+	}
+
+	return conditions.String(), args, nil
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"fmt"
+	"time"
+)
+
+// ReportPresetRepository stores the report presets users saved
+type ReportPresetRepository interface {
+	Create(ctx context.Context, preset *models.ReportPreset) (int, error)
+	Update(ctx context.Context, preset *models.ReportPreset) error
+	Delete(ctx context.Context, id int) error
+	GetByID(ctx context.Context, id int) (*models.ReportPreset, error)
+	List(ctx context.Context, userID int) ([]*models.ReportPreset, error)
+}
+
+type reportPresetRepository struct {
+	db *sql.DB
+}
+
+// NewReportPresetRepository creates a new report preset repository
+func NewReportPresetRepository(db *sql.DB) ReportPresetRepository {
+	return &reportPresetRepository{
+		db: db,
+	}
+}
+
+const reportPresetColumns = `id, user_id, name, report, parameters, created_at, updated_at`
+
+// Create stores a new preset
+func (r *reportPresetRepository) Create(ctx context.Context, preset *models.ReportPreset) (int, error) {
+	now := time.Now()
+	query := `
+        INSERT INTO report_presets (user_id, name, report, parameters, created_at, updated_at)
+        OUTPUT INSERTED.id
+        VALUES (@user_id, @name, @report, @parameters, @created_at, @updated_at)
+    `
+
+	var id int
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		sql.Named("user_id", preset.UserID),
+		sql.Named("name", preset.Name),
+		sql.Named("report", preset.Report),
+		sql.Named("parameters", preset.Parameters),
+		sql.Named("created_at", now),
+		sql.Named("updated_at", now),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error creating report preset: %w", err)
+	}
+
+	preset.ID = id
+	preset.CreatedAt = now
+	preset.UpdatedAt = now
+	return id, nil
+}
+
+// Update saves the name, report and parameters of a preset
+func (r *reportPresetRepository) Update(ctx context.Context, preset *models.ReportPreset) error {
+	now := time.Now()
+	query := `
+        UPDATE report_presets
+        SET name = @name, report = @report, parameters = @parameters, updated_at = @updated_at
+        WHERE id = @id
+    `
+
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("id", preset.ID),
+		sql.Named("name", preset.Name),
+		sql.Named("report", preset.Report),
+		sql.Named("parameters", preset.Parameters),
+		sql.Named("updated_at", now),
+	)
+	if err != nil {
+		return fmt.Errorf("error updating report preset: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting affected rows: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("report preset not found: %w", sql.ErrNoRows)
+	}
+
+	preset.UpdatedAt = now
+	return nil
+}
+
+// Delete removes a preset
+func (r *reportPresetRepository) Delete(ctx context.Context, id int) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM report_presets WHERE id = @id`, sql.Named("id", id)); err != nil {
+		return fmt.Errorf("error deleting report preset: %w", err)
+	}
+	return nil
+}
+
+// GetByID gets a preset by ID
+func (r *reportPresetRepository) GetByID(ctx context.Context, id int) (*models.ReportPreset, error) {
+	query := `SELECT ` + reportPresetColumns + ` FROM report_presets WHERE id = @id`
+
+	preset, err := scanReportPreset(r.db.QueryRowContext(ctx, query, sql.Named("id", id)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("report preset not found: %w", err)
+		}
+		return nil, fmt.Errorf("error getting report preset: %w", err)
+	}
+
+	return preset, nil
+}
+
+// List gets the presets of a user by name
+func (r *reportPresetRepository) List(ctx context.Context, userID int) ([]*models.ReportPreset, error) {
+	query := `
+        SELECT ` + reportPresetColumns + `
+        FROM report_presets
+        WHERE user_id = @user_id
+        ORDER BY name, id
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, sql.Named("user_id", userID))
+	if err != nil {
+		return nil, fmt.Errorf("error listing report presets: %w", err)
+	}
+	defer rows.Close()
+
+	presets := []*models.ReportPreset{}
+	for rows.Next() {
+		preset, err := scanReportPreset(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning report preset: %w", err)
+		}
+		presets = append(presets, preset)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating report presets: %w", err)
+	}
+
+	return presets, nil
+}
+
+// scanReportPreset scans one row selected with reportPresetColumns
+func scanReportPreset(row rowScanner) (*models.ReportPreset, error) {
+	var preset models.ReportPreset
+	if err := row.Scan(
+		&preset.ID,
+		&preset.UserID,
+		&preset.Name,
+		&preset.Report,
+		&preset.Parameters,
+		&preset.CreatedAt,
+		&preset.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &preset, nil
+}
@@ -0,0 +1,361 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"fmt"
+	"time"
+)
+
+// ReportScheduleRepository stores report schedules and their run history
+type ReportScheduleRepository interface {
+	Create(ctx context.Context, schedule *models.ReportSchedule) (int, error)
+	Update(ctx context.Context, schedule *models.ReportSchedule) error
+	Delete(ctx context.Context, id int) error
+	GetByID(ctx context.Context, id int) (*models.ReportSchedule, error)
+	List(ctx context.Context, createdBy int) ([]*models.ReportSchedule, error)
+	ListDue(ctx context.Context, now time.Time) ([]*models.ReportSchedule, error)
+	ClaimRun(ctx context.Context, id int, expectedNextRun time.Time, nextRun *time.Time) (bool, error)
+	StartRun(ctx context.Context, run *models.ReportScheduleRun) (int, error)
+	FinishRun(ctx context.Context, id int, status, fileName, errMsg string) error
+	ListRuns(ctx context.Context, scheduleID int, limit int) ([]*models.ReportScheduleRun, error)
+}
+
+type reportScheduleRepository struct {
+	db *sql.DB
+}
+
+// NewReportScheduleRepository creates a new report schedule repository
+func NewReportScheduleRepository(db *sql.DB) ReportScheduleRepository {
+	return &reportScheduleRepository{
+		db: db,
+	}
+}
+
+const reportScheduleColumns = `id, name, report, period, frequency, cron, recipients, ISNULL(spreadsheet_id, ''), ISNULL(sheet_name, ''), is_active, created_by, department_id, ISNULL(company, ''), next_run_at, last_run_at, created_at, updated_at`
+
+// Create stores a new schedule
+func (r *reportScheduleRepository) Create(ctx context.Context, schedule *models.ReportSchedule) (int, error) {
+	now := time.Now()
+	query := `
+        INSERT INTO report_schedules (name, report, period, frequency, cron, recipients, spreadsheet_id, sheet_name, is_active, created_by, department_id, company, next_run_at, created_at, updated_at)
+        OUTPUT INSERTED.id
+        VALUES (@name, @report, @period, @frequency, @cron, @recipients, NULLIF(@spreadsheet_id, ''), NULLIF(@sheet_name, ''), @is_active, @created_by, @department_id, NULLIF(@company, ''), @next_run_at, @created_at, @updated_at)
+    `
+
+	var id int
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		sql.Named("name", schedule.Name),
+		sql.Named("report", schedule.Report),
+		sql.Named("period", schedule.Period),
+		sql.Named("frequency", schedule.Frequency),
+		sql.Named("cron", schedule.Cron),
+		sql.Named("recipients", schedule.Recipients),
+		sql.Named("spreadsheet_id", schedule.SpreadsheetID),
+		sql.Named("sheet_name", schedule.SheetName),
+		sql.Named("is_active", schedule.IsActive),
+		sql.Named("created_by", schedule.CreatedBy),
+		sql.Named("department_id", schedule.DepartmentID),
+		sql.Named("company", schedule.Company),
+		sql.Named("next_run_at", nullTimePtr(schedule.NextRunAt)),
+		sql.Named("created_at", now),
+		sql.Named("updated_at", now),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error creating report schedule: %w", err)
+	}
+
+	schedule.ID = id
+	schedule.CreatedAt = now
+	schedule.UpdatedAt = now
+	return id, nil
+}
+
+// Update saves the editable fields of a schedule
+func (r *reportScheduleRepository) Update(ctx context.Context, schedule *models.ReportSchedule) error {
+	now := time.Now()
+	query := `
+        UPDATE report_schedules
+        SET name = @name, report = @report, period = @period, frequency = @frequency, cron = @cron,
+            recipients = @recipients, spreadsheet_id = NULLIF(@spreadsheet_id, ''), sheet_name = NULLIF(@sheet_name, ''),
+            is_active = @is_active, next_run_at = @next_run_at, updated_at = @updated_at
+        WHERE id = @id
+    `
+
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("id", schedule.ID),
+		sql.Named("name", schedule.Name),
+		sql.Named("report", schedule.Report),
+		sql.Named("period", schedule.Period),
+		sql.Named("frequency", schedule.Frequency),
+		sql.Named("cron", schedule.Cron),
+		sql.Named("recipients", schedule.Recipients),
+		sql.Named("spreadsheet_id", schedule.SpreadsheetID),
+		sql.Named("sheet_name", schedule.SheetName),
+		sql.Named("is_active", schedule.IsActive),
+		sql.Named("next_run_at", nullTimePtr(schedule.NextRunAt)),
+		sql.Named("updated_at", now),
+	)
+	if err != nil {
+		return fmt.Errorf("error updating report schedule: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting affected rows: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("report schedule not found: %w", sql.ErrNoRows)
+	}
+
+	schedule.UpdatedAt = now
+	return nil
+}
+
+// Delete removes a schedule and its run history
+func (r *reportScheduleRepository) Delete(ctx context.Context, id int) error {
+	query := `
+        DELETE FROM report_schedule_runs WHERE schedule_id = @id;
+        DELETE FROM report_schedules WHERE id = @id;
+    `
+
+	if _, err := r.db.ExecContext(ctx, query, sql.Named("id", id)); err != nil {
+		return fmt.Errorf("error deleting report schedule: %w", err)
+	}
+	return nil
+}
+
+// GetByID gets a schedule by ID
+func (r *reportScheduleRepository) GetByID(ctx context.Context, id int) (*models.ReportSchedule, error) {
+	query := `SELECT ` + reportScheduleColumns + ` FROM report_schedules WHERE id = @id`
+
+	schedule, err := scanReportSchedule(r.db.QueryRowContext(ctx, query, sql.Named("id", id)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("report schedule not found: %w", err)
+		}
+		return nil, fmt.Errorf("error getting report schedule: %w", err)
+	}
+
+	return schedule, nil
+}
+
+// List gets the schedules of a user, or every schedule when createdBy is 0
+func (r *reportScheduleRepository) List(ctx context.Context, createdBy int) ([]*models.ReportSchedule, error) {
+	query := `
+        SELECT ` + reportScheduleColumns + `
+        FROM report_schedules
+        WHERE @created_by = 0 OR created_by = @created_by
+        ORDER BY name, id
+    `
+
+	return r.query(ctx, query, sql.Named("created_by", createdBy))
+}
+
+// ListDue gets the active schedules whose next run is due
+func (r *reportScheduleRepository) ListDue(ctx context.Context, now time.Time) ([]*models.ReportSchedule, error) {
+	query := `
+        SELECT ` + reportScheduleColumns + `
+        FROM report_schedules
+        WHERE is_active = 1 AND next_run_at <= @now
+        ORDER BY next_run_at, id
+    `
+
+	return r.query(ctx, query, sql.Named("now", now))
+}
+
+// ClaimRun moves a due schedule on to its next run. It only succeeds while the next run is still
+// the expected one, so when several instances find the same due schedule only one of them runs it.
+func (r *reportScheduleRepository) ClaimRun(ctx context.Context, id int, expectedNextRun time.Time, nextRun *time.Time) (bool, error) {
+	query := `
+        UPDATE report_schedules
+        SET next_run_at = @next_run_at, last_run_at = @last_run_at
+        WHERE id = @id AND next_run_at = @expected_next_run_at
+    `
+
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("id", id),
+		sql.Named("expected_next_run_at", expectedNextRun),
+		sql.Named("next_run_at", nullTimePtr(nextRun)),
+		sql.Named("last_run_at", time.Now()),
+	)
+	if err != nil {
+		return false, fmt.Errorf("error claiming report schedule run: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// StartRun records the start of a delivery
+func (r *reportScheduleRepository) StartRun(ctx context.Context, run *models.ReportScheduleRun) (int, error) {
+	now := time.Now()
+	query := `
+        INSERT INTO report_schedule_runs (schedule_id, status, recipients, started_at)
+        OUTPUT INSERTED.id
+        VALUES (@schedule_id, 'running', @recipients, @started_at)
+    `
+
+	var id int
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		sql.Named("schedule_id", run.ScheduleID),
+		sql.Named("recipients", run.Recipients),
+		sql.Named("started_at", now),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error creating report schedule run: %w", err)
+	}
+
+	run.ID = id
+	run.Status = "running"
+	run.StartedAt = now
+	return id, nil
+}
+
+// FinishRun records the outcome of a delivery
+func (r *reportScheduleRepository) FinishRun(ctx context.Context, id int, status, fileName, errMsg string) error {
+	query := `
+        UPDATE report_schedule_runs
+        SET status = @status, file_name = NULLIF(@file_name, ''), error = NULLIF(LEFT(@error, 1000), ''), finished_at = @finished_at
+        WHERE id = @id
+    `
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("id", id),
+		sql.Named("status", status),
+		sql.Named("file_name", fileName),
+		sql.Named("error", errMsg),
+		sql.Named("finished_at", time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("error updating report schedule run: %w", err)
+	}
+	return nil
+}
+
+// ListRuns gets the newest runs of a schedule
+func (r *reportScheduleRepository) ListRuns(ctx context.Context, scheduleID int, limit int) ([]*models.ReportScheduleRun, error) {
+	query := `
+        SELECT TOP (@limit) id, schedule_id, status, ISNULL(file_name, ''), recipients, ISNULL(error, ''), started_at, finished_at
+        FROM report_schedule_runs
+        WHERE schedule_id = @schedule_id
+        ORDER BY started_at DESC, id DESC
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, sql.Named("limit", limit), sql.Named("schedule_id", scheduleID))
+	if err != nil {
+		return nil, fmt.Errorf("error listing report schedule runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []*models.ReportScheduleRun{}
+	for rows.Next() {
+		var run models.ReportScheduleRun
+		var finishedAt sql.NullTime
+		if err := rows.Scan(
+			&run.ID,
+			&run.ScheduleID,
+			&run.Status,
+			&run.FileName,
+			&run.Recipients,
+			&run.Error,
+			&run.StartedAt,
+			&finishedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning report schedule run: %w", err)
+		}
+		if finishedAt.Valid {
+			run.FinishedAt = &finishedAt.Time
+		}
+		runs = append(runs, &run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating report schedule runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+// query runs a schedule query and scans every row
+func (r *reportScheduleRepository) query(ctx context.Context, query string, args ...interface{}) ([]*models.ReportSchedule, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing report schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []*models.ReportSchedule{}
+	for rows.Next() {
+		schedule, err := scanReportSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning report schedule: %w", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating report schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// scanReportSchedule scans one row selected with reportScheduleColumns
+func scanReportSchedule(row rowScanner) (*models.ReportSchedule, error) {
+	var schedule models.ReportSchedule
+	var nextRunAt, lastRunAt sql.NullTime
+	if err := row.Scan(
+		&schedule.ID,
+		&schedule.Name,
+		&schedule.Report,
+		&schedule.Period,
+		&schedule.Frequency,
+		&schedule.Cron,
+		&schedule.Recipients,
+		&schedule.SpreadsheetID,
+		&schedule.SheetName,
+		&schedule.IsActive,
+		&schedule.CreatedBy,
+		&schedule.DepartmentID,
+		&schedule.Company,
+		&nextRunAt,
+		&lastRunAt,
+		&schedule.CreatedAt,
+		&schedule.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if nextRunAt.Valid {
+		schedule.NextRunAt = &nextRunAt.Time
+	}
+	if lastRunAt.Valid {
+		schedule.LastRunAt = &lastRunAt.Time
+	}
+
+	return &schedule, nil
+}
+
+// nullTimePtr converts a nil time to a SQL NULL
+func nullTimePtr(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return nullTime(*t)
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"fmt"
+	"time"
+)
+
+// ReportSnapshotRepository stores report snapshots. There is deliberately no update or delete.
+type ReportSnapshotRepository interface {
+	Create(ctx context.Context, snapshot *models.ReportSnapshot) (int, error)
+	List(ctx context.Context, report string, limit int) ([]*models.ReportSnapshot, error)
+	GetByID(ctx context.Context, id int) (*models.ReportSnapshot, error)
+}
+
+type reportSnapshotRepository struct {
+	db *sql.DB
+}
+
+// NewReportSnapshotRepository creates a new report snapshot repository
+func NewReportSnapshotRepository(db *sql.DB) ReportSnapshotRepository {
+	return &reportSnapshotRepository{
+		db: db,
+	}
+}
+
+// Create stores a snapshot
+func (r *reportSnapshotRepository) Create(ctx context.Context, snapshot *models.ReportSnapshot) (int, error) {
+	now := time.Now()
+	query := `
+        INSERT INTO report_snapshots (report, report_name, parameters, data, row_count, checksum, notes, created_by, created_at)
+        OUTPUT INSERTED.id
+        VALUES (@report, @report_name, @parameters, @data, @row_count, @checksum, @notes, @created_by, @created_at)
+    `
+
+	var id int
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		sql.Named("report", snapshot.Report),
+		sql.Named("report_name", snapshot.ReportName),
+		sql.Named("parameters", snapshot.Parameters),
+		sql.Named("data", snapshot.Data),
+		sql.Named("row_count", snapshot.RowCount),
+		sql.Named("checksum", snapshot.Checksum),
+		sql.Named("notes", snapshot.Notes),
+		sql.Named("created_by", snapshot.CreatedBy),
+		sql.Named("created_at", now),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error creating report snapshot: %w", err)
+	}
+
+	snapshot.ID = id
+	snapshot.CreatedAt = now
+	return id, nil
+}
+
+// List gets the latest snapshots without their data, optionally of one report
+func (r *reportSnapshotRepository) List(ctx context.Context, report string, limit int) ([]*models.ReportSnapshot, error) {
+	query := `
+        SELECT TOP (@limit) id, report, report_name, parameters, row_count, checksum, ISNULL(notes, ''), created_by, created_at
+        FROM report_snapshots
+        WHERE @report = '' OR report = @report
+        ORDER BY created_at DESC, id DESC
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, sql.Named("report", report), sql.Named("limit", limit))
+	if err != nil {
+		return nil, fmt.Errorf("error getting report snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []*models.ReportSnapshot
+	for rows.Next() {
+		var snapshot models.ReportSnapshot
+		if err := rows.Scan(
+			&snapshot.ID,
+			&snapshot.Report,
+			&snapshot.ReportName,
+			&snapshot.Parameters,
+			&snapshot.RowCount,
+			&snapshot.Checksum,
+			&snapshot.Notes,
+			&snapshot.CreatedBy,
+			&snapshot.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning report snapshot: %w", err)
+		}
+		snapshots = append(snapshots, &snapshot)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating report snapshots: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+// GetByID gets a snapshot including its data
+func (r *reportSnapshotRepository) GetByID(ctx context.Context, id int) (*models.ReportSnapshot, error) {
+	query := `
+        SELECT id, report, report_name, parameters, data, row_count, checksum, ISNULL(notes, ''), created_by, created_at
+        FROM report_snapshots
+        WHERE id = @id
+    `
+
+	var snapshot models.ReportSnapshot
+	err := r.db.QueryRowContext(ctx, query, sql.Named("id", id)).Scan(
+		&snapshot.ID,
+		&snapshot.Report,
+		&snapshot.ReportName,
+		&snapshot.Parameters,
+		&snapshot.Data,
+		&snapshot.RowCount,
+		&snapshot.Checksum,
+		&snapshot.Notes,
+		&snapshot.CreatedBy,
+		&snapshot.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("report snapshot not found: %w", err)
+		}
+		return nil, fmt.Errorf("error getting report snapshot: %w", err)
+	}
+
+	return &snapshot, nil
+}
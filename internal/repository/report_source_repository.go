@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/database"
+	"erp-excel/internal/models"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// ReportSourceRepository executes the source of a report definition against the ERP
+type ReportSourceRepository interface {
+	Execute(ctx context.Context, definition *models.ReportDefinition, params []sql.NamedArg, limit int) (*models.ReportResult, error)
+}
+
+// sqlIdentifierPattern matches a plain or schema-qualified name, it is put into the SQL text
+var sqlIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*){0,2}$`)
+
+// ValidSQLIdentifier reports whether name can be safely used as a procedure or parameter name
+func ValidSQLIdentifier(name string) bool {
+	return sqlIdentifierPattern.MatchString(name)
+}
+
+type reportSourceRepository struct {
+	erp     ERPPool
+	queries ERPPool // custom SQL reports, which run as reports.query_login
+	logger  *slog.Logger
+}
+
+// NewReportSourceRepository creates a new report source repository. Stored procedures run as the
+// ERP login, custom SQL reports as the read-only login of reports.query_login.
+func NewReportSourceRepository(db database.Database, logger *slog.Logger) ReportSourceRepository {
+	return &reportSourceRepository{
+		erp:     db,
+		queries: reportQueryPool{db: db},
+		logger:  logger,
+	}
+}
+
+// Execute runs the report source with named parameters and returns its first result set keyed by
+// column name. When limit is positive at most limit rows are read.
+func (r *reportSourceRepository) Execute(
+	ctx context.Context,
+	definition *models.ReportDefinition,
+	params []sql.NamedArg,
+	limit int,
+) (*models.ReportResult, error) {
+	switch definition.SourceType {
+	case models.ReportSourceProcedure:
+		return r.executeProcedure(ctx, definition, params, limit)
+	case models.ReportSourceQuery:
+		return r.executeQuery(ctx, definition, params, limit)
+	default:
+		return nil, fmt.Errorf("unsupported report source type %q", definition.SourceType)
+	}
+}
+
+// executeProcedure runs a stored procedure with its parameters assigned by name
+func (r *reportSourceRepository) executeProcedure(
+	ctx context.Context,
+	definition *models.ReportDefinition,
+	params []sql.NamedArg,
+	limit int,
+) (*models.ReportResult, error) {
+	if !ValidSQLIdentifier(definition.Source) {
+		return nil, fmt.Errorf("invalid procedure name %q", definition.Source)
+	}
+	assignments := make([]string, len(params))
+	for i, param := range params {
+		if !ValidSQLIdentifier(param.Name) || strings.Contains(param.Name, ".") {
+			return nil, fmt.Errorf("invalid parameter name %q", param.Name)
+		}
+		assignments[i] = fmt.Sprintf("@%s = @%s", param.Name, param.Name)
+	}
+	query := "EXEC " + definition.Source + " " + strings.Join(assignments, ", ")
+
+	erpDB, err := r.erp.ERPDatabaseFor(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting ERP database: %w", err)
+	}
+
+	r.logger.DebugContext(ctx, "Executing report procedure", "report", definition.Code, "query", query)
+
+	rows, err := erpDB.QueryContext(ctx, query, namedArgs(params)...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing report %s: %w", definition.Code, err)
+	}
+	defer rows.Close()
+
+	result, err := scanReportResult(rows, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error reading report %s: %w", definition.Code, err)
+	}
+
+	return result, nil
+}
+
+// executeQuery runs an admin-defined SELECT template. The query was checked against the deny-list
+// when it was registered; it also runs as the read-only login, inside a transaction that is
+// always rolled back, so a statement slipping through cannot leave changes behind.
+func (r *reportSourceRepository) executeQuery(
+	ctx context.Context,
+	definition *models.ReportDefinition,
+	params []sql.NamedArg,
+	limit int,
+) (*models.ReportResult, error) {
+	erpDB, err := r.queries.ERPDatabaseFor(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting ERP database: %w", err)
+	}
+	tx, err := erpDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	r.logger.DebugContext(ctx, "Executing report query", "report", definition.Code)
+
+	rows, err := tx.QueryContext(ctx, definition.Source, namedArgs(params)...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing report %s: %w", definition.Code, err)
+	}
+	defer rows.Close()
+
+	result, err := scanReportResult(rows, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error reading report %s: %w", definition.Code, err)
+	}
+
+	return result, nil
+}
+
+// namedArgs converts named arguments to query arguments
+func namedArgs(params []sql.NamedArg) []interface{} {
+	args := make([]interface{}, len(params))
+	for i, param := range params {
+		args[i] = param
+	}
+	return args
+}
+
+// scanReportResult reads rows into maps keyed by column name, stopping after limit rows when limit is positive
+func scanReportResult(rows *sql.Rows, limit int) (*models.ReportResult, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ReportResult{Columns: columns}
+	for rows.Next() {
+		if limit > 0 && len(result.Rows) == limit {
+			result.Truncated = true
+			return result, nil
+		}
+
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		item := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			// DECIMAL and MONEY come back as raw bytes
+			if b, ok := values[i].([]byte); ok {
+				item[column] = string(b)
+			} else {
+				item[column] = values[i]
+			}
+		}
+		result.Rows = append(result.Rows, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
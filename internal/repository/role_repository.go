@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"erp-excel/internal/fieldcrypt"
 	"erp-excel/internal/models"
 	"fmt"
 	"time"
@@ -19,26 +20,70 @@ type RoleRepository interface {
 	GetOperations(ctx context.Context, roleID int) ([]*models.Operation, error)
 	AssignOperations(ctx context.Context, roleID int, operationIDs []int) error
 	RemoveOperations(ctx context.Context, roleID int, operationIDs []int) error
+	GrantOperation(ctx context.Context, operationID int, roleIDs []int) error
+	ListUsers(ctx context.Context, roleID int, limit, offset int) ([]*models.User, error)
+	CountUsers(ctx context.Context, roleID int) (int, error)
+	GetUserCounts(ctx context.Context) (map[int]int, error)
+	ListUsersWithOperation(ctx context.Context, operationCode string, departmentIDs []int) ([]*models.User, error)
 	CheckUserOperationAccess(ctx context.Context, userID int, operationID int) (bool, error)
+	GetAncestorIDs(ctx context.Context, roleID int) ([]int, error)
+	WithTx(tx *sql.Tx) RoleRepository
+	GetUserOperationCodes(ctx context.Context, userID int) ([]string, error)
+	GetUserRoleIDs(ctx context.Context, userID int) ([]int, error)
+	ListDeleted(ctx context.Context) ([]*models.Role, error)
+	Restore(ctx context.Context, id int) error
+	Search(ctx context.Context, text string, limit int) ([]*models.Role, error)
 }
 
 type roleRepository struct {
-	db *sql.DB
+	db     DBTX
+	reads  ReadPool
+	cipher *fieldcrypt.Cipher
 }
 
-// NewRoleRepository creates a new role repository
-func NewRoleRepository(db *sql.DB) RoleRepository {
+// NewRoleRepository creates a new role repository; the cipher decrypts the emails of the users it
+// lists and GetByID, List and Count read from the read pool, as in NewUserRepository
+func NewRoleRepository(db *sql.DB, reads ReadPool, cipher *fieldcrypt.Cipher) RoleRepository {
 	return &roleRepository{
-		db: db,
+		db:     db,
+		reads:  reads,
+		cipher: cipher,
 	}
 }
 
+// WithTx returns a role repository that runs in the given transaction
+func (r *roleRepository) WithTx(tx *sql.Tx) RoleRepository {
+	return &roleRepository{
+		db:     tx,
+		cipher: r.cipher,
+	}
+}
+
+// maxRoleDepth bounds the walk up the role hierarchy, so a cycle left in the data cannot loop forever
+const maxRoleDepth = 32
+
+// userRolesCTE resolves the roles of @user_id together with every role they inherit from. Deleted
+// roles are skipped and so are the roles above them.
+const userRolesCTE = `
+        WITH effective_roles AS (
+            SELECT r.id, r.parent_role_id, 0 AS depth
+            FROM user_roles ur
+            JOIN roles r ON ur.role_id = r.id
+            WHERE ur.user_id = @user_id AND r.deleted_at IS NULL
+            UNION ALL
+            SELECT p.id, p.parent_role_id, er.depth + 1
+            FROM effective_roles er
+            JOIN roles p ON p.id = er.parent_role_id
+            WHERE p.deleted_at IS NULL AND er.depth < @max_depth
+        )
+`
+
 // Create adds a new role
 func (r *roleRepository) Create(ctx context.Context, role *models.Role) (*models.Role, error) {
 	query := `  
-        INSERT INTO roles (name, description, created_at, updated_at)  
+        INSERT INTO roles (name, description, parent_role_id, created_at, updated_at)  
         OUTPUT INSERTED.id  
-        VALUES (@name, @description, @created_at, @updated_at)  
+        VALUES (@name, @description, @parent_role_id, @created_at, @updated_at)  
     `
 
 	var id int
@@ -47,6 +92,7 @@ func (r *roleRepository) Create(ctx context.Context, role *models.Role) (*models
 		query,
 		sql.Named("name", role.Name),
 		sql.Named("description", role.Description),
+		sql.Named("parent_role_id", nullIntPtr(role.ParentRoleID)),
 		sql.Named("created_at", time.Now()),
 		sql.Named("updated_at", time.Now()),
 	).Scan(&id)
@@ -62,16 +108,18 @@ func (r *roleRepository) Create(ctx context.Context, role *models.Role) (*models
 // GetByID gets a role by ID
 func (r *roleRepository) GetByID(ctx context.Context, id int) (*models.Role, error) {
 	query := `  
-        SELECT id, name, description, created_at, updated_at  
+        SELECT id, name, description, parent_role_id, created_at, updated_at  
         FROM roles  
-        WHERE id = @id  
+        WHERE id = @id AND deleted_at IS NULL
     `
 
 	var role models.Role
-	err := r.db.QueryRowContext(ctx, query, sql.Named("id", id)).Scan(
+	var parentRoleID sql.NullInt64
+	err := reader(ctx, r.db, r.reads).QueryRowContext(ctx, query, sql.Named("id", id)).Scan(
 		&role.ID,
 		&role.Name,
 		&role.Description,
+		&parentRoleID,
 		&role.CreatedAt,
 		&role.UpdatedAt,
 	)
@@ -82,6 +130,7 @@ func (r *roleRepository) GetByID(ctx context.Context, id int) (*models.Role, err
 		}
 		return nil, fmt.Errorf("error getting role: %w", err)
 	}
+	role.ParentRoleID = intPtr(parentRoleID)
 
 	// Get operations for this role
 	operations, err := r.GetOperations(ctx, role.ID)
@@ -99,8 +148,9 @@ func (r *roleRepository) Update(ctx context.Context, role *models.Role) error {
         UPDATE roles  
         SET name = @name,  
             description = @description,  
+            parent_role_id = @parent_role_id,  
             updated_at = @updated_at  
-        WHERE id = @id  
+        WHERE id = @id AND deleted_at IS NULL
     `
 
 	_, err := r.db.ExecContext(
@@ -108,6 +158,7 @@ func (r *roleRepository) Update(ctx context.Context, role *models.Role) error {
 		query,
 		sql.Named("name", role.Name),
 		sql.Named("description", role.Description),
+		sql.Named("parent_role_id", nullIntPtr(role.ParentRoleID)),
 		sql.Named("updated_at", time.Now()),
 		sql.Named("id", role.ID),
 	)
@@ -119,51 +170,85 @@ func (r *roleRepository) Update(ctx context.Context, role *models.Role) error {
 	return nil
 }
 
-// Delete deletes a role
+// Delete soft deletes a role. Its operations and members are kept so a restore brings them back,
+// but a deleted role no longer grants access.
 func (r *roleRepository) Delete(ctx context.Context, id int) error {
-	// Start a transaction
-	tx, err := r.db.BeginTx(ctx, nil)
+	query := `
+        UPDATE roles
+        SET deleted_at = @now,
+            updated_at = @now
+        WHERE id = @id AND deleted_at IS NULL
+    `
+
+	result, err := r.db.ExecContext(ctx, query, sql.Named("now", time.Now()), sql.Named("id", id))
 	if err != nil {
-		return fmt.Errorf("error beginning transaction: %w", err)
+		return fmt.Errorf("error deleting role: %w", err)
 	}
-	defer tx.Rollback()
 
-	// Delete role_operations first
-	_, err = tx.ExecContext(
-		ctx,
-		"DELETE FROM role_operations WHERE role_id = @role_id",
-		sql.Named("role_id", id),
-	)
+	return checkAffected(result, "role")
+}
+
+// Restore brings a soft deleted role back
+func (r *roleRepository) Restore(ctx context.Context, id int) error {
+	query := `
+        UPDATE roles
+        SET deleted_at = NULL,
+            updated_at = @now
+        WHERE id = @id AND deleted_at IS NOT NULL
+    `
+
+	result, err := r.db.ExecContext(ctx, query, sql.Named("now", time.Now()), sql.Named("id", id))
 	if err != nil {
-		return fmt.Errorf("error deleting role operations: %w", err)
+		return fmt.Errorf("error restoring role: %w", err)
 	}
 
-	// Delete user_roles
-	_, err = tx.ExecContext(
-		ctx,
-		"DELETE FROM user_roles WHERE role_id = @role_id",
-		sql.Named("role_id", id),
-	)
+	return checkAffected(result, "deleted role")
+}
+
+// ListDeleted gets the soft deleted roles, most recently deleted first
+func (r *roleRepository) ListDeleted(ctx context.Context) ([]*models.Role, error) {
+	query := `
+        SELECT id, name, description, parent_role_id, created_at, updated_at, deleted_at
+        FROM roles
+        WHERE deleted_at IS NOT NULL
+        ORDER BY deleted_at DESC
+    `
+
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
-		return fmt.Errorf("error deleting user roles: %w", err)
+		return nil, fmt.Errorf("error listing deleted roles: %w", err)
 	}
+	defer rows.Close()
 
-	// Delete role
-	_, err = tx.ExecContext(
-		ctx,
-		"DELETE FROM roles WHERE id = @id",
-		sql.Named("id", id),
-	)
-	if err != nil {
-		return fmt.Errorf("error deleting role: %w", err)
+	var roles []*models.Role
+	for rows.Next() {
+		var role models.Role
+		var parentRoleID sql.NullInt64
+		var deletedAt time.Time
+
+		err := rows.Scan(
+			&role.ID,
+			&role.Name,
+			&role.Description,
+			&parentRoleID,
+			&role.CreatedAt,
+			&role.UpdatedAt,
+			&deletedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning role: %w", err)
+		}
+
+		role.ParentRoleID = intPtr(parentRoleID)
+		role.DeletedAt = &deletedAt
+		roles = append(roles, &role)
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %w", err)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating roles: %w", err)
 	}
 
-	return nil
+	return roles, nil
 }
 
 // List gets a list of roles
@@ -175,15 +260,17 @@ func (r *roleRepository) List(ctx context.Context, limit, offset int) ([]*models
                 id, 
                 name, 
                 description, 
+                parent_role_id,
                 created_at, 
                 updated_at,
                 ROW_NUMBER() OVER (ORDER BY name) AS RowNum
             FROM roles
+            WHERE deleted_at IS NULL
         ) AS RolesWithRowNumbers
         WHERE RowNum BETWEEN @offset + 1 AND @offset + @limit
     `
 
-	rows, err := r.db.QueryContext(
+	rows, err := reader(ctx, r.db, r.reads).QueryContext(
 		ctx,
 		query,
 		sql.Named("limit", limit),
@@ -197,12 +284,14 @@ func (r *roleRepository) List(ctx context.Context, limit, offset int) ([]*models
 	var roles []*models.Role
 	for rows.Next() {
 		var role models.Role
+		var parentRoleID sql.NullInt64
 		var rowNum int // Thêm biến để scan row number
 
 		err := rows.Scan(
 			&role.ID,
 			&role.Name,
 			&role.Description,
+			&parentRoleID,
 			&role.CreatedAt,
 			&role.UpdatedAt,
 			&rowNum, // Scan row number
@@ -211,6 +300,44 @@ func (r *roleRepository) List(ctx context.Context, limit, offset int) ([]*models
 			return nil, fmt.Errorf("error scanning role: %w", err)
 		}
 
+		role.ParentRoleID = intPtr(parentRoleID)
+		roles = append(roles, &role)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating roles: %w", err)
+	}
+
+	return roles, nil
+}
+
+// Search finds roles whose name or description contains the text
+func (r *roleRepository) Search(ctx context.Context, text string, limit int) ([]*models.Role, error) {
+	query := `
+        SELECT TOP (@limit) id, name, description
+        FROM roles
+        WHERE deleted_at IS NULL
+          AND (name LIKE @pattern ESCAPE '\' OR description LIKE @pattern ESCAPE '\')
+        ORDER BY name
+    `
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		query,
+		sql.Named("limit", clampSearchLimit(limit)),
+		sql.Named("pattern", containsPattern(text)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error searching roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []*models.Role
+	for rows.Next() {
+		var role models.Role
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description); err != nil {
+			return nil, fmt.Errorf("error scanning role: %w", err)
+		}
 		roles = append(roles, &role)
 	}
 
@@ -224,7 +351,7 @@ func (r *roleRepository) List(ctx context.Context, limit, offset int) ([]*models
 // Count gets the total number of roles
 func (r *roleRepository) Count(ctx context.Context) (int, error) {
 	var count int
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM roles").Scan(&count)
+	err := reader(ctx, r.db, r.reads).QueryRowContext(ctx, "SELECT COUNT(*) FROM roles WHERE deleted_at IS NULL").Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("error counting roles: %w", err)
 	}
@@ -272,45 +399,35 @@ func (r *roleRepository) GetOperations(ctx context.Context, roleID int) ([]*mode
 	return operations, nil
 }
 
-// AssignOperations assigns operations to a role
+// AssignOperations replaces the operations of a role
 func (r *roleRepository) AssignOperations(ctx context.Context, roleID int, operationIDs []int) error {
-	// Start a transaction
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("error beginning transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	// Delete existing operations first
-	_, err = tx.ExecContext(
-		ctx,
-		"DELETE FROM role_operations WHERE role_id = @role_id",
-		sql.Named("role_id", roleID),
-	)
-	if err != nil {
-		return fmt.Errorf("error deleting existing operations: %w", err)
-	}
-
-	// Insert new operations
-	for _, operationID := range operationIDs {
-		_, err = tx.ExecContext(
+	return runInTx(ctx, r.db, func(tx DBTX) error {
+		// Delete existing operations first
+		_, err := tx.ExecContext(
 			ctx,
-			"INSERT INTO role_operations (role_id, operation_id, can_access, created_at) VALUES (@role_id, @operation_id, 1, @created_at)",
+			"DELETE FROM role_operations WHERE role_id = @role_id",
 			sql.Named("role_id", roleID),
-			sql.Named("operation_id", operationID),
-			sql.Named("created_at", time.Now()),
 		)
 		if err != nil {
-			return fmt.Errorf("error assigning operation: %w", err)
+			return fmt.Errorf("error deleting existing operations: %w", err)
 		}
-	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %w", err)
-	}
+		// Insert new operations
+		for _, operationID := range operationIDs {
+			_, err = tx.ExecContext(
+				ctx,
+				"INSERT INTO role_operations (role_id, operation_id, can_access, created_at) VALUES (@role_id, @operation_id, 1, @created_at)",
+				sql.Named("role_id", roleID),
+				sql.Named("operation_id", operationID),
+				sql.Named("created_at", time.Now()),
+			)
+			if err != nil {
+				return fmt.Errorf("error assigning operation: %w", err)
+			}
+		}
 
-	return nil
+		return nil
+	})
 }
 
 // RemoveOperations removes operations from a role
@@ -341,14 +458,211 @@ func (r *roleRepository) RemoveOperations(ctx context.Context, roleID int, opera
 	return nil
 }
 
-// CheckUserOperationAccess checks if a user has access to an operation
-func (r *roleRepository) CheckUserOperationAccess(ctx context.Context, userID int, operationID int) (bool, error) {
+// GrantOperation adds an operation to each of the roles, keeping the operations they already have
+func (r *roleRepository) GrantOperation(ctx context.Context, operationID int, roleIDs []int) error {
+	return runInTx(ctx, r.db, func(tx DBTX) error {
+		for _, roleID := range roleIDs {
+			_, err := tx.ExecContext(
+				ctx,
+				`IF NOT EXISTS (SELECT 1 FROM role_operations WHERE role_id = @role_id AND operation_id = @operation_id)
+                INSERT INTO role_operations (role_id, operation_id, can_access, created_at) VALUES (@role_id, @operation_id, 1, @created_at)
+                ELSE UPDATE role_operations SET can_access = 1 WHERE role_id = @role_id AND operation_id = @operation_id`,
+				sql.Named("role_id", roleID),
+				sql.Named("operation_id", operationID),
+				sql.Named("created_at", time.Now()),
+			)
+			if err != nil {
+				return fmt.Errorf("error granting operation to role %d: %w", roleID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ListUsers gets a page of the users that hold the role directly, leaving out deleted users
+func (r *roleRepository) ListUsers(ctx context.Context, roleID int, limit, offset int) ([]*models.User, error) {
 	query := `
-        SELECT COUNT(*) 
+        SELECT u.id, u.username, u.full_name, u.email, u.department_id,
+               u.is_active, u.created_at, u.updated_at,
+               d.name AS department_name
         FROM user_roles ur
-        JOIN role_operations ro ON ur.role_id = ro.role_id
-        WHERE ur.user_id = @user_id 
-          AND ro.operation_id = @operation_id 
+        JOIN users u ON ur.user_id = u.id
+        LEFT JOIN departments d ON u.department_id = d.id
+        WHERE ur.role_id = @role_id AND u.deleted_at IS NULL
+        ORDER BY u.username
+        OFFSET @offset ROWS
+        FETCH NEXT @limit ROWS ONLY
+    `
+
+	rows, err := reader(ctx, r.db, r.reads).QueryContext(
+		ctx,
+		query,
+		sql.Named("role_id", roleID),
+		sql.Named("limit", limit),
+		sql.Named("offset", offset),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error listing role users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		var user models.User
+		var departmentName sql.NullString
+
+		err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.FullName,
+			&user.Email,
+			&user.DepartmentID,
+			&user.IsActive,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+			&departmentName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning user: %w", err)
+		}
+		if err := decryptUser(r.cipher, &user); err != nil {
+			return nil, err
+		}
+
+		user.Department = &models.Department{ID: user.DepartmentID, Name: departmentName.String}
+		users = append(users, &user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating role users: %w", err)
+	}
+
+	return users, nil
+}
+
+// CountUsers gets the number of users that hold the role directly, leaving out deleted users
+func (r *roleRepository) CountUsers(ctx context.Context, roleID int) (int, error) {
+	query := `
+        SELECT COUNT(*)
+        FROM user_roles ur
+        JOIN users u ON ur.user_id = u.id
+        WHERE ur.role_id = @role_id AND u.deleted_at IS NULL
+    `
+
+	var count int
+	if err := reader(ctx, r.db, r.reads).QueryRowContext(ctx, query, sql.Named("role_id", roleID)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting role users: %w", err)
+	}
+	return count, nil
+}
+
+// GetUserCounts gets the number of users that hold every role that has any directly, leaving out
+// deleted users, by role ID
+func (r *roleRepository) GetUserCounts(ctx context.Context) (map[int]int, error) {
+	query := `
+        SELECT ur.role_id, COUNT(*)
+        FROM user_roles ur
+        JOIN users u ON ur.user_id = u.id
+        WHERE u.deleted_at IS NULL
+        GROUP BY ur.role_id
+    `
+
+	rows, err := reader(ctx, r.db, r.reads).QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error counting users per role: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var roleID, count int
+		if err := rows.Scan(&roleID, &count); err != nil {
+			return nil, fmt.Errorf("error scanning role user count: %w", err)
+		}
+		counts[roleID] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating role user counts: %w", err)
+	}
+
+	return counts, nil
+}
+
+// ListUsersWithOperation gets the active users of the departments that hold the operation through
+// their roles or the roles they inherit from
+func (r *roleRepository) ListUsersWithOperation(ctx context.Context, operationCode string, departmentIDs []int) ([]*models.User, error) {
+	if len(departmentIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `
+        WITH granting_roles AS (
+            SELECT r.id, 0 AS depth
+            FROM roles r
+            JOIN role_operations ro ON ro.role_id = r.id AND ro.can_access = 1
+            JOIN operations o ON o.id = ro.operation_id
+            WHERE o.code = @operation_code AND r.deleted_at IS NULL
+            UNION ALL
+            SELECT c.id, g.depth + 1
+            FROM granting_roles g
+            JOIN roles c ON c.parent_role_id = g.id
+            WHERE c.deleted_at IS NULL AND g.depth < @max_depth
+        )
+        SELECT u.id, u.username, u.full_name, u.email, u.department_id
+        FROM users u
+        WHERE u.is_active = 1 AND u.deleted_at IS NULL
+          AND EXISTS (
+              SELECT 1 FROM user_roles ur
+              JOIN granting_roles g ON g.id = ur.role_id
+              WHERE ur.user_id = u.id
+          )
+          AND u.department_id IN (`
+
+	params := []interface{}{sql.Named("operation_code", operationCode), sql.Named("max_depth", maxRoleDepth)}
+	for i, departmentID := range departmentIDs {
+		if i > 0 {
+			query += ", "
+		}
+		paramName := fmt.Sprintf("department_id_%d", i)
+		query += "@" + paramName
+		params = append(params, sql.Named(paramName, departmentID))
+	}
+	query += ")\n        ORDER BY u.username"
+
+	rows, err := r.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing users with operation: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.FullName, &user.Email, &user.DepartmentID); err != nil {
+			return nil, fmt.Errorf("error scanning user: %w", err)
+		}
+		if err := decryptUser(r.cipher, &user); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating users with operation: %w", err)
+	}
+
+	return users, nil
+}
+
+// CheckUserOperationAccess checks if a user has access to an operation through their roles or
+// the roles they inherit from
+func (r *roleRepository) CheckUserOperationAccess(ctx context.Context, userID int, operationID int) (bool, error) {
+	query := userRolesCTE + `
+        SELECT COUNT(*)
+        FROM role_operations ro
+        WHERE ro.role_id IN (SELECT id FROM effective_roles)
+          AND ro.operation_id = @operation_id
           AND ro.can_access = 1
     `
 
@@ -358,6 +672,7 @@ func (r *roleRepository) CheckUserOperationAccess(ctx context.Context, userID in
 		query,
 		sql.Named("user_id", userID),
 		sql.Named("operation_id", operationID),
+		sql.Named("max_depth", maxRoleDepth),
 	).Scan(&count)
 
 	if err != nil {
@@ -366,3 +681,120 @@ func (r *roleRepository) CheckUserOperationAccess(ctx context.Context, userID in
 
 	return count > 0, nil
 }
+
+// GetUserOperationCodes gets the codes of the operations a user can access through any of their
+// roles, inherited ones included
+func (r *roleRepository) GetUserOperationCodes(ctx context.Context, userID int) ([]string, error) {
+	query := userRolesCTE + `
+        SELECT DISTINCT o.code
+        FROM role_operations ro
+        JOIN operations o ON ro.operation_id = o.id
+        WHERE ro.role_id IN (SELECT id FROM effective_roles)
+          AND ro.can_access = 1
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, sql.Named("user_id", userID), sql.Named("max_depth", maxRoleDepth))
+	if err != nil {
+		return nil, fmt.Errorf("error getting user operations: %w", err)
+	}
+	defer rows.Close()
+
+	var codes []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, fmt.Errorf("error scanning operation code: %w", err)
+		}
+		codes = append(codes, code)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating operation codes: %w", err)
+	}
+
+	return codes, nil
+}
+
+// GetUserRoleIDs gets the IDs of the roles of a user together with the roles they inherit from
+func (r *roleRepository) GetUserRoleIDs(ctx context.Context, userID int) ([]int, error) {
+	query := userRolesCTE + `
+        SELECT DISTINCT id FROM effective_roles
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, sql.Named("user_id", userID), sql.Named("max_depth", maxRoleDepth))
+	if err != nil {
+		return nil, fmt.Errorf("error getting user roles: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning user role: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating user roles: %w", err)
+	}
+
+	return ids, nil
+}
+
+// GetAncestorIDs gets the IDs of the roles a role inherits from, nearest first. Deleted roles are
+// included, since restoring them brings the inheritance back.
+func (r *roleRepository) GetAncestorIDs(ctx context.Context, roleID int) ([]int, error) {
+	query := `
+        WITH ancestors AS (
+            SELECT parent_role_id AS id, 1 AS depth
+            FROM roles
+            WHERE id = @role_id AND parent_role_id IS NOT NULL
+            UNION ALL
+            SELECT r.parent_role_id, a.depth + 1
+            FROM ancestors a
+            JOIN roles r ON r.id = a.id
+            WHERE r.parent_role_id IS NOT NULL AND a.depth < @max_depth
+        )
+        SELECT id FROM ancestors ORDER BY depth
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, sql.Named("role_id", roleID), sql.Named("max_depth", maxRoleDepth))
+	if err != nil {
+		return nil, fmt.Errorf("error getting role ancestors: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning role ancestor: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating role ancestors: %w", err)
+	}
+
+	return ids, nil
+}
+
+// nullIntPtr converts an optional ID to a nullable query parameter
+func nullIntPtr(value *int) sql.NullInt64 {
+	if value == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*value), Valid: true}
+}
+
+// intPtr converts a nullable column to an optional ID
+func intPtr(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	id := int(value.Int64)
+	return &id
+}
@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"erp-excel/internal/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RowPolicyRepository stores the row policies of the reports
+type RowPolicyRepository interface {
+	List(ctx context.Context, operationCode string) ([]*models.RowPolicy, error)
+	ListActive(ctx context.Context, operationCodes []string) ([]*models.RowPolicy, error)
+	GetByID(ctx context.Context, id int) (*models.RowPolicy, error)
+	Create(ctx context.Context, policy *models.RowPolicy) (int, error)
+	Update(ctx context.Context, policy *models.RowPolicy) error
+	Delete(ctx context.Context, id int) error
+}
+
+type rowPolicyRepository struct {
+	db *sql.DB
+}
+
+// NewRowPolicyRepository creates a new row policy repository
+func NewRowPolicyRepository(db *sql.DB) RowPolicyRepository {
+	return &rowPolicyRepository{
+		db: db,
+	}
+}
+
+const rowPolicyColumns = `id, name, ISNULL(description, ''), operation_code, role_id, attribute, operator, policy_values,
+    is_active, created_by, created_at, updated_at`
+
+// List gets the policies by operation and name, only those of the operation when one is given
+func (r *rowPolicyRepository) List(ctx context.Context, operationCode string) ([]*models.RowPolicy, error) {
+	query := `
+        SELECT ` + rowPolicyColumns + `
+        FROM row_policies
+        WHERE @operation_code = '' OR operation_code = @operation_code
+        ORDER BY operation_code, name, id
+    `
+	return r.query(ctx, query, sql.Named("operation_code", operationCode))
+}
+
+// ListActive gets the active policies of any of the operations
+func (r *rowPolicyRepository) ListActive(ctx context.Context, operationCodes []string) ([]*models.RowPolicy, error) {
+	if len(operationCodes) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(operationCodes))
+	args := make([]interface{}, 0, len(operationCodes))
+	for i, code := range operationCodes {
+		name := fmt.Sprintf("op%d", i)
+		names = append(names, "@"+name)
+		args = append(args, sql.Named(name, code))
+	}
+
+	query := `
+        SELECT ` + rowPolicyColumns + `
+        FROM row_policies
+        WHERE is_active = 1 AND operation_code IN (` + strings.Join(names, ", ") + `)
+        ORDER BY id
+    `
+	return r.query(ctx, query, args...)
+}
+
+// GetByID gets a policy by ID
+func (r *rowPolicyRepository) GetByID(ctx context.Context, id int) (*models.RowPolicy, error) {
+	query := `SELECT ` + rowPolicyColumns + ` FROM row_policies WHERE id = @id`
+	policy, err := scanRowPolicy(r.db.QueryRowContext(ctx, query, sql.Named("id", id)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("row policy not found: %w", err)
+		}
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Create stores a new policy
+func (r *rowPolicyRepository) Create(ctx context.Context, policy *models.RowPolicy) (int, error) {
+	values, err := json.Marshal(policy.Values)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding row policy values: %w", err)
+	}
+
+	now := time.Now()
+	query := `
+        INSERT INTO row_policies (name, description, operation_code, role_id, attribute, operator, policy_values,
+            is_active, created_by, created_at, updated_at)
+        OUTPUT INSERTED.id
+        VALUES (@name, @description, @operation_code, @role_id, @attribute, @operator, @policy_values,
+            @is_active, @created_by, @now, @now)
+    `
+
+	var id int
+	err = r.db.QueryRowContext(
+		ctx,
+		query,
+		sql.Named("name", policy.Name),
+		sql.Named("description", policy.Description),
+		sql.Named("operation_code", policy.OperationCode),
+		sql.Named("role_id", nullIntPtr(policy.RoleID)),
+		sql.Named("attribute", policy.Attribute),
+		sql.Named("operator", policy.Operator),
+		sql.Named("policy_values", string(values)),
+		sql.Named("is_active", policy.IsActive),
+		sql.Named("created_by", policy.CreatedBy),
+		sql.Named("now", now),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error creating row policy: %w", err)
+	}
+
+	policy.ID = id
+	policy.CreatedAt = now
+	policy.UpdatedAt = now
+	return id, nil
+}
+
+// Update saves every field of a policy but its creator
+func (r *rowPolicyRepository) Update(ctx context.Context, policy *models.RowPolicy) error {
+	values, err := json.Marshal(policy.Values)
+	if err != nil {
+		return fmt.Errorf("error encoding row policy values: %w", err)
+	}
+
+	now := time.Now()
+	query := `
+        UPDATE row_policies
+        SET name = @name, description = @description, operation_code = @operation_code, role_id = @role_id,
+            attribute = @attribute, operator = @operator, policy_values = @policy_values,
+            is_active = @is_active, updated_at = @now
+        WHERE id = @id
+    `
+
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("id", policy.ID),
+		sql.Named("name", policy.Name),
+		sql.Named("description", policy.Description),
+		sql.Named("operation_code", policy.OperationCode),
+		sql.Named("role_id", nullIntPtr(policy.RoleID)),
+		sql.Named("attribute", policy.Attribute),
+		sql.Named("operator", policy.Operator),
+		sql.Named("policy_values", string(values)),
+		sql.Named("is_active", policy.IsActive),
+		sql.Named("now", now),
+	)
+	if err != nil {
+		return fmt.Errorf("error updating row policy: %w", err)
+	}
+	if err := checkAffected(result, "row policy"); err != nil {
+		return err
+	}
+
+	policy.UpdatedAt = now
+	return nil
+}
+
+// Delete removes a policy
+func (r *rowPolicyRepository) Delete(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM row_policies WHERE id = @id`, sql.Named("id", id))
+	if err != nil {
+		return fmt.Errorf("error deleting row policy: %w", err)
+	}
+	return checkAffected(result, "row policy")
+}
+
+// query runs a query returning policy rows
+func (r *rowPolicyRepository) query(ctx context.Context, query string, args ...interface{}) ([]*models.RowPolicy, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting row policies: %w", err)
+	}
+	defer rows.Close()
+
+	policies := []*models.RowPolicy{}
+	for rows.Next() {
+		policy, err := scanRowPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating row policies: %w", err)
+	}
+
+	return policies, nil
+}
+
+// scanRowPolicy scans one policy row, returning sql.ErrNoRows as is
+func scanRowPolicy(row rowScanner) (*models.RowPolicy, error) {
+	var policy models.RowPolicy
+	var roleID sql.NullInt64
+	var values string
+	err := row.Scan(
+		&policy.ID,
+		&policy.Name,
+		&policy.Description,
+		&policy.OperationCode,
+		&roleID,
+		&policy.Attribute,
+		&policy.Operator,
+		&values,
+		&policy.IsActive,
+		&policy.CreatedBy,
+		&policy.CreatedAt,
+		&policy.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error scanning row policy: %w", err)
+	}
+
+	if roleID.Valid {
+		id := int(roleID.Int64)
+		policy.RoleID = &id
+	}
+	if err := json.Unmarshal([]byte(values), &policy.Values); err != nil {
+		return nil, fmt.Errorf("error decoding values of row policy %d: %w", policy.ID, err)
+	}
+	return &policy, nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// SchemaRepository reads the catalog of a database, used to verify that it has what the queries need
+type SchemaRepository interface {
+	DatabaseName(ctx context.Context) (string, error)
+	Columns(ctx context.Context, tables []string) (map[string]map[string]bool, error)
+	ProcedureExists(ctx context.Context, name string) (bool, error)
+}
+
+type schemaRepository struct {
+	db *sql.DB
+}
+
+// NewSchemaRepository creates a new schema repository for the app or the ERP database
+func NewSchemaRepository(db *sql.DB) SchemaRepository {
+	return &schemaRepository{
+		db: db,
+	}
+}
+
+// DatabaseName returns the name of the database the connection points at
+func (r *schemaRepository) DatabaseName(ctx context.Context) (string, error) {
+	var name string
+	if err := r.db.QueryRowContext(ctx, "SELECT DB_NAME()").Scan(&name); err != nil {
+		return "", fmt.Errorf("error reading database name: %w", err)
+	}
+	return name, nil
+}
+
+// Columns returns the columns of the given tables, keyed by upper-case table and column name.
+// Tables that do not exist are absent from the result.
+func (r *schemaRepository) Columns(ctx context.Context, tables []string) (map[string]map[string]bool, error) {
+	columns := make(map[string]map[string]bool)
+	if len(tables) == 0 {
+		return columns, nil
+	}
+
+	names := make([]string, len(tables))
+	args := make([]interface{}, len(tables))
+	for i, table := range tables {
+		names[i] = fmt.Sprintf("@t%d", i)
+		args[i] = sql.Named(fmt.Sprintf("t%d", i), table)
+	}
+
+	query := `
+        SELECT UPPER(TABLE_NAME), UPPER(COLUMN_NAME)
+        FROM INFORMATION_SCHEMA.COLUMNS
+        WHERE TABLE_NAME IN (` + strings.Join(names, ", ") + `)
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error reading table columns: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, fmt.Errorf("error scanning table column: %w", err)
+		}
+		if columns[table] == nil {
+			columns[table] = make(map[string]bool)
+		}
+		columns[table][column] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating table columns: %w", err)
+	}
+
+	return columns, nil
+}
+
+// ProcedureExists reports whether a stored procedure exists
+func (r *schemaRepository) ProcedureExists(ctx context.Context, name string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(
+		ctx,
+		"SELECT CAST(CASE WHEN OBJECT_ID(@name, 'P') IS NULL THEN 0 ELSE 1 END AS BIT)",
+		sql.Named("name", name),
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("error looking up procedure %s: %w", name, err)
+	}
+	return exists, nil
+}
@@ -0,0 +1,23 @@
+package repository
+
+import "strings"
+
+// searchLimitMax caps the rows a quick search returns per entity
+const searchLimitMax = 50
+
+// containsPattern builds a LIKE pattern matching text anywhere, escaping LIKE wildcards with '\'
+func containsPattern(text string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `[`, `\[`).Replace(text)
+	return "%" + escaped + "%"
+}
+
+// clampSearchLimit keeps a search limit between 1 and searchLimitMax
+func clampSearchLimit(limit int) int {
+	if limit < 1 {
+		return 1
+	}
+	if limit > searchLimitMax {
+		return searchLimitMax
+	}
+	return limit
+}
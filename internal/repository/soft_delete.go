@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// checkAffected turns an update that matched no rows into a not found error
+func checkAffected(result sql.Result, entity string) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error reading affected rows: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s not found: %w", entity, sql.ErrNoRows)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/dto"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// StockBalanceRepository reads on-hand stock from the ERP inventory ledger
+type StockBalanceRepository interface {
+	GetStockBalance(
+		ctx context.Context,
+		asOfDate time.Time,
+		itemCode string,
+		warehouseCode string,
+	) ([]dto.StockBalanceItem, error)
+}
+
+type stockBalanceRepository struct {
+	erp    ERPPool
+	logger *slog.Logger
+}
+
+// NewStockBalanceRepository creates a new stock balance repository. Like the item inventory
+// report it always reads the ERP server, as the ERP cache does not copy the inventory ledger.
+func NewStockBalanceRepository(erp ERPPool, logger *slog.Logger) StockBalanceRepository {
+	return &stockBalanceRepository{
+		erp:    erp,
+		logger: logger,
+	}
+}
+
+// GetStockBalance returns the quantity on hand per item and warehouse at the end of the as-of
+// date, summing the INVLA movements up to it. Items with nothing in stock are left out.
+func (r *stockBalanceRepository) GetStockBalance(
+	ctx context.Context,
+	asOfDate time.Time,
+	itemCode string,
+	warehouseCode string,
+) ([]dto.StockBalanceItem, error) {
+	r.logger.DebugContext(ctx, "Querying stock balance",
+		"as_of_date", asOfDate, "item_code", itemCode, "warehouse_code", warehouseCode)
+	erpDB, err := r.erp.ERPDatabaseFor(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting ERP database: %w", err)
+	}
+
+	query := `
+	SELECT
+    INVLA.LA001 AS item_code,
+    ISNULL(INVMB.MB002, '') AS item_name,
+    ISNULL(INVMB.MB004, '') AS unit,
+    INVLA.LA009 AS warehouse_code,
+    ISNULL(CMSMC.MC002, '') AS warehouse_name,
+    SUM(INVLA.LA005 * INVLA.LA011) AS on_hand_qty
+FROM
+    INVLA WITH (NOLOCK)
+LEFT JOIN
+    INVMB WITH (NOLOCK) ON INVMB.MB001 = INVLA.LA001
+LEFT JOIN
+    CMSMC WITH (NOLOCK) ON CMSMC.MC001 = INVLA.LA009
+WHERE INVLA.LA004 <= @AsOfDate
+    AND (@ItemCode = '' OR INVLA.LA001 = @ItemCode)
+    AND (@WarehouseCode = '' OR INVLA.LA009 = @WarehouseCode)
+GROUP BY
+    INVLA.LA001, INVMB.MB002, INVMB.MB004, INVLA.LA009, CMSMC.MC002
+HAVING
+    SUM(INVLA.LA005 * INVLA.LA011) <> 0
+ORDER BY
+    INVLA.LA001, INVLA.LA009
+	`
+
+	// LA004 is stored as YYYYMMDD text
+	rows, err := erpDB.QueryContext(
+		ctx,
+		query,
+		sql.Named("AsOfDate", asOfDate.Format("20060102")),
+		sql.Named("ItemCode", itemCode),
+		sql.Named("WarehouseCode", warehouseCode),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying stock balance: %w", err)
+	}
+	defer rows.Close()
+
+	var items []dto.StockBalanceItem
+	for rows.Next() {
+		var item dto.StockBalanceItem
+		if err := rows.Scan(
+			&item.ItemCode,
+			&item.ItemName,
+			&item.Unit,
+			&item.WarehouseCode,
+			&item.WarehouseName,
+			&item.OnHandQty,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning stock balance: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stock balance: %w", err)
+	}
+
+	return items, nil
+}
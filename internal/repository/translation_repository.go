@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"fmt"
+	"time"
+)
+
+// TranslationRepository stores the translation labels managed at runtime
+type TranslationRepository interface {
+	List(ctx context.Context, locale string) ([]*models.TranslationLabel, error)
+	Upsert(ctx context.Context, label *models.TranslationLabel) error
+	Delete(ctx context.Context, locale, key string) error
+}
+
+type translationRepository struct {
+	db *sql.DB
+}
+
+// NewTranslationRepository creates a new translation repository
+func NewTranslationRepository(db *sql.DB) TranslationRepository {
+	return &translationRepository{
+		db: db,
+	}
+}
+
+// List gets the labels ordered by locale and key, optionally of one locale
+func (r *translationRepository) List(ctx context.Context, locale string) ([]*models.TranslationLabel, error) {
+	query := `
+        SELECT id, locale, label_key, label, updated_by, created_at, updated_at
+        FROM translation_labels
+        WHERE @locale = '' OR locale = @locale
+        ORDER BY locale, label_key
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, sql.Named("locale", locale))
+	if err != nil {
+		return nil, fmt.Errorf("error getting translation labels: %w", err)
+	}
+	defer rows.Close()
+
+	var labels []*models.TranslationLabel
+	for rows.Next() {
+		var label models.TranslationLabel
+		err := rows.Scan(
+			&label.ID,
+			&label.Locale,
+			&label.Key,
+			&label.Label,
+			&label.UpdatedBy,
+			&label.CreatedAt,
+			&label.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning translation label: %w", err)
+		}
+		labels = append(labels, &label)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating translation labels: %w", err)
+	}
+
+	return labels, nil
+}
+
+// Upsert creates the label of a key or replaces it
+func (r *translationRepository) Upsert(ctx context.Context, label *models.TranslationLabel) error {
+	query := `
+        MERGE translation_labels AS target
+        USING (SELECT @locale AS locale, @label_key AS label_key) AS source
+        ON target.locale = source.locale AND target.label_key = source.label_key
+        WHEN MATCHED THEN
+            UPDATE SET label = @label, updated_by = @updated_by, updated_at = @now
+        WHEN NOT MATCHED THEN
+            INSERT (locale, label_key, label, updated_by, created_at, updated_at)
+            VALUES (@locale, @label_key, @label, @updated_by, @now, @now);
+    `
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("locale", label.Locale),
+		sql.Named("label_key", label.Key),
+		sql.Named("label", label.Label),
+		sql.Named("updated_by", label.UpdatedBy),
+		sql.Named("now", time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("error saving translation label: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the label of a key
+func (r *translationRepository) Delete(ctx context.Context, locale, key string) error {
+	result, err := r.db.ExecContext(
+		ctx,
+		"DELETE FROM translation_labels WHERE locale = @locale AND label_key = @label_key",
+		sql.Named("locale", locale),
+		sql.Named("label_key", key),
+	)
+	if err != nil {
+		return fmt.Errorf("error deleting translation label: %w", err)
+	}
+
+	return checkAffected(result, "translation label")
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// DBTX is the part of *sql.DB and *sql.Tx the repositories query through, so the same repository
+// code runs inside or outside a transaction
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// TxManager runs several repository calls as one unit of work
+type TxManager interface {
+	// WithinTransaction commits when fn returns nil and rolls back when it returns an error or panics.
+	// Repositories take part through their WithTx variants.
+	WithinTransaction(ctx context.Context, fn func(tx *sql.Tx) error) error
+}
+
+type txManager struct {
+	db *sql.DB
+}
+
+// NewTxManager creates a new transaction manager
+func NewTxManager(db *sql.DB) TxManager {
+	return &txManager{
+		db: db,
+	}
+}
+
+// WithinTransaction runs fn in a new transaction
+func (m *txManager) WithinTransaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// runInTx runs fn in a transaction of its own, or in the caller's when db already is one
+func runInTx(ctx context.Context, db DBTX, fn func(tx DBTX) error) error {
+	beginner, ok := db.(interface {
+		BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	})
+	if !ok {
+		return fn(db)
+	}
+
+	tx, err := beginner.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
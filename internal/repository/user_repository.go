@@ -3,11 +3,18 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"erp-excel/internal/fieldcrypt"
 	"erp-excel/internal/models"
 	"fmt"
 	"time"
 )
 
+// Fields of the users table encrypted at rest
+const (
+	userEmailField = "users.email"
+	userPhoneField = "users.phone"
+)
+
 // UserRepository interface
 type UserRepository interface {
 	Create(ctx context.Context, user *models.User) (*models.User, error)
@@ -16,33 +23,87 @@ type UserRepository interface {
 	Update(ctx context.Context, user *models.User) error
 	UpdatePassword(ctx context.Context, userID int, hashedPassword string) error
 	Delete(ctx context.Context, id int) error
-	List(ctx context.Context, limit, offset int) ([]*models.User, error)
-	Count(ctx context.Context) (int, error)
+	List(ctx context.Context, limit, offset int, includeDeleted bool) ([]*models.User, error)
+	Count(ctx context.Context, includeDeleted bool) (int, error)
 	GetUserRoles(ctx context.Context, userID int) ([]*models.Role, error)
 	AssignRoles(ctx context.Context, userID int, roleIDs []int) error
 	RemoveRoles(ctx context.Context, userID int, roleIDs []int) error
 	UpdateLastLogin(ctx context.Context, userID int) error
+	ListDeleted(ctx context.Context) ([]*models.User, error)
+	Restore(ctx context.Context, id int) error
+	Search(ctx context.Context, text string, limit int) ([]*models.User, error)
+	ListPasswordsChangedBefore(ctx context.Context, before time.Time) ([]*models.User, error)
+	RewriteEncryptedFields(ctx context.Context, batchSize int) (int, error)
+	WithTx(tx *sql.Tx) UserRepository
 }
 
 type userRepository struct {
-	db *sql.DB
+	db     DBTX
+	reads  ReadPool
+	cipher *fieldcrypt.Cipher
+}
+
+// NewUserRepository creates a new user repository. The email and phone of users are encrypted
+// with the cipher, which may be nil to store them in plaintext. GetByID, List and Count read from
+// the read pool, which may be nil to read from db.
+func NewUserRepository(db *sql.DB, reads ReadPool, cipher *fieldcrypt.Cipher) UserRepository {
+	return &userRepository{
+		db:     db,
+		reads:  reads,
+		cipher: cipher,
+	}
 }
 
-// NewUserRepository creates a new user repository
-func NewUserRepository(db *sql.DB) UserRepository {
+// WithTx returns a user repository that runs in the given transaction
+func (r *userRepository) WithTx(tx *sql.Tx) UserRepository {
 	return &userRepository{
-		db: db,
+		db:     tx,
+		cipher: r.cipher,
+	}
+}
+
+// encryptFields returns the email and phone of a user as they are stored, with the blind index
+// that finds the user by email
+func (r *userRepository) encryptFields(user *models.User) (email, phone string, emailHash sql.NullString, err error) {
+	if email, err = r.cipher.Encrypt(userEmailField, user.Email); err != nil {
+		return "", "", emailHash, err
+	}
+	if phone, err = r.cipher.Encrypt(userPhoneField, user.Phone); err != nil {
+		return "", "", emailHash, err
+	}
+	if hash := r.cipher.BlindIndex(user.Email); hash != "" {
+		emailHash = sql.NullString{String: hash, Valid: true}
+	}
+	return email, phone, emailHash, nil
+}
+
+// decryptUser decrypts the email and phone of a user read from the users table
+func decryptUser(cipher *fieldcrypt.Cipher, user *models.User) error {
+	email, err := cipher.Decrypt(userEmailField, user.Email)
+	if err != nil {
+		return fmt.Errorf("error reading user %d: %w", user.ID, err)
 	}
+	phone, err := cipher.Decrypt(userPhoneField, user.Phone)
+	if err != nil {
+		return fmt.Errorf("error reading user %d: %w", user.ID, err)
+	}
+	user.Email, user.Phone = email, phone
+	return nil
 }
 
 // Create adds a new user to the database
 func (r *userRepository) Create(ctx context.Context, user *models.User) (*models.User, error) {
 	query := `
-        INSERT INTO users (username, password, full_name, phone, department_id, is_active, created_at, updated_at)
+        INSERT INTO users (username, password, full_name, email, phone, email_hash, department_id, is_active, created_at, updated_at)
         OUTPUT INSERTED.id
-        VALUES (@username, @password, @full_name, @email, @department_id, @is_active, @created_at, @updated_at)
+        VALUES (@username, @password, @full_name, @email, @phone, @email_hash, @department_id, @is_active, @created_at, @updated_at)
     `
 
+	email, phone, emailHash, err := r.encryptFields(user)
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting user: %w", err)
+	}
+
 	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("error preparing statement: %w", err)
@@ -55,7 +116,9 @@ func (r *userRepository) Create(ctx context.Context, user *models.User) (*models
 		sql.Named("username", user.Username),
 		sql.Named("password", user.Password),
 		sql.Named("full_name", user.FullName),
-		sql.Named("email", user.Email),
+		sql.Named("email", email),
+		sql.Named("phone", phone),
+		sql.Named("email_hash", emailHash),
 		sql.Named("department_id", user.DepartmentID),
 		sql.Named("is_active", user.IsActive),
 		sql.Named("created_at", time.Now()),
@@ -72,18 +135,18 @@ func (r *userRepository) Create(ctx context.Context, user *models.User) (*models
 
 func (r *userRepository) GetByID(ctx context.Context, id int) (*models.User, error) {
 	query := `
-        SELECT u.id, u.username, u.full_name, u.email, u.department_id, 
+        SELECT u.id, u.username, u.full_name, u.email, ISNULL(u.phone, ''), u.department_id, 
                u.is_active, u.last_login, u.created_at, u.updated_at,
                d.name as department_name, 
                r.id as role_id, r.name as role_name, r.description as role_description
         FROM users u
         LEFT JOIN departments d ON u.department_id = d.id
         LEFT JOIN user_roles ur ON u.id = ur.user_id
-        LEFT JOIN roles r ON ur.role_id = r.id
-        WHERE u.id = @id
+        LEFT JOIN roles r ON ur.role_id = r.id AND r.deleted_at IS NULL
+        WHERE u.id = @id AND u.deleted_at IS NULL
     `
 
-	rows, err := r.db.QueryContext(ctx, query, sql.Named("id", id))
+	rows, err := reader(ctx, r.db, r.reads).QueryContext(ctx, query, sql.Named("id", id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("user not found: %w", err)
@@ -108,6 +171,7 @@ func (r *userRepository) GetByID(ctx context.Context, id int) (*models.User, err
 			&user.Username,
 			&user.FullName,
 			&user.Email,
+			&user.Phone,
 			&user.DepartmentID,
 			&user.IsActive,
 			&lastLogin,
@@ -144,6 +208,13 @@ func (r *userRepository) GetByID(ctx context.Context, id int) (*models.User, err
 		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
 
+	if user.ID == 0 {
+		return nil, fmt.Errorf("user not found: %w", sql.ErrNoRows)
+	}
+	if err := decryptUser(r.cipher, &user); err != nil {
+		return nil, err
+	}
+
 	user.Department = &department
 
 	return &user, nil
@@ -157,7 +228,7 @@ func (r *userRepository) GetByUsername(ctx context.Context, username string) (*m
                d.name as department_name
         FROM users u
         LEFT JOIN departments d ON u.department_id = d.id
-        WHERE u.username = @username
+        WHERE u.username = @username AND u.deleted_at IS NULL
     `
 
 	var user models.User
@@ -188,6 +259,9 @@ func (r *userRepository) GetByUsername(ctx context.Context, username string) (*m
 	if lastLogin.Valid {
 		user.LastLogin = lastLogin.Time
 	}
+	if err := decryptUser(r.cipher, &user); err != nil {
+		return nil, err
+	}
 
 	department.ID = user.DepartmentID
 	user.Department = &department
@@ -201,17 +275,26 @@ func (r *userRepository) Update(ctx context.Context, user *models.User) error {
         UPDATE users
         SET full_name = @full_name,
             email = @email,
+            phone = @phone,
+            email_hash = @email_hash,
             department_id = @department_id,
             is_active = @is_active,
             updated_at = @updated_at
-        WHERE id = @id
+        WHERE id = @id AND deleted_at IS NULL
     `
 
-	_, err := r.db.ExecContext(
+	email, phone, emailHash, err := r.encryptFields(user)
+	if err != nil {
+		return fmt.Errorf("error encrypting user: %w", err)
+	}
+
+	_, err = r.db.ExecContext(
 		ctx,
 		query,
 		sql.Named("full_name", user.FullName),
-		sql.Named("email", user.Email),
+		sql.Named("email", email),
+		sql.Named("phone", phone),
+		sql.Named("email_hash", emailHash),
 		sql.Named("department_id", user.DepartmentID),
 		sql.Named("is_active", user.IsActive),
 		sql.Named("updated_at", time.Now()),
@@ -230,6 +313,7 @@ func (r *userRepository) UpdatePassword(ctx context.Context, userID int, hashedP
 	query := `
         UPDATE users
         SET password = @password,
+            password_changed_at = @updated_at,
             updated_at = @updated_at
         WHERE id = @id
     `
@@ -249,23 +333,98 @@ func (r *userRepository) UpdatePassword(ctx context.Context, userID int, hashedP
 	return nil
 }
 
-// Delete soft deletes a user
+// Delete soft deletes a user; the account keeps its roles so it can be restored as it was
 func (r *userRepository) Delete(ctx context.Context, id int) error {
 	query := `
         UPDATE users
-        SET is_active = 0
-        WHERE id = @id
+        SET deleted_at = @now,
+            updated_at = @now
+        WHERE id = @id AND deleted_at IS NULL
     `
 
-	_, err := r.db.ExecContext(ctx, query, sql.Named("id", id))
+	result, err := r.db.ExecContext(ctx, query, sql.Named("now", time.Now()), sql.Named("id", id))
 	if err != nil {
 		return fmt.Errorf("error deleting user: %w", err)
 	}
 
-	return nil
+	return checkAffected(result, "user")
+}
+
+// Restore brings a soft deleted user back
+func (r *userRepository) Restore(ctx context.Context, id int) error {
+	query := `
+        UPDATE users
+        SET deleted_at = NULL,
+            updated_at = @now
+        WHERE id = @id AND deleted_at IS NOT NULL
+    `
+
+	result, err := r.db.ExecContext(ctx, query, sql.Named("now", time.Now()), sql.Named("id", id))
+	if err != nil {
+		return fmt.Errorf("error restoring user: %w", err)
+	}
+
+	return checkAffected(result, "deleted user")
+}
+
+// ListDeleted gets the soft deleted users, most recently deleted first
+func (r *userRepository) ListDeleted(ctx context.Context) ([]*models.User, error) {
+	query := `
+        SELECT u.id, u.username, u.full_name, u.email, u.department_id,
+               u.is_active, u.created_at, u.updated_at, u.deleted_at,
+               d.name AS department_name
+        FROM users u
+        LEFT JOIN departments d ON u.department_id = d.id
+        WHERE u.deleted_at IS NOT NULL
+        ORDER BY u.deleted_at DESC
+    `
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error listing deleted users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		var user models.User
+		var deletedAt time.Time
+		var departmentName sql.NullString
+
+		err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.FullName,
+			&user.Email,
+			&user.DepartmentID,
+			&user.IsActive,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+			&deletedAt,
+			&departmentName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning user: %w", err)
+		}
+
+		if err := decryptUser(r.cipher, &user); err != nil {
+			return nil, err
+		}
+
+		user.DeletedAt = &deletedAt
+		user.Department = &models.Department{ID: user.DepartmentID, Name: departmentName.String}
+		users = append(users, &user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating users: %w", err)
+	}
+
+	return users, nil
 }
 
-func (r *userRepository) List(ctx context.Context, limit, offset int) ([]*models.User, error) {
+// List gets a page of users, with the soft deleted ones when includeDeleted is set
+func (r *userRepository) List(ctx context.Context, limit, offset int, includeDeleted bool) ([]*models.User, error) {
 	query := `
         SELECT *
         FROM (
@@ -274,11 +433,13 @@ func (r *userRepository) List(ctx context.Context, limit, offset int) ([]*models
                 u.username, 
                 u.full_name, 
                 u.email, 
+                ISNULL(u.phone, '') AS phone, 
                 u.department_id, 
                 u.is_active, 
                 u.last_login, 
                 u.created_at, 
                 u.updated_at,
+                u.deleted_at,
                 d.name AS department_name,
                 r.id AS role_id,
                 r.name AS role_name,
@@ -290,17 +451,20 @@ func (r *userRepository) List(ctx context.Context, limit, offset int) ([]*models
             LEFT JOIN 
                 user_roles ur ON u.id = ur.user_id  
             LEFT JOIN 
-                roles r ON ur.role_id = r.id 
+                roles r ON ur.role_id = r.id AND r.deleted_at IS NULL
+            WHERE
+                u.deleted_at IS NULL OR @include_deleted = 1
         ) AS UsersWithRowNumbers
         WHERE RowNum BETWEEN @offset + 1 AND @offset + @limit
         ORDER BY id
     `
 
-	rows, err := r.db.QueryContext(
+	rows, err := reader(ctx, r.db, r.reads).QueryContext(
 		ctx,
 		query,
 		sql.Named("limit", limit),
 		sql.Named("offset", offset),
+		sql.Named("include_deleted", includeDeleted),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("error listing users: %w", err)
@@ -313,7 +477,7 @@ func (r *userRepository) List(ctx context.Context, limit, offset int) ([]*models
 	for rows.Next() {
 		var user models.User
 		var department models.Department
-		var lastLogin sql.NullTime
+		var lastLogin, deletedAt sql.NullTime
 		var roleID sql.NullInt64
 		var roleName sql.NullString
 		var rowNum int
@@ -323,11 +487,13 @@ func (r *userRepository) List(ctx context.Context, limit, offset int) ([]*models
 			&user.Username,
 			&user.FullName,
 			&user.Email,
+			&user.Phone,
 			&user.DepartmentID,
 			&user.IsActive,
 			&lastLogin,
 			&user.CreatedAt,
 			&user.UpdatedAt,
+			&deletedAt,
 			&department.Name,
 			&roleID,
 			&roleName,
@@ -340,6 +506,9 @@ func (r *userRepository) List(ctx context.Context, limit, offset int) ([]*models
 		if lastLogin.Valid {
 			user.LastLogin = lastLogin.Time
 		}
+		if deletedAt.Valid {
+			user.DeletedAt = &deletedAt.Time
+		}
 
 		department.ID = user.DepartmentID
 		user.Department = &department
@@ -348,6 +517,9 @@ func (r *userRepository) List(ctx context.Context, limit, offset int) ([]*models
 		existingUser, ok := userMap[user.ID]
 		if !ok {
 			// If user doesn't exist, create a new one
+			if err := decryptUser(r.cipher, &user); err != nil {
+				return nil, err
+			}
 			existingUser = &user
 			existingUser.Department = &department
 			users = append(users, existingUser)
@@ -367,10 +539,77 @@ func (r *userRepository) List(ctx context.Context, limit, offset int) ([]*models
 	return users, nil
 }
 
+// Search finds users whose username or full name contains the text, or whose email contains
+// it. Encrypted emails cannot be searched, so those only match when the text is the whole email.
+func (r *userRepository) Search(ctx context.Context, text string, limit int) ([]*models.User, error) {
+	query := `
+        SELECT TOP (@limit) u.id, u.username, u.full_name, u.email, u.department_id, u.is_active,
+               d.name AS department_name
+        FROM users u
+        LEFT JOIN departments d ON u.department_id = d.id
+        WHERE u.deleted_at IS NULL
+          AND (u.username LIKE @pattern ESCAPE '\'
+               OR u.full_name LIKE @pattern ESCAPE '\'
+               OR u.email_hash = @email_hash
+               OR (u.email_hash IS NULL AND u.email LIKE @pattern ESCAPE '\' AND u.email NOT LIKE 'enc:%'))
+        ORDER BY u.username
+    `
+
+	emailHash := sql.NullString{String: r.cipher.BlindIndex(text)}
+	emailHash.Valid = emailHash.String != ""
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		query,
+		sql.Named("limit", clampSearchLimit(limit)),
+		sql.Named("pattern", containsPattern(text)),
+		sql.Named("email_hash", emailHash),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error searching users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		var user models.User
+		var departmentName sql.NullString
+
+		err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.FullName,
+			&user.Email,
+			&user.DepartmentID,
+			&user.IsActive,
+			&departmentName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning user: %w", err)
+		}
+
+		if err := decryptUser(r.cipher, &user); err != nil {
+			return nil, err
+		}
+
+		user.Department = &models.Department{ID: user.DepartmentID, Name: departmentName.String}
+		users = append(users, &user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating users: %w", err)
+	}
+
+	return users, nil
+}
+
 // Count gets the total number of users
-func (r *userRepository) Count(ctx context.Context) (int, error) {
+func (r *userRepository) Count(ctx context.Context, includeDeleted bool) (int, error) {
 	var count int
-	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count)
+	err := reader(ctx, r.db, r.reads).QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM users WHERE deleted_at IS NULL OR @include_deleted = 1",
+		sql.Named("include_deleted", includeDeleted),
+	).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("error counting users: %w", err)
 	}
@@ -383,7 +622,7 @@ func (r *userRepository) GetUserRoles(ctx context.Context, userID int) ([]*model
         SELECT r.id, r.name, r.description
         FROM roles r
         JOIN user_roles ur ON r.id = ur.role_id
-        WHERE ur.user_id = @user_id
+        WHERE ur.user_id = @user_id AND r.deleted_at IS NULL
     `
 
 	rows, err := r.db.QueryContext(ctx, query, sql.Named("user_id", userID))
@@ -409,43 +648,35 @@ func (r *userRepository) GetUserRoles(ctx context.Context, userID int) ([]*model
 	return roles, nil
 }
 
-// AssignRoles assigns roles to a user
+// AssignRoles replaces the roles of a user
 func (r *userRepository) AssignRoles(ctx context.Context, userID int, roleIDs []int) error {
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("error beginning transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	// Delete existing roles first
-	_, err = tx.ExecContext(
-		ctx,
-		"DELETE FROM user_roles WHERE user_id = @user_id",
-		sql.Named("user_id", userID),
-	)
-	if err != nil {
-		return fmt.Errorf("error deleting existing roles: %w", err)
-	}
-
-	// Insert new roles
-	for _, roleID := range roleIDs {
-		_, err = tx.ExecContext(
+	return runInTx(ctx, r.db, func(tx DBTX) error {
+		// Delete existing roles first
+		_, err := tx.ExecContext(
 			ctx,
-			"INSERT INTO user_roles (user_id, role_id, created_at) VALUES (@user_id, @role_id, @created_at)",
+			"DELETE FROM user_roles WHERE user_id = @user_id",
 			sql.Named("user_id", userID),
-			sql.Named("role_id", roleID),
-			sql.Named("created_at", time.Now()),
 		)
 		if err != nil {
-			return fmt.Errorf("error assigning role: %w", err)
+			return fmt.Errorf("error deleting existing roles: %w", err)
 		}
-	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("error committing transaction: %w", err)
-	}
+		// Insert new roles
+		for _, roleID := range roleIDs {
+			_, err = tx.ExecContext(
+				ctx,
+				"INSERT INTO user_roles (user_id, role_id, created_at) VALUES (@user_id, @role_id, @created_at)",
+				sql.Named("user_id", userID),
+				sql.Named("role_id", roleID),
+				sql.Named("created_at", time.Now()),
+			)
+			if err != nil {
+				return fmt.Errorf("error assigning role: %w", err)
+			}
+		}
 
-	return nil
+		return nil
+	})
 }
 
 // RemoveRoles removes roles from a user
@@ -495,3 +726,132 @@ func (r *userRepository) UpdateLastLogin(ctx context.Context, userID int) error
 
 	return nil
 }
+
+// ListPasswordsChangedBefore gets the active users whose password was last changed before the
+// given time. Passwords never changed count from the creation of the account.
+func (r *userRepository) ListPasswordsChangedBefore(ctx context.Context, before time.Time) ([]*models.User, error) {
+	query := `
+        SELECT id, username, full_name, email, department_id, ISNULL(password_changed_at, created_at)
+        FROM users
+        WHERE is_active = 1 AND deleted_at IS NULL
+          AND ISNULL(password_changed_at, created_at) < @before
+        ORDER BY id
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, sql.Named("before", before))
+	if err != nil {
+		return nil, fmt.Errorf("error listing users by password age: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		var user models.User
+		err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.FullName,
+			&user.Email,
+			&user.DepartmentID,
+			&user.PasswordChangedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning user: %w", err)
+		}
+		if err := decryptUser(r.cipher, &user); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating users: %w", err)
+	}
+
+	return users, nil
+}
+
+// RewriteEncryptedFields rewrites the email and phone of every user, deleted ones included, that
+// are not stored as the cipher stores them now: plaintext rows written before encryption was
+// turned on, rows under a previous key, or encrypted rows once there is no current key. It works
+// through the table in batches and returns the number of users rewritten. A user changed while
+// it runs is left as the change wrote it.
+func (r *userRepository) RewriteEncryptedFields(ctx context.Context, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	type storedFields struct {
+		id        int
+		email     string
+		phone     string
+		emailHash string
+	}
+
+	rewritten, lastID := 0, 0
+	for {
+		rows, err := r.db.QueryContext(ctx, `
+            SELECT TOP (@batch) id, ISNULL(email, ''), ISNULL(phone, ''), ISNULL(email_hash, '')
+            FROM users
+            WHERE id > @last_id
+            ORDER BY id
+        `, sql.Named("batch", batchSize), sql.Named("last_id", lastID))
+		if err != nil {
+			return rewritten, fmt.Errorf("error reading users: %w", err)
+		}
+
+		var batch []storedFields
+		for rows.Next() {
+			var stored storedFields
+			if err := rows.Scan(&stored.id, &stored.email, &stored.phone, &stored.emailHash); err != nil {
+				rows.Close()
+				return rewritten, fmt.Errorf("error scanning user: %w", err)
+			}
+			batch = append(batch, stored)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return rewritten, fmt.Errorf("error iterating users: %w", err)
+		}
+		if len(batch) == 0 {
+			return rewritten, nil
+		}
+
+		for _, stored := range batch {
+			lastID = stored.id
+
+			user := &models.User{ID: stored.id, Email: stored.email, Phone: stored.phone}
+			if err := decryptUser(r.cipher, user); err != nil {
+				return rewritten, err
+			}
+			if r.cipher.IsCurrent(stored.email) && r.cipher.IsCurrent(stored.phone) &&
+				stored.emailHash == r.cipher.BlindIndex(user.Email) {
+				continue
+			}
+
+			email, phone, emailHash, err := r.encryptFields(user)
+			if err != nil {
+				return rewritten, fmt.Errorf("error encrypting user %d: %w", user.ID, err)
+			}
+			result, err := r.db.ExecContext(ctx, `
+                UPDATE users
+                SET email = @email, phone = @phone, email_hash = @email_hash
+                WHERE id = @id AND ISNULL(email, '') = @old_email AND ISNULL(phone, '') = @old_phone
+            `,
+				sql.Named("email", email),
+				sql.Named("phone", phone),
+				sql.Named("email_hash", emailHash),
+				sql.Named("id", user.ID),
+				sql.Named("old_email", stored.email),
+				sql.Named("old_phone", stored.phone),
+			)
+			if err != nil {
+				return rewritten, fmt.Errorf("error rewriting user %d: %w", user.ID, err)
+			}
+			if affected, _ := result.RowsAffected(); affected > 0 {
+				rewritten++
+			}
+		}
+	}
+}
@@ -0,0 +1,406 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WebhookDeliveryFilter narrows the delivery log
+type WebhookDeliveryFilter struct {
+	WebhookID int
+	Status    string
+	EventType string
+	Limit     int
+}
+
+// WebhookRepository stores the webhooks and the log of their deliveries, which doubles as the
+// queue of the delivery worker
+type WebhookRepository interface {
+	List(ctx context.Context) ([]*models.Webhook, error)
+	GetByID(ctx context.Context, id int) (*models.Webhook, error)
+	Create(ctx context.Context, webhook *models.Webhook) (int, error)
+	Update(ctx context.Context, webhook *models.Webhook) error
+	Delete(ctx context.Context, id int) error
+
+	AddDelivery(ctx context.Context, delivery *models.WebhookDelivery) (int64, error)
+	ClaimDelivery(ctx context.Context, lease time.Duration) (*models.WebhookDelivery, error)
+	CompleteDelivery(ctx context.Context, id int64, responseStatus int) error
+	FailDelivery(ctx context.Context, id int64, responseStatus int, errMsg string, nextAttemptAt *time.Time) error
+	RetryDelivery(ctx context.Context, id int64) error
+	GetDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, filter WebhookDeliveryFilter) ([]*models.WebhookDelivery, error)
+	DeleteDeliveriesBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type webhookRepository struct {
+	db *sql.DB
+}
+
+// NewWebhookRepository creates a new webhook repository
+func NewWebhookRepository(db *sql.DB) WebhookRepository {
+	return &webhookRepository{
+		db: db,
+	}
+}
+
+const webhookColumns = `id, name, url, secret, events, is_active, created_by, created_at, updated_at`
+
+const webhookDeliveryColumns = `id, webhook_id, event_type, payload, status, attempts, ISNULL(response_status, 0),
+    ISNULL(last_error, ''), next_attempt_at, created_at, delivered_at`
+
+// List gets all webhooks by name
+func (r *webhookRepository) List(ctx context.Context) ([]*models.Webhook, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY name, id`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []*models.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// GetByID gets a webhook by ID
+func (r *webhookRepository) GetByID(ctx context.Context, id int) (*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = @id`
+	return scanWebhook(r.db.QueryRowContext(ctx, query, sql.Named("id", id)))
+}
+
+// Create stores a new webhook
+func (r *webhookRepository) Create(ctx context.Context, webhook *models.Webhook) (int, error) {
+	now := time.Now()
+	query := `
+        INSERT INTO webhooks (name, url, secret, events, is_active, created_by, created_at, updated_at)
+        OUTPUT INSERTED.id
+        VALUES (@name, @url, @secret, @events, @is_active, @created_by, @now, @now)
+    `
+
+	var id int
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		sql.Named("name", webhook.Name),
+		sql.Named("url", webhook.URL),
+		sql.Named("secret", webhook.Secret),
+		sql.Named("events", strings.Join(webhook.Events, ",")),
+		sql.Named("is_active", webhook.IsActive),
+		sql.Named("created_by", webhook.CreatedBy),
+		sql.Named("now", now),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error creating webhook: %w", err)
+	}
+
+	webhook.ID = id
+	webhook.CreatedAt = now
+	webhook.UpdatedAt = now
+	return id, nil
+}
+
+// Update saves the name, URL, secret, events and active flag of a webhook
+func (r *webhookRepository) Update(ctx context.Context, webhook *models.Webhook) error {
+	now := time.Now()
+	query := `
+        UPDATE webhooks
+        SET name = @name, url = @url, secret = @secret, events = @events, is_active = @is_active, updated_at = @now
+        WHERE id = @id
+    `
+
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		sql.Named("id", webhook.ID),
+		sql.Named("name", webhook.Name),
+		sql.Named("url", webhook.URL),
+		sql.Named("secret", webhook.Secret),
+		sql.Named("events", strings.Join(webhook.Events, ",")),
+		sql.Named("is_active", webhook.IsActive),
+		sql.Named("now", now),
+	)
+	if err != nil {
+		return fmt.Errorf("error updating webhook: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("webhook not found: %w", sql.ErrNoRows)
+	}
+
+	webhook.UpdatedAt = now
+	return nil
+}
+
+// Delete removes a webhook together with its delivery log
+func (r *webhookRepository) Delete(ctx context.Context, id int) error {
+	query := `
+        DELETE FROM webhook_deliveries WHERE webhook_id = @id;
+        DELETE FROM webhooks WHERE id = @id;
+    `
+
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, query, sql.Named("id", id)); err != nil {
+		return fmt.Errorf("error deleting webhook: %w", err)
+	}
+	return nil
+}
+
+// AddDelivery queues a delivery, due at once
+func (r *webhookRepository) AddDelivery(ctx context.Context, delivery *models.WebhookDelivery) (int64, error) {
+	now := time.Now()
+	query := `
+        INSERT INTO webhook_deliveries (webhook_id, event_type, payload, status, attempts, next_attempt_at, created_at)
+        OUTPUT INSERTED.id
+        VALUES (@webhook_id, @event_type, @payload, 'pending', 0, @now, @now)
+    `
+
+	var id int64
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		sql.Named("webhook_id", delivery.WebhookID),
+		sql.Named("event_type", delivery.EventType),
+		sql.Named("payload", delivery.Payload),
+		sql.Named("now", now),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error adding webhook delivery: %w", err)
+	}
+
+	delivery.ID = id
+	delivery.Status = "pending"
+	delivery.NextAttemptAt = &now
+	delivery.CreatedAt = now
+	return id, nil
+}
+
+// ClaimDelivery takes the oldest due delivery and counts the attempt, or returns nil when none is
+// due. The delivery stays pending but is not due again until the lease ends, so a worker that
+// stops mid-attempt only delays it. READPAST lets several workers and instances claim deliveries
+// without picking the same one.
+func (r *webhookRepository) ClaimDelivery(ctx context.Context, lease time.Duration) (*models.WebhookDelivery, error) {
+	now := time.Now()
+	query := `
+        WITH next_delivery AS (
+            SELECT TOP (1) *
+            FROM webhook_deliveries WITH (ROWLOCK, UPDLOCK, READPAST)
+            WHERE status = 'pending' AND next_attempt_at <= @now
+            ORDER BY next_attempt_at, id
+        )
+        UPDATE next_delivery
+        SET attempts = attempts + 1, next_attempt_at = @lease_until
+        OUTPUT INSERTED.id, INSERTED.webhook_id, INSERTED.event_type, INSERTED.payload, INSERTED.status,
+            INSERTED.attempts, ISNULL(INSERTED.response_status, 0), ISNULL(INSERTED.last_error, ''),
+            INSERTED.next_attempt_at, INSERTED.created_at, INSERTED.delivered_at
+    `
+
+	delivery, err := scanWebhookDelivery(r.db.QueryRowContext(ctx, query,
+		sql.Named("now", now),
+		sql.Named("lease_until", now.Add(lease)),
+	))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error claiming webhook delivery: %w", err)
+	}
+
+	return delivery, nil
+}
+
+// CompleteDelivery records a delivery the webhook accepted
+func (r *webhookRepository) CompleteDelivery(ctx context.Context, id int64, responseStatus int) error {
+	query := `
+        UPDATE webhook_deliveries
+        SET status = 'delivered', response_status = @response_status, last_error = NULL,
+            next_attempt_at = NULL, delivered_at = @now
+        WHERE id = @id
+    `
+
+	_, err := r.db.ExecContext(ctx, query,
+		sql.Named("id", id),
+		sql.Named("response_status", responseStatus),
+		sql.Named("now", time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("error completing webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// FailDelivery records a failed attempt, retried at nextAttemptAt or given up when it is nil
+func (r *webhookRepository) FailDelivery(ctx context.Context, id int64, responseStatus int, errMsg string, nextAttemptAt *time.Time) error {
+	query := `
+        UPDATE webhook_deliveries
+        SET status = CASE WHEN @next_attempt_at IS NULL THEN 'failed' ELSE 'pending' END,
+            response_status = NULLIF(@response_status, 0),
+            last_error = LEFT(@last_error, 1000),
+            next_attempt_at = @next_attempt_at
+        WHERE id = @id
+    `
+
+	_, err := r.db.ExecContext(ctx, query,
+		sql.Named("id", id),
+		sql.Named("response_status", responseStatus),
+		sql.Named("last_error", errMsg),
+		sql.Named("next_attempt_at", nullTimePtr(nextAttemptAt)),
+	)
+	if err != nil {
+		return fmt.Errorf("error failing webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// RetryDelivery queues a delivery again with a fresh count of attempts, due at once
+func (r *webhookRepository) RetryDelivery(ctx context.Context, id int64) error {
+	query := `
+        UPDATE webhook_deliveries
+        SET status = 'pending', attempts = 0, next_attempt_at = @now, delivered_at = NULL
+        WHERE id = @id
+    `
+
+	result, err := r.db.ExecContext(ctx, query, sql.Named("id", id), sql.Named("now", time.Now()))
+	if err != nil {
+		return fmt.Errorf("error retrying webhook delivery: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("webhook delivery not found: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// GetDelivery gets a delivery by ID
+func (r *webhookRepository) GetDelivery(ctx context.Context, id int64) (*models.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id = @id`
+	delivery, err := scanWebhookDelivery(r.db.QueryRowContext(ctx, query, sql.Named("id", id)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("webhook delivery not found: %w", err)
+		}
+		return nil, fmt.Errorf("error getting webhook delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+// ListDeliveries gets the newest deliveries matching the filter
+func (r *webhookRepository) ListDeliveries(ctx context.Context, filter WebhookDeliveryFilter) ([]*models.WebhookDelivery, error) {
+	query := `
+        SELECT TOP (@limit) ` + webhookDeliveryColumns + `
+        FROM webhook_deliveries
+        WHERE (@webhook_id = 0 OR webhook_id = @webhook_id)
+          AND (@status = '' OR status = @status)
+          AND (@event_type = '' OR event_type = @event_type)
+        ORDER BY id DESC
+    `
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		query,
+		sql.Named("limit", filter.Limit),
+		sql.Named("webhook_id", filter.WebhookID),
+		sql.Named("status", filter.Status),
+		sql.Named("event_type", filter.EventType),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error listing webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// DeleteDeliveriesBefore removes the finished deliveries created before a time
+func (r *webhookRepository) DeleteDeliveriesBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx,
+		`DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < @before`,
+		sql.Named("before", before),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting webhook deliveries: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// scanWebhook scans one webhook row
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	var webhook models.Webhook
+	var events string
+	err := row.Scan(
+		&webhook.ID,
+		&webhook.Name,
+		&webhook.URL,
+		&webhook.Secret,
+		&events,
+		&webhook.IsActive,
+		&webhook.CreatedBy,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("webhook not found: %w", err)
+		}
+		return nil, fmt.Errorf("error scanning webhook: %w", err)
+	}
+
+	webhook.Events = strings.FieldsFunc(events, func(r rune) bool { return r == ',' })
+	return &webhook, nil
+}
+
+// scanWebhookDelivery scans one delivery row, returning sql.ErrNoRows as is
+func scanWebhookDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	var nextAttemptAt, deliveredAt sql.NullTime
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventType,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.ResponseStatus,
+		&delivery.LastError,
+		&nextAttemptAt,
+		&delivery.CreatedAt,
+		&deliveredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if nextAttemptAt.Valid {
+		delivery.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return &delivery, nil
+}
@@ -0,0 +1,168 @@
+// Package rpc runs the gRPC server of the internal services on google.golang.org/grpc. The
+// services are the stubs generated from the proto directory, registered on the server, which adds
+// mutual TLS, a limit on the size of request messages and a log line for every call.
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"runtime/debug"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// defaultMaxMessageBytes is the largest request message accepted unless configured, as gRPC has it
+const defaultMaxMessageBytes = 4 << 20
+
+// Server serves the services registered with it
+type Server struct {
+	addr   string
+	server *grpc.Server
+	logger *slog.Logger
+
+	// ErrorStatus turns the errors of handlers that carry no gRPC status into one; without it they
+	// are answered with Unknown
+	ErrorStatus func(ctx context.Context, err error) *status.Status
+}
+
+// NewServer creates a server listening on addr. It serves TLS with tlsConfig, and plaintext
+// HTTP/2 when tlsConfig is nil.
+func NewServer(addr string, tlsConfig *tls.Config, maxMessageBytes int, logger *slog.Logger) *Server {
+	if maxMessageBytes <= 0 {
+		maxMessageBytes = defaultMaxMessageBytes
+	}
+
+	s := &Server{addr: addr, logger: logger}
+	options := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(maxMessageBytes),
+		grpc.UnaryInterceptor(s.intercept),
+	}
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	s.server = grpc.NewServer(options...)
+	return s
+}
+
+// RegisterService registers a service and its implementation, as the generated Register functions
+// do
+func (s *Server) RegisterService(desc *grpc.ServiceDesc, impl any) {
+	s.server.RegisterService(desc, impl)
+}
+
+// ListenAndServe serves until Shutdown, after which it returns nil
+func (s *Server) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve serves the connections of listener until Shutdown
+func (s *Server) Serve(listener net.Listener) error {
+	return s.server.Serve(listener)
+}
+
+// Shutdown stops accepting calls and waits for the running ones until ctx is done, when it closes
+// the connections left
+func (s *Server) Shutdown(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
+
+// Peer describes the client of a call
+type Peer struct {
+	Address string
+	// Name is the common name of the client certificate, empty without TLS
+	Name string
+}
+
+// PeerFromContext returns the client of the call handled with ctx
+func PeerFromContext(ctx context.Context) Peer {
+	client, ok := peer.FromContext(ctx)
+	if !ok {
+		return Peer{}
+	}
+
+	p := Peer{Address: client.Addr.String()}
+	if info, ok := client.AuthInfo.(credentials.TLSInfo); ok && len(info.State.PeerCertificates) > 0 {
+		p.Name = info.State.PeerCertificates[0].Subject.CommonName
+	}
+	return p
+}
+
+// intercept runs a unary call, answering its error with a status and a panic with Internal, and
+// logs it
+func (s *Server) intercept(ctx context.Context, request any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	response, err := s.call(ctx, request, info, handler)
+	result := s.status(ctx, err)
+
+	level := slog.LevelInfo
+	if result.Code() == codes.Unknown || result.Code() == codes.Internal {
+		level = slog.LevelError
+	}
+	p := PeerFromContext(ctx)
+	s.logger.Log(ctx, level, "gRPC call",
+		"method", info.FullMethod,
+		"client", p.Name,
+		"remote_addr", p.Address,
+		"code", int(result.Code()),
+		"message", result.Message(),
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+
+	if result.Code() != codes.OK {
+		return nil, result.Err()
+	}
+	return response, nil
+}
+
+// call runs the handler of a call, recovering a panic
+func (s *Server) call(ctx context.Context, request any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (response any, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			s.logger.ErrorContext(ctx, "Panic handling gRPC call", "method", info.FullMethod, "panic", recovered, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+	return handler(ctx, request)
+}
+
+// status returns the status of a call from its error
+func (s *Server) status(ctx context.Context, err error) *status.Status {
+	if err == nil {
+		return status.New(codes.OK, "")
+	}
+
+	var coded interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &coded) {
+		return coded.GRPCStatus()
+	}
+	if s.ErrorStatus != nil {
+		if result := s.ErrorStatus(ctx, err); result != nil {
+			return result
+		}
+	}
+	return status.New(codes.Unknown, err.Error())
+}
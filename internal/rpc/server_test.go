@@ -0,0 +1,141 @@
+package rpc
+
+import (
+	"context"
+	erpexcelv1 "erp-excel/proto/erpexcel/v1"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testAccessServer answers CheckAccess by its operation code, allowing the call when it names the
+// client address it saw
+type testAccessServer struct {
+	erpexcelv1.UnimplementedAccessServiceServer
+}
+
+func (testAccessServer) CheckAccess(ctx context.Context, request *erpexcelv1.CheckAccessRequest) (*erpexcelv1.CheckAccessResponse, error) {
+	switch request.GetOperationCode() {
+	case "status":
+		return nil, status.Errorf(codes.NotFound, "report %q not found", "x")
+	case "wrapped":
+		return nil, fmt.Errorf("loading: %w", status.Error(codes.PermissionDenied, "denied"))
+	case "mapped":
+		return nil, context.DeadlineExceeded
+	case "panic":
+		panic("boom")
+	case "error":
+		return nil, errors.New("database is down")
+	}
+	return &erpexcelv1.CheckAccessResponse{Allowed: request.GetOperationCode() == PeerFromContext(ctx).Address}, nil
+}
+
+// testClient serves a server on an in-memory listener and returns a client connected to it
+func testClient(t *testing.T, maxMessageBytes int) (erpexcelv1.AccessServiceClient, *Server) {
+	t.Helper()
+
+	s := NewServer("", nil, maxMessageBytes, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.ErrorStatus = func(ctx context.Context, err error) *status.Status {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return status.New(codes.DeadlineExceeded, "too slow")
+		}
+		return nil
+	}
+	erpexcelv1.RegisterAccessServiceServer(s, testAccessServer{})
+
+	listener := bufconn.Listen(1 << 20)
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(listener) }()
+	t.Cleanup(func() {
+		s.Shutdown(context.Background())
+		if err := <-served; err != nil {
+			t.Errorf("Serve() error = %v", err)
+		}
+	})
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return erpexcelv1.NewAccessServiceClient(conn), s
+}
+
+func TestServerCalls(t *testing.T) {
+	tests := []struct {
+		name          string
+		operationCode string
+		wantCode      codes.Code
+		wantMessage   string
+		wantAllowed   bool
+	}{
+		{name: "call with the client address", operationCode: "bufconn", wantCode: codes.OK, wantAllowed: true},
+		{name: "call", operationCode: "reports:read", wantCode: codes.OK},
+		{name: "status error", operationCode: "status", wantCode: codes.NotFound, wantMessage: `report "x" not found`},
+		{name: "wrapped status error", operationCode: "wrapped", wantCode: codes.PermissionDenied, wantMessage: "denied"},
+		{name: "error mapped by ErrorStatus", operationCode: "mapped", wantCode: codes.DeadlineExceeded, wantMessage: "too slow"},
+		{name: "other error", operationCode: "error", wantCode: codes.Unknown, wantMessage: "database is down"},
+		{name: "panic", operationCode: "panic", wantCode: codes.Internal, wantMessage: "internal error"},
+	}
+
+	client, _ := testClient(t, 0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := client.CheckAccess(t.Context(), &erpexcelv1.CheckAccessRequest{UserId: 1, OperationCode: tt.operationCode})
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("code = %v, want %v (%v)", got, tt.wantCode, err)
+			}
+			if err != nil {
+				if got := status.Convert(err).Message(); got != tt.wantMessage {
+					t.Errorf("message = %q, want %q", got, tt.wantMessage)
+				}
+				return
+			}
+			if response.GetAllowed() != tt.wantAllowed {
+				t.Errorf("allowed = %t, want %t", response.GetAllowed(), tt.wantAllowed)
+			}
+		})
+	}
+}
+
+func TestServerMessageLimit(t *testing.T) {
+	client, _ := testClient(t, 64)
+
+	_, err := client.CheckAccess(t.Context(), &erpexcelv1.CheckAccessRequest{UserId: 1, OperationCode: strings.Repeat("x", 100)})
+	if got := status.Code(err); got != codes.ResourceExhausted {
+		t.Errorf("code = %v, want ResourceExhausted (%v)", got, err)
+	}
+	if _, err := client.CheckAccess(t.Context(), &erpexcelv1.CheckAccessRequest{UserId: 1, OperationCode: "small"}); err != nil {
+		t.Errorf("CheckAccess() error = %v for a message under the limit", err)
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	client, s := testClient(t, 0)
+	if _, err := client.CheckAccess(t.Context(), &erpexcelv1.CheckAccessRequest{UserId: 1, OperationCode: "x"}); err != nil {
+		t.Fatalf("CheckAccess() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if _, err := client.CheckAccess(t.Context(), &erpexcelv1.CheckAccessRequest{UserId: 1, OperationCode: "x"}); status.Code(err) != codes.Unavailable {
+		t.Errorf("CheckAccess() after Shutdown error = %v, want Unavailable", err)
+	}
+}
@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// MutualTLSConfig creates the TLS config of a server that requires a client certificate signed by
+// the CAs of clientCAFile. With allowedClients, the certificate also has to carry one of them as
+// its common name or a DNS name.
+func MutualTLSConfig(certificateFile, keyFile, clientCAFile string, allowedClients []string) (*tls.Config, error) {
+	if certificateFile == "" || keyFile == "" || clientCAFile == "" {
+		return nil, errors.New("grpc needs a certificate, key and client ca file unless insecure")
+	}
+
+	certificate, err := tls.LoadX509KeyPair(certificateFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading grpc certificate: %w", err)
+	}
+
+	pem, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading grpc client ca certificate: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("grpc client ca file contains no certificate")
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2"},
+	}
+	if len(allowedClients) > 0 {
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			leaf := state.PeerCertificates[0]
+			if slices.Contains(allowedClients, leaf.Subject.CommonName) {
+				return nil
+			}
+			for _, name := range leaf.DNSNames {
+				if slices.Contains(allowedClients, name) {
+					return nil
+				}
+			}
+			return fmt.Errorf("grpc client %q is not allowed", leaf.Subject.CommonName)
+		}
+	}
+	return config, nil
+}
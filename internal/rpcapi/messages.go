@@ -0,0 +1,126 @@
+package rpcapi
+
+import (
+	"erp-excel/internal/dto"
+	erpexcelv1 "erp-excel/proto/erpexcel/v1"
+	"fmt"
+	"math"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The conversions between the responses of the service layer and the generated messages of
+// proto/erpexcel/v1/erpexcel.proto
+
+func reportMessage(report *dto.ReportDefinitionResponse) *erpexcelv1.Report {
+	message := &erpexcelv1.Report{
+		Code:        report.Code,
+		Name:        report.Name,
+		Description: report.Description,
+		UsesDates:   report.UsesDates,
+		Columns:     report.Columns,
+	}
+	for _, parameter := range report.Parameters {
+		message.Parameters = append(message.Parameters, &erpexcelv1.ReportParameter{
+			Name:     parameter.Name,
+			Type:     parameter.Type,
+			Required: parameter.Required,
+			Default:  parameter.Default,
+		})
+	}
+	return message
+}
+
+func runReportResponseMessage(response *dto.ReportRunResponse) *erpexcelv1.RunReportResponse {
+	message := &erpexcelv1.RunReportResponse{
+		Code:        response.Code,
+		ReportName:  response.ReportName,
+		GeneratedAt: timestamppb.New(response.GeneratedAt),
+		Columns:     response.Columns,
+		RowCount:    int32(response.RowCount),
+		Truncated:   response.Truncated,
+	}
+	for _, item := range response.Items {
+		message.Items = append(message.Items, structMessage(item))
+	}
+	return message
+}
+
+func userMessage(user *dto.UserResponse) *erpexcelv1.User {
+	return &erpexcelv1.User{
+		Id:           int64(user.ID),
+		Username:     user.Username,
+		FullName:     user.FullName,
+		Email:        user.Email,
+		Phone:        user.Phone,
+		DepartmentId: int64(user.DepartmentID),
+		Department:   user.Department,
+		IsActive:     user.IsActive,
+		Roles:        user.Roles,
+	}
+}
+
+// requestTime reads an optional timestamp of a request
+func requestTime(name string, timestamp *timestamppb.Timestamp) (*time.Time, error) {
+	if timestamp == nil {
+		return nil, nil
+	}
+	if err := timestamp.CheckValid(); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s: %v", name, err)
+	}
+	t := timestamp.AsTime()
+	return &t, nil
+}
+
+// structMessage converts a report row to a google.protobuf.Struct
+func structMessage(fields map[string]interface{}) *structpb.Struct {
+	message := &structpb.Struct{Fields: make(map[string]*structpb.Value, len(fields))}
+	for key, value := range fields {
+		message.Fields[key] = valueMessage(value)
+	}
+	return message
+}
+
+// valueMessage converts a value of a report row: numbers to number_value, dates to RFC 3339
+// string_value and SQL NULL to null_value
+func valueMessage(value interface{}) *structpb.Value {
+	switch typed := value.(type) {
+	case nil:
+		return structpb.NewNullValue()
+	case bool:
+		return structpb.NewBoolValue(typed)
+	case string:
+		return structpb.NewStringValue(typed)
+	case []byte:
+		return structpb.NewStringValue(string(typed))
+	case time.Time:
+		return structpb.NewStringValue(typed.Format(time.RFC3339))
+	case int:
+		return structpb.NewNumberValue(float64(typed))
+	case int32:
+		return structpb.NewNumberValue(float64(typed))
+	case int64:
+		return structpb.NewNumberValue(float64(typed))
+	case float32:
+		return structpb.NewNumberValue(float64(typed))
+	case float64:
+		if math.IsNaN(typed) || math.IsInf(typed, 0) {
+			return structpb.NewStringValue(fmt.Sprint(typed))
+		}
+		return structpb.NewNumberValue(typed)
+	case map[string]interface{}:
+		return structpb.NewStructValue(structMessage(typed))
+	case []interface{}:
+		list := &structpb.ListValue{Values: make([]*structpb.Value, 0, len(typed))}
+		for _, item := range typed {
+			list.Values = append(list.Values, valueMessage(item))
+		}
+		return structpb.NewListValue(list)
+	default:
+		return structpb.NewStringValue(fmt.Sprint(typed))
+	}
+}
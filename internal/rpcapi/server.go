@@ -0,0 +1,247 @@
+// Package rpcapi serves the gRPC services of proto/erpexcel/v1/erpexcel.proto on top of the
+// service layer, for other internal services reading report data, users and access checks.
+package rpcapi
+
+import (
+	"context"
+	"crypto/tls"
+	"erp-excel/config"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/rpc"
+	"erp-excel/internal/service"
+	erpexcelv1 "erp-excel/proto/erpexcel/v1"
+	"log/slog"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultPort = "9090"
+
+// errInactiveUser is returned for a call acting as a user who may not log in
+var errInactiveUser = apperror.PermissionDenied("the user is inactive")
+
+type services struct {
+	reportEngineService service.ReportEngineService
+	userService         service.UserService
+	operationService    service.OperationService
+}
+
+// NewServer creates the gRPC server of the configuration with the services registered
+func NewServer(
+	cfg config.GRPCConfig,
+	reportEngineService service.ReportEngineService,
+	userService service.UserService,
+	operationService service.OperationService,
+	logger *slog.Logger,
+) (*rpc.Server, error) {
+	var tlsConfig *tls.Config
+	if cfg.Insecure {
+		logger.Warn("gRPC server is serving without TLS")
+	} else {
+		var err error
+		tlsConfig, err = rpc.MutualTLSConfig(cfg.CertificateFile, cfg.KeyFile, cfg.ClientCAFile, cfg.AllowedClients)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	port := cfg.Port
+	if port == "" {
+		port = defaultPort
+	}
+
+	server := rpc.NewServer(":"+port, tlsConfig, cfg.MaxMessageBytes, logger)
+	server.ErrorStatus = errorStatus
+
+	s := &services{
+		reportEngineService: reportEngineService,
+		userService:         userService,
+		operationService:    operationService,
+	}
+	erpexcelv1.RegisterReportServiceServer(server, &reportServer{services: s})
+	erpexcelv1.RegisterUserServiceServer(server, &userServer{services: s})
+	erpexcelv1.RegisterAccessServiceServer(server, &accessServer{services: s})
+	return server, nil
+}
+
+// reportServer serves erpexcel.v1.ReportService
+type reportServer struct {
+	erpexcelv1.UnimplementedReportServiceServer
+	*services
+}
+
+// ListReports lists the reports the user of the request may run, or every report for a call
+// without a user
+func (s *reportServer) ListReports(ctx context.Context, request *erpexcelv1.ListReportsRequest) (*erpexcelv1.ListReportsResponse, error) {
+	userID := int(request.GetUserId())
+	if userID != 0 {
+		if _, err := s.activeUser(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	reports, err := s.reportEngineService.ListReports(ctx, userID, userID == 0)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &erpexcelv1.ListReportsResponse{Reports: make([]*erpexcelv1.Report, 0, len(reports))}
+	for i := range reports {
+		response.Reports = append(response.Reports, reportMessage(&reports[i]))
+	}
+	return response, nil
+}
+
+// RunReport runs a report. A call with a user checks the operation of the report and scopes the
+// report to the user's department, as the REST route does.
+func (s *reportServer) RunReport(ctx context.Context, request *erpexcelv1.RunReportRequest) (*erpexcelv1.RunReportResponse, error) {
+	code, userID := request.GetCode(), int(request.GetUserId())
+	if code == "" {
+		return nil, status.Error(codes.InvalidArgument, "the request has no report code")
+	}
+
+	runRequest := &dto.ReportRunRequest{Params: request.GetParams()}
+	var err error
+	if runRequest.FromDate, err = requestTime("from_date", request.GetFromDate()); err != nil {
+		return nil, err
+	}
+	if runRequest.ToDate, err = requestTime("to_date", request.GetToDate()); err != nil {
+		return nil, err
+	}
+	if period := request.GetPeriod(); period != "" {
+		runRequest.Period = &period
+	}
+
+	departmentID := 0
+	if userID != 0 {
+		user, err := s.activeUser(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		definition, err := s.reportEngineService.GetDefinition(ctx, code)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.requireOperation(ctx, user.ID, definition.OperationCode); err != nil {
+			return nil, err
+		}
+		departmentID = user.DepartmentID
+	}
+
+	response, err := s.reportEngineService.RunReport(ctx, userID, departmentID, code, runRequest, rpc.PeerFromContext(ctx).Address)
+	if err != nil {
+		return nil, err
+	}
+	return runReportResponseMessage(response), nil
+}
+
+// userServer serves erpexcel.v1.UserService
+type userServer struct {
+	erpexcelv1.UnimplementedUserServiceServer
+	*services
+}
+
+// GetUser looks a user up by ID or username
+func (s *userServer) GetUser(ctx context.Context, request *erpexcelv1.GetUserRequest) (*erpexcelv1.User, error) {
+	var user *dto.UserResponse
+	var err error
+	switch {
+	case request.GetId() != 0:
+		user, err = s.userService.GetUserByID(ctx, int(request.GetId()))
+	case request.GetUsername() != "":
+		user, err = s.userService.GetUserByUsername(ctx, request.GetUsername())
+	default:
+		return nil, status.Error(codes.InvalidArgument, "the request needs an id or a username")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return userMessage(user), nil
+}
+
+// accessServer serves erpexcel.v1.AccessService
+type accessServer struct {
+	erpexcelv1.UnimplementedAccessServiceServer
+	*services
+}
+
+// CheckAccess reports whether a user may perform an operation. Inactive users may not perform any.
+func (s *accessServer) CheckAccess(ctx context.Context, request *erpexcelv1.CheckAccessRequest) (*erpexcelv1.CheckAccessResponse, error) {
+	userID, operationCode := int(request.GetUserId()), request.GetOperationCode()
+	if userID == 0 || operationCode == "" {
+		return nil, status.Error(codes.InvalidArgument, "the request needs a user_id and an operation_code")
+	}
+
+	user, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return &erpexcelv1.CheckAccessResponse{Allowed: false}, nil
+	}
+
+	allowed, err := s.operationService.CheckUserAccess(ctx, user.ID, operationCode)
+	if err != nil {
+		return nil, err
+	}
+	return &erpexcelv1.CheckAccessResponse{Allowed: allowed}, nil
+}
+
+// activeUser loads the user a call acts as
+func (s *services) activeUser(ctx context.Context, userID int) (*dto.UserResponse, error) {
+	user, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, errInactiveUser
+	}
+	return user, nil
+}
+
+func (s *services) requireOperation(ctx context.Context, userID int, operationCode string) error {
+	allowed, err := s.operationService.CheckUserAccess(ctx, userID, operationCode)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return apperror.ErrPermissionDenied
+	}
+	return nil
+}
+
+// codeStatuses maps the codes of the service errors to gRPC status codes
+var codeStatuses = map[string]codes.Code{
+	apperror.CodeValidation:       codes.InvalidArgument,
+	apperror.CodeInvalidRange:     codes.InvalidArgument,
+	apperror.CodeNotFound:         codes.NotFound,
+	apperror.CodeNoData:           codes.NotFound,
+	apperror.CodeConflict:         codes.FailedPrecondition,
+	apperror.CodePermissionDenied: codes.PermissionDenied,
+	apperror.CodeExportTooLarge:   codes.ResourceExhausted,
+	apperror.CodeReportTooLarge:   codes.ResourceExhausted,
+	apperror.CodeQueryTimeout:     codes.DeadlineExceeded,
+	apperror.CodeQueryCancelled:   codes.Canceled,
+}
+
+// errorStatus answers the errors of the services with the status matching their code, and errors
+// without a code as internal, logged rather than returned to the client
+func errorStatus(ctx context.Context, err error) *status.Status {
+	coded, ok := apperror.As(err)
+	if !ok {
+		slog.ErrorContext(ctx, "Error handling gRPC call", "error", err)
+		return status.New(codes.Internal, "internal error")
+	}
+
+	code, ok := codeStatuses[coded.Code]
+	if !ok {
+		code = codes.Unknown
+	}
+	message := err.Error()
+	if coded.Detail != "" {
+		message = coded.Detail
+	}
+	return status.New(code, message)
+}
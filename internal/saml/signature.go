@@ -0,0 +1,143 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+const (
+	dsigNS       = "http://www.w3.org/2000/09/xmldsig#"
+	excC14N      = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	envelopedSig = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	rsaSHA256    = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	rsaSHA1      = "http://www.w3.org/2000/09/xmldsig#rsa-sha1"
+	digestSHA256 = "http://www.w3.org/2001/04/xmlenc#sha256"
+	digestSHA1   = "http://www.w3.org/2000/09/xmldsig#sha1"
+)
+
+// verifySignature checks the enveloped XML-DSig signature on target against the IdP certificate.
+// Only exclusive canonicalization with RSA-SHA256/SHA1 is supported, which covers
+// ADFS, Azure AD, Okta, Keycloak and Shibboleth defaults.
+func (sp *ServiceProvider) verifySignature(root, target *element) error {
+	publicKey, ok := sp.idpCertificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("saml idp certificate must contain an RSA public key")
+	}
+
+	signature := target.child(dsigNS, "Signature")
+	signedInfo := signature.child(dsigNS, "SignedInfo")
+	if signedInfo == nil {
+		return errors.New("saml signature has no SignedInfo")
+	}
+
+	id := target.attr("ID")
+	if id == "" {
+		return errors.New("signed saml element has no ID")
+	}
+	// Duplicate IDs are the classic signature wrapping trick
+	if root.countIDs(id) != 1 {
+		return errors.New("saml message contains duplicate IDs")
+	}
+
+	canonicalization := signedInfo.child(dsigNS, "CanonicalizationMethod")
+	if canonicalization == nil || canonicalization.attr("Algorithm") != excC14N {
+		return errors.New("unsupported saml canonicalization method")
+	}
+
+	references := signedInfo.childrenNamed(dsigNS, "Reference")
+	if len(references) != 1 {
+		return errors.New("saml signature must have exactly one reference")
+	}
+	reference := references[0]
+	if reference.attr("URI") != "#"+id {
+		return errors.New("saml signature does not reference the signed element")
+	}
+
+	// Transforms must be the enveloped signature removal followed by exclusive c14n
+	var prefixes []string
+	if transforms := reference.child(dsigNS, "Transforms"); transforms != nil {
+		for _, transform := range transforms.childrenNamed(dsigNS, "Transform") {
+			switch transform.attr("Algorithm") {
+			case envelopedSig:
+			case excC14N:
+				prefixes = inclusivePrefixes(transform)
+			default:
+				return fmt.Errorf("unsupported saml transform %q", transform.attr("Algorithm"))
+			}
+		}
+	}
+
+	digestMethod := reference.child(dsigNS, "DigestMethod")
+	digestValue := reference.child(dsigNS, "DigestValue")
+	if digestMethod == nil || digestValue == nil {
+		return errors.New("saml signature reference is incomplete")
+	}
+
+	var digest hash.Hash
+	switch digestMethod.attr("Algorithm") {
+	case digestSHA256:
+		digest = sha256.New()
+	case digestSHA1:
+		digest = sha1.New()
+	default:
+		return fmt.Errorf("unsupported saml digest method %q", digestMethod.attr("Algorithm"))
+	}
+
+	digest.Write(canonicalize(target, signature, prefixes))
+	expectedDigest, err := base64.StdEncoding.DecodeString(stripSpace(digestValue.text()))
+	if err != nil {
+		return fmt.Errorf("invalid saml digest value: %w", err)
+	}
+	if subtle.ConstantTimeCompare(digest.Sum(nil), expectedDigest) != 1 {
+		return errors.New("saml signature digest mismatch")
+	}
+
+	signatureMethod := signedInfo.child(dsigNS, "SignatureMethod")
+	if signatureMethod == nil {
+		return errors.New("saml signature has no SignatureMethod")
+	}
+
+	var hashType crypto.Hash
+	switch signatureMethod.attr("Algorithm") {
+	case rsaSHA256:
+		hashType = crypto.SHA256
+	case rsaSHA1:
+		hashType = crypto.SHA1
+	default:
+		return fmt.Errorf("unsupported saml signature method %q", signatureMethod.attr("Algorithm"))
+	}
+
+	signatureValue := signature.child(dsigNS, "SignatureValue")
+	if signatureValue == nil {
+		return errors.New("saml signature has no SignatureValue")
+	}
+	signatureBytes, err := base64.StdEncoding.DecodeString(stripSpace(signatureValue.text()))
+	if err != nil {
+		return fmt.Errorf("invalid saml signature value: %w", err)
+	}
+
+	hasher := hashType.New()
+	hasher.Write(canonicalize(signedInfo, nil, inclusivePrefixes(canonicalization)))
+	if err := rsa.VerifyPKCS1v15(publicKey, hashType, hasher.Sum(nil), signatureBytes); err != nil {
+		return errors.New("saml signature is invalid")
+	}
+
+	return nil
+}
+
+// inclusivePrefixes reads the InclusiveNamespaces PrefixList of an exc-c14n transform
+func inclusivePrefixes(transform *element) []string {
+	inclusive := transform.child(excC14N, "InclusiveNamespaces")
+	if inclusive == nil {
+		return nil
+	}
+	return strings.Fields(inclusive.attr("PrefixList"))
+}
@@ -0,0 +1,637 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"erp-excel/config"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	xsNS       = "http://www.w3.org/2001/XMLSchema"
+	xsiNS      = "http://www.w3.org/2001/XMLSchema-instance"
+	testIdP    = "https://idp.example.com"
+	testEntity = "https://erp.example.com/saml/metadata"
+	testACS    = "https://erp.example.com/saml/acs"
+)
+
+var (
+	testKeysOnce sync.Once
+	idpKey       *rsa.PrivateKey
+	otherKey     *rsa.PrivateKey
+	idpCert      *x509.Certificate
+)
+
+// testKeys returns the signing key of the test identity provider, its certificate and a key it
+// does not know
+func testKeys(t *testing.T) (*rsa.PrivateKey, *x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	testKeysOnce.Do(func() {
+		var err error
+		if idpKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			panic(err)
+		}
+		if otherKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			panic(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "idp.example.com"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &idpKey.PublicKey, idpKey)
+		if err != nil {
+			panic(err)
+		}
+		if idpCert, err = x509.ParseCertificate(der); err != nil {
+			panic(err)
+		}
+	})
+	return idpKey, idpCert, otherKey
+}
+
+func testServiceProvider(t *testing.T) *ServiceProvider {
+	_, certificate, _ := testKeys(t)
+	return &ServiceProvider{
+		entityID:       testEntity,
+		acsURL:         testACS,
+		idpEntityID:    testIdP,
+		idpSSOURL:      "https://idp.example.com/sso",
+		idpCertificate: certificate,
+		clockSkew:      3 * time.Minute,
+		seen:           make(map[string]time.Time),
+	}
+}
+
+// signing describes how a test message is signed
+type signing struct {
+	key              *rsa.PrivateKey
+	canonicalization string
+	signatureMethod  string
+	signatureHash    crypto.Hash
+	digestMethod     string
+	digestHash       crypto.Hash
+	uri              string // defaults to the ID of the signed element
+	transforms       string // canonical Transform elements
+	extraReference   bool
+}
+
+func defaultSigning(key *rsa.PrivateKey) signing {
+	return signing{
+		key:              key,
+		canonicalization: excC14N,
+		signatureMethod:  rsaSHA256,
+		signatureHash:    crypto.SHA256,
+		digestMethod:     digestSHA256,
+		digestHash:       crypto.SHA256,
+		transforms: `<ds:Transform Algorithm="` + envelopedSig + `"></ds:Transform>` +
+			`<ds:Transform Algorithm="` + excC14N + `"></ds:Transform>`,
+	}
+}
+
+// signatureElement signs the canonical form of an element, written out by hand so the test does
+// not depend on the canonicalization it checks, and returns its Signature element
+func signatureElement(t *testing.T, canonical, id string, s signing) string {
+	t.Helper()
+	uri := s.uri
+	if uri == "" {
+		uri = "#" + id
+	}
+
+	digest := s.digestHash.New()
+	digest.Write([]byte(canonical))
+	reference := `<ds:Reference URI="` + uri + `"><ds:Transforms>` + s.transforms + `</ds:Transforms>` +
+		`<ds:DigestMethod Algorithm="` + s.digestMethod + `"></ds:DigestMethod>` +
+		`<ds:DigestValue>` + base64.StdEncoding.EncodeToString(digest.Sum(nil)) + `</ds:DigestValue></ds:Reference>`
+	if s.extraReference {
+		reference += reference
+	}
+
+	signedInfo := `<ds:SignedInfo xmlns:ds="` + dsigNS + `">` +
+		`<ds:CanonicalizationMethod Algorithm="` + s.canonicalization + `"></ds:CanonicalizationMethod>` +
+		`<ds:SignatureMethod Algorithm="` + s.signatureMethod + `"></ds:SignatureMethod>` +
+		reference + `</ds:SignedInfo>`
+	hashed := s.signatureHash.New()
+	hashed.Write([]byte(signedInfo))
+	value, err := rsa.SignPKCS1v15(rand.Reader, s.key, s.signatureHash, hashed.Sum(nil))
+	if err != nil {
+		t.Fatalf("signing: %v", err)
+	}
+
+	// In the document SignedInfo inherits the ds prefix from Signature
+	signedInfo = strings.Replace(signedInfo, ` xmlns:ds="`+dsigNS+`"`, "", 1)
+	return `<ds:Signature xmlns:ds="` + dsigNS + `">` + signedInfo +
+		"<ds:SignatureValue>\n" + base64.StdEncoding.EncodeToString(value) + "\n</ds:SignatureValue></ds:Signature>"
+}
+
+// message is a SAML response built for a test
+type message struct {
+	assertionID  string
+	issuer       string
+	nameID       string
+	audience     string
+	recipient    string
+	destination  string
+	notOnOrAfter time.Time
+	typedValues  bool // values typed with xsi:type="xs:string", signed with xs as an inclusive prefix
+
+	signResponse bool // sign the response instead of the assertion
+	unsigned     bool
+	signing      signing
+
+	// edit changes the document after it is signed
+	edit func(document string) string
+}
+
+func validMessage(t *testing.T) message {
+	key, _, _ := testKeys(t)
+	return message{
+		assertionID:  "_assertion1",
+		issuer:       testIdP,
+		nameID:       "jane@example.com",
+		audience:     testEntity,
+		recipient:    testACS,
+		destination:  testACS,
+		notOnOrAfter: time.Now().Add(5 * time.Minute),
+		signing:      defaultSigning(key),
+	}
+}
+
+// build returns the base64 response. The elements are first written in canonical form, which is
+// what gets signed, then the namespace declarations are moved to the root and the empty elements
+// closed on themselves, as identity providers write them.
+func (m message) build(t *testing.T) string {
+	t.Helper()
+	now := time.Now().UTC().Truncate(time.Second)
+	notBefore := now.Add(-time.Minute).Format(time.RFC3339)
+	notOnOrAfter := m.notOnOrAfter.UTC().Format(time.RFC3339)
+
+	value := func(v string) string {
+		if m.typedValues {
+			return `<saml:AttributeValue xmlns:xsi="` + xsiNS + `" xsi:type="xs:string">` + v + `</saml:AttributeValue>`
+		}
+		return `<saml:AttributeValue>` + v + `</saml:AttributeValue>`
+	}
+	declarations := `xmlns:saml="` + assertionNS + `"`
+	if m.typedValues {
+		declarations += ` xmlns:xs="` + xsNS + `"`
+	}
+
+	assertion := `<saml:Assertion ` + declarations + ` ID="` + m.assertionID + `" IssueInstant="` + now.Format(time.RFC3339) + `" Version="2.0">` +
+		"\n  " + `<saml:Issuer>` + m.issuer + `</saml:Issuer>` +
+		"\n  " + `<saml:Subject>` +
+		`<saml:NameID Format="urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress">` + m.nameID + `</saml:NameID>` +
+		`<saml:SubjectConfirmation Method="` + bearerMethod + `">` +
+		`<saml:SubjectConfirmationData InResponseTo="_request1" NotOnOrAfter="` + notOnOrAfter + `" Recipient="` + m.recipient + `"></saml:SubjectConfirmationData>` +
+		`</saml:SubjectConfirmation></saml:Subject>` +
+		"\n  " + `<saml:Conditions NotBefore="` + notBefore + `" NotOnOrAfter="` + notOnOrAfter + `">` +
+		`<saml:AudienceRestriction><saml:Audience>` + m.audience + `</saml:Audience></saml:AudienceRestriction></saml:Conditions>` +
+		"\n  " + `<saml:AuthnStatement AuthnInstant="` + now.Format(time.RFC3339) + `" SessionIndex="_session1"><saml:AuthnContext>` +
+		`<saml:AuthnContextClassRef>urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport</saml:AuthnContextClassRef>` +
+		`</saml:AuthnContext></saml:AuthnStatement>` +
+		"\n  " + `<saml:AttributeStatement>` +
+		`<saml:Attribute FriendlyName="mail" Name="urn:oid:0.9.2342.19200300.100.1.3">` + value("jane@example.com") + `</saml:Attribute>` +
+		`<saml:Attribute Name="groups">` + value("finance") + value("reports &amp; analytics") + `</saml:Attribute>` +
+		`</saml:AttributeStatement>` +
+		"\n" + `</saml:Assertion>`
+
+	if m.typedValues {
+		m.signing.transforms = `<ds:Transform Algorithm="` + envelopedSig + `"></ds:Transform>` +
+			`<ds:Transform Algorithm="` + excC14N + `"><ec:InclusiveNamespaces xmlns:ec="` + excC14N + `" PrefixList="xs"></ec:InclusiveNamespaces></ds:Transform>`
+	}
+	if !m.unsigned && !m.signResponse {
+		signature := signatureElement(t, assertion, m.assertionID, m.signing)
+		assertion = strings.Replace(assertion, `</saml:Issuer>`, `</saml:Issuer>`+signature, 1)
+	}
+
+	response := `<samlp:Response xmlns:samlp="` + protocolNS + `" Destination="` + m.destination + `" ID="_response1" InResponseTo="_request1" IssueInstant="` + now.Format(time.RFC3339) + `" Version="2.0">` +
+		"\n  " + `<saml:Issuer xmlns:saml="` + assertionNS + `">` + m.issuer + `</saml:Issuer>` +
+		"\n  " + `<samlp:Status><samlp:StatusCode Value="` + statusSuccess + `"></samlp:StatusCode></samlp:Status>` +
+		"\n  " + assertion +
+		"\n" + `</samlp:Response>`
+	if !m.unsigned && m.signResponse {
+		signature := signatureElement(t, response, "_response1", m.signing)
+		response = strings.Replace(response, `</saml:Issuer>`, `</saml:Issuer>`+signature, 1)
+	}
+
+	for _, declaration := range []string{
+		` xmlns:saml="` + assertionNS + `"`,
+		` xmlns:xs="` + xsNS + `"`,
+		` xmlns:xsi="` + xsiNS + `"`,
+	} {
+		response = strings.ReplaceAll(response, declaration, "")
+	}
+	response = strings.Replace(response, `<samlp:Response xmlns:samlp="`+protocolNS+`"`,
+		`<samlp:Response xmlns:samlp="`+protocolNS+`" xmlns:saml="`+assertionNS+`" xmlns:xs="`+xsNS+`" xmlns:xsi="`+xsiNS+`"`, 1)
+	for _, name := range []string{"ds:CanonicalizationMethod", "ds:SignatureMethod", "ds:Transform", "ds:DigestMethod", "samlp:StatusCode", "saml:SubjectConfirmationData"} {
+		response = strings.ReplaceAll(response, `"></`+name+`>`, `"/>`)
+	}
+
+	if m.edit != nil {
+		response = m.edit(response)
+	}
+	return base64.StdEncoding.EncodeToString([]byte(xmlHeader + response))
+}
+
+const xmlHeader = "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"
+
+// replaceOnce returns an edit replacing the first occurrence of old
+func replaceOnce(old, new string) func(string) string {
+	return func(document string) string {
+		return strings.Replace(document, old, new, 1)
+	}
+}
+
+// assertionElement splits a document around its assertion
+func assertionElement(document string) (before, assertion, after string) {
+	start := strings.Index(document, "<saml:Assertion")
+	end := strings.Index(document, "</saml:Assertion>") + len("</saml:Assertion>")
+	return document[:start], document[start:end], document[end:]
+}
+
+func TestParseResponse(t *testing.T) {
+	key, _, _ := testKeys(t)
+	sha1Signing := defaultSigning(key)
+	sha1Signing.signatureMethod, sha1Signing.signatureHash = rsaSHA1, crypto.SHA1
+	sha1Signing.digestMethod, sha1Signing.digestHash = digestSHA1, crypto.SHA1
+
+	tests := []struct {
+		name       string
+		message    func(m *message)
+		wantNameID string
+	}{
+		{name: "signed assertion"},
+		{name: "signed response", message: func(m *message) { m.signResponse = true }},
+		{name: "sha1", message: func(m *message) { m.signing = sha1Signing }},
+		{name: "inclusive namespace prefix", message: func(m *message) { m.typedValues = true }},
+		{name: "no destination", message: func(m *message) { m.destination = "" }},
+		{
+			// Canonicalization drops comments, so a comment cannot cut the signed name id short
+			name: "comment inside the name id",
+			message: func(m *message) {
+				m.nameID = "jane@example.com.evil.example"
+				m.edit = replaceOnce("jane@example.com.evil", "jane@example.com<!---->.evil")
+			},
+			wantNameID: "jane@example.com.evil.example",
+		},
+		{
+			name: "whitespace inside the signature value",
+			message: func(m *message) {
+				m.edit = replaceOnce("<ds:SignatureValue>\n", "<ds:SignatureValue>\n   ")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := validMessage(t)
+			if tt.message != nil {
+				tt.message(&m)
+			}
+
+			assertion, err := testServiceProvider(t).ParseResponse(m.build(t))
+			if err != nil {
+				t.Fatalf("ParseResponse() error = %v", err)
+			}
+
+			wantNameID := tt.wantNameID
+			if wantNameID == "" {
+				wantNameID = "jane@example.com"
+			}
+			if assertion.NameID != wantNameID || assertion.SessionIndex != "_session1" {
+				t.Errorf("NameID, SessionIndex = %q, %q", assertion.NameID, assertion.SessionIndex)
+			}
+			wantAttributes := map[string][]string{
+				"urn:oid:0.9.2342.19200300.100.1.3": {"jane@example.com"},
+				"mail":                              {"jane@example.com"},
+				"groups":                            {"finance", "reports & analytics"},
+			}
+			if !reflect.DeepEqual(assertion.Attributes, wantAttributes) {
+				t.Errorf("Attributes = %v, want %v", assertion.Attributes, wantAttributes)
+			}
+			if assertion.Attribute("mail") != "jane@example.com" || assertion.Attribute("missing") != "" {
+				t.Errorf("Attribute() = %q", assertion.Attribute("mail"))
+			}
+		})
+	}
+}
+
+func TestParseResponseRejects(t *testing.T) {
+	_, _, otherKey := testKeys(t)
+
+	tests := []struct {
+		name    string
+		message func(m *message)
+		wantErr string
+	}{
+		{
+			name: "name id changed after signing",
+			message: func(m *message) {
+				m.edit = replaceOnce(">jane@example.com</saml:NameID>", ">admin@example.com</saml:NameID>")
+			},
+			wantErr: "saml signature digest mismatch",
+		},
+		{
+			name: "attribute added after signing",
+			message: func(m *message) {
+				m.edit = replaceOnce(`<saml:Attribute Name="groups">`, `<saml:Attribute Name="groups"><saml:AttributeValue>admins</saml:AttributeValue>`)
+			},
+			wantErr: "saml signature digest mismatch",
+		},
+		{
+			name:    "response changed after signing",
+			message: func(m *message) { m.signResponse = true; m.edit = replaceOnce(`ID="_assertion1"`, `ID="_assertion2"`) },
+			wantErr: "saml signature digest mismatch",
+		},
+		{
+			name:    "digest value changed",
+			message: func(m *message) { m.edit = replaceOnce("<ds:DigestValue>", "<ds:DigestValue>AAAA") },
+			wantErr: "saml signature digest mismatch",
+		},
+		{
+			name:    "signed by another key",
+			message: func(m *message) { m.signing.key = otherKey },
+			wantErr: "saml signature is invalid",
+		},
+		{
+			name: "signed info changed after signing",
+			message: func(m *message) {
+				m.edit = replaceOnce(`<ds:Transforms>`, `<ds:Transforms><ds:Transform Algorithm="`+envelopedSig+`"/>`)
+			},
+			wantErr: "saml signature is invalid",
+		},
+		{
+			name:    "signature value not base64",
+			message: func(m *message) { m.edit = replaceOnce("<ds:SignatureValue>\n", "<ds:SignatureValue>!") },
+			wantErr: "invalid saml signature value",
+		},
+		{
+			name:    "digest value not base64",
+			message: func(m *message) { m.edit = replaceOnce("<ds:DigestValue>", "<ds:DigestValue>!") },
+			wantErr: "invalid saml digest value",
+		},
+		{
+			name:    "unsigned",
+			message: func(m *message) { m.unsigned = true },
+			wantErr: "saml response is not signed",
+		},
+		{
+			name: "signature removed",
+			message: func(m *message) {
+				m.edit = func(document string) string {
+					start := strings.Index(document, "<ds:Signature ")
+					end := strings.Index(document, "</ds:Signature>") + len("</ds:Signature>")
+					return document[:start] + document[end:]
+				}
+			},
+			wantErr: "saml response is not signed",
+		},
+		{
+			// The signed assertion is moved aside and a forged one with the same ID takes its place
+			name: "wrapped with a duplicate ID",
+			message: func(m *message) {
+				m.edit = func(document string) string {
+					before, assertion, after := assertionElement(document)
+					forged := strings.Replace(assertion, ">jane@example.com</saml:NameID>", ">admin@example.com</saml:NameID>", 1)
+					return before + "<samlp:Extensions>" + assertion + "</samlp:Extensions>" + forged + after
+				}
+			},
+			wantErr: "saml message contains duplicate IDs",
+		},
+		{
+			name: "wrapped under another ID",
+			message: func(m *message) {
+				m.edit = func(document string) string {
+					before, assertion, after := assertionElement(document)
+					forged := strings.Replace(assertion, `ID="_assertion1"`, `ID="_forged"`, 1)
+					return before + "<samlp:Extensions>" + assertion + "</samlp:Extensions>" + forged + after
+				}
+			},
+			wantErr: "saml signature does not reference the signed element",
+		},
+		{
+			name: "second assertion next to the signed one",
+			message: func(m *message) {
+				m.edit = func(document string) string {
+					before, assertion, after := assertionElement(document)
+					return before + assertion + `<saml:Assertion ID="_forged"></saml:Assertion>` + after
+				}
+			},
+			wantErr: "saml response must contain exactly one assertion, found 2",
+		},
+		{
+			name: "unsigned assertion in a response signed elsewhere",
+			message: func(m *message) {
+				m.signResponse = true
+				m.edit = replaceOnce(`ID="_response1"`, `ID="_other"`)
+			},
+			wantErr: "saml signature does not reference the signed element",
+		},
+		{
+			name:    "reference to another element",
+			message: func(m *message) { m.signing.uri = "#_response1" },
+			wantErr: "saml signature does not reference the signed element",
+		},
+		{
+			name:    "two references",
+			message: func(m *message) { m.signing.extraReference = true },
+			wantErr: "saml signature must have exactly one reference",
+		},
+		{
+			name:    "inclusive canonicalization",
+			message: func(m *message) { m.signing.canonicalization = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315" },
+			wantErr: "unsupported saml canonicalization method",
+		},
+		{
+			name: "xpath transform",
+			message: func(m *message) {
+				m.signing.transforms = `<ds:Transform Algorithm="http://www.w3.org/TR/1999/REC-xpath-19991116"></ds:Transform>`
+			},
+			wantErr: `unsupported saml transform "http://www.w3.org/TR/1999/REC-xpath-19991116"`,
+		},
+		{
+			name: "md5 digest",
+			message: func(m *message) {
+				m.edit = replaceOnce(`<ds:DigestMethod Algorithm="`+digestSHA256+`"`, `<ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#md5"`)
+			},
+			wantErr: "unsupported saml digest method",
+		},
+		{
+			name: "hmac signature",
+			message: func(m *message) {
+				m.edit = replaceOnce(`Algorithm="`+rsaSHA256+`"`, `Algorithm="http://www.w3.org/2000/09/xmldsig#hmac-sha1"`)
+			},
+			wantErr: "unsupported saml signature method",
+		},
+		{
+			name: "doctype",
+			message: func(m *message) {
+				m.edit = func(document string) string { return `<!DOCTYPE r [<!ENTITY e "x">]>` + document }
+			},
+			wantErr: "XML directives are not allowed",
+		},
+		{
+			name:    "other destination",
+			message: func(m *message) { m.destination = "https://evil.example.com/acs" },
+			wantErr: "does not match acs url",
+		},
+		{
+			name:    "other issuer",
+			message: func(m *message) { m.issuer = "https://evil.example.com" },
+			wantErr: "saml assertion issuer does not match the configured identity provider",
+		},
+		{
+			name:    "other audience",
+			message: func(m *message) { m.audience = "https://other.example.com" },
+			wantErr: "saml assertion is not intended for this service provider",
+		},
+		{
+			name:    "expired",
+			message: func(m *message) { m.notOnOrAfter = time.Now().Add(-5 * time.Minute) },
+			wantErr: "saml assertion has expired",
+		},
+		{
+			name:    "other recipient",
+			message: func(m *message) { m.recipient = "https://evil.example.com/acs" },
+			wantErr: "saml assertion has no valid bearer subject confirmation",
+		},
+		{
+			name: "failed status",
+			message: func(m *message) {
+				m.unsigned = true
+				m.edit = replaceOnce(`<samlp:StatusCode Value="`+statusSuccess+`"/>`,
+					`<samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Responder"/><samlp:StatusMessage>User locked</samlp:StatusMessage>`)
+			},
+			wantErr: "identity provider returned an unsuccessful status: User locked",
+		},
+		{
+			// Elements are matched by namespace URI, not by the prefix or local name alone
+			name:    "response of another namespace",
+			message: func(m *message) { m.edit = replaceOnce(`xmlns:samlp="`+protocolNS+`"`, `xmlns:samlp="urn:forged"`) },
+			wantErr: "saml message is not a Response",
+		},
+		{
+			name:    "assertion of another namespace",
+			message: func(m *message) { m.edit = replaceOnce("<saml:Assertion ", `<saml:Assertion xmlns:saml="urn:forged" `) },
+			wantErr: "saml response must contain exactly one assertion, found 0",
+		},
+		{
+			name: "signature of another namespace",
+			message: func(m *message) {
+				m.edit = replaceOnce(`<ds:Signature xmlns:ds="`+dsigNS+`"`, `<ds:Signature xmlns:ds="urn:forged"`)
+			},
+			wantErr: "saml response is not signed",
+		},
+		{
+			name: "encrypted assertion",
+			message: func(m *message) {
+				m.edit = func(document string) string {
+					before, _, after := assertionElement(document)
+					return before + "<saml:EncryptedAssertion/>" + after
+				}
+			},
+			wantErr: "encrypted saml assertions are not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := validMessage(t)
+			tt.message(&m)
+
+			assertion, err := testServiceProvider(t).ParseResponse(m.build(t))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ParseResponse() = %+v, %v, want error %s", assertion, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseResponseReplay(t *testing.T) {
+	sp := testServiceProvider(t)
+	response := validMessage(t).build(t)
+
+	if _, err := sp.ParseResponse(response); err != nil {
+		t.Fatalf("first ParseResponse() error = %v", err)
+	}
+	if _, err := sp.ParseResponse(response); err == nil || err.Error() != "saml assertion has already been used" {
+		t.Errorf("replayed ParseResponse() error = %v, want already used", err)
+	}
+}
+
+func TestVerifySignatureRequiresRSA(t *testing.T) {
+	sp := testServiceProvider(t)
+	sp.idpCertificate = &x509.Certificate{PublicKey: "not a key"}
+
+	if _, err := sp.ParseResponse(validMessage(t).build(t)); err == nil || err.Error() != "saml idp certificate must contain an RSA public key" {
+		t.Errorf("ParseResponse() error = %v", err)
+	}
+}
+
+func TestNewServiceProviderCertificate(t *testing.T) {
+	_, certificate, _ := testKeys(t)
+	dir := t.TempDir()
+	write := func(name string, content []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	pemFile := write("idp.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw}))
+	// IdP metadata carries the certificate as wrapped base64 without the PEM armour
+	encoded := base64.StdEncoding.EncodeToString(certificate.Raw)
+	bareFile := write("idp.txt", []byte(encoded[:64]+"\n  "+encoded[64:]+"\n"))
+	derFile := write("idp.cer", certificate.Raw)
+	badFile := write("bad.pem", []byte("not a certificate"))
+
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{name: "pem", file: pemFile},
+		{name: "bare base64", file: bareFile},
+		{name: "der", file: derFile},
+		{name: "not configured", file: "", wantErr: "saml idp certificate file is not configured"},
+		{name: "missing", file: filepath.Join(dir, "missing.pem"), wantErr: "error reading saml idp certificate"},
+		{name: "garbage", file: badFile, wantErr: "error parsing saml idp certificate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sp, err := NewServiceProvider(config.SAMLConfig{
+				EntityID:           testEntity,
+				ACSURL:             testACS,
+				IdPSSOURL:          "https://idp.example.com/sso",
+				IdPCertificateFile: tt.file,
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewServiceProvider() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewServiceProvider() error = %v", err)
+			}
+			if !sp.idpCertificate.Equal(certificate) || sp.clockSkew != 3*time.Minute {
+				t.Errorf("certificate or clock skew not loaded: %v", sp.clockSkew)
+			}
+		})
+	}
+}
@@ -0,0 +1,421 @@
+// Package saml is the service provider side of SAML 2.0 single sign-on.
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"erp-excel/config"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	protocolNS    = "urn:oasis:names:tc:SAML:2.0:protocol"
+	assertionNS   = "urn:oasis:names:tc:SAML:2.0:assertion"
+	bindingPOST   = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	statusSuccess = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	bearerMethod  = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	nameIDFormat  = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+)
+
+// Assertion holds the verified identity from a SAML response
+type Assertion struct {
+	NameID       string
+	SessionIndex string
+	Attributes   map[string][]string
+}
+
+// Attribute returns the first value of an attribute, or an empty string
+func (a *Assertion) Attribute(name string) string {
+	if values := a.Attributes[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// ServiceProvider implements the SP side of SAML 2.0 Web Browser SSO
+// (HTTP-Redirect binding for requests, HTTP-POST binding for responses)
+type ServiceProvider struct {
+	entityID       string
+	acsURL         string
+	idpEntityID    string
+	idpSSOURL      string
+	idpCertificate *x509.Certificate
+	clockSkew      time.Duration
+
+	// Assertion IDs already consumed, kept until they expire, to stop replays
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewServiceProvider creates a service provider from configuration
+func NewServiceProvider(cfg config.SAMLConfig) (*ServiceProvider, error) {
+	if cfg.EntityID == "" || cfg.ACSURL == "" {
+		return nil, errors.New("saml entity id and acs url are required")
+	}
+	if cfg.IdPSSOURL == "" {
+		return nil, errors.New("saml idp sso url is required")
+	}
+
+	certificate, err := loadCertificate(cfg.IdPCertificateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	clockSkew := time.Duration(cfg.ClockSkewSeconds) * time.Second
+	if clockSkew <= 0 {
+		clockSkew = 3 * time.Minute
+	}
+
+	return &ServiceProvider{
+		entityID:       cfg.EntityID,
+		acsURL:         cfg.ACSURL,
+		idpEntityID:    cfg.IdPEntityID,
+		idpSSOURL:      cfg.IdPSSOURL,
+		idpCertificate: certificate,
+		clockSkew:      clockSkew,
+		seen:           make(map[string]time.Time),
+	}, nil
+}
+
+// Metadata returns the SP metadata document to register with the identity provider
+func (sp *ServiceProvider) Metadata() ([]byte, error) {
+	type nameIDFormatElement struct {
+		Value string `xml:",chardata"`
+	}
+	type endpoint struct {
+		Binding  string `xml:"Binding,attr"`
+		Location string `xml:"Location,attr"`
+		Index    int    `xml:"index,attr"`
+	}
+	type spDescriptor struct {
+		AuthnRequestsSigned        bool                `xml:"AuthnRequestsSigned,attr"`
+		WantAssertionsSigned       bool                `xml:"WantAssertionsSigned,attr"`
+		ProtocolSupportEnumeration string              `xml:"protocolSupportEnumeration,attr"`
+		NameIDFormat               nameIDFormatElement `xml:"NameIDFormat"`
+		AssertionConsumerService   endpoint            `xml:"AssertionConsumerService"`
+	}
+	type entityDescriptor struct {
+		XMLName         xml.Name     `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+		EntityID        string       `xml:"entityID,attr"`
+		SPSSODescriptor spDescriptor `xml:"SPSSODescriptor"`
+	}
+
+	metadata := entityDescriptor{
+		EntityID: sp.entityID,
+		SPSSODescriptor: spDescriptor{
+			AuthnRequestsSigned:        false,
+			WantAssertionsSigned:       true,
+			ProtocolSupportEnumeration: protocolNS,
+			NameIDFormat:               nameIDFormatElement{Value: nameIDFormat},
+			AssertionConsumerService: endpoint{
+				Binding:  bindingPOST,
+				Location: sp.acsURL,
+				Index:    0,
+			},
+		},
+	}
+
+	output, err := xml.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error building saml metadata: %w", err)
+	}
+
+	return append([]byte(xml.Header), output...), nil
+}
+
+// AuthnRequestURL builds the identity provider redirect URL that starts a login
+func (sp *ServiceProvider) AuthnRequestURL(relayState string) (string, error) {
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+
+	var request bytes.Buffer
+	fmt.Fprintf(&request,
+		`<samlp:AuthnRequest xmlns:samlp="%s" xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s" Destination="%s" AssertionConsumerServiceURL="%s" ProtocolBinding="%s">`,
+		protocolNS, assertionNS, id, time.Now().UTC().Format(time.RFC3339),
+		escapeAttr(sp.idpSSOURL), escapeAttr(sp.acsURL), bindingPOST,
+	)
+	fmt.Fprintf(&request, `<saml:Issuer>%s</saml:Issuer>`, escapeText(sp.entityID))
+	request.WriteString(`<samlp:NameIDPolicy AllowCreate="true"/>`)
+	request.WriteString(`</samlp:AuthnRequest>`)
+
+	// HTTP-Redirect binding: raw DEFLATE, then base64, then URL encoding
+	var compressed bytes.Buffer
+	writer, err := flate.NewWriter(&compressed, flate.BestCompression)
+	if err != nil {
+		return "", fmt.Errorf("error compressing authn request: %w", err)
+	}
+	if _, err := writer.Write(request.Bytes()); err != nil {
+		return "", fmt.Errorf("error compressing authn request: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("error compressing authn request: %w", err)
+	}
+
+	redirect, err := url.Parse(sp.idpSSOURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid saml idp sso url: %w", err)
+	}
+	query := redirect.Query()
+	query.Set("SAMLRequest", base64.StdEncoding.EncodeToString(compressed.Bytes()))
+	if relayState != "" {
+		query.Set("RelayState", relayState)
+	}
+	redirect.RawQuery = query.Encode()
+
+	return redirect.String(), nil
+}
+
+// ParseResponse decodes and validates a base64 SAMLResponse posted to the ACS endpoint
+func (sp *ServiceProvider) ParseResponse(encoded string) (*Assertion, error) {
+	raw, err := base64.StdEncoding.DecodeString(stripSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding saml response: %w", err)
+	}
+
+	root, err := parseXML(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !root.is(protocolNS, "Response") {
+		return nil, errors.New("saml message is not a Response")
+	}
+
+	if destination := root.attr("Destination"); destination != "" && destination != sp.acsURL {
+		return nil, fmt.Errorf("saml response destination %q does not match acs url", destination)
+	}
+
+	statusCode := root.path(protocolNS, "Status", "StatusCode")
+	if statusCode == nil || statusCode.attr("Value") != statusSuccess {
+		message := ""
+		if statusMessage := root.path(protocolNS, "Status", "StatusMessage"); statusMessage != nil {
+			message = statusMessage.text()
+		}
+		return nil, fmt.Errorf("identity provider returned an unsuccessful status: %s", message)
+	}
+
+	if len(root.childrenNamed(assertionNS, "EncryptedAssertion")) > 0 {
+		return nil, errors.New("encrypted saml assertions are not supported")
+	}
+	assertions := root.childrenNamed(assertionNS, "Assertion")
+	if len(assertions) != 1 {
+		return nil, fmt.Errorf("saml response must contain exactly one assertion, found %d", len(assertions))
+	}
+	assertion := assertions[0]
+
+	// Either the assertion itself or the enclosing response has to carry a valid signature
+	if assertion.child(dsigNS, "Signature") != nil {
+		if err := sp.verifySignature(root, assertion); err != nil {
+			return nil, err
+		}
+	} else if root.child(dsigNS, "Signature") != nil {
+		if err := sp.verifySignature(root, root); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, errors.New("saml response is not signed")
+	}
+
+	return sp.validateAssertion(assertion)
+}
+
+// validateAssertion checks issuer, conditions and subject confirmation, then extracts the identity
+func (sp *ServiceProvider) validateAssertion(assertion *element) (*Assertion, error) {
+	now := time.Now()
+
+	if sp.idpEntityID != "" {
+		issuer := assertion.child(assertionNS, "Issuer")
+		if issuer == nil || issuer.text() != sp.idpEntityID {
+			return nil, errors.New("saml assertion issuer does not match the configured identity provider")
+		}
+	}
+
+	expiresAt := now.Add(time.Hour)
+	if conditions := assertion.child(assertionNS, "Conditions"); conditions != nil {
+		if err := sp.checkWindow(now, conditions.attr("NotBefore"), conditions.attr("NotOnOrAfter")); err != nil {
+			return nil, err
+		}
+		if notOnOrAfter, err := parseTime(conditions.attr("NotOnOrAfter")); err == nil && !notOnOrAfter.IsZero() {
+			expiresAt = notOnOrAfter
+		}
+
+		for _, restriction := range conditions.childrenNamed(assertionNS, "AudienceRestriction") {
+			matched := false
+			for _, audience := range restriction.childrenNamed(assertionNS, "Audience") {
+				if audience.text() == sp.entityID {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, errors.New("saml assertion is not intended for this service provider")
+			}
+		}
+	}
+
+	subject := assertion.child(assertionNS, "Subject")
+	if subject == nil {
+		return nil, errors.New("saml assertion has no subject")
+	}
+
+	confirmed := false
+	for _, confirmation := range subject.childrenNamed(assertionNS, "SubjectConfirmation") {
+		if confirmation.attr("Method") != bearerMethod {
+			continue
+		}
+		data := confirmation.child(assertionNS, "SubjectConfirmationData")
+		if data == nil {
+			continue
+		}
+		if recipient := data.attr("Recipient"); recipient != "" && recipient != sp.acsURL {
+			continue
+		}
+		if err := sp.checkWindow(now, data.attr("NotBefore"), data.attr("NotOnOrAfter")); err != nil {
+			continue
+		}
+		confirmed = true
+		break
+	}
+	if !confirmed {
+		return nil, errors.New("saml assertion has no valid bearer subject confirmation")
+	}
+
+	if err := sp.markConsumed(assertion.attr("ID"), expiresAt, now); err != nil {
+		return nil, err
+	}
+
+	result := &Assertion{Attributes: make(map[string][]string)}
+	if nameID := subject.child(assertionNS, "NameID"); nameID != nil {
+		result.NameID = nameID.text()
+	}
+	if authnStatement := assertion.child(assertionNS, "AuthnStatement"); authnStatement != nil {
+		result.SessionIndex = authnStatement.attr("SessionIndex")
+	}
+	for _, statement := range assertion.childrenNamed(assertionNS, "AttributeStatement") {
+		for _, attribute := range statement.childrenNamed(assertionNS, "Attribute") {
+			var values []string
+			for _, value := range attribute.childrenNamed(assertionNS, "AttributeValue") {
+				values = append(values, value.text())
+			}
+			if name := attribute.attr("Name"); name != "" {
+				result.Attributes[name] = append(result.Attributes[name], values...)
+			}
+			if friendlyName := attribute.attr("FriendlyName"); friendlyName != "" {
+				result.Attributes[friendlyName] = append(result.Attributes[friendlyName], values...)
+			}
+		}
+	}
+
+	if result.NameID == "" {
+		return nil, errors.New("saml assertion has no name id")
+	}
+
+	return result, nil
+}
+
+// checkWindow validates NotBefore/NotOnOrAfter timestamps allowing for clock skew
+func (sp *ServiceProvider) checkWindow(now time.Time, notBefore, notOnOrAfter string) error {
+	start, err := parseTime(notBefore)
+	if err != nil {
+		return err
+	}
+	end, err := parseTime(notOnOrAfter)
+	if err != nil {
+		return err
+	}
+
+	if !start.IsZero() && now.Add(sp.clockSkew).Before(start) {
+		return errors.New("saml assertion is not yet valid")
+	}
+	if !end.IsZero() && !now.Add(-sp.clockSkew).Before(end) {
+		return errors.New("saml assertion has expired")
+	}
+
+	return nil
+}
+
+// markConsumed records an assertion ID and rejects it if it was already used
+func (sp *ServiceProvider) markConsumed(id string, expiresAt, now time.Time) error {
+	if id == "" {
+		return errors.New("saml assertion has no id")
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	for seenID, expiry := range sp.seen {
+		if now.After(expiry.Add(sp.clockSkew)) {
+			delete(sp.seen, seenID)
+		}
+	}
+
+	if _, ok := sp.seen[id]; ok {
+		return errors.New("saml assertion has already been used")
+	}
+	sp.seen[id] = expiresAt
+
+	return nil
+}
+
+// loadCertificate reads the identity provider signing certificate (PEM, or bare base64 as found in IdP metadata)
+func loadCertificate(path string) (*x509.Certificate, error) {
+	if path == "" {
+		return nil, errors.New("saml idp certificate file is not configured")
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading saml idp certificate: %w", err)
+	}
+
+	der := raw
+	if block, _ := pem.Decode(raw); block != nil {
+		der = block.Bytes
+	} else if decoded, err := base64.StdEncoding.DecodeString(stripSpace(string(raw))); err == nil {
+		der = decoded
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing saml idp certificate: %w", err)
+	}
+
+	return certificate, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid saml timestamp %q", value)
+	}
+	return parsed, nil
+}
+
+func newID() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generating request id: %w", err)
+	}
+	// IDs must be valid xsd:ID values, which cannot start with a digit
+	return "_" + hex.EncodeToString(buf), nil
+}
+
+func stripSpace(value string) string {
+	return strings.Join(strings.Fields(value), "")
+}
@@ -0,0 +1,359 @@
+package saml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// element is a minimal XML tree node that keeps raw prefixes, which
+// encoding/xml's struct decoding throws away but canonicalization needs
+type element struct {
+	Prefix   string
+	Local    string
+	Attrs    []xml.Attr // raw attributes, including xmlns declarations
+	Children []node
+	Parent   *element
+}
+
+// node is either an *element or a text chunk
+type node struct {
+	Elem *element
+	Text string
+}
+
+// parseXML builds an element tree from raw XML
+func parseXML(data []byte) (*element, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(normalizeAttributes(data)))
+
+	var root *element
+	var current *element
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing XML: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			el := &element{
+				Prefix: t.Name.Space,
+				Local:  t.Name.Local,
+				Attrs:  append([]xml.Attr(nil), t.Attr...),
+				Parent: current,
+			}
+			if current == nil {
+				if root != nil {
+					return nil, errors.New("XML document has multiple root elements")
+				}
+				root = el
+			} else {
+				current.Children = append(current.Children, node{Elem: el})
+			}
+			current = el
+		case xml.EndElement:
+			if current == nil {
+				return nil, errors.New("unexpected closing tag")
+			}
+			current = current.Parent
+		case xml.CharData:
+			if current != nil {
+				current.Children = append(current.Children, node{Text: string(t)})
+			}
+		case xml.Directive:
+			// DTDs are never legitimate in SAML messages and enable entity tricks
+			return nil, errors.New("XML directives are not allowed")
+		}
+	}
+
+	if root == nil {
+		return nil, errors.New("empty XML document")
+	}
+
+	return root, nil
+}
+
+// normalizeAttributes turns the literal tabs and line breaks of attribute values into spaces, as
+// XML parsers do (XML 1.0 section 3.3.3) and encoding/xml does not, so that canonicalization sees
+// the values the signer saw. Character references such as &#xA; are left alone.
+func normalizeAttributes(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	var quote byte
+	inTag := false
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case quote != 0:
+			switch {
+			case b == quote:
+				quote = 0
+			case b == '\r' && i+1 < len(data) && data[i+1] == '\n':
+				continue
+			case b == '\t' || b == '\n' || b == '\r':
+				b = ' '
+			}
+		case inTag:
+			if b == '"' || b == '\'' {
+				quote = b
+			} else if b == '>' {
+				inTag = false
+			}
+		case b == '<':
+			// Comments, CDATA sections and processing instructions are copied as they are
+			if end := sectionEnd(data[i:]); end > 0 {
+				out = append(out, data[i:i+end]...)
+				i += end - 1
+				continue
+			}
+			inTag = true
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// sectionEnd returns the length of the comment, CDATA section or processing instruction data
+// starts with, all of it when unterminated, or 0 when it starts with none
+func sectionEnd(data []byte) int {
+	for _, section := range [][2]string{{"<!--", "-->"}, {"<![CDATA[", "]]>"}, {"<?", "?>"}} {
+		if bytes.HasPrefix(data, []byte(section[0])) {
+			end := bytes.Index(data[len(section[0]):], []byte(section[1]))
+			if end < 0 {
+				return len(data)
+			}
+			return len(section[0]) + end + len(section[1])
+		}
+	}
+	return 0
+}
+
+// attr returns the value of an unprefixed attribute
+func (e *element) attr(name string) string {
+	for _, a := range e.Attrs {
+		if a.Name.Space == "" && a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// text returns the concatenated character data of the element
+func (e *element) text() string {
+	var sb strings.Builder
+	for _, child := range e.Children {
+		if child.Elem == nil {
+			sb.WriteString(child.Text)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// is reports whether the element has the given namespace URI and local name
+func (e *element) is(space, local string) bool {
+	if e.Local != local {
+		return false
+	}
+	uri, _ := e.namespaceURI(e.Prefix)
+	return uri == space
+}
+
+// child returns the first direct child with the given namespace URI and local name
+func (e *element) child(space, local string) *element {
+	for _, c := range e.Children {
+		if c.Elem != nil && c.Elem.is(space, local) {
+			return c.Elem
+		}
+	}
+	return nil
+}
+
+// childrenNamed returns all direct children with the given namespace URI and local name
+func (e *element) childrenNamed(space, local string) []*element {
+	var result []*element
+	for _, c := range e.Children {
+		if c.Elem != nil && c.Elem.is(space, local) {
+			result = append(result, c.Elem)
+		}
+	}
+	return result
+}
+
+// path walks down direct children of one namespace by local name
+func (e *element) path(space string, locals ...string) *element {
+	current := e
+	for _, local := range locals {
+		if current == nil {
+			return nil
+		}
+		current = current.child(space, local)
+	}
+	return current
+}
+
+// findByID searches the subtree for an element with the given ID attribute
+func (e *element) findByID(id string) *element {
+	if e.attr("ID") == id {
+		return e
+	}
+	for _, c := range e.Children {
+		if c.Elem != nil {
+			if found := c.Elem.findByID(id); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+// countIDs counts elements in the subtree carrying the given ID (used to reject wrapping attacks)
+func (e *element) countIDs(id string) int {
+	count := 0
+	if e.attr("ID") == id {
+		count++
+	}
+	for _, c := range e.Children {
+		if c.Elem != nil {
+			count += c.Elem.countIDs(id)
+		}
+	}
+	return count
+}
+
+// namespaceURI resolves a prefix using declarations on this element and its ancestors
+func (e *element) namespaceURI(prefix string) (string, bool) {
+	for el := e; el != nil; el = el.Parent {
+		for _, a := range el.Attrs {
+			if prefix == "" && a.Name.Space == "" && a.Name.Local == "xmlns" {
+				return a.Value, true
+			}
+			if prefix != "" && a.Name.Space == "xmlns" && a.Name.Local == prefix {
+				return a.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// canonicalize serializes the element with Exclusive XML Canonicalization 1.0 (without comments).
+// skip, when set, is left out of the output (the enveloped signature transform).
+// inclusive lists prefixes from an InclusiveNamespaces PrefixList.
+func canonicalize(e *element, skip *element, inclusive []string) []byte {
+	var buf bytes.Buffer
+	writeCanonical(&buf, e, skip, map[string]string{}, inclusive)
+	return buf.Bytes()
+}
+
+func writeCanonical(buf *bytes.Buffer, e *element, skip *element, rendered map[string]string, inclusive []string) {
+	// Work out which namespace declarations this element has to emit
+	used := map[string]bool{e.Prefix: true}
+	for _, a := range e.Attrs {
+		if a.Name.Space != "" && a.Name.Space != "xmlns" && a.Name.Space != "xml" {
+			used[a.Name.Space] = true
+		}
+	}
+	for _, prefix := range inclusive {
+		if prefix == "#default" {
+			prefix = ""
+		}
+		if _, ok := e.namespaceURI(prefix); ok {
+			used[prefix] = true
+		}
+	}
+
+	type nsDecl struct{ prefix, uri string }
+	var decls []nsDecl
+	scope := make(map[string]string, len(rendered))
+	for k, v := range rendered {
+		scope[k] = v
+	}
+	for prefix := range used {
+		uri, _ := e.namespaceURI(prefix)
+		previous, seen := rendered[prefix]
+		if prefix == "" && uri == "" && (!seen || previous == "") {
+			continue
+		}
+		if seen && previous == uri {
+			continue
+		}
+		decls = append(decls, nsDecl{prefix, uri})
+		scope[prefix] = uri
+	}
+	sort.Slice(decls, func(i, j int) bool { return decls[i].prefix < decls[j].prefix })
+
+	// Regular attributes are sorted by namespace URI, then local name
+	type attribute struct{ uri, name, value string }
+	var attrs []attribute
+	for _, a := range e.Attrs {
+		if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+			continue
+		}
+		uri := ""
+		name := a.Name.Local
+		if a.Name.Space != "" {
+			name = a.Name.Space + ":" + a.Name.Local
+			if a.Name.Space == "xml" {
+				uri = "http://www.w3.org/XML/1998/namespace"
+			} else {
+				uri, _ = e.namespaceURI(a.Name.Space)
+			}
+		}
+		attrs = append(attrs, attribute{uri, name, a.Value})
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].uri != attrs[j].uri {
+			return attrs[i].uri < attrs[j].uri
+		}
+		return localPart(attrs[i].name) < localPart(attrs[j].name)
+	})
+
+	name := e.Local
+	if e.Prefix != "" {
+		name = e.Prefix + ":" + e.Local
+	}
+
+	buf.WriteString("<" + name)
+	for _, d := range decls {
+		if d.prefix == "" {
+			buf.WriteString(` xmlns="` + escapeAttr(d.uri) + `"`)
+		} else {
+			buf.WriteString(` xmlns:` + d.prefix + `="` + escapeAttr(d.uri) + `"`)
+		}
+	}
+	for _, a := range attrs {
+		buf.WriteString(" " + a.name + `="` + escapeAttr(a.value) + `"`)
+	}
+	buf.WriteString(">")
+
+	for _, c := range e.Children {
+		if c.Elem == nil {
+			buf.WriteString(escapeText(c.Text))
+			continue
+		}
+		if c.Elem == skip {
+			continue
+		}
+		writeCanonical(buf, c.Elem, skip, scope, inclusive)
+	}
+
+	buf.WriteString("</" + name + ">")
+}
+
+func localPart(name string) string {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+var attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+
+func escapeAttr(s string) string { return attrEscaper.Replace(s) }
+func escapeText(s string) string { return textEscaper.Replace(s) }
@@ -0,0 +1,276 @@
+package saml
+
+import (
+	"strings"
+	"testing"
+)
+
+// The examples of the W3C Canonical XML recommendation (section 3) and of the Exclusive XML
+// Canonicalization recommendation (section 2.2), with the DTDs left out since SAML messages
+// cannot carry any; the outputs differ from the inclusive ones only where namespace
+// declarations are not visibly used.
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		subtree   []string // namespace URI and local name of the child of the root canonicalized, the root when empty
+		inclusive []string
+		want      string
+	}{
+		{
+			name: "start and end tags",
+			input: `<doc>
+   <e1   />
+   <e2   ></e2>
+   <e3   name = "elem3"   id="elem3"   />
+   <e4   name="elem4"   id="elem4"   ></e4>
+   <e5 a:attr="out" b:attr="sorted" attr2="all" attr="I'm"
+      xmlns:b="http://www.ietf.org"
+      xmlns:a="http://www.w3.org"
+      xmlns="http://example.org"/>
+   <e6 xmlns="" xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="" xmlns:a="http://www.w3.org">
+            <e9 xmlns="" xmlns:a="http://www.ietf.org"/>
+         </e8>
+      </e7>
+   </e6>
+</doc>`,
+			want: `<doc>
+   <e1></e1>
+   <e2></e2>
+   <e3 id="elem3" name="elem3"></e3>
+   <e4 id="elem4" name="elem4"></e4>
+   <e5 xmlns="http://example.org" xmlns:a="http://www.w3.org" xmlns:b="http://www.ietf.org" attr="I'm" attr2="all" b:attr="sorted" a:attr="out"></e5>
+   <e6>
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="">
+            <e9></e9>
+         </e8>
+      </e7>
+   </e6>
+</doc>`,
+		},
+		{
+			name: "character modifications and character references",
+			input: `<doc>
+   <text>First line&#x0d;&#10;Second line</text>
+   <value>&#x32;</value>
+   <compute><![CDATA[value>"0" && value<"10" ?"valid":"error"]]></compute>
+   <compute expr='value>"0" &amp;&amp; value&lt;"10" ?"valid":"error"'>valid</compute>
+   <norm attr=' &apos;   &#x20;&#13;&#xa;&#9;   &apos; '/>
+   <normNames attr='   A   &#x20;&#13;&#xa;&#9;   B   '/>
+</doc>`,
+			want: `<doc>
+   <text>First line&#xD;
+Second line</text>
+   <value>2</value>
+   <compute>value&gt;"0" &amp;&amp; value&lt;"10" ?"valid":"error"</compute>
+   <compute expr="value>&quot;0&quot; &amp;&amp; value&lt;&quot;10&quot; ?&quot;valid&quot;:&quot;error&quot;">valid</compute>
+   <norm attr=" '    &#xD;&#xA;&#x9;   ' "></norm>
+   <normNames attr="   A    &#xD;&#xA;&#x9;   B   "></normNames>
+</doc>`,
+		},
+		{
+			name:  "comments and processing instructions outside the element",
+			input: "<?xml version=\"1.0\"?>\n<!-- before -->\n<doc><!-- inside -->text<a/></doc>\n<!-- after -->",
+			want:  "<doc>text<a></a></doc>",
+		},
+		{
+			name:  "literal whitespace in attributes",
+			input: "<doc a=\"one\ntwo\tthree\r\nfour\rfive\" b='&#xA;&#x9;'>\r\nline\r\n</doc>",
+			want:  "<doc a=\"one two three four five\" b=\"&#xA;&#x9;\">\nline\n</doc>",
+		},
+		{
+			name:    "subtree without the unused namespaces of its ancestors",
+			input:   `<n0:local xmlns:n0="foo:bar" xmlns:n3="ftp://example.org"><n1:elem2 xmlns:n1="http://example.net" xml:lang="en"><n3:stuff xmlns:n3="ftp://example.org"/></n1:elem2></n0:local>`,
+			subtree: []string{"http://example.net", "elem2"},
+			want:    `<n1:elem2 xmlns:n1="http://example.net" xml:lang="en"><n3:stuff xmlns:n3="ftp://example.org"></n3:stuff></n1:elem2>`,
+		},
+		{
+			name:    "subtree without the xml attributes of its ancestors",
+			input:   `<n2:pdu xmlns:n1="http://example.com" xmlns:n2="http://foo.example" xml:lang="fr" xml:space="retain"><n1:elem2 xmlns:n1="http://example.net" xml:lang="en"><n3:stuff xmlns:n3="ftp://example.org"/></n1:elem2></n2:pdu>`,
+			subtree: []string{"http://example.net", "elem2"},
+			want:    `<n1:elem2 xmlns:n1="http://example.net" xml:lang="en"><n3:stuff xmlns:n3="ftp://example.org"></n3:stuff></n1:elem2>`,
+		},
+		{
+			name:      "inclusive namespace prefix",
+			input:     `<n0:local xmlns:n0="foo:bar" xmlns:n3="ftp://example.org"><n1:elem2 xmlns:n1="http://example.net"><n1:child/></n1:elem2></n0:local>`,
+			subtree:   []string{"http://example.net", "elem2"},
+			inclusive: []string{"n0", "unknown"},
+			want:      `<n1:elem2 xmlns:n0="foo:bar" xmlns:n1="http://example.net"><n1:child></n1:child></n1:elem2>`,
+		},
+		{
+			name:      "inclusive default namespace",
+			input:     `<root xmlns="urn:default" xmlns:p="urn:p"><p:a><p:b/></p:a></root>`,
+			subtree:   []string{"urn:p", "a"},
+			inclusive: []string{"#default"},
+			want:      `<p:a xmlns="urn:default" xmlns:p="urn:p"><p:b></p:b></p:a>`,
+		},
+		{
+			name:    "namespace inherited from an ancestor left out",
+			input:   `<root xmlns:p="urn:p" xmlns:q="urn:q"><p:a q:attr="1"><p:b/><q:c/></p:a></root>`,
+			subtree: []string{"urn:p", "a"},
+			want:    `<p:a xmlns:p="urn:p" xmlns:q="urn:q" q:attr="1"><p:b></p:b><q:c></q:c></p:a>`,
+		},
+		{
+			name:    "namespace redeclared with another uri",
+			input:   `<p:a xmlns:p="urn:one"><p:b xmlns:p="urn:one"><p:c xmlns:p="urn:two"/></p:b></p:a>`,
+			subtree: nil,
+			want:    `<p:a xmlns:p="urn:one"><p:b><p:c xmlns:p="urn:two"></p:c></p:b></p:a>`,
+		},
+		{
+			name:  "attributes sorted by namespace uri before local name",
+			input: `<a xmlns:z="urn:a" xmlns:y="urn:b" y:first="1" z:second="2" b="3" xml:lang="en" a="4"/>`,
+			want:  `<a xmlns:y="urn:b" xmlns:z="urn:a" a="4" b="3" xml:lang="en" z:second="2" y:first="1"></a>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := parseXML([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("parseXML() error = %v", err)
+			}
+			target := root
+			if len(tt.subtree) == 2 {
+				target = root.child(tt.subtree[0], tt.subtree[1])
+			}
+			if target == nil {
+				t.Fatalf("no element at %v", tt.subtree)
+			}
+			if got := string(canonicalize(target, nil, tt.inclusive)); got != tt.want {
+				t.Errorf("canonicalize() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeSkipsEnvelopedSignature(t *testing.T) {
+	root, err := parseXML([]byte(`<a ID="x"><b>1</b><ds:Signature xmlns:ds="urn:dsig"><ds:SignedInfo/></ds:Signature><c>2</c></a>`))
+	if err != nil {
+		t.Fatalf("parseXML() error = %v", err)
+	}
+	want := `<a ID="x"><b>1</b><c>2</c></a>`
+	if got := string(canonicalize(root, root.child("urn:dsig", "Signature"), nil)); got != want {
+		t.Errorf("canonicalize() = %s, want %s", got, want)
+	}
+}
+
+func TestParseXMLErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"empty", "", "empty XML document"},
+		{"only a comment", "<!-- nothing -->", "empty XML document"},
+		{"two roots", "<a/><b/>", "XML document has multiple root elements"},
+		{"closing tag without an element", "<a></a></b>", "unexpected closing tag"},
+		{"doctype", `<!DOCTYPE a [<!ENTITY e "x">]><a>&e;</a>`, "XML directives are not allowed"},
+		{"doctype inside", `<a><!DOCTYPE a></a>`, "XML directives are not allowed"},
+		{"unknown entity", `<a>&e;</a>`, "error parsing XML"},
+		{"unterminated", `<a attr="1`, "error parsing XML"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseXML([]byte(tt.input)); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseXML() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNormalizeAttributes(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"text left alone", "<a>x\ty\nz</a>", "<a>x\ty\nz</a>"},
+		{"double quotes", "<a b=\"x\ty\nz\"/>", "<a b=\"x y z\"/>"},
+		{"single quotes", "<a b='x\r\ny'/>", "<a b='x y'/>"},
+		{"other quote inside", "<a b='say \"hi\n\"' c=\"it's\n\"/>", "<a b='say \"hi \"' c=\"it's \"/>"},
+		{"greater-than inside a value", "<a b='>\n'>\n</a>", "<a b='> '>\n</a>"},
+		{"comment", "<a><!-- b='\n' --></a>", "<a><!-- b='\n' --></a>"},
+		{"cdata", "<a><![CDATA[ b='\n' ]]></a>", "<a><![CDATA[ b='\n' ]]></a>"},
+		{"processing instruction", "<?pi b='\n'?><a/>", "<?pi b='\n'?><a/>"},
+		{"unterminated comment", "<a><!-- b='\n'", "<a><!-- b='\n'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(normalizeAttributes([]byte(tt.input))); got != tt.want {
+				t.Errorf("normalizeAttributes(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestElementLookups(t *testing.T) {
+	root, err := parseXML([]byte(`<p:root xmlns:p="urn:p" xmlns="urn:default" ID="r">
+  <p:a ID="one"> x <!-- c --> y <b/></p:a>
+  <a ID="two"><p:c ID="one" p:ID="ignored"/></a>
+  <a xmlns="" ID="three"/>
+</p:root>`))
+	if err != nil {
+		t.Fatalf("parseXML() error = %v", err)
+	}
+
+	if root.Prefix != "p" || root.Local != "root" || root.attr("ID") != "r" || root.attr("missing") != "" {
+		t.Errorf("root = %s:%s ID=%q", root.Prefix, root.Local, root.attr("ID"))
+	}
+	if got := root.child("urn:p", "a").text(); got != "x  y" {
+		t.Errorf("text() = %q, want the trimmed character data without the comment", got)
+	}
+	for _, space := range []string{"urn:p", "urn:default", ""} {
+		if got := len(root.childrenNamed(space, "a")); got != 1 {
+			t.Errorf("childrenNamed(%q, a) = %d elements, want 1", space, got)
+		}
+	}
+	// The local name alone does not match an element of another namespace
+	if root.child("urn:other", "a") != nil || root.child("", "root") != nil || !root.is("urn:p", "root") || root.is("", "root") {
+		t.Error("lookups should compare the namespace URI")
+	}
+	if root.child("urn:p", "missing") != nil || root.path("urn:p", "a", "missing", "deeper") != nil {
+		t.Error("lookups of missing elements should return nil")
+	}
+	if got := root.path("urn:default", "a"); got == nil || got.attr("ID") != "two" {
+		t.Errorf("path(urn:default, a) = %v", got)
+	}
+	if got := root.child("urn:p", "a").child("urn:default", "b"); got == nil || got.Local != "b" {
+		t.Errorf("child(urn:default, b) = %v", got)
+	}
+
+	if got := root.findByID("two"); got == nil || got.Local != "a" {
+		t.Errorf("findByID(two) = %v", got)
+	}
+	if got := root.findByID("one"); got == nil || got.Prefix != "p" || got.Local != "a" {
+		t.Errorf("findByID(one) = %v, want the first in document order", got)
+	}
+	if root.findByID("ignored") != nil {
+		t.Error("findByID should only match the unprefixed ID attribute")
+	}
+	if got := root.countIDs("one"); got != 2 {
+		t.Errorf("countIDs(one) = %d, want 2", got)
+	}
+
+	c := root.findByID("two").child("urn:p", "c")
+	for _, tt := range []struct {
+		element *element
+		prefix  string
+		want    string
+		wantOK  bool
+	}{
+		{c, "p", "urn:p", true},
+		{c, "", "urn:default", true},
+		{root.childrenNamed("", "a")[0], "", "", true},
+		{c, "missing", "", false},
+	} {
+		got, ok := tt.element.namespaceURI(tt.prefix)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("namespaceURI(%q) = %q, %t, want %q, %t", tt.prefix, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
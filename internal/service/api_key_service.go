@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrAPIKeyNotFound is returned for an unknown API key ID
+	ErrAPIKeyNotFound = apperror.NotFound("API key not found")
+	// ErrInvalidAPIKey is returned when a presented key is unknown, revoked or expired
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrInvalidAPIKeyRequest is returned for an expiry in the past or a scope the creator may not grant
+	ErrInvalidAPIKeyRequest = apperror.Validation("invalid API key request")
+)
+
+const (
+	// apiKeyPrefix starts every key, so leaked keys are easy to recognise in logs and scanners
+	apiKeyPrefix = "erpk_"
+	// apiKeyLookupLength is the length of the stored prefix, apiKeyPrefix and 8 hex characters
+	apiKeyLookupLength = len(apiKeyPrefix) + 8
+	// apiKeyAllScopes grants every operation
+	apiKeyAllScopes = "*"
+	// apiKeyTouchInterval limits how often the last use of a key is written
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKeyPrincipal is who a request authenticated with an API key acts as: a service account,
+// or the user owning the key
+type APIKeyPrincipal struct {
+	KeyID        int
+	Name         string
+	Prefix       string
+	Scopes       []string
+	UserID       int // 0 for a service account
+	Username     string
+	DepartmentID int
+}
+
+// IsAdmin reports whether the key is a service account key granting every operation
+func (p *APIKeyPrincipal) IsAdmin() bool {
+	return p.UserID == 0 && slices.Contains(p.Scopes, apiKeyAllScopes)
+}
+
+// Allows reports whether the key may use an operation
+func (p *APIKeyPrincipal) Allows(operationCode string) bool {
+	return p.IsAdmin() || slices.Contains(p.Scopes, operationCode)
+}
+
+// APIKeyService issues, revokes and authenticates the API keys of service accounts
+type APIKeyService interface {
+	List(ctx context.Context) ([]*models.APIKey, error)
+	Create(ctx context.Context, userID int, isAdmin bool, request *dto.APIKeyRequest) (*dto.APIKeyCreatedResponse, error)
+	Revoke(ctx context.Context, id int) error
+	ListForUser(ctx context.Context, userID int) ([]*models.APIKey, error)
+	CreateForUser(ctx context.Context, userID int, request *dto.APIKeyRequest) (*dto.APIKeyCreatedResponse, error)
+	RevokeForUser(ctx context.Context, userID, id int) error
+	Authenticate(ctx context.Context, key string) (*APIKeyPrincipal, error)
+}
+
+type apiKeyService struct {
+	apiKeyRepo       repository.APIKeyRepository
+	userRepo         repository.UserRepository
+	operationService OperationService
+	logger           *slog.Logger
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(
+	apiKeyRepo repository.APIKeyRepository,
+	userRepo repository.UserRepository,
+	operationService OperationService,
+	logger *slog.Logger,
+) APIKeyService {
+	return &apiKeyService{
+		apiKeyRepo:       apiKeyRepo,
+		userRepo:         userRepo,
+		operationService: operationService,
+		logger:           logger,
+	}
+}
+
+// List returns every API key, including the revoked and expired ones
+func (s *apiKeyService) List(ctx context.Context) ([]*models.APIKey, error) {
+	keys, err := s.apiKeyRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		keys = []*models.APIKey{}
+	}
+	return keys, nil
+}
+
+// Create issues a new service account key. Administrators may grant any operation; other users
+// only the operations they have themselves, so a key never widens its creator's access.
+func (s *apiKeyService) Create(ctx context.Context, userID int, isAdmin bool, request *dto.APIKeyRequest) (*dto.APIKeyCreatedResponse, error) {
+	return s.create(ctx, userID, nil, isAdmin, request)
+}
+
+// ListForUser returns the keys a user issued for themselves
+func (s *apiKeyService) ListForUser(ctx context.Context, userID int) ([]*models.APIKey, error) {
+	keys, err := s.apiKeyRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		keys = []*models.APIKey{}
+	}
+	return keys, nil
+}
+
+// CreateForUser issues a key acting as the user, scoped to some of the user's own operations
+func (s *apiKeyService) CreateForUser(ctx context.Context, userID int, request *dto.APIKeyRequest) (*dto.APIKeyCreatedResponse, error) {
+	return s.create(ctx, userID, &userID, false, request)
+}
+
+// RevokeForUser disables one of the user's own keys
+func (s *apiKeyService) RevokeForUser(ctx context.Context, userID, id int) error {
+	apiKey, err := s.apiKeyRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrAPIKeyNotFound
+		}
+		return err
+	}
+	if apiKey.UserID == nil || *apiKey.UserID != userID {
+		return ErrAPIKeyNotFound
+	}
+
+	return s.Revoke(ctx, id)
+}
+
+// create validates and stores a key of a service account (owner nil) or of a user
+func (s *apiKeyService) create(ctx context.Context, userID int, owner *int, isAdmin bool, request *dto.APIKeyRequest) (*dto.APIKeyCreatedResponse, error) {
+	scopes, err := s.checkScopes(ctx, userID, isAdmin, request.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: the expiry date is in the past", ErrInvalidAPIKeyRequest)
+	}
+
+	key, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey := &models.APIKey{
+		Name:      strings.TrimSpace(request.Name),
+		Prefix:    key[:apiKeyLookupLength],
+		KeyHash:   hashAPIKey(key),
+		Scopes:    scopes,
+		UserID:    owner,
+		ExpiresAt: request.ExpiresAt,
+		CreatedBy: userID,
+	}
+
+	if _, err := s.apiKeyRepo.Create(ctx, apiKey); err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "API key created", "api_key_id", apiKey.ID, "name", apiKey.Name,
+		"scopes", scopes, "created_by", userID, "user_key", owner != nil)
+	return &dto.APIKeyCreatedResponse{APIKey: apiKey, Key: key}, nil
+}
+
+// Revoke disables an API key immediately
+func (s *apiKeyService) Revoke(ctx context.Context, id int) error {
+	if err := s.apiKeyRepo.Revoke(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrAPIKeyNotFound
+		}
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "API key revoked", "api_key_id", id)
+	return nil
+}
+
+// Authenticate resolves a presented key to the service account it belongs to
+func (s *apiKeyService) Authenticate(ctx context.Context, key string) (*APIKeyPrincipal, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) || len(key) <= apiKeyLookupLength {
+		return nil, ErrInvalidAPIKey
+	}
+
+	apiKey, err := s.apiKeyRepo.GetByPrefix(ctx, key[:apiKeyLookupLength])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(key)), []byte(apiKey.KeyHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if !apiKey.Active(now) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.apiKeyRepo.TouchLastUsed(ctx, apiKey.ID, now); err != nil {
+			s.logger.WarnContext(ctx, "Error recording API key use", "api_key_id", apiKey.ID, "error", err)
+		}
+	}
+
+	principal := &APIKeyPrincipal{
+		KeyID:    apiKey.ID,
+		Name:     apiKey.Name,
+		Prefix:   apiKey.Prefix,
+		Scopes:   apiKey.Scopes,
+		Username: "api_key:" + apiKey.Prefix,
+	}
+
+	// A user's key stops working with the user
+	if apiKey.UserID != nil {
+		user, err := s.userRepo.GetByID(ctx, *apiKey.UserID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, ErrInvalidAPIKey
+			}
+			return nil, err
+		}
+		if !user.IsActive || user.DeletedAt != nil {
+			return nil, ErrInvalidAPIKey
+		}
+		principal.UserID = user.ID
+		principal.Username = user.Username
+		principal.DepartmentID = user.DepartmentID
+	}
+
+	return principal, nil
+}
+
+// checkScopes validates the requested operation codes and removes duplicates
+func (s *apiKeyService) checkScopes(ctx context.Context, userID int, isAdmin bool, requested []string) ([]string, error) {
+	operations, err := s.operationService.GetAllOperations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting operations: %w", err)
+	}
+	known := make(map[string]bool, len(operations))
+	for _, operation := range operations {
+		known[operation.Code] = true
+	}
+
+	var scopes []string
+	for _, scope := range requested {
+		scope = strings.TrimSpace(scope)
+		if slices.Contains(scopes, scope) {
+			continue
+		}
+
+		switch {
+		case scope == apiKeyAllScopes:
+			if !isAdmin {
+				return nil, fmt.Errorf("%w: only administrators may grant every operation", ErrInvalidAPIKeyRequest)
+			}
+		case !known[scope]:
+			return nil, fmt.Errorf("%w: unknown operation %s", ErrInvalidAPIKeyRequest, scope)
+		case !isAdmin:
+			hasAccess, err := s.operationService.CheckUserAccess(ctx, userID, scope)
+			if err != nil {
+				return nil, err
+			}
+			if !hasAccess {
+				return nil, fmt.Errorf("%w: you don't have operation %s", ErrInvalidAPIKeyRequest, scope)
+			}
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
+
+// generateAPIKey returns a new random key: the lookup prefix followed by 32 random bytes
+func generateAPIKey() (string, error) {
+	lookup := make([]byte, (apiKeyLookupLength-len(apiKeyPrefix))/2)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(lookup); err != nil {
+		return "", fmt.Errorf("error generating API key: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("error generating API key: %w", err)
+	}
+	return apiKeyPrefix + hex.EncodeToString(lookup) + "_" + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// hashAPIKey hashes a key for storage. Keys carry 256 random bits, so a fast hash is enough.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -3,31 +3,51 @@ package service
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"erp-excel/config"
+	"erp-excel/internal/apperror"
 	"erp-excel/internal/dto"
+	"erp-excel/internal/events"
+	"erp-excel/internal/integration"
 	"erp-excel/internal/models"
 	"erp-excel/internal/repository"
+	"erp-excel/internal/storage"
+	"erp-excel/internal/translate"
 	"erp-excel/internal/utils"
-	"errors"
 	"fmt"
-	"log"
-	"path/filepath"
+	"log/slog"
+	"math"
 	"time"
 )
 
 // ReportService interface defines methods for report generation.
 type ReportService interface {
 	GetInventoryReportData(ctx context.Context, userID int, departmentID int, request *dto.DateRangeRequest) ([]dto.Asisstant230ReportItem, error)
+	GetInventoryReportPage(ctx context.Context, userID int, departmentID int, request *dto.DateRangeRequest, page dto.ReportPageRequest) ([]dto.Asisstant230ReportItem, int, error)
 	ExportInventoryReport(ctx context.Context, userID int, departmentID int, request *dto.DateRangeRequest) (*dto.ReportFileResponse, error)
+	ExportInventoryReportToSheet(ctx context.Context, userID int, departmentID int, request *dto.SheetExportRequest) (*dto.SheetExportResponse, error)
+	PreviewInventoryReport(ctx context.Context, userID int, departmentID int, request *dto.DateRangeRequest) (*dto.ReportPreviewResponse, error)
 }
 
 type reportService struct {
-	erpDB         *sql.DB
-	config        *config.Config
-	userRepo      repository.UserRepository
-	operationRepo repository.OperationRepository
-	inventoryRepo repository.InventoryRepository
+	erpDB            *sql.DB
+	config           *config.Config
+	userRepo         repository.UserRepository
+	operationRepo    repository.OperationRepository
+	assistant230Repo repository.Assistant230Repository
+	sheetsClient     integration.GoogleSheetsClient
+	fileStorage      storage.Storage
+	fileRepo         repository.ReportFileRepository
+	reportNamer      ReportNamer
+	reportLimiter    ReportLimiter
+	queryTimeouts    QueryTimeouts
+	sharePointClient integration.SharePointClient
+	eventService     EventService
+
+	exchangeRateService ExchangeRateService
+	annotationRepo      repository.ReportAnnotationRepository
+	rowPolicies         RowPolicyService
+	masker              ReportMasker
+	logger              *slog.Logger
 }
 
 // NewReportService creates a new report service.
@@ -36,69 +56,44 @@ func NewReportService(
 	config *config.Config,
 	userRepo repository.UserRepository,
 	operationRepo repository.OperationRepository,
-	inventoryRepo repository.InventoryRepository,
+	assistant230Repo repository.Assistant230Repository,
+	sheetsClient integration.GoogleSheetsClient,
+	fileStorage storage.Storage,
+	fileRepo repository.ReportFileRepository,
+	reportNamer ReportNamer,
+	reportLimiter ReportLimiter,
+	queryTimeouts QueryTimeouts,
+	sharePointClient integration.SharePointClient,
+	eventService EventService,
+	exchangeRateService ExchangeRateService,
+	annotationRepo repository.ReportAnnotationRepository,
+	rowPolicies RowPolicyService,
+	masker ReportMasker,
+	logger *slog.Logger,
 ) ReportService {
 	return &reportService{
-		erpDB:         erpDB,
-		config:        config,
-		userRepo:      userRepo,
-		operationRepo: operationRepo,
-		inventoryRepo: inventoryRepo,
+		erpDB:            erpDB,
+		config:           config,
+		userRepo:         userRepo,
+		operationRepo:    operationRepo,
+		assistant230Repo: assistant230Repo,
+		sheetsClient:     sheetsClient,
+		fileStorage:      fileStorage,
+		fileRepo:         fileRepo,
+		reportNamer:      reportNamer,
+		reportLimiter:    reportLimiter,
+		queryTimeouts:    queryTimeouts,
+		sharePointClient: sharePointClient,
+		eventService:     eventService,
+
+		exchangeRateService: exchangeRateService,
+		annotationRepo:      annotationRepo,
+		rowPolicies:         rowPolicies,
+		masker:              masker,
+		logger:              logger,
 	}
 }
 
-// resolveDateRange calculates actual fromDate and toDate based on Period or uses provided dates.
-func (s *reportService) resolveDateRange(request *dto.DateRangeRequest) (time.Time, time.Time, error) {
-	log.Printf("resolveDateRange called with request: %+v", request)
-
-	now := time.Now()
-	currentEndOfDay := now.Truncate(24 * time.Hour).Add(24*time.Hour - time.Nanosecond)
-	fromDate := time.Time{}
-	toDate := time.Time{}
-
-	if request.Period != nil && *request.Period != "" {
-		period := *request.Period
-		log.Printf("Using period: %s", period)
-		switch period {
-		case "7days":
-			fromDate = currentEndOfDay.AddDate(0, 0, -6).Truncate(24 * time.Hour)
-			toDate = currentEndOfDay
-		case "30days":
-			fromDate = currentEndOfDay.AddDate(0, 0, -29).Truncate(24 * time.Hour)
-			toDate = currentEndOfDay
-		case "3months":
-			fromDate = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -2, 0).Truncate(24 * time.Hour)
-			toDate = currentEndOfDay
-		case "currentmonth":
-			fromDate = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-			toDate = currentEndOfDay
-		case "lastmonth":
-			firstOfThisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-			toDate = firstOfThisMonth.Add(-time.Nanosecond)
-			fromDate = time.Date(toDate.Year(), toDate.Month(), 1, 0, 0, 0, 0, now.Location())
-		default:
-			return time.Time{}, time.Time{}, fmt.Errorf("invalid period specified: %s", period)
-		}
-	} else if !request.FromDate.IsZero() && !request.ToDate.IsZero() {
-		log.Printf("Using FromDate and ToDate: %v - %v", request.FromDate, request.ToDate)
-
-		// Check if FromDate and ToDate are valid dates before truncating
-		if request.FromDate.Year() < 1900 || request.ToDate.Year() < 1900 {
-			log.Println("Invalid FromDate or ToDate (year < 1900)")
-			return time.Time{}, time.Time{}, errors.New("invalid FromDate or ToDate (year < 1900)")
-		}
-
-		fromDate = request.FromDate.Truncate(24 * time.Hour)
-		toDate = request.ToDate.Truncate(24 * time.Hour).Add(24*time.Hour - time.Nanosecond) // End of day
-	} else {
-		log.Println("No period or dates specified")
-		return time.Time{}, time.Time{}, errors.New("fromDate and toDate are required if period is not specified")
-	}
-
-	log.Printf("resolveDateRange returning fromDate: %v, toDate: %v", fromDate, toDate)
-	return fromDate, toDate, nil
-}
-
 // GetInventoryReportData retrieves inventory report data without generating a file.
 func (s *reportService) GetInventoryReportData(
 	ctx context.Context,
@@ -106,25 +101,45 @@ func (s *reportService) GetInventoryReportData(
 	departmentID int,
 	request *dto.DateRangeRequest,
 ) ([]dto.Asisstant230ReportItem, error) {
-	log.Printf("GetInventoryReportData called with userID: %d, departmentID: %d, request: %+v", userID, departmentID, request)
+	items, _, err := s.inventoryReportData(ctx, userID, departmentID, request, nil)
+	return items, err
+}
 
-	resolvedFromDate, resolvedToDate, err := s.resolveDateRange(request)
-	if err != nil {
-		log.Printf("Error resolving date range: %v", err)
-		return nil, err
-	}
+// GetInventoryReportPage retrieves one sorted page of the inventory report data, narrowed by the request
+// filters, and the total number of matching rows.
+func (s *reportService) GetInventoryReportPage(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	request *dto.DateRangeRequest,
+	page dto.ReportPageRequest,
+) ([]dto.Asisstant230ReportItem, int, error) {
+	repoPage := reportPage(page)
+	return s.inventoryReportData(ctx, userID, departmentID, request, &repoPage)
+}
 
-	if err = s.validateDateRange(resolvedFromDate, resolvedToDate); err != nil {
-		log.Printf("Error validating date range: %v", err)
-		return nil, err
+// inventoryReportData runs the report, or one page of it when page is set, and records the access.
+func (s *reportService) inventoryReportData(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	request *dto.DateRangeRequest,
+	page *repository.ReportPage,
+) ([]dto.Asisstant230ReportItem, int, error) {
+	s.logger.DebugContext(ctx, "Getting inventory report data", "user_id", userID, "department_id", departmentID, "request", request)
+
+	resolvedFromDate, resolvedToDate, err := s.reportLimiter.DateRange("assistant230").Resolve(request)
+	if err != nil {
+		s.logger.WarnContext(ctx, "Error resolving date range", "error", err)
+		return nil, 0, err
 	}
 
 	logRequest := *request
 	logRequest.FromDate = &resolvedFromDate
 	logRequest.ToDate = &resolvedToDate
-	searchParams, err := json.Marshal(logRequest)
+	searchParams, err := reportSearchParams("assistant230", logRequest)
 	if err != nil {
-		log.Printf("Error marshalling search params: %v", err)
+		s.logger.ErrorContext(ctx, "Error marshalling search params", "error", err)
 		searchParams = []byte(`{"error": "failed to marshal search parameters"}`)
 	}
 
@@ -138,28 +153,101 @@ func (s *reportService) GetInventoryReportData(
 
 	logID, err := s.operationRepo.LogAccess(ctx, accessLog)
 	if err != nil {
-		log.Printf("Error logging access: %v", err)
+		s.logger.ErrorContext(ctx, "Error logging access", "error", err)
 	}
 
 	var items []dto.Asisstant230ReportItem
-	items, err = s.inventoryRepo.GetInventoryReport(ctx, resolvedFromDate, resolvedToDate, departmentID)
+	var total int
+	// Pages are bounded by their size; the whole report by the query rows of the report
+	queryRows := 0
+	queryCtx, finishQuery := s.queryTimeouts.start(s.rowPolicies.Apply(ctx, userID, "assistant230"), "assistant230")
+	if page == nil {
+		queryRows = s.reportLimiter.QueryRows("assistant230")
+		items, err = s.assistant230Repo.GetInventoryReport(queryCtx, resolvedFromDate, resolvedToDate, departmentID, request.InvoiceStatus, exportRowLimit(queryRows))
+		total = len(items)
+	} else {
+		items, total, err = s.assistant230Repo.GetInventoryReportPage(queryCtx, resolvedFromDate, resolvedToDate, departmentID, request.InvoiceStatus, reportFilter(request.Filters), *page)
+	}
+	err = finishQuery(err)
 	if err != nil {
-		log.Printf("Error querying inventory data: %v", err)
+		s.logger.ErrorContext(ctx, "Error querying inventory data", "error", err)
 		s.updateLogStatus(ctx, logID, "error")
-		return nil, fmt.Errorf("error querying inventory data: %w", err)
+		return nil, 0, fmt.Errorf("error querying inventory data: %w", err)
+	}
+	if err := checkQueryRows("assistant230", len(items), queryRows); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, 0, err
 	}
+	s.overlayNotes(ctx, items)
 
 	if len(items) == 0 {
-		log.Printf("No data found for date range from %s to %s",
-			resolvedFromDate.Format("2006-01-02"),
-			resolvedToDate.Format("2006-01-02"))
+		s.logger.InfoContext(ctx, "No inventory data found",
+			"from_date", resolvedFromDate.Format("2006-01-02"),
+			"to_date", resolvedToDate.Format("2006-01-02"))
 		s.updateLogStatus(ctx, logID, "success")
-		return []dto.Asisstant230ReportItem{}, nil
+		return []dto.Asisstant230ReportItem{}, total, nil
+	}
+
+	if err := s.convertInventoryTotals(ctx, items, request.TargetCurrency); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, 0, err
 	}
+	s.masker.Mask(ctx, userID, "assistant230", items)
 
 	s.updateLogStatus(ctx, logID, "success")
 
-	return items, nil
+	return items, total, nil
+}
+
+// PreviewInventoryReport returns the first rows of the report with the resolved parameters.
+func (s *reportService) PreviewInventoryReport(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	request *dto.DateRangeRequest,
+) (*dto.ReportPreviewResponse, error) {
+	s.logger.DebugContext(ctx, "Previewing inventory report", "user_id", userID, "department_id", departmentID, "request", request)
+
+	resolvedFromDate, resolvedToDate, err := s.reportLimiter.DateRange("assistant230").Resolve(request)
+	if err != nil {
+		return nil, err
+	}
+
+	invoiceStatus := invoiceStatusOrDefault(request.InvoiceStatus)
+
+	// One extra row tells whether the preview is cut off
+	queryCtx, finishQuery := s.queryTimeouts.start(s.rowPolicies.Apply(ctx, userID, "assistant230"), "assistant230")
+	items, err := s.assistant230Repo.GetInventoryReport(queryCtx, resolvedFromDate, resolvedToDate, departmentID, invoiceStatus, dto.PreviewRowLimit+1)
+	err = finishQuery(err)
+	if err != nil {
+		return nil, fmt.Errorf("error querying inventory data: %w", err)
+	}
+	s.overlayNotes(ctx, items)
+
+	truncated := len(items) > dto.PreviewRowLimit
+	if truncated {
+		items = items[:dto.PreviewRowLimit]
+	}
+	if items == nil {
+		items = []dto.Asisstant230ReportItem{}
+	}
+	s.masker.Mask(ctx, userID, "assistant230", items)
+
+	return &dto.ReportPreviewResponse{
+		Type:       "inventory",
+		ReportName: s.reportNamer.Title(ctx, inventoryNameData(userID, departmentID, resolvedFromDate, resolvedToDate, invoiceStatus)),
+		Parameters: map[string]interface{}{
+			"from_date":      resolvedFromDate.Format("2006-01-02"),
+			"to_date":        resolvedToDate.Format("2006-01-02"),
+			"invoice_status": invoiceStatus,
+			"department_id":  departmentID,
+		},
+		Items:       items,
+		RowCount:    len(items),
+		Limit:       dto.PreviewRowLimit,
+		Truncated:   truncated,
+		GeneratedAt: time.Now(),
+	}, nil
 }
 
 // ExportInventoryReport generates and exports the inventory report to an Excel file.
@@ -169,18 +257,17 @@ func (s *reportService) ExportInventoryReport(
 	departmentID int,
 	request *dto.DateRangeRequest,
 ) (*dto.ReportFileResponse, error) {
-	log.Printf("ExportInventoryReport called with userID: %d, departmentID: %d, request: %+v", userID, departmentID, request)
+	s.logger.DebugContext(ctx, "Exporting inventory report", "user_id", userID, "department_id", departmentID, "request", request)
 
 	// Resolve actual fromDate and toDate
-	resolvedFromDate, resolvedToDate, err := s.resolveDateRange(request)
+	resolvedFromDate, resolvedToDate, err := s.reportLimiter.DateRange("assistant230").Resolve(request)
 	if err != nil {
-		log.Printf("Error resolving date range: %v", err)
+		s.logger.WarnContext(ctx, "Error resolving date range", "error", err)
 		return nil, err
 	}
 
-	// Validate the resolved date range
-	if err = s.validateDateRange(resolvedFromDate, resolvedToDate); err != nil {
-		log.Printf("Error validating date range: %v", err)
+	columns, err := InventoryColumns(request)
+	if err != nil {
 		return nil, err
 	}
 
@@ -189,9 +276,9 @@ func (s *reportService) ExportInventoryReport(
 	logRequest.FromDate = &resolvedFromDate
 	logRequest.ToDate = &resolvedToDate
 
-	searchParams, err := json.Marshal(logRequest)
+	searchParams, err := reportSearchParams("assistant230", logRequest)
 	if err != nil {
-		log.Printf("Error marshalling search params: %v", err)
+		s.logger.ErrorContext(ctx, "Error marshalling search params", "error", err)
 		searchParams = []byte(`{"error": "failed to marshal search parameters"}`)
 	}
 
@@ -205,108 +292,311 @@ func (s *reportService) ExportInventoryReport(
 
 	logID, err := s.operationRepo.LogAccess(ctx, accessLog)
 	if err != nil {
-		log.Printf("Error logging access for export: %v", err)
+		s.logger.ErrorContext(ctx, "Error logging access for export", "error", err)
 	}
 
 	// Get data using the repository
-	items, err := s.inventoryRepo.GetInventoryReport(ctx, resolvedFromDate, resolvedToDate, departmentID)
+	maxRows := s.reportLimiter.MaxRows(ctx, userID, "assistant230")
+	queryCtx, finishQuery := s.queryTimeouts.start(s.rowPolicies.Apply(ctx, userID, "assistant230"), "assistant230")
+	items, err := s.assistant230Repo.GetInventoryReport(queryCtx, resolvedFromDate, resolvedToDate, departmentID, request.InvoiceStatus, exportRowLimit(maxRows))
+	err = finishQuery(err)
 	if err != nil {
-		log.Printf("Error getting inventory data for export: %v", err)
+		s.logger.ErrorContext(ctx, "Error getting inventory data for export", "error", err)
 		s.updateLogStatus(ctx, logID, "error")
 		return nil, fmt.Errorf("error getting inventory data for export: %w", err)
 	}
+	if err := checkExportRows(len(items), maxRows); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+	s.overlayNotes(ctx, items)
 
 	if len(items) == 0 {
-		log.Println("No data found to export for the specified date range")
+		s.logger.InfoContext(ctx, "No inventory data found to export")
 		s.updateLogStatus(ctx, logID, "success") // Exporting no data is also a success
-		return nil, errors.New("no data found to export for the specified date range")
+		return nil, fmt.Errorf("%w to export for the specified date range", apperror.ErrNoData)
 	}
 
-	// Prepare title for the Excel file
-	title := fmt.Sprintf("Export Sales 230 from %s to %s",
-		resolvedFromDate.Format("02/01/2006"),
-		resolvedToDate.Format("02/01/2006"),
-	)
-
-	headers := []string{
-		"document_date",
-		"sales_order_number",
-		"customer_name",
-		"currency_type",
-		"currency",
-		"detailed_order_number",
-		"invoice_number",
-		"notes",
+	if err := s.convertInventoryTotals(ctx, items, request.TargetCurrency); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
 	}
+	masked := s.masker.Mask(ctx, userID, "assistant230", items)
+
+	// Prepare title for the Excel file
+	nameData := inventoryNameData(userID, departmentID, resolvedFromDate, resolvedToDate, invoiceStatusOrDefault(request.InvoiceStatus))
+	title := withTargetCurrency(s.reportNamer.Title(ctx, nameData), request.TargetCurrency)
 
 	// Prepare data for Excel export
-	data := make([]map[string]interface{}, len(items))
-	for i, item := range items {
-		data[i] = map[string]interface{}{
-			"document_date":         item.DocumentDate,
-			"sales_order_number":    item.SalesOrderNumber,
-			"customer_name":         item.CustomerName,
-			"currency_type":         item.CurrencyType,
-			"currency":              item.Currency,
-			"detailed_order_number": item.DetailedOrderNumber,
-			"invoice_number":        item.InvoiceNumber,
-			"notes":                 item.Notes,
-		}
-	}
+	headers, data := inventoryExportRows(ctx, items, columns)
+	masked.Rows(data)
+
 	// Generate Excel file using utils
-	filePath, fileDetail, err := utils.ExportToExcel(data, headers, title)
+	_, fileDetail, err := utils.ExportSheetsToExcel(ctx, title, utils.ExcelSheet{
+		Name:        "Sheet1",
+		Title:       title,
+		Headers:     headers,
+		Data:        data,
+		ColumnTypes: inventoryColumnTypes,
+	})
 	if err != nil {
 		s.updateLogStatus(ctx, logID, "error")
 		return nil, fmt.Errorf("error exporting to Excel: %w", err)
 	}
+	if err := checkExportSize("assistant230", fileDetail.Len(), s.reportLimiter.MaxExportBytes("assistant230")); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
 
 	// Update log status to success
 	s.updateLogStatus(ctx, logID, "success")
 
 	// Prepare response for frontend
-	fileName := filepath.Base(filePath)
+	fileName := s.reportNamer.FileName(ctx, nameData, title)
+	exportFile := &models.ReportFile{
+		FileName:    fileName,
+		Report:      "assistant230",
+		UserID:      userID,
+		AccessLogID: logID,
+		RowCount:    len(items),
+	}
+	downloadURL := storeExportFile(ctx, s.logger, s.fileStorage, s.fileRepo, exportFile, fileDetail)
+	publishExportFile(ctx, s.logger, s.sharePointClient, "assistant230", fileName, fileDetail)
+	s.eventService.Emit(ctx, events.ExportCompleted, events.ExportCompletedData{
+		Report:       "assistant230",
+		FileName:     fileName,
+		RowCount:     len(items),
+		UserID:       userID,
+		DepartmentID: departmentID,
+		DownloadURL:  downloadURL,
+	})
 
 	return &dto.ReportFileResponse{
 		ReportName:  title,
 		FileName:    fileName,
 		FileDetal:   fileDetail,
+		DownloadURL: downloadURL,
+		Checksum:    exportFile.Checksum,
 		GeneratedAt: time.Now(),
 	}, nil
 }
 
-// validateDateRange validates date range for reports.
-// This is an internal helper, not exposed via interface.
-func (s *reportService) validateDateRange(fromDate, toDate time.Time) error {
-	// Check that fromDate is before or equal to toDate
-	if fromDate.After(toDate) {
-		return errors.New("from date must be before or equal to to date")
+// ExportInventoryReportToSheet pushes the inventory report into the configured Google Sheet.
+func (s *reportService) ExportInventoryReportToSheet(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	request *dto.SheetExportRequest,
+) (*dto.SheetExportResponse, error) {
+	s.logger.DebugContext(ctx, "Exporting inventory report to Google Sheets", "user_id", userID, "department_id", departmentID, "request", request)
+
+	target, err := resolveSheetTarget(s.sheetsClient, "assistant230", request.SpreadsheetID, request.SheetName)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvedFromDate, resolvedToDate, err := s.reportLimiter.DateRange("assistant230").Resolve(&request.DateRangeRequest)
+	if err != nil {
+		s.logger.WarnContext(ctx, "Error resolving date range", "error", err)
+		return nil, err
+	}
+
+	columns, err := InventoryColumns(&request.DateRangeRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	logRequest := *request
+	logRequest.FromDate = &resolvedFromDate
+	logRequest.ToDate = &resolvedToDate
+
+	searchParams, err := reportSearchParams("assistant230", logRequest)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error marshalling search params", "error", err)
+		searchParams = []byte(`{"error": "failed to marshal search parameters"}`)
+	}
+
+	accessLog := &models.AccessLog{
+		UserID:       userID,
+		OperationID:  2, // Pushing to Google Sheets is logged as an export
+		AccessTime:   time.Now(),
+		SearchParams: string(searchParams),
+		Status:       "pending",
+	}
+
+	logID, err := s.operationRepo.LogAccess(ctx, accessLog)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error logging access for sheet export", "error", err)
+	}
+
+	maxRows := s.reportLimiter.MaxRows(ctx, userID, "assistant230")
+	queryCtx, finishQuery := s.queryTimeouts.start(s.rowPolicies.Apply(ctx, userID, "assistant230"), "assistant230")
+	items, err := s.assistant230Repo.GetInventoryReport(queryCtx, resolvedFromDate, resolvedToDate, departmentID, request.InvoiceStatus, exportRowLimit(maxRows))
+	err = finishQuery(err)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error getting inventory data for sheet export", "error", err)
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, fmt.Errorf("error getting inventory data for export: %w", err)
+	}
+	if err := checkExportRows(len(items), maxRows); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+	s.overlayNotes(ctx, items)
+
+	if len(items) == 0 {
+		s.logger.InfoContext(ctx, "No inventory data found to export")
+		s.updateLogStatus(ctx, logID, "success")
+		return nil, fmt.Errorf("%w to export for the specified date range", apperror.ErrNoData)
+	}
+
+	if err := s.convertInventoryTotals(ctx, items, request.TargetCurrency); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+	masked := s.masker.Mask(ctx, userID, "assistant230", items)
+
+	nameData := inventoryNameData(userID, departmentID, resolvedFromDate, resolvedToDate, invoiceStatusOrDefault(request.InvoiceStatus))
+	title := withTargetCurrency(s.reportNamer.Title(ctx, nameData), request.TargetCurrency)
+
+	headers, data := inventoryExportRows(ctx, items, columns)
+	masked.Rows(data)
+	values := buildSheetValues(ctx, headers, data)
+
+	if err := s.sheetsClient.WriteValues(ctx, target.SpreadsheetID, target.SheetName, values); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, fmt.Errorf("error exporting to Google Sheets: %w", err)
+	}
+
+	s.updateLogStatus(ctx, logID, "success")
+
+	return &dto.SheetExportResponse{
+		ReportName:    title,
+		SpreadsheetID: target.SpreadsheetID,
+		SheetName:     target.SheetName,
+		RowCount:      len(items),
+		ExportedAt:    time.Now(),
+	}, nil
+}
+
+// inventoryNameData holds the values used to name a 230 report
+func inventoryNameData(userID int, departmentID int, fromDate time.Time, toDate time.Time, invoiceStatus string) ReportNameData {
+	return ReportNameData{
+		Report:       "assistant230",
+		Name:         "Export Sales 230",
+		Status:       invoiceStatus,
+		FromDate:     fromDate,
+		ToDate:       toDate,
+		UserID:       userID,
+		DepartmentID: departmentID,
 	}
+}
 
-	// Check that toDate is not in the future (compared to current end of day)
-	nowEndOfDay := time.Now().Truncate(24 * time.Hour).Add(24*time.Hour - time.Nanosecond)
-	if toDate.After(nowEndOfDay) {
-		return errors.New("to date cannot be in the future")
+// overlayNotes replaces the ERP notes with the notes imported from edited exports. Notes are an
+// overlay, so a failure to read them is logged and the ERP notes are kept.
+func (s *reportService) overlayNotes(ctx context.Context, items []dto.Asisstant230ReportItem) {
+	notes, err := loadReportNotes(ctx, s.annotationRepo, "assistant230")
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error loading imported notes", "error", err)
+		return
 	}
+	for i := range items {
+		if note, ok := notes[items[i].SalesOrderNumber]; ok {
+			items[i].Notes = note
+		}
+	}
+}
 
-	// Check that date range is within allowed months
-	maxMonths := s.config.Excel.MaxSearchMonths
+// convertInventoryTotals fills in the converted total of every row when a target currency is requested.
+func (s *reportService) convertInventoryTotals(ctx context.Context, items []dto.Asisstant230ReportItem, targetCurrency string) error {
+	if targetCurrency == "" {
+		return nil
+	}
 
-	oldestAllowed := time.Now().Truncate(24*time.Hour).AddDate(0, -maxMonths, 0)
+	converter, err := s.exchangeRateService.Converter(ctx, targetCurrency)
+	if err != nil {
+		return err
+	}
 
-	if fromDate.Truncate(24 * time.Hour).Before(oldestAllowed) {
-		return fmt.Errorf("date range cannot exceed %d months from current date", maxMonths)
+	for i := range items {
+		converted, err := converter.ConvertFormatted(items[i].Currency, items[i].DocumentDate)
+		if err != nil {
+			return fmt.Errorf("error converting sales order %s: %w", items[i].SalesOrderNumber, err)
+		}
+		items[i].ConvertedTotal = &converted
 	}
 
 	return nil
 }
 
+// ConvertedInventoryTotal sums the converted totals of a 230 report. The report has one row per
+// order line, so each sales order is counted once. It returns nil when nothing was converted.
+func ConvertedInventoryTotal(items []dto.Asisstant230ReportItem) *float64 {
+	seen := make(map[string]bool, len(items))
+	var total *float64
+	for _, item := range items {
+		if item.ConvertedTotal == nil || seen[item.SalesOrderNumber] {
+			continue
+		}
+		seen[item.SalesOrderNumber] = true
+		if total == nil {
+			total = new(float64)
+		}
+		*total += *item.ConvertedTotal
+	}
+	if total != nil {
+		*total = math.Round(*total*100) / 100
+	}
+	return total
+}
+
+// inventoryExportRows maps inventory items to export rows with the given columns, as returned by
+// InventoryColumns. With the converted total among them a total row is added, labelled under
+// the first column.
+func inventoryExportRows(ctx context.Context, items []dto.Asisstant230ReportItem, columns []string) ([]string, []map[string]interface{}) {
+	headers := append([]string{}, columns...)
+
+	data := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		data[i] = map[string]interface{}{
+			"document_date":         item.DocumentDate,
+			"sales_order_number":    item.SalesOrderNumber,
+			"customer_name":         item.CustomerName,
+			"currency_type":         item.CurrencyType,
+			"currency":              item.Currency,
+			"detailed_order_number": item.DetailedOrderNumber,
+			"invoice_number":        item.InvoiceNumber,
+			"notes":                 item.Notes,
+			"invoice_status":        translate.Key(ctx, item.InvoiceStatus),
+		}
+		if item.ConvertedTotal != nil {
+			data[i]["converted_total"] = *item.ConvertedTotal
+		}
+	}
+
+	if total := ConvertedInventoryTotal(items); total != nil && containsColumn(headers, convertedTotalColumn) {
+		data = append(data, totalRow(ctx, headers, *total))
+	}
+
+	return headers, data
+}
+
+// invoiceStatusOrDefault returns the Assistant 230 invoice filter, uninvoiced when not set
+func invoiceStatusOrDefault(status string) string {
+	if status == "" {
+		return dto.InvoiceStatusUninvoiced
+	}
+	return status
+}
+
 // updateLogStatus updates the status of an access log.
 func (s *reportService) updateLogStatus(ctx context.Context, logID int, status string) {
 	if logID <= 0 {
 		return // Do not attempt to update if logID is invalid
 	}
 
+	ctx, status = logStatusContext(ctx, status)
 	if _, err := s.operationRepo.UpdateLogStatus(ctx, logID, status); err != nil {
-		log.Printf("Error updating log status for logID %d: %v", logID, err)
+		s.logger.ErrorContext(ctx, "Error updating log status", "log_id", logID, "error", err)
 	}
 }
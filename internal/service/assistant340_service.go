@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/events"
+	"erp-excel/internal/integration"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/storage"
+	"erp-excel/internal/utils"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Operations the purchase report logs its runs under; they also guard its routes
+const (
+	assistant340ViewOperation   = "reports:view:340"
+	assistant340ExportOperation = "reports:export:340"
+)
+
+// Assistant340Service builds the purchase receipts report (Assistant 340)
+type Assistant340Service interface {
+	GetAssistant340ReportData(ctx context.Context, userID int, request *dto.Assistant340Request, ipAddress string) ([]dto.Assistant340ReportItem, error)
+	ExportAssistant340Report(ctx context.Context, userID int, departmentID int, request *dto.Assistant340Request, ipAddress string) (*dto.ReportFileResponse, error)
+}
+
+type assistant340Service struct {
+	assistant340Repo repository.Assistant340Repository
+	operationService OperationService
+	fileStorage      storage.Storage
+	fileRepo         repository.ReportFileRepository
+	reportNamer      ReportNamer
+	reportLimiter    ReportLimiter
+	queryTimeouts    QueryTimeouts
+	sharePointClient integration.SharePointClient
+	eventService     EventService
+	rowPolicies      RowPolicyService
+	masker           ReportMasker
+	logger           *slog.Logger
+}
+
+// NewAssistant340Service creates a new purchase report service
+func NewAssistant340Service(
+	assistant340Repo repository.Assistant340Repository,
+	operationService OperationService,
+	fileStorage storage.Storage,
+	fileRepo repository.ReportFileRepository,
+	reportNamer ReportNamer,
+	reportLimiter ReportLimiter,
+	queryTimeouts QueryTimeouts,
+	sharePointClient integration.SharePointClient,
+	eventService EventService,
+	rowPolicies RowPolicyService,
+	masker ReportMasker,
+	logger *slog.Logger,
+) Assistant340Service {
+	return &assistant340Service{
+		assistant340Repo: assistant340Repo,
+		operationService: operationService,
+		fileStorage:      fileStorage,
+		fileRepo:         fileRepo,
+		reportNamer:      reportNamer,
+		reportLimiter:    reportLimiter,
+		queryTimeouts:    queryTimeouts,
+		sharePointClient: sharePointClient,
+		eventService:     eventService,
+		rowPolicies:      rowPolicies,
+		masker:           masker,
+		logger:           logger,
+	}
+}
+
+// GetAssistant340ReportData retrieves the purchase receipts without generating a file
+func (s *assistant340Service) GetAssistant340ReportData(
+	ctx context.Context,
+	userID int,
+	request *dto.Assistant340Request,
+	ipAddress string,
+) ([]dto.Assistant340ReportItem, error) {
+	s.logger.DebugContext(ctx, "GetAssistant340ReportData called", "user_id", userID, "request", request)
+
+	items, logID, err := s.queryItems(ctx, userID, assistant340ViewOperation, request, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+	s.masker.Mask(ctx, userID, "assistant340", items)
+
+	s.updateLogStatus(ctx, logID, "success")
+	if items == nil {
+		return []dto.Assistant340ReportItem{}, nil
+	}
+	return items, nil
+}
+
+// ExportAssistant340Report generates the purchase receipts Excel file
+func (s *assistant340Service) ExportAssistant340Report(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	request *dto.Assistant340Request,
+	ipAddress string,
+) (*dto.ReportFileResponse, error) {
+	s.logger.DebugContext(ctx, "ExportAssistant340Report called", "user_id", userID, "request", request)
+
+	items, logID, err := s.queryItems(ctx, userID, assistant340ExportOperation, request, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+	masked := s.masker.Mask(ctx, userID, "assistant340", items)
+
+	if err := checkExportRows(len(items), s.reportLimiter.MaxRows(ctx, userID, "assistant340")); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+
+	if len(items) == 0 {
+		s.updateLogStatus(ctx, logID, "success")
+		return nil, fmt.Errorf("%w to export for the specified date range", apperror.ErrNoData)
+	}
+
+	nameData := ReportNameData{
+		Report:       "assistant340",
+		Name:         "Purchases",
+		FromDate:     *request.FromDate,
+		ToDate:       *request.ToDate,
+		UserID:       userID,
+		DepartmentID: departmentID,
+	}
+	title := s.reportNamer.Title(ctx, nameData)
+
+	headers, data := assistant340ExportRows(items)
+	masked.Rows(data)
+
+	_, fileDetail, err := utils.ExportSheetsToExcel(ctx, title, utils.ExcelSheet{
+		Name:    "Sheet1",
+		Title:   title,
+		Headers: headers,
+		Data:    data,
+		ColumnTypes: map[string]utils.ExcelColumnType{
+			"receipt_date":     utils.ExcelDate,
+			"purchase_receipt": utils.ExcelText,
+			"supplier_code":    utils.ExcelText,
+			"item_code":        utils.ExcelText,
+			"order_qty":        utils.ExcelNumber,
+			"received_qty":     utils.ExcelNumber,
+			"amount_trans":     utils.ExcelCurrency,
+			"amount":           utils.ExcelCurrency,
+		},
+	})
+	if err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, fmt.Errorf("error exporting to Excel: %w", err)
+	}
+	if err := checkExportSize("assistant340", fileDetail.Len(), s.reportLimiter.MaxExportBytes("assistant340")); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+
+	s.updateLogStatus(ctx, logID, "success")
+
+	fileName := s.reportNamer.FileName(ctx, nameData, title)
+	exportFile := &models.ReportFile{
+		FileName:    fileName,
+		Report:      "assistant340",
+		UserID:      userID,
+		AccessLogID: logID,
+		RowCount:    len(items),
+	}
+	downloadURL := storeExportFile(ctx, s.logger, s.fileStorage, s.fileRepo, exportFile, fileDetail)
+	publishExportFile(ctx, s.logger, s.sharePointClient, "assistant340", fileName, fileDetail)
+	s.eventService.Emit(ctx, events.ExportCompleted, events.ExportCompletedData{
+		Report:       "assistant340",
+		FileName:     fileName,
+		RowCount:     len(items),
+		UserID:       userID,
+		DepartmentID: departmentID,
+		DownloadURL:  downloadURL,
+	})
+
+	return &dto.ReportFileResponse{
+		ReportName:  title,
+		FileName:    fileName,
+		FileDetal:   fileDetail,
+		DownloadURL: downloadURL,
+		Checksum:    exportFile.Checksum,
+		GeneratedAt: time.Now(),
+	}, nil
+}
+
+// queryItems resolves the date range in place, logs the access under the operation and runs the
+// query. The returned log ID is left pending for the caller to close.
+func (s *assistant340Service) queryItems(
+	ctx context.Context,
+	userID int,
+	operationCode string,
+	request *dto.Assistant340Request,
+	ipAddress string,
+) ([]dto.Assistant340ReportItem, int, error) {
+	fromDate, toDate, err := s.reportLimiter.DateRange("assistant340").Resolve(&request.DateRangeRequest)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error resolving date range", "error", err)
+		return nil, 0, err
+	}
+	request.FromDate = &fromDate
+	request.ToDate = &toDate
+
+	logID, err := s.operationService.LogAccess(ctx, userID, operationCode, request, ipAddress)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error logging purchase report access", "error", err)
+	}
+
+	queryCtx, finishQuery := s.queryTimeouts.start(s.rowPolicies.Apply(ctx, userID, "assistant340"), "assistant340")
+	items, err := s.assistant340Repo.GetAssistant340Report(queryCtx, fromDate, toDate, request.SupplierCode, request.ItemCode)
+	err = finishQuery(err)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error querying purchase receipts", "error", err)
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, 0, fmt.Errorf("error querying purchase receipts: %w", err)
+	}
+	if err := checkQueryRows("assistant340", len(items), s.reportLimiter.QueryRows("assistant340")); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, 0, err
+	}
+
+	return items, logID, nil
+}
+
+// assistant340ExportRows maps purchase receipt lines to export headers and rows.
+func assistant340ExportRows(items []dto.Assistant340ReportItem) ([]string, []map[string]interface{}) {
+	headers := []string{
+		"receipt_date",
+		"purchase_receipt",
+		"supplier_code",
+		"supplier_name",
+		"purchase_order",
+		"item_code",
+		"item_name",
+		"order_qty",
+		"received_qty",
+		"currency_type",
+		"amount_trans",
+		"amount",
+	}
+
+	data := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		data[i] = map[string]interface{}{
+			"receipt_date":     item.ReceiptDate,
+			"purchase_receipt": item.PurchaseReceipt,
+			"supplier_code":    item.SupplierCode,
+			"supplier_name":    item.SupplierName,
+			"purchase_order":   item.PurchaseOrder,
+			"item_code":        item.ItemCode,
+			"item_name":        item.ItemName,
+			"order_qty":        item.OrderQty,
+			"received_qty":     item.ReceivedQty,
+			"currency_type":    item.CurrencyType,
+			"amount_trans":     item.AmountTrans,
+			"amount":           item.Amount,
+		}
+	}
+
+	return headers, data
+}
+
+// updateLogStatus updates the status of an access log.
+func (s *assistant340Service) updateLogStatus(ctx context.Context, logID int, status string) {
+	if logID <= 0 {
+		return
+	}
+
+	ctx, status = logStatusContext(ctx, status)
+	if _, err := s.operationService.UpdateLogStatus(ctx, logID, status); err != nil {
+		s.logger.ErrorContext(ctx, "Error updating log status", "log_id", logID, "error", err)
+	}
+}
@@ -5,21 +5,31 @@ import (
 	"database/sql"
 	"encoding/json"
 	"erp-excel/config"
+	"erp-excel/internal/apperror"
 	"erp-excel/internal/dto"
+	"erp-excel/internal/events"
+	"erp-excel/internal/integration"
 	"erp-excel/internal/models"
 	"erp-excel/internal/repository"
+	"erp-excel/internal/storage"
+	"erp-excel/internal/translate"
 	"erp-excel/internal/utils"
-	"errors"
 	"fmt"
-	"log"
-	"path/filepath"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // Assistant610Service defines methods for report generation.
 type Assistant610Service interface {
 	GetAssistant610ReportData(ctx context.Context, userID int, departmentID int, request *dto.DateRangeRequest) ([]dto.Asisstant610ReportItem, error)
+	GetAssistant610ReportPage(ctx context.Context, userID int, departmentID int, request *dto.DateRangeRequest, page dto.ReportPageRequest) ([]dto.Asisstant610ReportItem, int, error)
 	ExportAssistant610Report(ctx context.Context, userID int, departmentID int, request *dto.DateRangeRequest) (*dto.ReportFileResponse, error)
+	ExportAssistant610ReportToSheet(ctx context.Context, userID int, departmentID int, request *dto.SheetExportRequest) (*dto.SheetExportResponse, error)
+	GetAssistant610AgingSummary(ctx context.Context, userID int, departmentID int, request *dto.DateRangeRequest) (*dto.Assistant610AgingSummary, error)
+	PreviewAssistant610Report(ctx context.Context, userID int, departmentID int, request *dto.DateRangeRequest) (*dto.ReportPreviewResponse, error)
 }
 
 type assistant610Service struct {
@@ -28,6 +38,20 @@ type assistant610Service struct {
 	userRepo         repository.UserRepository
 	operationRepo    repository.OperationRepository
 	assistant610Repo repository.Assistant610Repository
+	sheetsClient     integration.GoogleSheetsClient
+	fileStorage      storage.Storage
+	fileRepo         repository.ReportFileRepository
+	reportNamer      ReportNamer
+	reportLimiter    ReportLimiter
+	queryTimeouts    QueryTimeouts
+	sharePointClient integration.SharePointClient
+	eventService     EventService
+
+	exchangeRateService ExchangeRateService
+	annotationRepo      repository.ReportAnnotationRepository
+	rowPolicies         RowPolicyService
+	masker              ReportMasker
+	logger              *slog.Logger
 }
 
 // NewAssistant610Service creates a new report service.
@@ -37,6 +61,19 @@ func NewAssistant610Service(
 	userRepo repository.UserRepository,
 	operationRepo repository.OperationRepository,
 	assistant610Repo repository.Assistant610Repository,
+	sheetsClient integration.GoogleSheetsClient,
+	fileStorage storage.Storage,
+	fileRepo repository.ReportFileRepository,
+	reportNamer ReportNamer,
+	reportLimiter ReportLimiter,
+	queryTimeouts QueryTimeouts,
+	sharePointClient integration.SharePointClient,
+	eventService EventService,
+	exchangeRateService ExchangeRateService,
+	annotationRepo repository.ReportAnnotationRepository,
+	rowPolicies RowPolicyService,
+	masker ReportMasker,
+	logger *slog.Logger,
 ) Assistant610Service {
 	return &assistant610Service{
 		erpDB:            erpDB,
@@ -44,59 +81,23 @@ func NewAssistant610Service(
 		userRepo:         userRepo,
 		operationRepo:    operationRepo,
 		assistant610Repo: assistant610Repo,
+		sheetsClient:     sheetsClient,
+		fileStorage:      fileStorage,
+		fileRepo:         fileRepo,
+		reportNamer:      reportNamer,
+		reportLimiter:    reportLimiter,
+		queryTimeouts:    queryTimeouts,
+		sharePointClient: sharePointClient,
+		eventService:     eventService,
+
+		exchangeRateService: exchangeRateService,
+		annotationRepo:      annotationRepo,
+		rowPolicies:         rowPolicies,
+		masker:              masker,
+		logger:              logger,
 	}
 }
 
-// resolveDateRange calculates actual fromDate and toDate based on Period or uses provided dates.
-func (s *assistant610Service) resolveDateRange(request *dto.DateRangeRequest) (time.Time, time.Time, error) {
-	log.Printf("resolveDateRange called with request: %+v", request)
-
-	now := time.Now()
-	currentEndOfDay := now.Truncate(24 * time.Hour).Add(24*time.Hour - time.Nanosecond)
-	var fromDate, toDate time.Time
-
-	if request.Period != nil && *request.Period != "" {
-		period := *request.Period
-		log.Printf("Using period: %s", period)
-		switch period {
-		case "7days":
-			fromDate = currentEndOfDay.AddDate(0, 0, -6).Truncate(24 * time.Hour)
-			toDate = currentEndOfDay
-		case "30days":
-			fromDate = currentEndOfDay.AddDate(0, 0, -29).Truncate(24 * time.Hour)
-			toDate = currentEndOfDay
-		case "3months":
-			fromDate = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -2, 0).Truncate(24 * time.Hour)
-			toDate = currentEndOfDay
-		case "currentmonth":
-			fromDate = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-			toDate = currentEndOfDay
-		case "lastmonth":
-			firstOfThisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-			toDate = firstOfThisMonth.Add(-time.Nanosecond)
-			fromDate = time.Date(toDate.Year(), toDate.Month(), 1, 0, 0, 0, 0, now.Location())
-		default:
-			return time.Time{}, time.Time{}, fmt.Errorf("invalid period specified: %s", period)
-		}
-	} else if !request.FromDate.IsZero() && !request.ToDate.IsZero() {
-		log.Printf("Using FromDate and ToDate: %v - %v", request.FromDate, request.ToDate)
-
-		if request.FromDate.Year() < 1900 || request.ToDate.Year() < 1900 {
-			log.Println("Invalid FromDate or ToDate (year < 1900)")
-			return time.Time{}, time.Time{}, errors.New("invalid FromDate or ToDate (year < 1900)")
-		}
-
-		fromDate = request.FromDate.Truncate(24 * time.Hour)
-		toDate = request.ToDate.Truncate(24 * time.Hour).Add(24*time.Hour - time.Nanosecond)
-	} else {
-		log.Println("No period or dates specified")
-		return time.Time{}, time.Time{}, errors.New("fromDate and toDate are required if period is not specified")
-	}
-
-	log.Printf("resolveDateRange returning fromDate: %v, toDate: %v", fromDate, toDate)
-	return fromDate, toDate, nil
-}
-
 // GetAssistant610ReportData retrieves inventory report data without generating a file.
 func (s *assistant610Service) GetAssistant610ReportData(
 	ctx context.Context,
@@ -104,25 +105,45 @@ func (s *assistant610Service) GetAssistant610ReportData(
 	departmentID int,
 	request *dto.DateRangeRequest,
 ) ([]dto.Asisstant610ReportItem, error) {
-	log.Printf("GetAssistant610ReportData called with userID: %d, departmentID: %d, request: %+v", userID, departmentID, request)
+	items, _, err := s.assistant610ReportData(ctx, userID, departmentID, request, nil)
+	return items, err
+}
 
-	resolvedFromDate, resolvedToDate, err := s.resolveDateRange(request)
-	if err != nil {
-		log.Printf("Error resolving date range: %v", err)
-		return nil, err
-	}
+// GetAssistant610ReportPage retrieves one sorted page of the Assistant 610 report data, narrowed by the request
+// filters, and the total number of matching rows.
+func (s *assistant610Service) GetAssistant610ReportPage(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	request *dto.DateRangeRequest,
+	page dto.ReportPageRequest,
+) ([]dto.Asisstant610ReportItem, int, error) {
+	repoPage := reportPage(page)
+	return s.assistant610ReportData(ctx, userID, departmentID, request, &repoPage)
+}
 
-	if err = s.validate610DateRange(resolvedFromDate, resolvedToDate); err != nil {
-		log.Printf("Error validating date range: %v", err)
-		return nil, err
+// assistant610ReportData runs the report, or one page of it when page is set, and records the access.
+func (s *assistant610Service) assistant610ReportData(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	request *dto.DateRangeRequest,
+	page *repository.ReportPage,
+) ([]dto.Asisstant610ReportItem, int, error) {
+	s.logger.DebugContext(ctx, "Getting Assistant 610 report data", "user_id", userID, "department_id", departmentID, "request", request)
+
+	resolvedFromDate, resolvedToDate, err := s.reportLimiter.DateRange("assistant610").Resolve(request)
+	if err != nil {
+		s.logger.WarnContext(ctx, "Error resolving date range", "error", err)
+		return nil, 0, err
 	}
 
 	logRequest := *request
 	logRequest.FromDate = &resolvedFromDate
 	logRequest.ToDate = &resolvedToDate
-	searchParams, err := json.Marshal(logRequest)
+	searchParams, err := reportSearchParams("assistant610", logRequest)
 	if err != nil {
-		log.Printf("Error marshalling search params: %v", err)
+		s.logger.ErrorContext(ctx, "Error marshalling search params", "error", err)
 		searchParams = []byte(`{"error": "failed to marshal search parameters"}`)
 	}
 
@@ -136,25 +157,97 @@ func (s *assistant610Service) GetAssistant610ReportData(
 
 	logID, err := s.operationRepo.LogAccess(ctx, accessLog)
 	if err != nil {
-		log.Printf("Error logging access: %v", err)
+		s.logger.ErrorContext(ctx, "Error logging access", "error", err)
 	}
 
 	var items []dto.Asisstant610ReportItem
-	items, err = s.assistant610Repo.GetAssistant610Report(ctx, resolvedFromDate, resolvedToDate, departmentID) // Updated method name
+	var total int
+	// Pages are bounded by their size; the whole report by the query rows of the report
+	queryRows := 0
+	queryCtx, finishQuery := s.queryTimeouts.start(s.rowPolicies.Apply(ctx, userID, "assistant610"), "assistant610")
+	if page == nil {
+		queryRows = s.reportLimiter.QueryRows("assistant610")
+		items, err = s.assistant610Repo.GetAssistant610Report(queryCtx, resolvedFromDate, resolvedToDate, departmentID, exportRowLimit(queryRows))
+		total = len(items)
+	} else {
+		items, total, err = s.assistant610Repo.GetAssistant610ReportPage(queryCtx, resolvedFromDate, resolvedToDate, departmentID, reportFilter(request.Filters), *page)
+	}
+	err = finishQuery(err)
 	if err != nil {
-		log.Printf("Error querying inventory data: %v", err)
+		s.logger.ErrorContext(ctx, "Error querying inventory data", "error", err)
 		s.updateLogStatus(ctx, logID, "error")
-		return nil, fmt.Errorf("error querying inventory data: %w", err)
+		return nil, 0, fmt.Errorf("error querying inventory data: %w", err)
 	}
+	if err := checkQueryRows("assistant610", len(items), queryRows); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, 0, err
+	}
+	s.overlayNotes(ctx, items)
 
 	if len(items) == 0 {
-		log.Printf("No data found for date range from %s to %s", resolvedFromDate.Format("2006-01-02"), resolvedToDate.Format("2006-01-02"))
+		s.logger.InfoContext(ctx, "No Assistant 610 data found",
+			"from_date", resolvedFromDate.Format("2006-01-02"),
+			"to_date", resolvedToDate.Format("2006-01-02"))
 		s.updateLogStatus(ctx, logID, "success")
-		return []dto.Asisstant610ReportItem{}, nil
+		return []dto.Asisstant610ReportItem{}, total, nil
 	}
 
+	if err := s.convertAssistant610Totals(ctx, items, request.TargetCurrency); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, 0, err
+	}
+	s.masker.Mask(ctx, userID, "assistant610", items)
+
 	s.updateLogStatus(ctx, logID, "success")
-	return items, nil
+	return items, total, nil
+}
+
+// PreviewAssistant610Report returns the first rows of the report with the resolved parameters.
+func (s *assistant610Service) PreviewAssistant610Report(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	request *dto.DateRangeRequest,
+) (*dto.ReportPreviewResponse, error) {
+	s.logger.DebugContext(ctx, "Previewing Assistant 610 report", "user_id", userID, "department_id", departmentID, "request", request)
+
+	resolvedFromDate, resolvedToDate, err := s.reportLimiter.DateRange("assistant610").Resolve(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// One extra row tells whether the preview is cut off
+	queryCtx, finishQuery := s.queryTimeouts.start(s.rowPolicies.Apply(ctx, userID, "assistant610"), "assistant610")
+	items, err := s.assistant610Repo.GetAssistant610Report(queryCtx, resolvedFromDate, resolvedToDate, departmentID, dto.PreviewRowLimit+1)
+	err = finishQuery(err)
+	if err != nil {
+		return nil, fmt.Errorf("error querying inventory data: %w", err)
+	}
+	s.overlayNotes(ctx, items)
+
+	truncated := len(items) > dto.PreviewRowLimit
+	if truncated {
+		items = items[:dto.PreviewRowLimit]
+	}
+	if items == nil {
+		items = []dto.Asisstant610ReportItem{}
+	}
+	s.masker.Mask(ctx, userID, "assistant610", items)
+
+	return &dto.ReportPreviewResponse{
+		Type:       "assistant610",
+		ReportName: s.reportNamer.Title(ctx, assistant610NameData(userID, departmentID, resolvedFromDate, resolvedToDate)),
+		Parameters: map[string]interface{}{
+			"from_date":     resolvedFromDate.Format("2006-01-02"),
+			"to_date":       resolvedToDate.Format("2006-01-02"),
+			"department_id": departmentID,
+		},
+		Items:       items,
+		RowCount:    len(items),
+		Limit:       dto.PreviewRowLimit,
+		Truncated:   truncated,
+		GeneratedAt: time.Now(),
+	}, nil
 }
 
 // ExportAssistant610Report generates and exports the inventory report to an Excel file.
@@ -164,16 +257,16 @@ func (s *assistant610Service) ExportAssistant610Report( // Changed receiver type
 	departmentID int,
 	request *dto.DateRangeRequest,
 ) (*dto.ReportFileResponse, error) {
-	log.Printf("ExportAssistant610Report called with userID: %d, departmentID: %d, request: %+v", userID, departmentID, request)
+	s.logger.DebugContext(ctx, "Exporting Assistant 610 report", "user_id", userID, "department_id", departmentID, "request", request)
 
-	resolvedFromDate, resolvedToDate, err := s.resolveDateRange(request)
+	resolvedFromDate, resolvedToDate, err := s.reportLimiter.DateRange("assistant610").Resolve(request)
 	if err != nil {
-		log.Printf("Error resolving date range: %v", err)
+		s.logger.WarnContext(ctx, "Error resolving date range", "error", err)
 		return nil, err
 	}
 
-	if err = s.validate610DateRange(resolvedFromDate, resolvedToDate); err != nil {
-		log.Printf("Error validating date range: %v", err)
+	columns, err := Assistant610Columns(request)
+	if err != nil {
 		return nil, err
 	}
 
@@ -181,9 +274,9 @@ func (s *assistant610Service) ExportAssistant610Report( // Changed receiver type
 	logRequest.FromDate = &resolvedFromDate
 	logRequest.ToDate = &resolvedToDate
 
-	searchParams, err := json.Marshal(logRequest)
+	searchParams, err := reportSearchParams("assistant610", logRequest)
 	if err != nil {
-		log.Printf("Error marshalling search params: %v", err)
+		s.logger.ErrorContext(ctx, "Error marshalling search params", "error", err)
 		searchParams = []byte(`{"error": "failed to marshal search parameters"}`)
 	}
 
@@ -197,97 +290,506 @@ func (s *assistant610Service) ExportAssistant610Report( // Changed receiver type
 
 	logID, err := s.operationRepo.LogAccess(ctx, accessLog)
 	if err != nil {
-		log.Printf("Error logging access for export: %v", err)
+		s.logger.ErrorContext(ctx, "Error logging access for export", "error", err)
 	}
 
-	items, err := s.assistant610Repo.GetAssistant610Report(ctx, resolvedFromDate, resolvedToDate, departmentID)
+	maxRows := s.reportLimiter.MaxRows(ctx, userID, "assistant610")
+	queryCtx, finishQuery := s.queryTimeouts.start(s.rowPolicies.Apply(ctx, userID, "assistant610"), "assistant610")
+	items, err := s.assistant610Repo.GetAssistant610Report(queryCtx, resolvedFromDate, resolvedToDate, departmentID, exportRowLimit(maxRows))
+	err = finishQuery(err)
 	if err != nil {
-		log.Printf("Error getting inventory data for export: %v", err)
+		s.logger.ErrorContext(ctx, "Error getting inventory data for export", "error", err)
 		s.updateLogStatus(ctx, logID, "error")
 		return nil, fmt.Errorf("error getting inventory data for export: %w", err)
 	}
+	if err := checkExportRows(len(items), maxRows); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+	s.overlayNotes(ctx, items)
 
 	if len(items) == 0 {
-		log.Println("No data found to export for the specified date range")
+		s.logger.InfoContext(ctx, "No Assistant 610 data found to export")
 		s.updateLogStatus(ctx, logID, "success")
-		return nil, errors.New("no data found to export for the specified date range")
+		return nil, fmt.Errorf("%w to export for the specified date range", apperror.ErrNoData)
 	}
 
-	title := fmt.Sprintf("Export Sales 610 from %s to %s", resolvedFromDate.Format("02/01/2006"), resolvedToDate.Format("02/01/2006"))
-
-	headers := []string{
-		"doc_date",
-		"ar_type",
-		"shipping_order",
-		"customer_name",
-		"total_amt_trasn",
-		"total_amt",
-		"order_no",
-		"invoice_number",
-		"notes",
+	if err := s.convertAssistant610Totals(ctx, items, request.TargetCurrency); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
 	}
 
-	data := make([]map[string]interface{}, len(items))
-	for i, item := range items {
-		data[i] = map[string]interface{}{
-			"doc_date":        item.DocDate,
-			"ar_type":         item.Ar_Type,
-			"shipping_order":  item.ShippingOrder,
-			"customer_name":   item.CustomerName,
-			"total_amt_trasn": item.TotalAmtTrans,
-			"total_amt":       item.TotalAmt,
-			"order_no":        item.OrderNo,
-			"invoice_number":  item.InvoiceNumber,
-			"notes":           item.Notes,
-		}
+	nameData := assistant610NameData(userID, departmentID, resolvedFromDate, resolvedToDate)
+	title := withTargetCurrency(s.reportNamer.Title(ctx, nameData), request.TargetCurrency)
+
+	// The aging is built before masking, which may hide the amounts it sums
+	aging := s.buildAssistant610Aging(ctx, items, resolvedToDate)
+	masked := s.masker.Mask(ctx, userID, "assistant610", items)
+	agingMasked := maskAssistant610Aging(&aging, masked)
+
+	headers, data := assistant610ExportRows(ctx, items, columns)
+	masked.Rows(data)
+
+	agingHeaders, agingData := assistant610AgingRows(ctx, aging)
+	agingMasked.Rows(agingData)
+	agingSheet := utils.ExcelSheet{
+		Name:    "Aging",
+		Title:   strings.ReplaceAll(translate.Text(ctx, "aging_summary_title", "Aging Summary as of {date}"), "{date}", resolvedToDate.Format("02/01/2006")),
+		Headers: agingHeaders,
+		Data:    agingData,
+		ColumnTypes: map[string]utils.ExcelColumnType{
+			"document_count":  utils.ExcelNumber,
+			"aging_total_amt": utils.ExcelCurrency,
+			"aging_percent":   utils.ExcelNumber,
+		},
 	}
 
-	filePath, fileDetail, err := utils.ExportToExcel(data, headers, title)
+	_, fileDetail, err := utils.ExportSheetsToExcel(ctx, title, utils.ExcelSheet{
+		Name:        "Sheet1",
+		Title:       title,
+		Headers:     headers,
+		Data:        data,
+		ColumnTypes: assistant610ColumnTypes,
+	}, agingSheet)
 	if err != nil {
 		s.updateLogStatus(ctx, logID, "error")
 		return nil, fmt.Errorf("error exporting to Excel: %w", err)
 	}
+	if err := checkExportSize("assistant610", fileDetail.Len(), s.reportLimiter.MaxExportBytes("assistant610")); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
 
 	s.updateLogStatus(ctx, logID, "success")
 
-	fileName := filepath.Base(filePath)
+	fileName := s.reportNamer.FileName(ctx, nameData, title)
+	exportFile := &models.ReportFile{
+		FileName:    fileName,
+		Report:      "assistant610",
+		UserID:      userID,
+		AccessLogID: logID,
+		RowCount:    len(items),
+	}
+	downloadURL := storeExportFile(ctx, s.logger, s.fileStorage, s.fileRepo, exportFile, fileDetail)
+	publishExportFile(ctx, s.logger, s.sharePointClient, "assistant610", fileName, fileDetail)
+	s.eventService.Emit(ctx, events.ExportCompleted, events.ExportCompletedData{
+		Report:       "assistant610",
+		FileName:     fileName,
+		RowCount:     len(items),
+		UserID:       userID,
+		DepartmentID: departmentID,
+		DownloadURL:  downloadURL,
+	})
 
 	return &dto.ReportFileResponse{
 		ReportName:  title,
 		FileName:    fileName,
 		FileDetal:   fileDetail,
+		DownloadURL: downloadURL,
+		Checksum:    exportFile.Checksum,
 		GeneratedAt: time.Now(),
 	}, nil
 }
 
-// validateDateRange validates date range for reports.
-func (s *assistant610Service) validate610DateRange(fromDate, toDate time.Time) error {
-	if fromDate.After(toDate) {
-		return errors.New("from date must be before or equal to to date")
+// ExportAssistant610ReportToSheet pushes the 610 report into the configured Google Sheet.
+func (s *assistant610Service) ExportAssistant610ReportToSheet(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	request *dto.SheetExportRequest,
+) (*dto.SheetExportResponse, error) {
+	s.logger.DebugContext(ctx, "Exporting Assistant 610 report to Google Sheets", "user_id", userID, "department_id", departmentID, "request", request)
+
+	target, err := resolveSheetTarget(s.sheetsClient, "assistant610", request.SpreadsheetID, request.SheetName)
+	if err != nil {
+		return nil, err
+	}
+
+	resolvedFromDate, resolvedToDate, err := s.reportLimiter.DateRange("assistant610").Resolve(&request.DateRangeRequest)
+	if err != nil {
+		s.logger.WarnContext(ctx, "Error resolving date range", "error", err)
+		return nil, err
+	}
+
+	columns, err := Assistant610Columns(&request.DateRangeRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	logRequest := *request
+	logRequest.FromDate = &resolvedFromDate
+	logRequest.ToDate = &resolvedToDate
+
+	searchParams, err := reportSearchParams("assistant610", logRequest)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error marshalling search params", "error", err)
+		searchParams = []byte(`{"error": "failed to marshal search parameters"}`)
+	}
+
+	accessLog := &models.AccessLog{
+		UserID:       userID,
+		OperationID:  2,
+		AccessTime:   time.Now(),
+		SearchParams: string(searchParams),
+		Status:       "pending",
+	}
+
+	logID, err := s.operationRepo.LogAccess(ctx, accessLog)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error logging access for sheet export", "error", err)
+	}
+
+	maxRows := s.reportLimiter.MaxRows(ctx, userID, "assistant610")
+	queryCtx, finishQuery := s.queryTimeouts.start(s.rowPolicies.Apply(ctx, userID, "assistant610"), "assistant610")
+	items, err := s.assistant610Repo.GetAssistant610Report(queryCtx, resolvedFromDate, resolvedToDate, departmentID, exportRowLimit(maxRows))
+	err = finishQuery(err)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error getting inventory data for sheet export", "error", err)
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, fmt.Errorf("error getting inventory data for export: %w", err)
+	}
+	if err := checkExportRows(len(items), maxRows); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+	s.overlayNotes(ctx, items)
+
+	if len(items) == 0 {
+		s.logger.InfoContext(ctx, "No Assistant 610 data found to export")
+		s.updateLogStatus(ctx, logID, "success")
+		return nil, fmt.Errorf("%w to export for the specified date range", apperror.ErrNoData)
+	}
+
+	if err := s.convertAssistant610Totals(ctx, items, request.TargetCurrency); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+
+	nameData := assistant610NameData(userID, departmentID, resolvedFromDate, resolvedToDate)
+	title := withTargetCurrency(s.reportNamer.Title(ctx, nameData), request.TargetCurrency)
+
+	masked := s.masker.Mask(ctx, userID, "assistant610", items)
+	headers, data := assistant610ExportRows(ctx, items, columns)
+	masked.Rows(data)
+	values := buildSheetValues(ctx, headers, data)
+
+	if err := s.sheetsClient.WriteValues(ctx, target.SpreadsheetID, target.SheetName, values); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, fmt.Errorf("error exporting to Google Sheets: %w", err)
+	}
+
+	s.updateLogStatus(ctx, logID, "success")
+
+	return &dto.SheetExportResponse{
+		ReportName:    title,
+		SpreadsheetID: target.SpreadsheetID,
+		SheetName:     target.SheetName,
+		RowCount:      len(items),
+		ExportedAt:    time.Now(),
+	}, nil
+}
+
+// GetAssistant610AgingSummary groups the 610 receivables into age buckets by document date,
+// measured against the end of the requested range.
+func (s *assistant610Service) GetAssistant610AgingSummary(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	request *dto.DateRangeRequest,
+) (*dto.Assistant610AgingSummary, error) {
+	s.logger.DebugContext(ctx, "Getting Assistant 610 aging summary", "user_id", userID, "department_id", departmentID, "request", request)
+
+	resolvedFromDate, resolvedToDate, err := s.reportLimiter.DateRange("assistant610").Resolve(request)
+	if err != nil {
+		s.logger.WarnContext(ctx, "Error resolving date range", "error", err)
+		return nil, err
 	}
 
-	nowEndOfDay := time.Now().Truncate(24 * time.Hour).Add(24*time.Hour - time.Nanosecond)
-	if toDate.After(nowEndOfDay) {
-		return errors.New("to date cannot be in the future")
+	logRequest := *request
+	logRequest.FromDate = &resolvedFromDate
+	logRequest.ToDate = &resolvedToDate
+	searchParams, err := json.Marshal(logRequest)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error marshalling search params", "error", err)
+		searchParams = []byte(`{"error": "failed to marshal search parameters"}`)
 	}
 
-	maxMonths := s.config.Excel.MaxSearchMonths
-	oldestAllowed := time.Now().Truncate(24*time.Hour).AddDate(0, -maxMonths, 0)
+	accessLog := &models.AccessLog{
+		UserID:       userID,
+		OperationID:  1,
+		AccessTime:   time.Now(),
+		SearchParams: string(searchParams),
+		Status:       "pending",
+	}
+
+	logID, err := s.operationRepo.LogAccess(ctx, accessLog)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error logging access for aging summary", "error", err)
+	}
+
+	queryCtx, finishQuery := s.queryTimeouts.start(s.rowPolicies.Apply(ctx, userID, "assistant610"), "assistant610")
+	items, err := s.assistant610Repo.GetAssistant610Report(queryCtx, resolvedFromDate, resolvedToDate, departmentID, 0)
+	err = finishQuery(err)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error querying data for aging summary", "error", err)
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, fmt.Errorf("error querying inventory data: %w", err)
+	}
+
+	s.updateLogStatus(ctx, logID, "success")
+
+	summary := s.buildAssistant610Aging(ctx, items, resolvedToDate)
+	maskAssistant610Aging(&summary, s.masker.Mask(ctx, userID, "assistant610", items))
+	summary.ReportName = strings.NewReplacer(
+		"{from}", resolvedFromDate.Format("02/01/2006"),
+		"{to}", resolvedToDate.Format("02/01/2006"),
+	).Replace(translate.Text(ctx, "aging_summary_report_name", "Aging Sales 610 from {from} to {to}"))
+	return &summary, nil
+}
+
+// buildAssistant610Aging buckets receivable documents by age. The report has one row per
+// shipping line, so each AR document is counted once, at its oldest document date.
+func (s *assistant610Service) buildAssistant610Aging(ctx context.Context, items []dto.Asisstant610ReportItem, asOf time.Time) dto.Assistant610AgingSummary {
+	type arDocument struct {
+		docDate time.Time
+		amount  float64
+	}
 
-	if fromDate.Before(oldestAllowed) {
-		return fmt.Errorf("date range cannot exceed %d months from current date", maxMonths)
+	documents := make(map[string]*arDocument)
+	for _, item := range items {
+		docDate, err := time.Parse("02/01/2006", item.DocDate)
+		if err != nil {
+			s.logger.WarnContext(ctx, "Skipping 610 row with an invalid document date", "document", item.Ar_Type, "document_date", item.DocDate)
+			continue
+		}
+
+		if doc, ok := documents[item.Ar_Type]; ok {
+			if docDate.Before(doc.docDate) {
+				doc.docDate = docDate
+			}
+			continue
+		}
+
+		amount, err := strconv.ParseFloat(strings.ReplaceAll(item.TotalAmt, ",", ""), 64)
+		if err != nil {
+			s.logger.WarnContext(ctx, "Invalid total amount on 610 document", "document", item.Ar_Type, "total_amount", item.TotalAmt, "error", err)
+		}
+		documents[item.Ar_Type] = &arDocument{docDate: docDate, amount: amount}
+	}
+
+	bucketKeys := []string{dto.AgingBucket0To30, dto.AgingBucket31To60, dto.AgingBucket61To90, dto.AgingBucketOver90}
+	buckets := make(map[string]*dto.Assistant610AgingBucket, len(bucketKeys))
+	for _, key := range bucketKeys {
+		buckets[key] = &dto.Assistant610AgingBucket{Bucket: key}
+	}
+
+	summary := dto.Assistant610AgingSummary{
+		AsOf:        asOf,
+		GeneratedAt: time.Now(),
+	}
+
+	// Compare calendar dates so the time of day and location of asOf do not shift a bucket boundary
+	asOfDate := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC)
+	for _, doc := range documents {
+		days := int(asOfDate.Sub(doc.docDate).Hours() / 24)
+		bucket := buckets[agingBucket(days)]
+		bucket.DocumentCount++
+		bucket.TotalAmt += doc.amount
+		summary.DocumentCount++
+		summary.TotalAmt += doc.amount
+	}
+
+	summary.Buckets = make([]dto.Assistant610AgingBucket, 0, len(bucketKeys))
+	for _, key := range bucketKeys {
+		bucket := buckets[key]
+		if summary.TotalAmt != 0 {
+			bucket.Percent = math.Round(bucket.TotalAmt/summary.TotalAmt*10000) / 100
+		}
+		summary.Buckets = append(summary.Buckets, *bucket)
+	}
+
+	return summary
+}
+
+// maskAssistant610Aging clears the amounts of an aging summary when the total amount is masked,
+// keeping its document counts, and returns the columns of its export rows to mask with them
+func maskAssistant610Aging(summary *dto.Assistant610AgingSummary, masked MaskedColumns) MaskedColumns {
+	mask, ok := masked["total_amt"]
+	if !ok {
+		return nil
+	}
+
+	summary.TotalAmt = 0
+	for i := range summary.Buckets {
+		summary.Buckets[i].TotalAmt = 0
+		summary.Buckets[i].Percent = 0
+	}
+	return MaskedColumns{"aging_total_amt": mask, "aging_percent": mask}
+}
+
+// agingBucket returns the bucket key for a document that is the given number of days old
+func agingBucket(days int) string {
+	switch {
+	case days <= 30:
+		return dto.AgingBucket0To30
+	case days <= 60:
+		return dto.AgingBucket31To60
+	case days <= 90:
+		return dto.AgingBucket61To90
+	default:
+		return dto.AgingBucketOver90
+	}
+}
+
+// assistant610AgingRows maps the aging summary to export headers and rows, ending with a total row.
+func assistant610AgingRows(ctx context.Context, summary dto.Assistant610AgingSummary) ([]string, []map[string]interface{}) {
+	headers := []string{
+		"aging_bucket",
+		"document_count",
+		"aging_total_amt",
+		"aging_percent",
+	}
+
+	data := make([]map[string]interface{}, 0, len(summary.Buckets)+1)
+	for _, bucket := range summary.Buckets {
+		data = append(data, map[string]interface{}{
+			"aging_bucket":    bucket.Bucket,
+			"document_count":  bucket.DocumentCount,
+			"aging_total_amt": bucket.TotalAmt,
+			"aging_percent":   bucket.Percent,
+		})
+	}
+
+	totalPercent := 0.0
+	if summary.TotalAmt != 0 {
+		totalPercent = 100
+	}
+	data = append(data, map[string]interface{}{
+		"aging_bucket":    translate.Key(ctx, "total"),
+		"document_count":  summary.DocumentCount,
+		"aging_total_amt": summary.TotalAmt,
+		"aging_percent":   totalPercent,
+	})
+
+	return headers, data
+}
+
+// assistant610NameData holds the values used to name a 610 report
+func assistant610NameData(userID int, departmentID int, fromDate time.Time, toDate time.Time) ReportNameData {
+	return ReportNameData{
+		Report:       "assistant610",
+		Name:         "Export Sales 610",
+		FromDate:     fromDate,
+		ToDate:       toDate,
+		UserID:       userID,
+		DepartmentID: departmentID,
+	}
+}
+
+// overlayNotes replaces the ERP notes with the notes imported from edited exports. Notes are an
+// overlay, so a failure to read them is logged and the ERP notes are kept.
+func (s *assistant610Service) overlayNotes(ctx context.Context, items []dto.Asisstant610ReportItem) {
+	notes, err := loadReportNotes(ctx, s.annotationRepo, "assistant610")
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error loading imported notes", "error", err)
+		return
+	}
+	for i := range items {
+		if note, ok := notes[items[i].Ar_Type]; ok {
+			items[i].Notes = note
+		}
+	}
+}
+
+// convertAssistant610Totals fills in the converted total of every row when a target currency is requested.
+func (s *assistant610Service) convertAssistant610Totals(ctx context.Context, items []dto.Asisstant610ReportItem, targetCurrency string) error {
+	if targetCurrency == "" {
+		return nil
+	}
+
+	converter, err := s.exchangeRateService.Converter(ctx, targetCurrency)
+	if err != nil {
+		return err
+	}
+
+	for i := range items {
+		converted, err := converter.ConvertFormatted(items[i].TotalAmt, items[i].DocDate)
+		if err != nil {
+			return fmt.Errorf("error converting document %s: %w", items[i].Ar_Type, err)
+		}
+		items[i].ConvertedTotal = &converted
 	}
 
 	return nil
 }
 
+// ConvertedAssistant610Total sums the converted totals of a 610 report, counting each AR document
+// once like the aging summary. It returns nil when nothing was converted.
+func ConvertedAssistant610Total(items []dto.Asisstant610ReportItem) *float64 {
+	seen := make(map[string]bool, len(items))
+	var total *float64
+	for _, item := range items {
+		if item.ConvertedTotal == nil || seen[item.Ar_Type] {
+			continue
+		}
+		seen[item.Ar_Type] = true
+		if total == nil {
+			total = new(float64)
+		}
+		*total += *item.ConvertedTotal
+	}
+	if total != nil {
+		*total = math.Round(*total*100) / 100
+	}
+	return total
+}
+
+// assistant610ExportRows maps 610 items to export rows with the given columns, as returned by
+// Assistant610Columns. With the converted total among them a total row is added, labelled
+// under the first column.
+func assistant610ExportRows(ctx context.Context, items []dto.Asisstant610ReportItem, columns []string) ([]string, []map[string]interface{}) {
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = column
+		// the export header keeps its old spelling, which the translations are keyed by
+		if column == "total_amt_trans" {
+			headers[i] = "total_amt_trasn"
+		}
+	}
+
+	data := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		data[i] = map[string]interface{}{
+			"doc_date":        item.DocDate,
+			"ar_type":         item.Ar_Type,
+			"shipping_order":  item.ShippingOrder,
+			"customer_name":   item.CustomerName,
+			"total_amt_trasn": item.TotalAmtTrans,
+			"total_amt":       item.TotalAmt,
+			"order_no":        item.OrderNo,
+			"invoice_number":  item.InvoiceNumber,
+			"notes":           item.Notes,
+		}
+		if item.ConvertedTotal != nil {
+			data[i]["converted_total"] = *item.ConvertedTotal
+		}
+	}
+
+	if total := ConvertedAssistant610Total(items); total != nil && containsColumn(headers, convertedTotalColumn) {
+		data = append(data, totalRow(ctx, headers, *total))
+	}
+
+	return headers, data
+}
+
 // updateLogStatus updates the status of an access log.
 func (s *assistant610Service) updateLogStatus(ctx context.Context, logID int, status string) {
 	if logID <= 0 {
 		return // Skip updating if logID is invalid
 	}
 
+	ctx, status = logStatusContext(ctx, status)
 	if _, err := s.operationRepo.UpdateLogStatus(ctx, logID, status); err != nil {
-		log.Printf("Error updating log status for logID %d: %v", logID, err)
+		s.logger.ErrorContext(ctx, "Error updating log status", "log_id", logID, "error", err)
 	}
 }
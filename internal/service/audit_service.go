@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"log/slog"
+	"time"
+)
+
+const (
+	defaultAuditListLimit = 100
+	maxAuditListLimit     = 1000
+)
+
+// AuditService keeps the audit trail of mutating API requests
+type AuditService interface {
+	Enabled() bool
+	Record(entry *models.AuditLog)
+	List(ctx context.Context, filter repository.AuditLogFilter) ([]*models.AuditLog, error)
+	Start(ctx context.Context)
+}
+
+type auditService struct {
+	config    config.AuditConfig
+	auditRepo repository.AuditLogRepository
+	queue     chan *models.AuditLog
+	logger    *slog.Logger
+}
+
+// NewAuditService creates a new audit service
+func NewAuditService(cfg config.AuditConfig, auditRepo repository.AuditLogRepository, logger *slog.Logger) AuditService {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+
+	return &auditService{
+		config:    cfg,
+		auditRepo: auditRepo,
+		queue:     make(chan *models.AuditLog, cfg.QueueSize),
+		logger:    logger,
+	}
+}
+
+// Enabled reports whether requests are audited
+func (s *auditService) Enabled() bool {
+	return s.config.Enabled
+}
+
+// Record queues an entry for writing. It never blocks the request; when the writer falls behind
+// and the queue is full the entry is logged and dropped.
+func (s *auditService) Record(entry *models.AuditLog) {
+	if !s.config.Enabled {
+		return
+	}
+
+	select {
+	case s.queue <- entry:
+	default:
+		s.logger.Warn("Audit queue full, dropping entry", "method", entry.Method, "path", entry.Path, "user_id", entry.UserID, "status", entry.StatusCode)
+	}
+}
+
+// List returns the newest audit entries matching the filter
+func (s *auditService) List(ctx context.Context, filter repository.AuditLogFilter) ([]*models.AuditLog, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditListLimit
+	}
+	if filter.Limit > maxAuditListLimit {
+		filter.Limit = maxAuditListLimit
+	}
+
+	return s.auditRepo.List(ctx, filter)
+}
+
+// Start writes queued entries until the context is cancelled, then flushes what is left
+func (s *auditService) Start(ctx context.Context) {
+	if !s.config.Enabled {
+		return
+	}
+
+	go func() {
+		for {
+			select {
+			case entry := <-s.queue:
+				s.write(entry)
+			case <-ctx.Done():
+				for {
+					select {
+					case entry := <-s.queue:
+						s.write(entry)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	s.logger.InfoContext(ctx, "Audit trail started")
+}
+
+// write stores one entry, logging failures so that auditing never breaks a request
+func (s *auditService) write(entry *models.AuditLog) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := s.auditRepo.Create(ctx, entry); err != nil {
+		s.logger.ErrorContext(ctx, "Error writing audit entry", "method", entry.Method, "path", entry.Path, "error", err)
+	}
+}
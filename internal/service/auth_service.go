@@ -2,50 +2,106 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"erp-excel/config"
+	"erp-excel/database"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/cache"
 	"erp-excel/internal/dto"
+	"erp-excel/internal/events"
+	"erp-excel/internal/ldap"
 	"erp-excel/internal/models"
 	"erp-excel/internal/repository"
 	"erp-excel/internal/utils"
 	"errors"
 	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
+// ErrCompanyNotAllowed is returned when the user's department may not select a company
+var ErrCompanyNotAllowed = apperror.PermissionDenied("company not allowed")
+
 // AuthService interface
 type AuthService interface {
 	Login(ctx context.Context, req dto.LoginRequest) (*dto.LoginResponse, error)
 	ValidateToken(tokenString string) (*dto.TokenClaims, error)
-	GenerateToken(user *models.User) (string, error)
+	GenerateToken(user *models.User, company string) (string, error)
+	IssueLogin(ctx context.Context, user *models.User, company string) (*dto.LoginResponse, error)
+	SwitchCompany(ctx context.Context, userID int, company string) (*dto.LoginResponse, error)
+	GetCompanies(departmentID int, isAdmin bool) []dto.CompanyResponse
 	GetUserProfile(ctx context.Context, userID int) (*dto.UserResponse, error)
+	UpdateProfile(ctx context.Context, userID int, request dto.UpdateProfileRequest) (*dto.UserResponse, error)
+	Logout(ctx context.Context, tokenString string) error
+	IsTokenRevoked(ctx context.Context, tokenString string) bool
 }
 
 type authService struct {
-	userRepo repository.UserRepository
-	config   *config.Config
+	userRepo     repository.UserRepository
+	config       *config.Config
+	directory    *ldap.Client // nil when LDAP login is disabled
+	eventService EventService
+	store        cache.Store // revoked tokens
+	logger       *slog.Logger
 }
 
 // NewAuthService creates a new auth service
-func NewAuthService(userRepo repository.UserRepository, config *config.Config) AuthService {
+func NewAuthService(
+	userRepo repository.UserRepository,
+	config *config.Config,
+	directory *ldap.Client,
+	eventService EventService,
+	store cache.Store,
+	logger *slog.Logger,
+) AuthService {
 	return &authService{
-		userRepo: userRepo,
-		config:   config,
+		userRepo:     userRepo,
+		config:       config,
+		directory:    directory,
+		eventService: eventService,
+		store:        store,
+		logger:       logger,
 	}
 }
 
-// Login authenticates a user
+// Login authenticates a user with the local password or, when LDAP is enabled, the password of
+// the directory account with the same username
 func (s *authService) Login(ctx context.Context, req dto.LoginRequest) (*dto.LoginResponse, error) {
-	// Get user by username
-	user, err := s.userRepo.GetByUsername(ctx, req.Username)
+	user, err := s.authenticate(ctx, req.Username, req.Password)
 	if err != nil {
+		s.eventService.Emit(ctx, events.LoginFailed, events.LoginFailedData{
+			Username:  req.Username,
+			Reason:    err.Error(),
+			IPAddress: req.IPAddress,
+		})
+		return nil, err
+	}
+
+	return s.IssueLogin(ctx, user, req.Company)
+}
+
+// authenticate checks the password of a local or directory account and that the account is active
+func (s *authService) authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	// Get user by username
+	user, err := s.userRepo.GetByUsername(ctx, username)
+	if err != nil && (s.directory == nil || !errors.Is(err, sql.ErrNoRows)) {
 		return nil, errors.New("invalid username or password")
 	}
 
 	// Verify password
-	if !utils.CheckPasswordHash(req.Password, user.Password) {
-		return nil, errors.New("invalid username or password")
+	if user == nil || !utils.CheckPasswordHash(password, user.Password) {
+		if s.directory == nil {
+			return nil, errors.New("invalid username or password")
+		}
+		if user, err = s.directoryLogin(ctx, username, password, user); err != nil {
+			return nil, err
+		}
 	}
 
 	// Check if user is active
@@ -53,14 +109,91 @@ func (s *authService) Login(ctx context.Context, req dto.LoginRequest) (*dto.Log
 		return nil, errors.New("account is disabled")
 	}
 
+	return user, nil
+}
+
+// IssueLogin records the login and issues a token for an already authenticated user, working on
+// the given ERP company or, when empty, the default one
+func (s *authService) IssueLogin(ctx context.Context, user *models.User, company string) (*dto.LoginResponse, error) {
+	company, err := s.resolveCompany(company, user.DepartmentID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Update last login time
 	if err := s.userRepo.UpdateLastLogin(ctx, user.ID); err != nil {
 		// Just log this error, don't fail login
-		fmt.Printf("Error updating last login: %v\n", err)
+		s.logger.ErrorContext(ctx, "Error updating last login", "user_id", user.ID, "error", err)
+	}
+
+	return s.loginResponse(ctx, user, company)
+}
+
+// SwitchCompany issues a new token for the signed-in user working on another ERP company
+func (s *authService) SwitchCompany(ctx context.Context, userID int, company string) (*dto.LoginResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+	if !user.IsActive {
+		return nil, errors.New("account is disabled")
+	}
+
+	company, err = s.resolveCompany(company, user.DepartmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.loginResponse(ctx, user, company)
+}
+
+// GetCompanies returns the companies of the registry the department may select, sorted by code
+func (s *authService) GetCompanies(departmentID int, isAdmin bool) []dto.CompanyResponse {
+	defaultCompany := s.config.DefaultERPCompany()
+	companies := []dto.CompanyResponse{}
+	for code, company := range s.config.ERPCompanyRegistry() {
+		if !isAdmin && !company.Allows(departmentID) {
+			continue
+		}
+		companies = append(companies, dto.CompanyResponse{
+			Code:    code,
+			Name:    company.Name,
+			Default: code == defaultCompany,
+		})
+	}
+	sort.Slice(companies, func(i, j int) bool { return companies[i].Code < companies[j].Code })
+	return companies
+}
+
+// resolveCompany checks that the department may select the company. Without a company it picks
+// the default one, or the first the department may select when the default is restricted.
+func (s *authService) resolveCompany(company string, departmentID int) (string, error) {
+	registry := s.config.ERPCompanyRegistry()
+	company = strings.ToLower(strings.TrimSpace(company))
+	if company == "" {
+		if registry[s.config.DefaultERPCompany()].Allows(departmentID) {
+			return s.config.DefaultERPCompany(), nil
+		}
+		if allowed := s.GetCompanies(departmentID, false); len(allowed) > 0 {
+			return allowed[0].Code, nil
+		}
+		return "", fmt.Errorf("%w: no company is open to the department", ErrCompanyNotAllowed)
+	}
+
+	registered, ok := registry[company]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", database.ErrUnknownCompany, company)
+	}
+	if !registered.Allows(departmentID) {
+		return "", fmt.Errorf("%w: %s", ErrCompanyNotAllowed, company)
 	}
+	return company, nil
+}
 
+// loginResponse issues a token for the user and company and returns it with the user's profile
+func (s *authService) loginResponse(ctx context.Context, user *models.User, company string) (*dto.LoginResponse, error) {
 	// Generate JWT token
-	token, err := s.GenerateToken(user)
+	token, err := s.GenerateToken(user, company)
 	if err != nil {
 		return nil, fmt.Errorf("error generating token: %w", err)
 	}
@@ -83,6 +216,7 @@ func (s *authService) Login(ctx context.Context, req dto.LoginRequest) (*dto.Log
 		Username:     user.Username,
 		FullName:     user.FullName,
 		Email:        user.Email,
+		Phone:        user.Phone,
 		DepartmentID: user.DepartmentID,
 		Department:   user.Department.Name,
 		IsActive:     user.IsActive,
@@ -92,8 +226,9 @@ func (s *authService) Login(ctx context.Context, req dto.LoginRequest) (*dto.Log
 	}
 
 	return &dto.LoginResponse{
-		User:  userResp,
-		Token: token,
+		User:    userResp,
+		Token:   token,
+		Company: company,
 	}, nil
 }
 
@@ -120,8 +255,39 @@ func (s *authService) ValidateToken(tokenString string) (*dto.TokenClaims, error
 	return claims, nil
 }
 
-// GenerateToken generates a JWT token for a user
-func (s *authService) GenerateToken(user *models.User) (string, error) {
+// Logout revokes a token until it expires. Tokens are stateless, so the revocation is kept in the
+// store the instances share when Redis is configured.
+func (s *authService) Logout(ctx context.Context, tokenString string) error {
+	claims, err := s.ValidateToken(tokenString)
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return nil
+	}
+	return s.store.Set(ctx, revokedTokenKey(tokenString), []byte("1"), ttl)
+}
+
+// IsTokenRevoked reports whether a token was revoked by logging out
+func (s *authService) IsTokenRevoked(ctx context.Context, tokenString string) bool {
+	_, revoked, err := s.store.Get(ctx, revokedTokenKey(tokenString))
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error checking revoked tokens", "error", err)
+		return false
+	}
+	return revoked
+}
+
+// revokedTokenKey is the store key of a revoked token, its hash so the token itself is not stored
+func revokedTokenKey(tokenString string) string {
+	sum := sha256.Sum256([]byte(tokenString))
+	return "revoked_token:" + hex.EncodeToString(sum[:])
+}
+
+// GenerateToken generates a JWT token for a user working on an ERP company
+func (s *authService) GenerateToken(user *models.User, company string) (string, error) {
 	// Set expiration time
 	expirationTime := time.Now().Add(s.config.GetJWTExpiry())
 
@@ -130,6 +296,7 @@ func (s *authService) GenerateToken(user *models.User) (string, error) {
 		UserID:       user.ID,
 		Username:     user.Username,
 		DepartmentID: user.DepartmentID,
+		Company:      company,
 		Exp:          expirationTime.Unix(),
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime), // Sử dụng ExpiresAt
@@ -180,6 +347,7 @@ func (s *authService) GetUserProfile(ctx context.Context, userID int) (*dto.User
 		Username:     user.Username,
 		FullName:     user.FullName,
 		Email:        user.Email,
+		Phone:        user.Phone,
 		DepartmentID: user.DepartmentID,
 		Department:   departmentName,
 		IsActive:     user.IsActive,
@@ -188,3 +356,37 @@ func (s *authService) GetUserProfile(ctx context.Context, userID int) (*dto.User
 		Roles:        roleNames,
 	}, nil
 }
+
+// UpdateProfile changes the user's own name, email and phone; department, roles and the active
+// flag stay as the administrators set them
+func (s *authService) UpdateProfile(ctx context.Context, userID int, request dto.UpdateProfileRequest) (*dto.UserResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+
+	var changed []string
+	for _, field := range []struct {
+		name  string
+		value *string
+		new   string
+	}{
+		{"full_name", &user.FullName, strings.TrimSpace(request.FullName)},
+		{"email", &user.Email, strings.TrimSpace(request.Email)},
+		{"phone", &user.Phone, strings.TrimSpace(request.Phone)},
+	} {
+		if *field.value != field.new {
+			*field.value = field.new
+			changed = append(changed, field.name)
+		}
+	}
+
+	if len(changed) > 0 {
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return nil, fmt.Errorf("error updating profile: %w", err)
+		}
+		s.logger.InfoContext(ctx, "Profile updated", "user_id", userID, "fields", changed)
+	}
+
+	return s.GetUserProfile(ctx, userID)
+}
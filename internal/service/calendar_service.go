@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"erp-excel/config"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/utils"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	// calendarTokenPrefix starts every calendar token, so leaked ones are easy to recognise
+	calendarTokenPrefix = "cal_"
+	// calendarTokenTouchInterval limits how often the last use of a token is written; calendar
+	// clients poll the feed every few minutes
+	calendarTokenTouchInterval = time.Hour
+)
+
+var (
+	// ErrInvalidCalendarToken is returned when a feed token is unknown, replaced or revoked, or its
+	// user is deactivated or deleted
+	ErrInvalidCalendarToken = apperror.NotFound("invalid calendar token")
+	// ErrCalendarNotSubscribed is returned when revoking the token of a user who has none
+	ErrCalendarNotSubscribed = apperror.NotFound("no calendar subscription")
+)
+
+// CalendarSource contributes events to a user's calendar feed
+type CalendarSource interface {
+	Events(ctx context.Context, userID int) ([]utils.CalendarEvent, error)
+}
+
+// CalendarService builds iCalendar feeds that users subscribe to from Outlook/Google Calendar
+type CalendarService interface {
+	GetSubscription(ctx context.Context, userID int) (*models.CalendarToken, error)
+	Subscribe(ctx context.Context, userID int) (string, error)
+	Unsubscribe(ctx context.Context, userID int) error
+	UserIDFromToken(ctx context.Context, token string) (int, error)
+	BuildFeed(ctx context.Context, userID int) ([]byte, error)
+}
+
+type calendarService struct {
+	config    *config.Config
+	tokenRepo repository.CalendarTokenRepository
+	userRepo  repository.UserRepository
+	sources   []CalendarSource
+	logger    *slog.Logger
+}
+
+// NewCalendarService creates a new calendar service with the given event sources
+func NewCalendarService(
+	config *config.Config,
+	tokenRepo repository.CalendarTokenRepository,
+	userRepo repository.UserRepository,
+	logger *slog.Logger,
+	sources ...CalendarSource,
+) CalendarService {
+	return &calendarService{
+		config:    config,
+		tokenRepo: tokenRepo,
+		userRepo:  userRepo,
+		sources:   sources,
+		logger:    logger,
+	}
+}
+
+// GetSubscription returns the feed token of a user, without the token itself, or nil when the
+// user has none
+func (s *calendarService) GetSubscription(ctx context.Context, userID int) (*models.CalendarToken, error) {
+	token, err := s.tokenRepo.GetByUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+// Subscribe issues a new feed token for a user, which stops the previous one from working, and
+// returns it. Calendar clients cannot send Authorization headers, so the token is carried in the
+// feed URL; only its hash is stored, so it cannot be shown again.
+func (s *calendarService) Subscribe(ctx context.Context, userID int) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("error generating calendar token: %w", err)
+	}
+	token := calendarTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	if err := s.tokenRepo.Replace(ctx, &models.CalendarToken{UserID: userID, TokenHash: hashCalendarToken(token)}); err != nil {
+		return "", err
+	}
+
+	s.logger.InfoContext(ctx, "Calendar token issued", "user_id", userID)
+	return token, nil
+}
+
+// Unsubscribe revokes the feed token of a user
+func (s *calendarService) Unsubscribe(ctx context.Context, userID int) error {
+	if err := s.tokenRepo.Delete(ctx, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrCalendarNotSubscribed
+		}
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "Calendar token revoked", "user_id", userID)
+	return nil
+}
+
+// UserIDFromToken validates a feed token and returns the user it belongs to, who must still be
+// active
+func (s *calendarService) UserIDFromToken(ctx context.Context, token string) (int, error) {
+	if !strings.HasPrefix(token, calendarTokenPrefix) {
+		return 0, ErrInvalidCalendarToken
+	}
+
+	// The lookup by hash compares hashes, so it reveals nothing about the token itself
+	calendarToken, err := s.tokenRepo.GetByHash(ctx, hashCalendarToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidCalendarToken
+		}
+		return 0, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, calendarToken.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidCalendarToken
+		}
+		return 0, err
+	}
+	if !user.IsActive || user.DeletedAt != nil {
+		return 0, ErrInvalidCalendarToken
+	}
+
+	now := time.Now()
+	if calendarToken.LastUsedAt == nil || now.Sub(*calendarToken.LastUsedAt) >= calendarTokenTouchInterval {
+		if err := s.tokenRepo.TouchLastUsed(ctx, user.ID, now); err != nil {
+			s.logger.WarnContext(ctx, "Error recording calendar token use", "user_id", user.ID, "error", err)
+		}
+	}
+
+	return user.ID, nil
+}
+
+// BuildFeed collects the events of every source into one calendar.
+// A failing source is logged and skipped so the rest of the feed still updates.
+func (s *calendarService) BuildFeed(ctx context.Context, userID int) ([]byte, error) {
+	var events []utils.CalendarEvent
+	for _, source := range s.sources {
+		sourceEvents, err := source.Events(ctx, userID)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "Error loading calendar events", "error", err)
+			continue
+		}
+		events = append(events, sourceEvents...)
+	}
+
+	return utils.BuildICalendar(s.config.Server.Name, events), nil
+}
+
+// hashCalendarToken hashes a token for storage. Tokens carry 256 random bits, so a fast hash is
+// enough.
+func hashCalendarToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// reportRunSource lists the user's recent report views and exports
+type reportRunSource struct {
+	operationRepo repository.OperationRepository
+	historyDays   int
+}
+
+// NewReportRunCalendarSource creates a calendar source for the user's report runs
+func NewReportRunCalendarSource(operationRepo repository.OperationRepository, historyDays int) CalendarSource {
+	if historyDays <= 0 {
+		historyDays = 90
+	}
+	return &reportRunSource{
+		operationRepo: operationRepo,
+		historyDays:   historyDays,
+	}
+}
+
+// Events returns one event per report run
+func (s *reportRunSource) Events(ctx context.Context, userID int) ([]utils.CalendarEvent, error) {
+	since := time.Now().AddDate(0, 0, -s.historyDays)
+	logs, err := s.operationRepo.GetUserLogs(ctx, userID, since, 500)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]utils.CalendarEvent, 0, len(logs))
+	for _, accessLog := range logs {
+		events = append(events, utils.CalendarEvent{
+			UID:         fmt.Sprintf("report-run-%d@kanban", accessLog.ID),
+			Summary:     fmt.Sprintf("%s (%s)", accessLog.OperationName, accessLog.Status),
+			Description: accessLog.SearchParams,
+			Start:       accessLog.AccessTime,
+		})
+	}
+
+	return events, nil
+}
+
+// erpSyncSource shows the next scheduled ERP cache sync run
+type erpSyncSource struct {
+	config         *config.Config
+	erpSyncService ERPSyncService
+}
+
+// NewERPSyncCalendarSource creates a calendar source for scheduled ERP cache syncs
+func NewERPSyncCalendarSource(config *config.Config, erpSyncService ERPSyncService) CalendarSource {
+	return &erpSyncSource{
+		config:         config,
+		erpSyncService: erpSyncService,
+	}
+}
+
+// Events returns the next run of each sync group
+func (s *erpSyncSource) Events(ctx context.Context, userID int) ([]utils.CalendarEvent, error) {
+	if !s.config.ERPSync.Enabled {
+		return nil, nil
+	}
+
+	states, err := s.erpSyncService.GetStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(s.config.ERPSync.IntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = 15 * time.Minute
+	}
+
+	events := make([]utils.CalendarEvent, 0, len(states))
+	for _, state := range states {
+		events = append(events, utils.CalendarEvent{
+			UID:         fmt.Sprintf("erp-sync-%s@kanban", state.TableName),
+			Summary:     fmt.Sprintf("ERP sync: %s", state.TableName),
+			Description: fmt.Sprintf("Last run %s (%s)", state.LastRunAt.Format(time.RFC3339), state.Status),
+			Start:       state.LastRunAt.Add(interval),
+		})
+	}
+
+	return events, nil
+}
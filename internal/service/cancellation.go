@@ -0,0 +1,13 @@
+package service
+
+import "context"
+
+// logStatusContext prepares the final update of an access log. The update must outlive the request,
+// so it gets a context that is not cancelled with it, and a run that failed because the client went
+// away is recorded as cancelled rather than as an error.
+func logStatusContext(ctx context.Context, status string) (context.Context, string) {
+	if status == "error" && ctx.Err() != nil {
+		status = "cancelled"
+	}
+	return context.WithoutCancel(ctx), status
+}
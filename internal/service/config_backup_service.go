@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidConfigBundle is returned for a bundle that cannot be restored
+var ErrInvalidConfigBundle = apperror.Validation("invalid configuration bundle")
+
+// ConfigBackupService backs up and restores the permission configuration
+type ConfigBackupService interface {
+	Export(ctx context.Context) (*dto.ConfigBundle, error)
+	Restore(ctx context.Context, bundle *dto.ConfigBundle, userID int, dryRun bool) (*dto.ConfigRestoreResponse, error)
+}
+
+type configBackupService struct {
+	serverConfig   config.ServerConfig
+	backupRepo     repository.ConfigBackupRepository
+	definitionRepo repository.ReportDefinitionRepository
+}
+
+// NewConfigBackupService creates a new configuration backup service
+func NewConfigBackupService(
+	serverConfig config.ServerConfig,
+	backupRepo repository.ConfigBackupRepository,
+	definitionRepo repository.ReportDefinitionRepository,
+) ConfigBackupService {
+	return &configBackupService{
+		serverConfig:   serverConfig,
+		backupRepo:     backupRepo,
+		definitionRepo: definitionRepo,
+	}
+}
+
+// Export returns the current configuration as a bundle tagged with this instance
+func (s *configBackupService) Export(ctx context.Context) (*dto.ConfigBundle, error) {
+	bundle, err := s.backupRepo.Export(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bundle.Source = strings.TrimSpace(s.serverConfig.Name + " " + s.serverConfig.Env)
+
+	return bundle, nil
+}
+
+// Restore checks a bundle and merges it into the configuration
+func (s *configBackupService) Restore(
+	ctx context.Context,
+	bundle *dto.ConfigBundle,
+	userID int,
+	dryRun bool,
+) (*dto.ConfigRestoreResponse, error) {
+	if bundle.Version < 1 || bundle.Version > dto.ConfigBundleVersion {
+		return nil, fmt.Errorf("%w: version %d is not supported, expected at most %d", ErrInvalidConfigBundle, bundle.Version, dto.ConfigBundleVersion)
+	}
+	if err := validateConfigBundle(bundle); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfigBundle, err)
+	}
+
+	return s.backupRepo.Restore(ctx, bundle, userID, dryRun)
+}
+
+// validateConfigBundle rejects duplicate keys, role operations missing from the bundle and
+// incomplete report definitions before anything is written
+func validateConfigBundle(bundle *dto.ConfigBundle) error {
+	operations := make(map[string]bool, len(bundle.Operations))
+	for _, operation := range bundle.Operations {
+		if operations[operation.Code] {
+			return fmt.Errorf("operation %s appears more than once", operation.Code)
+		}
+		operations[operation.Code] = true
+	}
+
+	departments := make(map[string]bool, len(bundle.Departments))
+	for _, department := range bundle.Departments {
+		if departments[department.Code] {
+			return fmt.Errorf("department %s appears more than once", department.Code)
+		}
+		departments[department.Code] = true
+	}
+
+	definitions := make(map[string]bool, len(bundle.ReportDefinitions))
+	for _, definition := range bundle.ReportDefinitions {
+		if definition == nil || definition.Code == "" || definition.Name == "" || definition.Source == "" {
+			return errors.New("report definitions need a code, name and source")
+		}
+		if definition.SourceType != models.ReportSourceProcedure && definition.SourceType != models.ReportSourceQuery {
+			return fmt.Errorf("report definition %s has unknown source type %q", definition.Code, definition.SourceType)
+		}
+		if definitions[definition.Code] {
+			return fmt.Errorf("report definition %s appears more than once", definition.Code)
+		}
+		definitions[definition.Code] = true
+
+		if definition.OperationCode == "" {
+			definition.OperationCode = "report_" + definition.Code
+		}
+		// The operation of a report definition is created with it
+		operations[definition.OperationCode] = true
+	}
+
+	roles := make(map[string]bool, len(bundle.Roles))
+	for _, role := range bundle.Roles {
+		if roles[role.Name] {
+			return fmt.Errorf("role %s appears more than once", role.Name)
+		}
+		roles[role.Name] = true
+
+		for _, code := range role.Operations {
+			if !operations[code] {
+				return fmt.Errorf("role %s references operation %s which is not in the bundle", role.Name, code)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"erp-excel/config"
+	"erp-excel/database"
+	"erp-excel/internal/cache"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/repository"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DashboardService computes the business figures of the dashboard from the ERP
+type DashboardService interface {
+	GetStatistics(ctx context.Context, userID int) (*dto.DashboardStatistics, error)
+}
+
+type dashboardService struct {
+	dashboardRepo repository.DashboardRepository
+	rowPolicies   RowPolicyService
+	store         cache.Store // cached figures, shared by the instances when Redis is configured
+	cacheFor      time.Duration
+	topCustomers  int
+	logger        *slog.Logger
+
+	mu sync.Mutex
+}
+
+func NewDashboardService(
+	cfg config.DashboardConfig,
+	dashboardRepo repository.DashboardRepository,
+	rowPolicies RowPolicyService,
+	store cache.Store,
+	logger *slog.Logger,
+) DashboardService {
+	service := &dashboardService{
+		dashboardRepo: dashboardRepo,
+		rowPolicies:   rowPolicies,
+		store:         store,
+		cacheFor:      time.Duration(cfg.CacheSeconds) * time.Second,
+		topCustomers:  cfg.TopCustomers,
+		logger:        logger,
+	}
+	if service.cacheFor <= 0 {
+		service.cacheFor = 5 * time.Minute
+	}
+	if service.topCustomers <= 0 {
+		service.topCustomers = 10
+	}
+	return service
+}
+
+// GetStatistics returns the sales figures of the current month for the company of the request.
+// They are read from the ERP at most once per cache lifetime; concurrent callers of an instance
+// wait for the one query instead of each running their own. The figures only count the sales
+// orders the Assistant 230 row policies of the user allow; restricted figures are cached for the
+// user alone.
+func (s *dashboardService) GetStatistics(ctx context.Context, userID int) (*dto.DashboardStatistics, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx = s.rowPolicies.Apply(ctx, userID, "assistant230")
+	company := database.CompanyFromContext(ctx)
+	now := time.Now()
+	fromDate := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	// The month is part of the key, so the figures of the previous month are not served past its end
+	key := fmt.Sprintf("dashboard:%s:%s", company, fromDate.Format("2006-01"))
+	if repository.RowPolicyRestricts(ctx) {
+		key = fmt.Sprintf("%s:user:%d", key, userID)
+	}
+	if cached, ok, err := s.store.Get(ctx, key); err == nil && ok {
+		var statistics dto.DashboardStatistics
+		if err := json.Unmarshal(cached, &statistics); err == nil {
+			return &statistics, nil
+		}
+	}
+
+	totals, err := s.dashboardRepo.GetSalesTotals(ctx, fromDate, now)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error getting dashboard sales totals", "error", err)
+		return nil, fmt.Errorf("error getting sales totals: %w", err)
+	}
+
+	customers, err := s.dashboardRepo.GetTopCustomers(ctx, fromDate, now, s.topCustomers)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error getting dashboard top customers", "error", err)
+		return nil, fmt.Errorf("error getting top customers: %w", err)
+	}
+
+	statistics := &dto.DashboardStatistics{
+		FromDate:             fromDate,
+		ToDate:               now,
+		DashboardSalesTotals: *totals,
+		TopCustomers:         customers,
+		GeneratedAt:          now,
+	}
+	if data, err := json.Marshal(statistics); err == nil {
+		if err := s.store.Set(ctx, key, data, s.cacheFor); err != nil {
+			s.logger.ErrorContext(ctx, "Error caching dashboard statistics", "error", err)
+		}
+	}
+
+	return statistics, nil
+}
@@ -0,0 +1,675 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/config"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/translate"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+
+	excelize "github.com/xuri/excelize/v2"
+)
+
+var (
+	// ErrImportNotFound is returned for an unknown import batch
+	ErrImportNotFound = apperror.NotFound("import not found")
+	// ErrImportClosed is returned when a batch that was already committed or discarded is changed
+	ErrImportClosed = apperror.Conflict("import has already been committed or discarded")
+	// ErrInvalidImport is returned for an unknown import type or a sheet that cannot be imported
+	ErrInvalidImport = apperror.Validation("invalid import")
+	// ErrNothingToCommit is returned when no row of a batch differs from the ERP any more
+	ErrNothingToCommit = apperror.Conflict("the import has no changed rows to commit")
+	// ErrImportRuleNotFound is returned for an unknown validation rule
+	ErrImportRuleNotFound = apperror.NotFound("import rule not found")
+)
+
+const importListLimit = 50
+
+// erpValue is the value the ERP holds for the document of an imported row, or the reason the
+// document cannot be corrected
+type erpValue struct {
+	value   string
+	problem string
+}
+
+// importType is an import of ERP corrections: the sheet column naming the document, the column
+// holding the corrected value and how the current ERP values are read
+type importType struct {
+	keyColumn   string
+	valueColumn string
+	// rules are the built-in validation rules; the config and the database can add more
+	rules []models.ImportRule
+	// current reads the ERP value of each document; documents missing from the result are not in the ERP
+	current func(ctx context.Context, repo repository.DataImportRepository, keys []string) (map[string]erpValue, error)
+}
+
+// importTypes are the corrections that can be imported. The columns are the export columns, so
+// an edited report export can be uploaded as it is.
+var importTypes = map[string]importType{
+	"invoice_number": {
+		keyColumn:   "sales_order_number",
+		valueColumn: "invoice_number",
+		rules: []models.ImportRule{
+			{Column: "sales_order_number", Type: models.ImportRuleRequired},
+			{Column: "sales_order_number", Type: models.ImportRulePattern, Pattern: `^\S+-\S+$`, Message: "must be a document number such as 2301-20240001"},
+			{Column: "invoice_number", Type: models.ImportRuleRequired},
+			{Column: "invoice_number", Type: models.ImportRuleMaxLength, Max: &invoiceNumberLength},
+		},
+		current: currentInvoiceNumbers,
+	},
+}
+
+// invoiceNumberLength is the length of ACRTA.TA036
+var invoiceNumberLength = 30.0
+
+// importSheetRow is a row read from an uploaded sheet: the row to stage and the cells of the
+// columns the validation rules name
+type importSheetRow struct {
+	*models.ImportRow
+	cells map[string]string
+}
+
+// DataImportService imports corrections of ERP data from Excel. An upload is validated row by row
+// against the ERP and staged as a preview; committing it keeps the changed rows for the ERP team
+// to apply. Nothing is written to the ERP.
+type DataImportService interface {
+	Upload(ctx context.Context, userID int, importType string, fileName string, file io.Reader, ipAddress string) (*dto.DataImportResponse, error)
+	List(ctx context.Context) ([]*models.ImportBatch, error)
+	Get(ctx context.Context, id int) (*dto.DataImportResponse, error)
+	Commit(ctx context.Context, userID int, id int, ipAddress string) (*dto.DataImportResponse, error)
+	Discard(ctx context.Context, userID int, id int, ipAddress string) error
+
+	ListRules(ctx context.Context, importType string) ([]*models.ImportRule, error)
+	CreateRule(ctx context.Context, userID int, request *dto.ImportRuleRequest) (*models.ImportRule, error)
+	DeleteRule(ctx context.Context, userID int, id int) error
+}
+
+type dataImportService struct {
+	config           config.ImportsConfig
+	importRepo       repository.DataImportRepository
+	operationService OperationService
+	logger           *slog.Logger
+}
+
+// NewDataImportService creates a new data import service
+func NewDataImportService(
+	cfg config.ImportsConfig,
+	importRepo repository.DataImportRepository,
+	operationService OperationService,
+	logger *slog.Logger,
+) DataImportService {
+	if cfg.OperationCode == "" {
+		cfg.OperationCode = "data_import"
+	}
+	if cfg.MaxRows <= 0 {
+		cfg.MaxRows = 5000
+	}
+	if cfg.RuleOperationCode == "" {
+		cfg.RuleOperationCode = "data_import_rules"
+	}
+
+	return &dataImportService{
+		config:           cfg,
+		importRepo:       importRepo,
+		operationService: operationService,
+		logger:           logger,
+	}
+}
+
+// Upload reads the first sheet of an Excel file, validates every row against the rules of the
+// import type and the ERP, and stages the rows as a previewed batch
+func (s *dataImportService) Upload(
+	ctx context.Context,
+	userID int,
+	typeName string,
+	fileName string,
+	file io.Reader,
+	ipAddress string,
+) (*dto.DataImportResponse, error) {
+	kind, ok := importTypes[typeName]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown import type %s", ErrInvalidImport, typeName)
+	}
+
+	logID, err := s.operationService.LogAccess(ctx, userID, s.config.OperationCode, map[string]interface{}{
+		"action":      "upload",
+		"import_type": typeName,
+		"file_name":   fileName,
+	}, ipAddress)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error logging access for import", "error", err)
+	}
+
+	rules, err := s.compiledRules(ctx, typeName, kind)
+	if err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+
+	sheetRows, err := readImportSheet(ctx, file, kind, ruleColumns(rules), s.config.MaxRows)
+	if err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+
+	if err := s.validate(ctx, kind, rules, sheetRows); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+
+	rows := make([]*models.ImportRow, len(sheetRows))
+	for i, row := range sheetRows {
+		rows[i] = row.ImportRow
+	}
+
+	batch := &models.ImportBatch{
+		ImportType: typeName,
+		FileName:   fileName,
+		Status:     models.ImportPreviewed,
+		UserID:     userID,
+	}
+	countImportRows(batch, rows)
+
+	if err := s.importRepo.CreateBatch(ctx, batch, rows); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "Import staged",
+		"batch_id", batch.ID, "import_type", typeName, "user_id", userID,
+		"changed", batch.ChangedRows, "unchanged", batch.UnchangedRows, "invalid", batch.InvalidRows)
+	s.updateLogStatus(ctx, logID, "success")
+	return &dto.DataImportResponse{Batch: batch, Rows: rows}, nil
+}
+
+// List returns the newest import batches
+func (s *dataImportService) List(ctx context.Context) ([]*models.ImportBatch, error) {
+	return s.importRepo.ListBatches(ctx, importListLimit)
+}
+
+// Get returns a batch with its staged rows
+func (s *dataImportService) Get(ctx context.Context, id int) (*dto.DataImportResponse, error) {
+	batch, err := s.getBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.importRepo.ListRows(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.DataImportResponse{Batch: batch, Rows: rows}, nil
+}
+
+// Commit validates the changed rows of a previewed batch against the ERP again and commits the
+// batch. Rows whose document changed in the ERP since the preview are left out as invalid.
+func (s *dataImportService) Commit(ctx context.Context, userID int, id int, ipAddress string) (*dto.DataImportResponse, error) {
+	batch, err := s.getBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if batch.Status != models.ImportPreviewed {
+		return nil, ErrImportClosed
+	}
+	kind, ok := importTypes[batch.ImportType]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown import type %s", ErrInvalidImport, batch.ImportType)
+	}
+
+	rows, err := s.importRepo.ListRows(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rechecked, err := s.recheck(ctx, kind, rows)
+	if err != nil {
+		return nil, err
+	}
+	countImportRows(batch, rows)
+	if batch.ChangedRows == 0 {
+		return nil, ErrNothingToCommit
+	}
+
+	logID, err := s.operationService.LogAccess(ctx, userID, s.config.OperationCode, map[string]interface{}{
+		"action":      "commit",
+		"batch_id":    id,
+		"import_type": batch.ImportType,
+		"changed":     batch.ChangedRows,
+	}, ipAddress)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error logging access for import", "error", err)
+	}
+
+	if err := s.importRepo.Commit(ctx, batch, rechecked, userID); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		if errors.Is(err, sql.ErrNoRows) {
+			// Committed or discarded by another user in the meantime
+			return nil, ErrImportClosed
+		}
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "Import committed",
+		"batch_id", id, "import_type", batch.ImportType, "user_id", userID,
+		"changed", batch.ChangedRows, "invalid", batch.InvalidRows)
+	s.updateLogStatus(ctx, logID, "success")
+	return s.Get(ctx, id)
+}
+
+// Discard drops a previewed batch
+func (s *dataImportService) Discard(ctx context.Context, userID int, id int, ipAddress string) error {
+	batch, err := s.getBatch(ctx, id)
+	if err != nil {
+		return err
+	}
+	if batch.Status != models.ImportPreviewed {
+		return ErrImportClosed
+	}
+
+	logID, err := s.operationService.LogAccess(ctx, userID, s.config.OperationCode, map[string]interface{}{
+		"action":      "discard",
+		"batch_id":    id,
+		"import_type": batch.ImportType,
+	}, ipAddress)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error logging access for import", "error", err)
+	}
+
+	if err := s.importRepo.Discard(ctx, id, userID); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrImportClosed
+		}
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "Import discarded", "batch_id", id, "user_id", userID)
+	s.updateLogStatus(ctx, logID, "success")
+	return nil
+}
+
+// ListRules returns the validation rules of an import type, or of all types when importType is
+// empty: the built-in rules, then those of the config, then those managed in the database
+func (s *dataImportService) ListRules(ctx context.Context, typeName string) ([]*models.ImportRule, error) {
+	typeNames := []string{typeName}
+	if typeName == "" {
+		typeNames = make([]string, 0, len(importTypes))
+		for name := range importTypes {
+			typeNames = append(typeNames, name)
+		}
+		sort.Strings(typeNames)
+	}
+
+	var rules []*models.ImportRule
+	for _, name := range typeNames {
+		kind, ok := importTypes[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown import type %s", ErrInvalidImport, name)
+		}
+		typeRules, err := s.listRules(ctx, name, kind)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, typeRules...)
+	}
+	return rules, nil
+}
+
+// CreateRule adds a validation rule managed in the database, applied to the next uploads of its
+// import type
+func (s *dataImportService) CreateRule(ctx context.Context, userID int, request *dto.ImportRuleRequest) (*models.ImportRule, error) {
+	if _, ok := importTypes[request.ImportType]; !ok {
+		return nil, fmt.Errorf("%w: unknown import type %s", ErrInvalidImportRule, request.ImportType)
+	}
+
+	rule := &models.ImportRule{
+		ImportType: request.ImportType,
+		Column:     request.Column,
+		Type:       request.Type,
+		Pattern:    request.Pattern,
+		Min:        request.Min,
+		Max:        request.Max,
+		Lookup:     request.Lookup,
+		Message:    request.Message,
+		CreatedBy:  userID,
+	}
+	if _, err := compileImportRule(rule); err != nil {
+		return nil, err
+	}
+
+	if err := s.importRepo.CreateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "Import rule created",
+		"rule_id", rule.ID, "import_type", rule.ImportType, "column", rule.Column, "type", rule.Type, "user_id", userID)
+	return rule, nil
+}
+
+// DeleteRule deletes a validation rule managed in the database
+func (s *dataImportService) DeleteRule(ctx context.Context, userID int, id int) error {
+	if err := s.importRepo.DeleteRule(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrImportRuleNotFound
+		}
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "Import rule deleted", "rule_id", id, "user_id", userID)
+	return nil
+}
+
+// listRules returns the built-in, configured and database rules of an import type
+func (s *dataImportService) listRules(ctx context.Context, typeName string, kind importType) ([]*models.ImportRule, error) {
+	rules := make([]*models.ImportRule, 0, len(kind.rules))
+	for _, rule := range kind.rules {
+		rule.ImportType = typeName
+		rule.Source = models.ImportRuleBuiltIn
+		rules = append(rules, &rule)
+	}
+	rules = append(rules, configImportRules(typeName, s.config.Rules[typeName])...)
+
+	custom, err := s.importRepo.ListRules(ctx, typeName)
+	if err != nil {
+		return nil, err
+	}
+	return append(rules, custom...), nil
+}
+
+// compiledRules returns the rules of an import type ready to be applied. A configured or database
+// rule that cannot be applied fails the import rather than being skipped silently.
+func (s *dataImportService) compiledRules(ctx context.Context, typeName string, kind importType) ([]importRule, error) {
+	rules, err := s.listRules(ctx, typeName, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled := make([]importRule, 0, len(rules))
+	for _, rule := range rules {
+		c, err := compileImportRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("%s rule: %w", rule.Source, err)
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// validate checks the cells of each row against the rules, the documents for duplicates, then
+// the documents against the ERP, and sets the status and old value of each row
+func (s *dataImportService) validate(ctx context.Context, kind importType, rules []importRule, rows []importSheetRow) error {
+	if err := applyImportRules(ctx, s.importRepo, rules, rows); err != nil {
+		return err
+	}
+
+	firstRow := make(map[string]int, len(rows))
+	for _, row := range rows {
+		if row.Key == "" || hasCellError(row.ImportRow, kind.keyColumn) {
+			continue
+		}
+		if first, ok := firstRow[row.Key]; ok {
+			row.Errors = append(row.Errors, models.ImportCellError{
+				Column:  kind.keyColumn,
+				Code:    models.ImportErrorDuplicate,
+				Value:   row.Key,
+				Message: fmt.Sprintf("has a different value on row %d", first),
+			})
+		} else {
+			firstRow[row.Key] = row.RowNumber
+		}
+	}
+
+	var keys []string
+	for _, row := range rows {
+		if len(row.Errors) == 0 {
+			keys = append(keys, row.Key)
+		}
+	}
+	current, err := kind.current(ctx, s.importRepo, keys)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			row.Status = models.ImportRowInvalid
+			continue
+		}
+
+		erp, found := current[row.Key]
+		switch {
+		case !found:
+			row.Errors = append(row.Errors, models.ImportCellError{
+				Column: kind.keyColumn, Code: models.ImportErrorNotFound, Value: row.Key, Message: "not found in the ERP",
+			})
+			row.Status = models.ImportRowInvalid
+		case erp.problem != "":
+			row.Errors = append(row.Errors, models.ImportCellError{
+				Column: kind.keyColumn, Code: models.ImportErrorERPState, Value: row.Key, Message: erp.problem,
+			})
+			row.Status = models.ImportRowInvalid
+		case erp.value == row.NewValue:
+			row.OldValue = erp.value
+			row.Status = models.ImportRowUnchanged
+		default:
+			row.OldValue = erp.value
+			row.Status = models.ImportRowChanged
+		}
+	}
+	return nil
+}
+
+// recheck reads the ERP values of the changed rows again and returns the rows whose status
+// changed: rows the ERP now agrees with become unchanged, rows changed in the ERP since the
+// preview become invalid
+func (s *dataImportService) recheck(ctx context.Context, kind importType, rows []*models.ImportRow) ([]*models.ImportRow, error) {
+	var keys []string
+	for _, row := range rows {
+		if row.Status == models.ImportRowChanged {
+			keys = append(keys, row.Key)
+		}
+	}
+	current, err := kind.current(ctx, s.importRepo, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var rechecked []*models.ImportRow
+	for _, row := range rows {
+		if row.Status != models.ImportRowChanged {
+			continue
+		}
+
+		erp, found := current[row.Key]
+		switch {
+		case !found:
+			row.Errors = append(row.Errors, models.ImportCellError{
+				Column: kind.keyColumn, Code: models.ImportErrorNotFound, Value: row.Key, Message: "no longer found in the ERP",
+			})
+			row.Status = models.ImportRowInvalid
+		case erp.problem != "":
+			row.Errors = append(row.Errors, models.ImportCellError{
+				Column: kind.keyColumn, Code: models.ImportErrorERPState, Value: row.Key, Message: erp.problem,
+			})
+			row.Status = models.ImportRowInvalid
+		case erp.value == row.NewValue:
+			row.OldValue = erp.value
+			row.Status = models.ImportRowUnchanged
+		case erp.value != row.OldValue:
+			row.Errors = append(row.Errors, models.ImportCellError{
+				Column:  kind.valueColumn,
+				Code:    models.ImportErrorERPModified,
+				Value:   row.NewValue,
+				Message: fmt.Sprintf("changed in the ERP to %q since the preview", erp.value),
+			})
+			row.Status = models.ImportRowInvalid
+		default:
+			continue
+		}
+		rechecked = append(rechecked, row)
+	}
+	return rechecked, nil
+}
+
+// getBatch gets a batch, mapping a missing one to ErrImportNotFound
+func (s *dataImportService) getBatch(ctx context.Context, id int) (*models.ImportBatch, error) {
+	batch, err := s.importRepo.GetBatch(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrImportNotFound
+		}
+		return nil, err
+	}
+	return batch, nil
+}
+
+// updateLogStatus updates the status of an access log.
+func (s *dataImportService) updateLogStatus(ctx context.Context, logID int, status string) {
+	if logID <= 0 {
+		return
+	}
+
+	ctx, status = logStatusContext(ctx, status)
+	if _, err := s.operationService.UpdateLogStatus(ctx, logID, status); err != nil {
+		s.logger.ErrorContext(ctx, "Error updating log status", "log_id", logID, "error", err)
+	}
+}
+
+// countImportRows sets the row counts of a batch
+func countImportRows(batch *models.ImportBatch, rows []*models.ImportRow) {
+	batch.TotalRows = len(rows)
+	batch.ChangedRows, batch.UnchangedRows, batch.InvalidRows = 0, 0, 0
+	for _, row := range rows {
+		switch row.Status {
+		case models.ImportRowChanged:
+			batch.ChangedRows++
+		case models.ImportRowUnchanged:
+			batch.UnchangedRows++
+		default:
+			batch.InvalidRows++
+		}
+	}
+}
+
+// readImportSheet reads the document and value columns, and the other columns the rules name,
+// from the first sheet of a workbook. The header row is found by the translated or raw column
+// names, as for note imports. Blank rows, and rows repeating a document with the same value as
+// report exports do for each order line, are skipped; rows are numbered as in Excel.
+func readImportSheet(ctx context.Context, file io.Reader, kind importType, ruleColumns []string, maxRows int) ([]importSheetRow, error) {
+	f, err := excelize.OpenReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading Excel file: %w", err)
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, errors.New("the Excel file has no sheets")
+	}
+	sheetRows, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, fmt.Errorf("error reading sheet %s: %w", sheets[0], err)
+	}
+
+	headerRow := -1
+	var indexes map[string]int
+	for i := 0; i < len(sheetRows) && i < 10 && headerRow < 0; i++ {
+		indexes = headerIndexes(sheetRows[i], kind.keyColumn, kind.valueColumn, ruleColumns)
+		_, hasKey := indexes[kind.keyColumn]
+		_, hasValue := indexes[kind.valueColumn]
+		if hasKey && hasValue {
+			headerRow = i
+		}
+	}
+	if headerRow < 0 {
+		return nil, fmt.Errorf("the Excel file has no %q and %q columns", translate.Key(ctx, kind.keyColumn), translate.Key(ctx, kind.valueColumn))
+	}
+
+	var missing []string
+	for _, column := range ruleColumns {
+		if _, ok := indexes[column]; !ok {
+			missing = append(missing, fmt.Sprintf("%q", translate.Key(ctx, column)))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("the Excel file has no %s column", strings.Join(missing, ", "))
+	}
+
+	var rows []importSheetRow
+	seen := make(map[[2]string]bool)
+	for i := headerRow + 1; i < len(sheetRows); i++ {
+		cells := make(map[string]string, len(indexes))
+		blank := true
+		for column, index := range indexes {
+			cells[column] = strings.TrimSpace(cellAt(sheetRows[i], index))
+			blank = blank && cells[column] == ""
+		}
+		key, value := cells[kind.keyColumn], cells[kind.valueColumn]
+		if blank || seen[[2]string{key, value}] {
+			continue
+		}
+		seen[[2]string{key, value}] = true
+		if len(rows) == maxRows {
+			return nil, fmt.Errorf("the sheet has more than %d rows", maxRows)
+		}
+		rows = append(rows, importSheetRow{
+			ImportRow: &models.ImportRow{RowNumber: i + 1, Key: key, NewValue: value},
+			cells:     cells,
+		})
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("the sheet has no rows to import")
+	}
+	return rows, nil
+}
+
+// headerIndexes returns the index of each of the columns found in a header row
+func headerIndexes(header []string, keyColumn, valueColumn string, ruleColumns []string) map[string]int {
+	columns := append([]string{keyColumn, valueColumn}, ruleColumns...)
+	indexes := make(map[string]int, len(columns))
+	for j, cell := range header {
+		for _, column := range columns {
+			if _, ok := indexes[column]; !ok && matchesHeader(cell, column) {
+				indexes[column] = j
+				break
+			}
+		}
+	}
+	return indexes
+}
+
+// currentInvoiceNumbers reads the invoice numbers of sales documents numbered TG001-TG002
+func currentInvoiceNumbers(ctx context.Context, repo repository.DataImportRepository, keys []string) (map[string]erpValue, error) {
+	documentKeys := make([][2]string, 0, len(keys))
+	for _, key := range keys {
+		docType, docNo, _ := strings.Cut(key, "-")
+		documentKeys = append(documentKeys, [2]string{docType, docNo})
+	}
+
+	invoices, err := repo.GetInvoices(ctx, documentKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[string]erpValue, len(invoices))
+	for key, invoice := range invoices {
+		value := erpValue{value: invoice.InvoiceNumber}
+		switch {
+		case invoice.Confirmed == "V":
+			value.problem = "the document is voided in the ERP"
+		case !invoice.Invoiced:
+			value.problem = "the document has not been invoiced in the ERP"
+		}
+		current[key[0]+"-"+key[1]] = value
+	}
+	return current, nil
+}
@@ -0,0 +1,7 @@
+package service
+
+import "erp-excel/internal/daterange"
+
+// reportDateRange resolves the date ranges of the reports that do not limit how far back they
+// go. The 230 and 610 services have their own resolver, limited to the configured search months.
+var reportDateRange daterange.Resolver
@@ -2,11 +2,21 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"erp-excel/internal/apperror"
 	"erp-excel/internal/dto"
 	"erp-excel/internal/models"
 	"erp-excel/internal/repository"
 	"errors"
 	"fmt"
+	"log/slog"
+)
+
+var (
+	// ErrParentDepartmentNotFound is returned when a department is put under one that does not exist
+	ErrParentDepartmentNotFound = apperror.Validation("parent department not found")
+	// ErrDepartmentCycle is returned when a department would end up below itself
+	ErrDepartmentCycle = apperror.Validation("department cannot be placed below itself or a department below it")
 )
 
 // DepartmentService interface
@@ -16,22 +26,33 @@ type DepartmentService interface {
 	UpdateDepartment(ctx context.Context, id int, request dto.UpdateDepartmentRequest) (*dto.DepartmentResponse, error)
 	DeleteDepartment(ctx context.Context, id int) error
 	GetAllDepartments(ctx context.Context, limit, offset int) ([]*dto.DepartmentResponse, error)
+	GetDepartmentTree(ctx context.Context) ([]*dto.DepartmentTreeNode, error)
 	CountDepartments(ctx context.Context) (int, error)
+	GetDeletedDepartments(ctx context.Context) ([]*dto.DepartmentResponse, error)
+	RestoreDepartment(ctx context.Context, id int) error
 }
 
 type departmentService struct {
 	departmentRepo repository.DepartmentRepository
+	logger         *slog.Logger
 }
 
 // NewDepartmentService creates a new department service
-func NewDepartmentService(departmentRepo repository.DepartmentRepository) DepartmentService {
+func NewDepartmentService(departmentRepo repository.DepartmentRepository, logger *slog.Logger) DepartmentService {
 	return &departmentService{
 		departmentRepo: departmentRepo,
+		logger:         logger,
 	}
 }
 
 // CreateDepartment creates a new department
 func (s *departmentService) CreateDepartment(ctx context.Context, request dto.CreateDepartmentRequest) (*dto.DepartmentResponse, error) {
+	if request.ParentDepartmentID != nil {
+		if err := s.checkParent(ctx, 0, *request.ParentDepartmentID); err != nil {
+			return nil, err
+		}
+	}
+
 	// Create department model
 	isActive := true
 	if request.IsActive != nil {
@@ -39,10 +60,11 @@ func (s *departmentService) CreateDepartment(ctx context.Context, request dto.Cr
 	}
 
 	department := &models.Department{
-		Name:        request.Name,
-		Code:        request.Code,
-		Description: request.Description,
-		IsActive:    isActive,
+		Name:               request.Name,
+		Code:               request.Code,
+		Description:        request.Description,
+		ParentDepartmentID: request.ParentDepartmentID,
+		IsActive:           isActive,
 	}
 
 	// Save to database
@@ -53,11 +75,12 @@ func (s *departmentService) CreateDepartment(ctx context.Context, request dto.Cr
 
 	// Return response
 	return &dto.DepartmentResponse{
-		ID:          createdDepartment.ID,
-		Name:        createdDepartment.Name,
-		Code:        createdDepartment.Code,
-		Description: createdDepartment.Description,
-		IsActive:    createdDepartment.IsActive,
+		ID:                 createdDepartment.ID,
+		Name:               createdDepartment.Name,
+		Code:               createdDepartment.Code,
+		Description:        createdDepartment.Description,
+		ParentDepartmentID: createdDepartment.ParentDepartmentID,
+		IsActive:           createdDepartment.IsActive,
 	}, nil
 }
 
@@ -68,22 +91,7 @@ func (s *departmentService) GetDepartmentByID(ctx context.Context, id int) (*dto
 		return nil, fmt.Errorf("error getting department: %w", err)
 	}
 
-	// Get user count
-	userCount, err := s.departmentRepo.GetUserCount(ctx, department.ID)
-	if err != nil {
-		// Log the error but continue
-		fmt.Printf("Error getting user count: %v\n", err)
-		userCount = 0
-	}
-
-	return &dto.DepartmentResponse{
-		ID:          department.ID,
-		Name:        department.Name,
-		Code:        department.Code,
-		Description: department.Description,
-		IsActive:    department.IsActive,
-		UserCount:   userCount,
-	}, nil
+	return s.response(ctx, department), nil
 }
 
 // UpdateDepartment updates a department
@@ -107,31 +115,26 @@ func (s *departmentService) UpdateDepartment(ctx context.Context, id int, reques
 		department.IsActive = *request.IsActive
 	}
 
+	if request.ParentDepartmentID != nil {
+		if *request.ParentDepartmentID == 0 {
+			department.ParentDepartmentID = nil
+		} else {
+			if err := s.checkParent(ctx, department.ID, *request.ParentDepartmentID); err != nil {
+				return nil, err
+			}
+			department.ParentDepartmentID = request.ParentDepartmentID
+		}
+	}
+
 	// Save to database
 	if err := s.departmentRepo.Update(ctx, department); err != nil {
 		return nil, fmt.Errorf("error updating department: %w", err)
 	}
 
-	// Get user count
-	userCount, err := s.departmentRepo.GetUserCount(ctx, department.ID)
-	if err != nil {
-		// Log the error but continue
-		fmt.Printf("Error getting user count: %v\n", err)
-		userCount = 0
-	}
-
-	// Return response
-	return &dto.DepartmentResponse{
-		ID:          department.ID,
-		Name:        department.Name,
-		Code:        department.Code,
-		Description: department.Description,
-		IsActive:    department.IsActive,
-		UserCount:   userCount,
-	}, nil
+	return s.response(ctx, department), nil
 }
 
-// DeleteDepartment deletes a department
+// DeleteDepartment moves a department to the trash
 func (s *departmentService) DeleteDepartment(ctx context.Context, id int) error {
 	// Check if department has users
 	userCount, err := s.departmentRepo.GetUserCount(ctx, id)
@@ -140,7 +143,17 @@ func (s *departmentService) DeleteDepartment(ctx context.Context, id int) error
 	}
 
 	if userCount > 0 {
-		return errors.New("cannot delete department with assigned users")
+		return apperror.Conflict("cannot delete department with assigned users")
+	}
+
+	// Deleting a parent would cut its subtree off the users above it
+	childCount, err := s.departmentRepo.CountChildren(ctx, id)
+	if err != nil {
+		return fmt.Errorf("error checking child departments: %w", err)
+	}
+
+	if childCount > 0 {
+		return apperror.Conflict("cannot delete department with child departments")
 	}
 
 	// Delete department
@@ -161,28 +174,150 @@ func (s *departmentService) GetAllDepartments(ctx context.Context, limit, offset
 	// Convert to response DTOs
 	response := make([]*dto.DepartmentResponse, 0, len(departments))
 	for _, department := range departments {
-		// Get user count
-		userCount, err := s.departmentRepo.GetUserCount(ctx, department.ID)
-		if err != nil {
-			// Log the error but continue
-			fmt.Printf("Error getting user count: %v\n", err)
-			userCount = 0
+		response = append(response, s.response(ctx, department))
+	}
+
+	return response, nil
+}
+
+// GetDepartmentTree gets every department arranged under its parent, roots and children ordered
+// by name. A department whose parent is deleted is shown as a root.
+func (s *departmentService) GetDepartmentTree(ctx context.Context) ([]*dto.DepartmentTreeNode, error) {
+	departments, err := s.departmentRepo.ListAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing departments: %w", err)
+	}
+
+	userCounts, err := s.departmentRepo.GetUserCounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error counting department users: %w", err)
+	}
+
+	nodes := make(map[int]*dto.DepartmentTreeNode, len(departments))
+	for _, department := range departments {
+		nodes[department.ID] = &dto.DepartmentTreeNode{DepartmentResponse: dto.DepartmentResponse{
+			ID:                 department.ID,
+			Name:               department.Name,
+			Code:               department.Code,
+			Description:        department.Description,
+			ParentDepartmentID: department.ParentDepartmentID,
+			IsActive:           department.IsActive,
+			UserCount:          userCounts[department.ID],
+		}}
+	}
+
+	roots := make([]*dto.DepartmentTreeNode, 0)
+	for _, department := range departments {
+		node := nodes[department.ID]
+		if department.ParentDepartmentID != nil {
+			if parent, ok := nodes[*department.ParentDepartmentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
 		}
+		roots = append(roots, node)
+	}
+
+	for _, root := range roots {
+		sumTreeUsers(root)
+	}
+
+	return roots, nil
+}
 
+// sumTreeUsers sets the total user count of a node and everything below it
+func sumTreeUsers(node *dto.DepartmentTreeNode) int {
+	total := node.UserCount
+	for _, child := range node.Children {
+		total += sumTreeUsers(child)
+	}
+	node.TotalUserCount = total
+	return total
+}
+
+// GetDeletedDepartments gets the departments in the trash
+func (s *departmentService) GetDeletedDepartments(ctx context.Context) ([]*dto.DepartmentResponse, error) {
+	departments, err := s.departmentRepo.ListDeleted(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing deleted departments: %w", err)
+	}
+
+	response := make([]*dto.DepartmentResponse, 0, len(departments))
+	for _, department := range departments {
 		response = append(response, &dto.DepartmentResponse{
 			ID:          department.ID,
 			Name:        department.Name,
 			Code:        department.Code,
 			Description: department.Description,
 			IsActive:    department.IsActive,
-			UserCount:   userCount,
+			DeletedAt:   department.DeletedAt,
 		})
 	}
 
 	return response, nil
 }
 
+// RestoreDepartment takes a department out of the trash
+func (s *departmentService) RestoreDepartment(ctx context.Context, id int) error {
+	return restoreError(s.departmentRepo.Restore(ctx, id))
+}
+
 // CountDepartments gets the total number of departments
 func (s *departmentService) CountDepartments(ctx context.Context) (int, error) {
 	return s.departmentRepo.Count(ctx)
 }
+
+// response converts a department, counting its users directly and across its subtree. Count
+// errors are logged and leave the count at zero.
+func (s *departmentService) response(ctx context.Context, department *models.Department) *dto.DepartmentResponse {
+	userCount, err := s.departmentRepo.GetUserCount(ctx, department.ID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error getting user count", "department_id", department.ID, "error", err)
+	}
+
+	totalUserCount, err := s.departmentRepo.GetTotalUserCount(ctx, department.ID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error getting total user count", "department_id", department.ID, "error", err)
+	}
+
+	return &dto.DepartmentResponse{
+		ID:                 department.ID,
+		Name:               department.Name,
+		Code:               department.Code,
+		Description:        department.Description,
+		ParentDepartmentID: department.ParentDepartmentID,
+		IsActive:           department.IsActive,
+		UserCount:          userCount,
+		TotalUserCount:     totalUserCount,
+	}
+}
+
+// checkParent verifies that departmentID may be placed under parentID: the parent must exist and
+// must not be the department itself or below it. departmentID is 0 for a new department.
+func (s *departmentService) checkParent(ctx context.Context, departmentID, parentID int) error {
+	if parentID == departmentID {
+		return ErrDepartmentCycle
+	}
+
+	if _, err := s.departmentRepo.GetByID(ctx, parentID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrParentDepartmentNotFound
+		}
+		return fmt.Errorf("error getting parent department: %w", err)
+	}
+
+	if departmentID == 0 {
+		return nil
+	}
+
+	ancestors, err := s.departmentRepo.GetAncestorIDs(ctx, parentID)
+	if err != nil {
+		return err
+	}
+	for _, id := range ancestors {
+		if id == departmentID {
+			return ErrDepartmentCycle
+		}
+	}
+	return nil
+}
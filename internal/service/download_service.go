@@ -0,0 +1,383 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"erp-excel/config"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/storage"
+	"erp-excel/internal/utils"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ErrDownloadNotFound is returned for a file that is not in file storage
+var ErrDownloadNotFound = apperror.NotFound("file not found")
+
+// DownloadService manages the generated export files kept in file storage
+type DownloadService interface {
+	List(ctx context.Context) ([]*dto.DownloadFileResponse, error)
+	GetFile(ctx context.Context, fileName string) (*models.ReportFile, error)
+	ListByAccessLog(ctx context.Context, accessLogID int) ([]*dto.DownloadFileResponse, error)
+	ListForUser(ctx context.Context, userID int, report string, limit, offset int) ([]*dto.DownloadFileResponse, error)
+	CountForUser(ctx context.Context, userID int, report string) (int, error)
+	Delete(ctx context.Context, fileName string) error
+	Cleanup(ctx context.Context, request *dto.DownloadCleanupRequest) (*dto.DownloadCleanupResponse, error)
+	Start(ctx context.Context)
+}
+
+type downloadService struct {
+	config      config.DownloadsConfig
+	fileStorage storage.Storage
+	fileRepo    repository.ReportFileRepository
+	logger      *slog.Logger
+}
+
+// NewDownloadService creates a new download service
+func NewDownloadService(
+	cfg config.DownloadsConfig,
+	fileStorage storage.Storage,
+	fileRepo repository.ReportFileRepository,
+	logger *slog.Logger,
+) DownloadService {
+	if cfg.CleanupIntervalMinutes <= 0 {
+		cfg.CleanupIntervalMinutes = 60
+	}
+
+	return &downloadService{
+		config:      cfg,
+		fileStorage: fileStorage,
+		fileRepo:    fileRepo,
+		logger:      logger,
+	}
+}
+
+// List returns the stored files, newest first, with the owner and access log when recorded
+func (s *downloadService) List(ctx context.Context) ([]*dto.DownloadFileResponse, error) {
+	files, err := s.fileStorage.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.records(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	response := make([]*dto.DownloadFileResponse, 0, len(files))
+	for _, file := range files {
+		item := &dto.DownloadFileResponse{
+			FileName:   file.Name,
+			Size:       file.Size,
+			ModifiedAt: file.ModifiedAt,
+			AgeHours:   int(now.Sub(file.ModifiedAt).Hours()),
+		}
+
+		if record, ok := records[file.Name]; ok {
+			applyFileRecord(item, record)
+		}
+
+		response = append(response, item)
+	}
+
+	sort.Slice(response, func(i, j int) bool {
+		return response[i].ModifiedAt.After(response[j].ModifiedAt)
+	})
+
+	return response, nil
+}
+
+// GetFile returns the record of a stored file, or nil for files generated before exports were recorded
+func (s *downloadService) GetFile(ctx context.Context, fileName string) (*models.ReportFile, error) {
+	file, err := s.fileRepo.GetByFileName(ctx, filepath.Base(fileName))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// ListByAccessLog returns the files generated by the run of an access log entry, newest first.
+// Files that were recorded but have since been removed from file storage are flagged as missing.
+func (s *downloadService) ListByAccessLog(ctx context.Context, accessLogID int) ([]*dto.DownloadFileResponse, error) {
+	records, err := s.fileRepo.GetByAccessLogID(ctx, accessLogID)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrDownloadNotFound
+	}
+
+	now := time.Now()
+	response := make([]*dto.DownloadFileResponse, 0, len(records))
+	for _, record := range records {
+		item := &dto.DownloadFileResponse{
+			FileName:   record.FileName,
+			Size:       record.Size,
+			ModifiedAt: record.CreatedAt,
+			AgeHours:   int(now.Sub(record.CreatedAt).Hours()),
+		}
+		applyFileRecord(item, record)
+
+		exists, err := s.fileStorage.Exists(ctx, record.FileName)
+		if err != nil {
+			return nil, err
+		}
+		item.Missing = !exists
+
+		response = append(response, item)
+	}
+
+	return response, nil
+}
+
+// ListForUser returns a page of the files the user generated that have not expired, newest
+// first, each with a signed link to download it again. Files removed from file storage since are
+// flagged as missing and have no link.
+func (s *downloadService) ListForUser(ctx context.Context, userID int, report string, limit, offset int) ([]*dto.DownloadFileResponse, error) {
+	records, err := s.fileRepo.ListByUser(ctx, userID, report, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	response := make([]*dto.DownloadFileResponse, 0, len(records))
+	for _, record := range records {
+		item := &dto.DownloadFileResponse{
+			FileName:   record.FileName,
+			Size:       record.Size,
+			ModifiedAt: record.CreatedAt,
+			AgeHours:   int(now.Sub(record.CreatedAt).Hours()),
+		}
+		applyFileRecord(item, record)
+
+		exists, err := s.fileStorage.Exists(ctx, record.FileName)
+		if err != nil {
+			return nil, err
+		}
+		item.Missing = !exists
+		if exists {
+			item.DownloadURL = utils.SignDownloadLink(record.FileName, userID)
+		}
+
+		response = append(response, item)
+	}
+
+	return response, nil
+}
+
+// CountForUser returns the number of files the user generated that have not expired
+func (s *downloadService) CountForUser(ctx context.Context, userID int, report string) (int, error) {
+	return s.fileRepo.CountByUser(ctx, userID, report)
+}
+
+// Delete removes a stored file and its record
+func (s *downloadService) Delete(ctx context.Context, fileName string) error {
+	fileName = filepath.Base(fileName)
+
+	exists, err := s.fileStorage.Exists(ctx, fileName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrDownloadNotFound
+	}
+
+	return s.remove(ctx, fileName)
+}
+
+// Cleanup removes the files last modified before the cut-off
+func (s *downloadService) Cleanup(ctx context.Context, request *dto.DownloadCleanupRequest) (*dto.DownloadCleanupResponse, error) {
+	if request.OlderThanDays < 1 {
+		return nil, apperror.Validation("older_than_days must be at least 1")
+	}
+
+	files, err := s.fileStorage.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -request.OlderThanDays)
+	response := &dto.DownloadCleanupResponse{
+		DeletedFiles: []string{},
+		DryRun:       request.DryRun,
+	}
+
+	for _, file := range files {
+		if !file.ModifiedAt.Before(cutoff) {
+			continue
+		}
+
+		if !request.DryRun {
+			if err := s.remove(ctx, file.Name); err != nil {
+				return nil, fmt.Errorf("error deleting %s after removing %d files: %w", file.Name, response.DeletedCount, err)
+			}
+		}
+
+		response.DeletedFiles = append(response.DeletedFiles, file.Name)
+		response.DeletedCount++
+		response.FreedBytes += file.Size
+	}
+
+	return response, nil
+}
+
+// Start enforces the retention of generated files periodically until the context is cancelled
+func (s *downloadService) Start(ctx context.Context) {
+	if s.config.MaxAgeDays <= 0 && s.config.MaxTotalSizeMB <= 0 {
+		return
+	}
+
+	interval := time.Duration(s.config.CleanupIntervalMinutes) * time.Minute
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.enforceRetention(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	s.logger.InfoContext(ctx, "Download cleanup started",
+		"max_age_days", s.config.MaxAgeDays,
+		"max_total_size_mb", s.config.MaxTotalSizeMB,
+		"interval", interval.String(),
+	)
+}
+
+// enforceRetention deletes the expired files, then the oldest files while all files together
+// are over the size limit. Files without a record expire by their modification time.
+func (s *downloadService) enforceRetention(ctx context.Context) {
+	maxAge := time.Duration(s.config.MaxAgeDays) * 24 * time.Hour
+	if maxAge > 0 {
+		if _, err := s.fileRepo.SetMissingExpiry(ctx, maxAge); err != nil {
+			s.logger.ErrorContext(ctx, "Error setting report file expiry", "error", err)
+			return
+		}
+	}
+
+	files, err := s.fileStorage.List(ctx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error listing generated files", "error", err)
+		return
+	}
+	records, err := s.records(ctx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error reading report file records", "error", err)
+		return
+	}
+
+	// Oldest first, so the size limit removes the oldest files
+	sort.Slice(files, func(i, j int) bool { return files[i].ModifiedAt.Before(files[j].ModifiedAt) })
+
+	now := time.Now()
+	var totalSize int64
+	var kept []storage.FileInfo
+	deleted, freed := 0, int64(0)
+	for _, file := range files {
+		expired := false
+		if record, ok := records[file.Name]; ok && record.ExpiresAt != nil {
+			expired = record.ExpiresAt.Before(now)
+		} else if maxAge > 0 {
+			expired = file.ModifiedAt.Add(maxAge).Before(now)
+		}
+
+		if !expired {
+			kept = append(kept, file)
+			totalSize += file.Size
+			continue
+		}
+		if err := s.remove(ctx, file.Name); err != nil {
+			s.logger.ErrorContext(ctx, "Error deleting expired file", "file", file.Name, "error", err)
+			continue
+		}
+		deleted++
+		freed += file.Size
+	}
+
+	maxTotalSize := int64(s.config.MaxTotalSizeMB) << 20
+	for _, file := range kept {
+		if maxTotalSize <= 0 || totalSize <= maxTotalSize {
+			break
+		}
+		if err := s.remove(ctx, file.Name); err != nil {
+			s.logger.ErrorContext(ctx, "Error deleting file over the size limit", "file", file.Name, "error", err)
+			continue
+		}
+		totalSize -= file.Size
+		deleted++
+		freed += file.Size
+	}
+
+	if deleted > 0 {
+		s.logger.InfoContext(ctx, "Deleted generated files past retention", "files", deleted, "freed_bytes", freed)
+	}
+}
+
+// remove deletes a file; a leftover record is only logged since it no longer points anywhere
+func (s *downloadService) remove(ctx context.Context, fileName string) error {
+	if err := s.fileStorage.Delete(ctx, fileName); err != nil {
+		return err
+	}
+
+	if err := s.fileRepo.DeleteByFileName(ctx, fileName); err != nil {
+		s.logger.ErrorContext(ctx, "Error removing record", "file", fileName, "error", err)
+	}
+
+	return nil
+}
+
+// applyFileRecord fills the export history details of a file
+func applyFileRecord(item *dto.DownloadFileResponse, record *models.ReportFile) {
+	item.Report = record.Report
+	item.RowCount = record.RowCount
+	item.UserID = record.UserID
+	item.Username = record.Username
+	item.Checksum = record.Checksum
+	item.ExpiresAt = record.ExpiresAt
+	if json.Valid([]byte(record.Parameters)) {
+		item.Parameters = json.RawMessage(record.Parameters)
+	}
+	if record.AccessLogID > 0 {
+		item.AccessLog = &dto.DownloadAccessLog{
+			ID:         record.AccessLogID,
+			Operation:  record.OperationName,
+			Status:     record.AccessLogStatus,
+			AccessTime: record.AccessTime,
+		}
+	}
+}
+
+// records returns the recorded files by file name
+func (s *downloadService) records(ctx context.Context) (map[string]*models.ReportFile, error) {
+	files, err := s.fileRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[string]*models.ReportFile, len(files))
+	for _, file := range files {
+		records[file.FileName] = file
+	}
+
+	return records, nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/database"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/logging"
+	"erp-excel/internal/metrics"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Defaults of the slow query log
+const (
+	defaultSlowQuery        = 5 * time.Second
+	defaultSlowQueryLogSize = 200
+	maxSlowQueryText        = 4000
+)
+
+// ERPQueryMonitor records the duration and rows of every ERP query in the metrics, per company
+// and report, and keeps the slowest ones in memory for administrators, so degrading SQL shows up
+// before users complain about it
+type ERPQueryMonitor struct {
+	threshold time.Duration
+	logger    *slog.Logger
+
+	duration *metrics.Histogram
+	rows     *metrics.Histogram
+	queries  *metrics.Counter
+
+	mu   sync.Mutex
+	slow []dto.SlowQuery // ring of the latest slow queries
+	next int
+}
+
+// NewERPQueryMonitor registers the metrics of the ERP queries
+func NewERPQueryMonitor(cfg config.MetricsConfig, registry *metrics.Registry, logger *slog.Logger) *ERPQueryMonitor {
+	m := &ERPQueryMonitor{
+		threshold: time.Duration(cfg.SlowQueryMs) * time.Millisecond,
+		logger:    logger,
+		duration: registry.Histogram("erp_query_duration_seconds",
+			"Time ERP queries took until their last row was read", metrics.DurationBuckets, "company", "report"),
+		rows: registry.Histogram("erp_query_rows",
+			"Rows read by ERP queries", metrics.ExponentialBuckets(1, 10, 7), "company", "report"),
+		queries: registry.Counter("erp_queries_total",
+			"ERP queries run, by status: ok, error or cancelled", "company", "report", "status"),
+	}
+	if m.threshold <= 0 {
+		m.threshold = defaultSlowQuery
+	}
+	size := cfg.SlowQueryLogSize
+	if size <= 0 {
+		size = defaultSlowQueryLogSize
+	}
+	m.slow = make([]dto.SlowQuery, 0, size)
+
+	return m
+}
+
+// ObserveQuery records a query run on an ERP database
+func (m *ERPQueryMonitor) ObserveQuery(ctx context.Context, stats database.QueryStats) {
+	status := "ok"
+	switch {
+	case errors.Is(stats.Err, context.Canceled) || errors.Is(stats.Err, context.DeadlineExceeded) ||
+		(stats.Err != nil && ctx.Err() != nil):
+		status = "cancelled"
+	case stats.Err != nil:
+		status = "error"
+	}
+	m.duration.Observe(stats.Duration.Seconds(), stats.Company, stats.Label)
+	m.rows.Observe(float64(stats.Rows), stats.Company, stats.Label)
+	m.queries.Inc(stats.Company, stats.Label, status)
+
+	if stats.Duration < m.threshold {
+		return
+	}
+
+	query := stats.Query
+	if len(query) > maxSlowQueryText {
+		query = query[:maxSlowQueryText] + "…"
+	}
+	slow := dto.SlowQuery{
+		Company:    stats.Company,
+		Report:     stats.Label,
+		Query:      query,
+		Parameters: stats.Args,
+		StartedAt:  stats.Started,
+		DurationMs: stats.Duration.Milliseconds(),
+		Rows:       stats.Rows,
+		RequestID:  logging.RequestID(ctx),
+	}
+	if stats.Err != nil {
+		slow.Error = stats.Err.Error()
+	}
+	m.logger.WarnContext(ctx, "Slow ERP query", "company", stats.Company, "report", stats.Label,
+		"duration_ms", slow.DurationMs, "rows", stats.Rows, "parameters", stats.Args, "query", query)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.slow) < cap(m.slow) {
+		m.slow = append(m.slow, slow)
+		return
+	}
+	m.slow[m.next] = slow
+	m.next = (m.next + 1) % len(m.slow)
+}
+
+// SlowQueries returns up to limit of the latest slow queries, newest first; all kept when limit is
+// not positive
+func (m *ERPQueryMonitor) SlowQueries(limit int) []dto.SlowQuery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if limit <= 0 || limit > len(m.slow) {
+		limit = len(m.slow)
+	}
+	queries := make([]dto.SlowQuery, 0, limit)
+	// The newest entry sits just before next once the ring is full, at the end until then
+	newest := len(m.slow) - 1
+	if len(m.slow) == cap(m.slow) {
+		newest = (m.next - 1 + len(m.slow)) % len(m.slow)
+	}
+	for i := 0; i < limit; i++ {
+		queries = append(queries, m.slow[(newest-i+len(m.slow))%len(m.slow)])
+	}
+
+	return queries
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/cache"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ERPSyncService keeps the local ERP cache tables up to date
+type ERPSyncService interface {
+	Start(ctx context.Context)
+	RunSync(ctx context.Context) ([]*models.ERPSyncState, error)
+	GetStatus(ctx context.Context) ([]*models.ERPSyncState, error)
+}
+
+// Locks of the sync in the store, which the instances share when Redis is configured
+const (
+	erpSyncLock         = "lock:erp_sync"
+	erpSyncScheduleLock = "lock:erp_sync:schedule"
+
+	// erpSyncLockTTL frees the lock of an instance that stopped mid-sync
+	erpSyncLockTTL = 30 * time.Minute
+)
+
+type erpSyncService struct {
+	config    *config.Config
+	cacheRepo repository.ERPCacheRepository
+	store     cache.Store
+
+	mu     sync.Mutex // only one sync runs at a time
+	logger *slog.Logger
+}
+
+// NewERPSyncService creates a new ERP sync service
+func NewERPSyncService(config *config.Config, cacheRepo repository.ERPCacheRepository, store cache.Store, logger *slog.Logger) ERPSyncService {
+	return &erpSyncService{
+		config:    config,
+		cacheRepo: cacheRepo,
+		store:     store,
+		logger:    logger,
+	}
+}
+
+// Start runs the sync periodically until the context is cancelled
+func (s *erpSyncService) Start(ctx context.Context) {
+	if !s.config.ERPSync.Enabled {
+		return
+	}
+
+	interval := time.Duration(s.config.ERPSync.IntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = 15 * time.Minute
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.runScheduled(ctx, interval)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	s.logger.InfoContext(ctx, "ERP cache sync started", "interval", interval.String())
+}
+
+// runScheduled runs the periodic sync. With several instances only the one taking the schedule
+// lock syncs; the lock is left to expire just before the next interval, so the others skip this one.
+func (s *erpSyncService) runScheduled(ctx context.Context, interval time.Duration) {
+	if _, ok, err := s.store.TryLock(ctx, erpSyncScheduleLock, interval-interval/10); err != nil || !ok {
+		return
+	}
+
+	if _, err := s.RunSync(ctx); err != nil {
+		s.logger.ErrorContext(ctx, "Error syncing ERP cache", "error", err)
+	}
+}
+
+// RunSync copies the changed ERP window of every cache group into the local tables
+func (s *erpSyncService) RunSync(ctx context.Context) ([]*models.ERPSyncState, error) {
+	if !s.mu.TryLock() {
+		return nil, apperror.Conflict("ERP sync is already running")
+	}
+	defer s.mu.Unlock()
+
+	release, ok, err := s.store.TryLock(ctx, erpSyncLock, erpSyncLockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, apperror.Conflict("ERP sync is already running on another instance")
+	}
+	defer release()
+
+	groups := []struct {
+		name string
+		sync func(ctx context.Context, fromDate, toDate time.Time) (int, error)
+	}{
+		{repository.SyncGroupSalesDeliveries, s.cacheRepo.SyncSalesDeliveries},
+		{repository.SyncGroupReceivables, s.cacheRepo.SyncReceivables},
+	}
+
+	var states []*models.ERPSyncState
+	var errs []error
+	for _, group := range groups {
+		state, err := s.syncGroup(ctx, group.name, group.sync)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", group.name, err))
+		}
+		if state != nil {
+			states = append(states, state)
+		}
+	}
+
+	if len(errs) > 0 {
+		return states, errors.Join(errs...)
+	}
+
+	return states, nil
+}
+
+// GetStatus returns the sync state of every cache group
+func (s *erpSyncService) GetStatus(ctx context.Context) ([]*models.ERPSyncState, error) {
+	return s.cacheRepo.GetSyncStates(ctx)
+}
+
+// syncGroup syncs one cache group and records the outcome
+func (s *erpSyncService) syncGroup(
+	ctx context.Context,
+	name string,
+	syncFn func(ctx context.Context, fromDate, toDate time.Time) (int, error),
+) (*models.ERPSyncState, error) {
+	previous, err := s.cacheRepo.GetSyncState(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	fromDate, toDate := s.syncWindow(previous)
+	s.logger.InfoContext(ctx, "Syncing ERP cache group", "group", name, "from", fromDate.Format("2006-01-02"), "to", toDate.Format("2006-01-02"))
+
+	state := &models.ERPSyncState{
+		TableName: name,
+		LastRunAt: time.Now(),
+	}
+	if previous != nil {
+		state.SyncedFrom = previous.SyncedFrom
+		state.SyncedTo = previous.SyncedTo
+	}
+
+	count, syncErr := syncFn(ctx, fromDate, toDate)
+	if syncErr != nil {
+		state.Status = "error"
+		state.Error = syncErr.Error()
+	} else {
+		state.Status = "success"
+		state.RowCount = count
+		state.SyncedTo = toDate
+		if state.SyncedFrom.IsZero() || fromDate.Before(state.SyncedFrom) {
+			state.SyncedFrom = fromDate
+		}
+	}
+
+	if err := s.cacheRepo.SaveSyncState(ctx, state); err != nil {
+		s.logger.ErrorContext(ctx, "Error saving sync state", "name", name, "error", err)
+	}
+
+	return state, syncErr
+}
+
+// syncWindow picks the date range to refresh. The first run loads everything reports can
+// ask for; later runs only re-read the last few days, since ERP documents are mostly
+// changed shortly after they are entered.
+func (s *erpSyncService) syncWindow(previous *models.ERPSyncState) (time.Time, time.Time) {
+	now := time.Now()
+	toDate := now.Truncate(24 * time.Hour).Add(24*time.Hour - time.Nanosecond)
+	oldest := now.Truncate(24*time.Hour).AddDate(0, -s.config.Excel.MaxSearchMonths, 0)
+
+	if previous == nil || previous.SyncedTo.IsZero() {
+		return oldest, toDate
+	}
+
+	overlap := s.config.ERPSync.OverlapDays
+	if overlap <= 0 {
+		overlap = 7
+	}
+
+	fromDate := previous.SyncedTo.Truncate(24*time.Hour).AddDate(0, 0, -overlap)
+	if fromDate.Before(oldest) {
+		fromDate = oldest
+	}
+
+	return fromDate, toDate
+}
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"erp-excel/config"
+	"erp-excel/database"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/metrics"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of the ERP query queues
+const (
+	defaultThrottleMaxWaiting = 20
+	defaultThrottleWait       = time.Minute
+	minThrottleRetryAfter     = 5 * time.Second
+	maxThrottleRetryAfter     = 5 * time.Minute
+)
+
+// ERPThrottle limits the report queries running at once on each company ERP database, so
+// simultaneous large exports cannot saturate the ERP server. Queries over the limit wait in line
+// for their turn, first come first served; when the line is full or the wait too long they fail
+// with a 503 telling the client their position and when to try again. A nil ERPThrottle does not
+// limit.
+type ERPThrottle struct {
+	maxRunning     int
+	companies      map[string]int
+	maxWaiting     int
+	wait           time.Duration
+	defaultCompany string
+	logger         *slog.Logger
+
+	mu     sync.Mutex
+	queues map[string]*erpQueue
+}
+
+// erpQueue is the line of the queries of one ERP database
+type erpQueue struct {
+	company    string
+	maxRunning int
+	running    int
+	waiting    *list.List // of chan struct{}, closed when the waiter is given a turn
+
+	admitted    int64
+	queued      int64
+	rejected    int64
+	peakWaiting int
+	maxWait     time.Duration
+	avgQuery    time.Duration
+}
+
+// NewERPThrottle creates the queues of the ERP databases from the throttle of the reports
+func NewERPThrottle(cfg config.ReportThrottleConfig, defaultCompany string, logger *slog.Logger) *ERPThrottle {
+	t := &ERPThrottle{
+		maxRunning:     cfg.MaxRunning,
+		companies:      make(map[string]int, len(cfg.Companies)),
+		maxWaiting:     cfg.MaxWaiting,
+		wait:           time.Duration(cfg.WaitSeconds) * time.Second,
+		defaultCompany: defaultCompany,
+		logger:         logger,
+		queues:         make(map[string]*erpQueue),
+	}
+	if t.maxWaiting <= 0 {
+		t.maxWaiting = defaultThrottleMaxWaiting
+	}
+	if t.wait <= 0 {
+		t.wait = defaultThrottleWait
+	}
+
+	// Viper lower-cases map keys, so company codes are matched case-insensitively
+	for company, maxRunning := range cfg.Companies {
+		t.companies[strings.ToLower(company)] = maxRunning
+	}
+
+	return t
+}
+
+// Acquire waits for the turn of a query of the report on the ERP database of the company carried
+// by ctx and returns the function ending it, which must be called once the query is done
+func (t *ERPThrottle) Acquire(ctx context.Context, report string) (func(), error) {
+	if t == nil {
+		return func() {}, nil
+	}
+
+	q := t.queue(database.CompanyFromContext(ctx))
+	if q.maxRunning <= 0 {
+		return func() {}, nil
+	}
+
+	t.mu.Lock()
+	if q.running < q.maxRunning && q.waiting.Len() == 0 {
+		q.running++
+		q.admitted++
+		t.mu.Unlock()
+		return t.releaser(q), nil
+	}
+	if q.waiting.Len() >= t.maxWaiting {
+		q.rejected++
+		err := t.busyError(q, q.waiting.Len()+1)
+		t.mu.Unlock()
+		t.logger.WarnContext(ctx, "ERP query queue full", "company", q.company, "report", report, "waiting", t.maxWaiting)
+		return nil, err
+	}
+
+	turn := make(chan struct{})
+	element := q.waiting.PushBack(turn)
+	q.queued++
+	position := q.waiting.Len()
+	if position > q.peakWaiting {
+		q.peakWaiting = position
+	}
+	t.mu.Unlock()
+
+	t.logger.InfoContext(ctx, "Waiting for a turn to query the ERP", "company", q.company, "report", report, "position", position)
+	started := time.Now()
+	timer := time.NewTimer(t.wait)
+	defer timer.Stop()
+
+	var cause error
+	select {
+	case <-turn:
+		t.waited(q, time.Since(started))
+		return t.releaser(q), nil
+	case <-ctx.Done():
+		cause = fmt.Errorf("%w: %v while waiting for the ERP", ErrQueryCancelled, ctx.Err())
+	case <-timer.C:
+	}
+
+	t.mu.Lock()
+	select {
+	case <-turn:
+		// The turn was given between the wait ending and the lock: a cancelled query passes it on
+		t.mu.Unlock()
+		t.waited(q, time.Since(started))
+		release := t.releaser(q)
+		if cause != nil {
+			release()
+			return nil, cause
+		}
+		return release, nil
+	default:
+	}
+	position = t.position(q, element)
+	q.waiting.Remove(element)
+	if cause == nil {
+		q.rejected++
+	}
+	err := t.busyError(q, position)
+	t.mu.Unlock()
+	t.waited(q, time.Since(started))
+
+	if cause != nil {
+		return nil, cause
+	}
+	t.logger.WarnContext(ctx, "Gave up waiting for a turn to query the ERP", "company", q.company, "report", report, "position", position, "waited", t.wait)
+	return nil, err
+}
+
+// Status returns the queues of the ERP databases that ran a query, by company code
+func (t *ERPThrottle) Status() []dto.ERPQueueStatus {
+	if t == nil {
+		return []dto.ERPQueueStatus{}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	statuses := make([]dto.ERPQueueStatus, 0, len(t.queues))
+	for _, q := range t.queues {
+		statuses = append(statuses, dto.ERPQueueStatus{
+			Company:     q.company,
+			MaxRunning:  q.maxRunning,
+			MaxWaiting:  t.maxWaiting,
+			Running:     q.running,
+			Waiting:     q.waiting.Len(),
+			Admitted:    q.admitted,
+			Queued:      q.queued,
+			Rejected:    q.rejected,
+			PeakWaiting: q.peakWaiting,
+			MaxWaitMs:   q.maxWait.Milliseconds(),
+			AvgQueryMs:  q.avgQuery.Milliseconds(),
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Company < statuses[j].Company })
+
+	return statuses
+}
+
+// RegisterMetrics exposes the running and waiting queries of the ERP databases as gauges
+func (t *ERPThrottle) RegisterMetrics(registry *metrics.Registry) {
+	gauge := func(read func(dto.ERPQueueStatus) int) func() []metrics.GaugeValue {
+		return func() []metrics.GaugeValue {
+			statuses := t.Status()
+			values := make([]metrics.GaugeValue, len(statuses))
+			for i, status := range statuses {
+				values[i] = metrics.GaugeValue{Labels: []string{status.Company}, Value: float64(read(status))}
+			}
+			return values
+		}
+	}
+	registry.GaugeFunc("erp_queue_running", "Report queries running on the ERP database", []string{"company"},
+		gauge(func(status dto.ERPQueueStatus) int { return status.Running }))
+	registry.GaugeFunc("erp_queue_waiting", "Report queries waiting for a turn on the ERP database", []string{"company"},
+		gauge(func(status dto.ERPQueueStatus) int { return status.Waiting }))
+}
+
+// queue returns the queue of a company, the default company when empty
+func (t *ERPThrottle) queue(company string) *erpQueue {
+	if company == "" {
+		company = t.defaultCompany
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	q, ok := t.queues[company]
+	if !ok {
+		q = &erpQueue{company: company, maxRunning: t.maxRunning, waiting: list.New()}
+		if maxRunning, ok := t.companies[company]; ok {
+			q.maxRunning = maxRunning
+		}
+		t.queues[company] = q
+	}
+	return q
+}
+
+// releaser returns the function ending a turn, which gives it to the first query in line
+func (t *ERPThrottle) releaser(q *erpQueue) func() {
+	started := time.Now()
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			held := time.Since(started)
+
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			// A moving average of the last few queries estimates when a turn comes up
+			if q.avgQuery == 0 {
+				q.avgQuery = held
+			} else {
+				q.avgQuery += (held - q.avgQuery) / 8
+			}
+
+			if front := q.waiting.Front(); front != nil {
+				q.waiting.Remove(front)
+				q.admitted++
+				close(front.Value.(chan struct{}))
+				return
+			}
+			q.running--
+		})
+	}
+}
+
+// waited records how long a query waited for its turn
+func (t *ERPThrottle) waited(q *erpQueue, wait time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if wait > q.maxWait {
+		q.maxWait = wait
+	}
+}
+
+// position returns the 1-based position of a waiting query. The caller holds the lock.
+func (t *ERPThrottle) position(q *erpQueue, element *list.Element) int {
+	position := 1
+	for e := q.waiting.Front(); e != nil && e != element; e = e.Next() {
+		position++
+	}
+	return position
+}
+
+// busyError returns the error of a query refused at the given position, retried after the time
+// the queries ahead of it are expected to take. The caller holds the lock.
+func (t *ERPThrottle) busyError(q *erpQueue, position int) error {
+	retryAfter := q.avgQuery * time.Duration(position) / time.Duration(q.maxRunning)
+	retryAfter = min(max(retryAfter, minThrottleRetryAfter), maxThrottleRetryAfter)
+
+	return apperror.New(apperror.CodeERPBusy, http.StatusServiceUnavailable, "ERP busy",
+		fmt.Sprintf("the ERP database is busy: %d queries running and %d waiting", q.running, q.waiting.Len())).
+		WithDetail(fmt.Sprintf("The ERP is busy with other reports; you were number %d in line. Try again in %d seconds.",
+			position, int((retryAfter+time.Second-1)/time.Second))).
+		WithRetryAfter(retryAfter)
+}
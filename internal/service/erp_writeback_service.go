@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"erp-excel/config"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// ERPWriteBackService marks documents confirmed by accountants as processed in the ERP
+type ERPWriteBackService interface {
+	WriteBack(ctx context.Context, userID int, request *dto.ERPWriteBackRequest, ipAddress string) (*dto.ERPWriteBackResponse, error)
+	GetLogs(ctx context.Context, limit int) ([]*models.ERPWriteBackLog, error)
+}
+
+type erpWriteBackService struct {
+	config           config.ERPWriteBackConfig
+	writeBackRepo    repository.ERPWriteBackRepository
+	operationService OperationService
+	logger           *slog.Logger
+}
+
+// erpColumnPattern limits configured column names to plain identifiers, they are put into the SQL text
+var erpColumnPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,29}$`)
+
+// NewERPWriteBackService creates a new ERP write-back service
+func NewERPWriteBackService(
+	cfg config.ERPWriteBackConfig,
+	writeBackRepo repository.ERPWriteBackRepository,
+	operationService OperationService,
+	logger *slog.Logger,
+) (ERPWriteBackService, error) {
+	if cfg.Enabled {
+		if !erpColumnPattern.MatchString(cfg.FlagColumn) || !erpColumnPattern.MatchString(cfg.NoteColumn) {
+			return nil, errors.New("erp write-back flag and note columns must be plain column names")
+		}
+		if strings.EqualFold(cfg.FlagColumn, cfg.NoteColumn) {
+			return nil, errors.New("erp write-back flag and note columns must differ")
+		}
+	}
+	if cfg.FlagValue == "" {
+		cfg.FlagValue = "Y"
+	}
+	if cfg.MaxDocuments <= 0 {
+		cfg.MaxDocuments = 200
+	}
+
+	return &erpWriteBackService{
+		config:           cfg,
+		writeBackRepo:    writeBackRepo,
+		operationService: operationService,
+		logger:           logger,
+	}, nil
+}
+
+// WriteBack previews or applies the processed flag on the requested documents.
+// Only documents confirmed in the ERP (TG023 = 'Y') are touched, and every outcome is audited.
+func (s *erpWriteBackService) WriteBack(
+	ctx context.Context,
+	userID int,
+	request *dto.ERPWriteBackRequest,
+	ipAddress string,
+) (*dto.ERPWriteBackResponse, error) {
+	if !s.config.Enabled {
+		return nil, errors.New("erp write-back is disabled")
+	}
+
+	keys := uniqueDocumentKeys(request.Documents)
+	if len(keys) > s.config.MaxDocuments {
+		return nil, fmt.Errorf("at most %d documents can be written back at once", s.config.MaxDocuments)
+	}
+
+	dryRun := request.DryRun || s.config.DryRun
+
+	logID, err := s.operationService.LogAccess(ctx, userID, s.config.OperationCode, request, ipAddress)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error logging write-back access", "error", err)
+	}
+
+	documents, err := s.writeBackRepo.GetDocuments(ctx, s.config.FlagColumn, s.config.NoteColumn, keys)
+	if err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+
+	batchID, err := newBatchID()
+	if err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+
+	response := &dto.ERPWriteBackResponse{
+		BatchID: batchID,
+		DryRun:  dryRun,
+		Items:   make([]dto.ERPWriteBackItem, 0, len(keys)),
+	}
+
+	var eligible [][2]string
+	for _, key := range keys {
+		item := dto.ERPWriteBackItem{DocType: key[0], DocNo: key[1]}
+
+		document, ok := documents[key]
+		switch {
+		case !ok:
+			item.Status = "skipped"
+			item.Reason = "document not found in ERP"
+		case document.Confirmed != "Y":
+			item.Status = "skipped"
+			item.Reason = "document is not confirmed in ERP"
+			item.PreviousFlag, item.PreviousNote = document.Flag, document.Note
+		case document.Flag == s.config.FlagValue && document.Note == request.Note:
+			item.Status = "skipped"
+			item.Reason = "document is already marked as processed"
+			item.PreviousFlag, item.PreviousNote = document.Flag, document.Note
+		default:
+			item.PreviousFlag, item.PreviousNote = document.Flag, document.Note
+			item.NewFlag, item.NewNote = s.config.FlagValue, request.Note
+			item.Status = "previewed"
+			if !dryRun {
+				item.Status = "pending"
+			}
+			eligible = append(eligible, key)
+		}
+
+		response.Items = append(response.Items, item)
+	}
+
+	// The audit rows are written before the ERP is touched, the changes about to be made as
+	// pending, so the ERP is never updated without a trace in the audit trail
+	if err := s.writeBackRepo.AddLogs(ctx, s.auditLogs(batchID, userID, dryRun, response.Items)); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, fmt.Errorf("error writing audit trail: %w", err)
+	}
+
+	if !dryRun && len(eligible) > 0 {
+		var writeErr error
+		response.Updated, writeErr = s.writeBackRepo.UpdateDocuments(
+			ctx,
+			s.config.FlagColumn,
+			s.config.NoteColumn,
+			eligible,
+			s.config.FlagValue,
+			request.Note,
+		)
+
+		status, reason := "updated", ""
+		if writeErr != nil {
+			status, reason = "error", writeErr.Error()
+		}
+		for i := range response.Items {
+			if response.Items[i].Status == "pending" {
+				response.Items[i].Status, response.Items[i].Reason = status, reason
+			}
+		}
+
+		// The ERP transaction is over whether or not the request is still waiting, so the
+		// pending rows are settled even when it was cancelled
+		if err := s.writeBackRepo.SettleLogs(context.WithoutCancel(ctx), batchID, status, reason); err != nil {
+			s.logger.ErrorContext(ctx, "Error completing audit trail", "batch_id", batchID, "status", status, "error", err)
+			s.updateLogStatus(ctx, logID, "error")
+			return nil, fmt.Errorf("error completing audit trail of batch %s, its rows stay pending: %w", batchID, err)
+		}
+		if writeErr != nil {
+			s.updateLogStatus(ctx, logID, "error")
+			return nil, writeErr
+		}
+	}
+
+	for _, item := range response.Items {
+		if item.Status == "skipped" {
+			response.Skipped++
+		}
+	}
+
+	s.logger.InfoContext(ctx, "ERP write-back batch finished",
+		"batch_id", batchID, "user_id", userID, "dry_run", dryRun, "updated", response.Updated, "skipped", response.Skipped)
+	s.updateLogStatus(ctx, logID, "success")
+
+	return response, nil
+}
+
+// GetLogs returns the most recent write-back audit records
+func (s *erpWriteBackService) GetLogs(ctx context.Context, limit int) ([]*models.ERPWriteBackLog, error) {
+	if limit <= 0 {
+		limit = 50
+	} else if limit > 500 {
+		limit = 500
+	}
+
+	return s.writeBackRepo.ListLogs(ctx, limit)
+}
+
+// auditLogs returns the audit rows of a batch, one per written column of each document
+func (s *erpWriteBackService) auditLogs(batchID string, userID int, dryRun bool, items []dto.ERPWriteBackItem) []*models.ERPWriteBackLog {
+	logs := make([]*models.ERPWriteBackLog, 0, len(items)*2)
+	for _, item := range items {
+		for _, change := range []struct{ column, oldValue, newValue string }{
+			{s.config.FlagColumn, item.PreviousFlag, item.NewFlag},
+			{s.config.NoteColumn, item.PreviousNote, item.NewNote},
+		} {
+			logs = append(logs, &models.ERPWriteBackLog{
+				BatchID:  batchID,
+				UserID:   userID,
+				DocType:  item.DocType,
+				DocNo:    item.DocNo,
+				Column:   change.column,
+				OldValue: change.oldValue,
+				NewValue: change.newValue,
+				DryRun:   dryRun,
+				Status:   item.Status,
+				Error:    item.Reason,
+			})
+		}
+	}
+	return logs
+}
+
+func (s *erpWriteBackService) updateLogStatus(ctx context.Context, logID int, status string) {
+	if logID <= 0 {
+		return
+	}
+
+	ctx, status = logStatusContext(ctx, status)
+	if _, err := s.operationService.UpdateLogStatus(ctx, logID, status); err != nil {
+		s.logger.ErrorContext(ctx, "Error updating write-back log status", "error", err)
+	}
+}
+
+// uniqueDocumentKeys trims and de-duplicates the requested documents, keeping their order
+func uniqueDocumentKeys(documents []dto.ERPDocumentKey) [][2]string {
+	seen := make(map[[2]string]bool, len(documents))
+	keys := make([][2]string, 0, len(documents))
+	for _, document := range documents {
+		key := [2]string{strings.TrimSpace(document.DocType), strings.TrimSpace(document.DocNo)}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// newBatchID returns a random UUID (version 4) identifying one write-back request
+func newBatchID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generating batch id: %w", err)
+	}
+	buf[6] = (buf[6] & 0x0f) | 0x40
+	buf[8] = (buf[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:16]), nil
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"erp-excel/config"
+	"erp-excel/internal/events"
+	"erp-excel/internal/repository"
+	"log/slog"
+	"time"
+)
+
+// EventService records domain events in the outbox and dispatches them to the message queue, and
+// hands them to the webhooks subscribed to them
+type EventService interface {
+	Emit(ctx context.Context, eventType string, data interface{})
+	Start(ctx context.Context)
+}
+
+type eventService struct {
+	config     config.EventsConfig
+	outboxRepo repository.EventOutboxRepository
+	publisher  events.Publisher
+	webhooks   WebhookService
+	logger     *slog.Logger
+}
+
+// NewEventService creates a new event service
+func NewEventService(cfg config.EventsConfig, outboxRepo repository.EventOutboxRepository, webhooks WebhookService, logger *slog.Logger) (EventService, error) {
+	service := &eventService{
+		config:     cfg,
+		outboxRepo: outboxRepo,
+		webhooks:   webhooks,
+		logger:     logger,
+	}
+
+	if !cfg.Enabled {
+		return service, nil
+	}
+
+	publisher, err := events.NewPublisher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	service.publisher = publisher
+
+	if service.config.DispatchIntervalSeconds <= 0 {
+		service.config.DispatchIntervalSeconds = 10
+	}
+	if service.config.BatchSize <= 0 {
+		service.config.BatchSize = 100
+	}
+	if service.config.MaxAttempts <= 0 {
+		service.config.MaxAttempts = 10
+	}
+
+	return service, nil
+}
+
+// Emit queues an event for the webhooks and stores it in the outbox. Failures are logged only so
+// that a broken event pipeline never fails the business operation that produced the event.
+func (s *eventService) Emit(ctx context.Context, eventType string, data interface{}) {
+	s.webhooks.Enqueue(ctx, eventType, data)
+
+	if !s.config.Enabled {
+		return
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error marshalling event", "event", eventType, "error", err)
+		return
+	}
+
+	if _, err := s.outboxRepo.Add(ctx, eventType, string(payload)); err != nil {
+		s.logger.ErrorContext(ctx, "Error storing event", "event", eventType, "error", err)
+	}
+}
+
+// Start dispatches pending outbox events periodically until the context is cancelled
+func (s *eventService) Start(ctx context.Context) {
+	if !s.config.Enabled {
+		return
+	}
+
+	interval := time.Duration(s.config.DispatchIntervalSeconds) * time.Second
+
+	go func() {
+		defer s.publisher.Close()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.dispatch(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	s.logger.InfoContext(ctx, "Event dispatcher started", "driver", s.config.Driver, "interval", interval.String())
+}
+
+// dispatch publishes one batch of pending events in order, stopping at the first failure
+// so later events are not delivered ahead of an earlier one
+func (s *eventService) dispatch(ctx context.Context) {
+	pending, err := s.outboxRepo.ListPending(ctx, s.config.BatchSize, s.config.MaxAttempts)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error reading event outbox", "error", err)
+		return
+	}
+
+	for _, outboxEvent := range pending {
+		event := events.Event{
+			ID:         outboxEvent.ID,
+			Type:       outboxEvent.EventType,
+			OccurredAt: outboxEvent.CreatedAt,
+			Data:       json.RawMessage(outboxEvent.Payload),
+		}
+
+		if err := s.publisher.Publish(ctx, event); err != nil {
+			s.logger.ErrorContext(ctx, "Error publishing event", "outbox_event_id", outboxEvent.ID, "error", err)
+			if err := s.outboxRepo.MarkFailed(ctx, outboxEvent.ID, err.Error(), s.config.MaxAttempts); err != nil {
+				s.logger.ErrorContext(ctx, "Error updating event", "outbox_event_id", outboxEvent.ID, "error", err)
+			}
+			return
+		}
+
+		if err := s.outboxRepo.MarkPublished(ctx, outboxEvent.ID); err != nil {
+			s.logger.ErrorContext(ctx, "Error updating event", "outbox_event_id", outboxEvent.ID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/config"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrExchangeRateNotFound is returned for an unknown exchange rate ID
+var ErrExchangeRateNotFound = apperror.NotFound("exchange rate not found")
+
+const defaultLocalCurrency = "VND"
+
+// ExchangeRateService manages exchange rates and converts local report amounts
+type ExchangeRateService interface {
+	List(ctx context.Context, currencyCode string) ([]*models.ExchangeRate, error)
+	Create(ctx context.Context, userID int, request *dto.ExchangeRateRequest) (*models.ExchangeRate, error)
+	Update(ctx context.Context, id int, request *dto.ExchangeRateRequest) (*models.ExchangeRate, error)
+	Delete(ctx context.Context, id int) error
+	Converter(ctx context.Context, targetCurrency string) (*CurrencyConverter, error)
+}
+
+type exchangeRateService struct {
+	exchangeRateRepo repository.ExchangeRateRepository
+	localCurrency    string
+}
+
+// NewExchangeRateService creates a new exchange rate service
+func NewExchangeRateService(cfg config.CurrencyConfig, exchangeRateRepo repository.ExchangeRateRepository) ExchangeRateService {
+	localCurrency := strings.ToUpper(cfg.LocalCurrency)
+	if localCurrency == "" {
+		localCurrency = defaultLocalCurrency
+	}
+
+	return &exchangeRateService{
+		exchangeRateRepo: exchangeRateRepo,
+		localCurrency:    localCurrency,
+	}
+}
+
+// List returns the exchange rates, optionally of one currency
+func (s *exchangeRateService) List(ctx context.Context, currencyCode string) ([]*models.ExchangeRate, error) {
+	rates, err := s.exchangeRateRepo.List(ctx, strings.ToUpper(currencyCode))
+	if err != nil {
+		return nil, err
+	}
+	if rates == nil {
+		rates = []*models.ExchangeRate{}
+	}
+	return rates, nil
+}
+
+// Create adds an exchange rate
+func (s *exchangeRateService) Create(ctx context.Context, userID int, request *dto.ExchangeRateRequest) (*models.ExchangeRate, error) {
+	rate, err := s.buildRate(request)
+	if err != nil {
+		return nil, err
+	}
+	rate.CreatedBy = userID
+
+	if err := s.checkDuplicate(ctx, rate); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.exchangeRateRepo.Create(ctx, rate); err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+// Update changes an exchange rate
+func (s *exchangeRateService) Update(ctx context.Context, id int, request *dto.ExchangeRateRequest) (*models.ExchangeRate, error) {
+	existing, err := s.exchangeRateRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrExchangeRateNotFound
+		}
+		return nil, err
+	}
+
+	rate, err := s.buildRate(request)
+	if err != nil {
+		return nil, err
+	}
+	rate.ID = existing.ID
+	rate.CreatedBy = existing.CreatedBy
+	rate.CreatedAt = existing.CreatedAt
+
+	if err := s.checkDuplicate(ctx, rate); err != nil {
+		return nil, err
+	}
+
+	if err := s.exchangeRateRepo.Update(ctx, rate); err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+// Delete removes an exchange rate
+func (s *exchangeRateService) Delete(ctx context.Context, id int) error {
+	if err := s.exchangeRateRepo.Delete(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrExchangeRateNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// buildRate normalizes a request into an exchange rate
+func (s *exchangeRateService) buildRate(request *dto.ExchangeRateRequest) (*models.ExchangeRate, error) {
+	currencyCode := strings.ToUpper(request.CurrencyCode)
+	if currencyCode == s.localCurrency {
+		return nil, fmt.Errorf("%s is the local currency and always has a rate of 1", currencyCode)
+	}
+
+	effective := request.EffectiveDate
+	return &models.ExchangeRate{
+		CurrencyCode:  currencyCode,
+		Rate:          request.Rate,
+		EffectiveDate: time.Date(effective.Year(), effective.Month(), effective.Day(), 0, 0, 0, 0, time.UTC),
+		Notes:         strings.TrimSpace(request.Notes),
+	}, nil
+}
+
+// checkDuplicate rejects a second rate for the same currency and effective date
+func (s *exchangeRateService) checkDuplicate(ctx context.Context, rate *models.ExchangeRate) error {
+	rates, err := s.exchangeRateRepo.List(ctx, rate.CurrencyCode)
+	if err != nil {
+		return err
+	}
+	for _, existing := range rates {
+		if existing.ID != rate.ID && sameDay(existing.EffectiveDate, rate.EffectiveDate) {
+			return fmt.Errorf("a %s rate effective on %s already exists", rate.CurrencyCode, rate.EffectiveDate.Format("2006-01-02"))
+		}
+	}
+	return nil
+}
+
+// Converter loads the rates of the target currency for converting local amounts
+func (s *exchangeRateService) Converter(ctx context.Context, targetCurrency string) (*CurrencyConverter, error) {
+	targetCurrency = strings.ToUpper(targetCurrency)
+	converter := &CurrencyConverter{currency: targetCurrency}
+	if targetCurrency == s.localCurrency {
+		return converter, nil
+	}
+
+	rates, err := s.exchangeRateRepo.List(ctx, targetCurrency)
+	if err != nil {
+		return nil, err
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("no exchange rates are defined for %s", targetCurrency)
+	}
+
+	converter.rates = rates
+	return converter, nil
+}
+
+// CurrencyConverter converts local currency amounts into one target currency, using the rate in
+// effect on the document date. A converter without rates converts into the local currency itself.
+type CurrencyConverter struct {
+	currency string
+	rates    []*models.ExchangeRate // ordered by effective date
+}
+
+// Currency returns the target currency code
+func (c *CurrencyConverter) Currency() string {
+	return c.currency
+}
+
+// Convert converts a local amount dated at the given day, rounded to 2 decimals
+func (c *CurrencyConverter) Convert(amount float64, at time.Time) (float64, error) {
+	if len(c.rates) == 0 {
+		return amount, nil
+	}
+
+	day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+	// Index of the first rate that takes effect after the day; the one before it applies
+	next := sort.Search(len(c.rates), func(i int) bool {
+		return c.rates[i].EffectiveDate.After(day)
+	})
+	if next == 0 {
+		return 0, fmt.Errorf("no %s exchange rate is effective on %s", c.currency, day.Format("2006-01-02"))
+	}
+
+	return math.Round(amount/c.rates[next-1].Rate*100) / 100, nil
+}
+
+// ConvertFormatted converts a report amount formatted like "1,234,567" and dated dd/mm/yyyy
+func (c *CurrencyConverter) ConvertFormatted(amount string, docDate string) (float64, error) {
+	value, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(amount), ",", ""), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", amount, err)
+	}
+	at, err := time.Parse("02/01/2006", docDate)
+	if err != nil {
+		return 0, fmt.Errorf("invalid document date %q: %w", docDate, err)
+	}
+	return c.Convert(value, at)
+}
+
+// withTargetCurrency appends the target currency to a report title
+func withTargetCurrency(title string, targetCurrency string) string {
+	if targetCurrency == "" {
+		return title
+	}
+	return fmt.Sprintf("%s (%s)", title, strings.ToUpper(targetCurrency))
+}
+
+// sameDay reports whether two times fall on the same calendar date
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.Month() == b.Month() && a.Day() == b.Day()
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/config"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/events"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrExportRequestNotFound is returned for an export that is not waiting for approval or that
+	// lies outside the departments of the approver
+	ErrExportRequestNotFound = apperror.NotFound("export request not found")
+	// ErrSelfApproval is returned when users decide their own export request
+	ErrSelfApproval = apperror.PermissionDenied("an export request cannot be decided by the user who made it")
+	// ErrExportNeedsApproval is returned for a file of a report whose exports need approval by any
+	// other way than a queued export, such as a snapshot export or a schedule
+	ErrExportNeedsApproval = apperror.PermissionDenied("the exports of this report need a manager's approval, request one with a queued export")
+)
+
+const exportApprovalListLimit = 100
+
+// exportJobOperations are the export operations guarding the reports of queued exports
+var exportJobOperations = map[string]string{
+	"assistant230": "reports:export:230",
+	"assistant610": "reports:export:610",
+}
+
+// ExportOperationCode returns the operation guarding the exports of a report that can be queued
+func ExportOperationCode(report string) (string, bool) {
+	operationCode, ok := exportJobOperations[report]
+	return operationCode, ok
+}
+
+// ExportApprovalService holds the queued exports of sensitive reports until a manager of the
+// requester's department, or of a department above it, approves them
+type ExportApprovalService interface {
+	RequiresApproval(report string, isAdmin bool) bool
+	NotifyApprovers(ctx context.Context, job *models.ExportJob)
+	ListPending(ctx context.Context, departmentID int, isAdmin bool) ([]*dto.ExportJobResponse, error)
+	Decide(ctx context.Context, userID int, departmentID int, isAdmin bool, id int, approved bool, note string) (*dto.ExportJobResponse, error)
+}
+
+type exportApprovalService struct {
+	config         config.ExportApprovalConfig
+	jobRepo        repository.ExportJobRepository
+	departmentRepo repository.DepartmentRepository
+	roleRepo       repository.RoleRepository
+	userRepo       repository.UserRepository
+	notifications  NotificationService
+	eventService   EventService
+	logger         *slog.Logger
+}
+
+// NewExportApprovalService creates a new export approval service
+func NewExportApprovalService(
+	cfg config.ExportApprovalConfig,
+	jobRepo repository.ExportJobRepository,
+	departmentRepo repository.DepartmentRepository,
+	roleRepo repository.RoleRepository,
+	userRepo repository.UserRepository,
+	notifications NotificationService,
+	eventService EventService,
+	logger *slog.Logger,
+) ExportApprovalService {
+	if cfg.ApproverOperationCode == "" {
+		cfg.ApproverOperationCode = "export_approvals"
+	}
+
+	return &exportApprovalService{
+		config:         cfg,
+		jobRepo:        jobRepo,
+		departmentRepo: departmentRepo,
+		roleRepo:       roleRepo,
+		userRepo:       userRepo,
+		notifications:  notifications,
+		eventService:   eventService,
+		logger:         logger,
+	}
+}
+
+// RequiresApproval reports whether an export of the report waits for approval. Reports that
+// cannot be queued are those of the report engine. Administrators export without one.
+func (s *exportApprovalService) RequiresApproval(report string, isAdmin bool) bool {
+	operationCode, ok := exportJobOperations[report]
+	if !ok {
+		operationCode = config.ReportDefinitionsExportApproval
+	}
+	return !isAdmin && s.config.RequiresApproval(operationCode)
+}
+
+// NotifyApprovers tells the managers above the requester that an export waits for them
+func (s *exportApprovalService) NotifyApprovers(ctx context.Context, job *models.ExportJob) {
+	s.eventService.Emit(ctx, events.ExportApprovalRequested, events.ExportApprovalData{
+		JobID:        job.ID,
+		Report:       job.Report,
+		UserID:       job.UserID,
+		DepartmentID: job.DepartmentID,
+	})
+
+	departmentIDs, err := s.departmentRepo.GetAncestorIDs(ctx, job.DepartmentID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error finding export approvers", "job_id", job.ID, "error", err)
+		return
+	}
+	approvers, err := s.roleRepo.ListUsersWithOperation(ctx, s.config.ApproverOperationCode, append([]int{job.DepartmentID}, departmentIDs...))
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error finding export approvers", "job_id", job.ID, "error", err)
+		return
+	}
+
+	requester := s.username(ctx, job.UserID)
+	notified := 0
+	for _, approver := range approvers {
+		if approver.ID == job.UserID {
+			continue
+		}
+		s.notifications.Notify(ctx, &models.Notification{
+			UserID: approver.ID,
+			Type:   models.NotificationExportApprovalRequested,
+			Title:  fmt.Sprintf("Export of %s waiting for approval", job.Report),
+			Message: fmt.Sprintf(
+				"%s requested an export of %s at %s (request #%d). Approve or reject it in the export approvals page.",
+				requester,
+				job.Report,
+				job.CreatedAt.Format("2006-01-02 15:04"),
+				job.ID,
+			),
+			Link: "/api/export-approvals",
+		})
+		notified++
+	}
+	if notified == 0 {
+		s.logger.WarnContext(ctx, "No approver to notify of export request", "job_id", job.ID, "department_id", job.DepartmentID)
+	}
+}
+
+// ListPending gets the exports waiting for approval in the approver's department and the
+// departments below it, oldest first; administrators see every department
+func (s *exportApprovalService) ListPending(ctx context.Context, departmentID int, isAdmin bool) ([]*dto.ExportJobResponse, error) {
+	var departmentIDs []int
+	if !isAdmin {
+		ids, err := s.departmentRepo.GetDescendantIDs(ctx, departmentID)
+		if err != nil {
+			return nil, err
+		}
+		// Not nil even without departments, since nil lists every department
+		departmentIDs = append([]int{}, ids...)
+	}
+
+	jobs, err := s.jobRepo.ListPendingApproval(ctx, departmentIDs, exportApprovalListLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*dto.ExportJobResponse, 0, len(jobs))
+	for _, job := range jobs {
+		responses = append(responses, exportJobResponse(job))
+	}
+	return responses, nil
+}
+
+// Decide approves an export, queuing it to be generated, or rejects it with an optional note
+func (s *exportApprovalService) Decide(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	isAdmin bool,
+	id int,
+	approved bool,
+	note string,
+) (*dto.ExportJobResponse, error) {
+	job, err := s.jobRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrExportRequestNotFound
+		}
+		return nil, err
+	}
+	if job.Status != "pending_approval" {
+		return nil, ErrExportRequestNotFound
+	}
+
+	if !isAdmin {
+		if job.UserID == userID {
+			return nil, ErrSelfApproval
+		}
+		departmentIDs, err := s.departmentRepo.GetDescendantIDs(ctx, departmentID)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(departmentIDs, job.DepartmentID) {
+			return nil, ErrExportRequestNotFound
+		}
+	}
+
+	note = strings.TrimSpace(note)
+	if err := s.jobRepo.Decide(ctx, id, approved, userID, note); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Decided by another approver in the meantime
+			return nil, ErrExportRequestNotFound
+		}
+		return nil, err
+	}
+
+	eventType, decision := events.ExportRejected, "rejected"
+	if approved {
+		eventType, decision = events.ExportApproved, "approved"
+	}
+	s.eventService.Emit(ctx, eventType, events.ExportApprovalData{
+		JobID:        job.ID,
+		Report:       job.Report,
+		UserID:       job.UserID,
+		DepartmentID: job.DepartmentID,
+		DecidedBy:    userID,
+		Note:         note,
+	})
+	s.logger.InfoContext(ctx, "Export request decided", "job_id", job.ID, "decision", decision, "decided_by", userID)
+	s.notifyRequester(ctx, job, approved, userID, note)
+
+	job, err = s.jobRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return exportJobResponse(job), nil
+}
+
+// notifyRequester tells the user who requested the export about the decision
+func (s *exportApprovalService) notifyRequester(ctx context.Context, job *models.ExportJob, approved bool, decidedBy int, note string) {
+	notificationType, decision := models.NotificationExportRejected, "rejected"
+	if approved {
+		notificationType, decision = models.NotificationExportApproved, "approved"
+	}
+
+	message := fmt.Sprintf("Your export of %s (request #%d) was %s by %s.", job.Report, job.ID, decision, s.username(ctx, decidedBy))
+	if approved {
+		message += " The file is being generated and can be downloaded from your exports once it is ready."
+	}
+	if note != "" {
+		message += "\nNote: " + note
+	}
+
+	s.notifications.Notify(ctx, &models.Notification{
+		UserID:  job.UserID,
+		Type:    notificationType,
+		Title:   fmt.Sprintf("Export of %s %s", job.Report, decision),
+		Message: message,
+		Link:    fmt.Sprintf("/api/reports/exports/%d", job.ID),
+	})
+}
+
+// username returns the full name of a user for a notification, or their ID when it cannot be read
+func (s *exportApprovalService) username(ctx context.Context, userID int) string {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Sprintf("user %d", userID)
+	}
+	if user.FullName != "" {
+		return user.FullName
+	}
+	return user.Username
+}
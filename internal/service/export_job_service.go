@@ -0,0 +1,535 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"erp-excel/config"
+	"erp-excel/database"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/storage"
+	"erp-excel/internal/translate"
+	"erp-excel/internal/utils"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// ErrExportJobNotFound is returned for an unknown job or a job of another user
+	ErrExportJobNotFound = apperror.NotFound("export job not found")
+	// ErrExportJobNotReady is returned when the file of a job that has not completed is requested
+	ErrExportJobNotReady = apperror.Conflict("export job has not completed")
+	// ErrInvalidExportJob is returned when a job is submitted for an unknown report or with invalid parameters
+	ErrInvalidExportJob = apperror.Validation("invalid export job")
+)
+
+const exportJobListLimit = 50
+
+// bundleReport is the report of the export jobs generating a ZIP bundle of several reports
+const bundleReport = "bundle"
+
+// ExportJobService queues exports and generates them in the background, so a large date range
+// does not hold an HTTP request open for minutes
+type ExportJobService interface {
+	Submit(ctx context.Context, userID int, departmentID int, isAdmin bool, report string, request *dto.DateRangeRequest) (*dto.ExportJobResponse, error)
+	Bundle(ctx context.Context, userID int, departmentID int, isAdmin bool, request *dto.ReportBundleRequest) (*dto.ReportBundleResponse, error)
+	Get(ctx context.Context, userID int, id int) (*dto.ExportJobResponse, error)
+	List(ctx context.Context, userID int) ([]*dto.ExportJobResponse, error)
+	FileName(ctx context.Context, userID int, id int) (string, error)
+	Start(ctx context.Context)
+	// Stop waits for the running jobs once the context given to Start is cancelled. Jobs still
+	// running when ctx is done are cancelled and recorded as failed.
+	Stop(ctx context.Context)
+}
+
+// exportJobRunner generates the file of one report
+type exportJobRunner func(ctx context.Context, userID int, departmentID int, request *dto.DateRangeRequest) (*dto.ReportFileResponse, error)
+
+type exportJobService struct {
+	config        config.ExportJobsConfig
+	jobRepo       repository.ExportJobRepository
+	fileStorage   storage.Storage
+	fileRepo      repository.ReportFileRepository
+	approvals     ExportApprovalService
+	notifications NotificationService
+	runners       map[string]exportJobRunner
+	logger        *slog.Logger
+
+	workers workerGroup
+}
+
+// NewExportJobService creates a new export job service
+func NewExportJobService(
+	cfg config.ExportJobsConfig,
+	jobRepo repository.ExportJobRepository,
+	fileStorage storage.Storage,
+	fileRepo repository.ReportFileRepository,
+	approvals ExportApprovalService,
+	notifications NotificationService,
+	reportService ReportService,
+	assistant610Service Assistant610Service,
+	logger *slog.Logger,
+) ExportJobService {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 2
+	}
+	if cfg.PollIntervalSeconds <= 0 {
+		cfg.PollIntervalSeconds = 5
+	}
+	if cfg.TimeoutMinutes <= 0 {
+		cfg.TimeoutMinutes = 30
+	}
+	if cfg.BundleSyncReports <= 0 {
+		cfg.BundleSyncReports = 2
+	}
+
+	return &exportJobService{
+		config:        cfg,
+		jobRepo:       jobRepo,
+		fileStorage:   fileStorage,
+		fileRepo:      fileRepo,
+		approvals:     approvals,
+		notifications: notifications,
+		runners:       newExportJobRunners(reportService, assistant610Service),
+		logger:        logger,
+	}
+}
+
+// exportJobParameters are the stored parameters of a job: the export request, or the reports of
+// a bundle, and the language and company of the request that queued it, which the worker writes
+// the file in and queries
+type exportJobParameters struct {
+	dto.DateRangeRequest
+	Bundle  []dto.ReportBundleItem `json:"bundle,omitempty"`
+	Locale  string                 `json:"locale,omitempty"`
+	Company string                 `json:"company,omitempty"`
+}
+
+// newExportJobRunners maps the reports that can be exported in the background to their export
+func newExportJobRunners(reportService ReportService, assistant610Service Assistant610Service) map[string]exportJobRunner {
+	return map[string]exportJobRunner{
+		"assistant230": reportService.ExportInventoryReport,
+		"assistant610": assistant610Service.ExportAssistant610Report,
+	}
+}
+
+// Submit validates the request and queues the export. Exports of reports that need approval
+// wait in pending_approval until a manager approves them.
+func (s *exportJobService) Submit(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	isAdmin bool,
+	report string,
+	request *dto.DateRangeRequest,
+) (*dto.ExportJobResponse, error) {
+	if err := s.validate(report, request); err != nil {
+		return nil, err
+	}
+
+	needsApproval := s.approvals.RequiresApproval(report, isAdmin)
+	job, err := s.queue(ctx, userID, departmentID, report, exportJobParameters{DateRangeRequest: *request}, needsApproval)
+	if err != nil {
+		return nil, err
+	}
+	return exportJobResponse(job), nil
+}
+
+// Bundle exports several reports into one ZIP in file storage. Small bundles are generated while
+// the request waits; bundles of more reports than configured, bundles the client asks to queue and
+// bundles holding a report whose exports need approval are queued as an export job instead.
+func (s *exportJobService) Bundle(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	isAdmin bool,
+	request *dto.ReportBundleRequest,
+) (*dto.ReportBundleResponse, error) {
+	items := make([]dto.ReportBundleItem, 0, len(request.Reports))
+	needsApproval := false
+	for _, item := range request.Reports {
+		if item.FromDate == nil && item.ToDate == nil && item.Period == nil {
+			item.FromDate, item.ToDate, item.Period = request.FromDate, request.ToDate, request.Period
+		}
+		if err := s.validate(item.Report, &item.DateRangeRequest); err != nil {
+			return nil, err
+		}
+		needsApproval = needsApproval || s.approvals.RequiresApproval(item.Report, isAdmin)
+		items = append(items, item)
+	}
+
+	if request.Async || needsApproval || len(items) > s.config.BundleSyncReports {
+		job, err := s.queue(ctx, userID, departmentID, bundleReport, exportJobParameters{Bundle: items}, needsApproval)
+		if err != nil {
+			return nil, err
+		}
+		return &dto.ReportBundleResponse{Job: exportJobResponse(job)}, nil
+	}
+
+	fileName, downloadURL, err := s.runBundle(ctx, userID, departmentID, items)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.ReportBundleResponse{FileName: fileName, DownloadURL: downloadURL}, nil
+}
+
+// validate rejects an export of an unknown report or with a bad date range or columns now rather
+// than failing the job later
+func (s *exportJobService) validate(report string, request *dto.DateRangeRequest) error {
+	if _, ok := s.runners[report]; !ok {
+		return fmt.Errorf("%w: unknown report %s", ErrInvalidExportJob, report)
+	}
+
+	if _, _, err := reportDateRange.Resolve(request); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidExportJob, err)
+	}
+	if columns, ok := reportColumns[report]; ok {
+		if _, err := columns(request); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidExportJob, err)
+		}
+	}
+	return nil
+}
+
+// queue records a job with the language and company of the request. Jobs that need approval wait
+// in pending_approval until a manager approves them.
+func (s *exportJobService) queue(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	report string,
+	parameters exportJobParameters,
+	needsApproval bool,
+) (*models.ExportJob, error) {
+	parameters.Locale = translate.FromContext(ctx)
+	parameters.Company = database.CompanyFromContext(ctx)
+	encoded, err := json.Marshal(parameters)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling export parameters: %w", err)
+	}
+
+	job := &models.ExportJob{
+		Report:       report,
+		Parameters:   string(encoded),
+		UserID:       userID,
+		DepartmentID: departmentID,
+	}
+	if needsApproval {
+		job.Status = "pending_approval"
+	}
+	if _, err := s.jobRepo.Create(ctx, job); err != nil {
+		return nil, err
+	}
+
+	if job.Status == "pending_approval" {
+		s.logger.InfoContext(ctx, "Export job waiting for approval", "report", report, "job_id", job.ID, "user_id", userID)
+		s.approvals.NotifyApprovers(ctx, job)
+		return job, nil
+	}
+
+	s.logger.InfoContext(ctx, "Queued export job", "report", report, "job_id", job.ID, "user_id", userID)
+	return job, nil
+}
+
+// Get returns the status of one of the user's jobs
+func (s *exportJobService) Get(ctx context.Context, userID int, id int) (*dto.ExportJobResponse, error) {
+	job, err := s.getOwnJob(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return exportJobResponse(job), nil
+}
+
+// List returns the user's newest jobs
+func (s *exportJobService) List(ctx context.Context, userID int) ([]*dto.ExportJobResponse, error) {
+	jobs, err := s.jobRepo.ListByUser(ctx, userID, exportJobListLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*dto.ExportJobResponse, 0, len(jobs))
+	for _, job := range jobs {
+		responses = append(responses, exportJobResponse(job))
+	}
+	return responses, nil
+}
+
+// FileName returns the stored file of a completed job
+func (s *exportJobService) FileName(ctx context.Context, userID int, id int) (string, error) {
+	job, err := s.getOwnJob(ctx, userID, id)
+	if err != nil {
+		return "", err
+	}
+	if job.Status != "completed" {
+		return "", ErrExportJobNotReady
+	}
+	return job.FileName, nil
+}
+
+// Start runs the configured number of workers until the context is cancelled
+func (s *exportJobService) Start(ctx context.Context) {
+	interval := time.Duration(s.config.PollIntervalSeconds) * time.Second
+
+	// Cancelling ctx stops claiming jobs; the running ones finish on workCtx
+	workCtx := s.workers.start(ctx)
+	for i := 0; i < s.config.Workers; i++ {
+		s.workers.run(func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				// Keep claiming while there is work, then wait for the next poll
+				for s.runNext(ctx, workCtx) {
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		})
+	}
+
+	// Jobs of an instance that stopped mid-export would otherwise stay running forever
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			s.failStale(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	s.logger.InfoContext(ctx, "Export job workers started", "workers", s.config.Workers, "interval", interval.String())
+}
+
+func (s *exportJobService) Stop(ctx context.Context) {
+	s.workers.stop(ctx)
+}
+
+// runNext claims a job while ctx is not cancelled and runs it on workCtx, reporting whether
+// there was one
+func (s *exportJobService) runNext(ctx context.Context, workCtx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	job, err := s.jobRepo.ClaimNext(ctx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error claiming export job", "error", err)
+		return false
+	}
+	if job == nil {
+		return false
+	}
+
+	fileName, err := s.run(workCtx, job)
+
+	// Record the outcome even when the worker is being stopped
+	recordCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Export job failed", "job_id", job.ID, "error", err)
+		if err := s.jobRepo.Fail(recordCtx, job.ID, err.Error()); err != nil {
+			s.logger.ErrorContext(ctx, "Error updating export job", "job_id", job.ID, "error", err)
+		}
+		s.notifications.Notify(recordCtx, &models.Notification{
+			UserID:  job.UserID,
+			Type:    models.NotificationExportFailed,
+			Title:   fmt.Sprintf("Export of %s failed", job.Report),
+			Message: fmt.Sprintf("Your export of %s (job #%d) failed: %s", job.Report, job.ID, err.Error()),
+			Link:    fmt.Sprintf("/api/reports/exports/%d", job.ID),
+		})
+		return true
+	}
+
+	if err := s.jobRepo.Complete(recordCtx, job.ID, fileName); err != nil {
+		s.logger.ErrorContext(ctx, "Error updating export job", "job_id", job.ID, "error", err)
+		return true
+	}
+	s.logger.InfoContext(ctx, "Export job completed", "job_id", job.ID, "file", fileName)
+	s.notifications.Notify(recordCtx, &models.Notification{
+		UserID:  job.UserID,
+		Type:    models.NotificationExportReady,
+		Title:   fmt.Sprintf("Export of %s is ready", job.Report),
+		Message: fmt.Sprintf("Your export of %s (job #%d) is ready to download.", job.Report, job.ID),
+		Link:    fmt.Sprintf("/api/reports/exports/%d/file", job.ID),
+	})
+	return true
+}
+
+// run generates the file of a job and returns its stored name
+func (s *exportJobService) run(ctx context.Context, job *models.ExportJob) (string, error) {
+	runner, ok := s.runners[job.Report]
+	if !ok && job.Report != bundleReport {
+		return "", fmt.Errorf("unknown report %s", job.Report)
+	}
+
+	var parameters exportJobParameters
+	if err := json.Unmarshal([]byte(job.Parameters), &parameters); err != nil {
+		return "", fmt.Errorf("error reading export parameters: %w", err)
+	}
+	// Jobs queued before the snake_case fields may carry the deprecated spellings
+	parameters.Normalize()
+	for i := range parameters.Bundle {
+		parameters.Bundle[i].Normalize()
+	}
+	if parameters.Locale != "" {
+		ctx = translate.WithLocale(ctx, parameters.Locale)
+	}
+	if parameters.Company != "" {
+		ctx = database.WithCompany(ctx, parameters.Company)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.config.TimeoutMinutes)*time.Minute)
+	defer cancel()
+
+	if job.Report == bundleReport {
+		fileName, _, err := s.runBundle(ctx, job.UserID, job.DepartmentID, parameters.Bundle)
+		return fileName, err
+	}
+	return s.export(ctx, runner, job.UserID, job.DepartmentID, &parameters.DateRangeRequest)
+}
+
+// export generates the file of one report and returns its stored name
+func (s *exportJobService) export(
+	ctx context.Context,
+	runner exportJobRunner,
+	userID int,
+	departmentID int,
+	request *dto.DateRangeRequest,
+) (string, error) {
+	response, err := runner(ctx, userID, departmentID, request)
+	if err != nil {
+		return "", err
+	}
+
+	// The export stores its file on a best-effort basis; a job is only complete if it can be downloaded
+	fileName := filepath.Base(response.FileName)
+	exists, err := s.fileStorage.Exists(ctx, fileName)
+	if err != nil {
+		return "", fmt.Errorf("error checking export file: %w", err)
+	}
+	if !exists {
+		return "", errors.New("the export file could not be stored")
+	}
+
+	return fileName, nil
+}
+
+// runBundle exports each report of a bundle and stores the files together in one ZIP, recorded as
+// a file of the user. It returns the stored name of the ZIP and its download link.
+func (s *exportJobService) runBundle(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	items []dto.ReportBundleItem,
+) (string, string, error) {
+	if len(items) == 0 {
+		return "", "", errors.New("the bundle has no reports")
+	}
+
+	files := make([]utils.ZipFile, 0, len(items))
+	for _, item := range items {
+		runner, ok := s.runners[item.Report]
+		if !ok {
+			return "", "", fmt.Errorf("unknown report %s", item.Report)
+		}
+		fileName, err := s.export(ctx, runner, userID, departmentID, &item.DateRangeRequest)
+		if err != nil {
+			return "", "", fmt.Errorf("error exporting %s: %w", item.Report, err)
+		}
+		files = append(files, utils.ZipFile{
+			Name: fileName,
+			Open: func() (io.ReadCloser, error) { return s.fileStorage.Open(ctx, fileName) },
+		})
+	}
+
+	var content bytes.Buffer
+	if err := utils.ZipFiles(&content, files); err != nil {
+		return "", "", err
+	}
+
+	bundle := &models.ReportFile{
+		FileName: fmt.Sprintf("Report_Bundle_%d_%s.zip", userID, time.Now().Format("20060102_150405")),
+		Report:   bundleReport,
+		UserID:   userID,
+	}
+	downloadURL := storeGeneratedFile(ctx, s.logger, s.fileStorage, s.fileRepo, bundle, &content, utils.ZipContentType)
+
+	exists, err := s.fileStorage.Exists(ctx, bundle.FileName)
+	if err != nil {
+		return "", "", fmt.Errorf("error checking bundle file: %w", err)
+	}
+	if !exists {
+		return "", "", errors.New("the bundle file could not be stored")
+	}
+
+	s.logger.InfoContext(ctx, "Report bundle generated", "file", bundle.FileName, "reports", len(items), "user_id", userID)
+	return bundle.FileName, downloadURL, nil
+}
+
+// failStale fails the jobs running for longer than the timeout
+func (s *exportJobService) failStale(ctx context.Context) {
+	// Allow a minute on top of the timeout for the worker to record the outcome itself
+	cutoff := time.Now().Add(-time.Duration(s.config.TimeoutMinutes)*time.Minute - time.Minute)
+
+	count, err := s.jobRepo.FailStale(ctx, cutoff)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error failing stale export jobs", "error", err)
+		return
+	}
+	if count > 0 {
+		s.logger.WarnContext(ctx, "Failed stale export jobs", "count", count)
+	}
+}
+
+// getOwnJob gets a job, hiding the jobs of other users
+func (s *exportJobService) getOwnJob(ctx context.Context, userID int, id int) (*models.ExportJob, error) {
+	job, err := s.jobRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrExportJobNotFound
+		}
+		return nil, err
+	}
+	if job.UserID != userID {
+		return nil, ErrExportJobNotFound
+	}
+	return job, nil
+}
+
+// exportJobResponse converts a job to its API response
+func exportJobResponse(job *models.ExportJob) *dto.ExportJobResponse {
+	return &dto.ExportJobResponse{
+		ID:         job.ID,
+		Report:     job.Report,
+		Parameters: json.RawMessage(job.Parameters),
+		Status:     job.Status,
+		FileName:   job.FileName,
+		Error:      job.Error,
+		CreatedAt:  job.CreatedAt,
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+		UserID:     job.UserID,
+		DecidedBy:  job.DecidedBy,
+		DecidedAt:  job.DecidedAt,
+		Note:       job.DecisionNote,
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"erp-excel/internal/events"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/utils"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// provisionExternalUser creates the local account of a first-time SSO or directory user. Such
+// users never log in with the local password, so a random one is stored.
+func provisionExternalUser(
+	ctx context.Context,
+	userRepo repository.UserRepository,
+	eventService EventService,
+	logger *slog.Logger,
+	user *models.User,
+	source string,
+) (*models.User, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("error generating password: %w", err)
+	}
+	hashedPassword, err := utils.HashPassword(hex.EncodeToString(buf))
+	if err != nil {
+		return nil, fmt.Errorf("error hashing password: %w", err)
+	}
+	user.Password = hashedPassword
+	user.IsActive = true
+
+	if _, err := userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	logger.InfoContext(ctx, "Provisioned external user", "username", user.Username, "source", source)
+
+	eventService.Emit(ctx, events.UserCreated, events.UserCreatedData{
+		UserID:       user.ID,
+		Username:     user.Username,
+		DepartmentID: user.DepartmentID,
+		Source:       source,
+	})
+
+	// Reload to pick up the department
+	return userRepo.GetByUsername(ctx, user.Username)
+}
+
+// syncMappedRoles applies a group-to-role mapping to the groups the identity provider or
+// directory reports for the user. Only roles that appear in the mapping are managed; roles
+// granted manually by an administrator are left untouched.
+func syncMappedRoles(
+	ctx context.Context,
+	userRepo repository.UserRepository,
+	userID int,
+	mapping map[string]int,
+	groups []string,
+) error {
+	if len(mapping) == 0 {
+		return nil
+	}
+
+	mapped := make(map[int]bool)
+	asserted := make(map[int]bool)
+	for group, roleID := range mapping {
+		mapped[roleID] = true
+		for _, value := range groups {
+			if strings.EqualFold(strings.TrimSpace(value), group) {
+				asserted[roleID] = true
+			}
+		}
+	}
+
+	current, err := userRepo.GetUserRoles(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("error getting user roles: %w", err)
+	}
+
+	roleIDs := make([]int, 0, len(current)+len(asserted))
+	changed := false
+	for _, role := range current {
+		if mapped[role.ID] && !asserted[role.ID] {
+			changed = true
+			continue
+		}
+		roleIDs = append(roleIDs, role.ID)
+		delete(asserted, role.ID)
+	}
+	for roleID := range asserted {
+		roleIDs = append(roleIDs, roleID)
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	sort.Ints(roleIDs)
+	if err := userRepo.AssignRoles(ctx, userID, roleIDs); err != nil {
+		return fmt.Errorf("error syncing user roles: %w", err)
+	}
+
+	return nil
+}
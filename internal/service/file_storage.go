@@ -0,0 +1,111 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"erp-excel/internal/integration"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/storage"
+	"erp-excel/internal/utils"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// storeExportFile keeps a copy of a generated export in file storage so it can be downloaded again later,
+// and records who generated it and its checksum. It returns the presigned URL of object storage, or else
+// a signed download link bound to the user. The checksum is set on file even when storage is not
+// available. Failures are logged only, the caller still has the generated file in memory.
+func storeExportFile(
+	ctx context.Context,
+	logger *slog.Logger,
+	fileStorage storage.Storage,
+	fileRepo repository.ReportFileRepository,
+	file *models.ReportFile,
+	content *bytes.Buffer,
+) string {
+	return storeGeneratedFile(ctx, logger, fileStorage, fileRepo, file, content, utils.ExcelContentType)
+}
+
+// storeGeneratedFile is storeExportFile for files of any content type, such as ZIP bundles
+func storeGeneratedFile(
+	ctx context.Context,
+	logger *slog.Logger,
+	fileStorage storage.Storage,
+	fileRepo repository.ReportFileRepository,
+	file *models.ReportFile,
+	content *bytes.Buffer,
+	contentType string,
+) string {
+	if content == nil {
+		return ""
+	}
+
+	data := content.Bytes()
+	file.Size = int64(len(data))
+	file.Checksum = utils.Checksum(data)
+	if fileStorage == nil {
+		return ""
+	}
+
+	fileName := file.FileName
+	metadata := storage.Metadata{
+		"report":   file.Report,
+		"user-id":  strconv.Itoa(file.UserID),
+		"checksum": file.Checksum,
+	}
+	if file.RowCount > 0 {
+		metadata["row-count"] = strconv.Itoa(file.RowCount)
+	}
+	if err := fileStorage.Save(ctx, fileName, bytes.NewReader(data), int64(len(data)), contentType, metadata); err != nil {
+		logger.ErrorContext(ctx, "Error storing export file", "file", fileName, "error", err)
+		return ""
+	}
+
+	if fileRepo != nil {
+		if err := fileRepo.Create(ctx, file); err != nil {
+			logger.ErrorContext(ctx, "Error recording export file", "file", fileName, "error", err)
+		}
+	}
+
+	downloadURL, err := fileStorage.PresignedURL(ctx, fileName, 0)
+	if err != nil {
+		logger.ErrorContext(ctx, "Error creating download URL", "file", fileName, "error", err)
+		return ""
+	}
+	if downloadURL == "" {
+		downloadURL = utils.SignDownloadLink(fileName, file.UserID)
+	}
+
+	return downloadURL
+}
+
+// publishExportFile uploads a copy of a generated export to SharePoint/OneDrive in the background,
+// so a slow Graph API never holds up the download. The upload outlives the request but keeps its
+// request ID in the logs.
+func publishExportFile(
+	ctx context.Context,
+	logger *slog.Logger,
+	client integration.SharePointClient,
+	reportName, fileName string,
+	content *bytes.Buffer,
+) {
+	if client == nil || !client.Enabled() || content == nil {
+		return
+	}
+
+	data := append([]byte(nil), content.Bytes()...)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Minute)
+		defer cancel()
+
+		filePath := client.ResolvePath(reportName, fileName, time.Now())
+		webURL, err := client.Upload(ctx, filePath, data)
+		if err != nil {
+			logger.ErrorContext(ctx, "Error publishing export to SharePoint", "file", fileName, "error", err)
+			return
+		}
+		logger.InfoContext(ctx, "Published export to SharePoint", "file", fileName, "url", webURL)
+	}()
+}
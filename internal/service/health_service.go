@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/config"
+	"erp-excel/internal/dto"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Health statuses
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+	HealthStatusDown        = "down"
+)
+
+// HealthService probes the dependencies an instance needs to serve requests
+type HealthService interface {
+	// Check pings every dependency in parallel, each with its own timeout
+	Check(ctx context.Context) *dto.HealthResponse
+}
+
+type healthDependency struct {
+	name string
+	db   *sql.DB
+}
+
+type healthService struct {
+	config       *config.Config
+	timeout      time.Duration
+	dependencies []healthDependency
+	logger       *slog.Logger
+}
+
+// NewHealthService creates a new health service probing the app database and the ERP database
+// of every company. The default company is reported as erp_db, the others as erp_db:<code>.
+func NewHealthService(cfg *config.Config, appDB *sql.DB, erpDBs map[string]*sql.DB, logger *slog.Logger) HealthService {
+	timeoutMs := cfg.Health.TimeoutMs
+	if timeoutMs <= 0 {
+		timeoutMs = 2000
+	}
+
+	dependencies := []healthDependency{
+		{name: "app_db", db: appDB},
+		{name: "erp_db", db: erpDBs[cfg.DefaultERPCompany()]},
+	}
+	companies := make([]string, 0, len(erpDBs))
+	for company := range erpDBs {
+		if company != cfg.DefaultERPCompany() {
+			companies = append(companies, company)
+		}
+	}
+	sort.Strings(companies)
+	for _, company := range companies {
+		dependencies = append(dependencies, healthDependency{name: "erp_db:" + company, db: erpDBs[company]})
+	}
+
+	return &healthService{
+		config:       cfg,
+		timeout:      time.Duration(timeoutMs) * time.Millisecond,
+		dependencies: dependencies,
+		logger:       logger,
+	}
+}
+
+// Check pings the databases and reports the instance unavailable when one of them is down
+func (s *healthService) Check(ctx context.Context) *dto.HealthResponse {
+	results := make([]dto.DependencyHealth, len(s.dependencies))
+
+	var wg sync.WaitGroup
+	for i, dependency := range s.dependencies {
+		wg.Add(1)
+		go func(i int, dependency healthDependency) {
+			defer wg.Done()
+			results[i] = s.probe(ctx, dependency)
+		}(i, dependency)
+	}
+	wg.Wait()
+
+	status := HealthStatusOK
+	for _, result := range results {
+		if result.Status != HealthStatusOK {
+			status = HealthStatusUnavailable
+			s.logger.WarnContext(ctx, "Health check failed", "dependency", result.Name, "error", result.Error)
+		}
+	}
+
+	return &dto.HealthResponse{
+		Status:       status,
+		Name:         s.config.Server.Name,
+		Env:          s.config.Server.Env,
+		CheckedAt:    time.Now(),
+		Dependencies: results,
+	}
+}
+
+// probe pings one database within the configured timeout
+func (s *healthService) probe(ctx context.Context, dependency healthDependency) dto.DependencyHealth {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := dependency.db.PingContext(ctx)
+	result := dto.DependencyHealth{
+		Name:      dependency.name,
+		Status:    HealthStatusOK,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = HealthStatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
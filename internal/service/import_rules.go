@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidImportRule is returned for a validation rule that cannot be applied, such as a rule
+// with an invalid regular expression or an unknown ERP lookup
+var ErrInvalidImportRule = apperror.Validation("invalid import rule")
+
+// importRule is a validation rule ready to be applied, with its regular expression compiled
+type importRule struct {
+	*models.ImportRule
+	pattern *regexp.Regexp
+}
+
+// compileImportRule checks that a rule can be applied and compiles its regular expression
+func compileImportRule(rule *models.ImportRule) (importRule, error) {
+	compiled := importRule{ImportRule: rule}
+	if rule.Column == "" {
+		return compiled, fmt.Errorf("%w: a column is required", ErrInvalidImportRule)
+	}
+
+	switch rule.Type {
+	case models.ImportRuleRequired:
+	case models.ImportRulePattern:
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil || rule.Pattern == "" {
+			return compiled, fmt.Errorf("%w: %s needs a valid regular expression", ErrInvalidImportRule, rule.Column)
+		}
+		compiled.pattern = pattern
+	case models.ImportRuleRange:
+		if rule.Min == nil && rule.Max == nil {
+			return compiled, fmt.Errorf("%w: the range of %s needs a min or a max", ErrInvalidImportRule, rule.Column)
+		}
+		if rule.Min != nil && rule.Max != nil && *rule.Min > *rule.Max {
+			return compiled, fmt.Errorf("%w: the min of %s is above its max", ErrInvalidImportRule, rule.Column)
+		}
+	case models.ImportRuleMaxLength:
+		if rule.Max == nil || *rule.Max < 1 {
+			return compiled, fmt.Errorf("%w: the max_length of %s needs a max of at least 1", ErrInvalidImportRule, rule.Column)
+		}
+	case models.ImportRuleLookup:
+		if !slices.Contains(repository.ERPLookups(), rule.Lookup) {
+			return compiled, fmt.Errorf("%w: lookup of %s must be one of %s",
+				ErrInvalidImportRule, rule.Column, strings.Join(repository.ERPLookups(), ", "))
+		}
+	default:
+		return compiled, fmt.Errorf("%w: unknown rule type %q", ErrInvalidImportRule, rule.Type)
+	}
+	return compiled, nil
+}
+
+// configImportRules converts the rules of an import type in the config
+func configImportRules(typeName string, rules []config.ImportRuleConfig) []*models.ImportRule {
+	converted := make([]*models.ImportRule, 0, len(rules))
+	for _, rule := range rules {
+		converted = append(converted, &models.ImportRule{
+			ImportType: typeName,
+			Column:     rule.Column,
+			Type:       rule.Type,
+			Pattern:    rule.Pattern,
+			Min:        rule.Min,
+			Max:        rule.Max,
+			Lookup:     rule.Lookup,
+			Message:    rule.Message,
+			Source:     models.ImportRuleConfig,
+		})
+	}
+	return converted
+}
+
+// checkCell returns the error of a cell that breaks the rule, or nil. Empty cells only break
+// required rules; lookup rules are checked for all rows at once by applyImportRules.
+func (r importRule) checkCell(value string) *models.ImportCellError {
+	if value == "" {
+		if r.Type == models.ImportRuleRequired {
+			return r.cellError(r.Type, value, "is required")
+		}
+		return nil
+	}
+
+	switch r.Type {
+	case models.ImportRulePattern:
+		if !r.pattern.MatchString(value) {
+			return r.cellError(r.Type, value, fmt.Sprintf("must match %s", r.Pattern))
+		}
+	case models.ImportRuleRange:
+		number, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64)
+		switch {
+		case err != nil:
+			return r.cellError(models.ImportErrorNotNumber, value, "must be a number")
+		case r.Min != nil && number < *r.Min:
+			return r.cellError(r.Type, value, fmt.Sprintf("must be at least %g", *r.Min))
+		case r.Max != nil && number > *r.Max:
+			return r.cellError(r.Type, value, fmt.Sprintf("must be at most %g", *r.Max))
+		}
+	case models.ImportRuleMaxLength:
+		if utf8.RuneCountInString(value) > int(*r.Max) {
+			return r.cellError(r.Type, value, fmt.Sprintf("must be at most %d characters", int(*r.Max)))
+		}
+	}
+	return nil
+}
+
+// cellError builds the error of a cell, using the message of the rule when it has one
+func (r importRule) cellError(code, value, message string) *models.ImportCellError {
+	if r.Message != "" {
+		message = r.Message
+	}
+	return &models.ImportCellError{Column: r.Column, Code: code, Value: value, Message: message}
+}
+
+// applyImportRules checks the cells of the rows against the rules and appends the errors to the
+// rows. A cell is reported once: the rules after the first one it breaks are skipped. The values
+// of each lookup are read from the ERP with one query per lookup.
+func applyImportRules(ctx context.Context, repo repository.DataImportRepository, rules []importRule, rows []importSheetRow) error {
+	for _, rule := range rules {
+		if rule.Type == models.ImportRuleLookup {
+			if err := applyLookupRule(ctx, repo, rule, rows); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, row := range rows {
+			if hasCellError(row.ImportRow, rule.Column) {
+				continue
+			}
+			if cellError := rule.checkCell(row.cells[rule.Column]); cellError != nil {
+				row.Errors = append(row.Errors, *cellError)
+			}
+		}
+	}
+	return nil
+}
+
+// applyLookupRule checks the cells of a lookup rule against the keys of its ERP table
+func applyLookupRule(ctx context.Context, repo repository.DataImportRepository, rule importRule, rows []importSheetRow) error {
+	seen := make(map[string]bool)
+	var values []string
+	for _, row := range rows {
+		value := row.cells[rule.Column]
+		if value != "" && !seen[value] && !hasCellError(row.ImportRow, rule.Column) {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+
+	found, err := repo.LookupERPValues(ctx, rule.Lookup, values)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		value := row.cells[rule.Column]
+		if value == "" || found[strings.ToUpper(value)] || hasCellError(row.ImportRow, rule.Column) {
+			continue
+		}
+		row.Errors = append(row.Errors, *rule.cellError(rule.Type, value, fmt.Sprintf("is not a %s in the ERP", rule.Lookup)))
+	}
+	return nil
+}
+
+// hasCellError reports whether a cell of the row already has an error
+func hasCellError(row *models.ImportRow, column string) bool {
+	for _, cellError := range row.Errors {
+		if cellError.Column == column {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleColumns returns the columns the rules name, in rule order, without duplicates
+func ruleColumns(rules []importRule) []string {
+	var columns []string
+	for _, rule := range rules {
+		if !slices.Contains(columns, rule.Column) {
+			columns = append(columns, rule.Column)
+		}
+	}
+	return columns
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/events"
+	"erp-excel/internal/integration"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/storage"
+	"erp-excel/internal/utils"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ItemInventoryService builds the item-level stock report (quantity in/out/balance per warehouse)
+type ItemInventoryService interface {
+	GetItemInventoryData(ctx context.Context, userID int, request *dto.ItemInventoryRequest, ipAddress string) ([]dto.ItemInventoryItem, error)
+	ExportItemInventory(ctx context.Context, userID int, departmentID int, request *dto.ItemInventoryRequest, ipAddress string) (*dto.ReportFileResponse, error)
+}
+
+type itemInventoryService struct {
+	config            *config.Config
+	itemInventoryRepo repository.ItemInventoryRepository
+	operationService  OperationService
+	fileStorage       storage.Storage
+	fileRepo          repository.ReportFileRepository
+	reportNamer       ReportNamer
+	reportLimiter     ReportLimiter
+	queryTimeouts     QueryTimeouts
+	sharePointClient  integration.SharePointClient
+	eventService      EventService
+	operationCode     string
+	logger            *slog.Logger
+}
+
+// NewItemInventoryService creates a new item inventory service
+func NewItemInventoryService(
+	config *config.Config,
+	itemInventoryRepo repository.ItemInventoryRepository,
+	operationService OperationService,
+	fileStorage storage.Storage,
+	fileRepo repository.ReportFileRepository,
+	reportNamer ReportNamer,
+	reportLimiter ReportLimiter,
+	queryTimeouts QueryTimeouts,
+	sharePointClient integration.SharePointClient,
+	eventService EventService,
+	logger *slog.Logger,
+) ItemInventoryService {
+	operationCode := config.Inventory.OperationCode
+	if operationCode == "" {
+		operationCode = "item_inventory"
+	}
+
+	return &itemInventoryService{
+		config:            config,
+		itemInventoryRepo: itemInventoryRepo,
+		operationService:  operationService,
+		fileStorage:       fileStorage,
+		fileRepo:          fileRepo,
+		reportNamer:       reportNamer,
+		reportLimiter:     reportLimiter,
+		queryTimeouts:     queryTimeouts,
+		sharePointClient:  sharePointClient,
+		eventService:      eventService,
+		operationCode:     operationCode,
+		logger:            logger,
+	}
+}
+
+// GetItemInventoryData retrieves the item inventory without generating a file
+func (s *itemInventoryService) GetItemInventoryData(
+	ctx context.Context,
+	userID int,
+	request *dto.ItemInventoryRequest,
+	ipAddress string,
+) ([]dto.ItemInventoryItem, error) {
+	s.logger.DebugContext(ctx, "GetItemInventoryData called", "user_id", userID, "request", request)
+
+	items, logID, err := s.queryItems(ctx, userID, request, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	s.updateLogStatus(ctx, logID, "success")
+	if items == nil {
+		return []dto.ItemInventoryItem{}, nil
+	}
+	return items, nil
+}
+
+// ExportItemInventory generates the item inventory Excel file
+func (s *itemInventoryService) ExportItemInventory(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	request *dto.ItemInventoryRequest,
+	ipAddress string,
+) (*dto.ReportFileResponse, error) {
+	s.logger.DebugContext(ctx, "ExportItemInventory called", "user_id", userID, "request", request)
+
+	items, logID, err := s.queryItems(ctx, userID, request, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkExportRows(len(items), s.reportLimiter.MaxRows(ctx, userID, "item_inventory")); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+
+	if len(items) == 0 {
+		s.updateLogStatus(ctx, logID, "success")
+		return nil, fmt.Errorf("%w to export for the specified date range", apperror.ErrNoData)
+	}
+
+	nameData := ReportNameData{
+		Report:       "item_inventory",
+		Name:         "Inventory",
+		FromDate:     *request.FromDate,
+		ToDate:       *request.ToDate,
+		UserID:       userID,
+		DepartmentID: departmentID,
+	}
+	title := s.reportNamer.Title(ctx, nameData)
+
+	headers, data := itemInventoryExportRows(items)
+
+	_, fileDetail, err := utils.ExportSheetsToExcel(ctx, title, utils.ExcelSheet{
+		Name:    "Sheet1",
+		Title:   title,
+		Headers: headers,
+		Data:    data,
+		ColumnTypes: map[string]utils.ExcelColumnType{
+			"item_code":   utils.ExcelText,
+			"opening_qty": utils.ExcelNumber,
+			"qty_in":      utils.ExcelNumber,
+			"qty_out":     utils.ExcelNumber,
+			"balance_qty": utils.ExcelNumber,
+		},
+	})
+	if err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, fmt.Errorf("error exporting to Excel: %w", err)
+	}
+	if err := checkExportSize("item_inventory", fileDetail.Len(), s.reportLimiter.MaxExportBytes("item_inventory")); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+
+	s.updateLogStatus(ctx, logID, "success")
+
+	fileName := s.reportNamer.FileName(ctx, nameData, title)
+	exportFile := &models.ReportFile{
+		FileName:    fileName,
+		Report:      "item_inventory",
+		UserID:      userID,
+		AccessLogID: logID,
+		RowCount:    len(items),
+	}
+	downloadURL := storeExportFile(ctx, s.logger, s.fileStorage, s.fileRepo, exportFile, fileDetail)
+	publishExportFile(ctx, s.logger, s.sharePointClient, "item_inventory", fileName, fileDetail)
+	s.eventService.Emit(ctx, events.ExportCompleted, events.ExportCompletedData{
+		Report:       "item_inventory",
+		FileName:     fileName,
+		RowCount:     len(items),
+		UserID:       userID,
+		DepartmentID: departmentID,
+		DownloadURL:  downloadURL,
+	})
+
+	return &dto.ReportFileResponse{
+		ReportName:  title,
+		FileName:    fileName,
+		FileDetal:   fileDetail,
+		DownloadURL: downloadURL,
+		Checksum:    exportFile.Checksum,
+		GeneratedAt: time.Now(),
+	}, nil
+}
+
+// queryItems resolves the date range in place, logs the access and runs the query.
+// The returned log ID is left pending for the caller to close.
+func (s *itemInventoryService) queryItems(
+	ctx context.Context,
+	userID int,
+	request *dto.ItemInventoryRequest,
+	ipAddress string,
+) ([]dto.ItemInventoryItem, int, error) {
+	fromDate, toDate, err := s.reportLimiter.DateRange("item_inventory").Resolve(&request.DateRangeRequest)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error resolving date range", "error", err)
+		return nil, 0, err
+	}
+	request.FromDate = &fromDate
+	request.ToDate = &toDate
+
+	logID, err := s.operationService.LogAccess(ctx, userID, s.operationCode, request, ipAddress)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error logging item inventory access", "error", err)
+	}
+
+	queryCtx, finishQuery := s.queryTimeouts.start(ctx, "item_inventory")
+	items, err := s.itemInventoryRepo.GetItemInventory(queryCtx, fromDate, toDate, request.ItemCode, request.WarehouseCode)
+	err = finishQuery(err)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error querying item inventory", "error", err)
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, 0, fmt.Errorf("error querying item inventory: %w", err)
+	}
+	if err := checkQueryRows("item_inventory", len(items), s.reportLimiter.QueryRows("item_inventory")); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, 0, err
+	}
+
+	return items, logID, nil
+}
+
+// itemInventoryExportRows maps inventory items to export headers and rows.
+func itemInventoryExportRows(items []dto.ItemInventoryItem) ([]string, []map[string]interface{}) {
+	headers := []string{
+		"item_code",
+		"item_name",
+		"unit",
+		"warehouse_code",
+		"warehouse_name",
+		"opening_qty",
+		"qty_in",
+		"qty_out",
+		"balance_qty",
+	}
+
+	data := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		data[i] = map[string]interface{}{
+			"item_code":      item.ItemCode,
+			"item_name":      item.ItemName,
+			"unit":           item.Unit,
+			"warehouse_code": item.WarehouseCode,
+			"warehouse_name": item.WarehouseName,
+			"opening_qty":    item.OpeningQty,
+			"qty_in":         item.QtyIn,
+			"qty_out":        item.QtyOut,
+			"balance_qty":    item.BalanceQty,
+		}
+	}
+
+	return headers, data
+}
+
+// updateLogStatus updates the status of an access log.
+func (s *itemInventoryService) updateLogStatus(ctx context.Context, logID int, status string) {
+	if logID <= 0 {
+		return
+	}
+
+	ctx, status = logStatusContext(ctx, status)
+	if _, err := s.operationService.UpdateLogStatus(ctx, logID, status); err != nil {
+		s.logger.ErrorContext(ctx, "Error updating log status", "log_id", logID, "error", err)
+	}
+}
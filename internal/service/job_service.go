@@ -0,0 +1,382 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"erp-excel/config"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrJobNotFound is returned for an unknown job ID
+	ErrJobNotFound = apperror.NotFound("job not found")
+	// ErrJobNotRetryable is returned when retrying a job that has not failed or been cancelled
+	ErrJobNotRetryable = apperror.Conflict("only failed or cancelled jobs can be retried")
+	// ErrJobNotCancellable is returned when cancelling a job that already finished
+	ErrJobNotCancellable = apperror.Conflict("only pending or running jobs can be cancelled")
+	// ErrUnknownJobType is returned when queueing a job of a type no handler is registered for
+	ErrUnknownJobType = apperror.Validation("unknown job type")
+
+	// ErrJobPermanent marks a job error that retrying cannot fix; handlers wrap it to fail the job
+	// at once
+	ErrJobPermanent = errors.New("permanent job failure")
+)
+
+const (
+	defaultJobListLimit = 100
+	maxJobListLimit     = 1000
+)
+
+// JobHandler runs one attempt of a job. The context is cancelled when the attempt times out, the
+// job is cancelled or the shutdown drain time runs out. An error fails the attempt, which is
+// retried with backoff unless it wraps ErrJobPermanent or the job is out of attempts.
+type JobHandler func(ctx context.Context, job *models.Job) error
+
+// JobOptions changes how a job is queued
+type JobOptions struct {
+	RunAt       time.Time // first due time, at once when zero
+	MaxAttempts int       // the configured default when zero
+	CreatedBy   int       // user who queued the job, if any
+}
+
+// JobService is the background job engine. Services register a handler per job type and queue
+// jobs with a JSON payload; workers claim due jobs from the jobs table, so jobs survive restarts
+// and are shared among instances.
+type JobService interface {
+	Register(jobType string, handler JobHandler)
+	Enqueue(ctx context.Context, jobType string, payload interface{}, opts *JobOptions) (*models.Job, error)
+	List(ctx context.Context, filter repository.JobFilter) ([]*models.Job, error)
+	Get(ctx context.Context, id int64) (*models.Job, error)
+	Retry(ctx context.Context, id int64) error
+	Cancel(ctx context.Context, id int64) error
+	Start(ctx context.Context)
+	// Stop waits for the running jobs once the context given to Start is cancelled. Jobs still
+	// running when ctx is done are cancelled and retried later.
+	Stop(ctx context.Context)
+}
+
+type jobService struct {
+	config  config.JobsConfig
+	jobRepo repository.JobRepository
+	workers workerGroup
+	logger  *slog.Logger
+
+	mu       sync.RWMutex
+	handlers map[string]JobHandler
+	running  map[int64]context.CancelFunc // attempts running on this instance
+}
+
+// NewJobService creates a new job service
+func NewJobService(cfg config.JobsConfig, jobRepo repository.JobRepository, logger *slog.Logger) JobService {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 2
+	}
+	if cfg.PollIntervalSeconds <= 0 {
+		cfg.PollIntervalSeconds = 5
+	}
+	if cfg.TimeoutSeconds <= 0 {
+		cfg.TimeoutSeconds = 600
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.RetryBaseSeconds <= 0 {
+		cfg.RetryBaseSeconds = 30
+	}
+	if cfg.RetryMaxSeconds <= 0 {
+		cfg.RetryMaxSeconds = 3600
+	}
+	if cfg.RetentionDays <= 0 {
+		cfg.RetentionDays = 30
+	}
+
+	return &jobService{
+		config:   cfg,
+		jobRepo:  jobRepo,
+		logger:   logger,
+		handlers: make(map[string]JobHandler),
+		running:  make(map[int64]context.CancelFunc),
+	}
+}
+
+// Register sets the handler of a job type. Workers only claim jobs of registered types, so
+// instances running an older build leave the jobs they cannot run to the others.
+func (s *jobService) Register(jobType string, handler JobHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[jobType] = handler
+}
+
+// handler gets the handler of a job type
+func (s *jobService) handler(jobType string) (JobHandler, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	handler, ok := s.handlers[jobType]
+	return handler, ok
+}
+
+// types lists the registered job types
+func (s *jobService) types() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	types := make([]string, 0, len(s.handlers))
+	for jobType := range s.handlers {
+		types = append(types, jobType)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// Enqueue queues a job of a registered type with the payload marshalled to JSON
+func (s *jobService) Enqueue(ctx context.Context, jobType string, payload interface{}, opts *JobOptions) (*models.Job, error) {
+	if _, ok := s.handler(jobType); !ok {
+		return nil, fmt.Errorf("%w: no handler is registered for job type %q", ErrUnknownJobType, jobType)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling job payload: %w", err)
+	}
+
+	job := &models.Job{
+		Type:        jobType,
+		Payload:     string(data),
+		MaxAttempts: s.config.MaxAttempts,
+	}
+	if opts != nil {
+		job.RunAt = opts.RunAt
+		job.CreatedBy = opts.CreatedBy
+		if opts.MaxAttempts > 0 {
+			job.MaxAttempts = opts.MaxAttempts
+		}
+	}
+
+	if _, err := s.jobRepo.Create(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// List gets the newest jobs matching the filter
+func (s *jobService) List(ctx context.Context, filter repository.JobFilter) ([]*models.Job, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultJobListLimit
+	}
+	if filter.Limit > maxJobListLimit {
+		filter.Limit = maxJobListLimit
+	}
+
+	return s.jobRepo.List(ctx, filter)
+}
+
+// Get gets a job
+func (s *jobService) Get(ctx context.Context, id int64) (*models.Job, error) {
+	job, err := s.jobRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+// Retry queues a failed or cancelled job again with a fresh count of attempts
+func (s *jobService) Retry(ctx context.Context, id int64) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+
+	retried, err := s.jobRepo.Retry(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !retried {
+		return ErrJobNotRetryable
+	}
+	return nil
+}
+
+// Cancel cancels a pending or running job. An attempt running on this instance is interrupted;
+// one running on another instance finishes, but its outcome is not recorded.
+func (s *jobService) Cancel(ctx context.Context, id int64) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+
+	cancelled, err := s.jobRepo.Cancel(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return ErrJobNotCancellable
+	}
+
+	s.mu.RLock()
+	cancel := s.running[id]
+	s.mu.RUnlock()
+	if cancel != nil {
+		cancel()
+	}
+	return nil
+}
+
+// Start runs the configured number of workers until the context is cancelled
+func (s *jobService) Start(ctx context.Context) {
+	if !s.config.Enabled {
+		return
+	}
+
+	interval := time.Duration(s.config.PollIntervalSeconds) * time.Second
+
+	// Cancelling ctx stops claiming jobs; the running ones finish on workCtx
+	workCtx := s.workers.start(ctx)
+	for i := 0; i < s.config.Workers; i++ {
+		s.workers.run(func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				// Keep claiming while there is work, then wait for the next poll
+				for s.runNext(ctx, workCtx) {
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		})
+	}
+
+	// Finished jobs would otherwise pile up forever
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			s.pruneJobs(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	s.logger.InfoContext(ctx, "Job workers started", "workers", s.config.Workers, "interval", interval.String(), "types", s.types())
+}
+
+func (s *jobService) Stop(ctx context.Context) {
+	s.workers.stop(ctx)
+}
+
+// runNext claims a job while ctx is not cancelled and runs it on workCtx, reporting whether
+// there was one
+func (s *jobService) runNext(ctx context.Context, workCtx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	// The lease outlasts the attempt, so the job is only claimed again if this worker died
+	timeout := time.Duration(s.config.TimeoutSeconds) * time.Second
+	job, err := s.jobRepo.Claim(ctx, s.types(), timeout+time.Minute)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error claiming job", "error", err)
+		return false
+	}
+	if job == nil {
+		return false
+	}
+
+	jobCtx, cancel := context.WithTimeout(workCtx, timeout)
+	s.mu.Lock()
+	s.running[job.ID] = cancel
+	s.mu.Unlock()
+
+	start := time.Now()
+	err = s.run(jobCtx, job)
+
+	s.mu.Lock()
+	delete(s.running, job.ID)
+	s.mu.Unlock()
+	cancel()
+
+	// Record the outcome even when the worker is being stopped
+	recordCtx, cancelRecord := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelRecord()
+
+	if err != nil {
+		s.fail(recordCtx, job, err)
+		return true
+	}
+
+	if err := s.jobRepo.Complete(recordCtx, job.ID); err != nil {
+		s.logger.ErrorContext(ctx, "Error updating job", "job_id", job.ID, "error", err)
+		return true
+	}
+	s.logger.InfoContext(ctx, "Job completed", "job_id", job.ID, "type", job.Type, "attempt", job.Attempts, "duration", time.Since(start).String())
+	return true
+}
+
+// run runs an attempt of a job with its handler, turning a panic into an error so it fails the
+// attempt rather than the worker
+func (s *jobService) run(ctx context.Context, job *models.Job) (err error) {
+	handler, ok := s.handler(job.Type)
+	if !ok {
+		return fmt.Errorf("%w: no handler is registered for job type %q", ErrJobPermanent, job.Type)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job handler panicked: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// fail records a failed attempt, scheduling the next one after a delay doubling with each attempt,
+// or failing the job once it is out of attempts or the error is permanent
+func (s *jobService) fail(ctx context.Context, job *models.Job, jobErr error) {
+	var runAt *time.Time
+	if !errors.Is(jobErr, ErrJobPermanent) && job.Attempts < job.MaxAttempts {
+		next := time.Now().Add(retryDelay(job.Attempts, s.config.RetryBaseSeconds, s.config.RetryMaxSeconds))
+		runAt = &next
+	}
+
+	s.logger.WarnContext(ctx, "Job failed",
+		"job_id", job.ID,
+		"type", job.Type,
+		"attempt", job.Attempts,
+		"retry", runAt != nil,
+		"error", jobErr,
+	)
+
+	if err := s.jobRepo.Fail(ctx, job.ID, jobErr.Error(), runAt); err != nil {
+		s.logger.ErrorContext(ctx, "Error updating job", "job_id", job.ID, "error", err)
+	}
+}
+
+// pruneJobs removes the finished jobs older than the retention
+func (s *jobService) pruneJobs(ctx context.Context) {
+	before := time.Now().AddDate(0, 0, -s.config.RetentionDays)
+	removed, err := s.jobRepo.DeleteFinishedBefore(ctx, before)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error pruning jobs", "error", err)
+		return
+	}
+	if removed > 0 {
+		s.logger.InfoContext(ctx, "Finished jobs pruned", "removed", removed)
+	}
+}
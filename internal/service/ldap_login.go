@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"erp-excel/internal/ldap"
+	"erp-excel/internal/models"
+	"errors"
+	"strings"
+)
+
+// directoryLogin checks the password against the directory and returns the local account of the
+// user, provisioning it on the first login when enabled. existing is the local account with the
+// same username, nil when there is none.
+func (s *authService) directoryLogin(ctx context.Context, username, password string, existing *models.User) (*models.User, error) {
+	entry, err := s.directory.Authenticate(ctx, username, password)
+	if err != nil {
+		if errors.Is(err, ldap.ErrInvalidCredentials) {
+			return nil, errors.New("invalid username or password")
+		}
+		s.logger.ErrorContext(ctx, "Error authenticating with the directory", "username", username, "error", err)
+		return nil, errors.New("the directory is unavailable, try again later")
+	}
+
+	user := existing
+	if user == nil {
+		if !s.config.LDAP.AutoProvision {
+			return nil, errors.New("user " + username + " is not registered")
+		}
+		if user, err = s.provisionDirectoryUser(ctx, username, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.config.LDAP.GroupAttribute != "" {
+		groups := entry.Values(s.config.LDAP.GroupAttribute)
+		if err := syncMappedRoles(ctx, s.userRepo, user.ID, s.config.LDAP.RoleMapping, groups); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// provisionDirectoryUser creates the local account of a first-time directory user, in the
+// department of the first of the user's groups that is mapped to one
+func (s *authService) provisionDirectoryUser(ctx context.Context, username string, entry *ldap.Entry) (*models.User, error) {
+	cfg := s.config.LDAP
+	departmentID := cfg.DefaultDepartmentID
+	if cfg.GroupAttribute != "" {
+		for _, group := range entry.Values(cfg.GroupAttribute) {
+			if mapped, ok := cfg.DepartmentMapping[strings.ToLower(strings.TrimSpace(group))]; ok {
+				departmentID = mapped
+				break
+			}
+		}
+	}
+	if departmentID == 0 {
+		return nil, errors.New("ldap default department is not configured")
+	}
+
+	fullName := username
+	if cfg.FullNameAttribute != "" {
+		if value := entry.Attribute(cfg.FullNameAttribute); value != "" {
+			fullName = value
+		}
+	}
+
+	user := &models.User{
+		Username:     username,
+		FullName:     fullName,
+		DepartmentID: departmentID,
+	}
+	if cfg.EmailAttribute != "" {
+		user.Email = entry.Attribute(cfg.EmailAttribute)
+	}
+
+	return provisionExternalUser(ctx, s.userRepo, s.eventService, s.logger, user, "ldap")
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/repository"
+	"log/slog"
+)
+
+// MenuService builds the navigation menu the current user may see from their operations
+type MenuService interface {
+	GetMenu(ctx context.Context, userID int, isAdmin bool) ([]*dto.MenuItem, error)
+}
+
+// menuEntry is an entry of the menu tree. An entry without an operation code is shown to every
+// signed-in user.
+type menuEntry struct {
+	key           string
+	title         string
+	path          string
+	operationCode string
+	children      []menuEntry
+	reports       bool // the group also lists the generic reports the user may run
+}
+
+type menuService struct {
+	entries             []menuEntry
+	roleRepo            repository.RoleRepository
+	reportEngineService ReportEngineService
+	logger              *slog.Logger
+}
+
+// NewMenuService creates a new menu service. The operation codes mirror the ones guarding the routes.
+func NewMenuService(cfg *config.Config, roleRepo repository.RoleRepository, reportEngineService ReportEngineService, logger *slog.Logger) MenuService {
+	operationCode := func(code, fallback string) string {
+		if code == "" {
+			return fallback
+		}
+		return code
+	}
+
+	reports := []menuEntry{
+		{key: "inventory", title: "Inventory (Assistant 230)", path: "/reports/inventory", operationCode: "reports:view:230"},
+		{key: "assistant610", title: "Receivables (Assistant 610)", path: "/reports/assistant610", operationCode: "reports:view:610"},
+		{key: "assistant340", title: "Purchases (Assistant 340)", path: "/reports/assistant340", operationCode: "reports:view:340"},
+		{key: "reconciliation", title: "Reconciliation", path: "/reports/reconciliation", operationCode: "reports:view:reconciliation"},
+		{key: "item_inventory", title: "Item Inventory", path: "/reports/items", operationCode: operationCode(cfg.Inventory.OperationCode, "item_inventory")},
+		{key: "stock_balance", title: "Stock Balance", path: "/reports/stock-balance", operationCode: operationCode(cfg.Inventory.StockOperationCode, "stock_balance")},
+		{key: "snapshots", title: "Snapshots", path: "/snapshots", operationCode: operationCode(cfg.Snapshots.OperationCode, "report_snapshots")},
+		{key: "schedules", title: "Scheduled Reports", path: "/reports/schedules", operationCode: operationCode(cfg.Schedules.OperationCode, "report_schedules")},
+	}
+
+	admin := []menuEntry{
+		{key: "dashboard", title: "Dashboard", path: "/admin/dashboard", operationCode: "admin:dashboard"},
+		{key: "users", title: "Users", path: "/users", operationCode: "users:read"},
+		{key: "departments", title: "Departments", path: "/departments", operationCode: "departments:read"},
+		{key: "roles", title: "Roles", path: "/roles", operationCode: "roles:read"},
+		{key: "report_definitions", title: "Report Definitions", path: "/admin/reports", operationCode: operationCode(cfg.Reports.AdminOperationCode, "report_admin")},
+		{key: "exchange_rates", title: "Exchange Rates", path: "/exchange-rates", operationCode: operationCode(cfg.Currency.OperationCode, "exchange_rates")},
+		{key: "translations", title: "Translations", path: "/admin/translations", operationCode: operationCode(cfg.Translations.OperationCode, "translations")},
+		{key: "downloads", title: "Generated Files", path: "/admin/downloads", operationCode: operationCode(cfg.Downloads.OperationCode, "downloads_admin")},
+		{key: "config_backup", title: "Backup & Restore", path: "/admin/config", operationCode: operationCode(cfg.Backup.OperationCode, "config_backup")},
+		{key: "audit_logs", title: "Audit Trail", path: "/admin/audit-logs", operationCode: operationCode(cfg.Audit.OperationCode, "audit_logs")},
+		{key: "schema_check", title: "Schema Check", path: "/admin/schema/check", operationCode: operationCode(cfg.Preflight.OperationCode, "schema_check")},
+		{key: "erp_sync", title: "ERP Sync", path: "/admin/erp-sync", operationCode: "erp_sync:read"},
+		{key: "imports", title: "ERP Corrections", path: "/imports", operationCode: operationCode(cfg.Imports.OperationCode, "data_import")},
+	}
+	if len(cfg.ExportApproval.Operations) > 0 {
+		admin = append(admin, menuEntry{key: "export_approvals", title: "Export Approvals", path: "/export-approvals", operationCode: operationCode(cfg.ExportApproval.ApproverOperationCode, "export_approvals")})
+	}
+	if cfg.ERPWriteBack.Enabled {
+		admin = append(admin, menuEntry{key: "erp_writeback", title: "ERP Write-back", path: "/erp/writeback", operationCode: operationCode(cfg.ERPWriteBack.OperationCode, "erp_writeback")})
+	}
+
+	return &menuService{
+		entries: []menuEntry{
+			{key: "reports", title: "Reports", children: reports, reports: true},
+			{key: "admin", title: "Administration", children: admin},
+		},
+		roleRepo:            roleRepo,
+		reportEngineService: reportEngineService,
+		logger:              logger,
+	}
+}
+
+// GetMenu returns the menu tree filtered by the user's operations; empty groups are left out
+func (s *menuService) GetMenu(ctx context.Context, userID int, isAdmin bool) ([]*dto.MenuItem, error) {
+	granted := make(map[string]bool)
+	if !isAdmin {
+		codes, err := s.roleRepo.GetUserOperationCodes(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, code := range codes {
+			granted[code] = true
+		}
+	}
+
+	visible := func(entry menuEntry) bool {
+		if isAdmin {
+			return true
+		}
+		return entry.operationCode == "" || granted[entry.operationCode]
+	}
+
+	menu := make([]*dto.MenuItem, 0, len(s.entries))
+	for _, group := range s.entries {
+		if !visible(group) {
+			continue
+		}
+
+		item := &dto.MenuItem{Key: group.key, Title: group.title, Path: group.path}
+		for _, child := range group.children {
+			if visible(child) {
+				item.Children = append(item.Children, &dto.MenuItem{Key: child.key, Title: child.title, Path: child.path})
+			}
+		}
+
+		if group.reports {
+			reports, err := s.reportEngineService.ListReports(ctx, userID, isAdmin)
+			if err != nil {
+				// The built-in reports are still usable without the generic ones
+				s.logger.ErrorContext(ctx, "Error listing reports for the menu", "error", err)
+			}
+			for _, report := range reports {
+				item.Children = append(item.Children, &dto.MenuItem{
+					Key:   "report_" + report.Code,
+					Title: report.Name,
+					Path:  "/reports/" + report.Code,
+				})
+			}
+		}
+
+		if len(item.Children) == 0 && item.Path == "" {
+			continue
+		}
+		menu = append(menu, item)
+	}
+
+	return menu, nil
+}
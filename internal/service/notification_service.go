@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/config"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/integration"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ErrNotificationNotFound is returned for an unknown notification or a notification of another user
+var ErrNotificationNotFound = apperror.NotFound("notification not found")
+
+// NotificationService keeps the in-app notifications of users, optionally emailing them too,
+// and reminds users of passwords about to expire
+type NotificationService interface {
+	Notify(ctx context.Context, notification *models.Notification)
+	List(ctx context.Context, userID int, unreadOnly bool, limit, offset int) ([]*models.Notification, error)
+	Count(ctx context.Context, userID int, unreadOnly bool) (int, error)
+	MarkRead(ctx context.Context, userID int, id int) error
+	MarkAllRead(ctx context.Context, userID int) (int, error)
+	Start(ctx context.Context)
+}
+
+type notificationService struct {
+	config           config.NotificationsConfig
+	notificationRepo repository.NotificationRepository
+	userRepo         repository.UserRepository
+	mailer           integration.Mailer
+	logger           *slog.Logger
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(
+	cfg config.NotificationsConfig,
+	notificationRepo repository.NotificationRepository,
+	userRepo repository.UserRepository,
+	mailer integration.Mailer,
+	logger *slog.Logger,
+) NotificationService {
+	if cfg.PasswordWarnDays <= 0 {
+		cfg.PasswordWarnDays = 7
+	}
+	if cfg.IntervalSeconds <= 0 {
+		cfg.IntervalSeconds = 3600
+	}
+
+	return &notificationService{
+		config:           cfg,
+		notificationRepo: notificationRepo,
+		userRepo:         userRepo,
+		mailer:           mailer,
+		logger:           logger,
+	}
+}
+
+// Notify stores a notification for its user and emails it in the background when enabled.
+// Failures are logged only, so a notification never fails the operation it is about.
+func (s *notificationService) Notify(ctx context.Context, notification *models.Notification) {
+	if notification.UserID <= 0 {
+		return
+	}
+
+	if _, err := s.notificationRepo.Create(ctx, notification); err != nil {
+		s.logger.ErrorContext(ctx, "Error storing notification", "type", notification.Type, "user_id", notification.UserID, "error", err)
+		return
+	}
+
+	if !s.config.Email || !s.mailer.Enabled() {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+		defer cancel()
+
+		user, err := s.userRepo.GetByID(ctx, notification.UserID)
+		if err != nil || user.Email == "" {
+			return
+		}
+
+		message := &integration.MailMessage{
+			To:      []string{user.Email},
+			Subject: notification.Title,
+			Body:    notification.Message + "\n",
+		}
+		if err := s.mailer.Send(ctx, message); err != nil {
+			s.logger.ErrorContext(ctx, "Error emailing notification", "notification_id", notification.ID, "error", err)
+		}
+	}()
+}
+
+// List gets a page of the user's notifications, newest first
+func (s *notificationService) List(ctx context.Context, userID int, unreadOnly bool, limit, offset int) ([]*models.Notification, error) {
+	notifications, err := s.notificationRepo.List(ctx, userID, unreadOnly, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	if notifications == nil {
+		notifications = []*models.Notification{}
+	}
+	return notifications, nil
+}
+
+// Count gets the number of the user's notifications, or of the unread ones
+func (s *notificationService) Count(ctx context.Context, userID int, unreadOnly bool) (int, error) {
+	return s.notificationRepo.Count(ctx, userID, unreadOnly)
+}
+
+// MarkRead marks a notification of the user as read
+func (s *notificationService) MarkRead(ctx context.Context, userID int, id int) error {
+	if err := s.notificationRepo.MarkRead(ctx, userID, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotificationNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// MarkAllRead marks every notification of the user as read and returns how many were unread
+func (s *notificationService) MarkAllRead(ctx context.Context, userID int) (int, error) {
+	return s.notificationRepo.MarkAllRead(ctx, userID)
+}
+
+// Start prepares the notification table and, when passwords expire, checks password ages
+// periodically until the context is cancelled
+func (s *notificationService) Start(ctx context.Context) {
+	if s.config.PasswordMaxAgeDays <= 0 {
+		return
+	}
+
+	interval := time.Duration(s.config.IntervalSeconds) * time.Second
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.remindPasswordExpiry(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	s.logger.InfoContext(ctx, "Password expiry reminders started", "max_age_days", s.config.PasswordMaxAgeDays, "interval", interval.String())
+}
+
+// remindPasswordExpiry notifies the users whose password expires within the warning period,
+// once per password
+func (s *notificationService) remindPasswordExpiry(ctx context.Context) {
+	maxAge := time.Duration(s.config.PasswordMaxAgeDays) * 24 * time.Hour
+	warnBefore := time.Duration(s.config.PasswordWarnDays) * 24 * time.Hour
+
+	users, err := s.userRepo.ListPasswordsChangedBefore(ctx, time.Now().Add(warnBefore-maxAge))
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error reading password ages", "error", err)
+		return
+	}
+
+	for _, user := range users {
+		reminded, err := s.notificationRepo.ExistsSince(ctx, user.ID, models.NotificationPasswordExpiring, user.PasswordChangedAt)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "Error reading password expiry reminders", "user_id", user.ID, "error", err)
+			return
+		}
+		if reminded {
+			continue
+		}
+
+		expiresAt := user.PasswordChangedAt.Add(maxAge)
+		message := fmt.Sprintf("Your password expires on %s. Change it before then.", expiresAt.Format("2006-01-02"))
+		if !expiresAt.After(time.Now()) {
+			message = fmt.Sprintf("Your password expired on %s. Change it as soon as possible.", expiresAt.Format("2006-01-02"))
+		}
+		s.Notify(ctx, &models.Notification{
+			UserID:  user.ID,
+			Type:    models.NotificationPasswordExpiring,
+			Title:   "Password expires soon",
+			Message: message,
+			Link:    "/api/users/password",
+		})
+	}
+}
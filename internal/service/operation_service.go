@@ -14,27 +14,34 @@ import (
 type OperationService interface {
 	GetAllOperations(ctx context.Context) ([]*dto.OperationResponse, error)
 	CheckUserAccess(ctx context.Context, userID int, operationCode string) (bool, error)
+	IsAdmin(ctx context.Context, userID int) (bool, error)
 	LogAccess(ctx context.Context, userID int, operationCode string, params interface{}, ipAddress string) (int, error)
 	UpdateLogStatus(ctx context.Context, logID int, status string) (bool, error)
 	GetRecentLogs(ctx context.Context, limit int) ([]*models.AccessLog, error)
+	EnsureOperations(ctx context.Context, operations []*models.Operation) error
+	GetUserPermissions(ctx context.Context, userID int, isAdmin bool) ([]*dto.PermissionResponse, error)
 }
 
 type operationService struct {
-	operationRepo repository.OperationRepository
-	userRepo      repository.UserRepository
-	roleRepo      repository.RoleRepository
+	operationRepo  repository.OperationRepository
+	userRepo       repository.UserRepository
+	roleRepo       repository.RoleRepository
+	adminOperation string
 }
 
-// NewOperationService creates a new operation service
+// NewOperationService creates a new operation service. Users whose roles have the admin
+// operation are administrators.
 func NewOperationService(
 	operationRepo repository.OperationRepository,
 	userRepo repository.UserRepository,
 	roleRepo repository.RoleRepository,
+	adminOperation string,
 ) OperationService {
 	return &operationService{
-		operationRepo: operationRepo,
-		userRepo:      userRepo,
-		roleRepo:      roleRepo,
+		operationRepo:  operationRepo,
+		userRepo:       userRepo,
+		roleRepo:       roleRepo,
+		adminOperation: adminOperation,
 	}
 }
 
@@ -55,6 +62,11 @@ func (s *operationService) CheckUserAccess(ctx context.Context, userID int, oper
 	return s.roleRepo.CheckUserOperationAccess(ctx, userID, operation.ID)
 }
 
+// IsAdmin reports whether one of the user's roles has the admin operation
+func (s *operationService) IsAdmin(ctx context.Context, userID int) (bool, error) {
+	return s.CheckUserAccess(ctx, userID, s.adminOperation)
+}
+
 // LogAccess logs access to an operation
 func (s *operationService) LogAccess(
 	ctx context.Context,
@@ -110,3 +122,38 @@ func (s *operationService) GetRecentLogs(ctx context.Context, limit int) ([]*mod
 
 	return s.operationRepo.GetRecentLogs(ctx, limit)
 }
+
+// EnsureOperations registers the operations the routes are guarded with
+func (s *operationService) EnsureOperations(ctx context.Context, operations []*models.Operation) error {
+	return s.operationRepo.EnsureOperations(ctx, operations)
+}
+
+// GetUserPermissions lists every operation with whether the user may perform it, so the frontend
+// can hide the actions the API would refuse. Admins may perform all of them.
+func (s *operationService) GetUserPermissions(ctx context.Context, userID int, isAdmin bool) ([]*dto.PermissionResponse, error) {
+	operations, err := s.operationRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	granted := make(map[string]bool)
+	if !isAdmin {
+		codes, err := s.roleRepo.GetUserOperationCodes(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, code := range codes {
+			granted[code] = true
+		}
+	}
+
+	permissions := make([]*dto.PermissionResponse, 0, len(operations))
+	for _, operation := range operations {
+		permissions = append(permissions, &dto.PermissionResponse{
+			Code:    operation.Code,
+			Name:    operation.Name,
+			Allowed: isAdmin || granted[operation.Code],
+		})
+	}
+	return permissions, nil
+}
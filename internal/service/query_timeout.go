@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/database"
+	"erp-excel/internal/apperror"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultQueryTimeout bounds the ERP queries of the built-in reports unless configured
+const defaultQueryTimeout = 2 * time.Minute
+
+var (
+	// ErrQueryCancelled is returned when the request of an ERP query was cancelled, usually
+	// because the client went away
+	ErrQueryCancelled = apperror.New(apperror.CodeQueryCancelled, apperror.StatusClientClosedRequest, "Request cancelled", "report query cancelled")
+	// ErrQueryTimeout is returned when an ERP query ran longer than the timeout of its report
+	ErrQueryTimeout = apperror.New(apperror.CodeQueryTimeout, http.StatusGatewayTimeout, "Report timed out", "report query timed out").
+			WithDetail("The report took too long to run; narrow the date range or filters and try again")
+)
+
+// QueryTimeouts bounds how long the ERP queries of the built-in reports may run, and how many of
+// them run at once through the throttle
+type QueryTimeouts struct {
+	defaultTimeout time.Duration
+	reports        map[string]time.Duration
+	throttle       *ERPThrottle
+}
+
+// NewQueryTimeouts reads the query timeouts of the built-in reports
+func NewQueryTimeouts(cfg config.ReportsConfig, throttle *ERPThrottle) QueryTimeouts {
+	timeouts := QueryTimeouts{
+		defaultTimeout: time.Duration(cfg.QueryTimeoutSeconds) * time.Second,
+		reports:        make(map[string]time.Duration, len(cfg.QueryTimeouts)),
+		throttle:       throttle,
+	}
+	if timeouts.defaultTimeout <= 0 {
+		timeouts.defaultTimeout = defaultQueryTimeout
+	}
+
+	// Viper lower-cases map keys, so report codes are matched case-insensitively
+	for report, seconds := range cfg.QueryTimeouts {
+		if seconds > 0 {
+			timeouts.reports[strings.ToLower(report)] = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return timeouts
+}
+
+// Timeout returns how long the ERP query of a report may run
+func (t QueryTimeouts) Timeout(report string) time.Duration {
+	if timeout, ok := t.reports[strings.ToLower(report)]; ok {
+		return timeout
+	}
+	if t.defaultTimeout <= 0 {
+		return defaultQueryTimeout
+	}
+	return t.defaultTimeout
+}
+
+// start waits for the turn of an ERP query of the report and returns the context it runs under
+// and a function to call with the query error. It ends the turn, releases the context and turns
+// an error caused by the request being cancelled or by the timeout into ErrQueryCancelled or
+// ErrQueryTimeout. When no turn comes up the context is already cancelled, so the query fails at
+// once, and the function returns why, such as the 503 of a busy ERP.
+func (t QueryTimeouts) start(ctx context.Context, report string) (context.Context, func(error) error) {
+	release, err := t.throttle.Acquire(ctx, report)
+	if err != nil {
+		queryCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		return queryCtx, func(error) error {
+			return err
+		}
+	}
+
+	timeout := t.Timeout(report)
+	queryCtx, cancel := context.WithTimeout(database.WithQueryLabel(ctx, report), timeout)
+
+	return queryCtx, func(err error) error {
+		defer release()
+		defer cancel()
+		return queryError(ctx, queryCtx, timeout, err)
+	}
+}
+
+// queryError classifies the error of a query run on queryCtx, derived from the request ctx
+func queryError(ctx context.Context, queryCtx context.Context, timeout time.Duration, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("%w: %v", ErrQueryCancelled, err)
+	case queryCtx.Err() != nil:
+		return fmt.Errorf("%w after %s: %v", ErrQueryTimeout, timeout, err)
+	}
+	return err
+}
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/events"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/storage"
+	"erp-excel/internal/translate"
+	"erp-excel/internal/utils"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// defaultReconciliationTolerance absorbs rounding differences between shipping and AR amounts
+const defaultReconciliationTolerance = 1
+
+// Fill colors used to highlight mismatches in the exported file
+var reconciliationColors = map[string]string{
+	dto.ReconciliationShippedUninvoiced: "FFC7CE",
+	dto.ReconciliationAmountMismatch:    "FFEB9C",
+}
+
+// ReconciliationService cross-matches the 230 shipping documents against the 610 AR documents
+type ReconciliationService interface {
+	GetReconciliation(ctx context.Context, userID int, request *dto.ReconciliationRequest) (*dto.ReconciliationResponse, error)
+	ExportReconciliation(ctx context.Context, userID int, departmentID int, request *dto.ReconciliationRequest) (*dto.ReportFileResponse, error)
+}
+
+type reconciliationService struct {
+	operationRepo      repository.OperationRepository
+	reconciliationRepo repository.ReconciliationRepository
+	rowPolicies        RowPolicyService
+	fileStorage        storage.Storage
+	fileRepo           repository.ReportFileRepository
+	reportNamer        ReportNamer
+	reportLimiter      ReportLimiter
+	queryTimeouts      QueryTimeouts
+	eventService       EventService
+	logger             *slog.Logger
+}
+
+// NewReconciliationService creates a new reconciliation service
+func NewReconciliationService(
+	operationRepo repository.OperationRepository,
+	reconciliationRepo repository.ReconciliationRepository,
+	rowPolicies RowPolicyService,
+	fileStorage storage.Storage,
+	fileRepo repository.ReportFileRepository,
+	reportNamer ReportNamer,
+	reportLimiter ReportLimiter,
+	queryTimeouts QueryTimeouts,
+	eventService EventService,
+	logger *slog.Logger,
+) ReconciliationService {
+	return &reconciliationService{
+		operationRepo:      operationRepo,
+		reconciliationRepo: reconciliationRepo,
+		rowPolicies:        rowPolicies,
+		fileStorage:        fileStorage,
+		fileRepo:           fileRepo,
+		reportNamer:        reportNamer,
+		reportLimiter:      reportLimiter,
+		queryTimeouts:      queryTimeouts,
+		eventService:       eventService,
+		logger:             logger,
+	}
+}
+
+// GetReconciliation returns the mismatches for the period, and the matched documents when requested
+func (s *reconciliationService) GetReconciliation(
+	ctx context.Context,
+	userID int,
+	request *dto.ReconciliationRequest,
+) (*dto.ReconciliationResponse, error) {
+	response, _, _, err := s.reconcile(ctx, userID, 0, request, 1)
+	return response, err
+}
+
+// ExportReconciliation exports the reconciliation to Excel, highlighting each mismatch by type
+func (s *reconciliationService) ExportReconciliation(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	request *dto.ReconciliationRequest,
+) (*dto.ReportFileResponse, error) {
+	response, nameData, logID, err := s.reconcile(ctx, userID, departmentID, request, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkExportRows(len(response.Items), s.reportLimiter.MaxRows(ctx, userID, "reconciliation")); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+
+	if len(response.Items) == 0 {
+		return nil, fmt.Errorf("%w to export for the specified date range", apperror.ErrNoData)
+	}
+
+	headers, data, colors := reconciliationExportRows(ctx, response.Items)
+
+	_, fileDetail, err := utils.ExportSheetsToExcel(ctx, response.ReportName, utils.ExcelSheet{
+		Name:      "Sheet1",
+		Title:     response.ReportName,
+		Headers:   headers,
+		Data:      data,
+		RowColors: colors,
+		ColumnTypes: map[string]utils.ExcelColumnType{
+			"doc_date":       utils.ExcelDate,
+			"shipped_amt":    utils.ExcelCurrency,
+			"invoiced_amt":   utils.ExcelCurrency,
+			"ar_shipped_amt": utils.ExcelCurrency,
+			"difference":     utils.ExcelCurrency,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error exporting to Excel: %w", err)
+	}
+	if err := checkExportSize("reconciliation", fileDetail.Len(), s.reportLimiter.MaxExportBytes("reconciliation")); err != nil {
+		return nil, err
+	}
+
+	fileName := s.reportNamer.FileName(ctx, nameData, response.ReportName)
+	exportFile := &models.ReportFile{
+		FileName:    fileName,
+		Report:      "reconciliation",
+		UserID:      userID,
+		AccessLogID: logID,
+		RowCount:    len(response.Items),
+	}
+	downloadURL := storeExportFile(ctx, s.logger, s.fileStorage, s.fileRepo, exportFile, fileDetail)
+	s.eventService.Emit(ctx, events.ExportCompleted, events.ExportCompletedData{
+		Report:       "reconciliation",
+		FileName:     fileName,
+		RowCount:     len(response.Items),
+		UserID:       userID,
+		DepartmentID: departmentID,
+		DownloadURL:  downloadURL,
+	})
+
+	return &dto.ReportFileResponse{
+		ReportName:  response.ReportName,
+		FileName:    fileName,
+		FileDetal:   fileDetail,
+		DownloadURL: downloadURL,
+		Checksum:    exportFile.Checksum,
+		GeneratedAt: time.Now(),
+	}, nil
+}
+
+// reconcile runs the query and classifies each shipping document, logging the access under operationID.
+// Only the shipping documents the 230 and 610 row policies of the user allow are reconciled.
+// It returns the values used to name the report and the access log ID alongside the result.
+func (s *reconciliationService) reconcile(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	request *dto.ReconciliationRequest,
+	operationID int,
+) (*dto.ReconciliationResponse, ReportNameData, int, error) {
+	s.logger.DebugContext(ctx, "Reconciliation called", "user_id", userID, "request", request)
+
+	nameData := ReportNameData{
+		Report:       "reconciliation",
+		Name:         "Reconciliation 230/610",
+		UserID:       userID,
+		DepartmentID: departmentID,
+	}
+
+	fromDate, toDate, err := s.reportLimiter.DateRange("reconciliation").Resolve(&request.DateRangeRequest)
+	if err != nil {
+		return nil, nameData, 0, err
+	}
+	nameData.FromDate = fromDate
+	nameData.ToDate = toDate
+
+	tolerance := request.Tolerance
+	if tolerance == 0 {
+		tolerance = defaultReconciliationTolerance
+	}
+
+	logRequest := *request
+	logRequest.FromDate = &fromDate
+	logRequest.ToDate = &toDate
+	searchParams, err := json.Marshal(logRequest)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error marshalling search params", "error", err)
+		searchParams = []byte(`{"error": "failed to marshal search parameters"}`)
+	}
+
+	logID, err := s.operationRepo.LogAccess(ctx, &models.AccessLog{
+		UserID:       userID,
+		OperationID:  operationID,
+		AccessTime:   time.Now(),
+		SearchParams: string(searchParams),
+		Status:       "pending",
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error logging access for reconciliation", "error", err)
+	}
+
+	queryCtx, finishQuery := s.queryTimeouts.start(s.rowPolicies.Apply(ctx, userID, "reconciliation"), "reconciliation")
+	rows, err := s.reconciliationRepo.GetShipmentInvoices(queryCtx, fromDate, toDate)
+	err = finishQuery(err)
+	if err == nil {
+		err = checkQueryRows("reconciliation", len(rows), s.reportLimiter.QueryRows("reconciliation"))
+	}
+	if err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, nameData, logID, err
+	}
+
+	response := &dto.ReconciliationResponse{
+		ReportName:  s.reportNamer.Title(ctx, nameData),
+		GeneratedAt: time.Now(),
+		Items:       make([]dto.ReconciliationItem, 0, len(rows)),
+	}
+
+	for _, item := range rows {
+		switch {
+		case item.ARDocument == "":
+			item.Status = dto.ReconciliationShippedUninvoiced
+			response.Uninvoiced++
+		case math.Abs(item.InvoicedAmt-item.ARShippedAmt) > tolerance:
+			item.Difference = item.InvoicedAmt - item.ARShippedAmt
+			item.Status = dto.ReconciliationAmountMismatch
+			response.AmountMismatch++
+		default:
+			item.Difference = item.InvoicedAmt - item.ARShippedAmt
+			item.Status = dto.ReconciliationMatched
+			response.Matched++
+			if !request.IncludeMatched {
+				continue
+			}
+		}
+		response.Items = append(response.Items, item)
+	}
+
+	s.updateLogStatus(ctx, logID, "success")
+	return response, nameData, logID, nil
+}
+
+// reconciliationExportRows maps reconciliation items to export headers, rows and row fill colors.
+func reconciliationExportRows(ctx context.Context, items []dto.ReconciliationItem) ([]string, []map[string]interface{}, []string) {
+	headers := []string{
+		"shipping_document",
+		"doc_date",
+		"customer_name",
+		"shipped_amt",
+		"ar_document",
+		"invoice_number",
+		"invoiced_amt",
+		"ar_shipped_amt",
+		"difference",
+		"reconciliation_status",
+	}
+
+	data := make([]map[string]interface{}, len(items))
+	colors := make([]string, len(items))
+	for i, item := range items {
+		data[i] = map[string]interface{}{
+			"shipping_document":     item.ShippingOrder,
+			"doc_date":              item.DocDate,
+			"customer_name":         item.CustomerName,
+			"shipped_amt":           item.ShippedAmt,
+			"ar_document":           item.ARDocument,
+			"invoice_number":        item.InvoiceNumber,
+			"invoiced_amt":          item.InvoicedAmt,
+			"ar_shipped_amt":        item.ARShippedAmt,
+			"difference":            item.Difference,
+			"reconciliation_status": translate.Key(ctx, item.Status),
+		}
+		colors[i] = reconciliationColors[item.Status]
+	}
+
+	return headers, data, colors
+}
+
+// updateLogStatus updates the status of an access log.
+func (s *reconciliationService) updateLogStatus(ctx context.Context, logID int, status string) {
+	if logID <= 0 {
+		return
+	}
+
+	ctx, status = logStatusContext(ctx, status)
+	if _, err := s.operationRepo.UpdateLogStatus(ctx, logID, status); err != nil {
+		s.logger.ErrorContext(ctx, "Error updating log status", "log_id", logID, "error", err)
+	}
+}
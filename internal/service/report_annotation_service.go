@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/translate"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	excelize "github.com/xuri/excelize/v2"
+)
+
+const maxUnmatchedListed = 100
+
+// annotatedReports maps the reports that accept note imports to the export column identifying a document
+var annotatedReports = map[string]string{
+	"assistant230": "sales_order_number",
+	"assistant610": "ar_type",
+}
+
+// ReportAnnotationService imports notes edited in report exports. The notes are kept locally and
+// overlay the ERP notes in later report output.
+type ReportAnnotationService interface {
+	ImportNotes(ctx context.Context, userID int, departmentID int, report string, request *dto.DateRangeRequest, file io.Reader, ipAddress string) (*dto.NoteImportResponse, error)
+}
+
+type reportAnnotationService struct {
+	annotationRepo   repository.ReportAnnotationRepository
+	assistant230Repo repository.Assistant230Repository
+	assistant610Repo repository.Assistant610Repository
+	operationService OperationService
+	operationCode    string
+	logger           *slog.Logger
+}
+
+// NewReportAnnotationService creates a new report annotation service
+func NewReportAnnotationService(
+	annotationRepo repository.ReportAnnotationRepository,
+	assistant230Repo repository.Assistant230Repository,
+	assistant610Repo repository.Assistant610Repository,
+	operationService OperationService,
+	operationCode string,
+	logger *slog.Logger,
+) ReportAnnotationService {
+	if operationCode == "" {
+		operationCode = "note_import"
+	}
+
+	return &reportAnnotationService{
+		annotationRepo:   annotationRepo,
+		assistant230Repo: assistant230Repo,
+		assistant610Repo: assistant610Repo,
+		operationService: operationService,
+		operationCode:    operationCode,
+		logger:           logger,
+	}
+}
+
+// ImportNotes reads the notes column of an edited export and saves the notes that differ from the
+// report for the same date range. Rows are matched to documents by the document number column.
+func (s *reportAnnotationService) ImportNotes(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	report string,
+	request *dto.DateRangeRequest,
+	file io.Reader,
+	ipAddress string,
+) (*dto.NoteImportResponse, error) {
+	keyColumn, ok := annotatedReports[report]
+	if !ok {
+		return nil, fmt.Errorf("report %s does not support note imports", report)
+	}
+
+	fromDate, toDate, err := reportDateRange.Resolve(request)
+	if err != nil {
+		return nil, err
+	}
+
+	imported, conflicts, rowsRead, err := readImportedNotes(ctx, file, keyColumn)
+	if err != nil {
+		return nil, err
+	}
+
+	logRequest := *request
+	logRequest.FromDate = &fromDate
+	logRequest.ToDate = &toDate
+	logID, err := s.operationService.LogAccess(ctx, userID, s.operationCode, map[string]interface{}{
+		"report":     report,
+		"date_range": logRequest,
+	}, ipAddress)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error logging access for note import", "error", err)
+	}
+
+	current, err := s.currentNotes(ctx, report, fromDate, toDate, departmentID)
+	if err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+
+	response := &dto.NoteImportResponse{
+		Report:    report,
+		RowsRead:  rowsRead,
+		Unmatched: []string{},
+		Conflicts: conflicts,
+	}
+
+	changed := make(map[string]string)
+	for key, note := range imported {
+		currentNote, found := current[key]
+		switch {
+		case !found:
+			if len(response.Unmatched) < maxUnmatchedListed {
+				response.Unmatched = append(response.Unmatched, key)
+			}
+		case currentNote == note:
+			response.Unchanged++
+		default:
+			changed[key] = note
+		}
+	}
+
+	if len(changed) > 0 {
+		if err := s.annotationRepo.SaveNotes(ctx, report, changed, userID); err != nil {
+			s.updateLogStatus(ctx, logID, "error")
+			return nil, err
+		}
+	}
+	response.Updated = len(changed)
+
+	s.updateLogStatus(ctx, logID, "success")
+	return response, nil
+}
+
+// currentNotes returns the notes the report shows today for every document in the date range,
+// including notes imported earlier
+func (s *reportAnnotationService) currentNotes(ctx context.Context, report string, fromDate, toDate time.Time, departmentID int) (map[string]string, error) {
+	notes, err := loadReportNotes(ctx, s.annotationRepo, report)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[string]string)
+	switch report {
+	case "assistant230":
+		items, err := s.assistant230Repo.GetInventoryReport(ctx, fromDate, toDate, departmentID, dto.InvoiceStatusAll, 0)
+		if err != nil {
+			return nil, fmt.Errorf("error querying inventory data: %w", err)
+		}
+		for _, item := range items {
+			current[item.SalesOrderNumber] = item.Notes
+		}
+	case "assistant610":
+		items, err := s.assistant610Repo.GetAssistant610Report(ctx, fromDate, toDate, departmentID, 0)
+		if err != nil {
+			return nil, fmt.Errorf("error querying 610 data: %w", err)
+		}
+		for _, item := range items {
+			current[item.Ar_Type] = item.Notes
+		}
+	}
+
+	for key := range current {
+		if note, ok := notes[key]; ok {
+			current[key] = note
+		}
+	}
+	return current, nil
+}
+
+// readImportedNotes reads document numbers and notes from the first sheet of an exported workbook.
+// The header row is found by the translated or raw column names, so the title rows above it and
+// any columns the user added are ignored.
+func readImportedNotes(ctx context.Context, file io.Reader, keyColumn string) (map[string]string, []string, int, error) {
+	f, err := excelize.OpenReader(file)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("error reading Excel file: %w", err)
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, nil, 0, errors.New("the Excel file has no sheets")
+	}
+	rows, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("error reading sheet %s: %w", sheets[0], err)
+	}
+
+	headerRow, keyIndex, notesIndex := -1, -1, -1
+	for i := 0; i < len(rows) && i < 10 && headerRow < 0; i++ {
+		keyIndex, notesIndex = -1, -1
+		for j, cell := range rows[i] {
+			switch {
+			case matchesHeader(cell, keyColumn):
+				keyIndex = j
+			case matchesHeader(cell, "notes"):
+				notesIndex = j
+			}
+		}
+		if keyIndex >= 0 && notesIndex >= 0 {
+			headerRow = i
+		}
+	}
+	if headerRow < 0 {
+		return nil, nil, 0, fmt.Errorf("the Excel file has no %q and %q columns", translate.Key(ctx, keyColumn), translate.Key(ctx, "notes"))
+	}
+
+	notes := make(map[string]string)
+	var conflicts []string
+	conflicted := make(map[string]bool)
+	rowsRead := 0
+	for _, row := range rows[headerRow+1:] {
+		key := strings.TrimSpace(cellAt(row, keyIndex))
+		if key == "" {
+			continue
+		}
+		rowsRead++
+		note := strings.TrimSpace(cellAt(row, notesIndex))
+
+		// Documents span several rows; the first note wins and disagreements are reported
+		if existing, ok := notes[key]; ok {
+			if existing != note && !conflicted[key] {
+				conflicted[key] = true
+				conflicts = append(conflicts, key)
+			}
+			continue
+		}
+		notes[key] = note
+	}
+
+	if conflicts == nil {
+		conflicts = []string{}
+	}
+	return notes, conflicts, rowsRead, nil
+}
+
+// matchesHeader reports whether a header cell names the given column key, in any language the
+// file may have been exported in
+func matchesHeader(cell string, key string) bool {
+	cell = strings.TrimSpace(cell)
+	if strings.EqualFold(cell, key) {
+		return true
+	}
+	for _, locale := range translate.Locales() {
+		if label, ok := translate.Label(locale, key); ok && strings.EqualFold(cell, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// cellAt returns a cell of a row, which excelize shortens when trailing cells are empty
+func cellAt(row []string, index int) string {
+	if index < len(row) {
+		return row[index]
+	}
+	return ""
+}
+
+// loadReportNotes returns the imported notes of a report keyed by document
+func loadReportNotes(ctx context.Context, annotationRepo repository.ReportAnnotationRepository, report string) (map[string]string, error) {
+	return annotationRepo.GetNotes(ctx, report)
+}
+
+// updateLogStatus updates the status of an access log.
+func (s *reportAnnotationService) updateLogStatus(ctx context.Context, logID int, status string) {
+	if logID <= 0 {
+		return
+	}
+
+	ctx, status = logStatusContext(ctx, status)
+	if _, err := s.operationService.UpdateLogStatus(ctx, logID, status); err != nil {
+		s.logger.ErrorContext(ctx, "Error updating log status", "log_id", logID, "error", err)
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/translate"
+	"erp-excel/internal/utils"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidColumn is returned when a request selects a column the report does not have
+var ErrInvalidColumn = apperror.Validation("invalid report column")
+
+// convertedTotalColumn is only available when the request converts to a target currency
+const convertedTotalColumn = "converted_total"
+
+// inventoryColumns are the columns of the Assistant 230 report, in their default order
+var inventoryColumns = []string{
+	"document_date",
+	"sales_order_number",
+	"customer_name",
+	"currency_type",
+	"currency",
+	"detailed_order_number",
+	"invoice_number",
+	"notes",
+	"invoice_status",
+}
+
+// assistant610Columns are the columns of the Assistant 610 report, in their default order
+var assistant610Columns = []string{
+	"doc_date",
+	"ar_type",
+	"shipping_order",
+	"customer_name",
+	"total_amt_trans",
+	"total_amt",
+	"order_no",
+	"invoice_number",
+	"notes",
+}
+
+// inventoryColumnTypes types the date and amount columns of the Assistant 230 export
+var inventoryColumnTypes = map[string]utils.ExcelColumnType{
+	"document_date":      utils.ExcelDate,
+	"currency_type":      utils.ExcelCurrency,
+	"currency":           utils.ExcelCurrency,
+	convertedTotalColumn: utils.ExcelCurrency,
+}
+
+// assistant610ColumnTypes types the date and amount columns of the Assistant 610 export
+var assistant610ColumnTypes = map[string]utils.ExcelColumnType{
+	"doc_date":           utils.ExcelDate,
+	"total_amt_trans":    utils.ExcelCurrency, // JSON key, used by snapshots
+	"total_amt_trasn":    utils.ExcelCurrency, // export header
+	"total_amt":          utils.ExcelCurrency,
+	convertedTotalColumn: utils.ExcelCurrency,
+}
+
+// reportColumnTypes types the export columns of the built-in reports, by report name
+var reportColumnTypes = map[string]map[string]utils.ExcelColumnType{
+	"assistant230": inventoryColumnTypes,
+	"assistant610": assistant610ColumnTypes,
+}
+
+// reportColumns selects the columns of the reports that support column selection, by report name
+var reportColumns = map[string]func(*dto.DateRangeRequest) ([]string, error){
+	"assistant230": InventoryColumns,
+	"assistant610": Assistant610Columns,
+}
+
+// InventoryColumns returns the Assistant 230 columns a request selects, in the requested order,
+// or every column when none is selected
+func InventoryColumns(request *dto.DateRangeRequest) ([]string, error) {
+	return selectColumns(inventoryColumns, request)
+}
+
+// Assistant610Columns returns the Assistant 610 columns a request selects, in the requested
+// order, or every column when none is selected
+func Assistant610Columns(request *dto.DateRangeRequest) ([]string, error) {
+	return selectColumns(assistant610Columns, request)
+}
+
+// selectColumns checks the selected columns against those of the report. Names are matched
+// case-insensitively and duplicates are dropped.
+func selectColumns(columns []string, request *dto.DateRangeRequest) ([]string, error) {
+	available := append([]string{}, columns...)
+	if request.TargetCurrency != "" {
+		available = append(available, convertedTotalColumn)
+	}
+	if len(request.Columns) == 0 {
+		return available, nil
+	}
+
+	known := make(map[string]bool, len(available))
+	for _, column := range available {
+		known[column] = true
+	}
+
+	selected := make([]string, 0, len(request.Columns))
+	seen := make(map[string]bool, len(request.Columns))
+	for _, column := range request.Columns {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !known[column] {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidColumn, column)
+		}
+		if seen[column] {
+			continue
+		}
+		seen[column] = true
+		selected = append(selected, column)
+	}
+
+	return selected, nil
+}
+
+// ProjectColumns turns report items into rows holding only the given columns, keyed by their
+// JSON names. JSON objects are unordered, so callers send the column order alongside.
+func ProjectColumns(items interface{}, columns []string) ([]map[string]interface{}, error) {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding report items: %w", err)
+	}
+
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("error decoding report items: %w", err)
+	}
+
+	projected := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		projected[i] = make(map[string]interface{}, len(columns))
+		for _, column := range columns {
+			projected[i][column] = row[column]
+		}
+	}
+
+	return projected, nil
+}
+
+// containsColumn reports whether the column is among the selected ones
+func containsColumn(columns []string, column string) bool {
+	for _, selected := range columns {
+		if selected == column {
+			return true
+		}
+	}
+	return false
+}
+
+// totalRow is the export row holding the converted total, labelled under the first column
+func totalRow(ctx context.Context, headers []string, total float64) map[string]interface{} {
+	row := map[string]interface{}{convertedTotalColumn: total}
+	if headers[0] != convertedTotalColumn {
+		row[headers[0]] = translate.Key(ctx, "total")
+	}
+	return row
+}
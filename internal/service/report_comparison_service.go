@@ -0,0 +1,344 @@
+package service
+
+import (
+	"context"
+	"erp-excel/internal/apperror"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"erp-excel/internal/dto"
+)
+
+// ErrInvalidComparison is returned when a comparison request has an invalid range or group key
+var ErrInvalidComparison = apperror.Validation("invalid report comparison")
+
+// ReportComparisonService runs a report for two date ranges and compares them per group key
+type ReportComparisonService interface {
+	CompareReport(ctx context.Context, userID int, departmentID int, report string, request *dto.ReportComparisonRequest) (*dto.ReportComparisonResponse, error)
+}
+
+type reportComparisonService struct {
+	reportService       ReportService
+	assistant610Service Assistant610Service
+	logger              *slog.Logger
+}
+
+func NewReportComparisonService(
+	reportService ReportService,
+	assistant610Service Assistant610Service,
+	logger *slog.Logger,
+) ReportComparisonService {
+	return &reportComparisonService{
+		reportService:       reportService,
+		assistant610Service: assistant610Service,
+		logger:              logger,
+	}
+}
+
+// comparisonDocument is one document of a compared range: a sales order of the 230 report or an
+// AR document of the 610 report. The reports have one row per line, so lines are folded into it.
+type comparisonDocument struct {
+	key    string
+	amount float64
+}
+
+// inventoryComparisonKeys are the columns the 230 deltas can be grouped by
+var inventoryComparisonKeys = map[string]func(dto.Asisstant230ReportItem) string{
+	"customer_name":  func(item dto.Asisstant230ReportItem) string { return item.CustomerName },
+	"currency_type":  func(item dto.Asisstant230ReportItem) string { return item.CurrencyType },
+	"invoice_status": func(item dto.Asisstant230ReportItem) string { return item.InvoiceStatus },
+}
+
+// assistant610ComparisonKeys are the columns the 610 deltas can be grouped by
+var assistant610ComparisonKeys = map[string]func(dto.Asisstant610ReportItem) string{
+	"customer_name": func(item dto.Asisstant610ReportItem) string { return item.CustomerName },
+}
+
+// defaultComparisonKey groups the deltas when the request does not choose a column
+const defaultComparisonKey = "customer_name"
+
+// comparisonRun is the data of one range, as fetched by the report's data service
+type comparisonRun struct {
+	items     interface{}
+	documents []comparisonDocument
+}
+
+func (s *reportComparisonService) CompareReport(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	report string,
+	request *dto.ReportComparisonRequest,
+) (*dto.ReportComparisonResponse, error) {
+	s.logger.DebugContext(ctx, "Comparing report periods", "user_id", userID, "report", report, "request", request)
+
+	groupBy := strings.ToLower(strings.TrimSpace(request.GroupBy))
+	if groupBy == "" {
+		groupBy = defaultComparisonKey
+	}
+
+	var run func(ctx context.Context, request *dto.DateRangeRequest) (*comparisonRun, error)
+	var columns []string
+	var err error
+	switch report {
+	case "assistant230":
+		keyOf, ok := inventoryComparisonKeys[groupBy]
+		if !ok {
+			return nil, fmt.Errorf("%w: cannot group by %s", ErrInvalidComparison, groupBy)
+		}
+		if columns, err = InventoryColumns(&request.DateRangeRequest); err != nil {
+			return nil, err
+		}
+		run = func(ctx context.Context, rangeRequest *dto.DateRangeRequest) (*comparisonRun, error) {
+			items, _, err := s.reportService.GetInventoryReportPage(ctx, userID, departmentID, rangeRequest, dto.ReportPageRequest{})
+			if err != nil {
+				return nil, err
+			}
+			documents := inventoryComparisonDocuments(items, keyOf, rangeRequest.TargetCurrency != "")
+			return &comparisonRun{items: items, documents: documents}, nil
+		}
+	case "assistant610":
+		keyOf, ok := assistant610ComparisonKeys[groupBy]
+		if !ok {
+			return nil, fmt.Errorf("%w: cannot group by %s", ErrInvalidComparison, groupBy)
+		}
+		if columns, err = Assistant610Columns(&request.DateRangeRequest); err != nil {
+			return nil, err
+		}
+		run = func(ctx context.Context, rangeRequest *dto.DateRangeRequest) (*comparisonRun, error) {
+			items, _, err := s.assistant610Service.GetAssistant610ReportPage(ctx, userID, departmentID, rangeRequest, dto.ReportPageRequest{})
+			if err != nil {
+				return nil, err
+			}
+			documents := assistant610ComparisonDocuments(items, keyOf, rangeRequest.TargetCurrency != "")
+			return &comparisonRun{items: items, documents: documents}, nil
+		}
+	default:
+		return nil, fmt.Errorf("%w: report %s cannot be compared", ErrInvalidComparison, report)
+	}
+
+	currentFrom, currentTo, err := reportDateRange.Resolve(&request.DateRangeRequest)
+	if err != nil {
+		return nil, fmt.Errorf("%w: current range: %v", ErrInvalidComparison, err)
+	}
+
+	var previousFrom, previousTo time.Time
+	if request.PreviousPeriod != nil || request.PreviousFromDate != nil || request.PreviousToDate != nil {
+		previousFrom, previousTo, err = reportDateRange.Resolve(&dto.DateRangeRequest{
+			FromDate: request.PreviousFromDate,
+			ToDate:   request.PreviousToDate,
+			Period:   request.PreviousPeriod,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%w: previous range: %v", ErrInvalidComparison, err)
+		}
+	} else {
+		previousFrom, previousTo = previousDateRange(currentFrom, currentTo)
+	}
+
+	current, err := run(ctx, comparisonRangeRequest(request, currentFrom, currentTo))
+	if err != nil {
+		return nil, err
+	}
+	previous, err := run(ctx, comparisonRangeRequest(request, previousFrom, previousTo))
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.ReportComparisonResponse{
+		ReportName: fmt.Sprintf("Comparison %s %s - %s vs %s - %s", report,
+			currentFrom.Format("02/01/2006"), currentTo.Format("02/01/2006"),
+			previousFrom.Format("02/01/2006"), previousTo.Format("02/01/2006")),
+		GeneratedAt:    time.Now(),
+		GroupBy:        groupBy,
+		TargetCurrency: strings.ToUpper(request.TargetCurrency),
+		Current:        dto.ReportComparisonPeriod{FromDate: currentFrom, ToDate: currentTo},
+		Previous:       dto.ReportComparisonPeriod{FromDate: previousFrom, ToDate: previousTo},
+	}
+
+	// Without a column selection the items keep their full shape
+	response.Current.Items, response.Previous.Items = current.items, previous.items
+	if len(request.Columns) > 0 {
+		response.Columns = columns
+		if response.Current.Items, err = ProjectColumns(current.items, columns); err != nil {
+			return nil, err
+		}
+		if response.Previous.Items, err = ProjectColumns(previous.items, columns); err != nil {
+			return nil, err
+		}
+	}
+
+	response.Total, response.Deltas = compareDocuments(current.documents, previous.documents)
+	response.Current.DocumentCount = response.Total.CurrentDocuments
+	response.Current.TotalAmt = response.Total.CurrentAmt
+	response.Previous.DocumentCount = response.Total.PreviousDocuments
+	response.Previous.TotalAmt = response.Total.PreviousAmt
+
+	return response, nil
+}
+
+// comparisonRangeRequest is the data request of one compared range, with the report options of
+// the comparison
+func comparisonRangeRequest(request *dto.ReportComparisonRequest, fromDate, toDate time.Time) *dto.DateRangeRequest {
+	rangeRequest := request.DateRangeRequest
+	rangeRequest.FromDate = &fromDate
+	rangeRequest.ToDate = &toDate
+	rangeRequest.Period = nil
+	return &rangeRequest
+}
+
+// previousDateRange returns the range a comparison defaults to. A range starting on the first
+// of a month is moved back by the months it spans, so month to date compares with the same days
+// of the previous month and a whole month with the whole previous month. Any other range is
+// compared with the same number of days just before it.
+func previousDateRange(fromDate, toDate time.Time) (time.Time, time.Time) {
+	from := time.Date(fromDate.Year(), fromDate.Month(), fromDate.Day(), 0, 0, 0, 0, fromDate.Location())
+	to := time.Date(toDate.Year(), toDate.Month(), toDate.Day(), 0, 0, 0, 0, toDate.Location())
+	endOfDay := func(day time.Time) time.Time {
+		return day.Add(24*time.Hour - time.Nanosecond)
+	}
+
+	if from.Day() == 1 {
+		months := (to.Year()-from.Year())*12 + int(to.Month()-from.Month()) + 1
+		return from.AddDate(0, -months, 0), endOfDay(addMonthsClamped(to, -months))
+	}
+
+	days := int(to.Sub(from).Hours()/24) + 1
+	previousTo := from.AddDate(0, 0, -1)
+	return previousTo.AddDate(0, 0, 1-days), endOfDay(previousTo)
+}
+
+// addMonthsClamped moves a date by whole months, keeping it within the target month. The last
+// day of a month moves to the last day of the target month.
+func addMonthsClamped(day time.Time, months int) time.Time {
+	first := time.Date(day.Year(), day.Month()+time.Month(months), 1, 0, 0, 0, 0, day.Location())
+	last := first.AddDate(0, 1, -1)
+	if day.Day() >= last.Day() || day.AddDate(0, 0, 1).Day() == 1 {
+		return last
+	}
+	return first.AddDate(0, 0, day.Day()-1)
+}
+
+// inventoryComparisonDocuments folds 230 lines into sales orders, with their local amount or,
+// when converting, their converted total
+func inventoryComparisonDocuments(
+	items []dto.Asisstant230ReportItem,
+	keyOf func(dto.Asisstant230ReportItem) string,
+	converted bool,
+) []comparisonDocument {
+	seen := make(map[string]bool, len(items))
+	documents := make([]comparisonDocument, 0, len(items))
+	for _, item := range items {
+		if seen[item.SalesOrderNumber] {
+			continue
+		}
+		seen[item.SalesOrderNumber] = true
+		documents = append(documents, comparisonDocument{
+			key:    keyOf(item),
+			amount: comparisonAmount(item.Currency, item.ConvertedTotal, converted),
+		})
+	}
+	return documents
+}
+
+// assistant610ComparisonDocuments folds 610 lines into AR documents, with their local amount or,
+// when converting, their converted total
+func assistant610ComparisonDocuments(
+	items []dto.Asisstant610ReportItem,
+	keyOf func(dto.Asisstant610ReportItem) string,
+	converted bool,
+) []comparisonDocument {
+	seen := make(map[string]bool, len(items))
+	documents := make([]comparisonDocument, 0, len(items))
+	for _, item := range items {
+		if seen[item.Ar_Type] {
+			continue
+		}
+		seen[item.Ar_Type] = true
+		documents = append(documents, comparisonDocument{
+			key:    keyOf(item),
+			amount: comparisonAmount(item.TotalAmt, item.ConvertedTotal, converted),
+		})
+	}
+	return documents
+}
+
+// comparisonAmount reads the formatted local amount of a document, or its converted total
+func comparisonAmount(local string, convertedTotal *float64, converted bool) float64 {
+	if converted {
+		if convertedTotal == nil {
+			return 0
+		}
+		return *convertedTotal
+	}
+
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(local), ",", ""), 64)
+	if err != nil {
+		slog.Warn("Invalid amount on compared document", "amount", local, "error", err)
+		return 0
+	}
+	return amount
+}
+
+// compareDocuments totals the documents of both ranges per group key and overall. The deltas
+// are ordered by the size of the change, largest first, then by key.
+func compareDocuments(current, previous []comparisonDocument) (dto.ReportComparisonDelta, []dto.ReportComparisonDelta) {
+	groups := make(map[string]*dto.ReportComparisonDelta)
+	group := func(key string) *dto.ReportComparisonDelta {
+		delta, ok := groups[key]
+		if !ok {
+			delta = &dto.ReportComparisonDelta{Key: key}
+			groups[key] = delta
+		}
+		return delta
+	}
+
+	total := dto.ReportComparisonDelta{}
+	for _, document := range current {
+		delta := group(document.key)
+		delta.CurrentAmt += document.amount
+		delta.CurrentDocuments++
+		total.CurrentAmt += document.amount
+		total.CurrentDocuments++
+	}
+	for _, document := range previous {
+		delta := group(document.key)
+		delta.PreviousAmt += document.amount
+		delta.PreviousDocuments++
+		total.PreviousAmt += document.amount
+		total.PreviousDocuments++
+	}
+
+	deltas := make([]dto.ReportComparisonDelta, 0, len(groups))
+	for _, delta := range groups {
+		finishComparisonDelta(delta)
+		deltas = append(deltas, *delta)
+	}
+	finishComparisonDelta(&total)
+
+	sort.Slice(deltas, func(i, j int) bool {
+		a, b := math.Abs(deltas[i].DeltaAmt), math.Abs(deltas[j].DeltaAmt)
+		if a != b {
+			return a > b
+		}
+		return deltas[i].Key < deltas[j].Key
+	})
+
+	return total, deltas
+}
+
+// finishComparisonDelta rounds the amounts and computes the change between the ranges
+func finishComparisonDelta(delta *dto.ReportComparisonDelta) {
+	delta.CurrentAmt = math.Round(delta.CurrentAmt*100) / 100
+	delta.PreviousAmt = math.Round(delta.PreviousAmt*100) / 100
+	delta.DeltaAmt = math.Round((delta.CurrentAmt-delta.PreviousAmt)*100) / 100
+	if delta.PreviousAmt != 0 {
+		percent := math.Round(delta.DeltaAmt/math.Abs(delta.PreviousAmt)*10000) / 100
+		delta.DeltaPercent = &percent
+	}
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/config"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultCustomReportMaxRows = 10000
+	defaultCustomReportTimeout = 60
+)
+
+var reportCodePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// reservedReportCodes are path segments under /reports served by fixed report routes
+var reservedReportCodes = []string{"definitions", "inventory", "download", "items", "reconciliation", "assistant610", "bundle"}
+
+// ReportDefinitionService manages the custom SQL reports registered by admins
+type ReportDefinitionService interface {
+	List(ctx context.Context) ([]*models.ReportDefinition, error)
+	Get(ctx context.Context, code string) (*models.ReportDefinition, error)
+	Create(ctx context.Context, userID int, request *dto.ReportDefinitionRequest) (*models.ReportDefinition, error)
+	Update(ctx context.Context, code string, request *dto.ReportDefinitionRequest) (*models.ReportDefinition, error)
+	Delete(ctx context.Context, code string) error
+}
+
+type reportDefinitionService struct {
+	definitionRepo repository.ReportDefinitionRepository
+	reservedCodes  map[string]bool
+	maxRows        int
+	maxTimeout     int
+}
+
+// NewReportDefinitionService creates a new report definition service
+func NewReportDefinitionService(cfg config.ReportsConfig, definitionRepo repository.ReportDefinitionRepository) ReportDefinitionService {
+	service := &reportDefinitionService{
+		definitionRepo: definitionRepo,
+		reservedCodes:  make(map[string]bool),
+		maxRows:        cfg.CustomMaxRows,
+		maxTimeout:     cfg.CustomTimeoutSeconds,
+	}
+	if service.maxRows <= 0 {
+		service.maxRows = defaultCustomReportMaxRows
+	}
+	if service.maxTimeout <= 0 {
+		service.maxTimeout = defaultCustomReportTimeout
+	}
+
+	// Configured procedure reports win over stored definitions, so their codes cannot be reused
+	for _, code := range reservedReportCodes {
+		service.reservedCodes[code] = true
+	}
+	for _, procedure := range cfg.Procedures {
+		service.reservedCodes[procedure.Code] = true
+	}
+
+	return service
+}
+
+// List returns every stored report definition, including inactive ones
+func (s *reportDefinitionService) List(ctx context.Context) ([]*models.ReportDefinition, error) {
+	definitions, err := s.definitionRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if definitions == nil {
+		definitions = []*models.ReportDefinition{}
+	}
+	return definitions, nil
+}
+
+// Get returns a stored report definition
+func (s *reportDefinitionService) Get(ctx context.Context, code string) (*models.ReportDefinition, error) {
+	definition, err := s.definitionRepo.GetByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrReportNotFound
+		}
+		return nil, err
+	}
+	return definition, nil
+}
+
+// Create validates and stores a new report definition
+func (s *reportDefinitionService) Create(ctx context.Context, userID int, request *dto.ReportDefinitionRequest) (*models.ReportDefinition, error) {
+	code := strings.ToLower(strings.TrimSpace(request.Code))
+	if !reportCodePattern.MatchString(code) {
+		return nil, apperror.Validation("report code may only contain lowercase letters, digits and underscores")
+	}
+	if s.reservedCodes[code] {
+		return nil, fmt.Errorf("report code %q is reserved", code)
+	}
+
+	if _, err := s.definitionRepo.GetByCode(ctx, code); err == nil {
+		return nil, fmt.Errorf("report %s already exists", code)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	definition, err := s.buildDefinition(code, request)
+	if err != nil {
+		return nil, err
+	}
+	definition.CreatedBy = userID
+
+	if _, err := s.definitionRepo.Create(ctx, definition); err != nil {
+		return nil, err
+	}
+	return definition, nil
+}
+
+// Update validates and replaces a stored report definition
+func (s *reportDefinitionService) Update(ctx context.Context, code string, request *dto.ReportDefinitionRequest) (*models.ReportDefinition, error) {
+	existing, err := s.Get(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	definition, err := s.buildDefinition(existing.Code, request)
+	if err != nil {
+		return nil, err
+	}
+	definition.ID = existing.ID
+	definition.CreatedBy = existing.CreatedBy
+	definition.CreatedAt = existing.CreatedAt
+
+	if err := s.definitionRepo.Update(ctx, definition); err != nil {
+		return nil, err
+	}
+	return definition, nil
+}
+
+// Delete removes a stored report definition
+func (s *reportDefinitionService) Delete(ctx context.Context, code string) error {
+	if err := s.definitionRepo.Delete(ctx, code); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrReportNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// buildDefinition turns a request into a query definition and applies the guardrails: the query
+// must pass the deny-list, and the timeout and row limit are capped by the configured maximums.
+func (s *reportDefinitionService) buildDefinition(code string, request *dto.ReportDefinitionRequest) (*models.ReportDefinition, error) {
+	definition := &models.ReportDefinition{
+		Code:           code,
+		Name:           strings.TrimSpace(request.Name),
+		Description:    strings.TrimSpace(request.Description),
+		SourceType:     models.ReportSourceQuery,
+		Source:         strings.TrimSpace(request.Query),
+		OperationCode:  strings.TrimSpace(request.OperationCode),
+		TimeoutSeconds: request.TimeoutSeconds,
+		MaxRows:        request.MaxRows,
+		IsActive:       request.IsActive == nil || *request.IsActive,
+		Parameters:     []models.ReportParameter{},
+		Columns:        []models.ReportColumn{},
+
+		TitleTemplate:    strings.TrimSpace(request.TitleTemplate),
+		FileNameTemplate: strings.TrimSpace(request.FileNameTemplate),
+	}
+	if definition.OperationCode == "" {
+		definition.OperationCode = "report_" + code
+	}
+	if definition.TimeoutSeconds <= 0 || definition.TimeoutSeconds > s.maxTimeout {
+		definition.TimeoutSeconds = s.maxTimeout
+	}
+	if definition.MaxRows <= 0 || definition.MaxRows > s.maxRows {
+		definition.MaxRows = s.maxRows
+	}
+
+	for _, param := range request.Parameters {
+		definition.Parameters = append(definition.Parameters, models.ReportParameter{
+			Name:     strings.TrimPrefix(param.Name, "@"),
+			Source:   param.Source,
+			Type:     param.Type,
+			Required: param.Required,
+			Default:  param.Default,
+		})
+	}
+	for _, column := range request.Columns {
+		definition.Columns = append(definition.Columns, models.ReportColumn{
+			Key:   column.Key,
+			Field: column.Field,
+			Type:  column.Type,
+		})
+	}
+
+	if err := validateReportDefinition(definition); err != nil {
+		return nil, err
+	}
+	if err := validateReportQuery(definition.Source, definition.Parameters); err != nil {
+		return nil, fmt.Errorf("report %s: %w", code, err)
+	}
+
+	return definition, nil
+}
@@ -0,0 +1,697 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/config"
+	"erp-excel/database"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/daterange"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/events"
+	"erp-excel/internal/integration"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/storage"
+	"erp-excel/internal/utils"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrReportNotFound is returned for an unknown report code
+var ErrReportNotFound = apperror.NotFound("report not found")
+
+const defaultReportTimeout = 60 * time.Second
+
+// ReportEngineService runs reports described by report definitions
+type ReportEngineService interface {
+	GetDefinition(ctx context.Context, code string) (*models.ReportDefinition, error)
+	GetReport(ctx context.Context, code string) (*dto.ReportDefinitionResponse, error)
+	ListReports(ctx context.Context, userID int, isAdmin bool) ([]dto.ReportDefinitionResponse, error)
+	RunReport(ctx context.Context, userID int, departmentID int, code string, request *dto.ReportRunRequest, ipAddress string) (*dto.ReportRunResponse, error)
+	ExportReport(ctx context.Context, userID int, departmentID int, code string, request *dto.ReportRunRequest, ipAddress string) (*dto.ReportFileResponse, error)
+	PreviewReport(ctx context.Context, userID int, departmentID int, code string, request *dto.ReportRunRequest) (*dto.ReportPreviewResponse, error)
+}
+
+type reportEngineService struct {
+	definitions      map[string]*models.ReportDefinition
+	codes            []string // registration order, used for listing
+	sourceRepo       repository.ReportSourceRepository
+	definitionRepo   repository.ReportDefinitionRepository
+	departmentRepo   repository.DepartmentRepository
+	operationService OperationService
+	fileStorage      storage.Storage
+	fileRepo         repository.ReportFileRepository
+	reportNamer      ReportNamer
+	reportLimiter    ReportLimiter
+	throttle         *ERPThrottle
+	sharePointClient integration.SharePointClient
+	eventService     EventService
+	logger           *slog.Logger
+}
+
+// NewReportEngineService creates a new report engine from the configured stored procedure reports
+// and the custom SQL reports stored by admins
+func NewReportEngineService(
+	cfg config.ReportsConfig,
+	sourceRepo repository.ReportSourceRepository,
+	definitionRepo repository.ReportDefinitionRepository,
+	departmentRepo repository.DepartmentRepository,
+	operationService OperationService,
+	fileStorage storage.Storage,
+	fileRepo repository.ReportFileRepository,
+	reportNamer ReportNamer,
+	reportLimiter ReportLimiter,
+	throttle *ERPThrottle,
+	sharePointClient integration.SharePointClient,
+	eventService EventService,
+	logger *slog.Logger,
+) (ReportEngineService, error) {
+	service := &reportEngineService{
+		definitions:      make(map[string]*models.ReportDefinition),
+		sourceRepo:       sourceRepo,
+		definitionRepo:   definitionRepo,
+		departmentRepo:   departmentRepo,
+		operationService: operationService,
+		fileStorage:      fileStorage,
+		fileRepo:         fileRepo,
+		reportNamer:      reportNamer,
+		reportLimiter:    reportLimiter,
+		throttle:         throttle,
+		sharePointClient: sharePointClient,
+		eventService:     eventService,
+		logger:           logger,
+	}
+
+	for _, procedure := range cfg.Procedures {
+		definition := procedureDefinition(procedure)
+		if err := validateReportDefinition(definition); err != nil {
+			return nil, err
+		}
+		if _, exists := service.definitions[definition.Code]; exists {
+			return nil, fmt.Errorf("duplicate report code %q", definition.Code)
+		}
+		service.definitions[definition.Code] = definition
+		service.codes = append(service.codes, definition.Code)
+	}
+
+	return service, nil
+}
+
+// procedureDefinition converts a configured stored procedure into a report definition
+func procedureDefinition(cfg config.ReportProcedureConfig) *models.ReportDefinition {
+	definition := &models.ReportDefinition{
+		Code:           cfg.Code,
+		Name:           cfg.Name,
+		Description:    cfg.Description,
+		SourceType:     models.ReportSourceProcedure,
+		Source:         cfg.Procedure,
+		OperationCode:  cfg.OperationCode,
+		TimeoutSeconds: cfg.TimeoutSeconds,
+
+		TitleTemplate:    cfg.TitleTemplate,
+		FileNameTemplate: cfg.FileNameTemplate,
+	}
+	if definition.OperationCode == "" {
+		definition.OperationCode = cfg.Code
+	}
+	for _, param := range cfg.Parameters {
+		definition.Parameters = append(definition.Parameters, models.ReportParameter{
+			Name:     param.Name,
+			Source:   param.Source,
+			Type:     param.Type,
+			Required: param.Required,
+			Default:  param.Default,
+		})
+	}
+	for _, column := range cfg.Columns {
+		definition.Columns = append(definition.Columns, models.ReportColumn{
+			Key:   column.Key,
+			Field: column.Field,
+			Type:  column.Type,
+		})
+	}
+	return definition
+}
+
+// validateReportDefinition checks everything that ends up in the SQL text or drives parameter binding
+func validateReportDefinition(definition *models.ReportDefinition) error {
+	if definition.Code == "" || definition.Name == "" {
+		return errors.New("report code and name are required")
+	}
+	if definition.SourceType == models.ReportSourceProcedure && !repository.ValidSQLIdentifier(definition.Source) {
+		return fmt.Errorf("report %s: invalid procedure name %q", definition.Code, definition.Source)
+	}
+
+	for _, param := range definition.Parameters {
+		if !repository.ValidSQLIdentifier(param.Name) || strings.Contains(param.Name, ".") {
+			return fmt.Errorf("report %s: invalid parameter name %q", definition.Code, param.Name)
+		}
+		switch param.Source {
+		case "", models.ReportParamFromDate, models.ReportParamToDate, models.ReportParamDepartmentID,
+			models.ReportParamDepartmentIDs, models.ReportParamUserID:
+		default:
+			return fmt.Errorf("report %s: unknown source %q for parameter %s", definition.Code, param.Source, param.Name)
+		}
+		switch param.Type {
+		case "", "string", "int", "date":
+		default:
+			return fmt.Errorf("report %s: unknown type %q for parameter %s", definition.Code, param.Type, param.Name)
+		}
+	}
+
+	for _, column := range definition.Columns {
+		if column.Key == "" || column.Field == "" {
+			return fmt.Errorf("report %s: column key and field are required", definition.Code)
+		}
+	}
+
+	return nil
+}
+
+// GetDefinition returns the definition of a report. Configured reports are looked up first; stored
+// definitions are read on every call so admin changes apply without a restart.
+func (s *reportEngineService) GetDefinition(ctx context.Context, code string) (*models.ReportDefinition, error) {
+	if definition, ok := s.definitions[code]; ok {
+		return definition, nil
+	}
+
+	definition, err := s.definitionRepo.GetByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrReportNotFound
+		}
+		return nil, err
+	}
+	if !definition.IsActive {
+		return nil, ErrReportNotFound
+	}
+	return definition, nil
+}
+
+// GetReport describes an active report, whether or not the user may run it
+func (s *reportEngineService) GetReport(ctx context.Context, code string) (*dto.ReportDefinitionResponse, error) {
+	definition, err := s.GetDefinition(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	response := definitionResponse(definition)
+	return &response, nil
+}
+
+// ListReports returns the reports the user is allowed to run
+func (s *reportEngineService) ListReports(ctx context.Context, userID int, isAdmin bool) ([]dto.ReportDefinitionResponse, error) {
+	definitions := make([]*models.ReportDefinition, 0, len(s.codes))
+	for _, code := range s.codes {
+		definitions = append(definitions, s.definitions[code])
+	}
+
+	stored, err := s.definitionRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, definition := range stored {
+		if _, configured := s.definitions[definition.Code]; definition.IsActive && !configured {
+			definitions = append(definitions, definition)
+		}
+	}
+
+	reports := make([]dto.ReportDefinitionResponse, 0, len(definitions))
+	for _, definition := range definitions {
+		if !isAdmin {
+			hasAccess, err := s.operationService.CheckUserAccess(ctx, userID, definition.OperationCode)
+			if err != nil {
+				s.logger.ErrorContext(ctx, "Error checking access to report", "report", definition.Code, "error", err)
+				continue
+			}
+			if !hasAccess {
+				continue
+			}
+		}
+		reports = append(reports, definitionResponse(definition))
+	}
+	return reports, nil
+}
+
+// definitionResponse describes a definition without exposing its source
+func definitionResponse(definition *models.ReportDefinition) dto.ReportDefinitionResponse {
+	response := dto.ReportDefinitionResponse{
+		Code:        definition.Code,
+		Name:        definition.Name,
+		Description: definition.Description,
+		Parameters:  []dto.ReportParameterInfo{},
+	}
+	for _, param := range definition.Parameters {
+		if param.Source != "" {
+			if param.Source == models.ReportParamFromDate || param.Source == models.ReportParamToDate {
+				response.UsesDates = true
+			}
+			continue
+		}
+		paramType := param.Type
+		if paramType == "" {
+			paramType = "string"
+		}
+		response.Parameters = append(response.Parameters, dto.ReportParameterInfo{
+			Name:     param.Name,
+			Type:     paramType,
+			Required: param.Required,
+			Default:  param.Default,
+		})
+	}
+	for _, column := range definition.Columns {
+		response.Columns = append(response.Columns, column.Key)
+	}
+	return response
+}
+
+// RunReport runs a report and returns its rows
+func (s *reportEngineService) RunReport(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	code string,
+	request *dto.ReportRunRequest,
+	ipAddress string,
+) (*dto.ReportRunResponse, error) {
+	definition, err := s.GetDefinition(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	response, _, logID, err := s.run(ctx, definition, userID, departmentID, request, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	s.updateLogStatus(ctx, logID, "success")
+	return response, nil
+}
+
+// ExportReport runs a report and exports it to an Excel file
+func (s *reportEngineService) ExportReport(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	code string,
+	request *dto.ReportRunRequest,
+	ipAddress string,
+) (*dto.ReportFileResponse, error) {
+	definition, err := s.GetDefinition(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	response, nameData, logID, err := s.run(ctx, definition, userID, departmentID, request, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkExportRows(len(response.Items), s.reportLimiter.MaxRows(ctx, userID, definition.Code)); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+
+	if len(response.Items) == 0 {
+		s.updateLogStatus(ctx, logID, "success")
+		return nil, fmt.Errorf("%w to export for the specified date range", apperror.ErrNoData)
+	}
+
+	_, fileDetail, err := utils.ExportSheetsToExcel(ctx, response.ReportName, utils.ExcelSheet{
+		Name:        "Sheet1",
+		Title:       response.ReportName,
+		Headers:     response.Columns,
+		Data:        response.Items,
+		ColumnTypes: reportDefinitionColumnTypes(definition),
+	})
+	if err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, fmt.Errorf("error exporting to Excel: %w", err)
+	}
+	if err := checkExportSize(definition.Code, fileDetail.Len(), s.reportLimiter.MaxExportBytes(definition.Code)); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+
+	s.updateLogStatus(ctx, logID, "success")
+
+	fileName := s.reportNamer.FileName(ctx, nameData, response.ReportName)
+	exportFile := &models.ReportFile{
+		FileName:    fileName,
+		Report:      definition.Code,
+		UserID:      userID,
+		AccessLogID: logID,
+		RowCount:    len(response.Items),
+	}
+	downloadURL := storeExportFile(ctx, s.logger, s.fileStorage, s.fileRepo, exportFile, fileDetail)
+	publishExportFile(ctx, s.logger, s.sharePointClient, definition.Code, fileName, fileDetail)
+	s.eventService.Emit(ctx, events.ExportCompleted, events.ExportCompletedData{
+		Report:       definition.Code,
+		FileName:     fileName,
+		RowCount:     len(response.Items),
+		UserID:       userID,
+		DepartmentID: departmentID,
+		DownloadURL:  downloadURL,
+	})
+
+	return &dto.ReportFileResponse{
+		ReportName:  response.ReportName,
+		FileName:    fileName,
+		FileDetal:   fileDetail,
+		DownloadURL: downloadURL,
+		Checksum:    exportFile.Checksum,
+		GeneratedAt: time.Now(),
+	}, nil
+}
+
+// PreviewReport returns the first rows of a report with the resolved parameters. Stored procedures
+// cannot take a TOP clause, so the rest of the result set is simply not read.
+func (s *reportEngineService) PreviewReport(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	code string,
+	request *dto.ReportRunRequest,
+) (*dto.ReportPreviewResponse, error) {
+	definition, err := s.GetDefinition(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	params, nameData, err := s.bindParameters(ctx, definition, userID, departmentID, request)
+	if err != nil {
+		return nil, err
+	}
+	reportName := s.reportNamer.Title(ctx, nameData)
+
+	release, err := s.throttle.Acquire(ctx, definition.Code)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	timeout := reportTimeout(definition)
+	queryCtx, cancel := context.WithTimeout(database.WithQueryLabel(ctx, definition.Code), timeout)
+	defer cancel()
+
+	limit := dto.PreviewRowLimit
+	if definition.MaxRows > 0 && definition.MaxRows < limit {
+		limit = definition.MaxRows
+	}
+
+	result, err := s.sourceRepo.Execute(queryCtx, definition, params, limit)
+	if err != nil {
+		return nil, queryError(ctx, queryCtx, timeout, err)
+	}
+
+	columns, items := mapReportColumns(definition, result)
+
+	resolved := make(map[string]interface{}, len(params))
+	for _, param := range params {
+		if value, ok := param.Value.(time.Time); ok {
+			resolved[param.Name] = value.Format("2006-01-02")
+			continue
+		}
+		resolved[param.Name] = param.Value
+	}
+
+	return &dto.ReportPreviewResponse{
+		Type:        definition.Code,
+		ReportName:  reportName,
+		Parameters:  resolved,
+		Columns:     columns,
+		Items:       items,
+		RowCount:    len(items),
+		Limit:       limit,
+		Truncated:   result.Truncated,
+		GeneratedAt: time.Now(),
+	}, nil
+}
+
+// run binds the parameters, logs the access and executes the report source. The name data is
+// returned for naming the export file; the log ID is left pending for the caller to close.
+func (s *reportEngineService) run(
+	ctx context.Context,
+	definition *models.ReportDefinition,
+	userID int,
+	departmentID int,
+	request *dto.ReportRunRequest,
+	ipAddress string,
+) (*dto.ReportRunResponse, ReportNameData, int, error) {
+	params, nameData, err := s.bindParameters(ctx, definition, userID, departmentID, request)
+	if err != nil {
+		return nil, nameData, 0, err
+	}
+
+	searchParams, err := reportSearchFields(definition.Code, request)
+	if err != nil {
+		return nil, nameData, 0, err
+	}
+
+	logID, err := s.operationService.LogAccess(ctx, userID, definition.OperationCode, searchParams, ipAddress)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error logging access", "report", definition.Code, "error", err)
+	}
+
+	release, err := s.throttle.Acquire(ctx, definition.Code)
+	if err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, nameData, 0, err
+	}
+	defer release()
+
+	timeout := reportTimeout(definition)
+	queryCtx, cancel := context.WithTimeout(database.WithQueryLabel(ctx, definition.Code), timeout)
+	defer cancel()
+
+	// MaxRows is only set for custom reports and cuts them off; the query rows guardrail of the
+	// report rejects them instead
+	limit, queryRows := definition.MaxRows, s.reportLimiter.QueryRows(definition.Code)
+	if queryRows > 0 && (limit == 0 || queryRows < limit) {
+		limit = exportRowLimit(queryRows)
+	}
+	result, err := s.sourceRepo.Execute(queryCtx, definition, params, limit)
+	if err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, nameData, 0, queryError(ctx, queryCtx, timeout, err)
+	}
+	if err := checkQueryRows(definition.Code, len(result.Rows), queryRows); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, nameData, 0, err
+	}
+
+	columns, items := mapReportColumns(definition, result)
+
+	return &dto.ReportRunResponse{
+		Code:        definition.Code,
+		ReportName:  s.reportNamer.Title(ctx, nameData),
+		GeneratedAt: time.Now(),
+		Columns:     columns,
+		Items:       items,
+		RowCount:    len(items),
+		Truncated:   result.Truncated,
+	}, nameData, logID, nil
+}
+
+// joinIDs formats IDs as a comma separated list
+func joinIDs(ids []int) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.Itoa(id))
+	}
+	return strings.Join(parts, ",")
+}
+
+// reportTimeout returns the query timeout of a report
+func reportTimeout(definition *models.ReportDefinition) time.Duration {
+	if definition.TimeoutSeconds > 0 {
+		return time.Duration(definition.TimeoutSeconds) * time.Second
+	}
+	return defaultReportTimeout
+}
+
+// bindParameters binds the parameters of a report run, resolving the department subtree of the
+// user only when the report asks for it
+func (s *reportEngineService) bindParameters(
+	ctx context.Context,
+	definition *models.ReportDefinition,
+	userID int,
+	departmentID int,
+	request *dto.ReportRunRequest,
+) ([]sql.NamedArg, ReportNameData, error) {
+	var departmentIDs []int
+	for _, param := range definition.Parameters {
+		if param.Source != models.ReportParamDepartmentIDs {
+			continue
+		}
+		ids, err := s.departmentRepo.GetDescendantIDs(ctx, departmentID)
+		if err != nil {
+			return nil, ReportNameData{}, fmt.Errorf("error resolving departments: %w", err)
+		}
+		departmentIDs = ids
+		break
+	}
+	return bindReportParameters(definition, s.reportLimiter.DateRange(definition.Code), userID, departmentID, departmentIDs, request)
+}
+
+// bindReportParameters resolves every parameter of the definition to a named SQL argument and
+// collects the values used to name the report. The date range is only resolved when the report
+// uses it, with the resolver limited to the max months of the report.
+func bindReportParameters(
+	definition *models.ReportDefinition,
+	dateRange daterange.Resolver,
+	userID int,
+	departmentID int,
+	departmentIDs []int,
+	request *dto.ReportRunRequest,
+) ([]sql.NamedArg, ReportNameData, error) {
+	nameData := ReportNameData{
+		Report:           definition.Code,
+		Name:             definition.Name,
+		UserID:           userID,
+		DepartmentID:     departmentID,
+		TitleTemplate:    definition.TitleTemplate,
+		FileNameTemplate: definition.FileNameTemplate,
+	}
+
+	known := make(map[string]bool, len(definition.Parameters))
+	for _, param := range definition.Parameters {
+		known[param.Name] = true
+	}
+	for name := range request.Params {
+		if !known[name] {
+			return nil, nameData, fmt.Errorf("unknown parameter %s", name)
+		}
+	}
+
+	var fromDate, toDate time.Time
+	datesResolved := false
+
+	params := make([]sql.NamedArg, 0, len(definition.Parameters))
+	for _, param := range definition.Parameters {
+		var value interface{}
+
+		switch param.Source {
+		case models.ReportParamFromDate, models.ReportParamToDate:
+			if !datesResolved {
+				var err error
+				fromDate, toDate, err = dateRange.Resolve(&request.DateRangeRequest)
+				if err != nil {
+					return nil, nameData, err
+				}
+				datesResolved = true
+				nameData.FromDate = fromDate
+				nameData.ToDate = toDate
+			}
+			if param.Source == models.ReportParamFromDate {
+				value = fromDate
+			} else {
+				value = toDate
+			}
+		case models.ReportParamDepartmentID:
+			value = departmentID
+		case models.ReportParamDepartmentIDs:
+			value = joinIDs(departmentIDs)
+		case models.ReportParamUserID:
+			value = userID
+		default:
+			raw, ok := request.Params[param.Name]
+			if !ok || raw == "" {
+				raw = param.Default
+			}
+			if raw == "" {
+				if param.Required {
+					return nil, nameData, fmt.Errorf("parameter %s is required", param.Name)
+				}
+				params = append(params, sql.Named(param.Name, nil))
+				continue
+			}
+
+			converted, err := convertReportParameter(param, raw)
+			if err != nil {
+				return nil, nameData, err
+			}
+			value = converted
+		}
+
+		params = append(params, sql.Named(param.Name, value))
+	}
+
+	return params, nameData, nil
+}
+
+// convertReportParameter parses a request parameter according to its declared type
+func convertReportParameter(param models.ReportParameter, raw string) (interface{}, error) {
+	switch param.Type {
+	case "int":
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s must be an integer", param.Name)
+		}
+		return value, nil
+	case "date":
+		value, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s must be a date (YYYY-MM-DD)", param.Name)
+		}
+		return value, nil
+	default:
+		return raw, nil
+	}
+}
+
+// reportDefinitionColumnTypes types the export columns of a report definition by key
+func reportDefinitionColumnTypes(definition *models.ReportDefinition) map[string]utils.ExcelColumnType {
+	types := make(map[string]utils.ExcelColumnType, len(definition.Columns))
+	for _, column := range definition.Columns {
+		if column.Type != "" {
+			types[column.Key] = utils.ExcelColumnType(column.Type)
+		}
+	}
+	return types
+}
+
+// mapReportColumns renames result set columns to report column keys. Without a column mapping
+// every column is returned under its own name.
+func mapReportColumns(definition *models.ReportDefinition, result *models.ReportResult) ([]string, []map[string]interface{}) {
+	if len(definition.Columns) == 0 {
+		items := result.Rows
+		if items == nil {
+			items = []map[string]interface{}{}
+		}
+		return result.Columns, items
+	}
+
+	columns := make([]string, len(definition.Columns))
+	for i, column := range definition.Columns {
+		columns[i] = column.Key
+	}
+
+	items := make([]map[string]interface{}, len(result.Rows))
+	for i, row := range result.Rows {
+		item := make(map[string]interface{}, len(definition.Columns))
+		for _, column := range definition.Columns {
+			item[column.Key] = row[column.Field]
+		}
+		items[i] = item
+	}
+
+	return columns, items
+}
+
+// updateLogStatus updates the status of an access log.
+func (s *reportEngineService) updateLogStatus(ctx context.Context, logID int, status string) {
+	if logID <= 0 {
+		return
+	}
+
+	ctx, status = logStatusContext(ctx, status)
+	if _, err := s.operationService.UpdateLogStatus(ctx, logID, status); err != nil {
+		s.logger.ErrorContext(ctx, "Error updating log status", "log_id", logID, "error", err)
+	}
+}
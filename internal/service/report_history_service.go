@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/repository"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	// reportSearchParamsKey tags the search params of an access log with the report that was run
+	reportSearchParamsKey = "report"
+
+	reportHistoryDefaultLimit = 20
+	reportHistoryMaxLimit     = 100
+	reportHistoryDays         = 90
+	// reportHistoryScanLimit bounds the access logs read to find the report runs among them
+	reportHistoryScanLimit = 500
+)
+
+// ReportHistoryService lists the user's recent report runs and the reports they starred
+type ReportHistoryService interface {
+	History(ctx context.Context, userID int, limit int) ([]*dto.ReportHistoryEntry, error)
+	Favorites(ctx context.Context, userID int) ([]*dto.ReportFavoriteResponse, error)
+	ToggleFavorite(ctx context.Context, userID int, report string) (*dto.ReportFavoriteResponse, error)
+}
+
+type reportHistoryService struct {
+	operationRepo       repository.OperationRepository
+	favoriteRepo        repository.ReportFavoriteRepository
+	reportEngineService ReportEngineService
+	logger              *slog.Logger
+}
+
+// NewReportHistoryService creates a new report history service
+func NewReportHistoryService(
+	operationRepo repository.OperationRepository,
+	favoriteRepo repository.ReportFavoriteRepository,
+	reportEngineService ReportEngineService,
+	logger *slog.Logger,
+) ReportHistoryService {
+	return &reportHistoryService{
+		operationRepo:       operationRepo,
+		favoriteRepo:        favoriteRepo,
+		reportEngineService: reportEngineService,
+		logger:              logger,
+	}
+}
+
+// History returns the user's newest report runs with the parameters to run them again. Runs are
+// found in the access logs tagged with their report; other operations are left out.
+func (s *reportHistoryService) History(ctx context.Context, userID int, limit int) ([]*dto.ReportHistoryEntry, error) {
+	if limit <= 0 {
+		limit = reportHistoryDefaultLimit
+	}
+	if limit > reportHistoryMaxLimit {
+		limit = reportHistoryMaxLimit
+	}
+
+	favorites, err := s.favoriteSet(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	since := time.Now().AddDate(0, 0, -reportHistoryDays)
+	logs, err := s.operationRepo.GetUserLogs(ctx, userID, since, reportHistoryScanLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*dto.ReportHistoryEntry, 0, limit)
+	for _, accessLog := range logs {
+		runAgain, ok := reportRunAgain(accessLog.SearchParams)
+		if !ok {
+			continue
+		}
+
+		entries = append(entries, &dto.ReportHistoryEntry{
+			ID:        accessLog.ID,
+			Report:    runAgain.Report,
+			Operation: accessLog.OperationName,
+			Status:    accessLog.Status,
+			RunAt:     accessLog.AccessTime,
+			Favorite:  favorites[runAgain.Report],
+			RunAgain:  runAgain,
+		})
+		if len(entries) == limit {
+			break
+		}
+	}
+
+	return entries, nil
+}
+
+// Favorites returns the reports the user starred, newest first
+func (s *reportHistoryService) Favorites(ctx context.Context, userID int) ([]*dto.ReportFavoriteResponse, error) {
+	favorites, err := s.favoriteRepo.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*dto.ReportFavoriteResponse, 0, len(favorites))
+	for _, favorite := range favorites {
+		createdAt := favorite.CreatedAt
+		responses = append(responses, &dto.ReportFavoriteResponse{
+			Report:    favorite.Report,
+			Favorite:  true,
+			CreatedAt: &createdAt,
+		})
+	}
+	return responses, nil
+}
+
+// ToggleFavorite stars a report, or unstars it when it already is
+func (s *reportHistoryService) ToggleFavorite(ctx context.Context, userID int, report string) (*dto.ReportFavoriteResponse, error) {
+	report = strings.TrimSpace(report)
+	if err := checkReportCode(ctx, s.reportEngineService, report); err != nil {
+		return nil, err
+	}
+
+	removed, err := s.favoriteRepo.Remove(ctx, userID, report)
+	if err != nil {
+		return nil, err
+	}
+	if removed {
+		return &dto.ReportFavoriteResponse{Report: report, Favorite: false}, nil
+	}
+
+	if err := s.favoriteRepo.Add(ctx, userID, report); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &dto.ReportFavoriteResponse{Report: report, Favorite: true, CreatedAt: &now}, nil
+}
+
+// favoriteSet returns the reports the user starred
+func (s *reportHistoryService) favoriteSet(ctx context.Context, userID int) (map[string]bool, error) {
+	favorites, err := s.favoriteRepo.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]bool, len(favorites))
+	for _, favorite := range favorites {
+		set[favorite.Report] = true
+	}
+	return set, nil
+}
+
+// checkReportCode checks that a report exists, returning ErrReportNotFound otherwise
+func checkReportCode(ctx context.Context, reportEngineService ReportEngineService, report string) error {
+	if _, ok := reportColumns[report]; ok {
+		return nil
+	}
+	_, err := reportEngineService.GetDefinition(ctx, report)
+	return err
+}
+
+// reportRunAgain reads the report and its parameters back from the search params of an access
+// log written with reportSearchParams
+func reportRunAgain(searchParams string) (*dto.ReportRunAgain, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(searchParams), &fields); err != nil {
+		return nil, false
+	}
+
+	var report string
+	if err := json.Unmarshal(fields[reportSearchParamsKey], &report); err != nil || report == "" {
+		return nil, false
+	}
+	delete(fields, reportSearchParamsKey)
+
+	parameters, err := json.Marshal(fields)
+	if err != nil {
+		return nil, false
+	}
+
+	return &dto.ReportRunAgain{Report: report, Parameters: parameters}, true
+}
+
+// reportSearchParams encodes the parameters of a report run for its access log, tagged with the
+// report so the run can be repeated from the history
+func reportSearchParams(report string, params interface{}) ([]byte, error) {
+	fields, err := reportSearchFields(report, params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// reportSearchFields is reportSearchParams for callers that encode the params themselves
+func reportSearchFields(report string, params interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("error decoding report parameters: %w", err)
+	}
+	fields[reportSearchParamsKey] = report
+
+	return fields, nil
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/daterange"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// defaultExportMaxRows applies when no limit is configured
+const defaultExportMaxRows = 100000
+
+var (
+	// ErrExportTooLarge is returned for an export with more rows than the user may export at once
+	ErrExportTooLarge = apperror.New(apperror.CodeExportTooLarge, http.StatusUnprocessableEntity, "Export too large", "export is too large")
+	// ErrReportTooLarge is returned for a report query or export file beyond the guardrails of
+	// the report
+	ErrReportTooLarge = apperror.New(apperror.CodeReportTooLarge, http.StatusUnprocessableEntity, "Report too large", "report is too large")
+)
+
+// ReportLimiter decides how much of the ERP a report may read: how far back its date range may
+// start, how many rows its queries may return and how many of them a user may export
+type ReportLimiter interface {
+	// MaxRows returns the row limit of an export, 0 for no limit
+	MaxRows(ctx context.Context, userID int, report string) int
+	// QueryRows returns the row limit of a query of the report, 0 for no limit
+	QueryRows(report string) int
+	// MaxExportBytes returns the size limit of an export file of the report, 0 for no limit
+	MaxExportBytes(report string) int
+	// DateRange returns the resolver of the date ranges of the report, limited to its max months
+	DateRange(report string) daterange.Resolver
+	// Limit returns the effective guardrails of a report and where they come from
+	Limit(report string) *dto.ReportLimitResponse
+	// Reports returns the reports with a limit configured or set at runtime
+	Reports() []string
+	// SetStored replaces the limits set at runtime, which take precedence over the configured ones
+	SetStored(limits []*models.ReportLimit)
+}
+
+type reportLimiter struct {
+	config          config.ExportsConfig
+	reports         map[string]config.ReportLimitConfig
+	maxSearchMonths int
+	userRepo        repository.UserRepository
+	logger          *slog.Logger
+
+	mu     sync.RWMutex
+	stored map[string]*models.ReportLimit
+}
+
+// NewReportLimiter creates a new report limiter
+func NewReportLimiter(cfg *config.Config, userRepo repository.UserRepository, logger *slog.Logger) ReportLimiter {
+	exports := cfg.Exports
+	if exports.MaxRows == 0 {
+		exports.MaxRows = defaultExportMaxRows
+	}
+
+	// Viper lower-cases map keys, so role names and report codes are matched case-insensitively
+	roles := make(map[string]int, len(exports.Roles))
+	for name, limit := range exports.Roles {
+		roles[strings.ToLower(name)] = limit
+	}
+	exports.Roles = roles
+
+	reports := make(map[string]config.ReportLimitConfig, len(cfg.Reports.Limits))
+	for report, limit := range cfg.Reports.Limits {
+		reports[strings.ToLower(report)] = limit
+	}
+
+	return &reportLimiter{
+		config:          exports,
+		reports:         reports,
+		maxSearchMonths: cfg.Excel.MaxSearchMonths,
+		userRepo:        userRepo,
+		logger:          logger,
+		stored:          make(map[string]*models.ReportLimit),
+	}
+}
+
+// MaxRows returns the most generous limit among the user's roles, or else the report limit. The
+// query rows of the report bound it either way.
+func (l *reportLimiter) MaxRows(ctx context.Context, userID int, report string) int {
+	limit := l.exportRows(ctx, userID, report)
+	if queryRows := l.QueryRows(report); queryRows > 0 && (limit == 0 || queryRows < limit) {
+		limit = queryRows
+	}
+	return limit
+}
+
+func (l *reportLimiter) exportRows(ctx context.Context, userID int, report string) int {
+	limit := l.config.MaxRows
+	if reportLimit, ok := l.config.Reports[strings.ToLower(report)]; ok {
+		limit = reportLimit
+	}
+
+	if len(l.config.Roles) > 0 && userID > 0 {
+		roles, err := l.userRepo.GetUserRoles(ctx, userID)
+		if err != nil {
+			// Fall back to the report limit rather than failing the export
+			l.logger.ErrorContext(ctx, "Error getting user roles for the export limit", "user_id", userID, "error", err)
+		}
+
+		override, found := 0, false
+		for _, role := range roles {
+			roleLimit, ok := l.config.Roles[strings.ToLower(role.Name)]
+			if !ok {
+				continue
+			}
+			if roleLimit < 0 {
+				return 0
+			}
+			if !found || roleLimit > override {
+				override, found = roleLimit, true
+			}
+		}
+		if found {
+			limit = override
+		}
+	}
+
+	if limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// QueryRows returns the max rows of the report
+func (l *reportLimiter) QueryRows(report string) int {
+	return l.Limit(report).MaxRows
+}
+
+// MaxExportBytes returns the max export size of the report in bytes
+func (l *reportLimiter) MaxExportBytes(report string) int {
+	return l.Limit(report).MaxExportMB << 20
+}
+
+// DateRange returns a resolver limited to the max months of the report
+func (l *reportLimiter) DateRange(report string) daterange.Resolver {
+	return daterange.Resolver{MaxMonths: l.Limit(report).MaxMonths}
+}
+
+// Limit resolves the guardrails of a report: the stored limit, or else the configured one, with
+// 0 fields taking the default and -1 fields removing the limit
+func (l *reportLimiter) Limit(report string) *dto.ReportLimitResponse {
+	report = strings.ToLower(report)
+	response := &dto.ReportLimitResponse{Report: report, Source: dto.ReportLimitSourceDefault}
+
+	l.mu.RLock()
+	stored, ok := l.stored[report]
+	l.mu.RUnlock()
+
+	switch {
+	case ok:
+		updatedAt := stored.UpdatedAt
+		response.MaxMonths, response.MaxRows, response.MaxExportMB = stored.MaxMonths, stored.MaxRows, stored.MaxExportMB
+		response.Source, response.UpdatedBy, response.UpdatedAt = dto.ReportLimitSourceCustom, stored.UpdatedBy, &updatedAt
+	default:
+		if configured, ok := l.reports[report]; ok {
+			response.MaxMonths, response.MaxRows, response.MaxExportMB = configured.MaxMonths, configured.MaxRows, configured.MaxExportMB
+			response.Source = dto.ReportLimitSourceConfig
+		}
+	}
+
+	if response.MaxMonths == 0 {
+		response.MaxMonths = l.maxSearchMonths
+	}
+	response.MaxMonths = max(response.MaxMonths, 0)
+	response.MaxRows = max(response.MaxRows, 0)
+	response.MaxExportMB = max(response.MaxExportMB, 0)
+	return response
+}
+
+// Reports returns the reports with a configured or stored limit
+func (l *reportLimiter) Reports() []string {
+	reports := make([]string, 0, len(l.reports))
+	for report := range l.reports {
+		reports = append(reports, report)
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for report := range l.stored {
+		if _, configured := l.reports[report]; !configured {
+			reports = append(reports, report)
+		}
+	}
+	return reports
+}
+
+// SetStored replaces the limits set at runtime
+func (l *reportLimiter) SetStored(limits []*models.ReportLimit) {
+	stored := make(map[string]*models.ReportLimit, len(limits))
+	for _, limit := range limits {
+		stored[strings.ToLower(limit.Report)] = limit
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stored = stored
+}
+
+// exportRowLimit returns the limit for a query whose result is checked with checkExportRows:
+// one row more than allowed, so an oversized export is detected without reading all of it
+func exportRowLimit(maxRows int) int {
+	if maxRows <= 0 {
+		return 0
+	}
+	return maxRows + 1
+}
+
+// checkExportRows rejects an export with more rows than allowed
+func checkExportRows(rows, maxRows int) error {
+	if maxRows > 0 && rows > maxRows {
+		return fmt.Errorf("%w: the export has more than %d rows, which is the most you can export at once; narrow the date range or filters", ErrExportTooLarge, maxRows)
+	}
+	return nil
+}
+
+// checkQueryRows rejects a report query that returned more rows than its report allows; query
+// with exportRowLimit(maxRows) to stop reading after the first row too many
+func checkQueryRows(report string, rows, maxRows int) error {
+	if maxRows > 0 && rows > maxRows {
+		return fmt.Errorf("%w: %s returns more than %d rows, the most it may return at once; narrow the date range or filters", ErrReportTooLarge, report, maxRows)
+	}
+	return nil
+}
+
+// checkExportSize rejects an export file larger than its report allows
+func checkExportSize(report string, size, maxBytes int) error {
+	if maxBytes > 0 && size > maxBytes {
+		return fmt.Errorf("%w: the %s export is %.1f MB, above its limit of %d MB; narrow the date range or filters", ErrReportTooLarge, report, float64(size)/(1<<20), maxBytes>>20)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrReportLimitNotFound is returned when deleting the limit of a report that has none set at runtime
+var ErrReportLimitNotFound = apperror.NotFound("report limit not found")
+
+// ReportLimitService manages the report guardrails set at runtime, so a runaway report can be
+// reined in without a deploy
+type ReportLimitService interface {
+	List(ctx context.Context) ([]*dto.ReportLimitResponse, error)
+	Set(ctx context.Context, userID int, report string, request *dto.ReportLimitRequest) (*dto.ReportLimitResponse, error)
+	Delete(ctx context.Context, report string) error
+	Reload(ctx context.Context) error
+}
+
+type reportLimitService struct {
+	limitRepo           repository.ReportLimitRepository
+	limiter             ReportLimiter
+	reportEngineService ReportEngineService
+}
+
+// NewReportLimitService creates a new report limit service
+func NewReportLimitService(
+	limitRepo repository.ReportLimitRepository,
+	limiter ReportLimiter,
+	reportEngineService ReportEngineService,
+) ReportLimitService {
+	return &reportLimitService{
+		limitRepo:           limitRepo,
+		limiter:             limiter,
+		reportEngineService: reportEngineService,
+	}
+}
+
+// List returns the effective limits of the built-in reports and of the reports with a limit
+// configured or set at runtime, ordered by report. The stored limits are reloaded on the way.
+func (s *reportLimitService) List(ctx context.Context) ([]*dto.ReportLimitResponse, error) {
+	stored, err := s.limitRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.limiter.SetStored(stored)
+
+	reports := make(map[string]bool)
+	for report := range defaultReportTemplates {
+		reports[report] = true
+	}
+	for _, report := range s.limiter.Reports() {
+		reports[report] = true
+	}
+
+	response := make([]*dto.ReportLimitResponse, 0, len(reports))
+	for report := range reports {
+		response = append(response, s.limiter.Limit(report))
+	}
+	sort.Slice(response, func(i, j int) bool {
+		return response[i].Report < response[j].Report
+	})
+
+	return response, nil
+}
+
+// Set stores the limit of a report and applies it right away
+func (s *reportLimitService) Set(ctx context.Context, userID int, report string, request *dto.ReportLimitRequest) (*dto.ReportLimitResponse, error) {
+	report = strings.ToLower(strings.TrimSpace(report))
+	if _, builtIn := defaultReportTemplates[report]; !builtIn {
+		if _, err := s.reportEngineService.GetDefinition(ctx, report); err != nil {
+			return nil, err
+		}
+	}
+
+	limit := &models.ReportLimit{
+		Report:      report,
+		MaxMonths:   request.MaxMonths,
+		MaxRows:     request.MaxRows,
+		MaxExportMB: request.MaxExportMB,
+		UpdatedBy:   userID,
+	}
+
+	if err := s.limitRepo.Upsert(ctx, limit); err != nil {
+		return nil, err
+	}
+	if err := s.Reload(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.limiter.Limit(report), nil
+}
+
+// Delete removes the limit set at runtime of a report; its configured limit applies again
+func (s *reportLimitService) Delete(ctx context.Context, report string) error {
+	if err := s.limitRepo.Delete(ctx, strings.ToLower(report)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", ErrReportLimitNotFound, report)
+		}
+		return err
+	}
+
+	return s.Reload(ctx)
+}
+
+// Reload reads the limits set at runtime into the limiter. Other instances pick up changes made
+// elsewhere when they reload.
+func (s *reportLimitService) Reload(ctx context.Context) error {
+	limits, err := s.limitRepo.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.limiter.SetStored(limits)
+	return nil
+}
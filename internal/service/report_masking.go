@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/repository"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// defaultMask replaces the text of masked columns unless a rule sets its own
+const defaultMask = "***"
+
+// maskableReports are the item types of the reports whose columns may be masked, by report code
+var maskableReports = map[string]reflect.Type{
+	"assistant230": reflect.TypeOf(dto.Asisstant230ReportItem{}),
+	"assistant610": reflect.TypeOf(dto.Asisstant610ReportItem{}),
+	"assistant340": reflect.TypeOf(dto.Assistant340ReportItem{}),
+}
+
+// MaskedColumns are the columns masked for a user, keyed by their JSON names, with the text shown
+// in their place
+type MaskedColumns map[string]string
+
+// Has reports whether the column is masked
+func (m MaskedColumns) Has(column string) bool {
+	_, ok := m[column]
+	return ok
+}
+
+// Rows shows the mask text in the masked columns of export rows, so masked numbers do not read
+// as 0. Rows without the column, such as total rows, are left as they are.
+func (m MaskedColumns) Rows(rows []map[string]interface{}) {
+	for _, row := range rows {
+		for column, mask := range m {
+			if _, ok := row[column]; ok {
+				row[column] = mask
+			}
+		}
+	}
+}
+
+// ReportMasker hides the report columns a user may not see, so one report serves users of
+// different sensitivity levels. The report services mask their items before they are returned or
+// written to a file.
+type ReportMasker interface {
+	// Mask masks the columns of the report items, a slice of the item type of the report, in
+	// place and returns them. Masked text reads as the mask, other values are cleared.
+	Mask(ctx context.Context, userID int, report string, items interface{}) MaskedColumns
+}
+
+// maskRule is a configured rule with its columns resolved to item fields
+type maskRule struct {
+	columns         []string
+	roles           map[string]bool
+	unlessOperation string
+	mask            string
+}
+
+type reportMasker struct {
+	rules            map[string][]maskRule
+	fields           map[string]map[string]int // field index by JSON name, by report code
+	userRepo         repository.UserRepository
+	operationService OperationService
+	logger           *slog.Logger
+}
+
+// NewReportMasker checks the masking rules of the reports against their columns
+func NewReportMasker(
+	cfg config.ReportsConfig,
+	userRepo repository.UserRepository,
+	operationService OperationService,
+	logger *slog.Logger,
+) (ReportMasker, error) {
+	masker := &reportMasker{
+		rules:            make(map[string][]maskRule, len(cfg.Masking)),
+		fields:           make(map[string]map[string]int, len(maskableReports)),
+		userRepo:         userRepo,
+		operationService: operationService,
+		logger:           logger,
+	}
+	for report, itemType := range maskableReports {
+		masker.fields[report] = jsonFieldIndexes(itemType)
+	}
+
+	// Viper lower-cases map keys, so report codes and role names are matched case-insensitively
+	for report, rules := range cfg.Masking {
+		report = strings.ToLower(report)
+		fields, ok := masker.fields[report]
+		if !ok {
+			return nil, fmt.Errorf("reports.masking: report %s does not support masking", report)
+		}
+
+		for i, rule := range rules {
+			if len(rule.Columns) == 0 {
+				return nil, fmt.Errorf("reports.masking.%s[%d]: no columns", report, i)
+			}
+			resolved := maskRule{
+				roles:           make(map[string]bool, len(rule.Roles)),
+				unlessOperation: rule.UnlessOperation,
+				mask:            rule.Mask,
+			}
+			if resolved.mask == "" {
+				resolved.mask = defaultMask
+			}
+			for _, column := range rule.Columns {
+				column = strings.ToLower(strings.TrimSpace(column))
+				if _, ok := fields[column]; !ok {
+					return nil, fmt.Errorf("reports.masking.%s[%d]: unknown column %s", report, i, column)
+				}
+				resolved.columns = append(resolved.columns, column)
+			}
+			for _, role := range rule.Roles {
+				resolved.roles[strings.ToLower(role)] = true
+			}
+			masker.rules[report] = append(masker.rules[report], resolved)
+		}
+	}
+
+	return masker, nil
+}
+
+// Mask masks the columns of every rule of the report that applies to the user: the user is a
+// member of one of its roles, or it has none, and lacks its operation. When the roles or
+// operations of the user cannot be read the columns are masked. Reports run without a user, such
+// as the BI feeds authenticated with an API key, are not masked.
+func (m *reportMasker) Mask(ctx context.Context, userID int, report string, items interface{}) MaskedColumns {
+	rules := m.rules[report]
+	if userID == 0 || len(rules) == 0 {
+		return nil
+	}
+
+	masked := make(MaskedColumns)
+	var roles map[string]bool
+	for _, rule := range rules {
+		if len(rule.roles) > 0 {
+			if roles == nil {
+				roles = m.userRoles(ctx, userID)
+			}
+			if !memberOfAny(roles, rule.roles) {
+				continue
+			}
+		}
+		if rule.unlessOperation != "" {
+			allowed, err := m.operationService.CheckUserAccess(ctx, userID, rule.unlessOperation)
+			if err != nil {
+				m.logger.ErrorContext(ctx, "Error checking operation for column masking, masking the columns",
+					"user_id", userID, "report", report, "operation", rule.unlessOperation, "error", err)
+			}
+			if allowed {
+				continue
+			}
+		}
+		for _, column := range rule.columns {
+			if _, ok := masked[column]; !ok {
+				masked[column] = rule.mask
+			}
+		}
+	}
+	if len(masked) == 0 {
+		return nil
+	}
+
+	m.maskItems(report, items, masked)
+	return masked
+}
+
+// userRoles returns the lower-cased role names of a user. On error every role is assumed, so the
+// rules of all roles apply.
+func (m *reportMasker) userRoles(ctx context.Context, userID int) map[string]bool {
+	roles, err := m.userRepo.GetUserRoles(ctx, userID)
+	if err != nil {
+		m.logger.ErrorContext(ctx, "Error getting user roles for column masking, masking the columns", "user_id", userID, "error", err)
+		return nil
+	}
+
+	names := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		names[strings.ToLower(role.Name)] = true
+	}
+	return names
+}
+
+// maskItems sets the masked fields of every item to the mask, or to their zero value when they
+// are not text
+func (m *reportMasker) maskItems(report string, items interface{}, masked MaskedColumns) {
+	slice := reflect.ValueOf(items)
+	if slice.Kind() != reflect.Slice || slice.Type().Elem() != maskableReports[report] {
+		// A programming error: the report services pass their own items
+		panic(fmt.Sprintf("masking %s: expected []%s, got %T", report, maskableReports[report], items))
+	}
+
+	fields := m.fields[report]
+	for i := 0; i < slice.Len(); i++ {
+		item := slice.Index(i)
+		for column, mask := range masked {
+			field := item.Field(fields[column])
+			if field.Kind() == reflect.String {
+				field.SetString(mask)
+			} else {
+				field.Set(reflect.Zero(field.Type()))
+			}
+		}
+	}
+}
+
+// jsonFieldIndexes maps the JSON names of the fields of a struct type to their indexes
+func jsonFieldIndexes(itemType reflect.Type) map[string]int {
+	indexes := make(map[string]int, itemType.NumField())
+	for i := 0; i < itemType.NumField(); i++ {
+		name, _, _ := strings.Cut(itemType.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			indexes[name] = i
+		}
+	}
+	return indexes
+}
+
+// memberOfAny reports whether any of the roles is among those of the user. A nil set of user
+// roles, which could not be read, is a member of every role.
+func memberOfAny(userRoles, roles map[string]bool) bool {
+	if userRoles == nil {
+		return true
+	}
+	for role := range roles {
+		if userRoles[role] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/database"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/translate"
+	"erp-excel/internal/utils"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// defaultReportTemplates are the titles used when a report has no configured template. The
+// report_title_<report> label of the request's language replaces them.
+var defaultReportTemplates = map[string]config.ReportTemplateConfig{
+	"assistant230":   {Title: "Export Sales 230 ({status}) {period}"},
+	"assistant610":   {Title: "Export Sales 610 {period}"},
+	"assistant340":   {Title: "Purchases 340 {period}"},
+	"item_inventory": {Title: "Inventory {period}"},
+	"stock_balance":  {Title: "Stock Balance as of {to}"},
+	"reconciliation": {Title: "Reconciliation 230/610 {period}"},
+}
+
+const (
+	// defaultReportTitle is the title of reports without a built-in or configured template
+	defaultReportTitle = "{name} {period}"
+
+	// templateFileNameLength is the number of characters kept from a rendered file name template
+	templateFileNameLength = 100
+)
+
+// ReportNameData holds the values available to report title and file name templates
+type ReportNameData struct {
+	Report       string // report code, e.g. assistant230
+	Name         string // display name, defaults to the report code
+	Status       string // invoice status filter, if the report has one
+	FromDate     time.Time
+	ToDate       time.Time
+	UserID       int
+	DepartmentID int
+
+	// Report-specific templates, e.g. from a report definition; they win over the configuration
+	TitleTemplate    string
+	FileNameTemplate string
+}
+
+// ReportNamer builds report titles and export file names from per-report templates.
+// Supported placeholders: {report}, {name}, {title}, {status}, {period}, {from}, {to},
+// {department}, {user}, {company}, {yyyy}, {MM}, {dd}.
+type ReportNamer interface {
+	Title(ctx context.Context, data ReportNameData) string
+	FileName(ctx context.Context, data ReportNameData, title string) string
+}
+
+type reportNamer struct {
+	templates      map[string]config.ReportTemplateConfig
+	companies      map[string]config.ERPCompanyConfig
+	userRepo       repository.UserRepository
+	departmentRepo repository.DepartmentRepository
+	logger         *slog.Logger
+}
+
+// NewReportNamer creates a report namer from the configured templates
+func NewReportNamer(
+	templates map[string]config.ReportTemplateConfig,
+	companies map[string]config.ERPCompanyConfig,
+	userRepo repository.UserRepository,
+	departmentRepo repository.DepartmentRepository,
+	logger *slog.Logger,
+) ReportNamer {
+	return &reportNamer{
+		templates:      templates,
+		companies:      companies,
+		userRepo:       userRepo,
+		departmentRepo: departmentRepo,
+		logger:         logger,
+	}
+}
+
+// Title renders the title template of the report
+func (n *reportNamer) Title(ctx context.Context, data ReportNameData) string {
+	template := data.TitleTemplate
+	if template == "" {
+		template = n.templates[data.Report].Title
+	}
+	if template == "" {
+		template = translate.Text(ctx, "report_title_"+data.Report, defaultReportTemplates[data.Report].Title)
+	}
+	if template == "" {
+		template = defaultReportTitle
+	}
+
+	return n.render(ctx, template, data, "")
+}
+
+// FileName renders the file name template of the report. Without a template the name is derived
+// from the title as before. A timestamp is always appended so exports never overwrite each other.
+func (n *reportNamer) FileName(ctx context.Context, data ReportNameData, title string) string {
+	template := data.FileNameTemplate
+	if template == "" {
+		template = n.templates[data.Report].FileName
+	}
+	if template == "" {
+		return utils.ExcelFileName(title, utils.TitleFileNameLength)
+	}
+
+	name := strings.TrimSuffix(n.render(ctx, template, data, title), ".xlsx")
+	if name == "" {
+		return utils.ExcelFileName(title, utils.TitleFileNameLength)
+	}
+
+	return utils.ExcelFileName(name, templateFileNameLength)
+}
+
+// render replaces the placeholders of a template. Users and departments are only looked up when
+// the template uses them.
+func (n *reportNamer) render(ctx context.Context, template string, data ReportNameData, title string) string {
+	name := data.Name
+	if name == "" {
+		name = data.Report
+	}
+
+	var period, from, to string
+	if !data.FromDate.IsZero() && !data.ToDate.IsZero() {
+		from = data.FromDate.Format("02/01/2006")
+		to = data.ToDate.Format("02/01/2006")
+		period = strings.NewReplacer("{from}", from, "{to}", to).Replace(translate.Text(ctx, "report_period", "from {from} to {to}"))
+	}
+
+	var status string
+	if data.Status != "" {
+		status = translate.Key(ctx, data.Status)
+	}
+
+	var department, user string
+	if strings.Contains(template, "{department}") && data.DepartmentID > 0 {
+		if found, err := n.departmentRepo.GetByID(ctx, data.DepartmentID); err != nil {
+			n.logger.ErrorContext(ctx, "Error getting department for report title", "department_id", data.DepartmentID, "error", err)
+		} else {
+			department = found.Name
+		}
+	}
+	if strings.Contains(template, "{user}") && data.UserID > 0 {
+		if found, err := n.userRepo.GetByID(ctx, data.UserID); err != nil {
+			n.logger.ErrorContext(ctx, "Error getting user for report title", "user_id", data.UserID, "error", err)
+		} else {
+			user = found.FullName
+			if user == "" {
+				user = found.Username
+			}
+		}
+	}
+
+	var company string
+	if code := database.CompanyFromContext(ctx); code != "" {
+		company = n.companies[code].Name
+	}
+
+	now := time.Now()
+	replacer := strings.NewReplacer(
+		"{report}", data.Report,
+		"{name}", name,
+		"{title}", title,
+		"{status}", status,
+		"{period}", period,
+		"{from}", from,
+		"{to}", to,
+		"{department}", department,
+		"{user}", user,
+		"{company}", company,
+		"{yyyy}", now.Format("2006"),
+		"{MM}", now.Format("01"),
+		"{dd}", now.Format("02"),
+	)
+
+	// Placeholders without a value would leave double spaces and empty brackets behind
+	rendered := strings.ReplaceAll(replacer.Replace(template), "()", "")
+	return strings.Join(strings.Fields(rendered), " ")
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"erp-excel/internal/dto"
+	"erp-excel/internal/repository"
+	"strings"
+)
+
+// MaxReportPageSize caps the rows of one page of the report data endpoints
+const MaxReportPageSize = 1000
+
+// reportPage converts a page request to the page the repositories query
+func reportPage(request dto.ReportPageRequest) repository.ReportPage {
+	page := repository.ReportPage{Limit: request.Limit}
+	if request.Page > 1 && request.Limit > 0 {
+		page.Offset = (request.Page - 1) * request.Limit
+	}
+
+	sortBy := strings.TrimSpace(request.Sort)
+	if strings.HasPrefix(sortBy, "-") {
+		page.SortDesc = true
+		sortBy = sortBy[1:]
+	}
+	page.SortBy = strings.ToLower(sortBy)
+
+	return page
+}
+
+// reportFilter converts the filters of a report data request to the filter the repositories query
+func reportFilter(filters *dto.ReportFilters) repository.ReportFilter {
+	if filters == nil {
+		return repository.ReportFilter{}
+	}
+	return repository.ReportFilter{
+		CustomerName:  strings.TrimSpace(filters.CustomerName),
+		InvoiceNumber: strings.TrimSpace(filters.InvoiceNumber),
+		CurrencyType:  strings.ToUpper(strings.TrimSpace(filters.CurrencyType)),
+		OrderNumber:   strings.TrimSpace(filters.OrderNumber),
+	}
+}
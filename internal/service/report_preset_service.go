@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+var (
+	// ErrReportPresetNotFound is returned for an unknown preset or a preset of another user
+	ErrReportPresetNotFound = apperror.NotFound("report preset not found")
+	// ErrReportPresetExists is returned when the user already has a preset with the name
+	ErrReportPresetExists = apperror.Conflict("report preset already exists")
+	// ErrInvalidReportPreset is returned when a preset's report or parameters are invalid
+	ErrInvalidReportPreset = apperror.Validation("invalid report preset")
+)
+
+// reportPresetLimit caps the presets one user can save
+const reportPresetLimit = 100
+
+// ReportPresetService manages the report presets of the current user
+type ReportPresetService interface {
+	Create(ctx context.Context, userID int, request *dto.ReportPresetRequest) (*dto.ReportPresetResponse, error)
+	Update(ctx context.Context, userID int, id int, request *dto.ReportPresetRequest) (*dto.ReportPresetResponse, error)
+	Delete(ctx context.Context, userID int, id int) error
+	Get(ctx context.Context, userID int, id int) (*dto.ReportPresetResponse, error)
+	List(ctx context.Context, userID int) ([]*dto.ReportPresetResponse, error)
+}
+
+type reportPresetService struct {
+	presetRepo          repository.ReportPresetRepository
+	reportEngineService ReportEngineService
+	logger              *slog.Logger
+}
+
+// NewReportPresetService creates a new report preset service
+func NewReportPresetService(
+	presetRepo repository.ReportPresetRepository,
+	reportEngineService ReportEngineService,
+	logger *slog.Logger,
+) ReportPresetService {
+	return &reportPresetService{
+		presetRepo:          presetRepo,
+		reportEngineService: reportEngineService,
+		logger:              logger,
+	}
+}
+
+// Create saves a new preset of the user
+func (s *reportPresetService) Create(ctx context.Context, userID int, request *dto.ReportPresetRequest) (*dto.ReportPresetResponse, error) {
+	preset := &models.ReportPreset{UserID: userID}
+	if err := s.applyRequest(ctx, preset, request); err != nil {
+		return nil, err
+	}
+
+	presets, err := s.presetRepo.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(presets) >= reportPresetLimit {
+		return nil, fmt.Errorf("%w: at most %d presets can be saved", ErrInvalidReportPreset, reportPresetLimit)
+	}
+	if err := checkPresetName(presets, preset); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.presetRepo.Create(ctx, preset); err != nil {
+		return nil, err
+	}
+
+	return reportPresetResponse(preset)
+}
+
+// Update replaces the name, report and parameters of a preset
+func (s *reportPresetService) Update(ctx context.Context, userID int, id int, request *dto.ReportPresetRequest) (*dto.ReportPresetResponse, error) {
+	preset, err := s.getPreset(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.applyRequest(ctx, preset, request); err != nil {
+		return nil, err
+	}
+
+	presets, err := s.presetRepo.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPresetName(presets, preset); err != nil {
+		return nil, err
+	}
+
+	if err := s.presetRepo.Update(ctx, preset); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrReportPresetNotFound
+		}
+		return nil, err
+	}
+
+	return reportPresetResponse(preset)
+}
+
+// Delete removes a preset
+func (s *reportPresetService) Delete(ctx context.Context, userID int, id int) error {
+	if _, err := s.getPreset(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.presetRepo.Delete(ctx, id)
+}
+
+// Get returns one preset
+func (s *reportPresetService) Get(ctx context.Context, userID int, id int) (*dto.ReportPresetResponse, error) {
+	preset, err := s.getPreset(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return reportPresetResponse(preset)
+}
+
+// List returns the user's presets by name
+func (s *reportPresetService) List(ctx context.Context, userID int) ([]*dto.ReportPresetResponse, error) {
+	presets, err := s.presetRepo.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*dto.ReportPresetResponse, 0, len(presets))
+	for _, preset := range presets {
+		response, err := reportPresetResponse(preset)
+		if err != nil {
+			s.logger.WarnContext(ctx, "Skipping report preset with unreadable parameters", "preset_id", preset.ID, "error", err)
+			continue
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+// getPreset gets a preset, hiding the presets of other users
+func (s *reportPresetService) getPreset(ctx context.Context, userID int, id int) (*models.ReportPreset, error) {
+	preset, err := s.presetRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrReportPresetNotFound
+		}
+		return nil, err
+	}
+	if preset.UserID != userID {
+		return nil, ErrReportPresetNotFound
+	}
+
+	return preset, nil
+}
+
+// applyRequest checks the report and parameters of a request and copies them to the preset. The
+// user's access to the report is checked when the preset is run, as with any other request.
+func (s *reportPresetService) applyRequest(ctx context.Context, preset *models.ReportPreset, request *dto.ReportPresetRequest) error {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidReportPreset)
+	}
+	report := strings.TrimSpace(request.Report)
+
+	if _, _, err := reportDateRange.Resolve(&request.DateRangeRequest); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidReportPreset, err)
+	}
+
+	if err := checkReportCode(ctx, s.reportEngineService, report); err != nil {
+		if errors.Is(err, ErrReportNotFound) {
+			return fmt.Errorf("%w: unknown report %s", ErrInvalidReportPreset, report)
+		}
+		return err
+	}
+	if columns, ok := reportColumns[report]; ok {
+		if _, err := columns(&request.DateRangeRequest); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidReportPreset, err)
+		}
+	}
+
+	parameters, err := json.Marshal(dto.ReportRunRequest{
+		DateRangeRequest: request.DateRangeRequest,
+		Params:           request.Params,
+	})
+	if err != nil {
+		return fmt.Errorf("error marshalling preset parameters: %w", err)
+	}
+
+	preset.Name = name
+	preset.Report = report
+	preset.Parameters = string(parameters)
+	return nil
+}
+
+// checkPresetName rejects a name the user already gave another preset
+func checkPresetName(presets []*models.ReportPreset, preset *models.ReportPreset) error {
+	for _, existing := range presets {
+		if existing.ID != preset.ID && strings.EqualFold(existing.Name, preset.Name) {
+			return fmt.Errorf("%w: %s", ErrReportPresetExists, preset.Name)
+		}
+	}
+	return nil
+}
+
+// reportPresetResponse decodes the stored parameters of a preset
+func reportPresetResponse(preset *models.ReportPreset) (*dto.ReportPresetResponse, error) {
+	var parameters dto.ReportRunRequest
+	if err := json.Unmarshal([]byte(preset.Parameters), &parameters); err != nil {
+		return nil, fmt.Errorf("error unmarshalling preset parameters: %w", err)
+	}
+	// Presets saved before the snake_case fields may carry the deprecated spellings
+	parameters.Normalize()
+
+	return &dto.ReportPresetResponse{
+		ID:         preset.ID,
+		Name:       preset.Name,
+		Report:     preset.Report,
+		Parameters: parameters,
+		CreatedAt:  preset.CreatedAt,
+		UpdatedAt:  preset.UpdatedAt,
+	}, nil
+}
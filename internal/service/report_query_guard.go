@@ -0,0 +1,127 @@
+package service
+
+import (
+	"erp-excel/internal/models"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// deniedQueryKeywords may not appear in a custom report query. Besides DML and DDL this covers
+// statements that run code, change the session or reach other servers.
+var deniedQueryKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "TRUNCATE": true,
+	"CREATE": true, "ALTER": true, "DROP": true, "GRANT": true, "REVOKE": true, "DENY": true,
+	"EXEC": true, "EXECUTE": true, "INTO": true, "USE": true, "GO": true,
+	"DECLARE": true, "SET": true, "BEGIN": true, "COMMIT": true, "ROLLBACK": true, "SAVE": true, "TRANSACTION": true,
+	"OPENROWSET": true, "OPENQUERY": true, "OPENDATASOURCE": true, "OPENXML": true, "BULK": true,
+	"BACKUP": true, "RESTORE": true, "DBCC": true, "SHUTDOWN": true, "KILL": true, "RECONFIGURE": true,
+	"CHECKPOINT": true, "WAITFOR": true, "WRITETEXT": true, "UPDATETEXT": true, "READTEXT": true,
+	"RAISERROR": true, "THROW": true,
+}
+
+// validateReportQuery checks that a custom report query is a single read-only SELECT and that
+// every @parameter it references is declared. Comments and string literals are ignored, and
+// bracketed or quoted identifiers are never treated as keywords.
+func validateReportQuery(query string, parameters []models.ReportParameter) error {
+	declared := make(map[string]bool, len(parameters))
+	for _, param := range parameters {
+		declared[strings.ToUpper(param.Name)] = true
+	}
+
+	runes := []rune(strings.TrimSpace(query))
+	// A single trailing semicolon is harmless
+	if len(runes) > 0 && runes[len(runes)-1] == ';' {
+		runes = runes[:len(runes)-1]
+	}
+
+	firstKeyword := ""
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+		switch {
+		case ch == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case ch == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			for i += 2; i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/'); i++ {
+			}
+			if i+1 >= len(runes) {
+				return errors.New("query has an unterminated comment")
+			}
+			i++
+		case ch == '\'':
+			i++
+			for ; i < len(runes); i++ {
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			if i >= len(runes) {
+				return errors.New("query has an unterminated string")
+			}
+		case ch == '[' || ch == '"':
+			closing := ']'
+			if ch == '"' {
+				closing = '"'
+			}
+			for i++; i < len(runes) && runes[i] != closing; i++ {
+			}
+			if i >= len(runes) {
+				return errors.New("query has an unterminated identifier")
+			}
+		case ch == ';':
+			return errors.New("query must be a single statement")
+		case ch == '@':
+			start := i + 1
+			if start < len(runes) && runes[start] == '@' {
+				// @@ system functions such as @@ROWCOUNT
+				for i = start + 1; i < len(runes) && isIdentifierRune(runes[i]); i++ {
+				}
+				i--
+				continue
+			}
+			for i = start; i < len(runes) && isIdentifierRune(runes[i]); i++ {
+			}
+			name := string(runes[start:i])
+			i--
+			if name == "" {
+				return errors.New("query has an empty parameter name")
+			}
+			if !declared[strings.ToUpper(name)] {
+				return fmt.Errorf("query parameter @%s is not declared", name)
+			}
+		case unicode.IsLetter(ch) || ch == '_' || ch == '#':
+			start := i
+			for ; i < len(runes) && (isIdentifierRune(runes[i]) || runes[i] == '#'); i++ {
+			}
+			word := strings.ToUpper(string(runes[start:i]))
+			i--
+			if firstKeyword == "" {
+				firstKeyword = word
+			}
+			if strings.HasPrefix(word, "#") {
+				return errors.New("query may not use temporary tables")
+			}
+			if deniedQueryKeywords[word] || strings.HasPrefix(word, "SP_") || strings.HasPrefix(word, "XP_") {
+				return fmt.Errorf("query may not contain %s (use [brackets] for a column of that name)", word)
+			}
+		}
+	}
+
+	if firstKeyword != "SELECT" && firstKeyword != "WITH" {
+		return errors.New("query must start with SELECT or WITH")
+	}
+
+	return nil
+}
+
+// isIdentifierRune reports whether r can continue a T-SQL identifier
+func isIdentifierRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$'
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"erp-excel/internal/models"
+	"testing"
+)
+
+func TestValidateReportQuery(t *testing.T) {
+	parameters := []models.ReportParameter{{Name: "FromDate"}, {Name: "ToDate"}}
+
+	tests := []struct {
+		name    string
+		query   string
+		wantErr string
+	}{
+		{name: "select", query: "SELECT a, b FROM dbo.t WHERE d BETWEEN @FromDate AND @todate"},
+		{name: "cte", query: "WITH x AS (SELECT a FROM t) SELECT a FROM x"},
+		{name: "trailing semicolon", query: "SELECT 1;"},
+		{name: "system function", query: "SELECT @@ROWCOUNT"},
+		{name: "lower case", query: "select a from t"},
+
+		// Comments are skipped, whatever they hide, and cannot hide what follows them
+		{name: "keyword in block comment", query: "SELECT a /* DELETE FROM t */ FROM t"},
+		{name: "keyword in line comment", query: "SELECT a -- DROP TABLE t\nFROM t"},
+		{name: "statement after block comment", query: "SELECT a FROM t /* */ DELETE FROM t", wantErr: "query may not contain DELETE (use [brackets] for a column of that name)"},
+		{name: "statement after line comment", query: "SELECT a FROM t --\nDELETE FROM t", wantErr: "query may not contain DELETE (use [brackets] for a column of that name)"},
+		{name: "comment before statement", query: "/* SELECT */ DELETE FROM t", wantErr: "query may not contain DELETE (use [brackets] for a column of that name)"},
+		{name: "nested block comment", query: "SELECT a /* /* */ DELETE */ FROM t", wantErr: "query may not contain DELETE (use [brackets] for a column of that name)"},
+		{name: "comment opener in string", query: "SELECT '/*' FROM t; DELETE FROM t", wantErr: "query must be a single statement"},
+		{name: "line comment in string", query: "SELECT '--' FROM t WHERE 1 = 1 DELETE FROM t", wantErr: "query may not contain DELETE (use [brackets] for a column of that name)"},
+		{name: "unterminated comment", query: "SELECT a FROM t /* DELETE", wantErr: "query has an unterminated comment"},
+		{name: "comment only", query: "-- SELECT 1", wantErr: "query must start with SELECT or WITH"},
+
+		// Strings and bracketed or quoted identifiers are never keywords
+		{name: "keyword in string", query: "SELECT a FROM t WHERE b = 'EXEC xp_cmdshell'"},
+		{name: "escaped quote in string", query: "SELECT a FROM t WHERE b = 'it''s; DELETE'"},
+		{name: "unicode string", query: "SELECT a FROM t WHERE b = N'DROP'"},
+		{name: "bracketed keyword", query: "SELECT [Delete], [Into] FROM t"},
+		{name: "quoted keyword", query: `SELECT "Update" FROM t`},
+		{name: "bracket in string", query: "SELECT a FROM t WHERE b = '[' AND c = ']'"},
+		{name: "quote in brackets", query: "SELECT [it's] FROM t"},
+		{name: "escaped closing bracket", query: "SELECT [a]]DELETE] FROM t", wantErr: "query may not contain DELETE (use [brackets] for a column of that name)"},
+		{name: "escaped quote ends string", query: "SELECT 'a''' DELETE FROM t", wantErr: "query may not contain DELETE (use [brackets] for a column of that name)"},
+		{name: "unterminated string", query: "SELECT 'a FROM t", wantErr: "query has an unterminated string"},
+		{name: "unterminated identifier", query: "SELECT [a FROM t", wantErr: "query has an unterminated identifier"},
+
+		// Data changes behind a read-only start
+		{name: "with delete", query: "WITH x AS (SELECT a FROM t) DELETE FROM x", wantErr: "query may not contain DELETE (use [brackets] for a column of that name)"},
+		{name: "with update", query: "WITH x AS (SELECT a FROM t) UPDATE x SET a = 1", wantErr: "query may not contain UPDATE (use [brackets] for a column of that name)"},
+		{name: "with merge", query: "WITH x AS (SELECT a FROM t) MERGE t USING x ON 1 = 1 WHEN MATCHED THEN DELETE", wantErr: "query may not contain MERGE (use [brackets] for a column of that name)"},
+		{name: "select into", query: "SELECT a INTO dbo.copy FROM t", wantErr: "query may not contain INTO (use [brackets] for a column of that name)"},
+		{name: "insert", query: "INSERT INTO t VALUES (1)", wantErr: "query may not contain INSERT (use [brackets] for a column of that name)"},
+
+		// Chained statements
+		{name: "chained delete", query: "SELECT 1; DELETE FROM t", wantErr: "query must be a single statement"},
+		{name: "chained select", query: "SELECT 1; SELECT 2", wantErr: "query must be a single statement"},
+		{name: "two trailing semicolons", query: "SELECT 1;;", wantErr: "query must be a single statement"},
+		{name: "chained without semicolon", query: "SELECT 1 DROP TABLE t", wantErr: "query may not contain DROP (use [brackets] for a column of that name)"},
+
+		// Running code
+		{name: "exec", query: "EXEC dbo.usp_Report", wantErr: "query may not contain EXEC (use [brackets] for a column of that name)"},
+		{name: "execute string", query: "SELECT 1 EXECUTE('DELETE FROM t')", wantErr: "query may not contain EXECUTE (use [brackets] for a column of that name)"},
+		{name: "xp call", query: "SELECT * FROM master.dbo.xp_dirtree('c:\\')", wantErr: "query may not contain XP_DIRTREE (use [brackets] for a column of that name)"},
+		{name: "sp call", query: "SELECT * FROM sys.sp_helpdb()", wantErr: "query may not contain SP_HELPDB (use [brackets] for a column of that name)"},
+		{name: "upper case xp", query: "SELECT XP_CMDSHELL", wantErr: "query may not contain XP_CMDSHELL (use [brackets] for a column of that name)"},
+
+		// Temporary tables
+		{name: "temp table", query: "SELECT a FROM #orders", wantErr: "query may not use temporary tables"},
+		{name: "global temp table", query: "SELECT a FROM ##orders", wantErr: "query may not use temporary tables"},
+		{name: "temp table in join", query: "SELECT a FROM t JOIN #x ON t.id = #x.id", wantErr: "query may not use temporary tables"},
+
+		// Other servers and files
+		{name: "openrowset", query: "SELECT * FROM OPENROWSET('SQLNCLI', 'Server=x;', 'SELECT 1')", wantErr: "query may not contain OPENROWSET (use [brackets] for a column of that name)"},
+		{name: "openquery", query: "SELECT * FROM OPENQUERY(linked, 'SELECT 1')", wantErr: "query may not contain OPENQUERY (use [brackets] for a column of that name)"},
+		{name: "opendatasource", query: "SELECT * FROM OPENDATASOURCE('SQLNCLI', 'Data Source=x').db.dbo.t", wantErr: "query may not contain OPENDATASOURCE (use [brackets] for a column of that name)"},
+		{name: "bulk", query: "SELECT * FROM OPENROWSET(BULK 'c:\\secret.txt', SINGLE_CLOB) AS f", wantErr: "query may not contain OPENROWSET (use [brackets] for a column of that name)"},
+
+		// Delays and session changes
+		{name: "waitfor", query: "SELECT 1 WAITFOR DELAY '00:10:00'", wantErr: "query may not contain WAITFOR (use [brackets] for a column of that name)"},
+		{name: "set", query: "SELECT 1 SET ROWCOUNT 0", wantErr: "query may not contain SET (use [brackets] for a column of that name)"},
+		{name: "declare", query: "DECLARE @x INT SELECT @x", wantErr: "query may not contain DECLARE (use [brackets] for a column of that name)"},
+
+		// Parameters
+		{name: "undeclared parameter", query: "SELECT a FROM t WHERE b = @Customer", wantErr: "query parameter @Customer is not declared"},
+		{name: "empty parameter", query: "SELECT a FROM t WHERE b = @", wantErr: "query has an empty parameter name"},
+		{name: "parameter in string", query: "SELECT a FROM t WHERE b = '@Customer'"},
+		{name: "not a select", query: "VALUES (1)", wantErr: "query must start with SELECT or WITH"},
+		{name: "empty", query: "  ", wantErr: "query must start with SELECT or WITH"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateReportQuery(tt.query, parameters)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateReportQuery(%q) error = %v", tt.query, err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("validateReportQuery(%q) error = %v, want %s", tt.query, err, tt.wantErr)
+			}
+		})
+	}
+}
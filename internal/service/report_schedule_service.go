@@ -0,0 +1,598 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/config"
+	"erp-excel/database"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/integration"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/storage"
+	"erp-excel/internal/utils"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrReportScheduleNotFound is returned for an unknown schedule or a schedule of another user
+	ErrReportScheduleNotFound = apperror.NotFound("report schedule not found")
+	// ErrInvalidReportSchedule is returned when a schedule's timing cannot be understood
+	ErrInvalidReportSchedule = apperror.Validation("invalid report schedule")
+)
+
+const (
+	reportScheduleRunLimit = 50
+	reportScheduleTimeout  = 30 * time.Minute
+)
+
+// ReportScheduleService manages report schedules and emails their exports, or pushes them to
+// Google Sheets, when they are due
+type ReportScheduleService interface {
+	Create(ctx context.Context, userID int, departmentID int, isAdmin bool, request *dto.ReportScheduleRequest) (*dto.ReportScheduleResponse, error)
+	Update(ctx context.Context, userID int, isAdmin bool, id int, request *dto.ReportScheduleRequest) (*dto.ReportScheduleResponse, error)
+	Delete(ctx context.Context, userID int, isAdmin bool, id int) error
+	Get(ctx context.Context, userID int, isAdmin bool, id int) (*dto.ReportScheduleResponse, error)
+	List(ctx context.Context, userID int, isAdmin bool) ([]*dto.ReportScheduleResponse, error)
+	ListRuns(ctx context.Context, userID int, isAdmin bool, id int) ([]*models.ReportScheduleRun, error)
+	RunNow(ctx context.Context, userID int, isAdmin bool, id int) (*models.ReportScheduleRun, error)
+	Start(ctx context.Context)
+	// Stop waits for the running delivery once the context given to Start is cancelled. A delivery
+	// still running when ctx is done is cancelled and recorded as failed.
+	Stop(ctx context.Context)
+}
+
+type reportScheduleService struct {
+	config           config.SchedulesConfig
+	scheduleRepo     repository.ReportScheduleRepository
+	userRepo         repository.UserRepository
+	operationService OperationService
+	approvals        ExportApprovalService
+	fileStorage      storage.Storage
+	mailer           integration.Mailer
+	sheetsClient     integration.GoogleSheetsClient
+	runners          map[string]exportJobRunner
+	sheetRunners     map[string]sheetExportRunner
+	logger           *slog.Logger
+
+	workers workerGroup
+}
+
+// NewReportScheduleService creates a new report schedule service
+func NewReportScheduleService(
+	cfg config.SchedulesConfig,
+	scheduleRepo repository.ReportScheduleRepository,
+	userRepo repository.UserRepository,
+	operationService OperationService,
+	approvals ExportApprovalService,
+	fileStorage storage.Storage,
+	mailer integration.Mailer,
+	sheetsClient integration.GoogleSheetsClient,
+	reportService ReportService,
+	assistant610Service Assistant610Service,
+	logger *slog.Logger,
+) ReportScheduleService {
+	if cfg.IntervalSeconds <= 0 {
+		cfg.IntervalSeconds = 60
+	}
+
+	return &reportScheduleService{
+		config:           cfg,
+		scheduleRepo:     scheduleRepo,
+		userRepo:         userRepo,
+		operationService: operationService,
+		approvals:        approvals,
+		fileStorage:      fileStorage,
+		mailer:           mailer,
+		sheetsClient:     sheetsClient,
+		runners:          newExportJobRunners(reportService, assistant610Service),
+		sheetRunners: map[string]sheetExportRunner{
+			"assistant230": reportService.ExportInventoryReportToSheet,
+			"assistant610": assistant610Service.ExportAssistant610ReportToSheet,
+		},
+		logger: logger,
+	}
+}
+
+// sheetExportRunner pushes a report to a Google Sheet for a user
+type sheetExportRunner func(ctx context.Context, userID int, departmentID int, request *dto.SheetExportRequest) (*dto.SheetExportResponse, error)
+
+// Create stores a new schedule owned by the user, of a report the user may export
+func (s *reportScheduleService) Create(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	isAdmin bool,
+	request *dto.ReportScheduleRequest,
+) (*dto.ReportScheduleResponse, error) {
+	if err := s.checkExportAccess(ctx, userID, isAdmin, request.Report); err != nil {
+		return nil, err
+	}
+
+	schedule := &models.ReportSchedule{
+		CreatedBy:    userID,
+		DepartmentID: departmentID,
+		Company:      database.CompanyFromContext(ctx),
+	}
+	if err := applyReportScheduleRequest(schedule, request); err != nil {
+		return nil, err
+	}
+	if err := s.applySheetTarget(schedule, request); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.scheduleRepo.Create(ctx, schedule); err != nil {
+		return nil, err
+	}
+
+	return reportScheduleResponse(schedule), nil
+}
+
+// Update replaces the settings of a schedule and recalculates its next run
+func (s *reportScheduleService) Update(
+	ctx context.Context,
+	userID int,
+	isAdmin bool,
+	id int,
+	request *dto.ReportScheduleRequest,
+) (*dto.ReportScheduleResponse, error) {
+	schedule, err := s.getSchedule(ctx, userID, isAdmin, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkExportAccess(ctx, userID, isAdmin, request.Report); err != nil {
+		return nil, err
+	}
+
+	if err := applyReportScheduleRequest(schedule, request); err != nil {
+		return nil, err
+	}
+	if err := s.applySheetTarget(schedule, request); err != nil {
+		return nil, err
+	}
+
+	if err := s.scheduleRepo.Update(ctx, schedule); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrReportScheduleNotFound
+		}
+		return nil, err
+	}
+
+	return reportScheduleResponse(schedule), nil
+}
+
+// Delete removes a schedule with its run history
+func (s *reportScheduleService) Delete(ctx context.Context, userID int, isAdmin bool, id int) error {
+	if _, err := s.getSchedule(ctx, userID, isAdmin, id); err != nil {
+		return err
+	}
+	return s.scheduleRepo.Delete(ctx, id)
+}
+
+// Get returns one schedule
+func (s *reportScheduleService) Get(ctx context.Context, userID int, isAdmin bool, id int) (*dto.ReportScheduleResponse, error) {
+	schedule, err := s.getSchedule(ctx, userID, isAdmin, id)
+	if err != nil {
+		return nil, err
+	}
+	return reportScheduleResponse(schedule), nil
+}
+
+// List returns the user's schedules, or every schedule for an administrator
+func (s *reportScheduleService) List(ctx context.Context, userID int, isAdmin bool) ([]*dto.ReportScheduleResponse, error) {
+	createdBy := userID
+	if isAdmin {
+		createdBy = 0
+	}
+
+	schedules, err := s.scheduleRepo.List(ctx, createdBy)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*dto.ReportScheduleResponse, 0, len(schedules))
+	for _, schedule := range schedules {
+		responses = append(responses, reportScheduleResponse(schedule))
+	}
+	return responses, nil
+}
+
+// ListRuns returns the newest deliveries of a schedule
+func (s *reportScheduleService) ListRuns(ctx context.Context, userID int, isAdmin bool, id int) ([]*models.ReportScheduleRun, error) {
+	if _, err := s.getSchedule(ctx, userID, isAdmin, id); err != nil {
+		return nil, err
+	}
+	return s.scheduleRepo.ListRuns(ctx, id, reportScheduleRunLimit)
+}
+
+// RunNow delivers a schedule immediately without moving its next run
+func (s *reportScheduleService) RunNow(ctx context.Context, userID int, isAdmin bool, id int) (*models.ReportScheduleRun, error) {
+	schedule, err := s.getSchedule(ctx, userID, isAdmin, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.deliver(ctx, schedule)
+}
+
+// Start looks for due schedules periodically until the context is cancelled
+func (s *reportScheduleService) Start(ctx context.Context) {
+	if !s.config.Enabled {
+		return
+	}
+
+	if !s.mailer.Enabled() {
+		s.logger.WarnContext(ctx, "Report schedules are enabled but mail is not configured; deliveries will fail")
+	}
+
+	interval := time.Duration(s.config.IntervalSeconds) * time.Second
+
+	// Cancelling ctx stops claiming schedules; a running delivery finishes on workCtx
+	workCtx := s.workers.start(ctx)
+	s.workers.run(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.runDue(ctx, workCtx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+
+	s.logger.InfoContext(ctx, "Report scheduler started", "interval", interval.String())
+}
+
+func (s *reportScheduleService) Stop(ctx context.Context) {
+	s.workers.stop(ctx)
+}
+
+// runDue claims the schedules whose next run has passed while ctx is not cancelled, and
+// delivers them on workCtx
+func (s *reportScheduleService) runDue(ctx context.Context, workCtx context.Context) {
+	now := time.Now()
+	schedules, err := s.scheduleRepo.ListDue(ctx, now)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error reading due report schedules", "error", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Runs missed while the server was down are delivered once, not once per missed slot
+		nextRun, err := nextReportScheduleRun(schedule.Cron, now)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "Error scheduling report schedule", "schedule_id", schedule.ID, "error", err)
+		}
+
+		claimed, err := s.scheduleRepo.ClaimRun(ctx, schedule.ID, *schedule.NextRunAt, nextRun)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "Error claiming report schedule", "schedule_id", schedule.ID, "error", err)
+			continue
+		}
+		if !claimed {
+			// Another instance is delivering it
+			continue
+		}
+
+		if _, err := s.deliver(workCtx, schedule); err != nil {
+			s.logger.ErrorContext(ctx, "Error delivering report schedule", "schedule_id", schedule.ID, "error", err)
+		}
+	}
+}
+
+// deliver generates the export of a schedule, emails it or pushes it to its sheet and records the
+// run. The returned error covers recording the run only; a failed delivery is reported in the run
+// itself.
+func (s *reportScheduleService) deliver(ctx context.Context, schedule *models.ReportSchedule) (*models.ReportScheduleRun, error) {
+	run := &models.ReportScheduleRun{
+		ScheduleID: schedule.ID,
+		Recipients: schedule.Recipients,
+	}
+	if _, err := s.scheduleRepo.StartRun(ctx, run); err != nil {
+		return nil, err
+	}
+
+	fileName, err := s.export(ctx, schedule)
+
+	run.FileName = fileName
+	run.Status = "success"
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Report schedule failed", "schedule_id", schedule.ID, "error", err)
+		run.Status = "failed"
+		run.Error = err.Error()
+	}
+
+	// Record the outcome even when the scheduler is being stopped
+	recordCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := s.scheduleRepo.FinishRun(recordCtx, run.ID, run.Status, run.FileName, run.Error); err != nil {
+		return run, err
+	}
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	return run, nil
+}
+
+// export generates the report of a schedule as its owner, emails it to the recipients and pushes
+// it to the sheet of the schedule. It returns the stored file name of the emailed export.
+func (s *reportScheduleService) export(ctx context.Context, schedule *models.ReportSchedule) (string, error) {
+	runner, ok := s.runners[schedule.Report]
+	sheetRunner, sheetOK := s.sheetRunners[schedule.Report]
+	if !ok || !sheetOK {
+		return "", fmt.Errorf("unknown report %s", schedule.Report)
+	}
+
+	// A schedule stops delivering once its owner can no longer sign in
+	owner, err := s.userRepo.GetByID(ctx, schedule.CreatedBy)
+	if err != nil {
+		return "", fmt.Errorf("error getting schedule owner: %w", err)
+	}
+	if !owner.IsActive || owner.DeletedAt != nil {
+		return "", errors.New("the schedule owner is disabled")
+	}
+	// ...or loses the export operation of the report
+	ownerIsAdmin, err := s.operationService.IsAdmin(ctx, owner.ID)
+	if err != nil {
+		return "", fmt.Errorf("error checking permissions: %w", err)
+	}
+	if err := s.checkExportAccess(ctx, owner.ID, ownerIsAdmin, schedule.Report); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, reportScheduleTimeout)
+	defer cancel()
+	if schedule.Company != "" {
+		ctx = database.WithCompany(ctx, schedule.Company)
+	}
+
+	period := schedule.Period
+	fileName := ""
+	if schedule.Recipients != "" {
+		response, err := runner(ctx, schedule.CreatedBy, schedule.DepartmentID, &dto.DateRangeRequest{Period: &period})
+		if err != nil {
+			return "", err
+		}
+		fileName = filepath.Base(response.FileName)
+		if err := s.send(ctx, schedule, fileName); err != nil {
+			return fileName, err
+		}
+	}
+
+	if schedule.SpreadsheetID != "" {
+		// The target is checked against the configuration again, which may have changed since
+		if _, err := sheetRunner(ctx, schedule.CreatedBy, schedule.DepartmentID, &dto.SheetExportRequest{
+			DateRangeRequest: dto.DateRangeRequest{Period: &period},
+			SpreadsheetID:    schedule.SpreadsheetID,
+			SheetName:        schedule.SheetName,
+		}); err != nil {
+			return fileName, err
+		}
+	}
+
+	return fileName, nil
+}
+
+// send emails the stored export to the schedule's recipients
+func (s *reportScheduleService) send(ctx context.Context, schedule *models.ReportSchedule, fileName string) error {
+	file, err := s.fileStorage.Open(ctx, fileName)
+	if err != nil {
+		return fmt.Errorf("error opening export file: %w", err)
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("error reading export file: %w", err)
+	}
+
+	message := &integration.MailMessage{
+		To:      splitRecipients(schedule.Recipients),
+		Subject: schedule.Name,
+		Body: fmt.Sprintf(
+			"The scheduled report %q (%s, period %s) generated at %s is attached.\n",
+			schedule.Name,
+			schedule.Report,
+			schedule.Period,
+			time.Now().Format("2006-01-02 15:04"),
+		),
+		Attachments: []integration.MailAttachment{{
+			FileName:    fileName,
+			ContentType: utils.ExcelContentType,
+			Content:     content,
+		}},
+	}
+
+	if err := s.mailer.Send(ctx, message); err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+
+	return nil
+}
+
+// checkExportAccess returns ErrPermissionDenied unless the user may export the report, and
+// ErrExportNeedsApproval when its exports need approval: a schedule would email them without one
+func (s *reportScheduleService) checkExportAccess(ctx context.Context, userID int, isAdmin bool, report string) error {
+	operationCode, ok := ExportOperationCode(report)
+	if !ok {
+		return fmt.Errorf("%w: unknown report %s", ErrInvalidReportSchedule, report)
+	}
+	if s.approvals.RequiresApproval(report, isAdmin) {
+		return ErrExportNeedsApproval
+	}
+	if isAdmin {
+		return nil
+	}
+
+	hasAccess, err := s.operationService.CheckUserAccess(ctx, userID, operationCode)
+	if err != nil {
+		return fmt.Errorf("error checking permissions: %w", err)
+	}
+	if !hasAccess {
+		return fmt.Errorf("%w: no permission to export report %s", apperror.ErrPermissionDenied, report)
+	}
+	return nil
+}
+
+// applySheetTarget sets the Google Sheets target of a schedule to the configured target the
+// request picks, and requires the schedule to deliver to recipients or a sheet
+func (s *reportScheduleService) applySheetTarget(schedule *models.ReportSchedule, request *dto.ReportScheduleRequest) error {
+	schedule.SpreadsheetID = ""
+	schedule.SheetName = ""
+	if request.SpreadsheetID == "" && request.SheetName == "" {
+		if schedule.Recipients == "" {
+			return fmt.Errorf("%w: recipients or a Google Sheet are required", ErrInvalidReportSchedule)
+		}
+		return nil
+	}
+
+	target, err := resolveSheetTarget(s.sheetsClient, request.Report, request.SpreadsheetID, request.SheetName)
+	if err != nil {
+		if errors.Is(err, ErrSheetTargetNotAllowed) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrInvalidReportSchedule, err)
+	}
+	schedule.SpreadsheetID = target.SpreadsheetID
+	schedule.SheetName = target.SheetName
+	return nil
+}
+
+// getSchedule gets a schedule, hiding the schedules of other users from non-administrators
+func (s *reportScheduleService) getSchedule(ctx context.Context, userID int, isAdmin bool, id int) (*models.ReportSchedule, error) {
+	schedule, err := s.scheduleRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrReportScheduleNotFound
+		}
+		return nil, err
+	}
+	if !isAdmin && schedule.CreatedBy != userID {
+		return nil, ErrReportScheduleNotFound
+	}
+
+	return schedule, nil
+}
+
+// applyReportScheduleRequest copies a request onto a schedule, deriving its cron expression and next run
+func applyReportScheduleRequest(schedule *models.ReportSchedule, request *dto.ReportScheduleRequest) error {
+	expr, err := reportScheduleCron(request)
+	if err != nil {
+		return err
+	}
+
+	schedule.Name = strings.TrimSpace(request.Name)
+	schedule.Report = request.Report
+	schedule.Period = request.Period
+	schedule.Frequency = request.Frequency
+	schedule.Cron = expr
+	schedule.Recipients = strings.Join(request.Recipients, ",")
+	schedule.IsActive = request.IsActive == nil || *request.IsActive
+
+	schedule.NextRunAt = nil
+	if schedule.IsActive {
+		nextRun, err := nextReportScheduleRun(expr, time.Now())
+		if err != nil {
+			return err
+		}
+		schedule.NextRunAt = nextRun
+	}
+
+	return nil
+}
+
+// reportScheduleCron converts the daily, weekly and monthly frequencies to cron expressions
+func reportScheduleCron(request *dto.ReportScheduleRequest) (string, error) {
+	if request.Frequency == "cron" {
+		if _, err := utils.ParseCron(request.Cron); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidReportSchedule, err)
+		}
+		return strings.Join(strings.Fields(request.Cron), " "), nil
+	}
+
+	if request.Time == "" {
+		return "", fmt.Errorf("%w: time is required for the %s frequency", ErrInvalidReportSchedule, request.Frequency)
+	}
+	at, err := time.Parse("15:04", request.Time)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid time %s", ErrInvalidReportSchedule, request.Time)
+	}
+
+	switch request.Frequency {
+	case "daily":
+		return fmt.Sprintf("%d %d * * *", at.Minute(), at.Hour()), nil
+	case "weekly":
+		return fmt.Sprintf("%d %d * * %d", at.Minute(), at.Hour(), request.Weekday), nil
+	case "monthly":
+		day := request.DayOfMonth
+		if day == 0 {
+			day = 1
+		}
+		return fmt.Sprintf("%d %d %d * *", at.Minute(), at.Hour(), day), nil
+	default:
+		return "", fmt.Errorf("%w: unknown frequency %s", ErrInvalidReportSchedule, request.Frequency)
+	}
+}
+
+// nextReportScheduleRun returns the next run of a cron expression after a time, or nil if it never runs
+func nextReportScheduleRun(expr string, after time.Time) (*time.Time, error) {
+	cron, err := utils.ParseCron(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidReportSchedule, err)
+	}
+
+	next := cron.Next(after)
+	if next.IsZero() {
+		return nil, fmt.Errorf("%w: the schedule never runs", ErrInvalidReportSchedule)
+	}
+	return &next, nil
+}
+
+// splitRecipients splits the stored comma separated recipients
+func splitRecipients(recipients string) []string {
+	var addresses []string
+	for _, address := range strings.Split(recipients, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// reportScheduleResponse converts a schedule to its API response
+func reportScheduleResponse(schedule *models.ReportSchedule) *dto.ReportScheduleResponse {
+	return &dto.ReportScheduleResponse{
+		ID:            schedule.ID,
+		Name:          schedule.Name,
+		Report:        schedule.Report,
+		Period:        schedule.Period,
+		Frequency:     schedule.Frequency,
+		Cron:          schedule.Cron,
+		Recipients:    splitRecipients(schedule.Recipients),
+		SpreadsheetID: schedule.SpreadsheetID,
+		SheetName:     schedule.SheetName,
+		IsActive:      schedule.IsActive,
+		CreatedBy:     schedule.CreatedBy,
+		DepartmentID:  schedule.DepartmentID,
+		Company:       schedule.Company,
+		NextRunAt:     schedule.NextRunAt,
+		LastRunAt:     schedule.LastRunAt,
+		CreatedAt:     schedule.CreatedAt,
+		UpdatedAt:     schedule.UpdatedAt,
+	}
+}
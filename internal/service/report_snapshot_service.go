@@ -0,0 +1,435 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/events"
+	"erp-excel/internal/integration"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/storage"
+	"erp-excel/internal/utils"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+)
+
+// ErrSnapshotNotFound is returned for an unknown snapshot ID
+var ErrSnapshotNotFound = apperror.NotFound("report snapshot not found")
+
+const (
+	defaultSnapshotListLimit = 50
+	maxSnapshotListLimit     = 500
+)
+
+// snapshotViewOperations are the view operations of the built-in reports that can be snapshotted.
+// Their exports need the export operation; a report definition has one operation for both.
+var snapshotViewOperations = map[string]string{
+	"assistant230": "reports:view:230",
+	"assistant610": "reports:view:610",
+}
+
+// ReportSnapshotService freezes report results so they can be re-opened or re-exported later
+type ReportSnapshotService interface {
+	Create(ctx context.Context, userID int, departmentID int, isAdmin bool, request *dto.ReportSnapshotRequest, ipAddress string) (*dto.ReportSnapshotResponse, error)
+	List(ctx context.Context, userID int, isAdmin bool, report string, limit int) ([]dto.ReportSnapshotSummary, error)
+	Get(ctx context.Context, userID int, isAdmin bool, id int) (*dto.ReportSnapshotResponse, error)
+	Export(ctx context.Context, userID int, departmentID int, isAdmin bool, id int, ipAddress string) (*dto.ReportFileResponse, error)
+}
+
+type reportSnapshotService struct {
+	snapshotRepo        repository.ReportSnapshotRepository
+	reportService       ReportService
+	assistant610Service Assistant610Service
+	reportEngineService ReportEngineService
+	operationService    OperationService
+	approvals           ExportApprovalService
+	fileStorage         storage.Storage
+	fileRepo            repository.ReportFileRepository
+	reportNamer         ReportNamer
+	sharePointClient    integration.SharePointClient
+	eventService        EventService
+	operationCode       string
+	logger              *slog.Logger
+}
+
+// snapshotData is the JSON stored in the data column
+type snapshotData struct {
+	Columns []string                 `json:"columns"`
+	Items   []map[string]interface{} `json:"items"`
+}
+
+// NewReportSnapshotService creates a new report snapshot service
+func NewReportSnapshotService(
+	snapshotRepo repository.ReportSnapshotRepository,
+	reportService ReportService,
+	assistant610Service Assistant610Service,
+	reportEngineService ReportEngineService,
+	operationService OperationService,
+	approvals ExportApprovalService,
+	fileStorage storage.Storage,
+	fileRepo repository.ReportFileRepository,
+	reportNamer ReportNamer,
+	sharePointClient integration.SharePointClient,
+	eventService EventService,
+	operationCode string,
+	logger *slog.Logger,
+) ReportSnapshotService {
+	if operationCode == "" {
+		operationCode = "report_snapshots"
+	}
+
+	return &reportSnapshotService{
+		snapshotRepo:        snapshotRepo,
+		reportService:       reportService,
+		assistant610Service: assistant610Service,
+		reportEngineService: reportEngineService,
+		operationService:    operationService,
+		approvals:           approvals,
+		fileStorage:         fileStorage,
+		fileRepo:            fileRepo,
+		reportNamer:         reportNamer,
+		sharePointClient:    sharePointClient,
+		eventService:        eventService,
+		operationCode:       operationCode,
+		logger:              logger,
+	}
+}
+
+// Create runs the report and stores every row of the result together with the parameters
+func (s *reportSnapshotService) Create(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	isAdmin bool,
+	request *dto.ReportSnapshotRequest,
+	ipAddress string,
+) (*dto.ReportSnapshotResponse, error) {
+	columns, items, reportName, params, err := s.runReport(ctx, userID, departmentID, isAdmin, request, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w to snapshot for the specified parameters", apperror.ErrNoData)
+	}
+
+	data, err := json.Marshal(snapshotData{Columns: columns, Items: items})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding snapshot data: %w", err)
+	}
+	parameters, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding snapshot parameters: %w", err)
+	}
+
+	snapshot := &models.ReportSnapshot{
+		Report:     request.Report,
+		ReportName: reportName,
+		Parameters: string(parameters),
+		Data:       string(data),
+		RowCount:   len(items),
+		Checksum:   snapshotChecksum(string(data)),
+		Notes:      request.Notes,
+		CreatedBy:  userID,
+	}
+
+	if _, err := s.snapshotRepo.Create(ctx, snapshot); err != nil {
+		return nil, err
+	}
+	s.logger.InfoContext(ctx, "Stored report snapshot", "snapshot_id", snapshot.ID, "report", snapshot.Report, "rows", snapshot.RowCount)
+
+	return &dto.ReportSnapshotResponse{
+		ReportSnapshotSummary: snapshotSummary(snapshot),
+		ChecksumValid:         true,
+		Columns:               columns,
+		Items:                 items,
+	}, nil
+}
+
+// runReport runs the requested report, if the user may view it, and returns its export columns
+// and rows, its title and the parameters to store
+func (s *reportSnapshotService) runReport(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	isAdmin bool,
+	request *dto.ReportSnapshotRequest,
+	ipAddress string,
+) ([]string, []map[string]interface{}, string, interface{}, error) {
+	if err := s.checkReportAccess(ctx, userID, isAdmin, request.Report, false); err != nil {
+		return nil, nil, "", nil, err
+	}
+
+	switch request.Report {
+	case "assistant230", "assistant610":
+		fromDate, toDate, err := reportDateRange.Resolve(&request.DateRangeRequest)
+		if err != nil {
+			return nil, nil, "", nil, err
+		}
+		params := request.DateRangeRequest
+		params.FromDate = &fromDate
+		params.ToDate = &toDate
+
+		if request.Report == "assistant230" {
+			columns, err := InventoryColumns(&request.DateRangeRequest)
+			if err != nil {
+				return nil, nil, "", nil, err
+			}
+			items, err := s.reportService.GetInventoryReportData(ctx, userID, departmentID, &request.DateRangeRequest)
+			if err != nil {
+				return nil, nil, "", nil, err
+			}
+			headers, rows := inventoryExportRows(ctx, items, columns)
+			title := s.reportNamer.Title(ctx, inventoryNameData(userID, departmentID, fromDate, toDate, invoiceStatusOrDefault(request.InvoiceStatus)))
+			return headers, rows, withTargetCurrency(title, request.TargetCurrency), params, nil
+		}
+
+		columns, err := Assistant610Columns(&request.DateRangeRequest)
+		if err != nil {
+			return nil, nil, "", nil, err
+		}
+		items, err := s.assistant610Service.GetAssistant610ReportData(ctx, userID, departmentID, &request.DateRangeRequest)
+		if err != nil {
+			return nil, nil, "", nil, err
+		}
+		headers, rows := assistant610ExportRows(ctx, items, columns)
+		title := s.reportNamer.Title(ctx, assistant610NameData(userID, departmentID, fromDate, toDate))
+		return headers, rows, withTargetCurrency(title, request.TargetCurrency), params, nil
+	}
+
+	runRequest := &dto.ReportRunRequest{DateRangeRequest: request.DateRangeRequest, Params: request.Params}
+	response, err := s.reportEngineService.RunReport(ctx, userID, departmentID, request.Report, runRequest, ipAddress)
+	if err != nil {
+		return nil, nil, "", nil, err
+	}
+	return response.Columns, response.Items, response.ReportName, runRequest, nil
+}
+
+// List returns the latest snapshots of the reports the user may view, optionally of one report
+func (s *reportSnapshotService) List(ctx context.Context, userID int, isAdmin bool, report string, limit int) ([]dto.ReportSnapshotSummary, error) {
+	if limit <= 0 {
+		limit = defaultSnapshotListLimit
+	}
+	if limit > maxSnapshotListLimit {
+		limit = maxSnapshotListLimit
+	}
+
+	snapshots, err := s.snapshotRepo.List(ctx, report, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]dto.ReportSnapshotSummary, 0, len(snapshots))
+	visible := make(map[string]bool)
+	for _, snapshot := range snapshots {
+		allowed, ok := visible[snapshot.Report]
+		if !ok {
+			err := s.checkReportAccess(ctx, userID, isAdmin, snapshot.Report, false)
+			if err != nil && !errors.Is(err, apperror.ErrPermissionDenied) && !errors.Is(err, ErrReportNotFound) {
+				return nil, err
+			}
+			allowed = err == nil
+			visible[snapshot.Report] = allowed
+		}
+		if allowed {
+			summaries = append(summaries, snapshotSummary(snapshot))
+		}
+	}
+	return summaries, nil
+}
+
+// Get re-opens a snapshot of a report the user may view and checks its data against the stored
+// checksum
+func (s *reportSnapshotService) Get(ctx context.Context, userID int, isAdmin bool, id int) (*dto.ReportSnapshotResponse, error) {
+	snapshot, data, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkReportAccess(ctx, userID, isAdmin, snapshot.Report, false); err != nil {
+		return nil, err
+	}
+
+	return &dto.ReportSnapshotResponse{
+		ReportSnapshotSummary: snapshotSummary(snapshot),
+		ChecksumValid:         snapshotChecksum(snapshot.Data) == snapshot.Checksum,
+		Columns:               data.Columns,
+		Items:                 data.Items,
+	}, nil
+}
+
+// columnTypes types the export columns of a snapshot's report. Definitions removed since the
+// snapshot was taken leave every column as text.
+func (s *reportSnapshotService) columnTypes(ctx context.Context, report string) map[string]utils.ExcelColumnType {
+	if types, ok := reportColumnTypes[report]; ok {
+		return types
+	}
+	definition, err := s.reportEngineService.GetDefinition(ctx, report)
+	if err != nil {
+		return nil
+	}
+	return reportDefinitionColumnTypes(definition)
+}
+
+// Export re-exports a snapshot to Excel for a user who may export its report. A snapshot whose
+// data fails the checksum is not exported, nor one of a report whose exports need approval.
+func (s *reportSnapshotService) Export(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	isAdmin bool,
+	id int,
+	ipAddress string,
+) (*dto.ReportFileResponse, error) {
+	snapshot, data, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkReportAccess(ctx, userID, isAdmin, snapshot.Report, true); err != nil {
+		return nil, err
+	}
+	if s.approvals.RequiresApproval(snapshot.Report, isAdmin) {
+		return nil, ErrExportNeedsApproval
+	}
+	if snapshotChecksum(snapshot.Data) != snapshot.Checksum {
+		return nil, fmt.Errorf("snapshot %d does not match its checksum", id)
+	}
+
+	logID, err := s.operationService.LogAccess(ctx, userID, s.operationCode, map[string]interface{}{"snapshot_id": id}, ipAddress)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error logging access for snapshot", "id", id, "error", err)
+	}
+
+	title := fmt.Sprintf("%s - snapshot #%d of %s", snapshot.ReportName, snapshot.ID, snapshot.CreatedAt.Format("02/01/2006 15:04"))
+	filePath, fileDetail, err := utils.ExportSheetsToExcel(ctx, title, utils.ExcelSheet{
+		Name:        "Sheet1",
+		Title:       title,
+		Headers:     data.Columns,
+		Data:        data.Items,
+		ColumnTypes: s.columnTypes(ctx, snapshot.Report),
+	})
+	if err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, fmt.Errorf("error exporting to Excel: %w", err)
+	}
+
+	s.updateLogStatus(ctx, logID, "success")
+
+	fileName := filepath.Base(filePath)
+	exportFile := &models.ReportFile{
+		FileName:    fileName,
+		Report:      snapshot.Report,
+		UserID:      userID,
+		AccessLogID: logID,
+		RowCount:    len(data.Items),
+	}
+	downloadURL := storeExportFile(ctx, s.logger, s.fileStorage, s.fileRepo, exportFile, fileDetail)
+	publishExportFile(ctx, s.logger, s.sharePointClient, snapshot.Report, fileName, fileDetail)
+	s.eventService.Emit(ctx, events.ExportCompleted, events.ExportCompletedData{
+		Report:       snapshot.Report,
+		FileName:     fileName,
+		RowCount:     len(data.Items),
+		UserID:       userID,
+		DepartmentID: departmentID,
+		DownloadURL:  downloadURL,
+	})
+
+	return &dto.ReportFileResponse{
+		ReportName:  title,
+		FileName:    fileName,
+		FileDetal:   fileDetail,
+		DownloadURL: downloadURL,
+		Checksum:    exportFile.Checksum,
+		GeneratedAt: time.Now(),
+	}, nil
+}
+
+// checkReportAccess returns ErrPermissionDenied unless the user may view, or export, the report
+// of a snapshot: the operations of the built-in reports, or the operation of its definition
+func (s *reportSnapshotService) checkReportAccess(ctx context.Context, userID int, isAdmin bool, report string, export bool) error {
+	if isAdmin {
+		return nil
+	}
+
+	operationCode, ok := snapshotViewOperations[report]
+	if export {
+		operationCode, ok = ExportOperationCode(report)
+	}
+	if !ok {
+		definition, err := s.reportEngineService.GetDefinition(ctx, report)
+		if err != nil {
+			return err
+		}
+		operationCode = definition.OperationCode
+	}
+
+	hasAccess, err := s.operationService.CheckUserAccess(ctx, userID, operationCode)
+	if err != nil {
+		return fmt.Errorf("error checking permissions: %w", err)
+	}
+	if !hasAccess {
+		action := "view"
+		if export {
+			action = "export"
+		}
+		return fmt.Errorf("%w: no permission to %s report %s", apperror.ErrPermissionDenied, action, report)
+	}
+	return nil
+}
+
+// load reads a snapshot and decodes its data
+func (s *reportSnapshotService) load(ctx context.Context, id int) (*models.ReportSnapshot, *snapshotData, error) {
+	snapshot, err := s.snapshotRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, ErrSnapshotNotFound
+		}
+		return nil, nil, err
+	}
+
+	var data snapshotData
+	if err := json.Unmarshal([]byte(snapshot.Data), &data); err != nil {
+		return nil, nil, fmt.Errorf("error decoding snapshot %d: %w", id, err)
+	}
+
+	return snapshot, &data, nil
+}
+
+// updateLogStatus updates the status of an access log.
+func (s *reportSnapshotService) updateLogStatus(ctx context.Context, logID int, status string) {
+	if logID <= 0 {
+		return
+	}
+
+	ctx, status = logStatusContext(ctx, status)
+	if _, err := s.operationService.UpdateLogStatus(ctx, logID, status); err != nil {
+		s.logger.ErrorContext(ctx, "Error updating log status", "log_id", logID, "error", err)
+	}
+}
+
+// snapshotSummary describes a snapshot without its rows
+func snapshotSummary(snapshot *models.ReportSnapshot) dto.ReportSnapshotSummary {
+	return dto.ReportSnapshotSummary{
+		ID:         snapshot.ID,
+		Report:     snapshot.Report,
+		ReportName: snapshot.ReportName,
+		Parameters: json.RawMessage(snapshot.Parameters),
+		RowCount:   snapshot.RowCount,
+		Checksum:   snapshot.Checksum,
+		Notes:      snapshot.Notes,
+		CreatedBy:  snapshot.CreatedBy,
+		CreatedAt:  snapshot.CreatedAt,
+	}
+}
+
+// snapshotChecksum returns the hex SHA-256 of the stored snapshot data
+func snapshotChecksum(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
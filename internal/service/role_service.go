@@ -2,12 +2,26 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"erp-excel/internal/apperror"
 	"erp-excel/internal/dto"
 	"erp-excel/internal/models"
 	"erp-excel/internal/repository"
+	"errors"
 	"fmt"
 )
 
+var (
+	// ErrParentRoleNotFound is returned when a role is given a parent that does not exist
+	ErrParentRoleNotFound = apperror.Validation("parent role not found")
+	// ErrRoleCycle is returned when a role would end up inheriting from itself
+	ErrRoleCycle = apperror.Validation("role cannot inherit from itself or from a role that inherits from it")
+	// ErrRoleNotFound is returned when a role that an operation works on does not exist
+	ErrRoleNotFound = apperror.NotFound("role not found")
+	// ErrOperationNotFound is returned when an operation granted to roles does not exist
+	ErrOperationNotFound = apperror.NotFound("operation not found")
+)
+
 // RoleService interface
 type RoleService interface {
 	CreateRole(ctx context.Context, request dto.CreateRoleRequest) (*dto.RoleResponse, error)
@@ -17,38 +31,68 @@ type RoleService interface {
 	GetAllRoles(ctx context.Context, limit, offset int) ([]*dto.RoleResponse, error)
 	CountRoles(ctx context.Context) (int, error)
 	AssignOperations(ctx context.Context, roleID int, operationIDs []int) error
+	CloneRole(ctx context.Context, id int, request dto.CloneRoleRequest) (*dto.RoleResponse, error)
+	GrantOperation(ctx context.Context, request dto.GrantOperationRequest) error
+	GetRoleUsers(ctx context.Context, id int, limit, offset int) ([]*dto.UserResponse, error)
+	CountRoleUsers(ctx context.Context, id int) (int, error)
+	GetDeletedRoles(ctx context.Context) ([]*dto.RoleResponse, error)
+	RestoreRole(ctx context.Context, id int) error
 }
 
 type roleService struct {
-	roleRepo repository.RoleRepository
+	roleRepo      repository.RoleRepository
+	operationRepo repository.OperationRepository
+	txManager     repository.TxManager
 }
 
 // NewRoleService creates a new role service
-func NewRoleService(roleRepo repository.RoleRepository) RoleService {
+func NewRoleService(
+	roleRepo repository.RoleRepository,
+	operationRepo repository.OperationRepository,
+	txManager repository.TxManager,
+) RoleService {
 	return &roleService{
-		roleRepo: roleRepo,
+		roleRepo:      roleRepo,
+		operationRepo: operationRepo,
+		txManager:     txManager,
 	}
 }
 
 // CreateRole creates a new role
 func (s *roleService) CreateRole(ctx context.Context, request dto.CreateRoleRequest) (*dto.RoleResponse, error) {
+	if request.ParentRoleID != nil {
+		if err := s.checkParent(ctx, 0, *request.ParentRoleID); err != nil {
+			return nil, err
+		}
+	}
+
 	// Create role model
 	role := &models.Role{
-		Name:        request.Name,
-		Description: request.Description,
+		Name:         request.Name,
+		Description:  request.Description,
+		ParentRoleID: request.ParentRoleID,
 	}
 
-	// Save to database
-	createdRole, err := s.roleRepo.Create(ctx, role)
-	if err != nil {
-		return nil, fmt.Errorf("error creating role: %w", err)
-	}
+	// Save the role and its operations together
+	var createdRole *models.Role
+	err := s.txManager.WithinTransaction(ctx, func(tx *sql.Tx) error {
+		roleRepo := s.roleRepo.WithTx(tx)
 
-	// Assign operations if provided
-	if len(request.OperationIDs) > 0 {
-		if err := s.roleRepo.AssignOperations(ctx, createdRole.ID, request.OperationIDs); err != nil {
-			return nil, fmt.Errorf("error assigning operations: %w", err)
+		var err error
+		createdRole, err = roleRepo.Create(ctx, role)
+		if err != nil {
+			return fmt.Errorf("error creating role: %w", err)
+		}
+
+		if len(request.OperationIDs) > 0 {
+			if err := roleRepo.AssignOperations(ctx, createdRole.ID, request.OperationIDs); err != nil {
+				return fmt.Errorf("error assigning operations: %w", err)
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Return response
@@ -56,6 +100,7 @@ func (s *roleService) CreateRole(ctx context.Context, request dto.CreateRoleRequ
 		ID:           createdRole.ID,
 		Name:         createdRole.Name,
 		Description:  createdRole.Description,
+		ParentRoleID: createdRole.ParentRoleID,
 		CreatedAt:    createdRole.CreatedAt,
 		UpdatedAt:    createdRole.UpdatedAt,
 		OperationIDs: request.OperationIDs,
@@ -69,6 +114,11 @@ func (s *roleService) GetRoleByID(ctx context.Context, id int) (*dto.RoleRespons
 		return nil, fmt.Errorf("error getting role: %w", err)
 	}
 
+	userCount, err := s.roleRepo.CountUsers(ctx, role.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Extract operation IDs
 	operationIDs := make([]int, 0, len(role.Operations))
 	for _, operation := range role.Operations {
@@ -79,9 +129,11 @@ func (s *roleService) GetRoleByID(ctx context.Context, id int) (*dto.RoleRespons
 		ID:           role.ID,
 		Name:         role.Name,
 		Description:  role.Description,
+		ParentRoleID: role.ParentRoleID,
 		CreatedAt:    role.CreatedAt,
 		UpdatedAt:    role.UpdatedAt,
 		OperationIDs: operationIDs,
+		UserCount:    userCount,
 	}, nil
 }
 
@@ -102,17 +154,37 @@ func (s *roleService) UpdateRole(ctx context.Context, id int, request dto.Update
 		role.Description = request.Description
 	}
 
-	// Save to database
-	if err := s.roleRepo.Update(ctx, role); err != nil {
-		return nil, fmt.Errorf("error updating role: %w", err)
+	if request.ParentRoleID != nil {
+		if *request.ParentRoleID == 0 {
+			role.ParentRoleID = nil
+		} else {
+			if err := s.checkParent(ctx, role.ID, *request.ParentRoleID); err != nil {
+				return nil, err
+			}
+			role.ParentRoleID = request.ParentRoleID
+		}
 	}
 
-	// Update operations if provided
-	if len(request.OperationIDs) > 0 {
-		if err := s.roleRepo.AssignOperations(ctx, role.ID, request.OperationIDs); err != nil {
-			return nil, fmt.Errorf("error assigning operations: %w", err)
+	// Save the role and its operations together
+	err = s.txManager.WithinTransaction(ctx, func(tx *sql.Tx) error {
+		roleRepo := s.roleRepo.WithTx(tx)
+
+		if err := roleRepo.Update(ctx, role); err != nil {
+			return fmt.Errorf("error updating role: %w", err)
+		}
+
+		if len(request.OperationIDs) > 0 {
+			if err := roleRepo.AssignOperations(ctx, role.ID, request.OperationIDs); err != nil {
+				return fmt.Errorf("error assigning operations: %w", err)
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	if len(request.OperationIDs) > 0 {
 		// Reload operations
 		role, err = s.roleRepo.GetByID(ctx, id)
 		if err != nil {
@@ -131,17 +203,46 @@ func (s *roleService) UpdateRole(ctx context.Context, id int, request dto.Update
 		ID:           role.ID,
 		Name:         role.Name,
 		Description:  role.Description,
+		ParentRoleID: role.ParentRoleID,
 		CreatedAt:    role.CreatedAt,
 		UpdatedAt:    role.UpdatedAt,
 		OperationIDs: operationIDs,
 	}, nil
 }
 
-// DeleteRole deletes a role
+// DeleteRole moves a role to the trash; its members lose the role's operations until it is restored
 func (s *roleService) DeleteRole(ctx context.Context, id int) error {
 	return s.roleRepo.Delete(ctx, id)
 }
 
+// GetDeletedRoles gets the roles in the trash
+func (s *roleService) GetDeletedRoles(ctx context.Context) ([]*dto.RoleResponse, error) {
+	roles, err := s.roleRepo.ListDeleted(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing deleted roles: %w", err)
+	}
+
+	response := make([]*dto.RoleResponse, 0, len(roles))
+	for _, role := range roles {
+		response = append(response, &dto.RoleResponse{
+			ID:           role.ID,
+			Name:         role.Name,
+			Description:  role.Description,
+			ParentRoleID: role.ParentRoleID,
+			CreatedAt:    role.CreatedAt,
+			UpdatedAt:    role.UpdatedAt,
+			DeletedAt:    role.DeletedAt,
+		})
+	}
+
+	return response, nil
+}
+
+// RestoreRole takes a role out of the trash with its operations and members
+func (s *roleService) RestoreRole(ctx context.Context, id int) error {
+	return restoreError(s.roleRepo.Restore(ctx, id))
+}
+
 // GetAllRoles gets all roles
 func (s *roleService) GetAllRoles(ctx context.Context, limit, offset int) ([]*dto.RoleResponse, error) {
 	roles, err := s.roleRepo.List(ctx, limit, offset)
@@ -149,6 +250,11 @@ func (s *roleService) GetAllRoles(ctx context.Context, limit, offset int) ([]*dt
 		return nil, fmt.Errorf("error listing roles: %w", err)
 	}
 
+	userCounts, err := s.roleRepo.GetUserCounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error counting role users: %w", err)
+	}
+
 	// Convert to response DTOs
 	response := make([]*dto.RoleResponse, 0, len(roles))
 	for _, role := range roles {
@@ -168,9 +274,11 @@ func (s *roleService) GetAllRoles(ctx context.Context, limit, offset int) ([]*dt
 			ID:           role.ID,
 			Name:         role.Name,
 			Description:  role.Description,
+			ParentRoleID: role.ParentRoleID,
 			CreatedAt:    role.CreatedAt,
 			UpdatedAt:    role.UpdatedAt,
 			OperationIDs: operationIDs,
+			UserCount:    userCounts[role.ID],
 		})
 	}
 
@@ -186,3 +294,124 @@ func (s *roleService) CountRoles(ctx context.Context) (int, error) {
 func (s *roleService) AssignOperations(ctx context.Context, roleID int, operationIDs []int) error {
 	return s.roleRepo.AssignOperations(ctx, roleID, operationIDs)
 }
+
+// CloneRole creates a copy of a role with the same parent and operations under a new name.
+// Members are not copied.
+func (s *roleService) CloneRole(ctx context.Context, id int, request dto.CloneRoleRequest) (*dto.RoleResponse, error) {
+	source, err := s.getRole(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	description := request.Description
+	if description == "" {
+		description = source.Description
+	}
+
+	operationIDs := make([]int, 0, len(source.Operations))
+	for _, operation := range source.Operations {
+		operationIDs = append(operationIDs, operation.ID)
+	}
+
+	return s.CreateRole(ctx, dto.CreateRoleRequest{
+		Name:         request.Name,
+		Description:  description,
+		ParentRoleID: source.ParentRoleID,
+		OperationIDs: operationIDs,
+	})
+}
+
+// GrantOperation adds an operation to every role of the request in one transaction. The roles
+// keep the operations they already have.
+func (s *roleService) GrantOperation(ctx context.Context, request dto.GrantOperationRequest) error {
+	if _, err := s.operationRepo.GetByID(ctx, request.OperationID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %d", ErrOperationNotFound, request.OperationID)
+		}
+		return fmt.Errorf("error getting operation: %w", err)
+	}
+
+	for _, roleID := range request.RoleIDs {
+		if _, err := s.getRole(ctx, roleID); err != nil {
+			return err
+		}
+	}
+
+	return s.roleRepo.GrantOperation(ctx, request.OperationID, request.RoleIDs)
+}
+
+// GetRoleUsers gets a page of the users that hold a role directly
+func (s *roleService) GetRoleUsers(ctx context.Context, id int, limit, offset int) ([]*dto.UserResponse, error) {
+	if _, err := s.getRole(ctx, id); err != nil {
+		return nil, err
+	}
+
+	users, err := s.roleRepo.ListUsers(ctx, id, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*dto.UserResponse, 0, len(users))
+	for _, user := range users {
+		response = append(response, &dto.UserResponse{
+			ID:           user.ID,
+			Username:     user.Username,
+			FullName:     user.FullName,
+			Email:        user.Email,
+			DepartmentID: user.DepartmentID,
+			Department:   user.Department.Name,
+			IsActive:     user.IsActive,
+			CreatedAt:    user.CreatedAt,
+			UpdatedAt:    user.UpdatedAt,
+		})
+	}
+
+	return response, nil
+}
+
+// CountRoleUsers gets the number of users that hold a role directly
+func (s *roleService) CountRoleUsers(ctx context.Context, id int) (int, error) {
+	return s.roleRepo.CountUsers(ctx, id)
+}
+
+// getRole gets a role, returning ErrRoleNotFound when it does not exist or is deleted
+func (s *roleService) getRole(ctx context.Context, id int) (*models.Role, error) {
+	role, err := s.roleRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %d", ErrRoleNotFound, id)
+		}
+		return nil, fmt.Errorf("error getting role: %w", err)
+	}
+	return role, nil
+}
+
+// checkParent verifies that roleID may inherit from parentID: the parent must exist and must not
+// be the role itself or inherit from it. roleID is 0 for a new role.
+func (s *roleService) checkParent(ctx context.Context, roleID, parentID int) error {
+	if parentID == roleID {
+		return ErrRoleCycle
+	}
+
+	if _, err := s.roleRepo.GetByID(ctx, parentID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrParentRoleNotFound
+		}
+		return fmt.Errorf("error getting parent role: %w", err)
+	}
+
+	if roleID == 0 {
+		return nil
+	}
+
+	ancestors, err := s.roleRepo.GetAncestorIDs(ctx, parentID)
+	if err != nil {
+		return err
+	}
+	for _, id := range ancestors {
+		if id == roleID {
+			return ErrRoleCycle
+		}
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrRowPolicyNotFound is returned for an unknown row policy ID
+	ErrRowPolicyNotFound = apperror.NotFound("row policy not found")
+	// ErrInvalidRowPolicy is returned for a policy on an operation or attribute that does not
+	// support row policies, or with an unknown placeholder
+	ErrInvalidRowPolicy = apperror.Validation("invalid row policy")
+)
+
+// rowPolicyReports are the operations guarding each report that supports row policies, by report
+// code. A policy on either operation applies whether the report is viewed or exported, so an
+// export never holds more rows than the screen.
+var rowPolicyReports = map[string][]string{
+	"assistant230": {"reports:view:230", "reports:export:230"},
+	"assistant610": {"reports:view:610", "reports:export:610"},
+	"assistant340": {"reports:view:340", "reports:export:340"},
+}
+
+// rowPolicyDerivedReports are the reports built from the rows of other reports, with the
+// operations whose policies restrict them. Policies cannot be defined on them directly.
+var rowPolicyDerivedReports = map[string][]string{
+	"reconciliation": {"reports:view:230", "reports:export:230", "reports:view:610", "reports:export:610"},
+}
+
+// rowPolicyPlaceholderPattern matches the placeholders of policy values
+var rowPolicyPlaceholderPattern = regexp.MustCompile(`\{[a-z_]+\}`)
+
+// rowPolicyPlaceholders are the user fields policy values may refer to
+var rowPolicyPlaceholders = []string{"{username}", "{user_id}", "{department_id}", "{department_code}"}
+
+// RowPolicyService manages the row policies administrators define on report operations and
+// evaluates them for the users running reports
+type RowPolicyService interface {
+	List(ctx context.Context, operationCode string) ([]*models.RowPolicy, error)
+	Get(ctx context.Context, id int) (*models.RowPolicy, error)
+	Create(ctx context.Context, userID int, request *dto.RowPolicyRequest) (*models.RowPolicy, error)
+	Update(ctx context.Context, id int, request *dto.RowPolicyRequest) (*models.RowPolicy, error)
+	Delete(ctx context.Context, id int) error
+	Attributes() map[string][]string
+	Apply(ctx context.Context, userID int, report string) context.Context
+}
+
+type rowPolicyService struct {
+	policyRepo     repository.RowPolicyRepository
+	userRepo       repository.UserRepository
+	departmentRepo repository.DepartmentRepository
+	roleRepo       repository.RoleRepository
+	logger         *slog.Logger
+}
+
+// NewRowPolicyService creates a new row policy service
+func NewRowPolicyService(
+	policyRepo repository.RowPolicyRepository,
+	userRepo repository.UserRepository,
+	departmentRepo repository.DepartmentRepository,
+	roleRepo repository.RoleRepository,
+	logger *slog.Logger,
+) RowPolicyService {
+	return &rowPolicyService{
+		policyRepo:     policyRepo,
+		userRepo:       userRepo,
+		departmentRepo: departmentRepo,
+		roleRepo:       roleRepo,
+		logger:         logger,
+	}
+}
+
+// List gets the policies, only those of the operation when one is given
+func (s *rowPolicyService) List(ctx context.Context, operationCode string) ([]*models.RowPolicy, error) {
+	return s.policyRepo.List(ctx, operationCode)
+}
+
+// Get gets a policy
+func (s *rowPolicyService) Get(ctx context.Context, id int) (*models.RowPolicy, error) {
+	policy, err := s.policyRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRowPolicyNotFound
+		}
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Create defines a policy
+func (s *rowPolicyService) Create(ctx context.Context, userID int, request *dto.RowPolicyRequest) (*models.RowPolicy, error) {
+	if err := s.validate(ctx, request); err != nil {
+		return nil, err
+	}
+
+	policy := &models.RowPolicy{CreatedBy: userID}
+	applyRowPolicyRequest(policy, request)
+	if _, err := s.policyRepo.Create(ctx, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Update changes a policy
+func (s *rowPolicyService) Update(ctx context.Context, id int, request *dto.RowPolicyRequest) (*models.RowPolicy, error) {
+	if err := s.validate(ctx, request); err != nil {
+		return nil, err
+	}
+
+	policy, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	applyRowPolicyRequest(policy, request)
+	if err := s.policyRepo.Update(ctx, policy); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRowPolicyNotFound
+		}
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Delete removes a policy
+func (s *rowPolicyService) Delete(ctx context.Context, id int) error {
+	if err := s.policyRepo.Delete(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRowPolicyNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Attributes returns the attributes policies may restrict, by the operation they are defined on
+func (s *rowPolicyService) Attributes() map[string][]string {
+	attributes := make(map[string][]string)
+	for report, operations := range rowPolicyReports {
+		for _, operation := range operations {
+			attributes[operation] = repository.RowPolicyAttributes(report)
+		}
+	}
+	return attributes
+}
+
+// Apply returns a context whose queries of the report only return the rows the policies of the
+// user allow. Every active policy on an operation of the report applies when it has no role or
+// the user holds its role, directly or through inheritance; a row must match all of them. When
+// the policies cannot be evaluated the report queries fail instead of running unrestricted.
+// Reports run without a user, such as the BI feeds authenticated with a service account API
+// key, are restricted by the policies without a role, whose user placeholders match nothing.
+func (s *rowPolicyService) Apply(ctx context.Context, userID int, report string) context.Context {
+	operations, ok := rowPolicyReports[report]
+	if !ok {
+		operations = rowPolicyDerivedReports[report]
+	}
+	if len(operations) == 0 {
+		return ctx
+	}
+
+	predicates, err := s.predicates(ctx, userID, operations)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error evaluating row policies", "user_id", userID, "report", report, "error", err)
+		return repository.WithRowPolicyError(ctx, fmt.Errorf("error evaluating row policies: %w", err))
+	}
+	return repository.WithRowPredicates(ctx, predicates)
+}
+
+// predicates returns the predicates of the policies on the operations that apply to the user,
+// or to a service account without name, department or role when userID is 0
+func (s *rowPolicyService) predicates(ctx context.Context, userID int, operations []string) ([]repository.RowPredicate, error) {
+	policies, err := s.policyRepo.ListActive(ctx, operations)
+	if err != nil || len(policies) == 0 {
+		return nil, err
+	}
+
+	user := &models.User{}
+	var roleIDs []int
+	if userID != 0 {
+		if user, err = s.userRepo.GetByID(ctx, userID); err != nil {
+			return nil, fmt.Errorf("error getting user %d: %w", userID, err)
+		}
+		if roleIDs, err = s.roleRepo.GetUserRoleIDs(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	departmentCode := ""
+	if user.DepartmentID != 0 {
+		department, err := s.departmentRepo.GetByID(ctx, user.DepartmentID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("error getting department %d: %w", user.DepartmentID, err)
+		}
+		if department != nil {
+			departmentCode = department.Code
+		}
+	}
+	placeholders := strings.NewReplacer(
+		"{username}", user.Username,
+		"{user_id}", idPlaceholder(user.ID),
+		"{department_id}", idPlaceholder(user.DepartmentID),
+		"{department_code}", departmentCode,
+	)
+
+	var predicates []repository.RowPredicate
+	for _, policy := range policies {
+		if policy.RoleID != nil && !slices.Contains(roleIDs, *policy.RoleID) {
+			continue
+		}
+
+		// Values whose placeholders are empty for the user, such as a missing department, match nothing
+		values := make([]string, 0, len(policy.Values))
+		for _, value := range policy.Values {
+			if value = placeholders.Replace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		predicates = append(predicates, repository.RowPredicate{
+			Attribute: policy.Attribute,
+			Operator:  policy.Operator,
+			Values:    values,
+		})
+	}
+	return predicates, nil
+}
+
+// validate checks the operation, attribute, role and placeholders of a policy request
+func (s *rowPolicyService) validate(ctx context.Context, request *dto.RowPolicyRequest) error {
+	report := ""
+	for code, operations := range rowPolicyReports {
+		if slices.Contains(operations, request.OperationCode) {
+			report = code
+		}
+	}
+	if report == "" {
+		return fmt.Errorf("%w: operation %s does not support row policies", ErrInvalidRowPolicy, request.OperationCode)
+	}
+	if !slices.Contains(repository.RowPolicyAttributes(report), request.Attribute) {
+		return fmt.Errorf("%w: operation %s has no attribute %q, expected one of %s", ErrInvalidRowPolicy,
+			request.OperationCode, request.Attribute, strings.Join(repository.RowPolicyAttributes(report), ", "))
+	}
+
+	for _, value := range request.Values {
+		for _, placeholder := range rowPolicyPlaceholderPattern.FindAllString(value, -1) {
+			if !slices.Contains(rowPolicyPlaceholders, placeholder) {
+				return fmt.Errorf("%w: unknown placeholder %s, expected one of %s", ErrInvalidRowPolicy,
+					placeholder, strings.Join(rowPolicyPlaceholders, ", "))
+			}
+		}
+	}
+
+	if request.RoleID != nil {
+		if _, err := s.roleRepo.GetByID(ctx, *request.RoleID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("%w: %d", ErrRoleNotFound, *request.RoleID)
+			}
+			return fmt.Errorf("error getting role: %w", err)
+		}
+	}
+	return nil
+}
+
+// applyRowPolicyRequest copies the fields of a request to a policy
+func applyRowPolicyRequest(policy *models.RowPolicy, request *dto.RowPolicyRequest) {
+	policy.Name = request.Name
+	policy.Description = request.Description
+	policy.OperationCode = request.OperationCode
+	policy.RoleID = request.RoleID
+	policy.Attribute = request.Attribute
+	policy.Operator = request.Operator
+	policy.Values = request.Values
+	policy.IsActive = request.IsActive == nil || *request.IsActive
+}
+
+// idPlaceholder returns the {user_id} or {department_id} of a user, empty when it has none
+func idPlaceholder(id int) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.Itoa(id)
+}
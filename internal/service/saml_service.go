@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/config"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/saml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// SAMLService handles single sign-on through a SAML 2.0 identity provider
+type SAMLService interface {
+	Enabled() bool
+	Metadata() ([]byte, error)
+	LoginURL(relayState string) (string, error)
+	Login(ctx context.Context, samlResponse string) (*dto.LoginResponse, error)
+	RedirectURL() string
+}
+
+type samlService struct {
+	config       config.SAMLConfig
+	sp           *saml.ServiceProvider
+	userRepo     repository.UserRepository
+	authService  AuthService
+	eventService EventService
+	logger       *slog.Logger
+}
+
+// NewSAMLService creates a new SAML service; when SAML is disabled every call reports it as such
+func NewSAMLService(
+	cfg config.SAMLConfig,
+	userRepo repository.UserRepository,
+	authService AuthService,
+	eventService EventService,
+	logger *slog.Logger,
+) (SAMLService, error) {
+	service := &samlService{
+		config:       cfg,
+		userRepo:     userRepo,
+		authService:  authService,
+		eventService: eventService,
+		logger:       logger,
+	}
+
+	if !cfg.Enabled {
+		return service, nil
+	}
+
+	sp, err := saml.NewServiceProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	service.sp = sp
+
+	return service, nil
+}
+
+// Enabled reports whether SAML login is switched on
+func (s *samlService) Enabled() bool {
+	return s.sp != nil
+}
+
+// Metadata returns the service provider metadata XML
+func (s *samlService) Metadata() ([]byte, error) {
+	if s.sp == nil {
+		return nil, errors.New("saml login is disabled")
+	}
+	return s.sp.Metadata()
+}
+
+// LoginURL returns the identity provider URL that starts a login
+func (s *samlService) LoginURL(relayState string) (string, error) {
+	if s.sp == nil {
+		return "", errors.New("saml login is disabled")
+	}
+	return s.sp.AuthnRequestURL(relayState)
+}
+
+// RedirectURL returns the frontend URL that receives the token after SSO, if configured
+func (s *samlService) RedirectURL() string {
+	return s.config.RedirectURL
+}
+
+// Login validates a SAML response and signs in the matching local user
+func (s *samlService) Login(ctx context.Context, samlResponse string) (*dto.LoginResponse, error) {
+	if s.sp == nil {
+		return nil, errors.New("saml login is disabled")
+	}
+
+	assertion, err := s.sp.ParseResponse(samlResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	username := assertion.NameID
+	if s.config.UsernameAttribute != "" {
+		username = assertion.Attribute(s.config.UsernameAttribute)
+	}
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, errors.New("saml assertion does not contain a username")
+	}
+
+	user, err := s.userRepo.GetByUsername(ctx, username)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if !s.config.AutoProvision {
+			return nil, fmt.Errorf("user %s is not registered", username)
+		}
+		user, err = s.provisionUser(ctx, username, assertion)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !user.IsActive {
+		return nil, errors.New("account is disabled")
+	}
+
+	if err := s.syncRoles(ctx, user.ID, assertion); err != nil {
+		return nil, err
+	}
+
+	return s.authService.IssueLogin(ctx, user, "")
+}
+
+// provisionUser creates a local account for a first-time SSO user
+func (s *samlService) provisionUser(ctx context.Context, username string, assertion *saml.Assertion) (*models.User, error) {
+	if s.config.DefaultDepartmentID == 0 {
+		return nil, errors.New("saml default department is not configured")
+	}
+
+	fullName := username
+	if s.config.FullNameAttribute != "" {
+		if value := assertion.Attribute(s.config.FullNameAttribute); value != "" {
+			fullName = value
+		}
+	}
+
+	user := &models.User{
+		Username:     username,
+		FullName:     fullName,
+		DepartmentID: s.config.DefaultDepartmentID,
+	}
+	if s.config.EmailAttribute != "" {
+		user.Email = assertion.Attribute(s.config.EmailAttribute)
+	}
+
+	return provisionExternalUser(ctx, s.userRepo, s.eventService, s.logger, user, "saml")
+}
+
+// syncRoles applies the configured attribute-to-role mapping
+func (s *samlService) syncRoles(ctx context.Context, userID int, assertion *saml.Assertion) error {
+	if s.config.RoleAttribute == "" {
+		return nil
+	}
+	return syncMappedRoles(ctx, s.userRepo, userID, s.config.RoleMapping, assertion.Attributes[s.config.RoleAttribute])
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/repository"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaCheckService verifies before first use that the databases have the tables, columns and
+// procedures the reports query, so a misconfigured instance fails with an actionable report instead
+// of a SQL error in the middle of an export
+type SchemaCheckService interface {
+	Check(ctx context.Context) *dto.SchemaCheckResponse
+}
+
+// schemaRequirement lists the columns one report or component needs, by table
+type schemaRequirement struct {
+	database string // app or erp
+	name     string
+	tables   map[string][]string
+	hint     string
+}
+
+// erpReportRequirements are the ERP columns read by the built-in report queries
+var erpReportRequirements = []schemaRequirement{
+	{
+		name: "assistant230",
+		tables: map[string][]string{
+			"COPTG": {"TG001", "TG002", "TG004", "TG005", "TG006", "TG007", "TG011", "TG013", "TG020", "TG023", "TG025", "TG042", "TG045", "TG046"},
+			"COPTH": {"TH001", "TH002", "TH014", "TH015", "TH016"},
+			"COPTD": {"TD001", "TD002", "TD003"},
+			"ACRTA": {"TA001", "TA002", "TA036"},
+			"ACRTB": {"TB001", "TB002", "TB005", "TB006"},
+		},
+	},
+	{
+		name: "assistant610",
+		tables: map[string][]string{
+			"ACRTA": {"TA001", "TA002", "TA009", "TA029", "TA030", "TA036", "TA041", "TA042"},
+			"ACRTB": {"TB001", "TB002", "TB005", "TB006", "TB007", "TB008"},
+			"COPTG": {"TG001", "TG002", "TG004", "TG005", "TG006", "TG007", "TG020"},
+			"COPTH": {"TH001", "TH002", "TH014", "TH015", "TH016"},
+			"COPTD": {"TD001", "TD002", "TD003"},
+		},
+	},
+	{
+		name: "assistant340",
+		tables: map[string][]string{
+			"PURTG": {"TG001", "TG002", "TG003", "TG005", "TG007", "TG013"},
+			"PURTH": {"TH001", "TH002", "TH003", "TH004", "TH005", "TH007", "TH011", "TH012", "TH013", "TH045", "TH046", "TH047", "TH048"},
+			"PURTD": {"TD001", "TD002", "TD003", "TD008"},
+			"PURMA": {"MA001", "MA002"},
+		},
+	},
+	{
+		name: "reconciliation",
+		tables: map[string][]string{
+			"COPTG": {"TG001", "TG002", "TG007", "TG023", "TG042", "TG045", "TG046"},
+			"ACRTA": {"TA001", "TA002", "TA036", "TA041", "TA042"},
+			"ACRTB": {"TB001", "TB002", "TB005", "TB006"},
+		},
+	},
+	{
+		name: "item_inventory",
+		tables: map[string][]string{
+			"INVLA": {"LA001", "LA004", "LA005", "LA009", "LA011"},
+			"INVMB": {"MB001", "MB002", "MB004"},
+			"CMSMC": {"MC001", "MC002"},
+		},
+	},
+	{
+		name: "stock_balance",
+		tables: map[string][]string{
+			"INVLA": {"LA001", "LA004", "LA005", "LA009", "LA011"},
+			"INVMB": {"MB001", "MB002", "MB004"},
+			"CMSMC": {"MC001", "MC002"},
+		},
+	},
+}
+
+// appRequirements are the tables of the app database the code expects to exist. Tables and columns
+// added by the numbered migrations are not listed.
+var appRequirements = []schemaRequirement{
+	{
+		name: "users and permissions",
+		tables: map[string][]string{
+			"USERS":           {"ID", "USERNAME", "PASSWORD", "FULL_NAME", "DEPARTMENT_ID", "IS_ACTIVE", "CREATED_AT", "UPDATED_AT"},
+			"DEPARTMENTS":     {"ID", "NAME", "CODE", "IS_ACTIVE", "CREATED_AT", "UPDATED_AT"},
+			"ROLES":           {"ID", "NAME", "CREATED_AT", "UPDATED_AT"},
+			"OPERATIONS":      {"ID", "NAME", "CODE", "CREATED_AT", "UPDATED_AT"},
+			"USER_ROLES":      {"USER_ID", "ROLE_ID", "CREATED_AT"},
+			"ROLE_OPERATIONS": {"ROLE_ID", "OPERATION_ID", "CAN_ACCESS", "CREATED_AT"},
+		},
+		hint: "run `server migrate` against the app database",
+	},
+	{
+		name: "access logs",
+		tables: map[string][]string{
+			"ACCESS_LOGS": {"ID", "USER_ID", "OPERATION_ID", "ACCESS_TIME", "SEARCH_PARAMS", "IP_ADDRESS", "STATUS"},
+		},
+		hint: "run `server migrate` against the app database",
+	},
+}
+
+type schemaCheckService struct {
+	config    *config.Config
+	appSchema repository.SchemaRepository
+	erpSchema repository.SchemaRepository
+}
+
+// NewSchemaCheckService creates a new schema check service
+func NewSchemaCheckService(cfg *config.Config, appSchema, erpSchema repository.SchemaRepository) SchemaCheckService {
+	return &schemaCheckService{
+		config:    cfg,
+		appSchema: appSchema,
+		erpSchema: erpSchema,
+	}
+}
+
+// Check runs every check; a failing check does not stop the others
+func (s *schemaCheckService) Check(ctx context.Context) *dto.SchemaCheckResponse {
+	requirements := make([]schemaRequirement, 0, len(appRequirements)+len(erpReportRequirements)+1)
+	for _, requirement := range appRequirements {
+		requirement.database = "app"
+		requirements = append(requirements, requirement)
+	}
+
+	erpDatabase := s.config.ERPDatabase.DBName
+	for _, requirement := range erpReportRequirements {
+		requirement.database = "erp"
+		if requirement.hint == "" {
+			requirement.hint = fmt.Sprintf("check that erp_database.name (%s) is the company database of the ERP", erpDatabase)
+		}
+		requirements = append(requirements, requirement)
+	}
+
+	if writeBack := s.config.ERPWriteBack; writeBack.Enabled {
+		requirements = append(requirements, schemaRequirement{
+			database: "erp",
+			name:     "erp_writeback",
+			tables: map[string][]string{
+				"COPTG": {"TG001", "TG002", "TG023", strings.ToUpper(writeBack.FlagColumn), strings.ToUpper(writeBack.NoteColumn)},
+			},
+			hint: "check erp_writeback.flag_column and erp_writeback.note_column",
+		})
+	}
+
+	response := &dto.SchemaCheckResponse{
+		OK:        true,
+		CheckedAt: time.Now(),
+		Checks:    make([]dto.SchemaCheckResult, 0, len(requirements)+len(s.config.Reports.Procedures)),
+	}
+	add := func(result dto.SchemaCheckResult) {
+		response.OK = response.OK && result.OK
+		response.Checks = append(response.Checks, result)
+	}
+
+	columns := map[string]map[string]map[string]bool{}
+	errs := map[string]error{}
+	for _, database := range []string{"app", "erp"} {
+		columns[database], errs[database] = s.schema(database).Columns(ctx, requiredTables(requirements, database))
+	}
+
+	for _, requirement := range requirements {
+		result := dto.SchemaCheckResult{Database: requirement.database, Name: requirement.name}
+		if err := errs[requirement.database]; err != nil {
+			result.Error = err.Error()
+			result.Hint = fmt.Sprintf("check the connection settings of the %s database", requirement.database)
+			add(result)
+			continue
+		}
+
+		result.Missing = missingColumns(columns[requirement.database], requirement.tables)
+		result.OK = len(result.Missing) == 0
+		if !result.OK {
+			result.Hint = requirement.hint
+		}
+		add(result)
+	}
+
+	for _, procedure := range s.config.Reports.Procedures {
+		result := dto.SchemaCheckResult{Database: "erp", Name: procedure.Code}
+		exists, err := s.erpSchema.ProcedureExists(ctx, procedure.Procedure)
+		switch {
+		case err != nil:
+			result.Error = err.Error()
+		case !exists:
+			result.Missing = []string{procedure.Procedure}
+			result.Hint = "create the procedure in the ERP database or fix reports.procedures"
+		default:
+			result.OK = true
+		}
+		add(result)
+	}
+
+	return response
+}
+
+// schema returns the schema repository of the app or ERP database
+func (s *schemaCheckService) schema(database string) repository.SchemaRepository {
+	if database == "erp" {
+		return s.erpSchema
+	}
+	return s.appSchema
+}
+
+// requiredTables lists the tables one database needs, without duplicates
+func requiredTables(requirements []schemaRequirement, database string) []string {
+	seen := make(map[string]bool)
+	var tables []string
+	for _, requirement := range requirements {
+		if requirement.database != database {
+			continue
+		}
+		for table := range requirement.tables {
+			if !seen[table] {
+				seen[table] = true
+				tables = append(tables, table)
+			}
+		}
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// missingColumns lists the required tables and columns that are not in the catalog, sorted
+func missingColumns(catalog map[string]map[string]bool, tables map[string][]string) []string {
+	var missing []string
+	for table, required := range tables {
+		existing, ok := catalog[table]
+		if !ok {
+			missing = append(missing, table)
+			continue
+		}
+		for _, column := range required {
+			if !existing[column] {
+				missing = append(missing, table+"."+column)
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
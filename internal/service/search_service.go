@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/repository"
+	"fmt"
+	"strings"
+)
+
+// SearchService looks up users, departments and roles for the admin quick search
+type SearchService interface {
+	Search(ctx context.Context, query string, limit int) (*dto.SearchResponse, error)
+}
+
+type searchService struct {
+	userRepo       repository.UserRepository
+	departmentRepo repository.DepartmentRepository
+	roleRepo       repository.RoleRepository
+}
+
+// NewSearchService creates a new search service
+func NewSearchService(
+	userRepo repository.UserRepository,
+	departmentRepo repository.DepartmentRepository,
+	roleRepo repository.RoleRepository,
+) SearchService {
+	return &searchService{
+		userRepo:       userRepo,
+		departmentRepo: departmentRepo,
+		roleRepo:       roleRepo,
+	}
+}
+
+// Search returns up to limit matches per entity type; deleted records are not included
+func (s *searchService) Search(ctx context.Context, query string, limit int) (*dto.SearchResponse, error) {
+	query = strings.TrimSpace(query)
+
+	users, err := s.userRepo.Search(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error searching users: %w", err)
+	}
+	userGroup := &dto.SearchResultGroup{Type: dto.SearchTypeUser, Items: make([]*dto.SearchResult, 0, len(users))}
+	for _, user := range users {
+		subtitle := user.Username
+		if user.Department != nil && user.Department.Name != "" {
+			subtitle += " - " + user.Department.Name
+		}
+		userGroup.Items = append(userGroup.Items, &dto.SearchResult{
+			ID:       user.ID,
+			Title:    user.FullName,
+			Subtitle: subtitle,
+			Inactive: !user.IsActive,
+		})
+	}
+
+	departments, err := s.departmentRepo.Search(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error searching departments: %w", err)
+	}
+	departmentGroup := &dto.SearchResultGroup{Type: dto.SearchTypeDepartment, Items: make([]*dto.SearchResult, 0, len(departments))}
+	for _, department := range departments {
+		departmentGroup.Items = append(departmentGroup.Items, &dto.SearchResult{
+			ID:       department.ID,
+			Title:    department.Name,
+			Subtitle: department.Code,
+			Inactive: !department.IsActive,
+		})
+	}
+
+	roles, err := s.roleRepo.Search(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error searching roles: %w", err)
+	}
+	roleGroup := &dto.SearchResultGroup{Type: dto.SearchTypeRole, Items: make([]*dto.SearchResult, 0, len(roles))}
+	for _, role := range roles {
+		roleGroup.Items = append(roleGroup.Items, &dto.SearchResult{
+			ID:       role.ID,
+			Title:    role.Name,
+			Subtitle: role.Description,
+		})
+	}
+
+	return &dto.SearchResponse{
+		Query:  query,
+		Groups: []*dto.SearchResultGroup{userGroup, departmentGroup, roleGroup},
+	}, nil
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/integration"
+	"erp-excel/internal/translate"
+	"errors"
+	"slices"
+)
+
+// ErrSheetTargetNotAllowed is returned for a spreadsheet and tab that are not a configured target
+var ErrSheetTargetNotAllowed = apperror.Validation("the spreadsheet and tab are not a configured Google Sheets target")
+
+// resolveSheetTarget picks the spreadsheet and tab for a report: its configured target, or
+// another configured target the request names by its spreadsheet and tab. The tab written to is
+// cleared first, so a spreadsheet or tab that is not configured is refused.
+func resolveSheetTarget(client integration.GoogleSheetsClient, targetName, spreadsheetID, sheetName string) (config.SheetTargetConfig, error) {
+	if client == nil || !client.Enabled() {
+		return config.SheetTargetConfig{}, errors.New("google sheets integration is disabled")
+	}
+
+	target, _ := client.Target(targetName)
+	if spreadsheetID != "" {
+		target.SpreadsheetID = spreadsheetID
+	}
+	if sheetName != "" {
+		target.SheetName = sheetName
+	}
+
+	if target.SpreadsheetID == "" || target.SheetName == "" {
+		return config.SheetTargetConfig{}, errors.New("no google sheet configured for this report")
+	}
+	if !slices.Contains(client.Targets(), target) {
+		return config.SheetTargetConfig{}, ErrSheetTargetNotAllowed
+	}
+
+	return target, nil
+}
+
+// buildSheetValues converts export rows into a header row followed by data rows
+func buildSheetValues(ctx context.Context, headers []string, data []map[string]interface{}) [][]interface{} {
+	values := make([][]interface{}, 0, len(data)+1)
+
+	headerRow := make([]interface{}, len(headers))
+	for i, header := range headers {
+		headerRow[i] = translate.Key(ctx, header)
+	}
+	values = append(values, headerRow)
+
+	for _, item := range data {
+		row := make([]interface{}, len(headers))
+		for i, header := range headers {
+			row[i] = item[header]
+		}
+		values = append(values, row)
+	}
+
+	return values
+}
@@ -0,0 +1,18 @@
+package service
+
+import (
+	"database/sql"
+	"erp-excel/internal/apperror"
+	"errors"
+)
+
+// ErrNotDeleted is returned when restoring a record that is not in the trash
+var ErrNotDeleted = apperror.NotFound("record is not deleted")
+
+// restoreError maps the repository's not found error for a restore to ErrNotDeleted
+func restoreError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotDeleted
+	}
+	return err
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"erp-excel/config"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/events"
+	"erp-excel/internal/integration"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/storage"
+	"erp-excel/internal/utils"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// StockBalanceService builds the on-hand stock report per item and warehouse at a date
+type StockBalanceService interface {
+	GetStockBalanceData(ctx context.Context, userID int, request *dto.StockBalanceRequest, ipAddress string) ([]dto.StockBalanceItem, error)
+	ExportStockBalance(ctx context.Context, userID int, departmentID int, request *dto.StockBalanceRequest, ipAddress string) (*dto.ReportFileResponse, error)
+}
+
+type stockBalanceService struct {
+	stockBalanceRepo repository.StockBalanceRepository
+	operationService OperationService
+	fileStorage      storage.Storage
+	fileRepo         repository.ReportFileRepository
+	reportNamer      ReportNamer
+	reportLimiter    ReportLimiter
+	queryTimeouts    QueryTimeouts
+	sharePointClient integration.SharePointClient
+	eventService     EventService
+	operationCode    string
+	logger           *slog.Logger
+}
+
+// NewStockBalanceService creates a new stock balance service
+func NewStockBalanceService(
+	config *config.Config,
+	stockBalanceRepo repository.StockBalanceRepository,
+	operationService OperationService,
+	fileStorage storage.Storage,
+	fileRepo repository.ReportFileRepository,
+	reportNamer ReportNamer,
+	reportLimiter ReportLimiter,
+	queryTimeouts QueryTimeouts,
+	sharePointClient integration.SharePointClient,
+	eventService EventService,
+	logger *slog.Logger,
+) StockBalanceService {
+	operationCode := config.Inventory.StockOperationCode
+	if operationCode == "" {
+		operationCode = "stock_balance"
+	}
+
+	return &stockBalanceService{
+		stockBalanceRepo: stockBalanceRepo,
+		operationService: operationService,
+		fileStorage:      fileStorage,
+		fileRepo:         fileRepo,
+		reportNamer:      reportNamer,
+		reportLimiter:    reportLimiter,
+		queryTimeouts:    queryTimeouts,
+		sharePointClient: sharePointClient,
+		eventService:     eventService,
+		operationCode:    operationCode,
+		logger:           logger,
+	}
+}
+
+// GetStockBalanceData retrieves the stock balance without generating a file
+func (s *stockBalanceService) GetStockBalanceData(
+	ctx context.Context,
+	userID int,
+	request *dto.StockBalanceRequest,
+	ipAddress string,
+) ([]dto.StockBalanceItem, error) {
+	s.logger.DebugContext(ctx, "GetStockBalanceData called", "user_id", userID, "request", request)
+
+	items, logID, err := s.queryItems(ctx, userID, request, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	s.updateLogStatus(ctx, logID, "success")
+	if items == nil {
+		return []dto.StockBalanceItem{}, nil
+	}
+	return items, nil
+}
+
+// ExportStockBalance generates the stock balance Excel file
+func (s *stockBalanceService) ExportStockBalance(
+	ctx context.Context,
+	userID int,
+	departmentID int,
+	request *dto.StockBalanceRequest,
+	ipAddress string,
+) (*dto.ReportFileResponse, error) {
+	s.logger.DebugContext(ctx, "ExportStockBalance called", "user_id", userID, "request", request)
+
+	items, logID, err := s.queryItems(ctx, userID, request, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkExportRows(len(items), s.reportLimiter.MaxRows(ctx, userID, "stock_balance")); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+
+	if len(items) == 0 {
+		s.updateLogStatus(ctx, logID, "success")
+		return nil, fmt.Errorf("%w to export for the specified date range", apperror.ErrNoData)
+	}
+
+	// The balance is a point in time: both ends of the period are the as-of date
+	nameData := ReportNameData{
+		Report:       "stock_balance",
+		Name:         "Stock Balance",
+		FromDate:     *request.AsOfDate,
+		ToDate:       *request.AsOfDate,
+		UserID:       userID,
+		DepartmentID: departmentID,
+	}
+	title := s.reportNamer.Title(ctx, nameData)
+
+	headers, data := stockBalanceExportRows(items)
+
+	_, fileDetail, err := utils.ExportSheetsToExcel(ctx, title, utils.ExcelSheet{
+		Name:    "Sheet1",
+		Title:   title,
+		Headers: headers,
+		Data:    data,
+		ColumnTypes: map[string]utils.ExcelColumnType{
+			"item_code":   utils.ExcelText,
+			"on_hand_qty": utils.ExcelNumber,
+		},
+	})
+	if err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, fmt.Errorf("error exporting to Excel: %w", err)
+	}
+	if err := checkExportSize("stock_balance", fileDetail.Len(), s.reportLimiter.MaxExportBytes("stock_balance")); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, err
+	}
+
+	s.updateLogStatus(ctx, logID, "success")
+
+	fileName := s.reportNamer.FileName(ctx, nameData, title)
+	exportFile := &models.ReportFile{
+		FileName:    fileName,
+		Report:      "stock_balance",
+		UserID:      userID,
+		AccessLogID: logID,
+		RowCount:    len(items),
+	}
+	downloadURL := storeExportFile(ctx, s.logger, s.fileStorage, s.fileRepo, exportFile, fileDetail)
+	publishExportFile(ctx, s.logger, s.sharePointClient, "stock_balance", fileName, fileDetail)
+	s.eventService.Emit(ctx, events.ExportCompleted, events.ExportCompletedData{
+		Report:       "stock_balance",
+		FileName:     fileName,
+		RowCount:     len(items),
+		UserID:       userID,
+		DepartmentID: departmentID,
+		DownloadURL:  downloadURL,
+	})
+
+	return &dto.ReportFileResponse{
+		ReportName:  title,
+		FileName:    fileName,
+		FileDetal:   fileDetail,
+		DownloadURL: downloadURL,
+		Checksum:    exportFile.Checksum,
+		GeneratedAt: time.Now(),
+	}, nil
+}
+
+// queryItems resolves the as-of date in place, logs the access and runs the query.
+// The returned log ID is left pending for the caller to close.
+func (s *stockBalanceService) queryItems(
+	ctx context.Context,
+	userID int,
+	request *dto.StockBalanceRequest,
+	ipAddress string,
+) ([]dto.StockBalanceItem, int, error) {
+	asOfDate, err := resolveAsOfDate(request.AsOfDate)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error resolving as-of date", "error", err)
+		return nil, 0, err
+	}
+	request.AsOfDate = &asOfDate
+
+	logID, err := s.operationService.LogAccess(ctx, userID, s.operationCode, request, ipAddress)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error logging stock balance access", "error", err)
+	}
+
+	queryCtx, finishQuery := s.queryTimeouts.start(ctx, "stock_balance")
+	items, err := s.stockBalanceRepo.GetStockBalance(queryCtx, asOfDate, request.ItemCode, request.WarehouseCode)
+	err = finishQuery(err)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error querying stock balance", "error", err)
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, 0, fmt.Errorf("error querying stock balance: %w", err)
+	}
+	if err := checkQueryRows("stock_balance", len(items), s.reportLimiter.QueryRows("stock_balance")); err != nil {
+		s.updateLogStatus(ctx, logID, "error")
+		return nil, 0, err
+	}
+
+	return items, logID, nil
+}
+
+// resolveAsOfDate returns the day a stock balance is taken at, today when none is given
+func resolveAsOfDate(asOfDate *time.Time) (time.Time, error) {
+	if asOfDate == nil || asOfDate.IsZero() {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()), nil
+	}
+	if asOfDate.Year() < 1900 {
+		return time.Time{}, fmt.Errorf("%w: asOfDate must be after 1900", apperror.ErrInvalidRange)
+	}
+	return *asOfDate, nil
+}
+
+// stockBalanceExportRows maps stock balances to export headers and rows.
+func stockBalanceExportRows(items []dto.StockBalanceItem) ([]string, []map[string]interface{}) {
+	headers := []string{
+		"item_code",
+		"item_name",
+		"unit",
+		"warehouse_code",
+		"warehouse_name",
+		"on_hand_qty",
+	}
+
+	data := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		data[i] = map[string]interface{}{
+			"item_code":      item.ItemCode,
+			"item_name":      item.ItemName,
+			"unit":           item.Unit,
+			"warehouse_code": item.WarehouseCode,
+			"warehouse_name": item.WarehouseName,
+			"on_hand_qty":    item.OnHandQty,
+		}
+	}
+
+	return headers, data
+}
+
+// updateLogStatus updates the status of an access log.
+func (s *stockBalanceService) updateLogStatus(ctx context.Context, logID int, status string) {
+	if logID <= 0 {
+		return
+	}
+
+	ctx, status = logStatusContext(ctx, status)
+	if _, err := s.operationService.UpdateLogStatus(ctx, logID, status); err != nil {
+		s.logger.ErrorContext(ctx, "Error updating log status", "log_id", logID, "error", err)
+	}
+}
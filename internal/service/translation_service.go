@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"erp-excel/internal/translate"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrTranslationNotFound is returned when deleting a label that has no runtime entry
+var ErrTranslationNotFound = apperror.NotFound("translation label not found")
+
+var (
+	translationKeyPattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+	localePattern         = regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+)
+
+// TranslationService manages the translation labels that override or extend the built-in ones,
+// so a new report column can get its header without a deploy
+type TranslationService interface {
+	List(ctx context.Context, locale string) ([]*dto.TranslationLabelResponse, error)
+	Set(ctx context.Context, userID int, locale, key string, request *dto.TranslationLabelRequest) (*dto.TranslationLabelResponse, error)
+	Delete(ctx context.Context, locale, key string) error
+	Reload(ctx context.Context) error
+}
+
+type translationService struct {
+	translationRepo repository.TranslationRepository
+
+	mu            sync.Mutex
+	loadedLocales map[string]bool
+}
+
+// NewTranslationService creates a new translation service
+func NewTranslationService(translationRepo repository.TranslationRepository) TranslationService {
+	return &translationService{
+		translationRepo: translationRepo,
+		loadedLocales:   make(map[string]bool),
+	}
+}
+
+// List returns the effective labels of a locale, built-in and runtime ones, ordered by key
+func (s *translationService) List(ctx context.Context, locale string) ([]*dto.TranslationLabelResponse, error) {
+	if locale == "" {
+		locale = translate.DefaultLocale
+	}
+	if !localePattern.MatchString(locale) {
+		return nil, fmt.Errorf("invalid locale %q", locale)
+	}
+
+	custom, err := s.translationRepo.List(ctx, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make(map[string]*dto.TranslationLabelResponse)
+	for key, label := range translate.BuiltIn(locale) {
+		labels[key] = &dto.TranslationLabelResponse{
+			Locale: locale,
+			Key:    key,
+			Label:  label,
+			Source: dto.TranslationSourceBuiltIn,
+		}
+	}
+	for _, label := range custom {
+		response := translationResponse(label)
+		if builtIn, ok := labels[label.Key]; ok {
+			response.BuiltIn = builtIn.Label
+		}
+		labels[label.Key] = response
+	}
+
+	response := make([]*dto.TranslationLabelResponse, 0, len(labels))
+	for _, label := range labels {
+		response = append(response, label)
+	}
+	sort.Slice(response, func(i, j int) bool {
+		return response[i].Key < response[j].Key
+	})
+
+	return response, nil
+}
+
+// Set stores the label of a key and applies it right away
+func (s *translationService) Set(ctx context.Context, userID int, locale, key string, request *dto.TranslationLabelRequest) (*dto.TranslationLabelResponse, error) {
+	if !localePattern.MatchString(locale) {
+		return nil, fmt.Errorf("invalid locale %q", locale)
+	}
+	if !translationKeyPattern.MatchString(key) || len(key) > 100 {
+		return nil, fmt.Errorf("invalid key %q: use lowercase letters, digits and underscores", key)
+	}
+
+	label := &models.TranslationLabel{
+		Locale:    locale,
+		Key:       key,
+		Label:     strings.TrimSpace(request.Label),
+		UpdatedBy: userID,
+	}
+	if label.Label == "" {
+		return nil, errors.New("label is required")
+	}
+
+	if err := s.translationRepo.Upsert(ctx, label); err != nil {
+		return nil, err
+	}
+	if err := s.Reload(ctx); err != nil {
+		return nil, err
+	}
+
+	response := &dto.TranslationLabelResponse{
+		Locale:    label.Locale,
+		Key:       label.Key,
+		Label:     label.Label,
+		Source:    dto.TranslationSourceCustom,
+		UpdatedBy: userID,
+		BuiltIn:   translate.BuiltIn(locale)[key],
+	}
+
+	return response, nil
+}
+
+// Delete removes a runtime label; a built-in label of the key applies again
+func (s *translationService) Delete(ctx context.Context, locale, key string) error {
+	if err := s.translationRepo.Delete(ctx, locale, key); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTranslationNotFound
+		}
+		return err
+	}
+
+	return s.Reload(ctx)
+}
+
+// Reload reads the runtime labels from the database into the translate package. Other instances
+// pick up changes made elsewhere when they reload.
+func (s *translationService) Reload(ctx context.Context) error {
+	labels, err := s.translationRepo.List(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	byLocale := make(map[string]map[string]string)
+	for _, label := range labels {
+		if byLocale[label.Locale] == nil {
+			byLocale[label.Locale] = make(map[string]string)
+		}
+		byLocale[label.Locale][label.Key] = label.Label
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Locales whose last label was deleted are cleared as well
+	for locale := range s.loadedLocales {
+		if _, ok := byLocale[locale]; !ok {
+			translate.SetOverrides(locale, nil)
+			delete(s.loadedLocales, locale)
+		}
+	}
+	for locale, localeLabels := range byLocale {
+		translate.SetOverrides(locale, localeLabels)
+		s.loadedLocales[locale] = true
+	}
+
+	return nil
+}
+
+// translationResponse converts a stored label to its API response
+func translationResponse(label *models.TranslationLabel) *dto.TranslationLabelResponse {
+	updatedAt := label.UpdatedAt
+	return &dto.TranslationLabelResponse{
+		Locale:    label.Locale,
+		Key:       label.Key,
+		Label:     label.Label,
+		Source:    dto.TranslationSourceCustom,
+		UpdatedBy: label.UpdatedBy,
+		UpdatedAt: &updatedAt,
+	}
+}
@@ -2,31 +2,42 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"erp-excel/internal/apperror"
 	"erp-excel/internal/dto"
+	"erp-excel/internal/events"
 	"erp-excel/internal/models"
 	"erp-excel/internal/repository"
 	"erp-excel/internal/utils"
 	"errors"
 	"fmt"
+	"log/slog"
 )
 
 // UserService interface
 type UserService interface {
 	CreateUser(ctx context.Context, request dto.CreateUserRequest) (*dto.UserResponse, error)
 	GetUserByID(ctx context.Context, id int) (*dto.UserResponse, error)
+	GetUserByUsername(ctx context.Context, username string) (*dto.UserResponse, error)
 	UpdateUser(ctx context.Context, id int, request dto.UpdateUserRequest) (*dto.UserResponse, error)
 	UpdateUserPassword(ctx context.Context, id int, request dto.UpdatePasswordRequest) error
 	DeleteUser(ctx context.Context, id int) error
-	GetAllUsers(ctx context.Context, limit, offset int) ([]*dto.UserResponse, error)
-	CountUsers(ctx context.Context) (int, error)
+	GetAllUsers(ctx context.Context, limit, offset int, includeDeleted bool) ([]*dto.UserResponse, error)
+	CountUsers(ctx context.Context, includeDeleted bool) (int, error)
 	AssignRolesToUser(ctx context.Context, userID int, roleIDs []int) error
+	GetUserRoles(ctx context.Context, userID int) ([]*dto.RoleResponse, error)
+	GetDeletedUsers(ctx context.Context) ([]*dto.UserResponse, error)
+	RestoreUser(ctx context.Context, id int) error
 }
 
 type userService struct {
 	userRepo       repository.UserRepository
 	departmentRepo repository.DepartmentRepository
 	roleRepo       repository.RoleRepository
+	txManager      repository.TxManager
 	authService    AuthService
+	eventService   EventService
+	logger         *slog.Logger
 }
 
 // NewUserService creates a new user service
@@ -34,13 +45,19 @@ func NewUserService(
 	userRepo repository.UserRepository,
 	departmentRepo repository.DepartmentRepository,
 	roleRepo repository.RoleRepository,
+	txManager repository.TxManager,
 	authService AuthService,
+	eventService EventService,
+	logger *slog.Logger,
 ) UserService {
 	return &userService{
 		userRepo:       userRepo,
 		departmentRepo: departmentRepo,
 		roleRepo:       roleRepo,
+		txManager:      txManager,
 		authService:    authService,
+		eventService:   eventService,
+		logger:         logger,
 	}
 }
 
@@ -49,7 +66,7 @@ func (s *userService) CreateUser(ctx context.Context, request dto.CreateUserRequ
 	// Validate department exists
 	department, err := s.departmentRepo.GetByID(ctx, request.DepartmentID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid department: %w", err)
+		return nil, departmentError(request.DepartmentID, err)
 	}
 
 	// Hash password
@@ -68,16 +85,32 @@ func (s *userService) CreateUser(ctx context.Context, request dto.CreateUserRequ
 		IsActive:     true,
 	}
 
-	// Save to database
-	createdUser, err := s.userRepo.Create(ctx, user)
+	// Save the user and their roles together, so a failed role assignment leaves no user behind
+	var createdUser *models.User
+	err = s.txManager.WithinTransaction(ctx, func(tx *sql.Tx) error {
+		userRepo := s.userRepo.WithTx(tx)
+
+		var err error
+		createdUser, err = userRepo.Create(ctx, user)
+		if err != nil {
+			return fmt.Errorf("error creating user: %w", err)
+		}
+
+		if err := userRepo.AssignRoles(ctx, createdUser.ID, request.RoleIDs); err != nil {
+			return fmt.Errorf("error assigning roles: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error creating user: %w", err)
+		return nil, err
 	}
 
-	// Assign roles
-	if err := s.userRepo.AssignRoles(ctx, createdUser.ID, request.RoleIDs); err != nil {
-		return nil, fmt.Errorf("error assigning roles: %w", err)
-	}
+	s.eventService.Emit(ctx, events.UserCreated, events.UserCreatedData{
+		UserID:       createdUser.ID,
+		Username:     createdUser.Username,
+		DepartmentID: createdUser.DepartmentID,
+		Source:       "admin",
+	})
 
 	// Get roles for response
 	roles, err := s.userRepo.GetUserRoles(ctx, createdUser.ID)
@@ -123,6 +156,7 @@ func (s *userService) GetUserByID(ctx context.Context, id int) (*dto.UserRespons
 		Username:     user.Username,
 		FullName:     user.FullName,
 		Email:        user.Email,
+		Phone:        user.Phone,
 		DepartmentID: user.DepartmentID,
 		Department:   user.Department.Name,
 		IsActive:     user.IsActive,
@@ -131,6 +165,15 @@ func (s *userService) GetUserByID(ctx context.Context, id int) (*dto.UserRespons
 	}, nil
 }
 
+// GetUserByUsername gets a user by username, with the roles GetUserByID returns
+func (s *userService) GetUserByUsername(ctx context.Context, username string) (*dto.UserResponse, error) {
+	user, err := s.userRepo.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+	return s.GetUserByID(ctx, user.ID)
+}
+
 // UpdateUser updates a user
 func (s *userService) UpdateUser(ctx context.Context, id int, request dto.UpdateUserRequest) (*dto.UserResponse, error) {
 	// Get existing user
@@ -148,10 +191,14 @@ func (s *userService) UpdateUser(ctx context.Context, id int, request dto.Update
 		user.Email = request.Email
 	}
 
+	if request.Phone != "" {
+		user.Phone = request.Phone
+	}
+
 	if request.DepartmentID != 0 {
 		// Validate department exists
 		if _, err := s.departmentRepo.GetByID(ctx, request.DepartmentID); err != nil {
-			return nil, fmt.Errorf("invalid department: %w", err)
+			return nil, departmentError(request.DepartmentID, err)
 		}
 		user.DepartmentID = request.DepartmentID
 	}
@@ -169,7 +216,7 @@ func (s *userService) UpdateUser(ctx context.Context, id int, request dto.Update
 	department, err := s.departmentRepo.GetByID(ctx, user.DepartmentID)
 	if err != nil {
 		// Log error but don't fail the operation
-		fmt.Printf("Error getting department: %v\n", err)
+		s.logger.ErrorContext(ctx, "Error getting department", "department_id", user.DepartmentID, "error", err)
 	}
 
 	// Get roles for response
@@ -195,6 +242,7 @@ func (s *userService) UpdateUser(ctx context.Context, id int, request dto.Update
 		Username:     user.Username,
 		FullName:     user.FullName,
 		Email:        user.Email,
+		Phone:        user.Phone,
 		DepartmentID: user.DepartmentID,
 		Department:   departmentName,
 		IsActive:     user.IsActive,
@@ -235,14 +283,61 @@ func (s *userService) UpdateUserPassword(ctx context.Context, id int, request dt
 	return nil
 }
 
-// DeleteUser deletes (deactivates) a user
+// DeleteUser moves a user to the trash
 func (s *userService) DeleteUser(ctx context.Context, id int) error {
 	return s.userRepo.Delete(ctx, id)
 }
 
-// In UserService.GetAllUsers
-func (s *userService) GetAllUsers(ctx context.Context, limit, offset int) ([]*dto.UserResponse, error) {
-	users, err := s.userRepo.List(ctx, limit, offset)
+// GetDeletedUsers gets the users in the trash
+func (s *userService) GetDeletedUsers(ctx context.Context) ([]*dto.UserResponse, error) {
+	users, err := s.userRepo.ListDeleted(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing deleted users: %w", err)
+	}
+
+	response := make([]*dto.UserResponse, 0, len(users))
+	for _, user := range users {
+		response = append(response, &dto.UserResponse{
+			ID:           user.ID,
+			Username:     user.Username,
+			FullName:     user.FullName,
+			Email:        user.Email,
+			Phone:        user.Phone,
+			DepartmentID: user.DepartmentID,
+			Department:   user.Department.Name,
+			IsActive:     user.IsActive,
+			CreatedAt:    user.CreatedAt,
+			UpdatedAt:    user.UpdatedAt,
+			DeletedAt:    user.DeletedAt,
+		})
+	}
+
+	return response, nil
+}
+
+// RestoreUser takes a user out of the trash; the user's department has to be restored first
+func (s *userService) RestoreUser(ctx context.Context, id int) error {
+	users, err := s.userRepo.ListDeleted(ctx)
+	if err != nil {
+		return fmt.Errorf("error listing deleted users: %w", err)
+	}
+
+	for _, user := range users {
+		if user.ID != id {
+			continue
+		}
+		if _, err := s.departmentRepo.GetByID(ctx, user.DepartmentID); err != nil {
+			return fmt.Errorf("department %d of the user is deleted or missing: %w", user.DepartmentID, err)
+		}
+		return restoreError(s.userRepo.Restore(ctx, id))
+	}
+
+	return ErrNotDeleted
+}
+
+// GetAllUsers gets a page of users; deleted users are included, marked by DeletedAt, on request
+func (s *userService) GetAllUsers(ctx context.Context, limit, offset int, includeDeleted bool) ([]*dto.UserResponse, error) {
+	users, err := s.userRepo.List(ctx, limit, offset, includeDeleted)
 	if err != nil {
 		return nil, fmt.Errorf("error listing users: %w", err)
 	}
@@ -266,23 +361,52 @@ func (s *userService) GetAllUsers(ctx context.Context, limit, offset int) ([]*dt
 			Username:     user.Username,
 			FullName:     user.FullName,
 			Email:        user.Email,
+			Phone:        user.Phone,
 			DepartmentID: user.DepartmentID,
 			Department:   departmentName,
 			IsActive:     user.IsActive,
 			CreatedAt:    user.CreatedAt,
 			Roles:        roleNames,
+			DeletedAt:    user.DeletedAt,
 		})
 	}
 
 	return response, nil
 }
 
-// CountUsers gets the total number of users
-func (s *userService) CountUsers(ctx context.Context) (int, error) {
-	return s.userRepo.Count(ctx)
+// CountUsers gets the total number of users, with the deleted ones when includeDeleted is set
+func (s *userService) CountUsers(ctx context.Context, includeDeleted bool) (int, error) {
+	return s.userRepo.Count(ctx, includeDeleted)
 }
 
 // AssignRolesToUser assigns roles to a user
 func (s *userService) AssignRolesToUser(ctx context.Context, userID int, roleIDs []int) error {
 	return s.userRepo.AssignRoles(ctx, userID, roleIDs)
 }
+
+// GetUserRoles gets the roles assigned to a user, without their operations
+func (s *userService) GetUserRoles(ctx context.Context, userID int) ([]*dto.RoleResponse, error) {
+	roles, err := s.userRepo.GetUserRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*dto.RoleResponse, 0, len(roles))
+	for _, role := range roles {
+		response = append(response, &dto.RoleResponse{
+			ID:          role.ID,
+			Name:        role.Name,
+			Description: role.Description,
+		})
+	}
+	return response, nil
+}
+
+// departmentError explains a failed lookup of the department of a user request: a department
+// that does not exist is a validation error of the request
+func departmentError(departmentID int, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperror.Validation(fmt.Sprintf("invalid department: department %d does not exist", departmentID))
+	}
+	return fmt.Errorf("invalid department: %w", err)
+}
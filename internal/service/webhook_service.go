@@ -0,0 +1,400 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"erp-excel/config"
+	"erp-excel/internal/apperror"
+	"erp-excel/internal/dto"
+	"erp-excel/internal/events"
+	"erp-excel/internal/models"
+	"erp-excel/internal/repository"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"time"
+)
+
+var (
+	// ErrWebhookNotFound is returned for an unknown webhook ID
+	ErrWebhookNotFound = apperror.NotFound("webhook not found")
+	// ErrWebhookDeliveryNotFound is returned for an unknown delivery ID
+	ErrWebhookDeliveryNotFound = apperror.NotFound("webhook delivery not found")
+	// ErrInvalidWebhook is returned for a URL that is not http(s) or an unknown event type
+	ErrInvalidWebhook = apperror.Validation("invalid webhook")
+)
+
+const (
+	// webhookSecretPrefix starts generated secrets, so leaked ones are easy to recognise
+	webhookSecretPrefix = "whsec_"
+
+	defaultWebhookListLimit = 100
+	maxWebhookListLimit     = 1000
+)
+
+// WebhookService manages the webhooks administrators register and delivers events to them. Events
+// are queued in the delivery log as they happen and sent by a background worker, which retries
+// failed deliveries with exponential backoff.
+type WebhookService interface {
+	List(ctx context.Context) ([]*models.Webhook, error)
+	Get(ctx context.Context, id int) (*models.Webhook, error)
+	Create(ctx context.Context, userID int, request *dto.WebhookRequest) (*dto.WebhookCreatedResponse, error)
+	Update(ctx context.Context, id int, request *dto.WebhookRequest) (*models.Webhook, error)
+	Delete(ctx context.Context, id int) error
+	ListDeliveries(ctx context.Context, filter repository.WebhookDeliveryFilter) ([]*models.WebhookDelivery, error)
+	Redeliver(ctx context.Context, id int64) error
+	Enqueue(ctx context.Context, eventType string, data interface{})
+	Start(ctx context.Context)
+	Stop(ctx context.Context)
+}
+
+type webhookService struct {
+	config      config.WebhooksConfig
+	webhookRepo repository.WebhookRepository
+	sender      *events.WebhookSender
+	workers     workerGroup
+	logger      *slog.Logger
+}
+
+// NewWebhookService creates a new webhook service
+func NewWebhookService(cfg config.WebhooksConfig, webhookRepo repository.WebhookRepository, logger *slog.Logger) WebhookService {
+	if cfg.IntervalSeconds <= 0 {
+		cfg.IntervalSeconds = 5
+	}
+	if cfg.TimeoutSeconds <= 0 {
+		cfg.TimeoutSeconds = 10
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.RetryBaseSeconds <= 0 {
+		cfg.RetryBaseSeconds = 30
+	}
+	if cfg.RetryMaxSeconds <= 0 {
+		cfg.RetryMaxSeconds = 3600
+	}
+	if cfg.RetentionDays <= 0 {
+		cfg.RetentionDays = 30
+	}
+
+	return &webhookService{
+		config:      cfg,
+		webhookRepo: webhookRepo,
+		sender:      events.NewWebhookSender(time.Duration(cfg.TimeoutSeconds) * time.Second),
+		logger:      logger,
+	}
+}
+
+// List gets all webhooks, without their secrets
+func (s *webhookService) List(ctx context.Context) ([]*models.Webhook, error) {
+	return s.webhookRepo.List(ctx)
+}
+
+// Get gets a webhook, without its secret
+func (s *webhookService) Get(ctx context.Context, id int) (*models.Webhook, error) {
+	webhook, err := s.webhookRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// Create registers a webhook. The secret is returned once, whether it was given or generated.
+func (s *webhookService) Create(ctx context.Context, userID int, request *dto.WebhookRequest) (*dto.WebhookCreatedResponse, error) {
+	if err := validateWebhookRequest(request); err != nil {
+		return nil, err
+	}
+
+	secret := request.Secret
+	if secret == "" {
+		var err error
+		if secret, err = generateWebhookSecret(); err != nil {
+			return nil, err
+		}
+	}
+
+	webhook := &models.Webhook{
+		Name:      request.Name,
+		URL:       request.URL,
+		Secret:    secret,
+		Events:    slices.Compact(slices.Sorted(slices.Values(request.Events))),
+		IsActive:  request.IsActive == nil || *request.IsActive,
+		CreatedBy: userID,
+	}
+	if _, err := s.webhookRepo.Create(ctx, webhook); err != nil {
+		return nil, err
+	}
+
+	return &dto.WebhookCreatedResponse{Webhook: webhook, Secret: secret}, nil
+}
+
+// Update changes a webhook, keeping its secret unless the request has a new one
+func (s *webhookService) Update(ctx context.Context, id int, request *dto.WebhookRequest) (*models.Webhook, error) {
+	if err := validateWebhookRequest(request); err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.Name = request.Name
+	webhook.URL = request.URL
+	webhook.Events = slices.Compact(slices.Sorted(slices.Values(request.Events)))
+	webhook.IsActive = request.IsActive == nil || *request.IsActive
+	if request.Secret != "" {
+		webhook.Secret = request.Secret
+	}
+
+	if err := s.webhookRepo.Update(ctx, webhook); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// Delete removes a webhook and its delivery log
+func (s *webhookService) Delete(ctx context.Context, id int) error {
+	if err := s.webhookRepo.Delete(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrWebhookNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// ListDeliveries gets the newest deliveries matching the filter
+func (s *webhookService) ListDeliveries(ctx context.Context, filter repository.WebhookDeliveryFilter) ([]*models.WebhookDelivery, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultWebhookListLimit
+	}
+	if filter.Limit > maxWebhookListLimit {
+		filter.Limit = maxWebhookListLimit
+	}
+
+	return s.webhookRepo.ListDeliveries(ctx, filter)
+}
+
+// Redeliver queues a delivery again, e.g. one that failed while the receiver was down
+func (s *webhookService) Redeliver(ctx context.Context, id int64) error {
+	if err := s.webhookRepo.RetryDelivery(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrWebhookDeliveryNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Enqueue queues a delivery of an event to each active webhook subscribed to its type. Failures are
+// logged only, so a broken webhook never fails the operation that produced the event.
+func (s *webhookService) Enqueue(ctx context.Context, eventType string, data interface{}) {
+	if !s.config.Enabled {
+		return
+	}
+
+	webhooks, err := s.List(ctx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error loading webhooks", "event", eventType, "error", err)
+		return
+	}
+
+	var payload []byte
+	for _, webhook := range webhooks {
+		if !webhook.IsActive || !webhook.Subscribes(eventType) {
+			continue
+		}
+
+		if payload == nil {
+			if payload, err = json.Marshal(data); err != nil {
+				s.logger.ErrorContext(ctx, "Error marshalling webhook event", "event", eventType, "error", err)
+				return
+			}
+		}
+
+		delivery := &models.WebhookDelivery{
+			WebhookID: webhook.ID,
+			EventType: eventType,
+			Payload:   string(payload),
+		}
+		if _, err := s.webhookRepo.AddDelivery(ctx, delivery); err != nil {
+			s.logger.ErrorContext(ctx, "Error queueing webhook delivery", "webhook_id", webhook.ID, "event", eventType, "error", err)
+		}
+	}
+}
+
+// Start runs the delivery worker until the context is cancelled
+func (s *webhookService) Start(ctx context.Context) {
+	if !s.config.Enabled {
+		return
+	}
+
+	interval := time.Duration(s.config.IntervalSeconds) * time.Second
+
+	// Cancelling ctx stops claiming deliveries; the running one finishes on workCtx
+	workCtx := s.workers.start(ctx)
+	s.workers.run(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			for s.deliverNext(ctx, workCtx) {
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+
+	// The delivery log would otherwise grow forever
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			s.pruneDeliveries(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	s.logger.InfoContext(ctx, "Webhook delivery worker started", "interval", interval.String())
+}
+
+func (s *webhookService) Stop(ctx context.Context) {
+	s.workers.stop(ctx)
+}
+
+// deliverNext claims a due delivery while ctx is not cancelled and sends it on workCtx, reporting
+// whether there was one
+func (s *webhookService) deliverNext(ctx context.Context, workCtx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	// The lease outlasts the attempt, so the delivery is only claimed again if this worker died
+	lease := time.Duration(s.config.TimeoutSeconds)*time.Second + time.Minute
+	delivery, err := s.webhookRepo.ClaimDelivery(ctx, lease)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error claiming webhook delivery", "error", err)
+		return false
+	}
+	if delivery == nil {
+		return false
+	}
+
+	s.deliver(workCtx, delivery)
+	return true
+}
+
+// deliver sends a claimed delivery and records the outcome of the attempt
+func (s *webhookService) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	// Deliveries of a webhook deleted or deactivated since they were queued are given up
+	webhook, err := s.webhookRepo.GetByID(ctx, delivery.WebhookID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		s.failDelivery(ctx, delivery, 0, errors.New("webhook was deleted"), false)
+		return
+	case err != nil:
+		s.failDelivery(ctx, delivery, 0, err, true)
+		return
+	case !webhook.IsActive:
+		s.failDelivery(ctx, delivery, 0, errors.New("webhook is inactive"), false)
+		return
+	}
+
+	event := events.Event{
+		ID:         delivery.ID,
+		Type:       delivery.EventType,
+		OccurredAt: delivery.CreatedAt,
+		Data:       json.RawMessage(delivery.Payload),
+	}
+	status, err := s.sender.Send(ctx, webhook.URL, webhook.Secret, event)
+	if err != nil {
+		s.failDelivery(ctx, delivery, status, err, true)
+		return
+	}
+
+	if err := s.webhookRepo.CompleteDelivery(ctx, delivery.ID, status); err != nil {
+		s.logger.ErrorContext(ctx, "Error updating webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// failDelivery records a failed attempt, scheduling the next one after a delay doubling with each
+// attempt, or giving the delivery up once it is out of attempts
+func (s *webhookService) failDelivery(ctx context.Context, delivery *models.WebhookDelivery, status int, deliveryErr error, retry bool) {
+	var nextAttemptAt *time.Time
+	if retry && delivery.Attempts < s.config.MaxAttempts {
+		next := time.Now().Add(retryDelay(delivery.Attempts, s.config.RetryBaseSeconds, s.config.RetryMaxSeconds))
+		nextAttemptAt = &next
+	}
+
+	s.logger.WarnContext(ctx, "Webhook delivery failed",
+		"delivery_id", delivery.ID,
+		"webhook_id", delivery.WebhookID,
+		"event", delivery.EventType,
+		"attempt", delivery.Attempts,
+		"retry", nextAttemptAt != nil,
+		"error", deliveryErr,
+	)
+
+	if err := s.webhookRepo.FailDelivery(ctx, delivery.ID, status, deliveryErr.Error(), nextAttemptAt); err != nil {
+		s.logger.ErrorContext(ctx, "Error updating webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// pruneDeliveries removes the finished deliveries older than the retention
+func (s *webhookService) pruneDeliveries(ctx context.Context) {
+	before := time.Now().AddDate(0, 0, -s.config.RetentionDays)
+	removed, err := s.webhookRepo.DeleteDeliveriesBefore(ctx, before)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error pruning webhook deliveries", "error", err)
+		return
+	}
+	if removed > 0 {
+		s.logger.InfoContext(ctx, "Pruned webhook deliveries", "count", removed)
+	}
+}
+
+// validateWebhookRequest checks what the validation tags cannot: an http(s) URL and known events
+func validateWebhookRequest(request *dto.WebhookRequest) error {
+	target, err := url.Parse(request.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("%w: the url must be an http or https URL", ErrInvalidWebhook)
+	}
+
+	for _, event := range request.Events {
+		if !slices.Contains(events.Types, event) {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+		}
+	}
+	return nil
+}
+
+// generateWebhookSecret returns a new random secret of 32 bytes
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("error generating webhook secret: %w", err)
+	}
+	return webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// workerGroup tracks the goroutines of a background service so shutdown can wait for the work
+// they are doing. The work runs on its own context, which the shutdown only cancels once the
+// drain time runs out, so stopping the service does not abort a half-generated export.
+type workerGroup struct {
+	wg         sync.WaitGroup
+	workCtx    context.Context
+	cancelWork context.CancelFunc
+}
+
+// start prepares the work context, keeping the values of ctx but not its cancellation
+func (g *workerGroup) start(ctx context.Context) context.Context {
+	g.workCtx, g.cancelWork = context.WithCancel(context.WithoutCancel(ctx))
+	return g.workCtx
+}
+
+// run runs a worker goroutine
+func (g *workerGroup) run(worker func()) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		worker()
+	}()
+}
+
+// stop waits for the workers to return. They must have been told to stop taking new work.
+// When ctx is done first, the running work is cancelled and stop waits for it to unwind.
+func (g *workerGroup) stop(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+
+	if g.cancelWork != nil {
+		g.cancelWork()
+	}
+	<-done
+}
+
+// retryDelay is the delay after a failed attempt of background work: the base delay after the
+// first, doubling with each attempt up to the maximum
+func retryDelay(attempts, baseSeconds, maxSeconds int) time.Duration {
+	delay := time.Duration(baseSeconds) * time.Second
+	limit := time.Duration(maxSeconds) * time.Second
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
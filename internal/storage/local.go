@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tempPrefix starts the names of files being written, which are hidden from List
+const tempPrefix = ".tmp-"
+
+type localStorage struct {
+	basePath string
+	shared   bool // a network share mounted on every instance, which is never created here
+}
+
+// NewLocalStorage creates a storage backed by a directory on the server
+func NewLocalStorage(basePath string) Storage {
+	if basePath == "" {
+		basePath = filepath.Join("public", "downloads")
+	}
+	return &localStorage{
+		basePath: basePath,
+	}
+}
+
+// NewShareStorage creates a storage backed by a network share (SMB/CIFS or NFS) mounted at the
+// same path on every instance, so any instance can serve the files another one generated. The
+// directory must exist: creating it would write to the local disk when the share is not mounted.
+func NewShareStorage(sharePath string) (Storage, error) {
+	if sharePath == "" {
+		return nil, errors.New("storage share_path is not configured")
+	}
+	info, err := os.Stat(sharePath)
+	if err != nil {
+		return nil, fmt.Errorf("storage share is not available: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("storage share %s is not a directory", sharePath)
+	}
+
+	return &localStorage{
+		basePath: sharePath,
+		shared:   true,
+	}, nil
+}
+
+// Save writes the content to a temporary file in the base directory and renames it into place,
+// so a reader, possibly on another instance, never sees a partly written file. Files on disk
+// carry no metadata.
+func (s *localStorage) Save(ctx context.Context, name string, content io.Reader, size int64, contentType string, metadata Metadata) error {
+	if !s.shared {
+		if err := os.MkdirAll(s.basePath, 0o755); err != nil {
+			return fmt.Errorf("error creating storage directory: %w", err)
+		}
+	}
+
+	file, err := os.CreateTemp(s.basePath, tempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("error creating file: %w", err)
+	}
+	tempPath := file.Name()
+	defer os.Remove(tempPath) // no-op once renamed
+
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing file: %w", err)
+	}
+	// Flush to the share before the file becomes visible under its name
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error writing file: %w", err)
+	}
+
+	if err := os.Rename(tempPath, s.path(name)); err != nil {
+		return fmt.Errorf("error storing file: %w", err)
+	}
+	return nil
+}
+
+// Open opens a stored file
+func (s *localStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	file, err := os.Open(s.path(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	return file, nil
+}
+
+// Exists checks whether a stored file exists
+func (s *localStorage) Exists(ctx context.Context, name string) (bool, error) {
+	info, err := os.Stat(s.path(name))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error checking file: %w", err)
+	}
+	return !info.IsDir(), nil
+}
+
+// Delete removes a stored file
+func (s *localStorage) Delete(ctx context.Context, name string) error {
+	if err := os.Remove(s.path(name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error deleting file: %w", err)
+	}
+	return nil
+}
+
+// List returns the files in the base directory
+func (s *localStorage) List(ctx context.Context) ([]FileInfo, error) {
+	entries, err := os.ReadDir(s.basePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error listing files: %w", err)
+	}
+
+	files := make([]FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), tempPrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// Removed while listing
+			continue
+		}
+		files = append(files, FileInfo{
+			Name:       entry.Name(),
+			Size:       info.Size(),
+			ModifiedAt: info.ModTime(),
+		})
+	}
+
+	return files, nil
+}
+
+// PresignedURL is not supported for local files, they are served by the API
+func (s *localStorage) PresignedURL(ctx context.Context, name string, expiry time.Duration) (string, error) {
+	return "", nil
+}
+
+// path returns the on-disk path for a file, stripping any directory components
+func (s *localStorage) path(name string) string {
+	return filepath.Join(s.basePath, filepath.Base(name))
+}
@@ -0,0 +1,597 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"erp-excel/config"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	s3Service       = "s3"
+	s3Algorithm     = "AWS4-HMAC-SHA256"
+	s3UnsignedBody  = "UNSIGNED-PAYLOAD"
+	s3TimeFormat    = "20060102T150405Z"
+	s3ShortDateForm = "20060102"
+)
+
+type s3Storage struct {
+	config     config.S3Config
+	endpoint   *url.URL
+	httpClient *http.Client
+}
+
+// NewS3Storage creates a storage backed by an S3 compatible bucket (AWS S3 or MinIO)
+func NewS3Storage(cfg config.S3Config) (Storage, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3 bucket is required")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("s3 access key and secret key are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("s3.%s.amazonaws.com", cfg.Region)
+	}
+	if !strings.Contains(endpoint, "://") {
+		scheme := "https"
+		if !cfg.UseSSL {
+			scheme = "http"
+		}
+		endpoint = scheme + "://" + endpoint
+	}
+
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+
+	return &s3Storage{
+		config:     cfg,
+		endpoint:   parsed,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// Save uploads the content to the bucket with the metadata as x-amz-meta-* headers. The object is
+// served as an attachment under its name, so presigned downloads save under it too.
+func (s *s3Storage) Save(ctx context.Context, name string, content io.Reader, size int64, contentType string, metadata Metadata) error {
+	body, err := io.ReadAll(content)
+	if err != nil {
+		return fmt.Errorf("error reading content: %w", err)
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPut, name, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(name)}))
+	for key, value := range metadata {
+		if value == "" {
+			continue
+		}
+		// Metadata must be US-ASCII; other values are sent RFC 2047 encoded, as S3 expects
+		req.Header.Set("X-Amz-Meta-"+key, mime.QEncoding.Encode("utf-8", value))
+	}
+
+	sum := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(sum[:]), time.Now().UTC())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error uploading object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s.responseError("error uploading object", resp)
+	}
+
+	return nil
+}
+
+// Open downloads an object from the bucket
+func (s *s3Storage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, s3UnsignedBody, time.Now().UTC())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading object: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, s.responseError("error downloading object", resp)
+	}
+
+	return resp.Body, nil
+}
+
+// Exists checks whether an object exists in the bucket
+func (s *s3Storage) Exists(ctx context.Context, name string) (bool, error) {
+	req, err := s.newRequest(ctx, http.MethodHead, name, nil)
+	if err != nil {
+		return false, err
+	}
+	s.sign(req, s3UnsignedBody, time.Now().UTC())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("error checking object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("error checking object: %s", resp.Status)
+	}
+}
+
+// Delete removes an object from the bucket
+func (s *s3Storage) Delete(ctx context.Context, name string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, name, nil)
+	if err != nil {
+		return err
+	}
+	s.sign(req, s3UnsignedBody, time.Now().UTC())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error deleting object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return s.responseError("error deleting object", resp)
+	}
+
+	return nil
+}
+
+// List returns the objects under the configured prefix, following continuation tokens
+func (s *s3Storage) List(ctx context.Context) ([]FileInfo, error) {
+	prefix := strings.TrimPrefix(s.config.Prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	var files []FileInfo
+	continuationToken := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+
+		bucketURL := s.bucketURL()
+		bucketURL.RawQuery = canonicalQuery(query)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, bucketURL.String(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("error creating s3 request: %w", err)
+		}
+		s.sign(req, s3UnsignedBody, time.Now().UTC())
+
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error listing objects: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			err := s.responseError("error listing objects", resp)
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var result struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error decoding object list: %w", err)
+		}
+
+		for _, object := range result.Contents {
+			name := strings.TrimPrefix(object.Key, prefix)
+			// Only files written by Save, which never nests them under the prefix
+			if name == "" || strings.Contains(name, "/") {
+				continue
+			}
+			files = append(files, FileInfo{
+				Name:       name,
+				Size:       object.Size,
+				ModifiedAt: object.LastModified,
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return files, nil
+		}
+		continuationToken = result.NextContinuationToken
+	}
+}
+
+// PresignedURL returns a query-signed GET URL valid for the given duration
+func (s *s3Storage) PresignedURL(ctx context.Context, name string, expiry time.Duration) (string, error) {
+	if expiry <= 0 {
+		expiry = time.Duration(s.config.PresignExpiryMinutes) * time.Minute
+	}
+	if expiry <= 0 {
+		expiry = 15 * time.Minute
+	}
+
+	now := time.Now().UTC()
+	objectURL := s.objectURL(name)
+	scope := s.credentialScope(now)
+
+	query := objectURL.Query()
+	query.Set("X-Amz-Algorithm", s3Algorithm)
+	query.Set("X-Amz-Credential", s.config.AccessKey+"/"+scope)
+	query.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	query.Set("X-Amz-Expires", fmt.Sprintf("%d", int(expiry.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	objectURL.RawQuery = canonicalQuery(query)
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		objectURL.EscapedPath(),
+		objectURL.RawQuery,
+		"host:" + objectURL.Host + "\n",
+		"host",
+		s3UnsignedBody,
+	}, "\n")
+
+	signature := s.signature(now, scope, canonicalRequest)
+	objectURL.RawQuery += "&X-Amz-Signature=" + signature
+
+	return objectURL.String(), nil
+}
+
+// s3LifecycleRule is a rule of a bucket lifecycle configuration. The rules of others are kept
+// as they are, only their ID is read.
+type s3LifecycleRule struct {
+	ID    string `xml:"ID"`
+	Inner string `xml:",innerxml"`
+}
+
+type s3LifecycleConfiguration struct {
+	XMLName xml.Name          `xml:"LifecycleConfiguration"`
+	Rules   []s3LifecycleRule `xml:"Rule"`
+}
+
+// s3LifecycleRequest encodes a lifecycle configuration; the rules are written back as read
+type s3LifecycleRequest struct {
+	XMLName xml.Name    `xml:"LifecycleConfiguration"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Rules   []s3RawRule `xml:"Rule"`
+}
+
+type s3RawRule struct {
+	Inner string `xml:",innerxml"`
+}
+
+// SetExpiry sets a bucket lifecycle rule expiring the objects under the prefix after the given
+// number of days, keeping the other rules of the bucket. S3 counts the days from the upload and
+// deletes expired objects in the background, usually within a day.
+func (s *s3Storage) SetExpiry(ctx context.Context, days int) error {
+	lifecycle, err := s.getLifecycle(ctx)
+	if err != nil {
+		return err
+	}
+
+	prefix := strings.TrimPrefix(s.config.Prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	ruleID := "erp-excel-retention"
+	if prefix != "" {
+		ruleID += "-" + strings.TrimSuffix(strings.ReplaceAll(prefix, "/", "-"), "-")
+	}
+
+	rules := lifecycle.Rules[:0]
+	for _, rule := range lifecycle.Rules {
+		if rule.ID != ruleID {
+			rules = append(rules, rule)
+		}
+	}
+	if days > 0 {
+		var filter bytes.Buffer
+		xml.EscapeText(&filter, []byte(prefix))
+		rules = append(rules, s3LifecycleRule{
+			ID: ruleID,
+			Inner: fmt.Sprintf("<ID>%s</ID><Filter><Prefix>%s</Prefix></Filter><Status>Enabled</Status><Expiration><Days>%d</Days></Expiration>",
+				ruleID, filter.String(), days),
+		})
+	}
+	lifecycle.Rules = rules
+
+	return s.putLifecycle(ctx, lifecycle)
+}
+
+// getLifecycle reads the lifecycle configuration of the bucket, empty when it has none
+func (s *s3Storage) getLifecycle(ctx context.Context) (*s3LifecycleConfiguration, error) {
+	req, err := s.newBucketRequest(ctx, http.MethodGet, "lifecycle", nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, s3UnsignedBody, time.Now().UTC())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error reading bucket lifecycle: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return &s3LifecycleConfiguration{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s.responseError("error reading bucket lifecycle", resp)
+	}
+
+	var lifecycle s3LifecycleConfiguration
+	if err := xml.NewDecoder(resp.Body).Decode(&lifecycle); err != nil {
+		return nil, fmt.Errorf("error decoding bucket lifecycle: %w", err)
+	}
+	return &lifecycle, nil
+}
+
+// putLifecycle replaces the lifecycle configuration of the bucket, or deletes it when it has no
+// rules left, which S3 does not accept
+func (s *s3Storage) putLifecycle(ctx context.Context, lifecycle *s3LifecycleConfiguration) error {
+	if len(lifecycle.Rules) == 0 {
+		req, err := s.newBucketRequest(ctx, http.MethodDelete, "lifecycle", nil)
+		if err != nil {
+			return err
+		}
+		s.sign(req, s3UnsignedBody, time.Now().UTC())
+
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("error deleting bucket lifecycle: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+			return s.responseError("error deleting bucket lifecycle", resp)
+		}
+		return nil
+	}
+
+	request := s3LifecycleRequest{Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/"}
+	for _, rule := range lifecycle.Rules {
+		request.Rules = append(request.Rules, s3RawRule{Inner: rule.Inner})
+	}
+	body, err := xml.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("error encoding bucket lifecycle: %w", err)
+	}
+
+	req, err := s.newBucketRequest(ctx, http.MethodPut, "lifecycle", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Type", "application/xml")
+	// S3 requires the MD5 of lifecycle configurations
+	md5Sum := md5.Sum(body)
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(md5Sum[:]))
+
+	sum := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(sum[:]), time.Now().UTC())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error writing bucket lifecycle: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s.responseError("error writing bucket lifecycle", resp)
+	}
+	return nil
+}
+
+// newBucketRequest builds an unsigned request for a subresource of the bucket, such as lifecycle
+func (s *s3Storage) newBucketRequest(ctx context.Context, method, subresource string, body io.Reader) (*http.Request, error) {
+	bucketURL := s.bucketURL()
+	bucketURL.RawQuery = subresource + "="
+
+	req, err := http.NewRequestWithContext(ctx, method, bucketURL.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating s3 request: %w", err)
+	}
+	return req, nil
+}
+
+// newRequest builds an unsigned request for an object
+func (s *s3Storage) newRequest(ctx context.Context, method, name string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(name).String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating s3 request: %w", err)
+	}
+	return req, nil
+}
+
+// objectURL returns the URL of an object, using path-style addressing when configured (MinIO)
+func (s *s3Storage) objectURL(name string) *url.URL {
+	u := *s.endpoint
+	key := strings.TrimPrefix(path.Join(s.config.Prefix, path.Base(name)), "/")
+
+	if s.config.PathStyle {
+		u.Path = "/" + s.config.Bucket + "/" + key
+	} else {
+		u.Host = s.config.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+
+	// SigV4 needs every path segment encoded with the strict RFC 3986 rules
+	segments := strings.Split(u.Path, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	u.RawPath = strings.Join(segments, "/")
+
+	return &u
+}
+
+// bucketURL returns the URL of the bucket itself, used to list objects
+func (s *s3Storage) bucketURL() *url.URL {
+	u := *s.endpoint
+	if s.config.PathStyle {
+		u.Path = "/" + s.config.Bucket
+	} else {
+		u.Host = s.config.Bucket + "." + u.Host
+		u.Path = "/"
+	}
+	return &u
+}
+
+// sign adds AWS Signature Version 4 headers to the request
+func (s *s3Storage) sign(req *http.Request, payloadHash string, now time.Time) {
+	req.Header.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+	// Every x-amz-* header, metadata included, must be signed
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" || lower == "content-md5" {
+			headers[lower] = strings.Join(values, ",")
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := s.credentialScope(now)
+	signature := s.signature(now, scope, canonicalRequest)
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.config.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+// credentialScope returns the date/region/service scope used in signatures
+func (s *s3Storage) credentialScope(now time.Time) string {
+	return fmt.Sprintf("%s/%s/%s/aws4_request", now.Format(s3ShortDateForm), s.config.Region, s3Service)
+}
+
+// signature computes the request signature from the canonical request
+func (s *s3Storage) signature(now time.Time, scope, canonicalRequest string) string {
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		now.Format(s3TimeFormat),
+		scope,
+		hex.EncodeToString(hashed[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), now.Format(s3ShortDateForm))
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, s3Service)
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// responseError builds an error including the S3 error body
+func (s *s3Storage) responseError(prefix string, resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s: %s: %s", prefix, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// canonicalQuery encodes query parameters sorted by key as SigV4 requires
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range values[key] {
+			parts = append(parts, s3Escape(key)+"="+s3Escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape percent-encodes a string using the RFC 3986 unreserved set
+func s3Escape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
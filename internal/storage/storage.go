@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"erp-excel/config"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned when a stored file does not exist
+var ErrNotFound = errors.New("file not found")
+
+// Metadata describes a stored file, e.g. the report and user it was generated for. Backends that
+// can keep it with the file do, others ignore it.
+type Metadata map[string]string
+
+// FileInfo describes a stored file
+type FileInfo struct {
+	Name       string
+	Size       int64
+	ModifiedAt time.Time
+}
+
+// Storage stores generated files such as report exports
+type Storage interface {
+	// Save writes the content under the given name
+	Save(ctx context.Context, name string, content io.Reader, size int64, contentType string, metadata Metadata) error
+	// Open returns a reader for a stored file
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+	// Exists reports whether a file is stored under the given name
+	Exists(ctx context.Context, name string) (bool, error)
+	// Delete removes a stored file
+	Delete(ctx context.Context, name string) error
+	// List returns every stored file
+	List(ctx context.Context) ([]FileInfo, error)
+	// PresignedURL returns a temporary direct download URL, or an empty string
+	// when the backend can't serve files directly
+	PresignedURL(ctx context.Context, name string, expiry time.Duration) (string, error)
+}
+
+// ExpiryPolicy is implemented by backends that can delete old files themselves, such as S3 with a
+// bucket lifecycle rule, so files expire even while no instance runs the retention cleanup
+type ExpiryPolicy interface {
+	// SetExpiry deletes the stored files once they are the given number of days old, or stops
+	// doing so when days is 0
+	SetExpiry(ctx context.Context, days int) error
+}
+
+// NewStorage creates the storage backend selected in configuration. With several instances
+// behind a load balancer, choose a backend they all reach: a network share or S3.
+func NewStorage(cfg config.StorageConfig) (Storage, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocalStorage(cfg.LocalPath), nil
+	case "share", "smb":
+		return NewShareStorage(cfg.SharePath)
+	case "s3", "minio":
+		return NewS3Storage(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", cfg.Driver)
+	}
+}
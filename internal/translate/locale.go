@@ -0,0 +1,208 @@
+package translate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// languageFile is the content of a language file, <locale>.json in the locales directory
+type languageFile struct {
+	Labels   map[string]string `json:"labels"`   // report headers, values and titles by key
+	Messages map[string]string `json:"messages"` // API messages by their English text
+}
+
+var (
+	filesMu sync.RWMutex
+	files   = map[string]languageFile{}
+)
+
+// LoadLocales reads the language files of a directory, replacing those loaded before. The locale
+// of a file is its name without the .json extension. An empty directory name loads nothing.
+func LoadLocales(dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("error listing language files: %w", err)
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no language files in %s", dir)
+	}
+
+	loaded := make(map[string]languageFile, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading language file: %w", err)
+		}
+
+		var file languageFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("error parsing language file %s: %w", filepath.Base(path), err)
+		}
+		loaded[strings.TrimSuffix(filepath.Base(path), ".json")] = file
+	}
+
+	filesMu.Lock()
+	files = loaded
+	filesMu.Unlock()
+
+	return nil
+}
+
+// fileLabel returns the label of a key in the language file of a locale
+func fileLabel(locale, key string) (string, bool) {
+	filesMu.RLock()
+	defer filesMu.RUnlock()
+
+	label, ok := files[locale].Labels[key]
+	return label, ok
+}
+
+// fileLabels returns the labels of the language file of a locale
+func fileLabels(locale string) map[string]string {
+	filesMu.RLock()
+	defer filesMu.RUnlock()
+
+	return files[locale].Labels
+}
+
+// Locales returns the locales requests may choose, ordered with the default locale first
+func Locales() []string {
+	filesMu.RLock()
+	locales := make([]string, 0, len(files)+1)
+	for locale := range files {
+		if locale != DefaultLocale {
+			locales = append(locales, locale)
+		}
+	}
+	filesMu.RUnlock()
+
+	sort.Strings(locales)
+	return append([]string{DefaultLocale}, locales...)
+}
+
+// supported reports whether a locale has a language file or is the default one
+func supported(locale string) bool {
+	if locale == DefaultLocale {
+		return true
+	}
+
+	filesMu.RLock()
+	defer filesMu.RUnlock()
+
+	_, ok := files[locale]
+	return ok
+}
+
+// match returns the supported locale of a language tag such as "en-US", matching its primary
+// language when the full tag has no language file
+func match(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return "", false
+	}
+	if supported(tag) {
+		return tag, true
+	}
+	if base, _, found := strings.Cut(tag, "-"); found && supported(base) {
+		return base, true
+	}
+	return "", false
+}
+
+// Resolve picks the locale of a request from its lang query parameter, then its Accept-Language
+// header, falling back to the default locale. explicit is false for the fallback.
+func Resolve(query, acceptLanguage string) (locale string, explicit bool) {
+	if locale, ok := match(query); ok {
+		return locale, true
+	}
+
+	type weighted struct {
+		tag    string
+		weight float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		weight := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		if weight > 0 {
+			tags = append(tags, weighted{tag: tag, weight: weight})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].weight > tags[j].weight
+	})
+
+	for _, tag := range tags {
+		if locale, ok := match(tag.tag); ok {
+			return locale, true
+		}
+	}
+
+	return DefaultLocale, false
+}
+
+type localeKey struct{}
+
+// WithLocale returns a context carrying the locale of a request
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// FromContext returns the locale carried by a context, or the default locale
+func FromContext(ctx context.Context) string {
+	if locale, ok := ctx.Value(localeKey{}).(string); ok && locale != "" {
+		return locale
+	}
+	return DefaultLocale
+}
+
+// Key returns the label of a key in the locale of the context, falling back to the default
+// locale and then to the key itself
+func Key(ctx context.Context, key string) string {
+	return Text(ctx, key, key)
+}
+
+// Text returns the label of a key in the locale of the context, falling back to the default
+// locale and then to the given text. It is used for titles whose English text is compiled in.
+func Text(ctx context.Context, key, fallback string) string {
+	locale := FromContext(ctx)
+	if label, ok := Label(locale, key); ok {
+		return label
+	}
+	if locale != DefaultLocale {
+		if label, ok := Label(DefaultLocale, key); ok {
+			return label
+		}
+	}
+	return fallback
+}
+
+// Message translates an API message into a locale, returning it unchanged when the language
+// file of the locale has no translation
+func Message(locale, message string) string {
+	filesMu.RLock()
+	defer filesMu.RUnlock()
+
+	if translated, ok := files[locale].Messages[message]; ok {
+		return translated
+	}
+	return message
+}
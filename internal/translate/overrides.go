@@ -0,0 +1,59 @@
+package translate
+
+import "sync"
+
+// DefaultLocale is the locale of the built-in labels and of report headers
+const DefaultLocale = "vi"
+
+var (
+	overridesMu sync.RWMutex
+	overrides   = map[string]map[string]string{}
+)
+
+// SetOverrides replaces the runtime labels of a locale; they take precedence over the built-in ones
+func SetOverrides(locale string, labels map[string]string) {
+	copied := make(map[string]string, len(labels))
+	for key, label := range labels {
+		copied[key] = label
+	}
+
+	overridesMu.Lock()
+	overrides[locale] = copied
+	overridesMu.Unlock()
+}
+
+// Label returns the label of a key in a locale, looking at the runtime overrides, then the
+// language file of the locale and, for the default locale, the compiled labels. It returns
+// false when none of them has the key.
+func Label(locale, key string) (string, bool) {
+	overridesMu.RLock()
+	label, ok := overrides[locale][key]
+	overridesMu.RUnlock()
+	if ok {
+		return label, true
+	}
+
+	if label, ok = fileLabel(locale, key); ok {
+		return label, true
+	}
+
+	if locale == DefaultLocale {
+		label, ok = enToVnTranslate[key]
+	}
+	return label, ok
+}
+
+// BuiltIn returns a copy of the labels shipped with the application for a locale: those of its
+// language file and, for the default locale, the compiled ones
+func BuiltIn(locale string) map[string]string {
+	labels := make(map[string]string)
+	if locale == DefaultLocale {
+		for key, label := range enToVnTranslate {
+			labels[key] = label
+		}
+	}
+	for key, label := range fileLabels(locale) {
+		labels[key] = label
+	}
+	return labels
+}
@@ -3,7 +3,7 @@ package translate
 func TranslateReport(report map[string]interface{}) map[string]interface{} {
 	translatedReport := make(map[string]interface{})
 	for key, value := range report {
-		if translatedValue, ok := enToVnTranslate[key]; ok {
+		if translatedValue, ok := Label(DefaultLocale, key); ok {
 			translatedReport[translatedValue] = value
 		}
 	}
@@ -11,7 +11,7 @@ func TranslateReport(report map[string]interface{}) map[string]interface{} {
 }
 
 func TranslateKey(key string) string {
-	if translatedValue, ok := enToVnTranslate[key]; ok {
+	if translatedValue, ok := Label(DefaultLocale, key); ok {
 		return translatedValue
 	}
 	return key
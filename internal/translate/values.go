@@ -10,4 +10,43 @@ var enToVnTranslate = map[string]string{
 	"detailed_order_number": "Mã Đơn Hàng Chi Tiết",
 	"invoice_number":        "Hóa Đơn",
 	"notes":                 "Ghi Chú",
+	"invoice_status":        "Trạng Thái Hóa Đơn",
+	"invoiced":              "Đã Xuất Hóa Đơn",
+	"uninvoiced":            "Chưa Xuất Hóa Đơn",
+	"all":                   "Tất Cả",
+	"aging_bucket":          "Tuổi Nợ (Ngày)",
+	"document_count":        "Số Chứng Từ",
+	"aging_total_amt":       "Tổng Nội Tệ",
+	"aging_percent":         "Tỷ Lệ (%)",
+	"total":                 "Tổng Cộng",
+	"converted_total":       "Thành Tiền Quy Đổi",
+	"item_code":             "Mã Vật Tư",
+	"item_name":             "Tên Vật Tư",
+	"unit":                  "Đơn Vị Tính",
+	"warehouse_code":        "Mã Kho",
+	"warehouse_name":        "Tên Kho",
+	"opening_qty":           "Tồn Đầu Kỳ",
+	"qty_in":                "Nhập Trong Kỳ",
+	"qty_out":               "Xuất Trong Kỳ",
+	"balance_qty":           "Tồn Cuối Kỳ",
+	"on_hand_qty":           "Tồn Kho",
+	"shipping_document":     "Phiếu Xuất",
+	"shipped_amt":           "Tiền Xuất Hàng",
+	"ar_document":           "Chứng Từ Công Nợ",
+	"invoiced_amt":          "Tiền Công Nợ",
+	"ar_shipped_amt":        "Tổng Xuất Trên Công Nợ",
+	"difference":            "Chênh Lệch",
+	"reconciliation_status": "Kết Quả Đối Chiếu",
+	"matched":               "Khớp",
+	"shipped_uninvoiced":    "Đã Xuất Chưa Lập Công Nợ",
+	"amount_mismatch":       "Lệch Số Tiền",
+	"receipt_date":          "Ngày Nhập Hàng",
+	"purchase_receipt":      "Phiếu Nhập Hàng",
+	"supplier_code":         "Mã Nhà Cung Cấp",
+	"supplier_name":         "Nhà Cung Cấp",
+	"purchase_order":        "Đơn Mua Hàng",
+	"order_qty":             "Số Lượng Đặt",
+	"received_qty":          "Số Lượng Nhập",
+	"amount_trans":          "Tiền Nguyên Tệ",
+	"amount":                "Tiền Nội Tệ",
 }
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed five-field cron expression: minute hour day-of-month month day-of-week
+type CronSchedule struct {
+	minutes     [60]bool
+	hours       [24]bool
+	daysOfMonth [32]bool
+	months      [13]bool
+	daysOfWeek  [7]bool
+
+	// As in standard cron, when both day fields are restricted a day matching either one fires
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+// cronSearchLimit bounds the search for the next run so an impossible date such as 30 February ends
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// ParseCron parses a cron expression. Each field accepts *, numbers, ranges (1-5), lists (1,15)
+// and steps (*/15 or 0-30/10); day-of-week runs from 0 (Sunday) to 6, with 7 also meaning Sunday.
+func ParseCron(expr string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression must have 5 fields, got %d", len(fields))
+	}
+
+	var schedule CronSchedule
+	var daysOfWeek [8]bool
+
+	if err := parseCronField(fields[0], 0, 59, schedule.minutes[:]); err != nil {
+		return nil, fmt.Errorf("invalid minute field: %w", err)
+	}
+	if err := parseCronField(fields[1], 0, 23, schedule.hours[:]); err != nil {
+		return nil, fmt.Errorf("invalid hour field: %w", err)
+	}
+	if err := parseCronField(fields[2], 1, 31, schedule.daysOfMonth[:]); err != nil {
+		return nil, fmt.Errorf("invalid day-of-month field: %w", err)
+	}
+	if err := parseCronField(fields[3], 1, 12, schedule.months[:]); err != nil {
+		return nil, fmt.Errorf("invalid month field: %w", err)
+	}
+	if err := parseCronField(fields[4], 0, 7, daysOfWeek[:]); err != nil {
+		return nil, fmt.Errorf("invalid day-of-week field: %w", err)
+	}
+
+	copy(schedule.daysOfWeek[:], daysOfWeek[:7])
+	if daysOfWeek[7] {
+		schedule.daysOfWeek[0] = true
+	}
+	schedule.anyDayOfMonth = strings.HasPrefix(fields[2], "*")
+	schedule.anyDayOfWeek = strings.HasPrefix(fields[4], "*")
+
+	return &schedule, nil
+}
+
+// Next returns the first time after the given one that matches the schedule, or the zero time
+// when nothing matches within five years
+func (s *CronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		if !s.months[t.Month()] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// matchesDay applies the cron rule for combining the day-of-month and day-of-week fields
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := s.daysOfMonth[t.Day()]
+	dayOfWeek := s.daysOfWeek[t.Weekday()]
+
+	switch {
+	case s.anyDayOfMonth && s.anyDayOfWeek:
+		return true
+	case s.anyDayOfMonth:
+		return dayOfWeek
+	case s.anyDayOfWeek:
+		return dayOfMonth
+	default:
+		return dayOfMonth || dayOfWeek
+	}
+}
+
+// parseCronField marks the values selected by one field
+func parseCronField(field string, min, max int, values []bool) error {
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		start, end := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = parseCronValue(from, min, max); err != nil {
+				return err
+			}
+			if end, err = parseCronValue(to, min, max); err != nil {
+				return err
+			}
+			if start > end {
+				return fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			value, err := parseCronValue(rangePart, min, max)
+			if err != nil {
+				return err
+			}
+			start = value
+			if !hasStep {
+				end = value
+			}
+		}
+
+		for value := start; value <= end; value += step {
+			values[value] = true
+		}
+	}
+
+	return nil
+}
+
+// parseCronValue parses one number of a field and checks its bounds
+func parseCronValue(value string, min, max int) (int, error) {
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	if number < min || number > max {
+		return 0, fmt.Errorf("value %d is out of range %d-%d", number, min, max)
+	}
+	return number, nil
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DownloadLinkPath is the API route that serves files through signed download links
+const DownloadLinkPath = "/api/downloads/"
+
+var (
+	// ErrInvalidDownloadLink is returned for a download link whose signature does not match the
+	// file and the user
+	ErrInvalidDownloadLink = errors.New("invalid download link")
+	// ErrDownloadLinkExpired is returned for a download link past its expiry
+	ErrDownloadLinkExpired = errors.New("download link expired")
+)
+
+var (
+	downloadLinkMu  sync.RWMutex
+	downloadLinkKey []byte
+	downloadLinkTTL = 24 * time.Hour
+)
+
+// SetDownloadLinkKey sets the key download links are signed with and how long they are valid
+func SetDownloadLinkKey(key string, ttl time.Duration) {
+	downloadLinkMu.Lock()
+	defer downloadLinkMu.Unlock()
+
+	downloadLinkKey = []byte(key)
+	if ttl > 0 {
+		downloadLinkTTL = ttl
+	}
+}
+
+// SignDownloadLink returns a link to download a stored file that only the given user can use,
+// and only until it expires
+func SignDownloadLink(fileName string, userID int) string {
+	downloadLinkMu.RLock()
+	expires := time.Now().Add(downloadLinkTTL).Unix()
+	downloadLinkMu.RUnlock()
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", downloadLinkSignature(fileName, userID, expires))
+	return DownloadLinkPath + url.PathEscape(fileName) + "?" + query.Encode()
+}
+
+// VerifyDownloadLink checks the expires and signature parameters of a download link against the
+// file and the user who follows it
+func VerifyDownloadLink(fileName string, userID int, expires, signature string) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || signature == "" {
+		return ErrInvalidDownloadLink
+	}
+
+	expected := downloadLinkSignature(fileName, userID, expiresAt)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalidDownloadLink
+	}
+	if time.Now().Unix() > expiresAt {
+		return ErrDownloadLinkExpired
+	}
+	return nil
+}
+
+// downloadLinkSignature returns the hex encoded HMAC-SHA256 of the file, user and expiry
+func downloadLinkSignature(fileName string, userID int, expires int64) string {
+	downloadLinkMu.RLock()
+	mac := hmac.New(sha256.New, downloadLinkKey)
+	downloadLinkMu.RUnlock()
+
+	fmt.Fprintf(mac, "%s\n%d\n%d", fileName, userID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
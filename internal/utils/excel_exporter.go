@@ -2,7 +2,9 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"erp-excel/internal/translate"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -11,17 +13,168 @@ import (
 	excelize "github.com/xuri/excelize/v2"
 )
 
-// ExportToExcel exports data to Excel file
-func ExportToExcel(data []map[string]interface{}, headers []string, title string) (string, *bytes.Buffer, error) {
-	// Create a new Excel file
-	f := excelize.NewFile()
+// ExcelContentType is the MIME type of generated .xlsx files
+const ExcelContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// ExcelSheet is one worksheet of an exported workbook
+type ExcelSheet struct {
+	Name    string // made valid and unique within the workbook by ExportSheetsToExcel
+	Title   string
+	Headers []string
+	Data    []map[string]interface{}
+
+	// RowColors optionally holds a fill color (hex, e.g. "FFC7CE") per data row; empty means no fill
+	RowColors []string
+
+	// ColumnTypes optionally types columns by header, so amounts and dates are written as numbers
+	// and dates Excel can sum and sort rather than as text
+	ColumnTypes map[string]ExcelColumnType
+}
+
+// excelCancelCheckRows is the number of rows written between checks for a cancelled export
+const excelCancelCheckRows = 500
+
+// ExportToExcel exports data to Excel file, followed by any extra sheets such as summaries
+func ExportToExcel(ctx context.Context, data []map[string]interface{}, headers []string, title string, extraSheets ...ExcelSheet) (string, *bytes.Buffer, error) {
+	sheets := append([]ExcelSheet{{Name: "Sheet1", Title: title, Headers: headers, Data: data}}, extraSheets...)
+	return ExportSheetsToExcel(ctx, title, sheets...)
+}
+
+// ExportSheetsToExcel exports each sheet into one workbook, e.g. a summary sheet next to the
+// detail rows; the file name is derived from title. Generation stops with the context's error
+// once ctx is cancelled.
+func ExportSheetsToExcel(ctx context.Context, title string, sheets ...ExcelSheet) (string, *bytes.Buffer, error) {
+	if len(sheets) == 0 {
+		return "", nil, errors.New("no sheets to export")
+	}
+
+	// Create a new Excel file, or a copy of the branded template
+	f, templateSheet, templateOrigin, err := openExcelWorkbook()
+	if err != nil {
+		return "", nil, err
+	}
 	defer f.Close()
 
-	// Get the default sheet
-	sheetName := "Sheet1"
+	usedNames := make(map[string]bool, len(sheets))
+	if templateSheet != "" {
+		for _, name := range f.GetSheetList() {
+			usedNames[strings.ToLower(name)] = true
+		}
+	}
+
+	for i, sheet := range sheets {
+		origin := excelOrigin{row: 1}
+		switch {
+		case i == 0 && templateSheet != "":
+			// The template's sheet keeps its name, which its defined names refer to
+			sheet.Name = templateSheet
+			origin = templateOrigin
+		case i == 0:
+			// Rename the default sheet
+			sheet.Name = excelSheetName(sheet.Name, i, usedNames)
+			if err := f.SetSheetName("Sheet1", sheet.Name); err != nil {
+				return "", nil, fmt.Errorf("error naming sheet %s: %w", sheet.Name, err)
+			}
+		default:
+			sheet.Name = excelSheetName(sheet.Name, i, usedNames)
+			if _, err := f.NewSheet(sheet.Name); err != nil {
+				return "", nil, fmt.Errorf("error creating sheet %s: %w", sheet.Name, err)
+			}
+		}
+		if err := writeExcelSheet(ctx, f, sheet, origin); err != nil {
+			return "", nil, err
+		}
+	}
+
+	// Write file to buffer and return
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return "", nil, fmt.Errorf("error writing Excel to buffer: %w", err)
+	}
+	return ExcelFileName(title, TitleFileNameLength), buf, nil
+}
+
+// TitleFileNameLength is the number of characters of a title kept in its file name
+const TitleFileNameLength = 30
+
+// ExcelFileName builds a sanitized, timestamped .xlsx file name from the first maxLength
+// characters of name
+func ExcelFileName(name string, maxLength int) string {
+	// Generate timestamp for filename
+	timestamp := time.Now().Format("20060102_150405")
+
+	// Create sanitized filename
+	safeTitlePart := []rune(sanitizeFilename(name))
+	if len(safeTitlePart) > maxLength {
+		safeTitlePart = safeTitlePart[:maxLength]
+	}
+
+	// Complete filename
+	return fmt.Sprintf("%s_%s.xlsx", string(safeTitlePart), timestamp)
+}
+
+// excelSheetNameLength is the longest sheet name Excel accepts
+const excelSheetNameLength = 31
+
+// excelSheetNameReplacer replaces the characters Excel does not allow in sheet names
+var excelSheetNameReplacer = strings.NewReplacer(":", "_", "\\", "_", "/", "_", "?", "_", "*", "_", "[", "(", "]", ")")
+
+// excelSheetName makes a sheet name valid and unique among the used names, which Excel compares
+// case-insensitively. Empty names become SheetN after their position.
+func excelSheetName(name string, index int, used map[string]bool) string {
+	name = strings.Trim(strings.TrimSpace(excelSheetNameReplacer.Replace(name)), "'")
+	if name == "" {
+		name = fmt.Sprintf("Sheet%d", index+1)
+	}
+	if runes := []rune(name); len(runes) > excelSheetNameLength {
+		name = string(runes[:excelSheetNameLength])
+	}
+
+	unique := name
+	for n := 2; used[strings.ToLower(unique)]; n++ {
+		suffix := fmt.Sprintf(" (%d)", n)
+		base := []rune(name)
+		if len(base)+len(suffix) > excelSheetNameLength {
+			base = base[:excelSheetNameLength-len(suffix)]
+		}
+		unique = string(base) + suffix
+	}
+
+	used[strings.ToLower(unique)] = true
+	return unique
+}
+
+// excelCell returns the name of the cell in the zero-based column and one-based row, e.g. AA4
+func excelCell(column, row int) string {
+	name, _ := excelize.CoordinatesToCellName(column+1, row)
+	return name
+}
+
+// excelColumn returns the name of the zero-based column, e.g. AA
+func excelColumn(column int) string {
+	name, _ := excelize.ColumnNumberToName(column + 1)
+	return name
+}
+
+// writeExcelSheet writes the title, headers and data rows into a worksheet from the origin on
+func writeExcelSheet(ctx context.Context, f *excelize.File, sheet ExcelSheet, origin excelOrigin) error {
+	sheetName, data, headers, title := sheet.Name, sheet.Data, sheet.Headers, sheet.Title
+	if len(headers) == 0 {
+		return fmt.Errorf("sheet %s has no columns", sheetName)
+	}
+	firstColumn, lastColumn := origin.column, origin.column+len(headers)-1
+	titleRow, headerRow := origin.row, origin.row+2
+
+	// Push the rows below a template region, such as its footer, down to make room for the report
+	if rows := 3 + len(data) - origin.regionRows; origin.regionRows > 0 && rows > 0 {
+		if err := f.InsertRows(sheetName, origin.row+origin.regionRows, rows); err != nil {
+			return fmt.Errorf("error making room in Excel template: %w", err)
+		}
+	}
 
 	// Set title
-	f.SetCellValue(sheetName, "A1", title)
+	titleCell := excelCell(firstColumn, titleRow)
+	f.SetCellValue(sheetName, titleCell, title)
 
 	// Set title style
 	titleStyle, err := f.NewStyle(&excelize.Style{
@@ -36,12 +189,12 @@ func ExportToExcel(data []map[string]interface{}, headers []string, title string
 		},
 	})
 	if err != nil {
-		return "", nil, fmt.Errorf("error creating title style: %w", err)
+		return fmt.Errorf("error creating title style: %w", err)
 	}
 
 	// Apply title style and merge cells for title
-	f.SetCellStyle(sheetName, "A1", fmt.Sprintf("%c1", rune('A'+len(headers)-1)), titleStyle)
-	f.MergeCell(sheetName, "A1", fmt.Sprintf("%c1", rune('A'+len(headers)-1)))
+	f.SetCellStyle(sheetName, titleCell, excelCell(lastColumn, titleRow), titleStyle)
+	f.MergeCell(sheetName, titleCell, excelCell(lastColumn, titleRow))
 
 	// Set headers
 	headerStyle, err := f.NewStyle(&excelize.Style{
@@ -66,18 +219,20 @@ func ExportToExcel(data []map[string]interface{}, headers []string, title string
 		},
 	})
 	if err != nil {
-		return "", nil, fmt.Errorf("error creating header style: %w", err)
+		return fmt.Errorf("error creating header style: %w", err)
+	}
+	if origin.headerStyle != 0 {
+		headerStyle = origin.headerStyle
 	}
 
 	// Write headers
 	for i, header := range headers {
-		cellPos := fmt.Sprintf("%c3", rune('A'+i))
-		f.SetCellValue(sheetName, cellPos, translate.TranslateKey(header))
+		cellPos := excelCell(firstColumn+i, headerRow)
+		f.SetCellValue(sheetName, cellPos, translate.Key(ctx, header))
 	}
 
 	// Apply header style
-	headerRange := fmt.Sprintf("A3:%c3", rune('A'+len(headers)-1))
-	f.SetCellStyle(sheetName, headerRange, headerRange, headerStyle)
+	f.SetCellStyle(sheetName, excelCell(firstColumn, headerRow), excelCell(lastColumn, headerRow), headerStyle)
 
 	// Data cell styles
 	dataStyle, err := f.NewStyle(&excelize.Style{
@@ -92,68 +247,87 @@ func ExportToExcel(data []map[string]interface{}, headers []string, title string
 		},
 	})
 	if err != nil {
-		return "", nil, fmt.Errorf("error creating data style: %w", err)
-	}
-
-	// TODO: currently, no longer using number format style
-	// numberStyle, err := f.NewStyle(&excelize.Style{
-	// 	Border: []excelize.Border{
-	// 		{Type: "left", Color: "000000", Style: 1},
-	// 		{Type: "top", Color: "000000", Style: 1},
-	// 		{Type: "bottom", Color: "000000", Style: 1},
-	// 		{Type: "right", Color: "000000", Style: 1},
-	// 	},
-	// 	Alignment: &excelize.Alignment{
-	// 		Horizontal: "right",
-	// 		Vertical:   "center",
-	// 	},
-	// 	NumFmt: 3, // #,##0 format
-	// })
-	// if err != nil {
-	// 	return "", nil, fmt.Errorf("error creating number style: %w", err)
-	// }
+		return fmt.Errorf("error creating data style: %w", err)
+	}
+	if origin.dataStyle != 0 {
+		dataStyle = origin.dataStyle
+	}
+
+	// Typed columns use number formatted variants of the row styles
+	typedStyles := &excelTypedStyles{f: f}
+
+	// Highlighted rows share the data style with a solid fill, one style per color
+	highlightStyles := make(map[string]int)
+	for _, color := range sheet.RowColors {
+		if color == "" || highlightStyles[color] != 0 {
+			continue
+		}
+		style, err := f.NewStyle(&excelize.Style{
+			Border: []excelize.Border{
+				{Type: "left", Color: "000000", Style: 1},
+				{Type: "top", Color: "000000", Style: 1},
+				{Type: "bottom", Color: "000000", Style: 1},
+				{Type: "right", Color: "000000", Style: 1},
+			},
+			Fill: excelize.Fill{
+				Type:    "pattern",
+				Color:   []string{color},
+				Pattern: 1,
+			},
+			Alignment: &excelize.Alignment{
+				Vertical: "center",
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("error creating highlight style: %w", err)
+		}
+		highlightStyles[color] = style
+	}
 
 	// Write data
 	for i, item := range data {
-		row := i + 4 // Data starts from row 4
+		if i%excelCancelCheckRows == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		row := headerRow + 1 + i // Data starts right below the headers
+
+		rowStyle := dataStyle
+		if i < len(sheet.RowColors) && sheet.RowColors[i] != "" {
+			rowStyle = highlightStyles[sheet.RowColors[i]]
+		}
 
 		for j, header := range headers {
-			cellPos := fmt.Sprintf("%c%d", rune('A'+j), row)
-			f.SetCellValue(sheetName, cellPos, item[header])
+			cellPos := excelCell(firstColumn+j, row)
+			value, numFmt := typedCellValue(item[header], sheet.ColumnTypes[header])
+			f.SetCellValue(sheetName, cellPos, value)
 
 			// Apply style based on data type
-			f.SetCellStyle(sheetName, cellPos, cellPos, dataStyle)
+			cellStyle := rowStyle
+			if numFmt != nil {
+				if cellStyle, err = typedStyles.get(rowStyle, *numFmt); err != nil {
+					return fmt.Errorf("error creating number format style: %w", err)
+				}
+			}
+			f.SetCellStyle(sheetName, cellPos, cellPos, cellStyle)
 		}
 	}
 
-	// Set column width
-	for i := range headers {
-		colName := string(rune('A' + i))
-		f.SetColWidth(sheetName, colName, colName, 15)
+	// Set column width; a template keeps its own
+	if !origin.template {
+		for i := range headers {
+			colName := excelColumn(firstColumn + i)
+			f.SetColWidth(sheetName, colName, colName, 15)
+		}
 	}
 
 	// Set row height
-	f.SetRowHeight(sheetName, 1, 30)
-	f.SetRowHeight(sheetName, 3, 25)
-
-	// Generate timestamp for filename
-	timestamp := time.Now().Format("20060102_150405")
+	f.SetRowHeight(sheetName, titleRow, 30)
+	f.SetRowHeight(sheetName, headerRow, 25)
 
-	// Create sanitized filename
-	safeTitlePart := sanitizeFilename(title)
-	if len(safeTitlePart) > 30 {
-		safeTitlePart = safeTitlePart[:30]
-	}
-
-	// Complete filename
-	filename := fmt.Sprintf("%s_%s.xlsx", safeTitlePart, timestamp)
-
-	// Write file to buffer and return
-	buf, err := f.WriteToBuffer()
-	if err != nil {
-		return "", nil, fmt.Errorf("error writing Excel to buffer: %w", err)
-	}
-	return filename, buf, nil
+	return nil
 }
 
 // sanitizeFilename removes invalid characters from filename
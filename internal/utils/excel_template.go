@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	excelize "github.com/xuri/excelize/v2"
+)
+
+// excelTemplate is a branded workbook the first sheet of every export is written into
+type excelTemplate struct {
+	data   []byte
+	sheet  string
+	region string
+}
+
+var (
+	excelTemplateMu sync.RWMutex
+	activeTemplate  *excelTemplate
+)
+
+// excelOrigin places a sheet's report in its worksheet: the title goes in the origin cell, the
+// headers two rows below and the data right after them
+type excelOrigin struct {
+	column int // zero-based
+	row    int // one-based
+
+	// set for a template region
+	template    bool
+	regionRows  int // rows of the region, replaced by the report; the rows below are pushed down
+	headerStyle int // style of the region's first row, 0 for the built-in header style
+	dataStyle   int // style of the region's second row, 0 for the built-in data style
+}
+
+// LoadExcelTemplate makes exports fill their first sheet into a copy of the .xlsx template at
+// path, so they carry its logo, company header, footer and styles. The report is written at the
+// region, a defined name of the template whose first and optional second row give the header
+// and data styles; rows below it, such as a footer, are pushed down. Without a region the
+// report starts two rows below the content of the sheet, which defaults to the template's
+// first sheet. An empty path goes back to bare workbooks.
+func LoadExcelTemplate(path, sheet, region string) error {
+	if path == "" {
+		excelTemplateMu.Lock()
+		activeTemplate = nil
+		excelTemplateMu.Unlock()
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading Excel template: %w", err)
+	}
+
+	// Resolve the sheet and region once so a bad template fails at startup, not on every export
+	template := &excelTemplate{data: data, sheet: sheet, region: region}
+	f, resolvedSheet, _, err := template.open()
+	if err != nil {
+		return err
+	}
+	f.Close()
+	template.sheet = resolvedSheet
+
+	excelTemplateMu.Lock()
+	activeTemplate = template
+	excelTemplateMu.Unlock()
+	return nil
+}
+
+// openExcelWorkbook opens a copy of the loaded template, or a new workbook without one. It
+// returns the sheet the first export sheet goes into and where, or an empty sheet name when
+// that sheet still has to be named.
+func openExcelWorkbook() (*excelize.File, string, excelOrigin, error) {
+	excelTemplateMu.RLock()
+	template := activeTemplate
+	excelTemplateMu.RUnlock()
+
+	if template == nil {
+		return excelize.NewFile(), "", excelOrigin{row: 1}, nil
+	}
+	return template.open()
+}
+
+// open reads a copy of the template and locates where the report goes
+func (t *excelTemplate) open() (*excelize.File, string, excelOrigin, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(t.data))
+	if err != nil {
+		return nil, "", excelOrigin{}, fmt.Errorf("error opening Excel template: %w", err)
+	}
+
+	sheet, origin, err := t.locate(f)
+	if err != nil {
+		f.Close()
+		return nil, "", excelOrigin{}, err
+	}
+	return f, sheet, origin, nil
+}
+
+// locate finds the sheet and origin of the report in an opened template
+func (t *excelTemplate) locate(f *excelize.File) (string, excelOrigin, error) {
+	if t.region != "" {
+		return templateRegion(f, t.region)
+	}
+
+	sheet := t.sheet
+	if sheet == "" {
+		sheet = f.GetSheetName(0)
+	}
+	if index, err := f.GetSheetIndex(sheet); err != nil || index < 0 {
+		return "", excelOrigin{}, fmt.Errorf("excel template has no sheet %q", sheet)
+	}
+
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return "", excelOrigin{}, fmt.Errorf("error reading Excel template sheet: %w", err)
+	}
+	return sheet, excelOrigin{row: len(rows) + 2, template: true}, nil
+}
+
+// templateRegion resolves a defined name such as 'Report'!$A$6:$H$7 to its sheet and origin
+func templateRegion(f *excelize.File, region string) (string, excelOrigin, error) {
+	var refersTo string
+	for _, name := range f.GetDefinedName() {
+		if strings.EqualFold(name.Name, region) {
+			refersTo = name.RefersTo
+			break
+		}
+	}
+	if refersTo == "" {
+		return "", excelOrigin{}, fmt.Errorf("excel template has no region %q", region)
+	}
+
+	separator := strings.LastIndex(refersTo, "!")
+	if separator < 0 {
+		return "", excelOrigin{}, fmt.Errorf("excel template region %q does not refer to a sheet", region)
+	}
+	sheet := strings.Trim(refersTo[:separator], "'=")
+	cells := strings.Split(strings.ReplaceAll(refersTo[separator+1:], "$", ""), ":")
+
+	column, row, err := excelize.CellNameToCoordinates(cells[0])
+	if err != nil {
+		return "", excelOrigin{}, fmt.Errorf("invalid Excel template region %q: %w", region, err)
+	}
+	lastRow := row
+	if len(cells) > 1 {
+		if _, lastRow, err = excelize.CellNameToCoordinates(cells[1]); err != nil {
+			return "", excelOrigin{}, fmt.Errorf("invalid Excel template region %q: %w", region, err)
+		}
+	}
+
+	origin := excelOrigin{column: column - 1, row: row, template: true, regionRows: lastRow - row + 1}
+	if origin.headerStyle, err = f.GetCellStyle(sheet, cells[0]); err != nil {
+		return "", excelOrigin{}, fmt.Errorf("error reading Excel template region style: %w", err)
+	}
+	if origin.regionRows > 1 {
+		if origin.dataStyle, err = f.GetCellStyle(sheet, excelCell(origin.column, row+1)); err != nil {
+			return "", excelOrigin{}, fmt.Errorf("error reading Excel template region style: %w", err)
+		}
+	}
+
+	return sheet, origin, nil
+}
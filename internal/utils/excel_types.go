@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	excelize "github.com/xuri/excelize/v2"
+)
+
+// ExcelColumnType is how the values of an export column are written
+type ExcelColumnType string
+
+// Column types of ExcelSheet.ColumnTypes; columns without a type are written as they are
+const (
+	ExcelText     ExcelColumnType = "string"
+	ExcelNumber   ExcelColumnType = "number"
+	ExcelDate     ExcelColumnType = "date"
+	ExcelCurrency ExcelColumnType = "currency"
+)
+
+// excelDateLayouts are the layouts date columns are parsed with, the reports' dd/mm/yyyy first
+var excelDateLayouts = []string{"02/01/2006", "2006-01-02", time.RFC3339}
+
+// excelNumFmt is a built-in number format ID or a custom format code
+type excelNumFmt struct {
+	id     int
+	custom string
+}
+
+var (
+	excelIntegerFmt = excelNumFmt{id: 3}                // #,##0
+	excelDecimalFmt = excelNumFmt{id: 4}                // #,##0.00
+	excelDateFmt    = excelNumFmt{custom: "dd/mm/yyyy"} // matches the reports' date text
+)
+
+// typedCellValue parses a value for a column of the given type and returns the number format
+// to show it with. Values that do not parse, such as the label of a total row, are returned as
+// they are without a format.
+func typedCellValue(value interface{}, columnType ExcelColumnType) (interface{}, *excelNumFmt) {
+	switch columnType {
+	case ExcelNumber, ExcelCurrency:
+		number, ok := parseExcelNumber(value)
+		if !ok {
+			return value, nil
+		}
+		if columnType == ExcelCurrency || number != math.Trunc(number) {
+			return number, &excelDecimalFmt
+		}
+		return number, &excelIntegerFmt
+	case ExcelDate:
+		date, ok := parseExcelDate(value)
+		if !ok {
+			return value, nil
+		}
+		return date, &excelDateFmt
+	case ExcelText:
+		// Codes such as order numbers stay text even when they look like numbers
+		if _, ok := value.(string); !ok && value != nil {
+			return fmt.Sprint(value), nil
+		}
+	}
+	return value, nil
+}
+
+// parseExcelNumber reads a number, including text with thousands separators such as 1,234.50
+func parseExcelNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case string:
+		text := strings.ReplaceAll(strings.TrimSpace(v), ",", "")
+		if text == "" {
+			return 0, false
+		}
+		number, err := strconv.ParseFloat(text, 64)
+		return number, err == nil
+	}
+	return 0, false
+}
+
+// parseExcelDate reads a date given as a time or as text in one of excelDateLayouts
+func parseExcelDate(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, !v.IsZero()
+	case string:
+		text := strings.TrimSpace(v)
+		for _, layout := range excelDateLayouts {
+			if date, err := time.Parse(layout, text); err == nil {
+				return date, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// excelTypedStyles derives number formatted variants of a sheet's cell styles, creating each
+// variant once
+type excelTypedStyles struct {
+	f      *excelize.File
+	styles map[excelTypedStyleKey]int
+}
+
+type excelTypedStyleKey struct {
+	base   int
+	numFmt excelNumFmt
+}
+
+// get returns the base style with the number format applied
+func (s *excelTypedStyles) get(base int, numFmt excelNumFmt) (int, error) {
+	key := excelTypedStyleKey{base: base, numFmt: numFmt}
+	if style, ok := s.styles[key]; ok {
+		return style, nil
+	}
+
+	style, err := s.f.GetStyle(base)
+	if err != nil {
+		return 0, err
+	}
+	style.NumFmt = numFmt.id
+	style.CustomNumFmt = nil
+	if numFmt.custom != "" {
+		custom := numFmt.custom
+		style.CustomNumFmt = &custom
+	}
+
+	id, err := s.f.NewStyle(style)
+	if err != nil {
+		return 0, err
+	}
+	if s.styles == nil {
+		s.styles = make(map[excelTypedStyleKey]int)
+	}
+	s.styles[key] = id
+	return id, nil
+}
@@ -1,6 +1,10 @@
 package utils
 
-import "os"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+)
 
 // FileExists checks if a file exists and is not a directory
 func FileExists(filename string) bool {
@@ -10,3 +14,9 @@ func FileExists(filename string) bool {
 	}
 	return !info.IsDir()
 }
+
+// Checksum returns the hex encoded SHA-256 of data
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
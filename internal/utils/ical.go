@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"time"
+)
+
+// CalendarEvent is a single VEVENT in an iCalendar feed
+type CalendarEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+}
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// BuildICalendar renders events as an RFC 5545 calendar
+func BuildICalendar(name string, events []CalendarEvent) []byte {
+	var buf bytes.Buffer
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+
+	writeICalLine(&buf, "BEGIN:VCALENDAR")
+	writeICalLine(&buf, "VERSION:2.0")
+	writeICalLine(&buf, "PRODID:-//KanBan//Report Calendar//EN")
+	writeICalLine(&buf, "CALSCALE:GREGORIAN")
+	writeICalLine(&buf, "METHOD:PUBLISH")
+	writeICalLine(&buf, "X-WR-CALNAME:"+icalEscaper.Replace(name))
+
+	for _, event := range events {
+		writeICalLine(&buf, "BEGIN:VEVENT")
+		writeICalLine(&buf, "UID:"+event.UID)
+		writeICalLine(&buf, "DTSTAMP:"+stamp)
+		if event.AllDay {
+			end := event.End
+			if !end.After(event.Start) {
+				end = event.Start.AddDate(0, 0, 1)
+			}
+			writeICalLine(&buf, "DTSTART;VALUE=DATE:"+event.Start.Format("20060102"))
+			writeICalLine(&buf, "DTEND;VALUE=DATE:"+end.Format("20060102"))
+		} else {
+			end := event.End
+			if !end.After(event.Start) {
+				end = event.Start.Add(15 * time.Minute)
+			}
+			writeICalLine(&buf, "DTSTART:"+event.Start.UTC().Format("20060102T150405Z"))
+			writeICalLine(&buf, "DTEND:"+end.UTC().Format("20060102T150405Z"))
+		}
+		writeICalLine(&buf, "SUMMARY:"+icalEscaper.Replace(event.Summary))
+		if event.Description != "" {
+			writeICalLine(&buf, "DESCRIPTION:"+icalEscaper.Replace(event.Description))
+		}
+		writeICalLine(&buf, "END:VEVENT")
+	}
+
+	writeICalLine(&buf, "END:VCALENDAR")
+	return buf.Bytes()
+}
+
+// writeICalLine writes a content line, folding it at 75 octets without splitting UTF-8 characters
+func writeICalLine(buf *bytes.Buffer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74 // continuation lines start with a space
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
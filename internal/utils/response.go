@@ -20,6 +20,14 @@ func ErrorResponse(message string, error string) fiber.Map {
 	}
 }
 
+// CodedErrorResponse returns a standardized error response with the code of the error, which
+// clients branch on rather than on the messages
+func CodedErrorResponse(message string, error string, code string) fiber.Map {
+	response := ErrorResponse(message, error)
+	response["code"] = code
+	return response
+}
+
 // PaginatedResponse returns a response with pagination metadata
 func PaginatedResponse(data interface{}, page, limit, total int, message string) fiber.Map {
 	totalPages := (total + limit - 1) / limit
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"erp-excel/internal/apperror"
 	"fmt"
 	"strings"
 
@@ -9,15 +10,26 @@ import (
 
 var validate = validator.New()
 
-// ValidateStruct validates a struct against its validation tags
+// normalizer is implemented by requests that accept deprecated spellings of their fields, such as
+// dto.DateRangeRequest and the requests embedding it
+type normalizer interface {
+	Normalize()
+}
+
+// ValidateStruct validates a struct against its validation tags. Requests with deprecated field
+// spellings are normalized first, so the canonical fields are the ones validated and used. The
+// errors of the tags wrap apperror.ErrValidation.
 func ValidateStruct(s interface{}) error {
+	if request, ok := s.(normalizer); ok {
+		request.Normalize()
+	}
 	if err := validate.Struct(s); err != nil {
 		if validationErrors, ok := err.(validator.ValidationErrors); ok {
 			errorMessages := make([]string, 0, len(validationErrors))
 			for _, e := range validationErrors {
 				errorMessages = append(errorMessages, formatValidationError(e))
 			}
-			return fmt.Errorf("validation failed: %s", strings.Join(errorMessages, "; "))
+			return fmt.Errorf("%w: %s", apperror.ErrValidation, strings.Join(errorMessages, "; "))
 		}
 		return err
 	}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ZipContentType is the MIME type of generated .zip bundles
+const ZipContentType = "application/zip"
+
+// ZipFile is one file to add to a ZIP archive. Open is called when the file is written, so only
+// one file is open at a time.
+type ZipFile struct {
+	Name string
+	Open func() (io.ReadCloser, error)
+}
+
+// ZipFiles writes a ZIP archive of the files to w. Files with the same name are numbered, as in
+// "report (2).xlsx", so none of them is lost when the archive is extracted.
+func ZipFiles(w io.Writer, files []ZipFile) error {
+	archive := zip.NewWriter(w)
+	seen := make(map[string]int, len(files))
+
+	for _, file := range files {
+		name := filepath.Base(file.Name)
+		key := strings.ToLower(name)
+		seen[key]++
+		if count := seen[key]; count > 1 {
+			ext := filepath.Ext(name)
+			name = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), count, ext)
+		}
+
+		if err := addZipFile(archive, name, file.Open); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("error closing zip archive: %w", err)
+	}
+	return nil
+}
+
+// addZipFile copies one file into the archive
+func addZipFile(archive *zip.Writer, name string, open func() (io.ReadCloser, error)) error {
+	content, err := open()
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", name, err)
+	}
+	defer content.Close()
+
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	}
+	entry, err := archive.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("error adding %s to zip archive: %w", name, err)
+	}
+	if _, err := io.Copy(entry, content); err != nil {
+		return fmt.Errorf("error writing %s to zip archive: %w", name, err)
+	}
+	return nil
+}
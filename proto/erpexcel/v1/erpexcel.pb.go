@@ -0,0 +1,889 @@
+// Services of the gRPC server for other internal services, enabled with grpc.enabled. They share
+// the service layer of the REST API. A call naming a user_id acts as that user, with the user's
+// operations and department; a call without one acts as the calling service, like a service
+// account API key with every scope.
+//
+// The Go code next to this file is generated with protoc-gen-go and protoc-gen-go-grpc; run
+// go generate ./proto/... after changing it.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: erpexcel/v1/erpexcel.proto
+
+package erpexcelv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ListReportsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        int64                  `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListReportsRequest) Reset() {
+	*x = ListReportsRequest{}
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListReportsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListReportsRequest) ProtoMessage() {}
+
+func (x *ListReportsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListReportsRequest.ProtoReflect.Descriptor instead.
+func (*ListReportsRequest) Descriptor() ([]byte, []int) {
+	return file_erpexcel_v1_erpexcel_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ListReportsRequest) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+type ListReportsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reports       []*Report              `protobuf:"bytes,1,rep,name=reports,proto3" json:"reports,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListReportsResponse) Reset() {
+	*x = ListReportsResponse{}
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListReportsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListReportsResponse) ProtoMessage() {}
+
+func (x *ListReportsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListReportsResponse.ProtoReflect.Descriptor instead.
+func (*ListReportsResponse) Descriptor() ([]byte, []int) {
+	return file_erpexcel_v1_erpexcel_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListReportsResponse) GetReports() []*Report {
+	if x != nil {
+		return x.Reports
+	}
+	return nil
+}
+
+type Report struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Description   string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	UsesDates     bool                   `protobuf:"varint,4,opt,name=uses_dates,json=usesDates,proto3" json:"uses_dates,omitempty"`
+	Parameters    []*ReportParameter     `protobuf:"bytes,5,rep,name=parameters,proto3" json:"parameters,omitempty"`
+	Columns       []string               `protobuf:"bytes,6,rep,name=columns,proto3" json:"columns,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Report) Reset() {
+	*x = Report{}
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Report) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Report) ProtoMessage() {}
+
+func (x *Report) ProtoReflect() protoreflect.Message {
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Report.ProtoReflect.Descriptor instead.
+func (*Report) Descriptor() ([]byte, []int) {
+	return file_erpexcel_v1_erpexcel_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Report) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Report) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Report) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Report) GetUsesDates() bool {
+	if x != nil {
+		return x.UsesDates
+	}
+	return false
+}
+
+func (x *Report) GetParameters() []*ReportParameter {
+	if x != nil {
+		return x.Parameters
+	}
+	return nil
+}
+
+func (x *Report) GetColumns() []string {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+type ReportParameter struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Required      bool                   `protobuf:"varint,3,opt,name=required,proto3" json:"required,omitempty"`
+	Default       string                 `protobuf:"bytes,4,opt,name=default,proto3" json:"default,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReportParameter) Reset() {
+	*x = ReportParameter{}
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReportParameter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReportParameter) ProtoMessage() {}
+
+func (x *ReportParameter) ProtoReflect() protoreflect.Message {
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReportParameter.ProtoReflect.Descriptor instead.
+func (*ReportParameter) Descriptor() ([]byte, []int) {
+	return file_erpexcel_v1_erpexcel_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ReportParameter) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ReportParameter) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ReportParameter) GetRequired() bool {
+	if x != nil {
+		return x.Required
+	}
+	return false
+}
+
+func (x *ReportParameter) GetDefault() string {
+	if x != nil {
+		return x.Default
+	}
+	return ""
+}
+
+type RunReportRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Code   string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	UserId int64                  `protobuf:"varint,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// The date range of the report, either from_date and to_date or a period such as this_month
+	FromDate *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=from_date,json=fromDate,proto3" json:"from_date,omitempty"`
+	ToDate   *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=to_date,json=toDate,proto3" json:"to_date,omitempty"`
+	Period   string                 `protobuf:"bytes,5,opt,name=period,proto3" json:"period,omitempty"`
+	// The other parameters of the report by name
+	Params        map[string]string `protobuf:"bytes,6,rep,name=params,proto3" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RunReportRequest) Reset() {
+	*x = RunReportRequest{}
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RunReportRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunReportRequest) ProtoMessage() {}
+
+func (x *RunReportRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunReportRequest.ProtoReflect.Descriptor instead.
+func (*RunReportRequest) Descriptor() ([]byte, []int) {
+	return file_erpexcel_v1_erpexcel_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RunReportRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *RunReportRequest) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *RunReportRequest) GetFromDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FromDate
+	}
+	return nil
+}
+
+func (x *RunReportRequest) GetToDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ToDate
+	}
+	return nil
+}
+
+func (x *RunReportRequest) GetPeriod() string {
+	if x != nil {
+		return x.Period
+	}
+	return ""
+}
+
+func (x *RunReportRequest) GetParams() map[string]string {
+	if x != nil {
+		return x.Params
+	}
+	return nil
+}
+
+type RunReportResponse struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Code        string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	ReportName  string                 `protobuf:"bytes,2,opt,name=report_name,json=reportName,proto3" json:"report_name,omitempty"`
+	GeneratedAt *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=generated_at,json=generatedAt,proto3" json:"generated_at,omitempty"`
+	Columns     []string               `protobuf:"bytes,4,rep,name=columns,proto3" json:"columns,omitempty"`
+	// The rows of the report, keyed by column; dates are RFC 3339 strings
+	Items    []*structpb.Struct `protobuf:"bytes,5,rep,name=items,proto3" json:"items,omitempty"`
+	RowCount int32              `protobuf:"varint,6,opt,name=row_count,json=rowCount,proto3" json:"row_count,omitempty"`
+	// The report stopped at its row limit
+	Truncated     bool `protobuf:"varint,7,opt,name=truncated,proto3" json:"truncated,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RunReportResponse) Reset() {
+	*x = RunReportResponse{}
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RunReportResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunReportResponse) ProtoMessage() {}
+
+func (x *RunReportResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunReportResponse.ProtoReflect.Descriptor instead.
+func (*RunReportResponse) Descriptor() ([]byte, []int) {
+	return file_erpexcel_v1_erpexcel_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *RunReportResponse) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *RunReportResponse) GetReportName() string {
+	if x != nil {
+		return x.ReportName
+	}
+	return ""
+}
+
+func (x *RunReportResponse) GetGeneratedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.GeneratedAt
+	}
+	return nil
+}
+
+func (x *RunReportResponse) GetColumns() []string {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+func (x *RunReportResponse) GetItems() []*structpb.Struct {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *RunReportResponse) GetRowCount() int32 {
+	if x != nil {
+		return x.RowCount
+	}
+	return 0
+}
+
+func (x *RunReportResponse) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+type GetUserRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to User:
+	//
+	//	*GetUserRequest_Id
+	//	*GetUserRequest_Username
+	User          isGetUserRequest_User `protobuf_oneof:"user"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUserRequest) Reset() {
+	*x = GetUserRequest{}
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUserRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUserRequest) ProtoMessage() {}
+
+func (x *GetUserRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUserRequest.ProtoReflect.Descriptor instead.
+func (*GetUserRequest) Descriptor() ([]byte, []int) {
+	return file_erpexcel_v1_erpexcel_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetUserRequest) GetUser() isGetUserRequest_User {
+	if x != nil {
+		return x.User
+	}
+	return nil
+}
+
+func (x *GetUserRequest) GetId() int64 {
+	if x != nil {
+		if x, ok := x.User.(*GetUserRequest_Id); ok {
+			return x.Id
+		}
+	}
+	return 0
+}
+
+func (x *GetUserRequest) GetUsername() string {
+	if x != nil {
+		if x, ok := x.User.(*GetUserRequest_Username); ok {
+			return x.Username
+		}
+	}
+	return ""
+}
+
+type isGetUserRequest_User interface {
+	isGetUserRequest_User()
+}
+
+type GetUserRequest_Id struct {
+	Id int64 `protobuf:"varint,1,opt,name=id,proto3,oneof"`
+}
+
+type GetUserRequest_Username struct {
+	Username string `protobuf:"bytes,2,opt,name=username,proto3,oneof"`
+}
+
+func (*GetUserRequest_Id) isGetUserRequest_User() {}
+
+func (*GetUserRequest_Username) isGetUserRequest_User() {}
+
+type User struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Username      string                 `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	FullName      string                 `protobuf:"bytes,3,opt,name=full_name,json=fullName,proto3" json:"full_name,omitempty"`
+	Email         string                 `protobuf:"bytes,4,opt,name=email,proto3" json:"email,omitempty"`
+	Phone         string                 `protobuf:"bytes,5,opt,name=phone,proto3" json:"phone,omitempty"`
+	DepartmentId  int64                  `protobuf:"varint,6,opt,name=department_id,json=departmentId,proto3" json:"department_id,omitempty"`
+	Department    string                 `protobuf:"bytes,7,opt,name=department,proto3" json:"department,omitempty"`
+	IsActive      bool                   `protobuf:"varint,8,opt,name=is_active,json=isActive,proto3" json:"is_active,omitempty"`
+	Roles         []string               `protobuf:"bytes,9,rep,name=roles,proto3" json:"roles,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *User) Reset() {
+	*x = User{}
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *User) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*User) ProtoMessage() {}
+
+func (x *User) ProtoReflect() protoreflect.Message {
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use User.ProtoReflect.Descriptor instead.
+func (*User) Descriptor() ([]byte, []int) {
+	return file_erpexcel_v1_erpexcel_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *User) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *User) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *User) GetFullName() string {
+	if x != nil {
+		return x.FullName
+	}
+	return ""
+}
+
+func (x *User) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *User) GetPhone() string {
+	if x != nil {
+		return x.Phone
+	}
+	return ""
+}
+
+func (x *User) GetDepartmentId() int64 {
+	if x != nil {
+		return x.DepartmentId
+	}
+	return 0
+}
+
+func (x *User) GetDepartment() string {
+	if x != nil {
+		return x.Department
+	}
+	return ""
+}
+
+func (x *User) GetIsActive() bool {
+	if x != nil {
+		return x.IsActive
+	}
+	return false
+}
+
+func (x *User) GetRoles() []string {
+	if x != nil {
+		return x.Roles
+	}
+	return nil
+}
+
+type CheckAccessRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        int64                  `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	OperationCode string                 `protobuf:"bytes,2,opt,name=operation_code,json=operationCode,proto3" json:"operation_code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckAccessRequest) Reset() {
+	*x = CheckAccessRequest{}
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckAccessRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckAccessRequest) ProtoMessage() {}
+
+func (x *CheckAccessRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckAccessRequest.ProtoReflect.Descriptor instead.
+func (*CheckAccessRequest) Descriptor() ([]byte, []int) {
+	return file_erpexcel_v1_erpexcel_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *CheckAccessRequest) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *CheckAccessRequest) GetOperationCode() string {
+	if x != nil {
+		return x.OperationCode
+	}
+	return ""
+}
+
+type CheckAccessResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Allowed       bool                   `protobuf:"varint,1,opt,name=allowed,proto3" json:"allowed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CheckAccessResponse) Reset() {
+	*x = CheckAccessResponse{}
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CheckAccessResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CheckAccessResponse) ProtoMessage() {}
+
+func (x *CheckAccessResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_erpexcel_v1_erpexcel_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CheckAccessResponse.ProtoReflect.Descriptor instead.
+func (*CheckAccessResponse) Descriptor() ([]byte, []int) {
+	return file_erpexcel_v1_erpexcel_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *CheckAccessResponse) GetAllowed() bool {
+	if x != nil {
+		return x.Allowed
+	}
+	return false
+}
+
+var File_erpexcel_v1_erpexcel_proto protoreflect.FileDescriptor
+
+const file_erpexcel_v1_erpexcel_proto_rawDesc = "" +
+	"\n" +
+	"\x1aerpexcel/v1/erpexcel.proto\x12\verpexcel.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"-\n" +
+	"\x12ListReportsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x03R\x06userId\"D\n" +
+	"\x13ListReportsResponse\x12-\n" +
+	"\areports\x18\x01 \x03(\v2\x13.erpexcel.v1.ReportR\areports\"\xc9\x01\n" +
+	"\x06Report\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x1d\n" +
+	"\n" +
+	"uses_dates\x18\x04 \x01(\bR\tusesDates\x12<\n" +
+	"\n" +
+	"parameters\x18\x05 \x03(\v2\x1c.erpexcel.v1.ReportParameterR\n" +
+	"parameters\x12\x18\n" +
+	"\acolumns\x18\x06 \x03(\tR\acolumns\"o\n" +
+	"\x0fReportParameter\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x1a\n" +
+	"\brequired\x18\x03 \x01(\bR\brequired\x12\x18\n" +
+	"\adefault\x18\x04 \x01(\tR\adefault\"\xc3\x02\n" +
+	"\x10RunReportRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\x03R\x06userId\x127\n" +
+	"\tfrom_date\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\bfromDate\x123\n" +
+	"\ato_date\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x06toDate\x12\x16\n" +
+	"\x06period\x18\x05 \x01(\tR\x06period\x12A\n" +
+	"\x06params\x18\x06 \x03(\v2).erpexcel.v1.RunReportRequest.ParamsEntryR\x06params\x1a9\n" +
+	"\vParamsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x8b\x02\n" +
+	"\x11RunReportResponse\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x1f\n" +
+	"\vreport_name\x18\x02 \x01(\tR\n" +
+	"reportName\x12=\n" +
+	"\fgenerated_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\vgeneratedAt\x12\x18\n" +
+	"\acolumns\x18\x04 \x03(\tR\acolumns\x12-\n" +
+	"\x05items\x18\x05 \x03(\v2\x17.google.protobuf.StructR\x05items\x12\x1b\n" +
+	"\trow_count\x18\x06 \x01(\x05R\browCount\x12\x1c\n" +
+	"\ttruncated\x18\a \x01(\bR\ttruncated\"H\n" +
+	"\x0eGetUserRequest\x12\x10\n" +
+	"\x02id\x18\x01 \x01(\x03H\x00R\x02id\x12\x1c\n" +
+	"\busername\x18\x02 \x01(\tH\x00R\busernameB\x06\n" +
+	"\x04user\"\xf3\x01\n" +
+	"\x04User\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1a\n" +
+	"\busername\x18\x02 \x01(\tR\busername\x12\x1b\n" +
+	"\tfull_name\x18\x03 \x01(\tR\bfullName\x12\x14\n" +
+	"\x05email\x18\x04 \x01(\tR\x05email\x12\x14\n" +
+	"\x05phone\x18\x05 \x01(\tR\x05phone\x12#\n" +
+	"\rdepartment_id\x18\x06 \x01(\x03R\fdepartmentId\x12\x1e\n" +
+	"\n" +
+	"department\x18\a \x01(\tR\n" +
+	"department\x12\x1b\n" +
+	"\tis_active\x18\b \x01(\bR\bisActive\x12\x14\n" +
+	"\x05roles\x18\t \x03(\tR\x05roles\"T\n" +
+	"\x12CheckAccessRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x03R\x06userId\x12%\n" +
+	"\x0eoperation_code\x18\x02 \x01(\tR\roperationCode\"/\n" +
+	"\x13CheckAccessResponse\x12\x18\n" +
+	"\aallowed\x18\x01 \x01(\bR\aallowed2\xad\x01\n" +
+	"\rReportService\x12P\n" +
+	"\vListReports\x12\x1f.erpexcel.v1.ListReportsRequest\x1a .erpexcel.v1.ListReportsResponse\x12J\n" +
+	"\tRunReport\x12\x1d.erpexcel.v1.RunReportRequest\x1a\x1e.erpexcel.v1.RunReportResponse2H\n" +
+	"\vUserService\x129\n" +
+	"\aGetUser\x12\x1b.erpexcel.v1.GetUserRequest\x1a\x11.erpexcel.v1.User2a\n" +
+	"\rAccessService\x12P\n" +
+	"\vCheckAccess\x12\x1f.erpexcel.v1.CheckAccessRequest\x1a .erpexcel.v1.CheckAccessResponseB(Z&erp-excel/proto/erpexcel/v1;erpexcelv1b\x06proto3"
+
+var (
+	file_erpexcel_v1_erpexcel_proto_rawDescOnce sync.Once
+	file_erpexcel_v1_erpexcel_proto_rawDescData []byte
+)
+
+func file_erpexcel_v1_erpexcel_proto_rawDescGZIP() []byte {
+	file_erpexcel_v1_erpexcel_proto_rawDescOnce.Do(func() {
+		file_erpexcel_v1_erpexcel_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_erpexcel_v1_erpexcel_proto_rawDesc), len(file_erpexcel_v1_erpexcel_proto_rawDesc)))
+	})
+	return file_erpexcel_v1_erpexcel_proto_rawDescData
+}
+
+var file_erpexcel_v1_erpexcel_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_erpexcel_v1_erpexcel_proto_goTypes = []any{
+	(*ListReportsRequest)(nil),    // 0: erpexcel.v1.ListReportsRequest
+	(*ListReportsResponse)(nil),   // 1: erpexcel.v1.ListReportsResponse
+	(*Report)(nil),                // 2: erpexcel.v1.Report
+	(*ReportParameter)(nil),       // 3: erpexcel.v1.ReportParameter
+	(*RunReportRequest)(nil),      // 4: erpexcel.v1.RunReportRequest
+	(*RunReportResponse)(nil),     // 5: erpexcel.v1.RunReportResponse
+	(*GetUserRequest)(nil),        // 6: erpexcel.v1.GetUserRequest
+	(*User)(nil),                  // 7: erpexcel.v1.User
+	(*CheckAccessRequest)(nil),    // 8: erpexcel.v1.CheckAccessRequest
+	(*CheckAccessResponse)(nil),   // 9: erpexcel.v1.CheckAccessResponse
+	nil,                           // 10: erpexcel.v1.RunReportRequest.ParamsEntry
+	(*timestamppb.Timestamp)(nil), // 11: google.protobuf.Timestamp
+	(*structpb.Struct)(nil),       // 12: google.protobuf.Struct
+}
+var file_erpexcel_v1_erpexcel_proto_depIdxs = []int32{
+	2,  // 0: erpexcel.v1.ListReportsResponse.reports:type_name -> erpexcel.v1.Report
+	3,  // 1: erpexcel.v1.Report.parameters:type_name -> erpexcel.v1.ReportParameter
+	11, // 2: erpexcel.v1.RunReportRequest.from_date:type_name -> google.protobuf.Timestamp
+	11, // 3: erpexcel.v1.RunReportRequest.to_date:type_name -> google.protobuf.Timestamp
+	10, // 4: erpexcel.v1.RunReportRequest.params:type_name -> erpexcel.v1.RunReportRequest.ParamsEntry
+	11, // 5: erpexcel.v1.RunReportResponse.generated_at:type_name -> google.protobuf.Timestamp
+	12, // 6: erpexcel.v1.RunReportResponse.items:type_name -> google.protobuf.Struct
+	0,  // 7: erpexcel.v1.ReportService.ListReports:input_type -> erpexcel.v1.ListReportsRequest
+	4,  // 8: erpexcel.v1.ReportService.RunReport:input_type -> erpexcel.v1.RunReportRequest
+	6,  // 9: erpexcel.v1.UserService.GetUser:input_type -> erpexcel.v1.GetUserRequest
+	8,  // 10: erpexcel.v1.AccessService.CheckAccess:input_type -> erpexcel.v1.CheckAccessRequest
+	1,  // 11: erpexcel.v1.ReportService.ListReports:output_type -> erpexcel.v1.ListReportsResponse
+	5,  // 12: erpexcel.v1.ReportService.RunReport:output_type -> erpexcel.v1.RunReportResponse
+	7,  // 13: erpexcel.v1.UserService.GetUser:output_type -> erpexcel.v1.User
+	9,  // 14: erpexcel.v1.AccessService.CheckAccess:output_type -> erpexcel.v1.CheckAccessResponse
+	11, // [11:15] is the sub-list for method output_type
+	7,  // [7:11] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_erpexcel_v1_erpexcel_proto_init() }
+func file_erpexcel_v1_erpexcel_proto_init() {
+	if File_erpexcel_v1_erpexcel_proto != nil {
+		return
+	}
+	file_erpexcel_v1_erpexcel_proto_msgTypes[6].OneofWrappers = []any{
+		(*GetUserRequest_Id)(nil),
+		(*GetUserRequest_Username)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_erpexcel_v1_erpexcel_proto_rawDesc), len(file_erpexcel_v1_erpexcel_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   3,
+		},
+		GoTypes:           file_erpexcel_v1_erpexcel_proto_goTypes,
+		DependencyIndexes: file_erpexcel_v1_erpexcel_proto_depIdxs,
+		MessageInfos:      file_erpexcel_v1_erpexcel_proto_msgTypes,
+	}.Build()
+	File_erpexcel_v1_erpexcel_proto = out.File
+	file_erpexcel_v1_erpexcel_proto_goTypes = nil
+	file_erpexcel_v1_erpexcel_proto_depIdxs = nil
+}
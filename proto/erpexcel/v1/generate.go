@@ -0,0 +1,4 @@
+// Package erpexcelv1 holds the messages and service stubs generated from erpexcel.proto
+package erpexcelv1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative erpexcel/v1/erpexcel.proto